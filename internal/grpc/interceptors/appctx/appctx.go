@@ -23,19 +23,23 @@ import (
 
 	"github.com/cs3org/reva/pkg/appctx"
 	"github.com/cs3org/reva/pkg/tracing"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
 const tracerName = "appctx"
 
 // NewUnary returns a new unary interceptor that creates the application context.
+// The context carries the request ID, which is propagated to downstream services.
 func NewUnary(log zerolog.Logger) grpc.UnaryServerInterceptor {
 	interceptor := func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "appctx UnaryServerInterceptor")
 		defer span.End()
 
-		sub := log.With().Str("TraceID", span.SpanContext().TraceID().String()).Logger()
+		ctx, reqID := withRequestID(ctx)
+		sub := log.With().Str("TraceID", span.SpanContext().TraceID().String()).Str("RequestID", reqID).Logger()
 		ctx = appctx.WithLogger(ctx, &sub)
 		res, err := handler(ctx, req)
 		return res, err
@@ -45,13 +49,15 @@ func NewUnary(log zerolog.Logger) grpc.UnaryServerInterceptor {
 
 // NewStream returns a new server stream interceptor
 // that creates the application context.
+// The context carries the request ID, which is propagated to downstream services.
 func NewStream(log zerolog.Logger) grpc.StreamServerInterceptor {
 	interceptor := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 		ctx := ss.Context()
 		ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "appctx StreamServerInterceptor")
 		defer span.End()
 
-		sub := log.With().Str("TraceID", span.SpanContext().TraceID().String()).Logger()
+		ctx, reqID := withRequestID(ctx)
+		sub := log.With().Str("TraceID", span.SpanContext().TraceID().String()).Str("RequestID", reqID).Logger()
 		ctx = appctx.WithLogger(ctx, &sub)
 
 		wrapped := newWrappedServerStream(ctx, ss)
@@ -60,6 +66,24 @@ func NewStream(log zerolog.Logger) grpc.StreamServerInterceptor {
 	return interceptor
 }
 
+// withRequestID stores the request ID sent by the client in the context, generating one if absent,
+// and forwards it to the services called while handling the request.
+func withRequestID(ctx context.Context) (context.Context, string) {
+	var reqID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if lst := md.Get(appctx.RequestIDHeader); len(lst) != 0 {
+			reqID = lst[0]
+		}
+	}
+	if reqID == "" {
+		reqID = uuid.New().String()
+	}
+
+	ctx = appctx.WithRequestID(ctx, reqID)
+	ctx = metadata.AppendToOutgoingContext(ctx, appctx.RequestIDHeader, reqID)
+	return ctx, reqID
+}
+
 func newWrappedServerStream(ctx context.Context, ss grpc.ServerStream) *wrappedServerStream {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "appctx newWrappedServerStream")
 	defer span.End()
@@ -0,0 +1,81 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package appctx
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestRequestID(t *testing.T) {
+	tests := []struct {
+		name     string
+		incoming metadata.MD
+		expected string
+	}{
+		{
+			name:     "request id sent by the client",
+			incoming: metadata.Pairs(appctx.RequestIDHeader, "4e1d9b7c"),
+			expected: "4e1d9b7c",
+		},
+		{
+			name: "no metadata",
+		},
+		{
+			name:     "no request id",
+			incoming: metadata.Pairs("user-agent", "test"),
+		},
+	}
+
+	interceptor := NewUnary(zerolog.Nop())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.incoming != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.incoming)
+			}
+
+			var reqID string
+			var outgoing metadata.MD
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/test/Method"}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				reqID = appctx.RequestID(ctx)
+				outgoing, _ = metadata.FromOutgoingContext(ctx)
+				return nil, nil
+			})
+			assert.NoError(t, err)
+
+			if tt.expected != "" {
+				assert.Equal(t, tt.expected, reqID)
+			} else {
+				assert.NotEmpty(t, reqID)
+			}
+			assert.Equal(t, []string{reqID}, outgoing.Get(appctx.RequestIDHeader))
+		})
+	}
+}
+
+func TestRequestIDMissing(t *testing.T) {
+	assert.Empty(t, appctx.RequestID(context.Background()))
+}
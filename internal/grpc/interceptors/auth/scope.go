@@ -75,7 +75,7 @@ func expandAndVerifyScope(ctx context.Context, req interface{}, tokenScope map[s
 		for k := range tokenScope {
 			switch {
 			case strings.HasPrefix(k, "publicshare"):
-				if err = resolvePublicShare(ctx, ref, tokenScope[k], client, mgr); err == nil {
+				if err = resolvePublicShare(ctx, req, ref, tokenScope[k], client, mgr); err == nil {
 					return nil
 				}
 			case strings.HasPrefix(k, "share"):
@@ -236,16 +236,22 @@ func hasPermissions(ctx context.Context, client gateway.GatewayAPIClient, ref *p
 	return utils.HasPermissions(info.PermissionSet, permissionSet)
 }
 
-func resolvePublicShare(ctx context.Context, ref *provider.Reference, scope *authpb.Scope, client gateway.GatewayAPIClient, mgr token.Manager) error {
+func resolvePublicShare(ctx context.Context, req interface{}, ref *provider.Reference, s *authpb.Scope, client gateway.GatewayAPIClient, mgr token.Manager) error {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "auth resolvePublicShare")
 	defer span.End()
 
 	var share link.PublicShare
-	err := utils.UnmarshalJSONToProtoV1(scope.Resource.Value, &share)
+	err := utils.UnmarshalJSONToProtoV1(s.Resource.Value, &share)
 	if err != nil {
 		return err
 	}
 
+	// the permissions of the share are checked before the expensive
+	// resolution of the resource, and are never cached
+	if err := scope.VerifyPublicSharePermissions(s, req); err != nil {
+		return err
+	}
+
 	return checkCacheForNestedResource(ctx, ref, share.ResourceId, client, mgr)
 }
 
@@ -341,10 +347,10 @@ func checkIfNestedResource(ctx context.Context, ref *provider.Reference, parent
 		if childStat.Status.Code != rpc.Code_CODE_OK {
 			return false, statuspkg.NewErrorFromCode(childStat.Status.Code, "auth interceptor")
 		}
-		childPath = statResponse.Info.Path
+		childPath = childStat.Info.Path
 	}
 
-	return strings.HasPrefix(childPath, parentPath), nil
+	return scope.IsInSubtree(parentPath, childPath), nil
 }
 
 func extractRefForReaderRole(req interface{}) (*provider.Reference, bool) {
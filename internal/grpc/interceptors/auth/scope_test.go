@@ -0,0 +1,121 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package auth
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bluele/gcache"
+	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/auth/scope"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/token/manager/jwt"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// fakeGatewayClient is a gateway holding the paths of the resources by their opaque id.
+type fakeGatewayClient struct {
+	gateway.GatewayAPIClient
+	paths map[string]string
+}
+
+func (c *fakeGatewayClient) Stat(ctx context.Context, req *provider.StatRequest, opts ...grpc.CallOption) (*provider.StatResponse, error) {
+	p, ok := c.paths[req.Ref.GetResourceId().GetOpaqueId()]
+	if !ok {
+		return &provider.StatResponse{Status: &rpc.Status{Code: rpc.Code_CODE_NOT_FOUND}}, nil
+	}
+	if req.Ref.Path != "" && req.Ref.Path != "." {
+		p += req.Ref.Path[1:]
+	}
+	return &provider.StatResponse{
+		Status: &rpc.Status{Code: rpc.Code_CODE_OK},
+		Info:   &provider.ResourceInfo{Path: p, Owner: &userpb.UserId{OpaqueId: "einstein"}},
+	}, nil
+}
+
+func (c *fakeGatewayClient) GetUser(ctx context.Context, req *userpb.GetUserRequest, opts ...grpc.CallOption) (*userpb.GetUserResponse, error) {
+	return &userpb.GetUserResponse{
+		Status: &rpc.Status{Code: rpc.Code_CODE_OK},
+		User:   &userpb.User{Id: req.UserId, Username: req.UserId.OpaqueId},
+	}, nil
+}
+
+func TestResolvePublicShare(t *testing.T) {
+	scopeExpansionCache = gcache.New(100).LFU().Build()
+	mgr, err := jwt.New(map[string]interface{}{"secret": "secret"})
+	if err != nil {
+		t.Fatalf("not expected error creating the token manager: %+v", err)
+	}
+	client := &fakeGatewayClient{paths: map[string]string{
+		"shared":  "/eos/user/e/einstein/shared",
+		"sibling": "/eos/user/e/einstein/shared-sibling",
+	}}
+	share := &link.PublicShare{
+		Id:          &link.PublicShareId{OpaqueId: "1"},
+		Token:       "token",
+		ResourceId:  &provider.ResourceId{StorageId: "storage", OpaqueId: "shared"},
+		Permissions: &link.PublicSharePermissions{Permissions: &provider.ResourcePermissions{Stat: true, InitiateFileDownload: true}},
+	}
+	scopes, err := scope.AddPublicSharePermissionsScope(share, authpb.Role_ROLE_EDITOR, nil)
+	if err != nil {
+		t.Fatalf("not expected error adding the scope: %+v", err)
+	}
+	s := scopes["publicshare:1"]
+
+	tests := []struct {
+		name    string
+		ref     *provider.Reference
+		req     func(*provider.Reference) interface{}
+		allowed bool
+	}{
+		{
+			name:    "inside the subtree",
+			ref:     &provider.Reference{ResourceId: share.ResourceId, Path: "./folder/file.txt"},
+			req:     func(ref *provider.Reference) interface{} { return &provider.StatRequest{Ref: ref} },
+			allowed: true,
+		},
+		{
+			name: "outside the subtree",
+			ref:  &provider.Reference{ResourceId: &provider.ResourceId{StorageId: "storage", OpaqueId: "sibling"}, Path: "./file.txt"},
+			req:  func(ref *provider.Reference) interface{} { return &provider.StatRequest{Ref: ref} },
+		},
+		{
+			name: "not allowed by the permissions",
+			ref:  &provider.Reference{ResourceId: share.ResourceId, Path: "./folder/file.txt"},
+			req:  func(ref *provider.Reference) interface{} { return &provider.DeleteRequest{Ref: ref} },
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := resolvePublicShare(context.Background(), tt.req(tt.ref), tt.ref, s, client, mgr)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.IsType(t, errtypes.PermissionDenied(""), err)
+			}
+		})
+	}
+}
@@ -20,11 +20,19 @@ package recovery
 
 import (
 	"context"
+	"fmt"
 	"runtime/debug"
+	"strings"
+	"sync"
 
 	"github.com/cs3org/reva/pkg/appctx"
 	"github.com/cs3org/reva/pkg/tracing"
 	grpc_recovery "github.com/grpc-ecosystem/go-grpc-middleware/recovery"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
@@ -32,37 +40,154 @@ import (
 
 const tracerName = "recovery"
 
+var (
+	panicsRecovered = stats.Int64("grpc_panics_recovered_total", "The number of panics recovered in the grpc services", stats.UnitDimensionless)
+	serviceKey      = tag.MustNewKey("service")
+	methodKey       = tag.MustNewKey("method")
+	registerOnce    sync.Once
+)
+
+// registerViews registers the view of the recovered panics,
+// which is shared by all the interceptors.
+func registerViews() error {
+	var err error
+	registerOnce.Do(func() {
+		err = view.Register(&view.View{
+			Name:        panicsRecovered.Name(),
+			Description: panicsRecovered.Description(),
+			Measure:     panicsRecovered,
+			TagKeys:     []tag.Key{serviceKey, methodKey},
+			Aggregation: view.Count(),
+		})
+	})
+	return err
+}
+
+// PanicHandler is called with the full grpc method and the value
+// of every recovered panic, for example to page an operator.
+type PanicHandler func(ctx context.Context, fullMethod string, p interface{})
+
+// Option configures the recovery interceptors.
+type Option func(r *recoverer)
+
+// WithPanicHandler sets a handler called on every recovered panic.
+func WithPanicHandler(h PanicHandler) Option {
+	return func(r *recoverer) {
+		r.handler = h
+	}
+}
+
+type recoverer struct {
+	conf    *config
+	handler PanicHandler
+}
+
+type config struct {
+	// Debug prints the stack of the recovered panics to stderr
+	// and returns the panic message to the client.
+	Debug bool `mapstructure:"debug"`
+	// IncludeStack adds the stack of the recovered panics
+	// to the error returned to the client.
+	IncludeStack bool `mapstructure:"include_stack"`
+}
+
+func parseConfig(m map[string]interface{}) (*config, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		err = errors.Wrap(err, "recovery: error decoding conf")
+		return nil, err
+	}
+	return c, nil
+}
+
 // NewUnary returns a server interceptor that adds telemetry to
 // grpc calls.
-func NewUnary() grpc.UnaryServerInterceptor {
+func NewUnary(m map[string]interface{}, opts ...Option) (grpc.UnaryServerInterceptor, error) {
+	r, err := newRecoverer(m, opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "recovery UnaryServerInterceptor")
 		defer span.End()
 
-		interceptor := grpc_recovery.UnaryServerInterceptor(grpc_recovery.WithRecoveryHandlerContext(recoveryFunc))
+		interceptor := grpc_recovery.UnaryServerInterceptor(grpc_recovery.WithRecoveryHandlerContext(r.recoveryFunc(info.FullMethod)))
 		return interceptor(ctx, req, info, handler)
-	}
+	}, nil
 }
 
 // NewStream returns a streaming server interceptor that adds telemetry to
 // streaming grpc calls.
-func NewStream() grpc.StreamServerInterceptor {
+func NewStream(m map[string]interface{}, opts ...Option) (grpc.StreamServerInterceptor, error) {
+	r, err := newRecoverer(m, opts...)
+	if err != nil {
+		return nil, err
+	}
+
 	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
 		ctx := ss.Context()
-		ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "recovery StreamServerInterceptor")
+		_, span := tracing.SpanStartFromContext(ctx, tracerName, "recovery StreamServerInterceptor")
 		defer span.End()
 
-		interceptor := grpc_recovery.StreamServerInterceptor(grpc_recovery.WithRecoveryHandlerContext(recoveryFunc))
+		interceptor := grpc_recovery.StreamServerInterceptor(grpc_recovery.WithRecoveryHandlerContext(r.recoveryFunc(info.FullMethod)))
 		return interceptor(srv, ss, info, handler)
+	}, nil
+}
+
+func newRecoverer(m map[string]interface{}, opts ...Option) (*recoverer, error) {
+	conf, err := parseConfig(m)
+	if err != nil {
+		return nil, err
+	}
+	if err := registerViews(); err != nil {
+		return nil, errors.Wrap(err, "recovery: error registering metrics")
+	}
+
+	r := &recoverer{conf: conf}
+	for _, o := range opts {
+		o(r)
+	}
+	return r, nil
+}
+
+// splitMethod splits a full grpc method, as /package.Service/Method,
+// in its service and method names.
+func splitMethod(fullMethod string) (string, string) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return "unknown", service
 	}
+	return service, method
 }
 
-func recoveryFunc(ctx context.Context, p interface{}) (err error) {
-	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "recovery recoveryFunc")
-	defer span.End()
+func (r *recoverer) recoveryFunc(fullMethod string) grpc_recovery.RecoveryHandlerFuncContext {
+	return func(ctx context.Context, p interface{}) (err error) {
+		ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "recovery recoveryFunc")
+		defer span.End()
+
+		stack := debug.Stack()
+		if r.conf.Debug {
+			debug.PrintStack()
+		}
+		log := appctx.GetLogger(ctx)
+		log.Error().Str("method", fullMethod).Str("stack", string(stack)).Msgf("recovered from panic: %+v", p)
 
-	debug.PrintStack()
-	log := appctx.GetLogger(ctx)
-	log.Error().Msgf("%+v; stack: %s", p, debug.Stack())
-	return status.Errorf(codes.Internal, "%s", p)
+		service, method := splitMethod(fullMethod)
+		if err := stats.RecordWithTags(ctx, []tag.Mutator{tag.Upsert(serviceKey, service), tag.Upsert(methodKey, method)}, panicsRecovered.M(1)); err != nil {
+			log.Error().Err(err).Msg("error recording recovered panic")
+		}
+		if r.handler != nil {
+			r.handler(ctx, fullMethod, p)
+		}
+
+		msg := "internal error"
+		if r.conf.Debug {
+			msg = fmt.Sprintf("%s", p)
+		}
+		if r.conf.IncludeStack {
+			msg = fmt.Sprintf("%s; stack: %s", msg, stack)
+		}
+		return status.Error(codes.Internal, msg)
+	}
 }
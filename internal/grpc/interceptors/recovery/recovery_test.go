@@ -0,0 +1,170 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package recovery
+
+import (
+	"context"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"go.opencensus.io/stats/view"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestUnaryRecovery(t *testing.T) {
+	var handled string
+	interceptor, err := NewUnary(nil, WithPanicHandler(func(ctx context.Context, fullMethod string, p interface{}) {
+		handled = fullMethod
+	}))
+	if err != nil {
+		t.Fatalf("error creating the interceptor: %v", err)
+	}
+	// the views are registered only once
+	if _, err := NewStream(nil); err != nil {
+		t.Fatalf("error creating the interceptor: %v", err)
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/cs3.gateway.v1beta1.GatewayAPI/Stat"}
+	// the views are global, hence only the increase of the count is checked
+	before := recoveredPanics(t, "cs3.gateway.v1beta1.GatewayAPI", "Stat")
+	_, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		panic("secret internals")
+	})
+
+	st, _ := status.FromError(err)
+	if st.Code() != codes.Internal || st.Message() != "internal error" {
+		t.Errorf("expected a generic internal error, got %v", err)
+	}
+	if handled != info.FullMethod {
+		t.Errorf("expected the panic handler to be called for %s, got %s", info.FullMethod, handled)
+	}
+	if c := recoveredPanics(t, "cs3.gateway.v1beta1.GatewayAPI", "Stat") - before; c != 1 {
+		t.Errorf("expected 1 more recovered panic, got %d", c)
+	}
+}
+
+// recoveredPanics returns the number of panics recovered so far in the method of the service.
+func recoveredPanics(t *testing.T, service, method string) int64 {
+	rows, err := view.RetrieveData(panicsRecovered.Name())
+	if err != nil {
+		t.Fatalf("error retrieving the recovered panics: %v", err)
+	}
+	for _, r := range rows {
+		var s, m string
+		for _, tag := range r.Tags {
+			switch tag.Key {
+			case serviceKey:
+				s = tag.Value
+			case methodKey:
+				m = tag.Value
+			}
+		}
+		if s == service && m == method {
+			return r.Data.(*view.CountData).Value
+		}
+	}
+	return 0
+}
+
+func TestRecoveryConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		conf        map[string]interface{}
+		message     string
+		stack       bool
+		printsStack bool
+	}{
+		{
+			name:    "default",
+			conf:    nil,
+			message: "internal error",
+		},
+		{
+			name:        "debug",
+			conf:        map[string]interface{}{"debug": true},
+			message:     "secret internals",
+			printsStack: true,
+		},
+		{
+			name:    "include stack",
+			conf:    map[string]interface{}{"include_stack": true},
+			message: "internal error; stack: ",
+			stack:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			interceptor, err := NewUnary(tt.conf)
+			if err != nil {
+				t.Fatalf("error creating the interceptor: %v", err)
+			}
+
+			info := &grpc.UnaryServerInfo{FullMethod: "/cs3.gateway.v1beta1.GatewayAPI/Stat"}
+			stderr := captureStderr(t, func() {
+				_, err = interceptor(context.Background(), nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+					panic("secret internals")
+				})
+			})
+
+			st, _ := status.FromError(err)
+			if st.Code() != codes.Internal {
+				t.Errorf("expected an internal error, got %v", err)
+			}
+			if tt.stack {
+				if !strings.HasPrefix(st.Message(), tt.message) || !strings.Contains(st.Message(), "runtime/debug.Stack") {
+					t.Errorf("expected the error to include the stack, got %q", st.Message())
+				}
+			} else if st.Message() != tt.message {
+				t.Errorf("expected error %q, got %q", tt.message, st.Message())
+			}
+
+			if tt.printsStack && !strings.Contains(stderr, "runtime/debug.Stack") {
+				t.Errorf("expected the stack to be printed to stderr, got %q", stderr)
+			}
+			if !tt.printsStack && stderr != "" {
+				t.Errorf("expected nothing to be printed to stderr, got %q", stderr)
+			}
+		})
+	}
+}
+
+// captureStderr returns what f writes to stderr.
+func captureStderr(t *testing.T, f func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("error creating pipe: %v", err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	defer func() { os.Stderr = stderr }()
+
+	out := make(chan string)
+	go func() {
+		b, _ := io.ReadAll(r)
+		out <- string(b)
+	}()
+	f()
+	_ = w.Close()
+	return <-out
+}
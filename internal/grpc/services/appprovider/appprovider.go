@@ -57,6 +57,7 @@ type service struct {
 	tracing.GrpcMiddleware
 	provider app.Provider
 	conf     *config
+	quit     chan struct{}
 }
 
 type config struct {
@@ -68,6 +69,7 @@ type config struct {
 	CustomMimeTypesJSON string                            `mapstructure:"custom_mime_types_json" docs:"nil;An optional mapping file with the list of supported custom file extensions and corresponding mime types."`
 	Priority            uint64                            `mapstructure:"priority"`
 	Language            string                            `mapstructure:"language"`
+	RegisterInterval    int                               `mapstructure:"register_interval" docs:"0;The interval in seconds at which the app provider registers again, to not be evicted by the app registries with a provider_ttl. Disabled if 0."`
 }
 
 func (c *config) init() {
@@ -110,6 +112,7 @@ func New(m map[string]interface{}, ss *grpc.Server) (rgrpc.Service, error) {
 	service := &service{
 		conf:     c,
 		provider: provider,
+		quit:     make(chan struct{}),
 	}
 	go service.registerProvider(ctx)
 	return service, nil
@@ -136,12 +139,36 @@ func registerMimeTypes(mappingFile string) error {
 	return nil
 }
 
+// registerProvider registers the app provider in the app registry,
+// and then again at the configured interval, until the service is closed.
 func (s *service) registerProvider(ctx context.Context) {
-	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "registerProvider")
-	defer span.End()
 	// Give the appregistry service time to come up
 	// TODO(lopresti) we should register the appproviders after all other microservices
-	time.Sleep(3 * time.Second)
+	select {
+	case <-s.quit:
+		return
+	case <-time.After(3 * time.Second):
+	}
+	s.register(ctx)
+
+	if s.conf.RegisterInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Duration(s.conf.RegisterInterval) * time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-ticker.C:
+			s.register(ctx)
+		}
+	}
+}
+
+func (s *service) register(ctx context.Context) {
+	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "registerProvider")
+	defer span.End()
 
 	log := logger.New().With().Int("pid", os.Getpid()).Logger()
 	pInfo, err := s.provider.GetAppProviderInfo(ctx)
@@ -192,6 +219,7 @@ func (s *service) registerProvider(ctx context.Context) {
 }
 
 func (s *service) Close() error {
+	close(s.quit)
 	return nil
 }
 
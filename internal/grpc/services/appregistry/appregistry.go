@@ -22,6 +22,7 @@ import (
 	"context"
 
 	registrypb "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/pkg/app"
 	"github.com/cs3org/reva/pkg/app/registry/registry"
 	"github.com/cs3org/reva/pkg/errtypes"
@@ -29,6 +30,7 @@ import (
 	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/tracing"
 	"github.com/mitchellh/mapstructure"
+	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc"
 )
 
@@ -41,7 +43,9 @@ func init() {
 
 type svc struct {
 	tracing.GrpcMiddleware
-	reg app.Registry
+	reg        app.Registry
+	catalogue  catalogue
+	extensions extensionMimeTypes
 }
 
 func (s *svc) Close() error {
@@ -59,6 +63,13 @@ func (s *svc) Register(ss *grpc.Server) {
 type config struct {
 	Driver  string                            `mapstructure:"driver"`
 	Drivers map[string]map[string]interface{} `mapstructure:"drivers"`
+	// Catalogue describes the mime types served to the clients,
+	// for the ones not described by the registry driver.
+	Catalogue []*catalogueEntry `mapstructure:"catalogue"`
+	// ExtensionMimeTypes maps the file extensions to the mime types used to
+	// look up the app providers of the files without a mime type, or with a
+	// generic one. It extends and overrides the built-in mapping.
+	ExtensionMimeTypes map[string]string `mapstructure:"extension_mime_types"`
 }
 
 func (c *config) init() {
@@ -80,7 +91,16 @@ func New(m map[string]interface{}, ss *grpc.Server) (rgrpc.Service, error) {
 	}
 
 	svc := &svc{
-		reg: reg,
+		reg:        reg,
+		catalogue:  newCatalogue(c.Catalogue),
+		extensions: newExtensionMimeTypes(c.ExtensionMimeTypes),
+	}
+
+	// the mime types of the providers visible to everyone, as no user is known here
+	if mimeTypes, err := reg.ListSupportedMimeTypes(context.Background()); err == nil {
+		if missing := svc.catalogue.undescribed(mimeTypes); len(missing) != 0 {
+			log.Warn().Strs("mime_types", missing).Msg("appregistry: mime types of the app providers described neither by the registry driver nor by the catalogue")
+		}
 	}
 
 	return svc, nil
@@ -106,10 +126,11 @@ func (s *svc) GetAppProviders(ctx context.Context, req *registrypb.GetAppProvide
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "GetAppProviders")
 	defer span.End()
 
-	p, err := s.reg.FindProviders(ctx, req.ResourceInfo.MimeType)
+	mimeType, derived := s.extensions.resolve(req.ResourceInfo)
+	p, err := s.reg.FindProviders(ctx, mimeType)
 	if err != nil {
 		return &registrypb.GetAppProvidersResponse{
-			Status: status.NewInternal(ctx, err, "error looking for the app provider"),
+			Status: status.NewStatusFromErrType(ctx, "error looking for the app provider", err),
 		}, nil
 	}
 
@@ -117,9 +138,19 @@ func (s *svc) GetAppProviders(ctx context.Context, req *registrypb.GetAppProvide
 		Status:    status.NewOK(ctx),
 		Providers: p,
 	}
+	if derived {
+		res.Opaque = &typespb.Opaque{Map: map[string]*typespb.OpaqueEntry{
+			ResolvedMimeTypeOpaqueKey: {Decoder: "plain", Value: []byte(mimeType)},
+		}}
+	}
 	return res, nil
 }
 
+// ResolvedMimeTypeOpaqueKey is the key in the opaque map of a GetAppProvidersResponse
+// holding the mime type derived from the file extension, encoded as plain text,
+// when the resource had no mime type or a generic one.
+const ResolvedMimeTypeOpaqueKey = "mime_type"
+
 func (s *svc) AddAppProvider(ctx context.Context, req *registrypb.AddAppProviderRequest) (*registrypb.AddAppProviderResponse, error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "AddAppProvider")
 	defer span.End()
@@ -127,7 +158,7 @@ func (s *svc) AddAppProvider(ctx context.Context, req *registrypb.AddAppProvider
 	err := s.reg.AddProvider(ctx, req.Provider)
 	if err != nil {
 		return &registrypb.AddAppProviderResponse{
-			Status: status.NewInternal(ctx, err, "error adding the app provider"),
+			Status: status.NewStatusFromErrType(ctx, "error adding the app provider", err),
 		}, nil
 	}
 
@@ -137,14 +168,29 @@ func (s *svc) AddAppProvider(ctx context.Context, req *registrypb.AddAppProvider
 	return res, nil
 }
 
+// MimeTypeFilterOpaqueKey is the key in the opaque map of a ListAppProvidersRequest
+// holding a mime type, encoded as plain text: when present, only the providers
+// able to open files of that type are listed.
+const MimeTypeFilterOpaqueKey = "mime_type"
+
 func (s *svc) ListAppProviders(ctx context.Context, req *registrypb.ListAppProvidersRequest) (*registrypb.ListAppProvidersResponse, error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "ListAppProviders")
 	defer span.End()
 
-	providers, err := s.reg.ListProviders(ctx)
+	var providers []*registrypb.ProviderInfo
+	var err error
+	if mimeType, ok := mimeTypeFilter(req); ok {
+		providers, err = s.reg.FindProviders(ctx, mimeType)
+		if _, isNotFound := err.(errtypes.IsNotFound); isNotFound {
+			// no provider can open the mime type
+			providers, err = []*registrypb.ProviderInfo{}, nil
+		}
+	} else {
+		providers, err = s.reg.ListProviders(ctx)
+	}
 	if err != nil {
 		return &registrypb.ListAppProvidersResponse{
-			Status: status.NewInternal(ctx, err, "error listing the app providers"),
+			Status: status.NewStatusFromErrType(ctx, "error listing the app providers", err),
 		}, nil
 	}
 
@@ -155,6 +201,15 @@ func (s *svc) ListAppProviders(ctx context.Context, req *registrypb.ListAppProvi
 	return res, nil
 }
 
+// mimeTypeFilter returns the mime type the providers have to be filtered by, if any.
+func mimeTypeFilter(req *registrypb.ListAppProvidersRequest) (string, bool) {
+	e, ok := req.GetOpaque().GetMap()[MimeTypeFilterOpaqueKey]
+	if !ok || e.Decoder != "plain" || len(e.Value) == 0 {
+		return "", false
+	}
+	return string(e.Value), true
+}
+
 func (s *svc) ListSupportedMimeTypes(ctx context.Context, req *registrypb.ListSupportedMimeTypesRequest) (*registrypb.ListSupportedMimeTypesResponse, error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "ListSupportedMimeTypes")
 	defer span.End()
@@ -162,12 +217,14 @@ func (s *svc) ListSupportedMimeTypes(ctx context.Context, req *registrypb.ListSu
 	mimeTypes, err := s.reg.ListSupportedMimeTypes(ctx)
 	if err != nil {
 		return &registrypb.ListSupportedMimeTypesResponse{
-			Status: status.NewInternal(ctx, err, "error listing the supported mime types"),
+			Status: status.NewStatusFromErrType(ctx, "error listing the supported mime types", err),
 		}, nil
 	}
 
-	// hide mimetypes for app providers
 	for _, mime := range mimeTypes {
+		s.catalogue.decorate(mime)
+
+		// hide mimetypes for app providers
 		for _, app := range mime.AppProviders {
 			app.MimeTypes = nil
 		}
@@ -187,7 +244,7 @@ func (s *svc) GetDefaultAppProviderForMimeType(ctx context.Context, req *registr
 	provider, err := s.reg.GetDefaultProviderForMimeType(ctx, req.MimeType)
 	if err != nil {
 		return &registrypb.GetDefaultAppProviderForMimeTypeResponse{
-			Status: status.NewInternal(ctx, err, "error getting the default app provider for the mimetype"),
+			Status: status.NewStatusFromErrType(ctx, "error getting the default app provider for the mimetype", err),
 		}, nil
 	}
 
@@ -205,7 +262,7 @@ func (s *svc) SetDefaultAppProviderForMimeType(ctx context.Context, req *registr
 	err := s.reg.SetDefaultProviderForMimeType(ctx, req.MimeType, req.Provider)
 	if err != nil {
 		return &registrypb.SetDefaultAppProviderForMimeTypeResponse{
-			Status: status.NewInternal(ctx, err, "error setting the default app provider for the mimetype"),
+			Status: status.NewStatusFromErrType(ctx, "error setting the default app provider for the mimetype", err),
 		}, nil
 	}
 
@@ -26,6 +26,8 @@ import (
 	registrypb "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
 	rpcv1beta1 "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	providerv1beta1 "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/app"
 	"github.com/cs3org/reva/pkg/app/registry/static"
 	"github.com/stretchr/testify/assert"
 )
@@ -222,10 +224,10 @@ func Test_GetAppProviders(t *testing.T) {
 					Message: "",
 				},
 				Providers: []*registrypb.ProviderInfo{
-					{
+					withCapabilities(&registrypb.ProviderInfo{
 						Address:   "text appprovider addr",
 						MimeTypes: []string{"text/json", "text/xml"},
-					},
+					}, "text/json", &app.MimeTypeCapabilities{View: true, Edit: true, Extension: "json"}),
 				},
 			},
 		},
@@ -239,10 +241,10 @@ func Test_GetAppProviders(t *testing.T) {
 					Message: "",
 				},
 				Providers: []*registrypb.ProviderInfo{
-					{
+					withCapabilities(&registrypb.ProviderInfo{
 						Address:   "misc appprovider addr",
 						MimeTypes: []string{"application/vnd.openxmlformats-officedocument.wordprocessingml.document", "application/vnd.oasis.opendocument.presentation", "application/vnd.apple.installer+xml"},
-					},
+					}, "application/vnd.apple.installer+xml", &app.MimeTypeCapabilities{View: true, Edit: true, Extension: "mpkg"}),
 				},
 			},
 		},
@@ -251,9 +253,9 @@ func Test_GetAppProviders(t *testing.T) {
 			search: &providerv1beta1.ResourceInfo{MimeType: "doesnot/exist"},
 			want: &registrypb.GetAppProvidersResponse{
 				Status: &rpcv1beta1.Status{
-					Code:    15,
+					Code:    rpcv1beta1.Code_CODE_NOT_FOUND,
 					Trace:   "00000000000000000000000000000000",
-					Message: "error looking for the app provider",
+					Message: "error looking for the app provider: error: not found: application provider not found for mime type doesnot/exist",
 				},
 				Providers: nil,
 			},
@@ -263,9 +265,9 @@ func Test_GetAppProviders(t *testing.T) {
 			search: &providerv1beta1.ResourceInfo{MimeType: ""},
 			want: &registrypb.GetAppProvidersResponse{
 				Status: &rpcv1beta1.Status{
-					Code:    15,
+					Code:    rpcv1beta1.Code_CODE_NOT_FOUND,
 					Trace:   "00000000000000000000000000000000",
-					Message: "error looking for the app provider",
+					Message: "error looking for the app provider: error: not found: application provider not found for mime type ",
 				},
 				Providers: nil,
 			},
@@ -275,9 +277,9 @@ func Test_GetAppProviders(t *testing.T) {
 			search: &providerv1beta1.ResourceInfo{},
 			want: &registrypb.GetAppProvidersResponse{
 				Status: &rpcv1beta1.Status{
-					Code:    15,
+					Code:    rpcv1beta1.Code_CODE_NOT_FOUND,
 					Trace:   "00000000000000000000000000000000",
-					Message: "error looking for the app provider",
+					Message: "error looking for the app provider: error: not found: application provider not found for mime type ",
 				},
 				Providers: nil,
 			},
@@ -287,9 +289,9 @@ func Test_GetAppProviders(t *testing.T) {
 			search: &providerv1beta1.ResourceInfo{MimeType: "this/type\\IS.not?VALID@all"},
 			want: &registrypb.GetAppProvidersResponse{
 				Status: &rpcv1beta1.Status{
-					Code:    15,
+					Code:    rpcv1beta1.Code_CODE_NOT_FOUND,
 					Trace:   "00000000000000000000000000000000",
-					Message: "error looking for the app provider",
+					Message: "error looking for the app provider: error: not found: application provider not found for mime type this/type\\IS.not?VALID@all",
 				},
 				Providers: nil,
 			},
@@ -323,6 +325,412 @@ func Test_GetAppProviders(t *testing.T) {
 	}
 }
 
+func withCapabilities(p *registrypb.ProviderInfo, mimeType string, caps *app.MimeTypeCapabilities) *registrypb.ProviderInfo {
+	if err := app.SetCapabilities(p, map[string]*app.MimeTypeCapabilities{mimeType: caps}); err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func Test_ListSupportedMimeTypesCapabilities(t *testing.T) {
+	providers := []map[string]interface{}{
+		{
+			"address":   "office addr",
+			"name":      "Office",
+			"mimetypes": []string{"application/vnd.oasis.opendocument.text", "application/pdf"},
+		},
+		{
+			"address":    "viewer addr",
+			"name":       "Viewer",
+			"mimetypes":  []string{"application/vnd.oasis.opendocument.text"},
+			"capability": registrypb.ProviderInfo_CAPABILITY_VIEWER,
+		},
+	}
+	mimeTypes := []map[string]interface{}{
+		{
+			"mime_type":      "application/vnd.oasis.opendocument.text",
+			"extension":      "odt",
+			"allow_creation": true,
+		},
+		{
+			"mime_type":      "application/pdf",
+			"extension":      "pdf",
+			"allow_creation": true,
+			"app_capabilities": map[string]interface{}{
+				"Office": map[string]interface{}{"view": true},
+			},
+		},
+	}
+
+	rr, err := static.New(map[string]interface{}{"providers": providers, "mime_types": mimeTypes})
+	if err != nil {
+		t.Fatalf("could not create registry error = %v", err)
+	}
+	ss := &svc{
+		reg: rr,
+	}
+
+	// the registry announces a provider with an explicit "new file" extension
+	announced := &registrypb.ProviderInfo{
+		Address:   "announced addr",
+		Name:      "Announced",
+		MimeTypes: []string{"application/vnd.oasis.opendocument.text"},
+	}
+	err = app.SetCapabilities(announced, map[string]*app.MimeTypeCapabilities{
+		"application/vnd.oasis.opendocument.text": {View: true, Edit: true, Create: true, Extension: "fodt"},
+	})
+	if err != nil {
+		t.Fatalf("could not set the capabilities error = %v", err)
+	}
+	if _, err := ss.AddAppProvider(context.Background(), &registrypb.AddAppProviderRequest{Provider: announced}); err != nil {
+		t.Fatalf("AddAppProvider() error = %v", err)
+	}
+
+	got, err := ss.ListSupportedMimeTypes(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("ListSupportedMimeTypes() error = %v", err)
+	}
+
+	expected := map[string]map[string]*app.MimeTypeCapabilities{
+		"application/vnd.oasis.opendocument.text": {
+			"Office":    {View: true, Edit: true, Create: true, Extension: "odt"},
+			"Viewer":    {View: true, Extension: "odt"},
+			"Announced": {View: true, Edit: true, Create: true, Extension: "fodt"},
+		},
+		"application/pdf": {
+			"Office": {View: true, Extension: "pdf"},
+		},
+	}
+
+	assert.Len(t, got.MimeTypes, len(expected))
+	for _, m := range got.MimeTypes {
+		assert.Len(t, m.AppProviders, len(expected[m.MimeType]))
+		for _, p := range m.AppProviders {
+			caps, err := app.GetCapabilities(p)
+			if err != nil {
+				t.Fatalf("GetCapabilities() error = %v", err)
+			}
+			assert.Equal(t, map[string]*app.MimeTypeCapabilities{m.MimeType: expected[m.MimeType][p.Name]}, caps)
+		}
+	}
+
+	// the capabilities are per mime type, so listing them must not alter the registered providers
+	list, err := ss.ListAppProviders(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("ListAppProviders() error = %v", err)
+	}
+	for _, p := range list.Providers {
+		assert.NotEmpty(t, p.MimeTypes)
+	}
+}
+
+func TestListAppProvidersMimeTypeFilter(t *testing.T) {
+	providers := []map[string]interface{}{
+		{
+			"address":   "office addr",
+			"name":      "Office",
+			"mimetypes": []string{"application/vnd.oasis.opendocument.text", "application/pdf"},
+		},
+		{
+			"address":   "viewer addr",
+			"name":      "Viewer",
+			"mimetypes": []string{"application/pdf"},
+		},
+		{
+			"address":   "code addr",
+			"name":      "Code",
+			"mimetypes": []string{"text/plain"},
+		},
+	}
+	mimeTypes := []map[string]interface{}{
+		{"mime_type": "application/vnd.oasis.opendocument.text", "extension": "odt"},
+		{"mime_type": "application/pdf", "extension": "pdf"},
+		{"mime_type": "text/plain", "extension": "txt"},
+	}
+
+	rr, err := static.New(map[string]interface{}{"providers": providers, "mime_types": mimeTypes})
+	if err != nil {
+		t.Fatalf("could not create registry error = %v", err)
+	}
+	ss := &svc{
+		reg: rr,
+	}
+
+	filter := func(mimeType string) *registrypb.ListAppProvidersRequest {
+		return &registrypb.ListAppProvidersRequest{
+			Opaque: &typesv1beta1.Opaque{Map: map[string]*typesv1beta1.OpaqueEntry{
+				MimeTypeFilterOpaqueKey: {Decoder: "plain", Value: []byte(mimeType)},
+			}},
+		}
+	}
+
+	tests := []struct {
+		name     string
+		req      *registrypb.ListAppProvidersRequest
+		expected []string
+	}{
+		{
+			name:     "no filter",
+			req:      &registrypb.ListAppProvidersRequest{},
+			expected: []string{"code addr", "office addr", "viewer addr"},
+		},
+		{
+			name:     "nil request",
+			expected: []string{"code addr", "office addr", "viewer addr"},
+		},
+		{
+			name:     "single provider",
+			req:      filter("application/vnd.oasis.opendocument.text"),
+			expected: []string{"office addr"},
+		},
+		{
+			name:     "multiple providers",
+			req:      filter("application/pdf"),
+			expected: []string{"office addr", "viewer addr"},
+		},
+		{
+			name:     "no provider",
+			req:      filter("image/png"),
+			expected: []string{},
+		},
+		{
+			name:     "empty filter",
+			req:      filter(""),
+			expected: []string{"code addr", "office addr", "viewer addr"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ss.ListAppProviders(context.Background(), tt.req)
+			if err != nil {
+				t.Fatalf("ListAppProviders() error = %v", err)
+			}
+			assert.Equal(t, rpcv1beta1.Code_CODE_OK, res.Status.Code)
+
+			addresses := []string{}
+			for _, p := range res.Providers {
+				addresses = append(addresses, p.Address)
+			}
+			sort.Strings(addresses)
+			assert.Equal(t, tt.expected, addresses)
+		})
+	}
+}
+
+func Test_ListSupportedMimeTypesCatalogue(t *testing.T) {
+	conf := map[string]interface{}{
+		"drivers": map[string]interface{}{
+			"static": map[string]interface{}{
+				"providers": []map[string]interface{}{
+					{
+						"address":   "office addr",
+						"name":      "Office",
+						"mimetypes": []string{"application/vnd.oasis.opendocument.text", "application/x-reva-test", "text/x-reva-test"},
+					},
+				},
+				"mime_types": []map[string]interface{}{
+					{
+						"mime_type": "application/vnd.oasis.opendocument.text",
+						"extension": "odt",
+						"name":      "ODT",
+					},
+				},
+			},
+		},
+		"catalogue": []map[string]interface{}{
+			{
+				"mime_type":      "application/vnd.oasis.opendocument.text",
+				"name":           "OpenDocument Text",
+				"description":    "OpenDocument text document",
+				"icon":           "x-office-document",
+				"allow_creation": true,
+			},
+			{
+				"mime_type":   "application/x-reva-test",
+				"name":        "Reva test",
+				"icon":        "reva-test",
+				"extension":   "rvt",
+				"description": "Reva test file",
+			},
+		},
+	}
+
+	ss, err := New(conf, nil)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	got, err := ss.(*svc).ListSupportedMimeTypes(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("ListSupportedMimeTypes() error = %v", err)
+	}
+	assert.Equal(t, rpcv1beta1.Code_CODE_OK, got.Status.Code)
+
+	expected := map[string]*registrypb.MimeTypeInfo{
+		// the driver configuration takes precedence over the catalogue
+		"application/vnd.oasis.opendocument.text": {
+			Ext:           "odt",
+			Name:          "ODT",
+			Description:   "OpenDocument text document",
+			Icon:          "x-office-document",
+			AllowCreation: true,
+		},
+		// not configured in the driver
+		"application/x-reva-test": {
+			Ext:         "rvt",
+			Name:        "Reva test",
+			Description: "Reva test file",
+			Icon:        "reva-test",
+		},
+		// not in the catalogue
+		"text/x-reva-test": {
+			Name:        "text/x-reva-test",
+			Description: "text/x-reva-test",
+			Icon:        "text-x-generic",
+		},
+	}
+
+	assert.Len(t, got.MimeTypes, len(expected))
+	for _, m := range got.MimeTypes {
+		e, ok := expected[m.MimeType]
+		if !assert.True(t, ok, "unexpected mime type %s", m.MimeType) {
+			continue
+		}
+		assert.Equal(t, e.Ext, m.Ext)
+		assert.Equal(t, e.Name, m.Name)
+		assert.Equal(t, e.Description, m.Description)
+		assert.Equal(t, e.Icon, m.Icon)
+		assert.Equal(t, e.AllowCreation, m.AllowCreation)
+		assert.Len(t, m.AppProviders, 1)
+	}
+}
+
+func TestCatalogueUndescribed(t *testing.T) {
+	c := newCatalogue([]*catalogueEntry{{MimeType: "application/x-reva-test", Name: "Reva test"}})
+	providers := []*registrypb.ProviderInfo{{Address: "office addr"}}
+	missing := c.undescribed([]*registrypb.MimeTypeInfo{
+		// described by the registry driver
+		{MimeType: "application/vnd.oasis.opendocument.text", Name: "ODT", AppProviders: providers},
+		// described by the catalogue
+		{MimeType: "application/x-reva-test", AppProviders: providers},
+		// without providers
+		{MimeType: "text/plain"},
+		{MimeType: "text/x-reva-test", AppProviders: providers},
+	})
+	assert.Equal(t, []string{"text/x-reva-test"}, missing)
+}
+
+func TestCatalogueDefaults(t *testing.T) {
+	assert.Equal(t, "PDF file", defaultName("application/pdf", "pdf"))
+	assert.Equal(t, "application/x-reva-test", defaultName("application/x-reva-test", ""))
+	assert.Equal(t, "image-x-generic", defaultIcon("image/png"))
+	assert.Equal(t, "application-x-generic", defaultIcon(""))
+}
+
+func TestGetAppProvidersByExtension(t *testing.T) {
+	providers := []map[string]interface{}{
+		{
+			"address":   "markdown addr",
+			"mimetypes": []string{"text/markdown"},
+		},
+		{
+			"address":   "drawio addr",
+			"mimetypes": []string{"application/x-drawio"},
+		},
+		{
+			"address":   "notebook addr",
+			"mimetypes": []string{"application/x-notebook"},
+		},
+	}
+	mimeTypes := []map[string]interface{}{
+		{"mime_type": "text/markdown", "extension": "md"},
+		{"mime_type": "application/x-drawio", "extension": "drawio"},
+		{"mime_type": "application/x-notebook", "extension": "nb"},
+	}
+
+	rr, err := static.New(map[string]interface{}{"providers": providers, "mime_types": mimeTypes})
+	if err != nil {
+		t.Fatalf("could not create registry error = %v", err)
+	}
+	ss := &svc{
+		reg:        rr,
+		extensions: newExtensionMimeTypes(map[string]string{".NB": "application/x-notebook"}),
+	}
+
+	tests := []struct {
+		name     string
+		ri       *providerv1beta1.ResourceInfo
+		code     rpcv1beta1.Code
+		address  string
+		mimeType string
+	}{
+		{
+			name:     "mapped extension without mime type",
+			ri:       &providerv1beta1.ResourceInfo{Path: "/notes/README.md"},
+			code:     rpcv1beta1.Code_CODE_OK,
+			address:  "markdown addr",
+			mimeType: "text/markdown",
+		},
+		{
+			name:     "mapped extension with a generic mime type",
+			ri:       &providerv1beta1.ResourceInfo{Path: "/diagrams/flow.DrawIO", MimeType: "application/octet-stream"},
+			code:     rpcv1beta1.Code_CODE_OK,
+			address:  "drawio addr",
+			mimeType: "application/x-drawio",
+		},
+		{
+			name:     "configured extension",
+			ri:       &providerv1beta1.ResourceInfo{Path: "/analysis.nb", MimeType: "application/octet-stream"},
+			code:     rpcv1beta1.Code_CODE_OK,
+			address:  "notebook addr",
+			mimeType: "application/x-notebook",
+		},
+		{
+			name: "unknown extension",
+			ri:   &providerv1beta1.ResourceInfo{Path: "/data.bin", MimeType: "application/octet-stream"},
+			code: rpcv1beta1.Code_CODE_NOT_FOUND,
+		},
+		{
+			name: "no extension",
+			ri:   &providerv1beta1.ResourceInfo{Path: "/Makefile"},
+			code: rpcv1beta1.Code_CODE_NOT_FOUND,
+		},
+		{
+			name:    "real mime type present",
+			ri:      &providerv1beta1.ResourceInfo{Path: "/notes.md", MimeType: "application/x-drawio"},
+			code:    rpcv1beta1.Code_CODE_OK,
+			address: "drawio addr",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := ss.GetAppProviders(context.Background(), &registrypb.GetAppProvidersRequest{ResourceInfo: tt.ri})
+			if err != nil {
+				t.Fatalf("GetAppProviders() error = %v", err)
+			}
+			assert.Equal(t, tt.code, res.Status.Code)
+			if tt.code != rpcv1beta1.Code_CODE_OK {
+				return
+			}
+
+			if assert.Len(t, res.Providers, 1) {
+				assert.Equal(t, tt.address, res.Providers[0].Address)
+			}
+			e, ok := res.Opaque.GetMap()[ResolvedMimeTypeOpaqueKey]
+			if tt.mimeType == "" {
+				assert.False(t, ok)
+				return
+			}
+			if assert.True(t, ok) {
+				assert.Equal(t, "plain", e.Decoder)
+				assert.Equal(t, tt.mimeType, string(e.Value))
+			}
+		})
+	}
+}
+
 func TestNew(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -0,0 +1,125 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package appregistry
+
+import (
+	"mime"
+	"strings"
+
+	registrypb "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
+)
+
+// catalogueEntry describes how clients present a mime type.
+type catalogueEntry struct {
+	MimeType      string `mapstructure:"mime_type"`
+	Name          string `mapstructure:"name"`
+	Description   string `mapstructure:"description"`
+	Icon          string `mapstructure:"icon"`
+	Extension     string `mapstructure:"extension"`
+	AllowCreation bool   `mapstructure:"allow_creation"`
+}
+
+// catalogue indexes the entries by mime type.
+type catalogue map[string]*catalogueEntry
+
+func newCatalogue(entries []*catalogueEntry) catalogue {
+	c := make(catalogue, len(entries))
+	for _, e := range entries {
+		if e != nil && e.MimeType != "" {
+			c[e.MimeType] = e
+		}
+	}
+	return c
+}
+
+// decorate fills the fields of the mime type info left empty by the registry
+// driver with the ones in the catalogue, and derives the remaining ones
+// from the mime type itself.
+func (c catalogue) decorate(info *registrypb.MimeTypeInfo) {
+	if e, ok := c[info.MimeType]; ok {
+		if info.Name == "" {
+			info.Name = e.Name
+		}
+		if info.Description == "" {
+			info.Description = e.Description
+		}
+		if info.Icon == "" {
+			info.Icon = e.Icon
+		}
+		if info.Ext == "" {
+			info.Ext = e.Extension
+		}
+		info.AllowCreation = info.AllowCreation || e.AllowCreation
+	}
+
+	if info.Ext == "" {
+		info.Ext = defaultExtension(info.MimeType)
+	}
+	if info.Name == "" {
+		info.Name = defaultName(info.MimeType, info.Ext)
+	}
+	if info.Description == "" {
+		info.Description = info.Name
+	}
+	if info.Icon == "" {
+		info.Icon = defaultIcon(info.MimeType)
+	}
+}
+
+// undescribed returns the mime types served by app providers that are
+// described neither by the registry driver, having no name, nor by the catalogue.
+func (c catalogue) undescribed(infos []*registrypb.MimeTypeInfo) []string {
+	var missing []string
+	for _, info := range infos {
+		if _, ok := c[info.MimeType]; ok || info.Name != "" || len(info.AppProviders) == 0 {
+			continue
+		}
+		missing = append(missing, info.MimeType)
+	}
+	return missing
+}
+
+// defaultExtension returns the extension of the mime type known to the system,
+// when there is only one, without the leading dot.
+func defaultExtension(mimeType string) string {
+	exts, err := mime.ExtensionsByType(mimeType)
+	if err != nil || len(exts) != 1 {
+		return ""
+	}
+	return strings.TrimPrefix(exts[0], ".")
+}
+
+// defaultName names the mime type after its extension, e.g. "PDF file",
+// or after the mime type itself when there is no extension.
+func defaultName(mimeType, ext string) string {
+	if ext == "" {
+		return mimeType
+	}
+	return strings.ToUpper(ext) + " file"
+}
+
+// defaultIcon returns the generic icon of the media type, following
+// the freedesktop naming, e.g. "image-x-generic" for "image/png".
+func defaultIcon(mimeType string) string {
+	mediaType, _, _ := strings.Cut(mimeType, "/")
+	if mediaType == "" {
+		mediaType = "application"
+	}
+	return mediaType + "-x-generic"
+}
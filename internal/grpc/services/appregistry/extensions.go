@@ -0,0 +1,83 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package appregistry
+
+import (
+	"path"
+	"strings"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+)
+
+// genericMimeType is the mime type of the files whose type could not be detected.
+const genericMimeType = "application/octet-stream"
+
+// defaultExtensionMimeTypes maps the extensions of the files commonly opened
+// in an app to their mime type, for the files stored without a meaningful one.
+var defaultExtensionMimeTypes = map[string]string{
+	"md":       "text/markdown",
+	"markdown": "text/markdown",
+	"txt":      "text/plain",
+	"drawio":   "application/x-drawio",
+	"dio":      "application/x-drawio",
+	"ipynb":    "application/x-ipynb+json",
+	"odt":      "application/vnd.oasis.opendocument.text",
+	"ods":      "application/vnd.oasis.opendocument.spreadsheet",
+	"odp":      "application/vnd.oasis.opendocument.presentation",
+	"docx":     "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	"xlsx":     "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	"pptx":     "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	"pdf":      "application/pdf",
+}
+
+// extensionMimeTypes maps the file extensions, without the leading dot
+// and in lower case, to the mime types.
+type extensionMimeTypes map[string]string
+
+// newExtensionMimeTypes returns the built-in mapping, extended and overridden
+// by the configured one.
+func newExtensionMimeTypes(conf map[string]string) extensionMimeTypes {
+	m := make(extensionMimeTypes, len(defaultExtensionMimeTypes)+len(conf))
+	for ext, mimeType := range defaultExtensionMimeTypes {
+		m[ext] = mimeType
+	}
+	for ext, mimeType := range conf {
+		ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+		if ext != "" && mimeType != "" {
+			m[ext] = mimeType
+		}
+	}
+	return m
+}
+
+// resolve returns the mime type the app providers are looked up by:
+// the one of the resource, unless it is missing or generic, in which case
+// it is derived from the extension of the file. The returned bool
+// reports whether the mime type was derived.
+func (m extensionMimeTypes) resolve(ri *provider.ResourceInfo) (string, bool) {
+	mimeType := ri.GetMimeType()
+	if mimeType != "" && mimeType != genericMimeType {
+		return mimeType, false
+	}
+	ext := strings.ToLower(strings.TrimPrefix(path.Ext(ri.GetPath()), "."))
+	if derived, ok := m[ext]; ok && ext != "" {
+		return derived, true
+	}
+	return mimeType, false
+}
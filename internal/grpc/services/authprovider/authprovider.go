@@ -22,6 +22,7 @@ import (
 	"context"
 	"fmt"
 	"path/filepath"
+	"time"
 
 	provider "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
 	"github.com/cs3org/reva/pkg/appctx"
@@ -34,8 +35,11 @@ import (
 	"github.com/cs3org/reva/pkg/sharedconf"
 	"github.com/cs3org/reva/pkg/tracing"
 	"github.com/cs3org/reva/pkg/user"
+	"github.com/fsnotify/fsnotify"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
 	"google.golang.org/grpc"
 )
 
@@ -49,6 +53,16 @@ func init() {
 type config struct {
 	AuthManager  string                            `mapstructure:"auth_manager"`
 	AuthManagers map[string]map[string]interface{} `mapstructure:"auth_managers"`
+	// ReloadInterval is the interval in seconds at which the auth manager
+	// reloads its configuration, if it supports it. Disabled if 0.
+	ReloadInterval int `mapstructure:"reload_interval"`
+	// WatchFiles reloads the configuration of the auth manager and
+	// the blocked ips as soon as their files change on disk.
+	WatchFiles bool `mapstructure:"watch_files"`
+	// Impersonation allows trusted service accounts to act on behalf of the users.
+	Impersonation impersonationConfig `mapstructure:"impersonation"`
+	// BlockedIPs denies the authentication to the clients from these addresses.
+	BlockedIPs   blockedIPsConfig `mapstructure:"blocked_ips"`
 	blockedUsers []string
 }
 
@@ -56,6 +70,8 @@ func (c *config) init() {
 	if c.AuthManager == "" {
 		c.AuthManager = "json"
 	}
+	c.Impersonation.init()
+	c.BlockedIPs.init()
 	c.blockedUsers = sharedconf.GetBlockedUsers()
 }
 
@@ -65,6 +81,10 @@ type service struct {
 	conf         *config
 	plugin       *plugin.RevaPlugin
 	blockedUsers user.BlockedUsers
+	blockedIPs   *blockedIPs
+	// impersonationRole is the role of the users impersonated by the trusted clients
+	impersonationRole provider.Role
+	quit              chan struct{}
 }
 
 func parseConfig(m map[string]interface{}) (*config, error) {
@@ -111,28 +131,168 @@ func New(m map[string]interface{}, ss *grpc.Server) (rgrpc.Service, error) {
 		return nil, err
 	}
 
+	role, err := c.Impersonation.role()
+	if err != nil {
+		return nil, err
+	}
+
+	blockedIPs, err := newBlockedIPs(&c.BlockedIPs)
+	if err != nil {
+		return nil, err
+	}
+
 	authManager, plug, err := getAuthManager(c.AuthManager, c.AuthManagers)
 	if err != nil {
 		return nil, err
 	}
 
 	svc := &service{
-		conf:         c,
-		authmgr:      authManager,
-		plugin:       plug,
-		blockedUsers: user.NewBlockedUsersSet(c.blockedUsers),
+		conf:              c,
+		authmgr:           authManager,
+		plugin:            plug,
+		blockedUsers:      user.NewBlockedUsersSet(c.blockedUsers),
+		blockedIPs:        blockedIPs,
+		impersonationRole: role,
+		quit:              make(chan struct{}),
+	}
+
+	r, _ := authManager.(auth.Reloader)
+	var watcher *fsnotify.Watcher
+	if c.WatchFiles {
+		watcher, err = svc.watch(r)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if watcher != nil || c.ReloadInterval > 0 && (r != nil || c.BlockedIPs.File != "") {
+		go svc.reload(r, watcher)
 	}
 
 	return svc, nil
 }
 
+// watch watches the directories of the configuration files of the auth manager
+// and of the blocked ips file rather than the files themselves, so that the
+// changes made by replacing the files are noticed too.
+// It returns a nil watcher if there are no files to watch.
+func (s *service) watch(r auth.Reloader) (*fsnotify.Watcher, error) {
+	var files []string
+	if r != nil {
+		files = append(files, r.ConfigFiles()...)
+	}
+	if s.conf.BlockedIPs.File != "" {
+		files = append(files, s.conf.BlockedIPs.File)
+	}
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "authprovider: error creating the config files watcher")
+	}
+	for _, f := range files {
+		if err := watcher.Add(filepath.Dir(f)); err != nil {
+			watcher.Close()
+			return nil, errors.Wrapf(err, "authprovider: error watching %s", f)
+		}
+	}
+	return watcher, nil
+}
+
+// reload reloads the configuration of the auth manager, if it supports it,
+// and the blocked ips, periodically and as soon as their files change,
+// keeping the previous ones in case of errors.
+func (s *service) reload(r auth.Reloader, watcher *fsnotify.Watcher) {
+	var tick <-chan time.Time
+	if s.conf.ReloadInterval > 0 {
+		ticker := time.NewTicker(time.Duration(s.conf.ReloadInterval) * time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+	if watcher != nil {
+		defer watcher.Close()
+		events = watcher.Events
+		watchErrors = watcher.Errors
+	}
+
+	for {
+		select {
+		case <-s.quit:
+			return
+		case <-tick:
+			s.reloadAuthManager(r)
+			s.reloadBlockedIPs()
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) == 0 {
+				continue
+			}
+			if r != nil && isFile(ev.Name, r.ConfigFiles()...) {
+				s.reloadAuthManager(r)
+			}
+			if s.conf.BlockedIPs.File != "" && isFile(ev.Name, s.conf.BlockedIPs.File) {
+				s.reloadBlockedIPs()
+			}
+		case err, ok := <-watchErrors:
+			if !ok {
+				watchErrors = nil
+				continue
+			}
+			log.Error().Err(err).Msg("authprovider: error watching the config files")
+		}
+	}
+}
+
+func (s *service) reloadAuthManager(r auth.Reloader) {
+	if r == nil {
+		return
+	}
+	if err := r.Reload(context.Background()); err != nil {
+		log.Error().Err(err).Str("auth_manager", s.conf.AuthManager).Msg("authprovider: error reloading the auth manager, keeping the previous configuration")
+	}
+}
+
+func (s *service) reloadBlockedIPs() {
+	if s.conf.BlockedIPs.File == "" {
+		return
+	}
+	if err := s.blockedIPs.load(); err != nil {
+		log.Error().Err(err).Msg("authprovider: error reloading the blocked ips, keeping the previous ones")
+	}
+}
+
+// isFile returns whether name is one of the files.
+func isFile(name string, files ...string) bool {
+	for _, f := range files {
+		if filepath.Clean(name) == filepath.Clean(f) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *service) Close() error {
+	close(s.quit)
 	if s.plugin != nil {
 		s.plugin.Kill()
 	}
 	return nil
 }
 
+// Health reports the health of the auth manager, if it supports it.
+func (s *service) Health(ctx context.Context) error {
+	if r, ok := s.authmgr.(rgrpc.HealthReporter); ok {
+		return r.Health(ctx)
+	}
+	return nil
+}
+
 func (s *service) UnprotectedEndpoints() []string {
 	return []string{"/cs3.auth.provider.v1beta1.ProviderAPI/Authenticate"}
 }
@@ -149,6 +309,13 @@ func (s *service) Authenticate(ctx context.Context, req *provider.AuthenticateRe
 	username := req.ClientId
 	password := req.ClientSecret
 
+	if ip, blocked := s.blockedIPs.isBlocked(ctx); blocked {
+		log.Warn().Str("ip", ip.String()).Msg("authentication from a blocked ip")
+		return &provider.AuthenticateResponse{
+			Status: status.NewPermissionDenied(ctx, errtypes.PermissionDenied(ip.String()), "ip is blocked"),
+		}, nil
+	}
+
 	if s.blockedUsers.IsBlocked(username) {
 		return &provider.AuthenticateResponse{
 			Status: status.NewPermissionDenied(ctx, errtypes.PermissionDenied(""), "user is blocked"),
@@ -158,7 +325,11 @@ func (s *service) Authenticate(ctx context.Context, req *provider.AuthenticateRe
 	u, scope, err := s.authmgr.Authenticate(ctx, username, password)
 	switch v := err.(type) {
 	case nil:
-		log.Info().Interface("userId", u.Id).Msg("user authenticated")
+		span.SetAttributes(attribute.String("auth_manager", s.conf.AuthManager))
+		if target, ok := impersonatedUser(req); ok {
+			return s.impersonate(ctx, username, target), nil
+		}
+		log.Info().Interface("userId", u.Id).Str("auth_manager", s.conf.AuthManager).Msg("user authenticated")
 		return &provider.AuthenticateResponse{
 			Status:     status.NewOK(ctx),
 			User:       u,
@@ -172,6 +343,10 @@ func (s *service) Authenticate(ctx context.Context, req *provider.AuthenticateRe
 		return &provider.AuthenticateResponse{
 			Status: status.NewNotFound(ctx, "unknown client id"),
 		}, nil
+	case errtypes.PermissionDenied:
+		return &provider.AuthenticateResponse{
+			Status: status.NewPermissionDenied(ctx, v, "user not authorized"),
+		}, nil
 	default:
 		err = errors.Wrap(err, "authsvc: error in Authenticate")
 		return &provider.AuthenticateResponse{
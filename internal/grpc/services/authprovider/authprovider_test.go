@@ -0,0 +1,132 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package authprovider
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/pkg/auth"
+	"github.com/cs3org/reva/pkg/auth/manager/registry"
+	"github.com/cs3org/reva/pkg/errtypes"
+)
+
+func init() {
+	registry.Register("reloadtest", func(m map[string]interface{}) (auth.Manager, error) {
+		r := &fakeReloader{file: m["file"].(string)}
+		return r, r.Reload(context.Background())
+	})
+}
+
+// fakeReloader authenticates the clients listed in its file, whose secret is their id.
+type fakeReloader struct {
+	file    string
+	mu      sync.RWMutex
+	clients []string
+}
+
+func (r *fakeReloader) Configure(map[string]interface{}) error { return nil }
+
+func (r *fakeReloader) Authenticate(ctx context.Context, clientID, clientSecret string) (*userpb.User, map[string]*provider.Scope, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, c := range r.clients {
+		if c == clientID && clientSecret == clientID {
+			return &userpb.User{Id: &userpb.UserId{OpaqueId: clientID}, Username: clientID}, nil, nil
+		}
+	}
+	return nil, nil, errtypes.InvalidCredentials(clientID)
+}
+
+func (r *fakeReloader) Reload(ctx context.Context) error {
+	b, err := os.ReadFile(r.file)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.clients = strings.Fields(string(b))
+	return nil
+}
+
+func (r *fakeReloader) ConfigFiles() []string { return []string{r.file} }
+
+// waitFor waits until cond is true, failing the test if it does not happen in time.
+func waitFor(t *testing.T, msg string, cond func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return
+		}
+	}
+	t.Fatal(msg)
+}
+
+func TestWatchFiles(t *testing.T) {
+	dir := t.TempDir()
+	clients := filepath.Join(dir, "clients")
+	blocked := filepath.Join(dir, "blocked.json")
+	if err := os.WriteFile(clients, []byte("einstein"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(blocked, []byte(`[]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	svc, err := New(map[string]interface{}{
+		"auth_manager":  "reloadtest",
+		"auth_managers": map[string]map[string]interface{}{"reloadtest": {"file": clients}},
+		"blocked_ips":   map[string]interface{}{"file": blocked},
+		"watch_files":   true,
+	}, nil)
+	if err != nil {
+		t.Fatalf("not expected error creating the service: %+v", err)
+	}
+	t.Cleanup(func() { _ = svc.Close() })
+	s := svc.(*service)
+
+	authenticate := func(client string) rpc.Code {
+		res, err := s.Authenticate(peerContext("192.0.2.10"), &provider.AuthenticateRequest{ClientId: client, ClientSecret: client})
+		if err != nil {
+			t.Fatalf("not expected error authenticating: %+v", err)
+		}
+		return res.Status.Code
+	}
+	if code := authenticate("marie"); code == rpc.Code_CODE_OK {
+		t.Fatal("expected the client not in the file to be refused")
+	}
+
+	if err := os.WriteFile(clients, []byte("einstein marie"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "expected the auth manager to be reloaded", func() bool { return authenticate("marie") == rpc.Code_CODE_OK })
+
+	if err := os.WriteFile(blocked, []byte(`["192.0.2.10"]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "expected the blocked ips to be reloaded", func() bool { return authenticate("marie") == rpc.Code_CODE_PERMISSION_DENIED })
+}
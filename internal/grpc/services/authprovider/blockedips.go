@@ -0,0 +1,190 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package authprovider
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+type blockedIPsConfig struct {
+	// IPs are the addresses or networks, in CIDR notation, not allowed to authenticate.
+	IPs []string `mapstructure:"ips"`
+	// File holds a json list of further addresses or networks, reloaded
+	// at the reload interval of the service.
+	File string `mapstructure:"file"`
+	// ForwardedHeader is the metadata key holding the addresses of the client
+	// and of the proxies it went through, e.g. x-forwarded-for. It is only
+	// considered for the requests coming from the trusted proxies.
+	// The authentications reach the auth provider through the gateway, which
+	// forwards in x-forwarded-for the addresses received from the HTTP
+	// services: the gateways and the HTTP services, as well as the reverse
+	// proxies in front of them, have to be trusted for the address of the
+	// client to be found. Otherwise, only the direct callers can be blocked.
+	ForwardedHeader string   `mapstructure:"forwarded_header"`
+	TrustedProxies  []string `mapstructure:"trusted_proxies"`
+}
+
+func (c *blockedIPsConfig) init() {
+	c.ForwardedHeader = strings.ToLower(c.ForwardedHeader)
+}
+
+// ipSet is a set of networks, single addresses being networks of one address.
+type ipSet []*net.IPNet
+
+func parseIPSet(entries []string) (ipSet, error) {
+	s := make(ipSet, 0, len(entries))
+	for _, e := range entries {
+		e = strings.TrimSpace(e)
+		if e == "" {
+			continue
+		}
+		if !strings.Contains(e, "/") {
+			ip := net.ParseIP(e)
+			if ip == nil {
+				return nil, fmt.Errorf("authprovider: invalid ip address %s", e)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			s = append(s, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(e)
+		if err != nil {
+			return nil, fmt.Errorf("authprovider: invalid network %s: %w", e, err)
+		}
+		s = append(s, n)
+	}
+	return s, nil
+}
+
+func (s ipSet) contains(ip net.IP) bool {
+	for _, n := range s {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// blockedIPs checks the address of the clients against the deny-list.
+type blockedIPs struct {
+	conf    *blockedIPsConfig
+	trusted ipSet
+
+	mu      sync.RWMutex
+	blocked ipSet
+}
+
+func newBlockedIPs(c *blockedIPsConfig) (*blockedIPs, error) {
+	trusted, err := parseIPSet(c.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	b := &blockedIPs{conf: c, trusted: trusted}
+	if err := b.load(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// load reads the deny-list from the configuration and from the file, if any.
+// On error, the previous deny-list is kept.
+func (b *blockedIPs) load() error {
+	entries := append([]string{}, b.conf.IPs...)
+	if b.conf.File != "" {
+		f, err := os.ReadFile(b.conf.File)
+		if err != nil {
+			return fmt.Errorf("authprovider: error reading the blocked ips file: %w", err)
+		}
+		var fromFile []string
+		if err := json.Unmarshal(f, &fromFile); err != nil {
+			return fmt.Errorf("authprovider: error unmarshalling the blocked ips file: %w", err)
+		}
+		entries = append(entries, fromFile...)
+	}
+
+	blocked, err := parseIPSet(entries)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.blocked = blocked
+	return nil
+}
+
+// isBlocked returns the address of the client and whether it is blocked.
+func (b *blockedIPs) isBlocked(ctx context.Context) (net.IP, bool) {
+	ip := b.clientIP(ctx)
+	if ip == nil {
+		return nil, false
+	}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return ip, b.blocked.contains(ip)
+}
+
+// clientIP returns the address of the peer or, for the requests coming from
+// the trusted proxies, the last address in the forwarded header not belonging
+// to a trusted proxy.
+func (b *blockedIPs) clientIP(ctx context.Context) net.IP {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		host = p.Addr.String()
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || b.conf.ForwardedHeader == "" || !b.trusted.contains(ip) {
+		return ip
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	var hops []string
+	for _, v := range md.Get(b.conf.ForwardedHeader) {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := net.ParseIP(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			// not trustworthy from here on
+			break
+		}
+		ip = hop
+		if !b.trusted.contains(hop) {
+			break
+		}
+	}
+	return ip
+}
@@ -0,0 +1,152 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package authprovider
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func newBlockedIPsService(t *testing.T, blockedIPs map[string]interface{}) *service {
+	svc, err := New(map[string]interface{}{
+		"auth_manager": "impersonationtest",
+		"blocked_ips":  blockedIPs,
+	}, nil)
+	if err != nil {
+		t.Fatalf("not expected error creating the service: %+v", err)
+	}
+	t.Cleanup(func() { _ = svc.Close() })
+	return svc.(*service)
+}
+
+func peerContext(addr string, md ...string) context.Context {
+	ctx := peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 43210}})
+	if len(md) != 0 {
+		ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(md...))
+	}
+	return ctx
+}
+
+func TestBlockedIPs(t *testing.T) {
+	s := newBlockedIPsService(t, map[string]interface{}{
+		"ips":              []string{"192.0.2.10", "198.51.100.0/24", "2001:db8::/32"},
+		"forwarded_header": "X-Forwarded-For",
+		"trusted_proxies":  []string{"10.0.0.0/8"},
+	})
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		code rpc.Code
+	}{
+		{name: "blocked ip", ctx: peerContext("192.0.2.10"), code: rpc.Code_CODE_PERMISSION_DENIED},
+		{name: "blocked network", ctx: peerContext("198.51.100.7"), code: rpc.Code_CODE_PERMISSION_DENIED},
+		{name: "blocked ipv6 network", ctx: peerContext("2001:db8::1"), code: rpc.Code_CODE_PERMISSION_DENIED},
+		{name: "allowed ip", ctx: peerContext("192.0.2.11"), code: rpc.Code_CODE_OK},
+		{name: "no peer", ctx: context.Background(), code: rpc.Code_CODE_OK},
+		{
+			name: "blocked ip behind a trusted proxy",
+			ctx:  peerContext("10.0.0.1", "x-forwarded-for", "192.0.2.10, 10.0.0.2"),
+			code: rpc.Code_CODE_PERMISSION_DENIED,
+		},
+		{
+			name: "allowed ip behind a trusted proxy",
+			ctx:  peerContext("10.0.0.1", "x-forwarded-for", "192.0.2.10, 192.0.2.11"),
+			code: rpc.Code_CODE_OK,
+		},
+		{
+			name: "forwarded header from an untrusted peer",
+			ctx:  peerContext("192.0.2.11", "x-forwarded-for", "192.0.2.10"),
+			code: rpc.Code_CODE_OK,
+		},
+		{
+			name: "spoofed forwarded header",
+			ctx:  peerContext("192.0.2.10", "x-forwarded-for", "192.0.2.11"),
+			code: rpc.Code_CODE_PERMISSION_DENIED,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := s.Authenticate(tt.ctx, &provider.AuthenticateRequest{ClientId: "einstein", ClientSecret: "einstein"})
+			if err != nil {
+				t.Fatalf("not expected error authenticating: %+v", err)
+			}
+			if res.Status.Code != tt.code {
+				t.Errorf("expected code %v, got %v", tt.code, res.Status.Code)
+			}
+		})
+	}
+}
+
+func TestReloadBlockedIPs(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "blocked.json")
+	if err := os.WriteFile(file, []byte(`["192.0.2.10"]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	s := newBlockedIPsService(t, map[string]interface{}{"file": file})
+
+	if _, blocked := s.blockedIPs.isBlocked(peerContext("192.0.2.10")); !blocked {
+		t.Error("expected the ip in the file to be blocked")
+	}
+
+	if err := os.WriteFile(file, []byte(`["192.0.2.11"]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.blockedIPs.load(); err != nil {
+		t.Fatalf("not expected error reloading the blocked ips: %+v", err)
+	}
+	if _, blocked := s.blockedIPs.isBlocked(peerContext("192.0.2.10")); blocked {
+		t.Error("expected the ip removed from the file to be allowed")
+	}
+	if _, blocked := s.blockedIPs.isBlocked(peerContext("192.0.2.11")); !blocked {
+		t.Error("expected the ip added to the file to be blocked")
+	}
+
+	// invalid entries are not loaded, and the previous ones are kept
+	if err := os.WriteFile(file, []byte(`["not an ip"]`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.blockedIPs.load(); err == nil {
+		t.Error("expected an error reloading invalid blocked ips")
+	}
+	if _, blocked := s.blockedIPs.isBlocked(peerContext("192.0.2.11")); !blocked {
+		t.Error("expected the previous blocked ips to be kept")
+	}
+}
+
+func TestInvalidBlockedIPs(t *testing.T) {
+	for _, c := range []map[string]interface{}{
+		{"ips": []string{"192.0.2.300"}},
+		{"ips": []string{"192.0.2.0/33"}},
+		{"trusted_proxies": []string{"proxy"}},
+	} {
+		if _, err := New(map[string]interface{}{"auth_manager": "impersonationtest", "blocked_ips": c}, nil); err == nil {
+			t.Errorf("expected an error for the configuration %v", c)
+		}
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package authprovider
+
+import (
+	"context"
+	"fmt"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/auth/scope"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/rgrpc/status"
+	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/pkg/sharedconf"
+)
+
+// impersonateKey is the key of the request opaque holding
+// the username of the user to impersonate.
+const impersonateKey = "impersonate"
+
+type impersonationConfig struct {
+	// TrustedClients are the client ids allowed to impersonate the users,
+	// after authenticating with their own secret.
+	TrustedClients []string `mapstructure:"trusted_clients"`
+	// AllowedGroups, if set, restricts the impersonation to the members of these groups.
+	AllowedGroups []string `mapstructure:"allowed_groups"`
+	// Role of the impersonated users, either viewer or editor. Defaults to viewer.
+	Role       string `mapstructure:"role"`
+	GatewaySvc string `mapstructure:"gatewaysvc"`
+}
+
+func (c *impersonationConfig) init() {
+	if c.Role == "" {
+		c.Role = "viewer"
+	}
+	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
+}
+
+func (c *impersonationConfig) role() (provider.Role, error) {
+	switch c.Role {
+	case "viewer":
+		return provider.Role_ROLE_VIEWER, nil
+	case "editor":
+		return provider.Role_ROLE_EDITOR, nil
+	default:
+		return provider.Role_ROLE_INVALID, fmt.Errorf("authprovider: invalid impersonation role %s", c.Role)
+	}
+}
+
+func (c *impersonationConfig) trusted(clientID string) bool {
+	for _, id := range c.TrustedClients {
+		if id == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *impersonationConfig) allowed(u *userpb.User) bool {
+	if len(c.AllowedGroups) == 0 {
+		return true
+	}
+	for _, g := range c.AllowedGroups {
+		for _, ug := range u.Groups {
+			if g == ug {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// impersonatedUser returns the username of the user to impersonate, if requested.
+func impersonatedUser(req *provider.AuthenticateRequest) (string, bool) {
+	e, ok := req.GetOpaque().GetMap()[impersonateKey]
+	if !ok || e.Decoder != "plain" || len(e.Value) == 0 {
+		return "", false
+	}
+	return string(e.Value), true
+}
+
+// impersonate returns the target user on behalf of the trusted client, already
+// authenticated, with a restricted scope not allowing to share the resources.
+func (s *service) impersonate(ctx context.Context, clientID, target string) *provider.AuthenticateResponse {
+	log := appctx.GetLogger(ctx)
+
+	if !s.conf.Impersonation.trusted(clientID) {
+		return &provider.AuthenticateResponse{
+			Status: status.NewPermissionDenied(ctx, errtypes.PermissionDenied(clientID), "client not allowed to impersonate users"),
+		}
+	}
+	if s.blockedUsers.IsBlocked(target) {
+		return &provider.AuthenticateResponse{
+			Status: status.NewPermissionDenied(ctx, errtypes.PermissionDenied(target), "impersonated user is blocked"),
+		}
+	}
+
+	client, err := pool.GetGatewayServiceClient(ctx, pool.Endpoint(s.conf.Impersonation.GatewaySvc))
+	if err != nil {
+		return &provider.AuthenticateResponse{
+			Status: status.NewInternal(ctx, err, "error getting gateway client"),
+		}
+	}
+	res, err := client.GetUserByClaim(ctx, &userpb.GetUserByClaimRequest{
+		Claim: "username",
+		Value: target,
+	})
+	switch {
+	case err != nil:
+		return &provider.AuthenticateResponse{
+			Status: status.NewInternal(ctx, err, "error getting the impersonated user"),
+		}
+	case res.Status.Code == rpc.Code_CODE_NOT_FOUND:
+		return &provider.AuthenticateResponse{
+			Status: status.NewNotFound(ctx, "unknown impersonated user"),
+		}
+	case res.Status.Code != rpc.Code_CODE_OK:
+		return &provider.AuthenticateResponse{
+			Status: status.NewInternal(ctx, errtypes.InternalError(res.Status.Message), "error getting the impersonated user"),
+		}
+	}
+
+	u := res.User
+	if !s.conf.Impersonation.allowed(u) {
+		return &provider.AuthenticateResponse{
+			Status: status.NewPermissionDenied(ctx, errtypes.PermissionDenied(target), "impersonated user not in the allowed groups"),
+		}
+	}
+
+	scope, err := scope.AddImpersonationScope(s.impersonationRole, nil)
+	if err != nil {
+		return &provider.AuthenticateResponse{
+			Status: status.NewInternal(ctx, err, "error creating the impersonation scope"),
+		}
+	}
+
+	log.Info().Str("impersonator", clientID).Str("impersonated", u.Username).Interface("userId", u.Id).
+		Str("role", s.conf.Impersonation.Role).Msg("user impersonated")
+	return &provider.AuthenticateResponse{
+		Status:     status.NewOK(ctx),
+		User:       u,
+		TokenScope: scope,
+	}
+}
@@ -0,0 +1,234 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package authprovider
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	storageprovider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/auth"
+	"github.com/cs3org/reva/pkg/auth/manager/registry"
+	"github.com/cs3org/reva/pkg/auth/scope"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/user"
+	"google.golang.org/grpc"
+)
+
+func init() {
+	registry.Register("impersonationtest", func(map[string]interface{}) (auth.Manager, error) {
+		return &fakeManager{}, nil
+	})
+}
+
+// fakeManager authenticates the clients whose secret is their id.
+type fakeManager struct{}
+
+func (m *fakeManager) Configure(map[string]interface{}) error { return nil }
+
+func (m *fakeManager) Authenticate(ctx context.Context, clientID, clientSecret string) (*userpb.User, map[string]*provider.Scope, error) {
+	if clientSecret != clientID {
+		return nil, nil, errtypes.InvalidCredentials(clientID)
+	}
+	s, err := scope.AddOwnerScope(nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &userpb.User{Id: &userpb.UserId{OpaqueId: clientID}, Username: clientID}, s, nil
+}
+
+// fakeGateway resolves the users by username.
+type fakeGateway struct {
+	gateway.UnimplementedGatewayAPIServer
+	users map[string]*userpb.User
+}
+
+func (g *fakeGateway) GetUserByClaim(ctx context.Context, req *userpb.GetUserByClaimRequest) (*userpb.GetUserByClaimResponse, error) {
+	u, ok := g.users[req.Value]
+	if req.Claim != "username" || !ok {
+		return &userpb.GetUserByClaimResponse{Status: &rpc.Status{Code: rpc.Code_CODE_NOT_FOUND}}, nil
+	}
+	return &userpb.GetUserByClaimResponse{Status: &rpc.Status{Code: rpc.Code_CODE_OK}, User: u}, nil
+}
+
+func startGateway(t *testing.T, g *fakeGateway) string {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("not expected error while listening: %+v", err)
+	}
+	s := grpc.NewServer()
+	gateway.RegisterGatewayAPIServer(s, g)
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(s.Stop)
+	return l.Addr().String()
+}
+
+func newImpersonationService(t *testing.T, role string) *service {
+	g := &fakeGateway{users: map[string]*userpb.User{
+		"marie":    {Id: &userpb.UserId{OpaqueId: "marie"}, Username: "marie", Groups: []string{"physics"}},
+		"richard":  {Id: &userpb.UserId{OpaqueId: "richard"}, Username: "richard", Groups: []string{"chemistry"}},
+		"moriarty": {Id: &userpb.UserId{OpaqueId: "moriarty"}, Username: "moriarty", Groups: []string{"physics"}},
+	}}
+	svc, err := New(map[string]interface{}{
+		"auth_manager": "impersonationtest",
+		"impersonation": map[string]interface{}{
+			"trusted_clients": []string{"migration"},
+			"allowed_groups":  []string{"physics"},
+			"role":            role,
+			"gatewaysvc":      startGateway(t, g),
+		},
+	}, nil)
+	if err != nil {
+		t.Fatalf("not expected error creating the service: %+v", err)
+	}
+	t.Cleanup(func() { _ = svc.Close() })
+	s := svc.(*service)
+	s.blockedUsers = user.NewBlockedUsersSet([]string{"moriarty"})
+	return s
+}
+
+func impersonateRequest(clientID, clientSecret, target string) *provider.AuthenticateRequest {
+	return &provider.AuthenticateRequest{
+		ClientId:     clientID,
+		ClientSecret: clientSecret,
+		Opaque: &types.Opaque{Map: map[string]*types.OpaqueEntry{
+			impersonateKey: {Decoder: "plain", Value: []byte(target)},
+		}},
+	}
+}
+
+func TestImpersonation(t *testing.T) {
+	ctx := context.Background()
+	s := newImpersonationService(t, "")
+
+	res, err := s.Authenticate(ctx, impersonateRequest("migration", "migration", "marie"))
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+		t.Fatalf("expected marie to be impersonated, got %+v, %+v", res, err)
+	}
+	if res.User.Username != "marie" {
+		t.Fatalf("expected the impersonated user, got %s", res.User.Username)
+	}
+	if s, ok := res.TokenScope["impersonation"]; !ok || s.Role != provider.Role_ROLE_VIEWER || len(res.TokenScope) != 1 {
+		t.Fatalf("expected the impersonation scope with the viewer role, got %+v", res.TokenScope)
+	}
+
+	allowed := map[interface{}]bool{
+		&storageprovider.StatRequest{}:                 true,
+		&storageprovider.InitiateFileDownloadRequest{}: true,
+		&storageprovider.InitiateFileUploadRequest{}:   false,
+		&storageprovider.DeleteRequest{}:               false,
+		&collaboration.CreateShareRequest{}:            false,
+		&storageprovider.AddGrantRequest{}:             false,
+		"/remote.php/dav/files/marie/some/file":        true,
+	}
+	for resource, expected := range allowed {
+		ok, err := scope.VerifyScope(ctx, res.TokenScope, resource)
+		if err != nil || ok != expected {
+			t.Fatalf("unexpected access to %T: got=%v expected=%v", resource, ok, expected)
+		}
+	}
+
+	// without the opaque the client authenticates as itself
+	res, err = s.Authenticate(ctx, &provider.AuthenticateRequest{ClientId: "migration", ClientSecret: "migration"})
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK || res.User.Username != "migration" {
+		t.Fatalf("expected the client to be authenticated, got %+v, %+v", res, err)
+	}
+}
+
+func TestImpersonationEditor(t *testing.T) {
+	ctx := context.Background()
+	s := newImpersonationService(t, "editor")
+
+	res, err := s.Authenticate(ctx, impersonateRequest("migration", "migration", "marie"))
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+		t.Fatalf("expected marie to be impersonated, got %+v, %+v", res, err)
+	}
+	if ok, _ := scope.VerifyScope(ctx, res.TokenScope, &storageprovider.InitiateFileUploadRequest{}); !ok {
+		t.Fatalf("expected the editor to upload files")
+	}
+	if ok, _ := scope.VerifyScope(ctx, res.TokenScope, &collaboration.CreateShareRequest{}); ok {
+		t.Fatalf("expected the editor not to create shares")
+	}
+}
+
+func TestImpersonationDenied(t *testing.T) {
+	ctx := context.Background()
+	s := newImpersonationService(t, "")
+
+	tests := []struct {
+		name string
+		req  *provider.AuthenticateRequest
+		code rpc.Code
+	}{
+		{
+			name: "untrusted client",
+			req:  impersonateRequest("einstein", "einstein", "marie"),
+			code: rpc.Code_CODE_PERMISSION_DENIED,
+		},
+		{
+			name: "wrong secret",
+			req:  impersonateRequest("migration", "wrong", "marie"),
+			code: rpc.Code_CODE_PERMISSION_DENIED,
+		},
+		{
+			name: "blocked user",
+			req:  impersonateRequest("migration", "migration", "moriarty"),
+			code: rpc.Code_CODE_PERMISSION_DENIED,
+		},
+		{
+			name: "user outside the allowed groups",
+			req:  impersonateRequest("migration", "migration", "richard"),
+			code: rpc.Code_CODE_PERMISSION_DENIED,
+		},
+		{
+			name: "unknown user",
+			req:  impersonateRequest("migration", "migration", "nobody"),
+			code: rpc.Code_CODE_NOT_FOUND,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := s.Authenticate(ctx, tt.req)
+			if err != nil {
+				t.Fatalf("not expected error: %+v", err)
+			}
+			if res.Status.Code != tt.code || res.User != nil {
+				t.Fatalf("unexpected response: got=%+v expected code=%v", res, tt.code)
+			}
+		})
+	}
+}
+
+func TestImpersonationInvalidRole(t *testing.T) {
+	_, err := New(map[string]interface{}{
+		"auth_manager":  "impersonationtest",
+		"impersonation": map[string]interface{}{"role": "owner"},
+	}, nil)
+	if err == nil {
+		t.Fatalf("expected error with an invalid impersonation role")
+	}
+}
@@ -218,6 +218,10 @@ func (s *svc) openLocalResources(ctx context.Context, ri *storageprovider.Resour
 	return res, nil
 }
 
+// resolvedMimeTypeOpaqueKey is the key in the opaque map of a GetAppProvidersResponse
+// holding the mime type the app registry derived for the resource.
+const resolvedMimeTypeOpaqueKey = "mime_type"
+
 func (s *svc) findAppProvider(ctx context.Context, ri *storageprovider.ResourceInfo, app string) (*registry.ProviderInfo, error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "findAppProvider")
 	defer span.End()
@@ -288,6 +292,13 @@ func (s *svc) findAppProvider(ctx context.Context, ri *storageprovider.ResourceI
 		return nil, errtypes.InternalError("gateway: error finding app providers")
 	}
 
+	// the app registry derives the mime type from the file extension
+	// when the resource has none, or a generic one: the app provider
+	// opens the resource with the derived one
+	if e, ok := res.Opaque.GetMap()[resolvedMimeTypeOpaqueKey]; ok && e.Decoder == "plain" && len(e.Value) != 0 {
+		ri.MimeType = string(e.Value)
+	}
+
 	// as long as the above mentioned GetAppProviderByName(app) method is not available
 	// we need to apply a manual filter
 	filteredProviders := []*registry.ProviderInfo{}
@@ -0,0 +1,128 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/ReneKroon/ttlcache/v2"
+	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/user"
+)
+
+// basicAuthType is the auth type of the password-based authentications,
+// which are cached only if explicitly enabled.
+const basicAuthType = "basic"
+
+// authentication is the result of a successful authentication by an auth provider.
+type authentication struct {
+	clientID string
+	user     *userpb.User
+	scope    map[string]*authpb.Scope
+}
+
+// authCache keeps the successful authentications by the auth providers for a
+// short time, so that clients sending many requests with the same credentials
+// (e.g. the PROPFINDs of a WebDAV client) don't hit the auth provider every time.
+// The credentials are never stored: the entries are keyed by the auth type and
+// a hash of the client id and secret, and of the addresses the request has been
+// forwarded from, so that an authentication is never reused from another address.
+type authCache struct {
+	cache        *ttlcache.Cache
+	cacheBasic   bool
+	blockedUsers user.BlockedUsers
+}
+
+// newAuthCache returns nil, i.e. a disabled cache, if the ttl is not positive.
+func newAuthCache(ttl time.Duration, size int, cacheBasic bool, blockedUsers []string) *authCache {
+	if ttl <= 0 {
+		return nil
+	}
+	cache := ttlcache.NewCache()
+	_ = cache.SetTTL(ttl)
+	cache.SkipTTLExtensionOnHit(true)
+	if size > 0 {
+		cache.SetCacheSizeLimit(size)
+	}
+	return &authCache{
+		cache:        cache,
+		cacheBasic:   cacheBasic,
+		blockedUsers: user.NewBlockedUsersSet(blockedUsers),
+	}
+}
+
+func (c *authCache) cacheable(authType string) bool {
+	return c != nil && (authType != basicAuthType || c.cacheBasic)
+}
+
+func authCacheKey(authType, clientID, clientSecret, forwardedFor string) string {
+	h := sha256.New()
+	_, _ = h.Write([]byte(clientID))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(clientSecret))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(forwardedFor))
+	return authType + ":" + hex.EncodeToString(h.Sum(nil))
+}
+
+func (c *authCache) get(authType, clientID, clientSecret, forwardedFor string) (*authentication, bool) {
+	if !c.cacheable(authType) {
+		return nil, false
+	}
+	v, err := c.cache.Get(authCacheKey(authType, clientID, clientSecret, forwardedFor))
+	if err != nil {
+		return nil, false
+	}
+	auth := v.(*authentication)
+	if c.blockedUsers.IsBlocked(auth.user.Username) || c.blockedUsers.IsBlocked(auth.clientID) {
+		c.invalidate(auth.clientID)
+		return nil, false
+	}
+	return auth, true
+}
+
+func (c *authCache) set(authType, clientID, clientSecret, forwardedFor string, auth *authentication) {
+	if !c.cacheable(authType) {
+		return
+	}
+	auth.clientID = clientID
+	_ = c.cache.Set(authCacheKey(authType, clientID, clientSecret, forwardedFor), auth)
+}
+
+// invalidate removes all the cached authentications of a user, identified either
+// by the client id or by the username, e.g. once the user has been blocked.
+func (c *authCache) invalidate(user string) {
+	if c == nil {
+		return
+	}
+	for k, v := range c.cache.GetItems() {
+		if auth := v.(*authentication); auth.clientID == user || auth.user.GetUsername() == user {
+			_ = c.cache.Remove(k)
+		}
+	}
+}
+
+func (c *authCache) close() {
+	if c != nil {
+		_ = c.cache.Close()
+	}
+}
@@ -0,0 +1,297 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// fakeAuthProviderClient is an auth provider accepting the secrets equal to
+// the client id, and denying the access to the blocked users.
+type fakeAuthProviderClient struct {
+	authpb.ProviderAPIClient
+	blocked map[string]bool
+	latency time.Duration
+
+	mu           sync.Mutex
+	calls        int
+	forwardedFor []string
+}
+
+func (c *fakeAuthProviderClient) Authenticate(ctx context.Context, req *authpb.AuthenticateRequest, opts ...grpc.CallOption) (*authpb.AuthenticateResponse, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	c.mu.Lock()
+	c.calls++
+	c.forwardedFor = md.Get(ctxpkg.ForwardedForHeader)
+	blocked := c.blocked[req.ClientId]
+	c.mu.Unlock()
+	time.Sleep(c.latency)
+
+	switch {
+	case blocked:
+		return &authpb.AuthenticateResponse{Status: &rpc.Status{Code: rpc.Code_CODE_PERMISSION_DENIED}}, nil
+	case req.ClientSecret != req.ClientId:
+		return &authpb.AuthenticateResponse{Status: &rpc.Status{Code: rpc.Code_CODE_UNAUTHENTICATED}}, nil
+	}
+	return &authpb.AuthenticateResponse{
+		Status: &rpc.Status{Code: rpc.Code_CODE_OK},
+		User: &userpb.User{
+			Id:       &userpb.UserId{Idp: "cernbox.cern.ch", OpaqueId: req.ClientId},
+			Username: req.ClientId,
+		},
+	}, nil
+}
+
+func (c *fakeAuthProviderClient) getCalls() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calls
+}
+
+func (c *fakeAuthProviderClient) setBlocked(user string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.blocked[user] = true
+}
+
+func newTestAuthService(c *fakeAuthProviderClient, cache *authCache) (*svc, func(context.Context, string) (authpb.ProviderAPIClient, error)) {
+	s := &svc{c: &config{}, authCache: cache}
+	return s, func(context.Context, string) (authpb.ProviderAPIClient, error) {
+		return c, nil
+	}
+}
+
+func authenticateRequest(authType, clientID, clientSecret string) *gateway.AuthenticateRequest {
+	return &gateway.AuthenticateRequest{Type: authType, ClientId: clientID, ClientSecret: clientSecret}
+}
+
+func TestAuthCache(t *testing.T) {
+	ctx := context.Background()
+	c := &fakeAuthProviderClient{blocked: map[string]bool{}}
+	s, find := newTestAuthService(c, newAuthCache(time.Minute, 100, false, nil))
+
+	for i := 0; i < 3; i++ {
+		res, errRes := s.authenticateWithProvider(ctx, authenticateRequest("bearer", "einstein", "einstein"), find)
+		if errRes != nil || res.User.Username != "einstein" {
+			t.Fatalf("expected einstein to be authenticated, got %+v, %+v", res, errRes)
+		}
+	}
+	if c.getCalls() != 1 {
+		t.Fatalf("expected 1 call to the auth provider, got %d", c.getCalls())
+	}
+
+	// failures are never cached
+	for i := 0; i < 2; i++ {
+		if _, errRes := s.authenticateWithProvider(ctx, authenticateRequest("bearer", "einstein", "wrong"), find); errRes == nil || errRes.Status.Code != rpc.Code_CODE_UNAUTHENTICATED {
+			t.Fatalf("expected unauthenticated status, got %+v", errRes)
+		}
+	}
+	if c.getCalls() != 3 {
+		t.Fatalf("expected 3 calls to the auth provider, got %d", c.getCalls())
+	}
+
+	// the entries are specific to the auth type
+	if _, errRes := s.authenticateWithProvider(ctx, authenticateRequest("publicshares", "einstein", "einstein"), find); errRes != nil {
+		t.Fatalf("expected einstein to be authenticated, got %+v", errRes)
+	}
+	if c.getCalls() != 4 {
+		t.Fatalf("expected 4 calls to the auth provider, got %d", c.getCalls())
+	}
+}
+
+func TestAuthCacheBasic(t *testing.T) {
+	ctx := context.Background()
+	for _, cacheBasic := range []bool{false, true} {
+		c := &fakeAuthProviderClient{blocked: map[string]bool{}}
+		s, find := newTestAuthService(c, newAuthCache(time.Minute, 100, cacheBasic, nil))
+		for i := 0; i < 2; i++ {
+			if _, errRes := s.authenticateWithProvider(ctx, authenticateRequest(basicAuthType, "einstein", "einstein"), find); errRes != nil {
+				t.Fatalf("expected einstein to be authenticated, got %+v", errRes)
+			}
+		}
+		expected := 2
+		if cacheBasic {
+			expected = 1
+		}
+		if c.getCalls() != expected {
+			t.Fatalf("expected %d calls to the auth provider with basic caching set to %v, got %d", expected, cacheBasic, c.getCalls())
+		}
+	}
+}
+
+func TestAuthCacheOpaque(t *testing.T) {
+	ctx := context.Background()
+	c := &fakeAuthProviderClient{blocked: map[string]bool{}}
+	s, find := newTestAuthService(c, newAuthCache(time.Minute, 100, false, nil))
+
+	if _, errRes := s.authenticateWithProvider(ctx, authenticateRequest("bearer", "einstein", "einstein"), find); errRes != nil {
+		t.Fatalf("expected einstein to be authenticated, got %+v", errRes)
+	}
+
+	// the authentications depending on the opaque are neither served from nor stored in the cache
+	req := authenticateRequest("bearer", "einstein", "einstein")
+	req.Opaque = &typespb.Opaque{Map: map[string]*typespb.OpaqueEntry{
+		"impersonate": {Decoder: "plain", Value: []byte("marie")},
+	}}
+	for i := 0; i < 2; i++ {
+		if _, errRes := s.authenticateWithProvider(ctx, req, find); errRes != nil {
+			t.Fatalf("expected einstein to be authenticated, got %+v", errRes)
+		}
+	}
+	if c.getCalls() != 3 {
+		t.Fatalf("expected 3 calls to the auth provider, got %d", c.getCalls())
+	}
+}
+
+func TestAuthCacheDisabled(t *testing.T) {
+	ctx := context.Background()
+	c := &fakeAuthProviderClient{blocked: map[string]bool{}}
+	s, find := newTestAuthService(c, newAuthCache(0, 100, true, nil))
+	for i := 0; i < 2; i++ {
+		if _, errRes := s.authenticateWithProvider(ctx, authenticateRequest("bearer", "einstein", "einstein"), find); errRes != nil {
+			t.Fatalf("expected einstein to be authenticated, got %+v", errRes)
+		}
+	}
+	if c.getCalls() != 2 {
+		t.Fatalf("expected 2 calls to the auth provider, got %d", c.getCalls())
+	}
+}
+
+func TestAuthCacheBlockedUsers(t *testing.T) {
+	ctx := context.Background()
+	c := &fakeAuthProviderClient{blocked: map[string]bool{}}
+	cache := newAuthCache(time.Minute, 100, true, []string{"marie"})
+	s, find := newTestAuthService(c, cache)
+
+	if _, errRes := s.authenticateWithProvider(ctx, authenticateRequest("bearer", "einstein", "einstein"), find); errRes != nil {
+		t.Fatalf("expected einstein to be authenticated, got %+v", errRes)
+	}
+	if _, errRes := s.authenticateWithProvider(ctx, authenticateRequest("publicshares", "einstein", "einstein"), find); errRes != nil {
+		t.Fatalf("expected einstein to be authenticated, got %+v", errRes)
+	}
+
+	// once the user is blocked, all their cached authentications are dropped
+	c.setBlocked("einstein")
+	if _, errRes := s.authenticateWithProvider(ctx, authenticateRequest(basicAuthType, "einstein", "einstein"), find); errRes == nil || errRes.Status.Code != rpc.Code_CODE_PERMISSION_DENIED {
+		t.Fatalf("expected permission denied status, got %+v", errRes)
+	}
+	if _, errRes := s.authenticateWithProvider(ctx, authenticateRequest("bearer", "einstein", "einstein"), find); errRes == nil || errRes.Status.Code != rpc.Code_CODE_PERMISSION_DENIED {
+		t.Fatalf("expected permission denied status for the cached credentials, got %+v", errRes)
+	}
+
+	// the users blocked in the configuration are never served from the cache
+	cache.set("bearer", "marie", "marie", "", &authentication{user: &userpb.User{Username: "marie"}})
+	if _, ok := cache.get("bearer", "marie", "marie", ""); ok {
+		t.Fatalf("expected the authentication of a blocked user not to be served from the cache")
+	}
+}
+
+func TestAuthCacheForwardedFor(t *testing.T) {
+	c := &fakeAuthProviderClient{blocked: map[string]bool{}}
+	s, find := newTestAuthService(c, newAuthCache(time.Minute, 100, false, nil))
+
+	// the requests come from the http services, forwarding the address of the client
+	fromClient := func(client string) context.Context {
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ctxpkg.ForwardedForHeader, client))
+		return peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 41234}})
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, errRes := s.authenticateWithProvider(fromClient("192.0.2.1"), authenticateRequest("bearer", "einstein", "einstein"), find); errRes != nil {
+			t.Fatalf("expected einstein to be authenticated, got %+v", errRes)
+		}
+	}
+	if c.getCalls() != 1 {
+		t.Fatalf("expected 1 call to the auth provider, got %d", c.getCalls())
+	}
+	if fwd := c.forwardedFor; len(fwd) != 1 || fwd[0] != "192.0.2.1, 10.0.0.1" {
+		t.Fatalf("expected the addresses of the client and of the http service to be forwarded, got %v", fwd)
+	}
+
+	// the authentications are never reused from another address
+	if _, errRes := s.authenticateWithProvider(fromClient("198.51.100.1"), authenticateRequest("bearer", "einstein", "einstein"), find); errRes != nil {
+		t.Fatalf("expected einstein to be authenticated, got %+v", errRes)
+	}
+	if c.getCalls() != 2 {
+		t.Fatalf("expected 2 calls to the auth provider, got %d", c.getCalls())
+	}
+}
+
+func TestAuthCacheSize(t *testing.T) {
+	cache := newAuthCache(time.Minute, 2, false, nil)
+	for i := 0; i < 5; i++ {
+		user := fmt.Sprintf("user%d", i)
+		cache.set("bearer", user, user, "", &authentication{user: &userpb.User{Username: user}})
+	}
+	if n := cache.cache.Count(); n != 2 {
+		t.Fatalf("expected the cache to be bounded to 2 entries, got %d", n)
+	}
+}
+
+// BenchmarkAuthenticatePropfind simulates WebDAV clients sending bursts of
+// PROPFINDs, each one authenticated with the same credentials of the client,
+// and reports how many of them reach the auth provider.
+func BenchmarkAuthenticatePropfind(b *testing.B) {
+	const clients = 20
+	const propfindsPerClient = 50
+
+	for _, ttl := range []time.Duration{0, time.Minute} {
+		b.Run(fmt.Sprintf("ttl=%v", ttl), func(b *testing.B) {
+			ctx := context.Background()
+			c := &fakeAuthProviderClient{blocked: map[string]bool{}, latency: 100 * time.Microsecond}
+			s, find := newTestAuthService(c, newAuthCache(ttl, 1000, false, nil))
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				var wg sync.WaitGroup
+				for j := 0; j < clients; j++ {
+					token := fmt.Sprintf("token-%d-%d", i, j)
+					wg.Add(1)
+					go func() {
+						defer wg.Done()
+						for k := 0; k < propfindsPerClient; k++ {
+							if _, errRes := s.authenticateWithProvider(ctx, authenticateRequest("bearer", token, token), find); errRes != nil {
+								b.Errorf("expected the client to be authenticated, got %+v", errRes)
+								return
+							}
+						}
+					}()
+				}
+				wg.Wait()
+			}
+
+			b.ReportMetric(float64(c.getCalls())/float64(b.N*clients*propfindsPerClient), "authprovider-calls/request")
+		})
+	}
+}
@@ -45,56 +45,9 @@ func (s *svc) Authenticate(ctx context.Context, req *gateway.AuthenticateRequest
 
 	log := appctx.GetLogger(ctx)
 
-	// find auth provider
-	c, err := s.findAuthProvider(ctx, req.Type)
-	if err != nil {
-		err = errtypes.NotFound("gateway: error finding auth provider for type: " + req.Type)
-		return &gateway.AuthenticateResponse{
-			Status: status.NewInternal(ctx, err, "error getting auth provider client"),
-		}, nil
-	}
-
-	authProviderReq := &authpb.AuthenticateRequest{
-		ClientId:     req.ClientId,
-		ClientSecret: req.ClientSecret,
-	}
-	res, err := c.Authenticate(ctx, authProviderReq)
-	switch {
-	case err != nil:
-		return &gateway.AuthenticateResponse{
-			Status: status.NewInternal(ctx, err, fmt.Sprintf("gateway: error calling Authenticate for type: %s", req.Type)),
-		}, nil
-	case res.Status.Code == rpc.Code_CODE_PERMISSION_DENIED:
-		fallthrough
-	case res.Status.Code == rpc.Code_CODE_UNAUTHENTICATED:
-		fallthrough
-	case res.Status.Code == rpc.Code_CODE_NOT_FOUND:
-		// normal failures, no need to log
-		return &gateway.AuthenticateResponse{
-			Status: res.Status,
-		}, nil
-	case res.Status.Code != rpc.Code_CODE_OK:
-		err := status.NewErrorFromCode(res.Status.Code, "gateway")
-		return &gateway.AuthenticateResponse{
-			Status: status.NewInternal(ctx, err, fmt.Sprintf("error authenticating credentials to auth provider for type: %s", req.Type)),
-		}, nil
-	}
-
-	// validate valid userId
-	if res.User == nil {
-		err := errtypes.NotFound("gateway: user after Authenticate is nil")
-		log.Err(err).Msg("user is nil")
-		return &gateway.AuthenticateResponse{
-			Status: status.NewInternal(ctx, err, "user is nil"),
-		}, nil
-	}
-
-	if res.User.Id == nil {
-		err := errtypes.NotFound("gateway: uid after Authenticate is nil")
-		log.Err(err).Msg("user id is nil")
-		return &gateway.AuthenticateResponse{
-			Status: status.NewInternal(ctx, err, "user id is nil"),
-		}, nil
+	res, errRes := s.authenticateWithProvider(ctx, req, s.findAuthProvider)
+	if errRes != nil {
+		return errRes, nil
 	}
 
 	u := *res.User
@@ -186,6 +139,96 @@ func (s *svc) Authenticate(ctx context.Context, req *gateway.AuthenticateRequest
 	return gwRes, nil
 }
 
+// authenticateWithProvider authenticates the credentials with the auth provider
+// for their type, unless they are found in the auth cache. If the authentication
+// fails, the response to send back is returned instead.
+func (s *svc) authenticateWithProvider(ctx context.Context, req *gateway.AuthenticateRequest, findAuthProvider func(context.Context, string) (authpb.ProviderAPIClient, error)) (*authpb.AuthenticateResponse, *gateway.AuthenticateResponse) {
+	log := appctx.GetLogger(ctx)
+
+	// the authentication may depend on the opaque of the request, e.g. when
+	// impersonating a user, so only the plain credentials are cached
+	cacheable := len(req.GetOpaque().GetMap()) == 0
+
+	// the auth providers may deny the authentications from some addresses,
+	// so the addresses of the client are forwarded to them and are part of
+	// the cache key
+	forwardedFor := ctxpkg.ContextGetForwardedFor(ctx)
+	if forwardedFor != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, ctxpkg.ForwardedForHeader, forwardedFor)
+	}
+
+	if auth, ok := s.authCache.get(req.Type, req.ClientId, req.ClientSecret, forwardedFor); cacheable && ok {
+		return &authpb.AuthenticateResponse{
+			Status:     status.NewOK(ctx),
+			User:       auth.user,
+			TokenScope: auth.scope,
+		}, nil
+	}
+
+	// find auth provider
+	c, err := findAuthProvider(ctx, req.Type)
+	if err != nil {
+		err = errtypes.NotFound("gateway: error finding auth provider for type: " + req.Type)
+		return nil, &gateway.AuthenticateResponse{
+			Status: status.NewInternal(ctx, err, "error getting auth provider client"),
+		}
+	}
+
+	authProviderReq := &authpb.AuthenticateRequest{
+		Opaque:       req.Opaque,
+		ClientId:     req.ClientId,
+		ClientSecret: req.ClientSecret,
+	}
+	res, err := c.Authenticate(ctx, authProviderReq)
+	switch {
+	case err != nil:
+		return nil, &gateway.AuthenticateResponse{
+			Status: status.NewInternal(ctx, err, fmt.Sprintf("gateway: error calling Authenticate for type: %s", req.Type)),
+		}
+	case res.Status.Code == rpc.Code_CODE_PERMISSION_DENIED:
+		// e.g. the user has been blocked, so their other credentials must not be trusted anymore
+		s.authCache.invalidate(req.ClientId)
+		fallthrough
+	case res.Status.Code == rpc.Code_CODE_UNAUTHENTICATED:
+		fallthrough
+	case res.Status.Code == rpc.Code_CODE_NOT_FOUND:
+		// normal failures, no need to log
+		return nil, &gateway.AuthenticateResponse{
+			Status: res.Status,
+		}
+	case res.Status.Code != rpc.Code_CODE_OK:
+		err := status.NewErrorFromCode(res.Status.Code, "gateway")
+		return nil, &gateway.AuthenticateResponse{
+			Status: status.NewInternal(ctx, err, fmt.Sprintf("error authenticating credentials to auth provider for type: %s", req.Type)),
+		}
+	}
+
+	// validate valid userId
+	if res.User == nil {
+		err := errtypes.NotFound("gateway: user after Authenticate is nil")
+		log.Err(err).Msg("user is nil")
+		return nil, &gateway.AuthenticateResponse{
+			Status: status.NewInternal(ctx, err, "user is nil"),
+		}
+	}
+
+	if res.User.Id == nil {
+		err := errtypes.NotFound("gateway: uid after Authenticate is nil")
+		log.Err(err).Msg("user id is nil")
+		return nil, &gateway.AuthenticateResponse{
+			Status: status.NewInternal(ctx, err, "user id is nil"),
+		}
+	}
+
+	if cacheable {
+		s.authCache.set(req.Type, req.ClientId, req.ClientSecret, forwardedFor, &authentication{
+			user:  res.User,
+			scope: res.TokenScope,
+		})
+	}
+	return res, nil
+}
+
 func (s *svc) WhoAmI(ctx context.Context, req *gateway.WhoAmIRequest) (*gateway.WhoAmIResponse, error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "WhoAmI")
 	defer span.End()
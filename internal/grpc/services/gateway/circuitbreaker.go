@@ -0,0 +1,157 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+var errCircuitOpen = errors.New("circuit breaker open")
+
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+func (s circuitState) String() string {
+	switch s {
+	case circuitOpen:
+		return "open"
+	case circuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+type circuit struct {
+	state    circuitState
+	failures int
+	openedAt time.Time
+}
+
+// circuitBreaker stops calling an endpoint after a number of consecutive failures,
+// failing fast for a cool-down period. Once it is over, a single probe request
+// is let through: if it succeeds the circuit is closed again, otherwise it is
+// opened for another cool-down period.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		circuits:  make(map[string]*circuit),
+	}
+}
+
+func (b *circuitBreaker) get(endpoint string) *circuit {
+	c, ok := b.circuits[endpoint]
+	if !ok {
+		c = &circuit{}
+		b.circuits[endpoint] = c
+	}
+	return c
+}
+
+func (b *circuitBreaker) transition(ctx context.Context, endpoint string, c *circuit, state circuitState) {
+	log := appctx.GetLogger(ctx)
+	log.Warn().Str("endpoint", endpoint).Str("from", c.state.String()).Str("to", state.String()).Msg("gateway: circuit breaker state changed")
+	c.state = state
+}
+
+// allow returns an error if the endpoint must not be called.
+// When nil is returned, the outcome of the call must be reported with done.
+func (b *circuitBreaker) allow(ctx context.Context, endpoint string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.get(endpoint)
+	switch c.state {
+	case circuitOpen:
+		if b.now().Sub(c.openedAt) < b.cooldown {
+			return errors.Wrapf(errCircuitOpen, "gateway: endpoint %s", endpoint)
+		}
+		// let this request through as a probe
+		b.transition(ctx, endpoint, c, circuitHalfOpen)
+	case circuitHalfOpen:
+		// a probe is already in flight
+		return errors.Wrapf(errCircuitOpen, "gateway: endpoint %s", endpoint)
+	}
+	return nil
+}
+
+// done reports the outcome of a call to the endpoint.
+// Only errors meaning that the endpoint could not be reached count as failures.
+func (b *circuitBreaker) done(ctx context.Context, endpoint string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	c := b.get(endpoint)
+	if !isUnreachable(err) {
+		c.failures = 0
+		if c.state != circuitClosed {
+			b.transition(ctx, endpoint, c, circuitClosed)
+		}
+		return
+	}
+
+	c.failures++
+	if c.state == circuitHalfOpen || (c.state == circuitClosed && c.failures >= b.threshold) {
+		c.openedAt = b.now()
+		b.transition(ctx, endpoint, c, circuitOpen)
+	}
+}
+
+// callOCM calls f, unless the circuit of the endpoint is open, and reports
+// its outcome to the circuit breaker. When the circuit is open, f is not
+// called and the returned error wraps errCircuitOpen.
+func (s *svc) callOCM(ctx context.Context, endpoint string, f func() error) error {
+	if err := s.ocmCircuitBreaker.allow(ctx, endpoint); err != nil {
+		return err
+	}
+	err := f()
+	s.ocmCircuitBreaker.done(ctx, endpoint, err)
+	return err
+}
+
+func isUnreachable(err error) bool {
+	switch grpcstatus.Code(errors.Cause(err)) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
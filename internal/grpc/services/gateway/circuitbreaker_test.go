@@ -0,0 +1,136 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	invitepb "github.com/cs3org/go-cs3apis/cs3/ocm/invite/v1beta1"
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+const testEndpoint = "localhost:19000"
+
+// fakeInviteClient is an invite manager client whose calls
+// fail as if the service was down when failing is set.
+type fakeInviteClient struct {
+	invitepb.InviteAPIClient
+	failing bool
+	calls   int
+}
+
+func (c *fakeInviteClient) GenerateInviteToken(ctx context.Context, req *invitepb.GenerateInviteTokenRequest, opts ...grpc.CallOption) (*invitepb.GenerateInviteTokenResponse, error) {
+	c.calls++
+	if c.failing {
+		return nil, grpcstatus.Error(codes.Unavailable, "connection refused")
+	}
+	return &invitepb.GenerateInviteTokenResponse{}, nil
+}
+
+func call(ctx context.Context, b *circuitBreaker, c *fakeInviteClient) error {
+	s := &svc{ocmCircuitBreaker: b}
+	return s.callOCM(ctx, testEndpoint, func() error {
+		_, err := c.GenerateInviteToken(ctx, &invitepb.GenerateInviteTokenRequest{})
+		return err
+	})
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	b := newCircuitBreaker(3, 30*time.Second)
+	b.now = func() time.Time { return now }
+	c := &fakeInviteClient{failing: true}
+
+	// the circuit opens after 3 consecutive failures
+	for i := 0; i < 3; i++ {
+		if err := call(ctx, b, c); errors.Is(err, errCircuitOpen) {
+			t.Fatalf("call %d: circuit open before reaching the threshold", i)
+		}
+	}
+	if err := call(ctx, b, c); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("expected the circuit to be open, got %v", err)
+	}
+	if c.calls != 3 {
+		t.Fatalf("expected 3 calls to the client, got %d", c.calls)
+	}
+
+	// after the cool-down a failing probe opens the circuit again
+	now = now.Add(31 * time.Second)
+	if err := call(ctx, b, c); errors.Is(err, errCircuitOpen) {
+		t.Fatalf("expected a probe to be let through")
+	}
+	if err := call(ctx, b, c); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("expected the circuit to be open after a failed probe, got %v", err)
+	}
+	if c.calls != 4 {
+		t.Fatalf("expected 4 calls to the client, got %d", c.calls)
+	}
+
+	// a successful probe closes the circuit
+	now = now.Add(31 * time.Second)
+	c.failing = false
+	if err := call(ctx, b, c); err != nil {
+		t.Fatalf("expected the probe to succeed, got %v", err)
+	}
+	if state := b.get(testEndpoint).state; state != circuitClosed {
+		t.Fatalf("expected the circuit to be closed, got %s", state)
+	}
+	if err := call(ctx, b, c); err != nil {
+		t.Fatalf("expected the call to succeed, got %v", err)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	b := newCircuitBreaker(1, time.Second)
+	b.now = func() time.Time { return now }
+
+	b.done(ctx, testEndpoint, grpcstatus.Error(codes.DeadlineExceeded, "timeout"))
+	now = now.Add(2 * time.Second)
+
+	// only one probe is let through while half-open
+	if err := b.allow(ctx, testEndpoint); err != nil {
+		t.Fatalf("expected a probe to be let through, got %v", err)
+	}
+	if err := b.allow(ctx, testEndpoint); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("expected the other requests to fail fast, got %v", err)
+	}
+}
+
+func TestCircuitBreakerIgnoresOtherErrors(t *testing.T) {
+	ctx := context.Background()
+	b := newCircuitBreaker(1, time.Minute)
+
+	b.done(ctx, testEndpoint, grpcstatus.Error(codes.NotFound, "not found"))
+	b.done(ctx, "other:9142", grpcstatus.Error(codes.Unavailable, "connection refused"))
+
+	if err := b.allow(ctx, testEndpoint); err != nil {
+		t.Fatalf("expected the circuit to stay closed, got %v", err)
+	}
+	if err := b.allow(ctx, "other:9142"); !errors.Is(err, errCircuitOpen) {
+		t.Fatalf("expected the circuit of the other endpoint to be open, got %v", err)
+	}
+}
@@ -19,6 +19,7 @@
 package gateway
 
 import (
+	"context"
 	"fmt"
 	"net/url"
 	"strings"
@@ -26,8 +27,10 @@ import (
 
 	"github.com/ReneKroon/ttlcache/v2"
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	"github.com/cs3org/reva/pkg/errtypes"
 	"github.com/cs3org/reva/pkg/rgrpc"
+	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/sharedconf"
 	"github.com/cs3org/reva/pkg/token"
 	"github.com/cs3org/reva/pkg/token/manager/registry"
@@ -75,6 +78,37 @@ type config struct {
 	EtagCacheTTL        int                               `mapstructure:"etag_cache_ttl"`
 	AllowedUserAgents   map[string][]string               `mapstructure:"allowed_user_agents"` // map[path][]user-agent
 	CreateHomeCacheTTL  int                               `mapstructure:"create_home_cache_ttl"`
+	// OCMCircuitBreakerThreshold is the number of consecutive failures after which
+	// the OCM services are not called anymore for a cool-down period.
+	OCMCircuitBreakerThreshold int `mapstructure:"ocm_circuit_breaker_threshold"`
+	// OCMCircuitBreakerCooldown is the cool-down period in seconds.
+	OCMCircuitBreakerCooldown int `mapstructure:"ocm_circuit_breaker_cooldown"`
+	// OCMCoreRetries is the number of times a share creation is retried while
+	// the OCM core service is unavailable. Defaults to 3, 0 disables the retries.
+	OCMCoreRetries *int `mapstructure:"ocm_core_retries"`
+	// OCMCoreRetryBackoff is the wait in milliseconds before the first retry,
+	// doubled at every following one.
+	OCMCoreRetryBackoff int `mapstructure:"ocm_core_retry_backoff"`
+	// OCMCoreIdempotencyTTL is the time in seconds during which the retries of
+	// a share creation with the same idempotency key get the original response.
+	OCMCoreIdempotencyTTL int `mapstructure:"ocm_core_idempotency_ttl"`
+	// OCMIncomingMaxPermissions are the maximum OCM permissions of the received shares,
+	// unless set for the sending provider in its info. It defaults to read and write,
+	// and cannot include share, as the received shares cannot be shared again.
+	OCMIncomingMaxPermissions []string `mapstructure:"ocm_incoming_max_permissions"`
+	// OCMRoutes send the OCM calls for the users of some domains to other
+	// invite manager and OCM core services than the default ones.
+	OCMRoutes []*ocmRoute `mapstructure:"ocm_routes"`
+	// AuthCacheTTL is the time in seconds during which the successful authentications
+	// by the auth providers are cached. If 0, they are not cached.
+	// The users blocked in the gateway configuration are dropped from the cache at once,
+	// but the users and addresses blocked by the auth providers, e.g. when they reload
+	// their deny-lists, can still authenticate from the cache until their entries expire.
+	AuthCacheTTL int `mapstructure:"auth_cache_ttl"`
+	// AuthCacheSize is the maximum number of cached authentications.
+	AuthCacheSize int `mapstructure:"auth_cache_size"`
+	// AuthCacheBasic enables caching the password-based authentications too.
+	AuthCacheBasic bool `mapstructure:"auth_cache_basic"`
 }
 
 // sets defaults.
@@ -119,6 +153,35 @@ func (c *config) init() {
 	if c.TransferExpires == 0 {
 		c.TransferExpires = 100 * 60 // seconds
 	}
+
+	if c.OCMCircuitBreakerThreshold == 0 {
+		c.OCMCircuitBreakerThreshold = 5
+	}
+
+	if c.OCMCircuitBreakerCooldown == 0 {
+		c.OCMCircuitBreakerCooldown = 30 // seconds
+	}
+
+	if c.OCMCoreRetries == nil {
+		retries := 3
+		c.OCMCoreRetries = &retries
+	}
+
+	if c.OCMCoreRetryBackoff == 0 {
+		c.OCMCoreRetryBackoff = 200 // milliseconds
+	}
+
+	if c.OCMCoreIdempotencyTTL == 0 {
+		c.OCMCoreIdempotencyTTL = 300 // seconds
+	}
+
+	if len(c.OCMIncomingMaxPermissions) == 0 {
+		c.OCMIncomingMaxPermissions = []string{ocmPermissionRead, ocmPermissionWrite}
+	}
+
+	if c.AuthCacheSize == 0 {
+		c.AuthCacheSize = 10000
+	}
 }
 
 type svc struct {
@@ -128,6 +191,16 @@ type svc struct {
 	tokenmgr        token.Manager
 	etagCache       *ttlcache.Cache `mapstructure:"etag_cache"`
 	createHomeCache *ttlcache.Cache `mapstructure:"create_home_cache"`
+	// ocmCircuitBreaker guards the calls to the OCM services
+	ocmCircuitBreaker *circuitBreaker
+	// ocmCoreSharesCache keeps the responses to the OCM core share creations by idempotency key
+	ocmCoreSharesCache *ttlcache.Cache
+	// ocmIncomingMaxPermissions are the default maximum permissions of the received OCM shares
+	ocmIncomingMaxPermissions ocmPermissions
+	// ocmRouter selects the endpoints of the OCM services by the domain of the users
+	ocmRouter *ocmRouter
+	// authCache keeps the successful authentications by the auth providers, nil if disabled
+	authCache *authCache
 }
 
 // New creates a new gateway svc that acts as a proxy for any grpc operation.
@@ -152,6 +225,16 @@ func New(m map[string]interface{}, ss *grpc.Server) (rgrpc.Service, error) {
 		return nil, err
 	}
 
+	ocmIncomingMaxPermissions, err := parseOCMPermissions(c.OCMIncomingMaxPermissions)
+	if err != nil {
+		return nil, errors.Wrap(err, "gateway: invalid ocm_incoming_max_permissions")
+	}
+
+	ocmRouter, err := newOCMRouter(c.OCMRoutes, c.OCMInviteManagerEndpoint, c.OCMCoreEndpoint)
+	if err != nil {
+		return nil, err
+	}
+
 	etagCache := ttlcache.NewCache()
 	_ = etagCache.SetTTL(time.Duration(c.EtagCacheTTL) * time.Second)
 	etagCache.SkipTTLExtensionOnHit(true)
@@ -160,12 +243,22 @@ func New(m map[string]interface{}, ss *grpc.Server) (rgrpc.Service, error) {
 	_ = createHomeCache.SetTTL(time.Duration(c.CreateHomeCacheTTL) * time.Second)
 	createHomeCache.SkipTTLExtensionOnHit(true)
 
+	ocmCoreSharesCache := ttlcache.NewCache()
+	_ = ocmCoreSharesCache.SetTTL(time.Duration(c.OCMCoreIdempotencyTTL) * time.Second)
+	ocmCoreSharesCache.SkipTTLExtensionOnHit(true)
+
 	s := &svc{
-		c:               c,
-		dataGatewayURL:  *u,
-		tokenmgr:        tokenManager,
-		etagCache:       etagCache,
-		createHomeCache: createHomeCache,
+		c:                  c,
+		dataGatewayURL:     *u,
+		tokenmgr:           tokenManager,
+		etagCache:          etagCache,
+		createHomeCache:    createHomeCache,
+		ocmCircuitBreaker:  newCircuitBreaker(c.OCMCircuitBreakerThreshold, time.Duration(c.OCMCircuitBreakerCooldown)*time.Second),
+		ocmCoreSharesCache: ocmCoreSharesCache,
+		authCache:          newAuthCache(time.Duration(c.AuthCacheTTL)*time.Second, c.AuthCacheSize, c.AuthCacheBasic, sharedconf.GetBlockedUsers()),
+
+		ocmIncomingMaxPermissions: ocmIncomingMaxPermissions,
+		ocmRouter:                 ocmRouter,
 	}
 
 	return s, nil
@@ -177,6 +270,8 @@ func (s *svc) Register(ss *grpc.Server) {
 
 func (s *svc) Close() error {
 	s.etagCache.Close()
+	s.ocmCoreSharesCache.Close()
+	s.authCache.close()
 	return nil
 }
 
@@ -200,3 +295,13 @@ func getTokenManager(manager string, m map[string]map[string]interface{}) (token
 
 	return nil, errtypes.NotFound(fmt.Sprintf("driver %s not found for token manager", manager))
 }
+
+// statusFromOCMError translates the error of a call to an OCM service into
+// the status of the response, so that the callers get a CS3 status instead
+// of a transport error.
+func statusFromOCMError(ctx context.Context, err error, msg string) *rpc.Status {
+	if isUnreachable(err) || errors.Is(err, errCircuitOpen) {
+		return status.NewUnavailable(ctx, err, msg+": "+err.Error())
+	}
+	return status.NewStatusFromErrType(ctx, msg, err)
+}
@@ -20,29 +20,133 @@ package gateway
 
 import (
 	"context"
+	"time"
 
 	ocmcore "github.com/cs3org/go-cs3apis/cs3/ocm/core/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
 	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/pkg/tracing"
 	"github.com/pkg/errors"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
 )
 
-func (s *svc) CreateOCMCoreShare(ctx context.Context, req *ocmcore.CreateOCMCoreShareRequest) (*ocmcore.CreateOCMCoreShareResponse, error) {
+// IdempotencyKeyOpaqueKey is the key in the opaque map of a CreateOCMCoreShareRequest
+// holding the idempotency key of the request, encoded as plain text.
+// Requests from the same sender with the same idempotency key received within
+// the configured window are only forwarded once: the retries get the response
+// of the first successful request, without creating a new share.
+const IdempotencyKeyOpaqueKey = "idempotency-key"
+
+// uncachedResponse carries a response that must not be kept for the retries.
+type uncachedResponse struct {
+	res *ocmcore.CreateOCMCoreShareResponse
+}
+
+func (e *uncachedResponse) Error() string {
+	return "gateway: unsuccessful response from CreateOCMCoreShare"
+}
+
+func (s *svc) CreateOCMCoreShare(ctx context.Context, req *ocmcore.CreateOCMCoreShareRequest) (res *ocmcore.CreateOCMCoreShareResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "CreateOCMCoreShare")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
 
-	c, err := pool.GetOCMCoreClient(ctx, pool.Endpoint(s.c.OCMCoreEndpoint))
+	max := s.ocmMaxPermissions(ctx, req.Sender.GetIdp(), s.getProviderInfo)
+	if err := sanitizeOCMPermissions(ctx, req, max); err != nil {
+		return &ocmcore.CreateOCMCoreShareResponse{
+			Status: status.NewInvalid(ctx, err.Error()),
+		}, nil
+	}
+
+	// the share is created by the OCM core service of the recipient
+	endpoint := s.ocmRouter.coreEndpoint(req.ShareWith.GetIdp())
+	span.SetAttributes(attrEndpoint.String(endpoint), attrResourceID.String(req.GetResourceId()))
+	c, err := pool.GetOCMCoreClient(ctx, pool.Endpoint(endpoint))
 	if err != nil {
 		return &ocmcore.CreateOCMCoreShareResponse{
 			Status: status.NewInternal(ctx, err, "error getting ocm core client"),
 		}, nil
 	}
 
-	res, err := c.CreateOCMCoreShare(ctx, req)
+	return s.createOCMCoreShare(ctx, endpoint, c, req)
+}
+
+// createOCMCoreShare forwards the request, unless a request with the same
+// idempotency key was already successfully forwarded.
+func (s *svc) createOCMCoreShare(ctx context.Context, endpoint string, c ocmcore.OcmCoreAPIClient, req *ocmcore.CreateOCMCoreShareRequest) (*ocmcore.CreateOCMCoreShareResponse, error) {
+	key := idempotencyKey(req)
+	if key == "" {
+		return s.forwardCreateOCMCoreShare(ctx, endpoint, c, req)
+	}
+
+	// concurrent requests with the same key wait for the first one to complete
+	res, err := s.ocmCoreSharesCache.GetByLoader(key, func(string) (interface{}, time.Duration, error) {
+		res, err := s.forwardCreateOCMCoreShare(ctx, endpoint, c, req)
+		if err != nil {
+			return nil, 0, err
+		}
+		if res.Status.Code != rpc.Code_CODE_OK {
+			return nil, 0, &uncachedResponse{res: res}
+		}
+		return res, time.Duration(s.c.OCMCoreIdempotencyTTL) * time.Second, nil
+	})
+
+	var uncached *uncachedResponse
+	if errors.As(err, &uncached) {
+		return uncached.res, nil
+	}
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling CreateOCMCoreShare")
+		return &ocmcore.CreateOCMCoreShareResponse{
+			Status: status.NewInternal(ctx, err, "error creating ocm core share"),
+		}, nil
+	}
+	return res.(*ocmcore.CreateOCMCoreShareResponse), nil
+}
+
+// forwardCreateOCMCoreShare calls the OCM core service, retrying with an
+// exponential backoff as long as the service is unavailable.
+func (s *svc) forwardCreateOCMCoreShare(ctx context.Context, endpoint string, c ocmcore.OcmCoreAPIClient, req *ocmcore.CreateOCMCoreShareRequest) (*ocmcore.CreateOCMCoreShareResponse, error) {
+	log := appctx.GetLogger(ctx)
+	backoff := time.Duration(s.c.OCMCoreRetryBackoff) * time.Millisecond
+
+	for attempt := 0; ; attempt++ {
+		var res *ocmcore.CreateOCMCoreShareResponse
+		err := s.callOCM(ctx, endpoint, func() (err error) {
+			res, err = c.CreateOCMCoreShare(ctx, req)
+			return err
+		})
+		if err == nil {
+			return res, nil
+		}
+		if grpcstatus.Code(err) != codes.Unavailable || attempt >= *s.c.OCMCoreRetries {
+			return &ocmcore.CreateOCMCoreShareResponse{
+				Status: statusFromOCMError(ctx, err, "error calling CreateOCMCoreShare"),
+			}, nil
+		}
+
+		wait := backoff << attempt
+		log.Warn().Err(err).Int("attempt", attempt+1).Dur("backoff", wait).Msg("gateway: ocm core unavailable, retrying CreateOCMCoreShare")
+		select {
+		case <-ctx.Done():
+			return &ocmcore.CreateOCMCoreShareResponse{
+				Status: statusFromOCMError(ctx, err, "error calling CreateOCMCoreShare"),
+			}, nil
+		case <-time.After(wait):
+		}
 	}
+}
 
-	return res, nil
+// idempotencyKey returns the idempotency key of the request, scoped to its sender,
+// or an empty string if the request does not have one.
+func idempotencyKey(req *ocmcore.CreateOCMCoreShareRequest) string {
+	if req.Opaque == nil || req.Opaque.Map == nil {
+		return ""
+	}
+	e, ok := req.Opaque.Map[IdempotencyKeyOpaqueKey]
+	if !ok || e.Decoder != "plain" || len(e.Value) == 0 {
+		return ""
+	}
+	return req.Sender.GetIdp() + "!" + req.Sender.GetOpaqueId() + "!" + string(e.Value)
 }
@@ -0,0 +1,226 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ReneKroon/ttlcache/v2"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	ocmcore "github.com/cs3org/go-cs3apis/cs3/ocm/core/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// fakeOCMCoreClient is an OCM core client failing as if the service
+// was down for the first unavailable calls.
+type fakeOCMCoreClient struct {
+	ocmcore.OcmCoreAPIClient
+	unavailable int
+	code        rpc.Code
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (c *fakeOCMCoreClient) CreateOCMCoreShare(ctx context.Context, req *ocmcore.CreateOCMCoreShareRequest, opts ...grpc.CallOption) (*ocmcore.CreateOCMCoreShareResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls++
+	if c.calls <= c.unavailable {
+		return nil, grpcstatus.Error(codes.Unavailable, "connection refused")
+	}
+	code := c.code
+	if code == rpc.Code_CODE_INVALID {
+		code = rpc.Code_CODE_OK
+	}
+	return &ocmcore.CreateOCMCoreShareResponse{
+		Status: &rpc.Status{Code: code},
+		Id:     req.ResourceId,
+	}, nil
+}
+
+func newTestOCMCoreService(retries int) *svc {
+	cache := ttlcache.NewCache()
+	_ = cache.SetTTL(time.Minute)
+	return &svc{
+		c: &config{
+			OCMCoreEndpoint:     testEndpoint,
+			OCMCoreRetries:      &retries,
+			OCMCoreRetryBackoff: 1,
+		},
+		ocmCircuitBreaker:  newCircuitBreaker(10, time.Minute),
+		ocmCoreSharesCache: cache,
+	}
+}
+
+func newOCMCoreShareRequest(sender, key, resourceID string) *ocmcore.CreateOCMCoreShareRequest {
+	req := &ocmcore.CreateOCMCoreShareRequest{
+		Sender:     &userpb.UserId{Idp: "cernbox.cern.ch", OpaqueId: sender},
+		ResourceId: resourceID,
+	}
+	if key != "" {
+		req.Opaque = &types.Opaque{Map: map[string]*types.OpaqueEntry{
+			IdempotencyKeyOpaqueKey: {Decoder: "plain", Value: []byte(key)},
+		}}
+	}
+	return req
+}
+
+func TestCreateOCMCoreShareRetries(t *testing.T) {
+	ctx := context.Background()
+
+	s := newTestOCMCoreService(3)
+	c := &fakeOCMCoreClient{unavailable: 2}
+	res, err := s.createOCMCoreShare(ctx, testEndpoint, c, newOCMCoreShareRequest("einstein", "", "1"))
+	if err != nil {
+		t.Fatalf("not expected error creating the share: %+v", err)
+	}
+	if res.Status.Code != rpc.Code_CODE_OK || c.calls != 3 {
+		t.Fatalf("expected the share to be created at the third call, got %v after %d calls", res.Status.Code, c.calls)
+	}
+
+	c = &fakeOCMCoreClient{unavailable: 5}
+	res, err = s.createOCMCoreShare(ctx, testEndpoint, c, newOCMCoreShareRequest("einstein", "", "1"))
+	if err != nil || res.Status.Code != rpc.Code_CODE_UNAVAILABLE {
+		t.Fatalf("expected unavailable status, got %+v, error %+v", res, err)
+	}
+	if c.calls != 4 {
+		t.Fatalf("expected 4 calls to the client, got %d", c.calls)
+	}
+
+	s = newTestOCMCoreService(0)
+	c = &fakeOCMCoreClient{unavailable: 1}
+	res, err = s.createOCMCoreShare(ctx, testEndpoint, c, newOCMCoreShareRequest("einstein", "", "1"))
+	if err != nil || res.Status.Code != rpc.Code_CODE_UNAVAILABLE {
+		t.Fatalf("expected unavailable status with the retries disabled, got %+v, error %+v", res, err)
+	}
+	if c.calls != 1 {
+		t.Fatalf("expected 1 call to the client, got %d", c.calls)
+	}
+}
+
+func TestOCMCoreRetriesConfig(t *testing.T) {
+	for _, tt := range []struct {
+		conf     map[string]interface{}
+		expected int
+	}{
+		{map[string]interface{}{}, 3},
+		{map[string]interface{}{"ocm_core_retries": 5}, 5},
+		{map[string]interface{}{"ocm_core_retries": 0}, 0},
+	} {
+		c, err := parseConfig(tt.conf)
+		if err != nil {
+			t.Fatalf("error parsing the config %v: %v", tt.conf, err)
+		}
+		c.init()
+		if *c.OCMCoreRetries != tt.expected {
+			t.Errorf("expected %d retries with the config %v, got %d", tt.expected, tt.conf, *c.OCMCoreRetries)
+		}
+	}
+}
+
+func TestCreateOCMCoreShareIdempotency(t *testing.T) {
+	ctx := context.Background()
+	s := newTestOCMCoreService(3)
+	c := &fakeOCMCoreClient{}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res, err := s.createOCMCoreShare(ctx, testEndpoint, c, newOCMCoreShareRequest("einstein", "key", "1"))
+			if err != nil || res.Id != "1" {
+				t.Errorf("unexpected response %+v, error %+v", res, err)
+			}
+		}()
+	}
+	wg.Wait()
+	if c.calls != 1 {
+		t.Fatalf("expected retries with the same key to be forwarded once, got %d calls", c.calls)
+	}
+
+	// the original response is returned to the retries
+	res, err := s.createOCMCoreShare(ctx, testEndpoint, c, newOCMCoreShareRequest("einstein", "key", "2"))
+	if err != nil || res.Id != "1" || c.calls != 1 {
+		t.Fatalf("expected the original response, got %+v, error %+v after %d calls", res, err, c.calls)
+	}
+
+	// keys are scoped to the sender
+	if _, err := s.createOCMCoreShare(ctx, testEndpoint, c, newOCMCoreShareRequest("marie", "key", "3")); err != nil || c.calls != 2 {
+		t.Fatalf("expected a new call for another sender, got error %+v after %d calls", err, c.calls)
+	}
+
+	// requests without a key are always forwarded
+	for i := 0; i < 2; i++ {
+		if _, err := s.createOCMCoreShare(ctx, testEndpoint, c, newOCMCoreShareRequest("einstein", "", "1")); err != nil {
+			t.Fatalf("not expected error creating the share: %+v", err)
+		}
+	}
+	if c.calls != 4 {
+		t.Fatalf("expected 4 calls to the client, got %d", c.calls)
+	}
+}
+
+func TestCreateOCMCoreShareIdempotencyFailure(t *testing.T) {
+	ctx := context.Background()
+	s := newTestOCMCoreService(3)
+
+	// unsuccessful responses are not kept
+	c := &fakeOCMCoreClient{code: rpc.Code_CODE_INTERNAL}
+	res, err := s.createOCMCoreShare(ctx, testEndpoint, c, newOCMCoreShareRequest("einstein", "key", "1"))
+	if err != nil || res.Status.Code != rpc.Code_CODE_INTERNAL {
+		t.Fatalf("expected internal error status, got %+v, error %+v", res, err)
+	}
+
+	c.code = rpc.Code_CODE_OK
+	res, err = s.createOCMCoreShare(ctx, testEndpoint, c, newOCMCoreShareRequest("einstein", "key", "1"))
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK || c.calls != 2 {
+		t.Fatalf("expected the retry to be forwarded, got %+v, error %+v after %d calls", res, err, c.calls)
+	}
+}
+
+func TestStatusFromOCMError(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		err      error
+		expected rpc.Code
+	}{
+		{err: grpcstatus.Error(codes.Unavailable, "connection refused"), expected: rpc.Code_CODE_UNAVAILABLE},
+		{err: grpcstatus.Error(codes.DeadlineExceeded, "timeout"), expected: rpc.Code_CODE_UNAVAILABLE},
+		{err: grpcstatus.Error(codes.PermissionDenied, "denied"), expected: rpc.Code_CODE_PERMISSION_DENIED},
+		{err: errtypes.NotFound("token"), expected: rpc.Code_CODE_NOT_FOUND},
+		{err: errors.New("unexpected"), expected: rpc.Code_CODE_INTERNAL},
+	}
+
+	for _, tt := range tests {
+		if s := statusFromOCMError(ctx, tt.err, "error calling GenerateInviteToken"); s.Code != tt.expected {
+			t.Fatalf("unexpected code for %v: got %v, expected %v", tt.err, s.Code, tt.expected)
+		}
+	}
+}
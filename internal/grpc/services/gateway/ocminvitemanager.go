@@ -21,120 +21,416 @@ package gateway
 import (
 	"context"
 
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	invitepb "github.com/cs3org/go-cs3apis/cs3/ocm/invite/v1beta1"
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/ocm/invite"
 	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/pkg/tracing"
-	"github.com/pkg/errors"
+	"github.com/cs3org/reva/pkg/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
 )
 
-func (s *svc) GenerateInviteToken(ctx context.Context, req *invitepb.GenerateInviteTokenRequest) (*invitepb.GenerateInviteTokenResponse, error) {
+func (s *svc) GenerateInviteToken(ctx context.Context, req *invitepb.GenerateInviteTokenRequest) (res *invitepb.GenerateInviteTokenResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "GenerateInviteToken")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
 
-	c, err := pool.GetOCMInviteManagerClient(ctx, pool.Endpoint(s.c.OCMInviteManagerEndpoint))
+	endpoint := s.ocmRouter.inviteManagerEndpoint(userDomain(ctx))
+	span.SetAttributes(attrEndpoint.String(endpoint))
+	c, err := pool.GetOCMInviteManagerClient(ctx, pool.Endpoint(endpoint))
 	if err != nil {
 		return &invitepb.GenerateInviteTokenResponse{
 			Status: status.NewInternal(ctx, err, "error getting user invite provider client"),
 		}, nil
 	}
 
-	res, err := c.GenerateInviteToken(ctx, req)
+	return s.generateInviteToken(ctx, endpoint, c, req), nil
+}
+
+// generateInviteToken creates the token on the invite manager of the user,
+// where the invite is then accepted and the accepted users are looked up.
+func (s *svc) generateInviteToken(ctx context.Context, endpoint string, c invitepb.InviteAPIClient, req *invitepb.GenerateInviteTokenRequest) *invitepb.GenerateInviteTokenResponse {
+	var res *invitepb.GenerateInviteTokenResponse
+	err := s.callOCM(ctx, endpoint, func() (err error) {
+		res, err = c.GenerateInviteToken(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling GenerateInviteToken")
+		return &invitepb.GenerateInviteTokenResponse{
+			Status: statusFromOCMError(ctx, err, "error calling GenerateInviteToken"),
+		}
 	}
 
-	return res, nil
+	return res
 }
 
-func (s *svc) ListInviteTokens(ctx context.Context, req *invitepb.ListInviteTokensRequest) (*invitepb.ListInviteTokensResponse, error) {
-	c, err := pool.GetOCMInviteManagerClient(ctx, pool.Endpoint(s.c.OCMInviteManagerEndpoint))
+func (s *svc) ListInviteTokens(ctx context.Context, req *invitepb.ListInviteTokensRequest) (res *invitepb.ListInviteTokensResponse, err error) {
+	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "ListInviteTokens")
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoints.StringSlice(s.ocmRouter.inviteManagerEndpoints()))
+
+	clients, err := s.getOCMInviteManagerClients(ctx)
 	if err != nil {
 		return &invitepb.ListInviteTokensResponse{
 			Status: status.NewInternal(ctx, err, "error getting user invite provider client"),
 		}, nil
 	}
 
-	res, err := c.ListInviteTokens(ctx, req)
+	return s.listInviteTokens(ctx, clients, req)
+}
+
+// getOCMInviteManagerClients returns the clients of all the invite managers, by endpoint.
+func (s *svc) getOCMInviteManagerClients(ctx context.Context) (map[string]invitepb.InviteAPIClient, error) {
+	clients := map[string]invitepb.InviteAPIClient{}
+	for _, endpoint := range s.ocmRouter.inviteManagerEndpoints() {
+		c, err := pool.GetOCMInviteManagerClient(ctx, pool.Endpoint(endpoint))
+		if err != nil {
+			return nil, err
+		}
+		clients[endpoint] = c
+	}
+	return clients, nil
+}
+
+// listInviteTokens forwards the request to all the invite managers, with the
+// pagination and the filter set in its metadata, merges their pages and sends
+// back the continuation token of the next one. As the tokens are sorted and
+// the cursor is the last token of the previous page, every invite manager
+// returns the tokens following it, of which the merged page keeps the first ones.
+func (s *svc) listInviteTokens(ctx context.Context, clients map[string]invitepb.InviteAPIClient, req *invitepb.ListInviteTokensRequest) (*invitepb.ListInviteTokensResponse, error) {
+	o, err := invite.ContextGetListTokensOptions(ctx)
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling ListInviteTokens")
+		return &invitepb.ListInviteTokensResponse{
+			Status: status.NewInvalid(ctx, err.Error()),
+		}, nil
 	}
 
-	return res, nil
+	var tokens []*invitepb.InviteToken
+	more := false
+	for endpoint, c := range clients {
+		res, next := s.forwardListInviteTokens(ctx, endpoint, c, req)
+		if res.Status.GetCode() != rpc.Code_CODE_OK {
+			return res, nil
+		}
+		tokens = append(tokens, res.InviteTokens...)
+		more = more || next != ""
+	}
+
+	tokens, next := invite.PaginateTokens(tokens, &invite.ListTokensOptions{PageSize: o.PageSize})
+	if next == "" && more && len(tokens) != 0 {
+		next = tokens[len(tokens)-1].Token
+	}
+
+	if next != "" {
+		if err := grpc.SetHeader(ctx, invite.EncodeNextCursor(next)); err != nil {
+			return &invitepb.ListInviteTokensResponse{
+				Status: status.NewInternal(ctx, err, "error sending the continuation token"),
+			}, nil
+		}
+	}
+
+	if invite.ContextGetIncludeAcceptedUsers(ctx) {
+		s.sendAcceptedUsers(ctx)
+	}
+
+	return &invitepb.ListInviteTokensResponse{
+		Status:       status.NewOK(ctx),
+		InviteTokens: tokens,
+	}, nil
+}
+
+// forwardListInviteTokens lists the tokens of an invite manager,
+// returning the continuation token of its next page.
+func (s *svc) forwardListInviteTokens(ctx context.Context, endpoint string, c invitepb.InviteAPIClient, req *invitepb.ListInviteTokensRequest) (*invitepb.ListInviteTokensResponse, string) {
+	var header metadata.MD
+	var res *invitepb.ListInviteTokensResponse
+	err := s.callOCM(ctx, endpoint, func() (err error) {
+		res, err = c.ListInviteTokens(invite.ForwardListTokensOptions(ctx), req, grpc.Header(&header))
+		return err
+	})
+	if err != nil {
+		return &invitepb.ListInviteTokensResponse{
+			Status: statusFromOCMError(ctx, err, "error calling ListInviteTokens"),
+		}, ""
+	}
+
+	return res, invite.DecodeNextCursor(header)
+}
+
+// sendAcceptedUsers sends in the response header the users who accepted the
+// invites, with the info of their providers. The failures are reported in the
+// listing, so that the tokens are returned anyway.
+func (s *svc) sendAcceptedUsers(ctx context.Context) {
+	listing := &invite.Listing{}
+	usersRes, err := s.FindAcceptedUsers(ctx, &invitepb.FindAcceptedUsersRequest{})
+	switch {
+	case err != nil:
+		listing.Error = err.Error()
+	case usersRes.Status.GetCode() != rpc.Code_CODE_OK:
+		listing.Error = usersRes.Status.GetMessage()
+	default:
+		listing.AcceptedUsers = resolveProviders(ctx, usersRes.AcceptedUsers, s.getProviderInfo)
+	}
+
+	md, err := invite.EncodeListing(listing)
+	if err != nil {
+		appctx.GetLogger(ctx).Error().Err(err).Msg("error encoding the accepted users")
+		return
+	}
+	if err := grpc.SetHeader(ctx, md); err != nil {
+		appctx.GetLogger(ctx).Error().Err(err).Msg("error sending the accepted users")
+	}
+}
+
+// resolveProviders gets the info of the provider of each user,
+// looking up every domain only once.
+func resolveProviders(ctx context.Context, users []*userpb.User, getInfo func(context.Context, string) (*ocmprovider.ProviderInfo, error)) []*invite.AcceptedUser {
+	type result struct {
+		info *ocmprovider.ProviderInfo
+		err  error
+	}
+	cache := map[string]result{}
+
+	accepted := make([]*invite.AcceptedUser, 0, len(users))
+	for _, u := range users {
+		domain := u.GetId().GetIdp()
+		r, ok := cache[domain]
+		if !ok {
+			r.info, r.err = getInfo(ctx, domain)
+			cache[domain] = r
+		}
+		a := &invite.AcceptedUser{User: u, Provider: r.info}
+		if r.err != nil {
+			a.Error = r.err.Error()
+		}
+		accepted = append(accepted, a)
+	}
+	return accepted
 }
 
-func (s *svc) ForwardInvite(ctx context.Context, req *invitepb.ForwardInviteRequest) (*invitepb.ForwardInviteResponse, error) {
+func (s *svc) getProviderInfo(ctx context.Context, domain string) (*ocmprovider.ProviderInfo, error) {
+	res, err := s.GetInfoByDomain(ctx, &ocmprovider.GetInfoByDomainRequest{Domain: domain})
+	if err != nil {
+		return nil, err
+	}
+	if res.Status.GetCode() != rpc.Code_CODE_OK {
+		return nil, status.NewErrorFromCode(res.Status.GetCode(), "gateway")
+	}
+	return res.ProviderInfo, nil
+}
+
+func (s *svc) ForwardInvite(ctx context.Context, req *invitepb.ForwardInviteRequest) (res *invitepb.ForwardInviteResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "ForwardInvite")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
 
-	c, err := pool.GetOCMInviteManagerClient(ctx, pool.Endpoint(s.c.OCMInviteManagerEndpoint))
+	endpoint := s.ocmRouter.inviteManagerEndpoint(userDomain(ctx))
+	span.SetAttributes(attrEndpoint.String(endpoint), tracing.Hashed(attrInviteTokenHash, req.GetInviteToken().GetToken()))
+	c, err := pool.GetOCMInviteManagerClient(ctx, pool.Endpoint(endpoint))
 	if err != nil {
 		return &invitepb.ForwardInviteResponse{
 			Status: status.NewInternal(ctx, err, "error getting user invite provider client"),
 		}, nil
 	}
 
-	res, err := c.ForwardInvite(ctx, req)
+	err = s.callOCM(ctx, endpoint, func() (err error) {
+		res, err = c.ForwardInvite(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling ForwardInvite")
+		return &invitepb.ForwardInviteResponse{
+			Status: statusFromOCMError(ctx, err, "error calling ForwardInvite"),
+		}, nil
 	}
 
 	return res, nil
 }
 
-func (s *svc) AcceptInvite(ctx context.Context, req *invitepb.AcceptInviteRequest) (*invitepb.AcceptInviteResponse, error) {
+func (s *svc) AcceptInvite(ctx context.Context, req *invitepb.AcceptInviteRequest) (res *invitepb.AcceptInviteResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "AcceptInvite")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoints.StringSlice(s.ocmRouter.inviteManagerEndpoints()), tracing.Hashed(attrInviteTokenHash, req.GetInviteToken().GetToken()))
 
-	c, err := pool.GetOCMInviteManagerClient(ctx, pool.Endpoint(s.c.OCMInviteManagerEndpoint))
+	clients, err := s.getOCMInviteManagerClients(ctx)
 	if err != nil {
 		return &invitepb.AcceptInviteResponse{
 			Status: status.NewInternal(ctx, err, "error getting user invite provider client"),
 		}, nil
 	}
 
-	res, err := c.AcceptInvite(ctx, req)
-	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling AcceptInvite")
+	return s.acceptInvite(ctx, clients, req), nil
+}
+
+// acceptInvite accepts the invite on the invite manager holding the token.
+// The token is looked up on all of them, as the user accepting the invite
+// is not the one who generated the token, whose domain routes the token.
+func (s *svc) acceptInvite(ctx context.Context, clients map[string]invitepb.InviteAPIClient, req *invitepb.AcceptInviteRequest) *invitepb.AcceptInviteResponse {
+	var res *invitepb.AcceptInviteResponse
+	for _, endpoint := range s.ocmRouter.inviteManagerEndpointsFrom(userDomain(ctx)) {
+		c := clients[endpoint]
+		err := s.callOCM(ctx, endpoint, func() (err error) {
+			res, err = c.AcceptInvite(ctx, req)
+			return err
+		})
+		if err != nil {
+			return &invitepb.AcceptInviteResponse{
+				Status: statusFromOCMError(ctx, err, "error calling AcceptInvite"),
+			}
+		}
+		if res.Status.GetCode() != rpc.Code_CODE_NOT_FOUND {
+			return res
+		}
 	}
 
-	return res, nil
+	return res
 }
 
-func (s *svc) GetAcceptedUser(ctx context.Context, req *invitepb.GetAcceptedUserRequest) (*invitepb.GetAcceptedUserResponse, error) {
+func (s *svc) GetAcceptedUser(ctx context.Context, req *invitepb.GetAcceptedUserRequest) (res *invitepb.GetAcceptedUserResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "GetAcceptedUser")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoints.StringSlice(s.ocmRouter.inviteManagerEndpoints()))
 
-	c, err := pool.GetOCMInviteManagerClient(ctx, pool.Endpoint(s.c.OCMInviteManagerEndpoint))
+	clients, err := s.getOCMInviteManagerClients(ctx)
 	if err != nil {
 		return &invitepb.GetAcceptedUserResponse{
 			Status: status.NewInternal(ctx, err, "error getting user invite provider client"),
 		}, nil
 	}
 
-	res, err := c.GetAcceptedUser(ctx, req)
-	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling GetAcceptedUser")
+	return s.getAcceptedUser(ctx, clients, req), nil
+}
+
+// getAcceptedUser looks up the accepted user on the invite manager of the user
+// who sent the invite first, and then on the other ones, in case the domain of
+// the user is not known, or the invite has been accepted before it was routed.
+func (s *svc) getAcceptedUser(ctx context.Context, clients map[string]invitepb.InviteAPIClient, req *invitepb.GetAcceptedUserRequest) *invitepb.GetAcceptedUserResponse {
+	var res *invitepb.GetAcceptedUserResponse
+	for _, endpoint := range s.ocmRouter.inviteManagerEndpointsFrom(inviterDomain(ctx, req)) {
+		c := clients[endpoint]
+		err := s.callOCM(ctx, endpoint, func() (err error) {
+			res, err = c.GetAcceptedUser(ctx, req)
+			return err
+		})
+		if err != nil {
+			return &invitepb.GetAcceptedUserResponse{
+				Status: statusFromOCMError(ctx, err, "error calling GetAcceptedUser"),
+			}
+		}
+		if res.Status.GetCode() != rpc.Code_CODE_NOT_FOUND {
+			return res
+		}
 	}
 
-	return res, nil
+	return res
 }
 
-func (s *svc) FindAcceptedUsers(ctx context.Context, req *invitepb.FindAcceptedUsersRequest) (*invitepb.FindAcceptedUsersResponse, error) {
+func (s *svc) FindAcceptedUsers(ctx context.Context, req *invitepb.FindAcceptedUsersRequest) (res *invitepb.FindAcceptedUsersResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "FindAcceptedUsers")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoints.StringSlice(s.ocmRouter.inviteManagerEndpoints()))
 
-	c, err := pool.GetOCMInviteManagerClient(ctx, pool.Endpoint(s.c.OCMInviteManagerEndpoint))
+	clients, err := s.getOCMInviteManagerClients(ctx)
 	if err != nil {
 		return &invitepb.FindAcceptedUsersResponse{
 			Status: status.NewInternal(ctx, err, "error getting user invite provider client"),
 		}, nil
 	}
 
-	res, err := c.FindAcceptedUsers(ctx, req)
+	return s.findAcceptedUsers(ctx, clients, req)
+}
+
+// findAcceptedUsers gets the accepted users from all the invite managers.
+// With more than one, the pagination is removed from the forwarded requests,
+// and applied to the merged users.
+func (s *svc) findAcceptedUsers(ctx context.Context, clients map[string]invitepb.InviteAPIClient, req *invitepb.FindAcceptedUsersRequest) (*invitepb.FindAcceptedUsersResponse, error) {
+	if len(clients) == 1 {
+		for endpoint, c := range clients {
+			return paginateAcceptedUsers(ctx, req, s.forwardFindAcceptedUsers(ctx, endpoint, c, req)), nil
+		}
+	}
+
+	fwd := &invitepb.FindAcceptedUsersRequest{Filter: req.Filter}
+	merged := &invitepb.FindAcceptedUsersResponse{Status: status.NewOK(ctx)}
+	seen := map[string]bool{}
+	for endpoint, c := range clients {
+		res := s.forwardFindAcceptedUsers(ctx, endpoint, c, fwd)
+		if res.Status.GetCode() != rpc.Code_CODE_OK {
+			return res, nil
+		}
+		for _, u := range res.AcceptedUsers {
+			id := u.GetId().GetIdp() + "!" + u.GetId().GetOpaqueId()
+			if !seen[id] {
+				seen[id] = true
+				merged.AcceptedUsers = append(merged.AcceptedUsers, u)
+			}
+		}
+	}
+
+	return paginateAcceptedUsers(ctx, req, merged), nil
+}
+
+func (s *svc) forwardFindAcceptedUsers(ctx context.Context, endpoint string, c invitepb.InviteAPIClient, req *invitepb.FindAcceptedUsersRequest) *invitepb.FindAcceptedUsersResponse {
+	var res *invitepb.FindAcceptedUsersResponse
+	err := s.callOCM(ctx, endpoint, func() (err error) {
+		res, err = c.FindAcceptedUsers(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling FindAcceptedUsers")
+		return &invitepb.FindAcceptedUsersResponse{
+			Status: statusFromOCMError(ctx, err, "error calling FindAcceptedUsers"),
+		}
 	}
 
-	return res, nil
+	return res
+}
+
+// userDomain returns the idp domain of the user of the request.
+func userDomain(ctx context.Context) string {
+	u, _ := ctxpkg.ContextGetUser(ctx)
+	return u.GetId().GetIdp()
+}
+
+// inviterDomain returns the idp domain of the user who sent the invite, either
+// the user of the request or, without one, the user in its user-filter opaque
+// entry, as sent by the services looking up the users on behalf of others.
+func inviterDomain(ctx context.Context, req *invitepb.GetAcceptedUserRequest) string {
+	if domain := userDomain(ctx); domain != "" {
+		return domain
+	}
+	if v, ok := req.GetOpaque().GetMap()["user-filter"]; ok {
+		var u userpb.UserId
+		if err := utils.UnmarshalJSONToProtoV1(v.Value, &u); err == nil {
+			return u.Idp
+		}
+	}
+	return ""
+}
+
+// paginateAcceptedUsers applies the filter and the pagination of the request
+// to the accepted users, in case the invite manager did not already do it.
+func paginateAcceptedUsers(ctx context.Context, req *invitepb.FindAcceptedUsersRequest, res *invitepb.FindAcceptedUsersResponse) *invitepb.FindAcceptedUsersResponse {
+	if res.Status.GetCode() != rpc.Code_CODE_OK || invite.IsPaginated(res) {
+		return res
+	}
+
+	page, err := invite.GetPage(req)
+	if err != nil {
+		return &invitepb.FindAcceptedUsersResponse{
+			Status: status.NewInvalid(ctx, err.Error()),
+		}
+	}
+
+	users := make([]*userpb.User, 0, len(res.AcceptedUsers))
+	for _, u := range res.AcceptedUsers {
+		if req.Filter == "" || invite.UserMatches(u, req.Filter) {
+			users = append(users, u)
+		}
+	}
+	res.AcceptedUsers = invite.Paginate(users, page)
+	invite.SetPaginated(res)
+	return res
 }
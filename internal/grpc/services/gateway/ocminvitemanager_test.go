@@ -0,0 +1,530 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	invitepb "github.com/cs3org/go-cs3apis/cs3/ocm/invite/v1beta1"
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/ocm/invite"
+	"github.com/cs3org/reva/pkg/rgrpc/status"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func acceptedUser(id, name string) *userpb.User {
+	return &userpb.User{
+		Id:          &userpb.UserId{Idp: "cernbox.cern.ch", OpaqueId: id},
+		Username:    id,
+		DisplayName: name,
+	}
+}
+
+func acceptedUsersIDs(users []*userpb.User) []string {
+	ids := make([]string, 0, len(users))
+	for _, u := range users {
+		ids = append(ids, u.Id.OpaqueId)
+	}
+	return ids
+}
+
+func TestPaginateAcceptedUsers(t *testing.T) {
+	ctx := context.Background()
+	users := func() []*userpb.User {
+		return []*userpb.User{
+			acceptedUser("marie", "Marie Curie"),
+			acceptedUser("einstein", "Albert Einstein"),
+			acceptedUser("richard", "Richard Feynman"),
+			acceptedUser("mcurie", "Marie Curie"),
+			acceptedUser("bohr", "Niels Bohr"),
+		}
+	}
+
+	tests := []struct {
+		description string
+		filter      string
+		page        *invite.Page
+		expected    []string
+	}{
+		{
+			description: "all users sorted by display name",
+			expected:    []string{"einstein", "marie", "mcurie", "bohr", "richard"},
+		},
+		{
+			description: "filter",
+			filter:      "CURIE",
+			expected:    []string{"marie", "mcurie"},
+		},
+		{
+			description: "first page",
+			page:        &invite.Page{Number: 1, Size: 2},
+			expected:    []string{"einstein", "marie"},
+		},
+		{
+			description: "last page",
+			page:        &invite.Page{Number: 3, Size: 2},
+			expected:    []string{"richard"},
+		},
+		{
+			description: "page out of range",
+			page:        &invite.Page{Number: 4, Size: 2},
+			expected:    []string{},
+		},
+		{
+			description: "filter and page",
+			filter:      "ie",
+			page:        &invite.Page{Number: 2, Size: 2},
+			expected:    []string{"bohr"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			req := &invitepb.FindAcceptedUsersRequest{Filter: tt.filter}
+			if tt.page != nil {
+				invite.SetPage(req, tt.page)
+			}
+			res := paginateAcceptedUsers(ctx, req, &invitepb.FindAcceptedUsersResponse{
+				Status:        status.NewOK(ctx),
+				AcceptedUsers: users(),
+			})
+			assert.Equal(t, rpc.Code_CODE_OK, res.Status.Code)
+			assert.Equal(t, tt.expected, acceptedUsersIDs(res.AcceptedUsers))
+			assert.True(t, invite.IsPaginated(res))
+		})
+	}
+}
+
+func TestPaginateAcceptedUsersAlreadyPaginated(t *testing.T) {
+	ctx := context.Background()
+	req := &invitepb.FindAcceptedUsersRequest{Filter: "einstein"}
+	invite.SetPage(req, &invite.Page{Number: 1, Size: 1})
+
+	// the invite manager matched the filter on other attributes,
+	// and its pagination is kept as it is
+	res := &invitepb.FindAcceptedUsersResponse{
+		Status:        status.NewOK(ctx),
+		AcceptedUsers: []*userpb.User{acceptedUser("marie", "Marie Curie"), acceptedUser("bohr", "Niels Bohr")},
+	}
+	invite.SetPaginated(res)
+
+	res = paginateAcceptedUsers(ctx, req, res)
+	assert.Equal(t, []string{"marie", "bohr"}, acceptedUsersIDs(res.AcceptedUsers))
+}
+
+func TestPaginateAcceptedUsersInvalidPage(t *testing.T) {
+	ctx := context.Background()
+	req := &invitepb.FindAcceptedUsersRequest{}
+	invite.SetPage(req, &invite.Page{Number: 0, Size: 10})
+
+	res := paginateAcceptedUsers(ctx, req, &invitepb.FindAcceptedUsersResponse{
+		Status:        status.NewOK(ctx),
+		AcceptedUsers: []*userpb.User{acceptedUser("marie", "Marie Curie")},
+	})
+	assert.Equal(t, rpc.Code_CODE_INVALID_ARGUMENT, res.Status.Code)
+	assert.Empty(t, res.AcceptedUsers)
+}
+
+func TestResolveProviders(t *testing.T) {
+	ctx := context.Background()
+	users := []*userpb.User{
+		{Id: &userpb.UserId{Idp: "cernbox.cern.ch", OpaqueId: "marie"}},
+		{Id: &userpb.UserId{Idp: "unreachable.org", OpaqueId: "einstein"}},
+		{Id: &userpb.UserId{Idp: "cernbox.cern.ch", OpaqueId: "richard"}},
+	}
+
+	lookups := map[string]int{}
+	getInfo := func(ctx context.Context, domain string) (*ocmprovider.ProviderInfo, error) {
+		lookups[domain]++
+		if domain == "unreachable.org" {
+			return nil, errors.New("provider unavailable")
+		}
+		return &ocmprovider.ProviderInfo{Domain: domain, FullName: "CERNBox"}, nil
+	}
+
+	accepted := resolveProviders(ctx, users, getInfo)
+	assert.Equal(t, map[string]int{"cernbox.cern.ch": 1, "unreachable.org": 1}, lookups)
+	assert.Len(t, accepted, 3)
+
+	assert.Equal(t, "marie", accepted[0].User.Id.OpaqueId)
+	assert.Equal(t, "CERNBox", accepted[0].Provider.FullName)
+	assert.Empty(t, accepted[0].Error)
+
+	// the failure of a provider is reported only for its users
+	assert.Equal(t, "einstein", accepted[1].User.Id.OpaqueId)
+	assert.Nil(t, accepted[1].Provider)
+	assert.Equal(t, "provider unavailable", accepted[1].Error)
+
+	assert.Equal(t, "richard", accepted[2].User.Id.OpaqueId)
+	assert.Equal(t, "CERNBox", accepted[2].Provider.FullName)
+	assert.Empty(t, accepted[2].Error)
+}
+
+func TestAcceptedUsersListing(t *testing.T) {
+	out := invite.ContextSetIncludeAcceptedUsers(context.Background())
+	md, _ := metadata.FromOutgoingContext(out)
+	assert.True(t, invite.ContextGetIncludeAcceptedUsers(metadata.NewIncomingContext(context.Background(), md)))
+	assert.False(t, invite.ContextGetIncludeAcceptedUsers(context.Background()))
+
+	listing := &invite.Listing{
+		AcceptedUsers: []*invite.AcceptedUser{
+			{User: acceptedUser("marie", "Marie Skłodowska-Curie"), Provider: &ocmprovider.ProviderInfo{Domain: "cernbox.cern.ch"}},
+			{User: acceptedUser("einstein", "Albert Einstein"), Error: "provider unavailable"},
+		},
+	}
+	header, err := invite.EncodeListing(listing)
+	assert.NoError(t, err)
+
+	got, ok, err := invite.DecodeListing(header)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Len(t, got.AcceptedUsers, 2)
+	assert.Equal(t, "Marie Skłodowska-Curie", got.AcceptedUsers[0].User.DisplayName)
+	assert.Equal(t, "cernbox.cern.ch", got.AcceptedUsers[0].Provider.Domain)
+	assert.Equal(t, "provider unavailable", got.AcceptedUsers[1].Error)
+
+	_, ok, err = invite.DecodeListing(metadata.MD{})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+// fakeTokensClient is an OCM invite manager client paginating the tokens
+// following the options in the metadata of the request, as the invite manager does.
+type fakeTokensClient struct {
+	invitepb.InviteAPIClient
+	tokens []*invitepb.InviteToken
+	opts   *invite.ListTokensOptions
+}
+
+func (c *fakeTokensClient) ListInviteTokens(ctx context.Context, req *invitepb.ListInviteTokensRequest, opts ...grpc.CallOption) (*invitepb.ListInviteTokensResponse, error) {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	o, err := invite.ContextGetListTokensOptions(metadata.NewIncomingContext(ctx, md))
+	if err != nil {
+		return &invitepb.ListInviteTokensResponse{Status: status.NewInvalid(ctx, err.Error())}, nil
+	}
+	c.opts = o
+
+	tokens, next := invite.PaginateTokens(c.tokens, o)
+	for _, opt := range opts {
+		if h, ok := opt.(grpc.HeaderCallOption); ok && next != "" {
+			*h.HeaderAddr = invite.EncodeNextCursor(next)
+		}
+	}
+	return &invitepb.ListInviteTokensResponse{Status: status.NewOK(ctx), InviteTokens: tokens}, nil
+}
+
+// fakeServerStream records the headers sent by the gateway.
+type fakeServerStream struct {
+	header metadata.MD
+}
+
+func (s *fakeServerStream) Method() string { return "ListInviteTokens" }
+func (s *fakeServerStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+func (s *fakeServerStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+func (s *fakeServerStream) SetTrailer(md metadata.MD) error { return nil }
+
+func TestListInviteTokens(t *testing.T) {
+	s := &svc{
+		c:                 &config{OCMInviteManagerEndpoint: testEndpoint},
+		ocmCircuitBreaker: newCircuitBreaker(10, time.Minute),
+	}
+	c := &fakeTokensClient{
+		tokens: []*invitepb.InviteToken{{Token: "c"}, {Token: "a"}, {Token: "d"}, {Token: "b"}},
+	}
+
+	list := func(o *invite.ListTokensOptions) ([]string, string) {
+		// the options set by the client are received by the gateway in the incoming metadata
+		out := invite.ContextSetListTokensOptions(context.Background(), o)
+		md, _ := metadata.FromOutgoingContext(out)
+		stream := &fakeServerStream{}
+		ctx := grpc.NewContextWithServerTransportStream(metadata.NewIncomingContext(context.Background(), md), stream)
+
+		res, err := s.listInviteTokens(ctx, map[string]invitepb.InviteAPIClient{testEndpoint: c}, &invitepb.ListInviteTokensRequest{})
+		if err != nil {
+			t.Fatalf("not expected error listing the tokens: %+v", err)
+		}
+		assert.Equal(t, rpc.Code_CODE_OK, res.Status.Code)
+		tokens := []string{}
+		for _, tkn := range res.InviteTokens {
+			tokens = append(tokens, tkn.Token)
+		}
+		return tokens, invite.DecodeNextCursor(stream.header)
+	}
+
+	tokens, next := list(&invite.ListTokensOptions{PageSize: 3, State: invite.TokenStatePending})
+	assert.Equal(t, &invite.ListTokensOptions{PageSize: 3, State: invite.TokenStatePending}, c.opts)
+	assert.Equal(t, []string{"a", "b", "c"}, tokens)
+	assert.Equal(t, "c", next)
+
+	tokens, next = list(&invite.ListTokensOptions{PageSize: 3, Cursor: next, State: invite.TokenStatePending})
+	assert.Equal(t, &invite.ListTokensOptions{PageSize: 3, Cursor: "c", State: invite.TokenStatePending}, c.opts)
+	assert.Equal(t, []string{"d"}, tokens)
+	assert.Empty(t, next)
+
+	// without options all the tokens are returned
+	tokens, next = list(&invite.ListTokensOptions{})
+	assert.Equal(t, &invite.ListTokensOptions{}, c.opts)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, tokens)
+	assert.Empty(t, next)
+}
+
+func TestListInviteTokensInvalidOptions(t *testing.T) {
+	s := &svc{
+		c:                 &config{OCMInviteManagerEndpoint: testEndpoint},
+		ocmCircuitBreaker: newCircuitBreaker(10, time.Minute),
+	}
+	c := &fakeTokensClient{}
+
+	md := metadata.Pairs(invite.TokensStateHeader, "revoked")
+	ctx := grpc.NewContextWithServerTransportStream(metadata.NewIncomingContext(context.Background(), md), &fakeServerStream{})
+	res, err := s.listInviteTokens(ctx, map[string]invitepb.InviteAPIClient{testEndpoint: c}, &invitepb.ListInviteTokensRequest{})
+	if err != nil {
+		t.Fatalf("not expected error listing the tokens: %+v", err)
+	}
+	assert.Equal(t, rpc.Code_CODE_INVALID_ARGUMENT, res.Status.Code)
+}
+
+func TestListInviteTokensAggregation(t *testing.T) {
+	s := &svc{
+		c:                 &config{OCMInviteManagerEndpoint: testEndpoint},
+		ocmCircuitBreaker: newCircuitBreaker(10, time.Minute),
+	}
+	clients := map[string]invitepb.InviteAPIClient{
+		testEndpoint: &fakeTokensClient{
+			tokens: []*invitepb.InviteToken{{Token: "c"}, {Token: "a"}, {Token: "e"}},
+		},
+		"localhost:19001": &fakeTokensClient{
+			tokens: []*invitepb.InviteToken{{Token: "d"}, {Token: "b"}},
+		},
+	}
+
+	list := func(o *invite.ListTokensOptions) ([]string, string) {
+		out := invite.ContextSetListTokensOptions(context.Background(), o)
+		md, _ := metadata.FromOutgoingContext(out)
+		stream := &fakeServerStream{}
+		ctx := grpc.NewContextWithServerTransportStream(metadata.NewIncomingContext(context.Background(), md), stream)
+
+		res, err := s.listInviteTokens(ctx, clients, &invitepb.ListInviteTokensRequest{})
+		if err != nil {
+			t.Fatalf("not expected error listing the tokens: %+v", err)
+		}
+		assert.Equal(t, rpc.Code_CODE_OK, res.Status.Code)
+		tokens := []string{}
+		for _, tkn := range res.InviteTokens {
+			tokens = append(tokens, tkn.Token)
+		}
+		return tokens, invite.DecodeNextCursor(stream.header)
+	}
+
+	tokens, next := list(&invite.ListTokensOptions{PageSize: 2})
+	assert.Equal(t, []string{"a", "b"}, tokens)
+	assert.Equal(t, "b", next)
+
+	tokens, next = list(&invite.ListTokensOptions{PageSize: 2, Cursor: next})
+	assert.Equal(t, []string{"c", "d"}, tokens)
+	assert.Equal(t, "d", next)
+
+	tokens, next = list(&invite.ListTokensOptions{PageSize: 2, Cursor: next})
+	assert.Equal(t, []string{"e"}, tokens)
+	assert.Empty(t, next)
+
+	tokens, next = list(&invite.ListTokensOptions{})
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, tokens)
+	assert.Empty(t, next)
+}
+
+// fakeAcceptedUsersClient is an invite manager client returning
+// its accepted users, without filtering nor paginating them,
+// or the failure code when set.
+type fakeAcceptedUsersClient struct {
+	invitepb.InviteAPIClient
+	users   []*userpb.User
+	failure rpc.Code
+	req     *invitepb.FindAcceptedUsersRequest
+}
+
+func (c *fakeAcceptedUsersClient) FindAcceptedUsers(ctx context.Context, req *invitepb.FindAcceptedUsersRequest, opts ...grpc.CallOption) (*invitepb.FindAcceptedUsersResponse, error) {
+	c.req = req
+	if c.failure != rpc.Code_CODE_INVALID {
+		return &invitepb.FindAcceptedUsersResponse{Status: &rpc.Status{Code: c.failure}}, nil
+	}
+	return &invitepb.FindAcceptedUsersResponse{Status: status.NewOK(ctx), AcceptedUsers: c.users}, nil
+}
+
+func TestFindAcceptedUsersAggregation(t *testing.T) {
+	ctx := context.Background()
+	s := &svc{
+		c:                 &config{OCMInviteManagerEndpoint: testEndpoint},
+		ocmCircuitBreaker: newCircuitBreaker(10, time.Minute),
+	}
+	c1 := &fakeAcceptedUsersClient{
+		users: []*userpb.User{acceptedUser("marie", "Marie Curie"), acceptedUser("einstein", "Albert Einstein")},
+	}
+	c2 := &fakeAcceptedUsersClient{
+		users: []*userpb.User{acceptedUser("richard", "Richard Feynman"), acceptedUser("marie", "Marie Curie")},
+	}
+	clients := map[string]invitepb.InviteAPIClient{testEndpoint: c1, "localhost:19001": c2}
+
+	req := &invitepb.FindAcceptedUsersRequest{}
+	invite.SetPage(req, &invite.Page{Number: 1, Size: 2})
+	res, err := s.findAcceptedUsers(ctx, clients, req)
+	if err != nil {
+		t.Fatalf("not expected error finding the accepted users: %+v", err)
+	}
+	assert.Equal(t, rpc.Code_CODE_OK, res.Status.Code)
+	assert.Equal(t, []string{"einstein", "marie"}, acceptedUsersIDs(res.AcceptedUsers))
+	// the invite managers are asked for all the users
+	assert.Nil(t, c1.req.Opaque)
+	assert.Nil(t, c2.req.Opaque)
+
+	invite.SetPage(req, &invite.Page{Number: 2, Size: 2})
+	res, err = s.findAcceptedUsers(ctx, clients, req)
+	if err != nil {
+		t.Fatalf("not expected error finding the accepted users: %+v", err)
+	}
+	assert.Equal(t, []string{"richard"}, acceptedUsersIDs(res.AcceptedUsers))
+
+	res, err = s.findAcceptedUsers(ctx, clients, &invitepb.FindAcceptedUsersRequest{Filter: "feyn"})
+	if err != nil {
+		t.Fatalf("not expected error finding the accepted users: %+v", err)
+	}
+	assert.Equal(t, []string{"richard"}, acceptedUsersIDs(res.AcceptedUsers))
+	assert.Equal(t, "feyn", c2.req.Filter)
+
+	// a failing invite manager fails the whole lookup
+	c2.failure = rpc.Code_CODE_INTERNAL
+	res, err = s.findAcceptedUsers(ctx, clients, &invitepb.FindAcceptedUsersRequest{})
+	if err != nil {
+		t.Fatalf("not expected error finding the accepted users: %+v", err)
+	}
+	assert.Equal(t, rpc.Code_CODE_INTERNAL, res.Status.Code)
+}
+
+// fakeInviteManager is an invite manager keeping its tokens
+// and the users who accepted them in memory.
+type fakeInviteManager struct {
+	invitepb.InviteAPIClient
+	tokens   map[string]*invitepb.InviteToken
+	accepted map[string]*userpb.User // by inviter and remote user
+}
+
+func newFakeInviteManager() *fakeInviteManager {
+	return &fakeInviteManager{tokens: map[string]*invitepb.InviteToken{}, accepted: map[string]*userpb.User{}}
+}
+
+func (m *fakeInviteManager) GenerateInviteToken(ctx context.Context, req *invitepb.GenerateInviteTokenRequest, opts ...grpc.CallOption) (*invitepb.GenerateInviteTokenResponse, error) {
+	u := ctxpkg.ContextMustGetUser(ctx)
+	tkn := &invitepb.InviteToken{Token: "token-" + u.Id.OpaqueId, UserId: u.Id}
+	m.tokens[tkn.Token] = tkn
+	return &invitepb.GenerateInviteTokenResponse{Status: status.NewOK(ctx), InviteToken: tkn}, nil
+}
+
+func (m *fakeInviteManager) AcceptInvite(ctx context.Context, req *invitepb.AcceptInviteRequest, opts ...grpc.CallOption) (*invitepb.AcceptInviteResponse, error) {
+	tkn, ok := m.tokens[req.InviteToken.GetToken()]
+	if !ok {
+		return &invitepb.AcceptInviteResponse{Status: status.NewNotFound(ctx, "token not found")}, nil
+	}
+	m.accepted[tkn.UserId.OpaqueId+"!"+req.RemoteUser.Id.OpaqueId] = req.RemoteUser
+	return &invitepb.AcceptInviteResponse{Status: status.NewOK(ctx)}, nil
+}
+
+func (m *fakeInviteManager) GetAcceptedUser(ctx context.Context, req *invitepb.GetAcceptedUserRequest, opts ...grpc.CallOption) (*invitepb.GetAcceptedUserResponse, error) {
+	var inviter userpb.UserId
+	if u, ok := ctxpkg.ContextGetUser(ctx); ok {
+		inviter = *u.Id
+	} else if err := utils.UnmarshalJSONToProtoV1(req.Opaque.Map["user-filter"].Value, &inviter); err != nil {
+		return &invitepb.GetAcceptedUserResponse{Status: status.NewInvalidArg(ctx, "user not found")}, nil
+	}
+	u, ok := m.accepted[inviter.OpaqueId+"!"+req.RemoteUserId.GetOpaqueId()]
+	if !ok {
+		return &invitepb.GetAcceptedUserResponse{Status: status.NewNotFound(ctx, "remote user not found")}, nil
+	}
+	return &invitepb.GetAcceptedUserResponse{Status: status.NewOK(ctx), RemoteUser: u}, nil
+}
+
+func TestInviteWorkflowWithRoutedDomain(t *testing.T) {
+	router, err := newOCMRouter([]*ocmRoute{
+		{Domain: "cernbox.cern.ch", OCMInviteManagerEndpoint: "localhost:19001"},
+	}, testEndpoint, "")
+	if err != nil {
+		t.Fatalf("not expected error creating the router: %+v", err)
+	}
+	s := &svc{
+		c:                 &config{OCMInviteManagerEndpoint: testEndpoint},
+		ocmRouter:         router,
+		ocmCircuitBreaker: newCircuitBreaker(10, time.Minute),
+	}
+	defaultManager, routedManager := newFakeInviteManager(), newFakeInviteManager()
+	clients := map[string]invitepb.InviteAPIClient{testEndpoint: defaultManager, "localhost:19001": routedManager}
+
+	einstein := &userpb.User{Id: &userpb.UserId{Idp: "cernbox.cern.ch", OpaqueId: "einstein"}}
+	marie := &userpb.User{Id: &userpb.UserId{Idp: "cesnet.cz", OpaqueId: "marie"}}
+	ctx := ctxpkg.ContextSetUser(context.Background(), einstein)
+
+	// the token is generated on the invite manager of the domain of the user
+	endpoint := s.ocmRouter.inviteManagerEndpoint(userDomain(ctx))
+	generated := s.generateInviteToken(ctx, endpoint, clients[endpoint], &invitepb.GenerateInviteTokenRequest{})
+	assert.Equal(t, rpc.Code_CODE_OK, generated.Status.Code)
+	assert.Contains(t, routedManager.tokens, generated.InviteToken.Token)
+	assert.Empty(t, defaultManager.tokens)
+
+	// the invite is accepted by the remote user, without a local user
+	accepted := s.acceptInvite(context.Background(), clients, &invitepb.AcceptInviteRequest{
+		InviteToken: generated.InviteToken,
+		RemoteUser:  marie,
+	})
+	assert.Equal(t, rpc.Code_CODE_OK, accepted.Status.Code)
+
+	// the accepted user is found by the user who sent the invite
+	got := s.getAcceptedUser(ctx, clients, &invitepb.GetAcceptedUserRequest{RemoteUserId: marie.Id})
+	assert.Equal(t, rpc.Code_CODE_OK, got.Status.Code)
+	assert.Equal(t, marie.Id, got.RemoteUser.GetId())
+
+	// and by the services looking it up on behalf of the user, e.g. the ocmshares auth manager
+	filter, err := utils.MarshalProtoV1ToJSON(einstein.Id)
+	if err != nil {
+		t.Fatalf("not expected error marshalling the user filter: %+v", err)
+	}
+	got = s.getAcceptedUser(context.Background(), clients, &invitepb.GetAcceptedUserRequest{
+		RemoteUserId: marie.Id,
+		Opaque:       &types.Opaque{Map: map[string]*types.OpaqueEntry{"user-filter": {Decoder: "json", Value: filter}}},
+	})
+	assert.Equal(t, rpc.Code_CODE_OK, got.Status.Code)
+	assert.Equal(t, marie.Id, got.RemoteUser.GetId())
+
+	// an unknown token is not found on any invite manager
+	accepted = s.acceptInvite(context.Background(), clients, &invitepb.AcceptInviteRequest{
+		InviteToken: &invitepb.InviteToken{Token: "unknown"},
+		RemoteUser:  marie,
+	})
+	assert.Equal(t, rpc.Code_CODE_NOT_FOUND, accepted.Status.Code)
+}
@@ -0,0 +1,177 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	"context"
+	"strings"
+
+	ocmcore "github.com/cs3org/go-cs3apis/cs3/ocm/core/v1beta1"
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	providerpb "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/golang/protobuf/proto" //nolint:staticcheck
+)
+
+// OCMMaxPermissionsProperty is the property in the info of a provider, as
+// returned by the provider authorizer, holding the comma-separated maximum
+// permissions of the shares received from that provider, e.g. "read".
+// It overrides the ocm_incoming_max_permissions of the gateway.
+const OCMMaxPermissionsProperty = "ocm_max_permissions"
+
+// permissions of the OCM API
+const (
+	ocmPermissionRead  = "read"
+	ocmPermissionWrite = "write"
+	ocmPermissionShare = "share"
+)
+
+// ocmPermissions is a set of permissions of the OCM API.
+type ocmPermissions struct {
+	read, write, share bool
+}
+
+// parseOCMPermissions parses the maximum permissions of the received shares.
+// The share permission is rejected, as the received shares cannot be shared again.
+func parseOCMPermissions(perms []string) (ocmPermissions, error) {
+	var p ocmPermissions
+	for _, perm := range perms {
+		switch perm = strings.TrimSpace(perm); perm {
+		case "":
+		case ocmPermissionRead:
+			p.read = true
+		case ocmPermissionWrite:
+			p.write = true
+		case ocmPermissionShare:
+			return ocmPermissions{}, errtypes.BadRequest("the received ocm shares cannot be granted the " + perm + " permission")
+		default:
+			return ocmPermissions{}, errtypes.BadRequest("unknown ocm permission " + perm)
+		}
+	}
+	return p, nil
+}
+
+func (p ocmPermissions) String() string {
+	perms := []string{}
+	if p.read {
+		perms = append(perms, ocmPermissionRead)
+	}
+	if p.write {
+		perms = append(perms, ocmPermissionWrite)
+	}
+	if p.share {
+		perms = append(perms, ocmPermissionShare)
+	}
+	return strings.Join(perms, ",")
+}
+
+// sharePermissionsToOCM translates the permissions of a webdav protocol back
+// to the permissions of the OCM API, the same way they are translated
+// when the share is received. The resource permissions not granted
+// by any OCM permission are rejected.
+func sharePermissionsToOCM(perms *ocm.SharePermissions) (ocmPermissions, error) {
+	rp := &providerpb.ResourcePermissions{}
+	if perms.GetPermissions() != nil {
+		rp = proto.Clone(perms.Permissions).(*providerpb.ResourcePermissions)
+	}
+	p := ocmPermissions{
+		read:  rp.GetPath || rp.InitiateFileDownload || rp.ListContainer || rp.Stat,
+		write: rp.InitiateFileUpload,
+		share: perms.GetReshare(),
+	}
+
+	rp.GetPath, rp.InitiateFileDownload, rp.ListContainer, rp.Stat = false, false, false, false
+	rp.InitiateFileUpload = false
+	if !proto.Equal(rp, &providerpb.ResourcePermissions{}) {
+		return ocmPermissions{}, errtypes.BadRequest("unknown ocm permissions " + proto.CompactTextString(rp))
+	}
+	return p, nil
+}
+
+// clampSharePermissions removes from the permissions of a webdav protocol
+// the ones exceeding the maximum.
+func clampSharePermissions(perms *ocm.SharePermissions, max ocmPermissions) {
+	if perms.Permissions != nil {
+		if !max.read {
+			perms.Permissions.GetPath = false
+			perms.Permissions.InitiateFileDownload = false
+			perms.Permissions.ListContainer = false
+			perms.Permissions.Stat = false
+		}
+		if !max.write {
+			perms.Permissions.InitiateFileUpload = false
+		}
+	}
+	if !max.share {
+		perms.Reshare = false
+	}
+}
+
+// sanitizeOCMPermissions clamps the permissions claimed by the remote provider
+// in the protocols of the share against the maximum allowed for that provider.
+// The request is rejected if the permissions are unknown.
+func sanitizeOCMPermissions(ctx context.Context, req *ocmcore.CreateOCMCoreShareRequest, max ocmPermissions) error {
+	log := appctx.GetLogger(ctx)
+	for _, p := range req.Protocols {
+		webdav := p.GetWebdavOptions()
+		if webdav == nil || webdav.Permissions == nil {
+			continue
+		}
+		claimed, err := sharePermissionsToOCM(webdav.Permissions)
+		if err != nil {
+			return err
+		}
+		granted := ocmPermissions{
+			read:  claimed.read && max.read,
+			write: claimed.write && max.write,
+			share: claimed.share && max.share,
+		}
+		if granted != claimed {
+			log.Warn().Str("sender", req.Sender.GetIdp()).Str("resource_id", req.ResourceId).
+				Str("claimed", claimed.String()).Str("granted", granted.String()).
+				Msg("gateway: clamping the permissions of the incoming ocm share")
+			clampSharePermissions(webdav.Permissions, max)
+		}
+	}
+	return nil
+}
+
+// ocmMaxPermissions returns the maximum permissions of the shares received
+// from the provider of the given domain, as set in its info, or else the
+// ones configured in the gateway.
+func (s *svc) ocmMaxPermissions(ctx context.Context, domain string, getInfo func(context.Context, string) (*ocmprovider.ProviderInfo, error)) ocmPermissions {
+	log := appctx.GetLogger(ctx)
+	info, err := getInfo(ctx, domain)
+	if err != nil {
+		log.Debug().Err(err).Str("domain", domain).Msg("gateway: error getting the provider info, using the default maximum ocm permissions")
+		return s.ocmIncomingMaxPermissions
+	}
+	v, ok := info.GetProperties()[OCMMaxPermissionsProperty]
+	if !ok {
+		return s.ocmIncomingMaxPermissions
+	}
+	max, err := parseOCMPermissions(strings.Split(v, ","))
+	if err != nil {
+		log.Warn().Err(err).Str("domain", domain).Msg("gateway: invalid maximum ocm permissions of the provider, using the default ones")
+		return s.ocmIncomingMaxPermissions
+	}
+	return max
+}
@@ -0,0 +1,174 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	ocmcore "github.com/cs3org/go-cs3apis/cs3/ocm/core/v1beta1"
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	providerpb "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	ocmshare "github.com/cs3org/reva/pkg/ocm/share"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	ocmRead = &providerpb.ResourcePermissions{
+		GetPath:              true,
+		InitiateFileDownload: true,
+		ListContainer:        true,
+		Stat:                 true,
+	}
+	ocmReadWrite = &providerpb.ResourcePermissions{
+		GetPath:              true,
+		InitiateFileDownload: true,
+		ListContainer:        true,
+		Stat:                 true,
+		InitiateFileUpload:   true,
+	}
+)
+
+func newOCMShareWithPermissions(perms *ocm.SharePermissions) *ocmcore.CreateOCMCoreShareRequest {
+	return &ocmcore.CreateOCMCoreShareRequest{
+		ResourceId: "1",
+		Protocols: []*ocm.Protocol{
+			ocmshare.NewWebDAVProtocol("https://cernbox.cern.ch/remote.php/dav/ocm/token", "secret", perms),
+			ocmshare.NewWebappProtocol("https://cernbox.cern.ch/external/sciencemesh/token/{relative-path-to-shared-resource}"),
+		},
+	}
+}
+
+func TestSanitizeOCMPermissions(t *testing.T) {
+	readWrite := ocmPermissions{read: true, write: true}
+
+	tests := []struct {
+		description string
+		perms       *ocm.SharePermissions
+		max         ocmPermissions
+		expected    *ocm.SharePermissions
+		invalid     bool
+	}{
+		{
+			description: "read only",
+			perms:       &ocm.SharePermissions{Permissions: ocmRead},
+			max:         readWrite,
+			expected:    &ocm.SharePermissions{Permissions: ocmRead},
+		},
+		{
+			description: "read and write",
+			perms:       &ocm.SharePermissions{Permissions: ocmReadWrite},
+			max:         readWrite,
+			expected:    &ocm.SharePermissions{Permissions: ocmReadWrite},
+		},
+		{
+			description: "reshare is clamped",
+			perms:       &ocm.SharePermissions{Permissions: ocmReadWrite, Reshare: true},
+			max:         readWrite,
+			expected:    &ocm.SharePermissions{Permissions: ocmReadWrite},
+		},
+		{
+			description: "reshare allowed",
+			perms:       &ocm.SharePermissions{Permissions: ocmRead, Reshare: true},
+			max:         ocmPermissions{read: true, share: true},
+			expected:    &ocm.SharePermissions{Permissions: ocmRead, Reshare: true},
+		},
+		{
+			description: "write is clamped for a read only provider",
+			perms:       &ocm.SharePermissions{Permissions: ocmReadWrite, Reshare: true},
+			max:         ocmPermissions{read: true},
+			expected:    &ocm.SharePermissions{Permissions: ocmRead},
+		},
+		{
+			description: "delete is not an ocm permission",
+			perms: &ocm.SharePermissions{Permissions: &providerpb.ResourcePermissions{
+				Stat: true, InitiateFileDownload: true, Delete: true,
+			}},
+			max:     readWrite,
+			invalid: true,
+		},
+		{
+			description: "all the permissions",
+			perms: &ocm.SharePermissions{Reshare: true, Permissions: &providerpb.ResourcePermissions{
+				AddGrant: true, CreateContainer: true, Delete: true, GetPath: true, GetQuota: true,
+				InitiateFileDownload: true, InitiateFileUpload: true, ListGrants: true, ListContainer: true,
+				Move: true, RemoveGrant: true, Stat: true, UpdateGrant: true, DenyGrant: true,
+			}},
+			max:     readWrite,
+			invalid: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			req := newOCMShareWithPermissions(tt.perms)
+			err := sanitizeOCMPermissions(context.Background(), req, tt.max)
+			if tt.invalid {
+				assert.IsType(t, errtypes.BadRequest(""), err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected.String(), req.Protocols[0].GetWebdavOptions().Permissions.String())
+			assert.NotNil(t, req.Protocols[1].GetWebappOptions())
+		})
+	}
+}
+
+func TestParseOCMPermissions(t *testing.T) {
+	p, err := parseOCMPermissions([]string{"read", " write"})
+	assert.NoError(t, err)
+	assert.Equal(t, ocmPermissions{read: true, write: true}, p)
+	assert.Equal(t, "read,write", p.String())
+
+	_, err = parseOCMPermissions([]string{"read", "delete"})
+	assert.Error(t, err)
+
+	_, err = parseOCMPermissions([]string{"read", "share"})
+	assert.Error(t, err)
+}
+
+func TestOCMMaxPermissions(t *testing.T) {
+	s := &svc{ocmIncomingMaxPermissions: ocmPermissions{read: true, write: true}}
+	getInfo := func(ctx context.Context, domain string) (*ocmprovider.ProviderInfo, error) {
+		switch domain {
+		case "readonly.org":
+			return &ocmprovider.ProviderInfo{Domain: domain, Properties: map[string]string{OCMMaxPermissionsProperty: "read"}}, nil
+		case "trusted.org":
+			return &ocmprovider.ProviderInfo{Domain: domain, Properties: map[string]string{OCMMaxPermissionsProperty: "read,write"}}, nil
+		case "resharing.org":
+			return &ocmprovider.ProviderInfo{Domain: domain, Properties: map[string]string{OCMMaxPermissionsProperty: "read,share"}}, nil
+		case "invalid.org":
+			return &ocmprovider.ProviderInfo{Domain: domain, Properties: map[string]string{OCMMaxPermissionsProperty: "read,delete"}}, nil
+		case "cernbox.cern.ch":
+			return &ocmprovider.ProviderInfo{Domain: domain}, nil
+		}
+		return nil, errors.New("provider not found")
+	}
+
+	ctx := context.Background()
+	assert.Equal(t, ocmPermissions{read: true}, s.ocmMaxPermissions(ctx, "readonly.org", getInfo))
+	assert.Equal(t, ocmPermissions{read: true, write: true}, s.ocmMaxPermissions(ctx, "trusted.org", getInfo))
+	assert.Equal(t, ocmPermissions{read: true, write: true}, s.ocmMaxPermissions(ctx, "resharing.org", getInfo))
+	assert.Equal(t, ocmPermissions{read: true, write: true}, s.ocmMaxPermissions(ctx, "invalid.org", getInfo))
+	assert.Equal(t, ocmPermissions{read: true, write: true}, s.ocmMaxPermissions(ctx, "cernbox.cern.ch", getInfo))
+	assert.Equal(t, ocmPermissions{read: true, write: true}, s.ocmMaxPermissions(ctx, "unknown.org", getInfo))
+}
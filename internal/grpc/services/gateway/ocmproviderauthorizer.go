@@ -25,12 +25,12 @@ import (
 	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/pkg/tracing"
-	"github.com/pkg/errors"
 )
 
-func (s *svc) IsProviderAllowed(ctx context.Context, req *ocmprovider.IsProviderAllowedRequest) (*ocmprovider.IsProviderAllowedResponse, error) {
+func (s *svc) IsProviderAllowed(ctx context.Context, req *ocmprovider.IsProviderAllowedRequest) (res *ocmprovider.IsProviderAllowedResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "IsProviderAllowed")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoint.String(s.c.OCMProviderAuthorizerEndpoint))
 
 	c, err := pool.GetOCMProviderAuthorizerClient(ctx, pool.Endpoint(s.c.OCMProviderAuthorizerEndpoint))
 	if err != nil {
@@ -39,17 +39,20 @@ func (s *svc) IsProviderAllowed(ctx context.Context, req *ocmprovider.IsProvider
 		}, nil
 	}
 
-	res, err := c.IsProviderAllowed(ctx, req)
+	res, err = c.IsProviderAllowed(ctx, req)
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling IsProviderAllowed")
+		return &ocmprovider.IsProviderAllowedResponse{
+			Status: statusFromOCMError(ctx, err, "error calling IsProviderAllowed"),
+		}, nil
 	}
 
 	return res, nil
 }
 
-func (s *svc) GetInfoByDomain(ctx context.Context, req *ocmprovider.GetInfoByDomainRequest) (*ocmprovider.GetInfoByDomainResponse, error) {
+func (s *svc) GetInfoByDomain(ctx context.Context, req *ocmprovider.GetInfoByDomainRequest) (res *ocmprovider.GetInfoByDomainResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "GetInfoByDomain")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoint.String(s.c.OCMProviderAuthorizerEndpoint))
 
 	c, err := pool.GetOCMProviderAuthorizerClient(ctx, pool.Endpoint(s.c.OCMProviderAuthorizerEndpoint))
 	if err != nil {
@@ -58,17 +61,20 @@ func (s *svc) GetInfoByDomain(ctx context.Context, req *ocmprovider.GetInfoByDom
 		}, nil
 	}
 
-	res, err := c.GetInfoByDomain(ctx, req)
+	res, err = c.GetInfoByDomain(ctx, req)
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling GetInfoByDomain")
+		return &ocmprovider.GetInfoByDomainResponse{
+			Status: statusFromOCMError(ctx, err, "error calling GetInfoByDomain"),
+		}, nil
 	}
 
 	return res, nil
 }
 
-func (s *svc) ListAllProviders(ctx context.Context, req *ocmprovider.ListAllProvidersRequest) (*ocmprovider.ListAllProvidersResponse, error) {
+func (s *svc) ListAllProviders(ctx context.Context, req *ocmprovider.ListAllProvidersRequest) (res *ocmprovider.ListAllProvidersResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "ListAllProviders")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoint.String(s.c.OCMProviderAuthorizerEndpoint))
 
 	c, err := pool.GetOCMProviderAuthorizerClient(ctx, pool.Endpoint(s.c.OCMProviderAuthorizerEndpoint))
 	if err != nil {
@@ -77,9 +83,11 @@ func (s *svc) ListAllProviders(ctx context.Context, req *ocmprovider.ListAllProv
 		}, nil
 	}
 
-	res, err := c.ListAllProviders(ctx, req)
+	res, err = c.ListAllProviders(ctx, req)
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling ListAllProviders")
+		return &ocmprovider.ListAllProvidersResponse{
+			Status: statusFromOCMError(ctx, err, "error calling ListAllProviders"),
+		}, nil
 	}
 
 	return res, nil
@@ -0,0 +1,178 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ocmRoute sends the OCM calls for the users of a domain to other
+// invite manager and OCM core services than the default ones.
+type ocmRoute struct {
+	// Domain is the idp domain of the users. Prefixed with "*.",
+	// it matches all its subdomains.
+	Domain string `mapstructure:"domain"`
+	// OCMInviteManagerEndpoint defaults to the one of the gateway.
+	OCMInviteManagerEndpoint string `mapstructure:"ocminvitemanagersvc"`
+	// OCMCoreEndpoint defaults to the one of the gateway.
+	OCMCoreEndpoint string `mapstructure:"ocmcoresvc"`
+}
+
+// ocmRouter selects the endpoints of the OCM services by the domain of the users.
+// The exact rules take precedence over the ones matching the subdomains,
+// of which the longest suffix wins.
+type ocmRouter struct {
+	exact    map[string]*ocmRoute
+	suffixes []*ocmRoute
+	fallback *ocmRoute
+}
+
+func newOCMRouter(routes []*ocmRoute, inviteManagerEndpoint, coreEndpoint string) (*ocmRouter, error) {
+	r := &ocmRouter{
+		exact: map[string]*ocmRoute{},
+		fallback: &ocmRoute{
+			OCMInviteManagerEndpoint: inviteManagerEndpoint,
+			OCMCoreEndpoint:          coreEndpoint,
+		},
+	}
+
+	for _, route := range routes {
+		if route == nil || route.Domain == "" {
+			return nil, errors.New("gateway: ocm route without domain")
+		}
+		if route.OCMInviteManagerEndpoint == "" && route.OCMCoreEndpoint == "" {
+			return nil, errors.Errorf("gateway: ocm route for %s without endpoints", route.Domain)
+		}
+
+		rt := &ocmRoute{
+			Domain:                   ocmDomain(route.Domain),
+			OCMInviteManagerEndpoint: route.OCMInviteManagerEndpoint,
+			OCMCoreEndpoint:          route.OCMCoreEndpoint,
+		}
+		if rt.OCMInviteManagerEndpoint == "" {
+			rt.OCMInviteManagerEndpoint = inviteManagerEndpoint
+		}
+		if rt.OCMCoreEndpoint == "" {
+			rt.OCMCoreEndpoint = coreEndpoint
+		}
+
+		if suffix := strings.TrimPrefix(rt.Domain, "*"); suffix != rt.Domain {
+			if !strings.HasPrefix(suffix, ".") || len(suffix) == 1 {
+				return nil, errors.Errorf("gateway: invalid ocm route domain %s", route.Domain)
+			}
+			rt.Domain = suffix
+			r.suffixes = append(r.suffixes, rt)
+			continue
+		}
+		if _, ok := r.exact[rt.Domain]; ok {
+			return nil, errors.Errorf("gateway: duplicated ocm route for %s", route.Domain)
+		}
+		r.exact[rt.Domain] = rt
+	}
+
+	sort.SliceStable(r.suffixes, func(i, j int) bool {
+		return len(r.suffixes[i].Domain) > len(r.suffixes[j].Domain)
+	})
+	return r, nil
+}
+
+// route returns the route of the users of the domain.
+func (r *ocmRouter) route(domain string) *ocmRoute {
+	domain = ocmDomain(domain)
+	if domain == "" {
+		return r.fallback
+	}
+	if rt, ok := r.exact[domain]; ok {
+		return rt
+	}
+	for _, rt := range r.suffixes {
+		if strings.HasSuffix(domain, rt.Domain) {
+			return rt
+		}
+	}
+	return r.fallback
+}
+
+// inviteManagerEndpoint returns the endpoint of the invite manager of the users of the domain.
+func (r *ocmRouter) inviteManagerEndpoint(domain string) string {
+	return r.route(domain).OCMInviteManagerEndpoint
+}
+
+// coreEndpoint returns the endpoint of the OCM core service of the users of the domain.
+func (r *ocmRouter) coreEndpoint(domain string) string {
+	return r.route(domain).OCMCoreEndpoint
+}
+
+// inviteManagerEndpoints returns all the endpoints of the invite managers,
+// starting from the default one.
+func (r *ocmRouter) inviteManagerEndpoints() []string {
+	endpoints := []string{r.fallback.OCMInviteManagerEndpoint}
+	seen := map[string]bool{r.fallback.OCMInviteManagerEndpoint: true}
+	add := func(rt *ocmRoute) {
+		if !seen[rt.OCMInviteManagerEndpoint] {
+			seen[rt.OCMInviteManagerEndpoint] = true
+			endpoints = append(endpoints, rt.OCMInviteManagerEndpoint)
+		}
+	}
+
+	domains := make([]string, 0, len(r.exact))
+	for d := range r.exact {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	for _, d := range domains {
+		add(r.exact[d])
+	}
+	for _, rt := range r.suffixes {
+		add(rt)
+	}
+	return endpoints
+}
+
+// inviteManagerEndpointsFrom returns all the endpoints of the invite managers,
+// starting from the one of the users of the domain.
+func (r *ocmRouter) inviteManagerEndpointsFrom(domain string) []string {
+	first := r.inviteManagerEndpoint(domain)
+	endpoints := []string{first}
+	for _, e := range r.inviteManagerEndpoints() {
+		if e != first {
+			endpoints = append(endpoints, e)
+		}
+	}
+	return endpoints
+}
+
+// ocmDomain returns the domain of an idp, that may be given as a URL
+// or with a port, in lower case.
+func ocmDomain(idp string) string {
+	if i := strings.Index(idp, "://"); i >= 0 {
+		idp = idp[i+3:]
+	}
+	if i := strings.IndexByte(idp, '/'); i >= 0 {
+		idp = idp[:i]
+	}
+	if host, _, err := net.SplitHostPort(idp); err == nil {
+		idp = host
+	}
+	return strings.TrimSuffix(strings.ToLower(idp), ".")
+}
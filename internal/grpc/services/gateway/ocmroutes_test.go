@@ -0,0 +1,89 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOCMRouter(t *testing.T) {
+	r, err := newOCMRouter([]*ocmRoute{
+		{Domain: "cernbox.cern.ch", OCMInviteManagerEndpoint: "localhost:19001", OCMCoreEndpoint: "localhost:19011"},
+		{Domain: "*.cern.ch", OCMInviteManagerEndpoint: "localhost:19002"},
+		{Domain: "*.eos.cern.ch", OCMCoreEndpoint: "localhost:19013"},
+		{Domain: "*.example.org", OCMInviteManagerEndpoint: "localhost:19001"},
+	}, testEndpoint, "localhost:19010")
+	if err != nil {
+		t.Fatalf("not expected error creating the router: %+v", err)
+	}
+
+	tests := []struct {
+		domain        string
+		inviteManager string
+		core          string
+	}{
+		{domain: "cernbox.cern.ch", inviteManager: "localhost:19001", core: "localhost:19011"},
+		{domain: "https://CERNBox.cern.ch:443/", inviteManager: "localhost:19001", core: "localhost:19011"},
+		{domain: "box.cern.ch", inviteManager: "localhost:19002", core: "localhost:19010"},
+		{domain: "home.eos.cern.ch", inviteManager: testEndpoint, core: "localhost:19013"},
+		{domain: "cern.ch", inviteManager: testEndpoint, core: "localhost:19010"},
+		{domain: "notcern.ch", inviteManager: testEndpoint, core: "localhost:19010"},
+		{domain: "", inviteManager: testEndpoint, core: "localhost:19010"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.domain, func(t *testing.T) {
+			assert.Equal(t, tt.inviteManager, r.inviteManagerEndpoint(tt.domain))
+			assert.Equal(t, tt.core, r.coreEndpoint(tt.domain))
+		})
+	}
+
+	assert.Equal(t, []string{testEndpoint, "localhost:19001", "localhost:19002"}, r.inviteManagerEndpoints())
+	assert.Equal(t, []string{"localhost:19002", testEndpoint, "localhost:19001"}, r.inviteManagerEndpointsFrom("box.cern.ch"))
+	assert.Equal(t, []string{testEndpoint, "localhost:19001", "localhost:19002"}, r.inviteManagerEndpointsFrom(""))
+}
+
+func TestOCMRouterWithoutRoutes(t *testing.T) {
+	r, err := newOCMRouter(nil, testEndpoint, "localhost:19010")
+	if err != nil {
+		t.Fatalf("not expected error creating the router: %+v", err)
+	}
+	assert.Equal(t, testEndpoint, r.inviteManagerEndpoint("cernbox.cern.ch"))
+	assert.Equal(t, "localhost:19010", r.coreEndpoint("cernbox.cern.ch"))
+	assert.Equal(t, []string{testEndpoint}, r.inviteManagerEndpoints())
+}
+
+func TestInvalidOCMRoutes(t *testing.T) {
+	tests := map[string][]*ocmRoute{
+		"without domain":    {{OCMCoreEndpoint: "localhost:19011"}},
+		"without endpoints": {{Domain: "cernbox.cern.ch"}},
+		"invalid suffix":    {{Domain: "*cern.ch", OCMCoreEndpoint: "localhost:19011"}},
+		"duplicated domain": {
+			{Domain: "cernbox.cern.ch", OCMCoreEndpoint: "localhost:19011"},
+			{Domain: "CERNBox.cern.ch", OCMCoreEndpoint: "localhost:19012"},
+		},
+	}
+	for name, routes := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := newOCMRouter(routes, testEndpoint, "localhost:19010")
+			assert.Error(t, err)
+		})
+	}
+}
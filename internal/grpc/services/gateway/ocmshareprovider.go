@@ -40,9 +40,10 @@ import (
 )
 
 // TODO(labkode): add multi-phase commit logic when commit share or commit ref is enabled.
-func (s *svc) CreateOCMShare(ctx context.Context, req *ocm.CreateOCMShareRequest) (*ocm.CreateOCMShareResponse, error) {
+func (s *svc) CreateOCMShare(ctx context.Context, req *ocm.CreateOCMShareRequest) (res *ocm.CreateOCMShareResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "CreateOCMShare")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoint.String(s.c.OCMShareProviderEndpoint), resourceIDAttribute(req.GetResourceId()))
 
 	c, err := pool.GetOCMShareProviderClient(ctx, pool.Endpoint(s.c.OCMShareProviderEndpoint))
 	if err != nil {
@@ -51,17 +52,24 @@ func (s *svc) CreateOCMShare(ctx context.Context, req *ocm.CreateOCMShareRequest
 		}, nil
 	}
 
-	res, err := c.CreateOCMShare(ctx, req)
+	err = s.callOCM(ctx, s.c.OCMShareProviderEndpoint, func() (err error) {
+		res, err = c.CreateOCMShare(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling CreateShare")
+		return &ocm.CreateOCMShareResponse{
+			Status: statusFromOCMError(ctx, err, "error calling CreateShare"),
+		}, nil
 	}
 
 	return res, nil
 }
 
-func (s *svc) RemoveOCMShare(ctx context.Context, req *ocm.RemoveOCMShareRequest) (*ocm.RemoveOCMShareResponse, error) {
+func (s *svc) RemoveOCMShare(ctx context.Context, req *ocm.RemoveOCMShareRequest) (res *ocm.RemoveOCMShareResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "RemoveOCMShare")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoint.String(s.c.OCMShareProviderEndpoint))
+	span.SetAttributes(shareRefAttributes(req.GetRef())...)
 
 	c, err := pool.GetOCMShareProviderClient(ctx, pool.Endpoint(s.c.OCMShareProviderEndpoint))
 	if err != nil {
@@ -70,9 +78,14 @@ func (s *svc) RemoveOCMShare(ctx context.Context, req *ocm.RemoveOCMShareRequest
 		}, nil
 	}
 
-	res, err := c.RemoveOCMShare(ctx, req)
+	err = s.callOCM(ctx, s.c.OCMShareProviderEndpoint, func() (err error) {
+		res, err = c.RemoveOCMShare(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling RemoveShare")
+		return &ocm.RemoveOCMShareResponse{
+			Status: statusFromOCMError(ctx, err, "error calling RemoveShare"),
+		}, nil
 	}
 
 	return res, nil
@@ -81,16 +94,18 @@ func (s *svc) RemoveOCMShare(ctx context.Context, req *ocm.RemoveOCMShareRequest
 // TODO(labkode): we need to validate share state vs storage grant and storage ref
 // If there are any inconsistencies, the share needs to be flag as invalid and a background process
 // or active fix needs to be performed.
-func (s *svc) GetOCMShare(ctx context.Context, req *ocm.GetOCMShareRequest) (*ocm.GetOCMShareResponse, error) {
+func (s *svc) GetOCMShare(ctx context.Context, req *ocm.GetOCMShareRequest) (res *ocm.GetOCMShareResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "GetOCMShare")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
 
 	return s.getOCMShare(ctx, req)
 }
 
-func (s *svc) getOCMShare(ctx context.Context, req *ocm.GetOCMShareRequest) (*ocm.GetOCMShareResponse, error) {
+func (s *svc) getOCMShare(ctx context.Context, req *ocm.GetOCMShareRequest) (res *ocm.GetOCMShareResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "getOCMShare")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoint.String(s.c.OCMShareProviderEndpoint))
+	span.SetAttributes(shareRefAttributes(req.GetRef())...)
 
 	c, err := pool.GetOCMShareProviderClient(ctx, pool.Endpoint(s.c.OCMShareProviderEndpoint))
 	if err != nil {
@@ -100,32 +115,49 @@ func (s *svc) getOCMShare(ctx context.Context, req *ocm.GetOCMShareRequest) (*oc
 		}, nil
 	}
 
-	res, err := c.GetOCMShare(ctx, req)
+	err = s.callOCM(ctx, s.c.OCMShareProviderEndpoint, func() (err error) {
+		res, err = c.GetOCMShare(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling GetShare")
+		return &ocm.GetOCMShareResponse{
+			Status: statusFromOCMError(ctx, err, "error calling GetShare"),
+		}, nil
 	}
 
 	return res, nil
 }
 
-func (s *svc) GetOCMShareByToken(ctx context.Context, req *ocm.GetOCMShareByTokenRequest) (*ocm.GetOCMShareByTokenResponse, error) {
+func (s *svc) GetOCMShareByToken(ctx context.Context, req *ocm.GetOCMShareByTokenRequest) (res *ocm.GetOCMShareByTokenResponse, err error) {
+	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "GetOCMShareByToken")
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoint.String(s.c.OCMShareProviderEndpoint), tracing.Hashed(attrShareTokenHash, req.GetToken()))
+
 	c, err := pool.GetOCMShareProviderClient(ctx, pool.Endpoint(s.c.OCMShareProviderEndpoint))
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling GetOCMShareProviderClient")
+		return &ocm.GetOCMShareByTokenResponse{
+			Status: status.NewInternal(ctx, err, "error getting share provider client"),
+		}, nil
 	}
 
-	res, err := c.GetOCMShareByToken(ctx, req)
+	err = s.callOCM(ctx, s.c.OCMShareProviderEndpoint, func() (err error) {
+		res, err = c.GetOCMShareByToken(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling GetOCMShareByToken")
+		return &ocm.GetOCMShareByTokenResponse{
+			Status: statusFromOCMError(ctx, err, "error calling GetOCMShareByToken"),
+		}, nil
 	}
 
 	return res, nil
 }
 
 // TODO(labkode): read GetShare comment.
-func (s *svc) ListOCMShares(ctx context.Context, req *ocm.ListOCMSharesRequest) (*ocm.ListOCMSharesResponse, error) {
+func (s *svc) ListOCMShares(ctx context.Context, req *ocm.ListOCMSharesRequest) (res *ocm.ListOCMSharesResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "ListOCMShares")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoint.String(s.c.OCMShareProviderEndpoint))
 
 	c, err := pool.GetOCMShareProviderClient(ctx, pool.Endpoint(s.c.OCMShareProviderEndpoint))
 	if err != nil {
@@ -135,17 +167,24 @@ func (s *svc) ListOCMShares(ctx context.Context, req *ocm.ListOCMSharesRequest)
 		}, nil
 	}
 
-	res, err := c.ListOCMShares(ctx, req)
+	err = s.callOCM(ctx, s.c.OCMShareProviderEndpoint, func() (err error) {
+		res, err = c.ListOCMShares(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling ListShares")
+		return &ocm.ListOCMSharesResponse{
+			Status: statusFromOCMError(ctx, err, "error calling ListShares"),
+		}, nil
 	}
 
 	return res, nil
 }
 
-func (s *svc) UpdateOCMShare(ctx context.Context, req *ocm.UpdateOCMShareRequest) (*ocm.UpdateOCMShareResponse, error) {
+func (s *svc) UpdateOCMShare(ctx context.Context, req *ocm.UpdateOCMShareRequest) (res *ocm.UpdateOCMShareResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "UpdateOCMShare")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoint.String(s.c.OCMShareProviderEndpoint))
+	span.SetAttributes(shareRefAttributes(req.GetRef())...)
 
 	c, err := pool.GetOCMShareProviderClient(ctx, pool.Endpoint(s.c.OCMShareProviderEndpoint))
 	if err != nil {
@@ -155,17 +194,23 @@ func (s *svc) UpdateOCMShare(ctx context.Context, req *ocm.UpdateOCMShareRequest
 		}, nil
 	}
 
-	res, err := c.UpdateOCMShare(ctx, req)
+	err = s.callOCM(ctx, s.c.OCMShareProviderEndpoint, func() (err error) {
+		res, err = c.UpdateOCMShare(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling UpdateShare")
+		return &ocm.UpdateOCMShareResponse{
+			Status: statusFromOCMError(ctx, err, "error calling UpdateShare"),
+		}, nil
 	}
 
 	return res, nil
 }
 
-func (s *svc) ListReceivedOCMShares(ctx context.Context, req *ocm.ListReceivedOCMSharesRequest) (*ocm.ListReceivedOCMSharesResponse, error) {
-	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "IsProviderAllowed")
-	defer span.End()
+func (s *svc) ListReceivedOCMShares(ctx context.Context, req *ocm.ListReceivedOCMSharesRequest) (res *ocm.ListReceivedOCMSharesResponse, err error) {
+	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "ListReceivedOCMShares")
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoint.String(s.c.OCMShareProviderEndpoint))
 
 	c, err := pool.GetOCMShareProviderClient(ctx, pool.Endpoint(s.c.OCMShareProviderEndpoint))
 	if err != nil {
@@ -175,17 +220,23 @@ func (s *svc) ListReceivedOCMShares(ctx context.Context, req *ocm.ListReceivedOC
 		}, nil
 	}
 
-	res, err := c.ListReceivedOCMShares(ctx, req)
+	err = s.callOCM(ctx, s.c.OCMShareProviderEndpoint, func() (err error) {
+		res, err = c.ListReceivedOCMShares(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling ListReceivedShares")
+		return &ocm.ListReceivedOCMSharesResponse{
+			Status: statusFromOCMError(ctx, err, "error calling ListReceivedShares"),
+		}, nil
 	}
 
 	return res, nil
 }
 
-func (s *svc) UpdateReceivedOCMShare(ctx context.Context, req *ocm.UpdateReceivedOCMShareRequest) (*ocm.UpdateReceivedOCMShareResponse, error) {
+func (s *svc) UpdateReceivedOCMShare(ctx context.Context, req *ocm.UpdateReceivedOCMShareRequest) (res *ocm.UpdateReceivedOCMShareResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "UpdateReceivedOCMShare")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoint.String(s.c.OCMShareProviderEndpoint), attrShareID.String(req.GetShare().GetId().GetOpaqueId()))
 
 	log := appctx.GetLogger(ctx)
 	c, err := pool.GetOCMShareProviderClient(ctx, pool.Endpoint(s.c.OCMShareProviderEndpoint))
@@ -196,13 +247,13 @@ func (s *svc) UpdateReceivedOCMShare(ctx context.Context, req *ocm.UpdateReceive
 		}, nil
 	}
 
-	res, err := c.UpdateReceivedOCMShare(ctx, req)
+	err = s.callOCM(ctx, s.c.OCMShareProviderEndpoint, func() (err error) {
+		res, err = c.UpdateReceivedOCMShare(ctx, req)
+		return err
+	})
 	if err != nil {
-		log.Err(err).Msg("gateway: error calling UpdateReceivedShare")
 		return &ocm.UpdateReceivedOCMShareResponse{
-			Status: &rpc.Status{
-				Code: rpc.Code_CODE_INTERNAL,
-			},
+			Status: statusFromOCMError(ctx, err, "error calling UpdateReceivedOCMShare"),
 		}, nil
 	}
 
@@ -421,9 +472,11 @@ func isTransferShare(ctx context.Context, s *ocm.ReceivedShare) bool {
 	return ok
 }
 
-func (s *svc) GetReceivedOCMShare(ctx context.Context, req *ocm.GetReceivedOCMShareRequest) (*ocm.GetReceivedOCMShareResponse, error) {
+func (s *svc) GetReceivedOCMShare(ctx context.Context, req *ocm.GetReceivedOCMShareRequest) (res *ocm.GetReceivedOCMShareResponse, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "GetReceivedOCMShare")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, res.GetStatus(), err) }()
+	span.SetAttributes(attrEndpoint.String(s.c.OCMShareProviderEndpoint))
+	span.SetAttributes(shareRefAttributes(req.GetRef())...)
 
 	c, err := pool.GetOCMShareProviderClient(ctx, pool.Endpoint(s.c.OCMShareProviderEndpoint))
 	if err != nil {
@@ -433,9 +486,14 @@ func (s *svc) GetReceivedOCMShare(ctx context.Context, req *ocm.GetReceivedOCMSh
 		}, nil
 	}
 
-	res, err := c.GetReceivedOCMShare(ctx, req)
+	err = s.callOCM(ctx, s.c.OCMShareProviderEndpoint, func() (err error) {
+		res, err = c.GetReceivedOCMShare(ctx, req)
+		return err
+	})
 	if err != nil {
-		return nil, errors.Wrap(err, "gateway: error calling GetReceivedShare")
+		return &ocm.GetReceivedOCMShareResponse{
+			Status: statusFromOCMError(ctx, err, "error calling GetReceivedShare"),
+		}, nil
 	}
 
 	return res, nil
@@ -453,9 +511,10 @@ func getTransferProtocol(ctx context.Context, share *ocm.ReceivedShare) (*ocm.Tr
 	return nil, false
 }
 
-func (s *svc) createOCMReference(ctx context.Context, share *ocm.ReceivedShare) (*rpc.Status, error) {
+func (s *svc) createOCMReference(ctx context.Context, share *ocm.ReceivedShare) (st *rpc.Status, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "createOCMReference")
-	defer span.End()
+	defer func() { tracing.EndSpanWithStatus(span, st, err) }()
+	span.SetAttributes(attrShareID.String(share.GetId().GetOpaqueId()))
 
 	log := appctx.GetLogger(ctx)
 
@@ -0,0 +1,55 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// The attributes set on the spans of the OCM calls.
+const (
+	attrEndpoint        = attribute.Key("ocm.endpoint")
+	attrEndpoints       = attribute.Key("ocm.endpoints")
+	attrShareID         = attribute.Key("ocm.share_id")
+	attrShareTokenHash  = attribute.Key("ocm.share_token_hash")
+	attrResourceID      = attribute.Key("ocm.resource_id")
+	attrInviteTokenHash = attribute.Key("ocm.invite_token_hash")
+)
+
+// shareRefAttributes returns the attributes identifying the referenced share.
+// The tokens are only recorded hashed.
+func shareRefAttributes(ref *ocm.ShareReference) []attribute.KeyValue {
+	switch {
+	case ref.GetId() != nil:
+		return []attribute.KeyValue{attrShareID.String(ref.GetId().GetOpaqueId())}
+	case ref.GetKey() != nil:
+		return []attribute.KeyValue{resourceIDAttribute(ref.GetKey().GetResourceId())}
+	case ref.GetToken() != "":
+		return []attribute.KeyValue{tracing.Hashed(attrShareTokenHash, ref.GetToken())}
+	}
+	return nil
+}
+
+// resourceIDAttribute returns the attribute identifying the shared resource.
+func resourceIDAttribute(id *provider.ResourceId) attribute.KeyValue {
+	return attrResourceID.String(id.GetStorageId() + "!" + id.GetOpaqueId())
+}
@@ -0,0 +1,131 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	invitepb "github.com/cs3org/go-cs3apis/cs3/ocm/invite/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/ocm/invite"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	grpcstatus "google.golang.org/grpc/status"
+)
+
+// recordSpans returns a context whose spans are recorded in the returned exporter.
+func recordSpans(t *testing.T) (context.Context, *tracetest.InMemoryExporter) {
+	rec := tracetest.NewInMemoryExporter()
+	tp := tracesdk.NewTracerProvider(tracesdk.WithSyncer(rec))
+	ctx, span := tp.Tracer("test").Start(context.Background(), "test")
+	t.Cleanup(func() { span.End() })
+	return ctx, rec
+}
+
+func recordedSpan(t *testing.T, rec *tracetest.InMemoryExporter, name string) tracetest.SpanStub {
+	for _, s := range rec.GetSpans() {
+		if s.Name == name {
+			return s
+		}
+	}
+	t.Fatalf("expected span %s to be recorded", name)
+	return tracetest.SpanStub{}
+}
+
+func spanAttributes(s tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, a := range s.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	return attrs
+}
+
+func TestOCMSpanErrors(t *testing.T) {
+	router, err := newOCMRouter(nil, testEndpoint, testEndpoint)
+	if err != nil {
+		t.Fatalf("not expected error creating the router: %+v", err)
+	}
+	s := &svc{
+		c:                 &config{OCMInviteManagerEndpoint: testEndpoint},
+		ocmRouter:         router,
+		ocmCircuitBreaker: newCircuitBreaker(1, time.Minute),
+	}
+
+	// the failures reported in the status of the response are recorded
+	ctx, rec := recordSpans(t)
+	md := metadata.Pairs(invite.TokensStateHeader, "revoked")
+	ctx = grpc.NewContextWithServerTransportStream(metadata.NewIncomingContext(ctx, md), &fakeServerStream{})
+	res, err := s.ListInviteTokens(ctx, &invitepb.ListInviteTokensRequest{})
+	if err != nil {
+		t.Fatalf("not expected error listing the tokens: %+v", err)
+	}
+	assert.Equal(t, rpc.Code_CODE_INVALID_ARGUMENT, res.Status.Code)
+
+	span := recordedSpan(t, rec, "ListInviteTokens")
+	assert.Equal(t, codes.Error, span.Status.Code)
+	assert.Contains(t, span.Status.Description, "CODE_INVALID_ARGUMENT")
+	assert.Equal(t, []string{testEndpoint}, spanAttributes(span)[attrEndpoints].AsStringSlice())
+
+	// the downstream endpoints and the hash of the token are recorded
+	ctx, rec = recordSpans(t)
+	s.ocmCircuitBreaker.done(ctx, testEndpoint, grpcstatus.Error(grpccodes.Unavailable, "connection refused"))
+	acceptRes, err := s.AcceptInvite(ctx, &invitepb.AcceptInviteRequest{InviteToken: &invitepb.InviteToken{Token: "secret-token"}})
+	if err != nil {
+		t.Fatalf("not expected error accepting the invite: %+v", err)
+	}
+	assert.Equal(t, rpc.Code_CODE_UNAVAILABLE, acceptRes.Status.Code)
+
+	span = recordedSpan(t, rec, "AcceptInvite")
+	assert.Equal(t, codes.Error, span.Status.Code)
+	attrs := spanAttributes(span)
+	assert.Equal(t, []string{testEndpoint}, attrs[attrEndpoints].AsStringSlice())
+	assert.NotEmpty(t, attrs[attrInviteTokenHash].AsString())
+	assert.NotContains(t, attrs[attrInviteTokenHash].AsString(), "secret")
+	if assert.NotEmpty(t, span.Events) {
+		assert.Equal(t, "exception", span.Events[0].Name)
+	}
+}
+
+func TestShareRefAttributes(t *testing.T) {
+	attrs := shareRefAttributes(&ocm.ShareReference{Spec: &ocm.ShareReference_Id{Id: &ocm.ShareId{OpaqueId: "42"}}})
+	assert.Equal(t, []attribute.KeyValue{attrShareID.String("42")}, attrs)
+
+	attrs = shareRefAttributes(&ocm.ShareReference{Spec: &ocm.ShareReference_Key{Key: &ocm.ShareKey{
+		ResourceId: &provider.ResourceId{StorageId: "storage", OpaqueId: "10"},
+	}}})
+	assert.Equal(t, []attribute.KeyValue{attrResourceID.String("storage!10")}, attrs)
+
+	attrs = shareRefAttributes(&ocm.ShareReference{Spec: &ocm.ShareReference_Token{Token: "secret-token"}})
+	if assert.Len(t, attrs, 1) {
+		assert.Equal(t, attrShareTokenHash, attrs[0].Key)
+		assert.NotContains(t, attrs[0].Value.AsString(), "secret")
+	}
+
+	assert.Empty(t, shareRefAttributes(nil))
+}
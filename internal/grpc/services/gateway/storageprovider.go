@@ -91,7 +91,7 @@ func (s *svc) CreateHome(ctx context.Context, req *provider.CreateHomeRequest) (
 	c, err := s.findByPath(ctx, home)
 	if err != nil {
 		return &provider.CreateHomeResponse{
-			Status: status.NewStatusFromErrType(ctx, "error finding home", err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: error finding home", err),
 		}, nil
 	}
 
@@ -114,7 +114,7 @@ func (s *svc) CreateStorageSpace(ctx context.Context, req *provider.CreateStorag
 	c, err := s.findByPath(ctx, "/users")
 	if err != nil {
 		return &provider.CreateStorageSpaceResponse{
-			Status: status.NewStatusFromErrType(ctx, "error finding path", err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: error finding path", err),
 		}, nil
 	}
 
@@ -165,7 +165,7 @@ func (s *svc) ListStorageSpaces(ctx context.Context, req *provider.ListStorageSp
 		})
 		if err != nil {
 			return &provider.ListStorageSpacesResponse{
-				Status: status.NewStatusFromErrType(ctx, "ListStorageSpaces filters: req "+req.String(), err),
+				Status: status.NewStatusFromErrType(ctx, "gateway: ListStorageSpaces filters: req "+req.String(), err),
 			}, nil
 		}
 		if res.Status.Code != rpc.Code_CODE_OK {
@@ -180,7 +180,7 @@ func (s *svc) ListStorageSpaces(ctx context.Context, req *provider.ListStorageSp
 
 		if err != nil {
 			return &provider.ListStorageSpacesResponse{
-				Status: status.NewStatusFromErrType(ctx, "error listing providers", err),
+				Status: status.NewStatusFromErrType(ctx, "gateway: error listing providers", err),
 			}, nil
 		}
 		if res.Status.Code != rpc.Code_CODE_OK {
@@ -219,7 +219,7 @@ func (s *svc) ListStorageSpaces(ctx context.Context, req *provider.ListStorageSp
 				continue
 			}
 			return &provider.ListStorageSpacesResponse{
-				Status: status.NewStatusFromErrType(ctx, "error listing space", errors[i]),
+				Status: status.NewStatusFromErrType(ctx, "gateway: error listing space", errors[i]),
 			}, nil
 		}
 		for j := range spacesFromProviders[i] {
@@ -271,7 +271,7 @@ func (s *svc) UpdateStorageSpace(ctx context.Context, req *provider.UpdateStorag
 	c, err := s.find(ctx, &provider.Reference{ResourceId: req.StorageSpace.Root})
 	if err != nil {
 		return &provider.UpdateStorageSpaceResponse{
-			Status: status.NewStatusFromErrType(ctx, "error finding ID", err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: error finding ID", err),
 		}, nil
 	}
 
@@ -303,7 +303,7 @@ func (s *svc) DeleteStorageSpace(ctx context.Context, req *provider.DeleteStorag
 	}})
 	if err != nil {
 		return &provider.DeleteStorageSpaceResponse{
-			Status: status.NewStatusFromErrType(ctx, "error finding path", err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: error finding path", err),
 		}, nil
 	}
 
@@ -402,7 +402,7 @@ func (s *svc) InitiateFileDownload(ctx context.Context, req *provider.InitiateFi
 		ri, protocol, err := s.checkRef(ctx, statRes.Info)
 		if err != nil {
 			return &gateway.InitiateFileDownloadResponse{
-				Status: status.NewStatusFromErrType(ctx, "error resolving reference "+statRes.Info.Target, err),
+				Status: status.NewStatusFromErrType(ctx, "gateway: error resolving reference "+statRes.Info.Target, err),
 			}, nil
 		}
 
@@ -466,7 +466,7 @@ func (s *svc) InitiateFileDownload(ctx context.Context, req *provider.InitiateFi
 		ri, protocol, err := s.checkRef(ctx, statRes.Info)
 		if err != nil {
 			return &gateway.InitiateFileDownloadResponse{
-				Status: status.NewStatusFromErrType(ctx, "error resolving reference "+statRes.Info.Target, err),
+				Status: status.NewStatusFromErrType(ctx, "gateway: error resolving reference "+statRes.Info.Target, err),
 			}, nil
 		}
 
@@ -508,7 +508,7 @@ func (s *svc) initiateFileDownload(ctx context.Context, req *provider.InitiateFi
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &gateway.InitiateFileDownloadResponse{
-			Status: status.NewStatusFromErrType(ctx, "error initiating download ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: error initiating download ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -612,7 +612,7 @@ func (s *svc) InitiateFileUpload(ctx context.Context, req *provider.InitiateFile
 		ri, protocol, err := s.checkRef(ctx, statRes.Info)
 		if err != nil {
 			return &gateway.InitiateFileUploadResponse{
-				Status: status.NewStatusFromErrType(ctx, "error resolving reference "+statRes.Info.Target, err),
+				Status: status.NewStatusFromErrType(ctx, "gateway: error resolving reference "+statRes.Info.Target, err),
 			}, nil
 		}
 
@@ -673,7 +673,7 @@ func (s *svc) InitiateFileUpload(ctx context.Context, req *provider.InitiateFile
 		ri, protocol, err := s.checkRef(ctx, statRes.Info)
 		if err != nil {
 			return &gateway.InitiateFileUploadResponse{
-				Status: status.NewStatusFromErrType(ctx, "error resolving reference "+statRes.Info.Target, err),
+				Status: status.NewStatusFromErrType(ctx, "gateway: error resolving reference "+statRes.Info.Target, err),
 			}, nil
 		}
 
@@ -713,7 +713,7 @@ func (s *svc) initiateFileUpload(ctx context.Context, req *provider.InitiateFile
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &gateway.InitiateFileUploadResponse{
-			Status: status.NewStatusFromErrType(ctx, "initiateFileUpload ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: initiateFileUpload ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -844,7 +844,7 @@ func (s *svc) CreateContainer(ctx context.Context, req *provider.CreateContainer
 		ri, protocol, err := s.checkRef(ctx, statRes.Info)
 		if err != nil {
 			return &provider.CreateContainerResponse{
-				Status: status.NewStatusFromErrType(ctx, "error resolving reference "+statRes.Info.Target, err),
+				Status: status.NewStatusFromErrType(ctx, "gateway: error resolving reference "+statRes.Info.Target, err),
 			}, nil
 		}
 
@@ -875,7 +875,7 @@ func (s *svc) createContainer(ctx context.Context, req *provider.CreateContainer
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &provider.CreateContainerResponse{
-			Status: status.NewStatusFromErrType(ctx, "createContainer ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: createContainer ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -897,7 +897,7 @@ func (s *svc) TouchFile(ctx context.Context, req *provider.TouchFileRequest) (*p
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &provider.TouchFileResponse{
-			Status: status.NewStatusFromErrType(ctx, "TouchFile ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: TouchFile ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -1024,7 +1024,7 @@ func (s *svc) Delete(ctx context.Context, req *provider.DeleteRequest) (*provide
 		ri, protocol, err := s.checkRef(ctx, statRes.Info)
 		if err != nil {
 			return &provider.DeleteResponse{
-				Status: status.NewStatusFromErrType(ctx, "error resolving reference "+statRes.Info.Target, err),
+				Status: status.NewStatusFromErrType(ctx, "gateway: error resolving reference "+statRes.Info.Target, err),
 			}, nil
 		}
 
@@ -1056,7 +1056,7 @@ func (s *svc) delete(ctx context.Context, req *provider.DeleteRequest) (*provide
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &provider.DeleteResponse{
-			Status: status.NewStatusFromErrType(ctx, "delete ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: delete ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -1137,7 +1137,7 @@ func (s *svc) Move(ctx context.Context, req *provider.MoveRequest) (*provider.Mo
 		srcRi, srcProtocol, err := s.checkRef(ctx, srcStatRes.Info)
 		if err != nil {
 			return &provider.MoveResponse{
-				Status: status.NewStatusFromErrType(ctx, "error resolving reference "+srcStatRes.Info.Target, err),
+				Status: status.NewStatusFromErrType(ctx, "gateway: error resolving reference "+srcStatRes.Info.Target, err),
 			}, nil
 		}
 
@@ -1155,7 +1155,7 @@ func (s *svc) Move(ctx context.Context, req *provider.MoveRequest) (*provider.Mo
 		dstRi, dstProtocol, err := s.checkRef(ctx, dstStatRes.Info)
 		if err != nil {
 			return &provider.MoveResponse{
-				Status: status.NewStatusFromErrType(ctx, "error resolving reference "+srcStatRes.Info.Target, err),
+				Status: status.NewStatusFromErrType(ctx, "gateway: error resolving reference "+srcStatRes.Info.Target, err),
 			}, nil
 		}
 
@@ -1185,7 +1185,7 @@ func (s *svc) Move(ctx context.Context, req *provider.MoveRequest) (*provider.Mo
 	}
 
 	return &provider.MoveResponse{
-		Status: status.NewStatusFromErrType(ctx, "move", errtypes.BadRequest("gateway: move called on unknown path: "+p)),
+		Status: status.NewStatusFromErrType(ctx, "gateway: move", errtypes.BadRequest("gateway: move called on unknown path: "+p)),
 	}, nil
 }
 
@@ -1196,14 +1196,14 @@ func (s *svc) move(ctx context.Context, req *provider.MoveRequest) (*provider.Mo
 	srcProviders, err := s.findProviders(ctx, req.Source)
 	if err != nil {
 		return &provider.MoveResponse{
-			Status: status.NewStatusFromErrType(ctx, "move src="+req.Source.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: move src="+req.Source.String(), err),
 		}, nil
 	}
 
 	dstProviders, err := s.findProviders(ctx, req.Destination)
 	if err != nil {
 		return &provider.MoveResponse{
-			Status: status.NewStatusFromErrType(ctx, "move dst="+req.Destination.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: move dst="+req.Destination.String(), err),
 		}, nil
 	}
 
@@ -1243,7 +1243,7 @@ func (s *svc) SetArbitraryMetadata(ctx context.Context, req *provider.SetArbitra
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &provider.SetArbitraryMetadataResponse{
-			Status: status.NewStatusFromErrType(ctx, "SetArbitraryMetadata ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: SetArbitraryMetadata ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -1266,7 +1266,7 @@ func (s *svc) UnsetArbitraryMetadata(ctx context.Context, req *provider.UnsetArb
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &provider.UnsetArbitraryMetadataResponse{
-			Status: status.NewStatusFromErrType(ctx, "UnsetArbitraryMetadata ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: UnsetArbitraryMetadata ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -1289,7 +1289,7 @@ func (s *svc) SetLock(ctx context.Context, req *provider.SetLockRequest) (*provi
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &provider.SetLockResponse{
-			Status: status.NewStatusFromErrType(ctx, "SetLock ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: SetLock ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -1312,7 +1312,7 @@ func (s *svc) GetLock(ctx context.Context, req *provider.GetLockRequest) (*provi
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &provider.GetLockResponse{
-			Status: status.NewStatusFromErrType(ctx, "GetLock ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: GetLock ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -1335,7 +1335,7 @@ func (s *svc) RefreshLock(ctx context.Context, req *provider.RefreshLockRequest)
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &provider.RefreshLockResponse{
-			Status: status.NewStatusFromErrType(ctx, "RefreshLock ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: RefreshLock ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -1358,7 +1358,7 @@ func (s *svc) Unlock(ctx context.Context, req *provider.UnlockRequest) (*provide
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &provider.UnlockResponse{
-			Status: status.NewStatusFromErrType(ctx, "Unlock ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: Unlock ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -1471,7 +1471,7 @@ func (s *svc) stat(ctx context.Context, req *provider.StatRequest) (*provider.St
 	providers, err := s.findProviders(ctx, req.Ref)
 	if err != nil {
 		return &provider.StatResponse{
-			Status: status.NewStatusFromErrType(ctx, "stat ref: "+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: stat ref: "+req.Ref.String(), err),
 		}, nil
 	}
 	providers = s.getUniqueProviders(ctx, providers)
@@ -1612,7 +1612,7 @@ func (s *svc) Stat(ctx context.Context, req *provider.StatRequest) (*provider.St
 		ri, protocol, err := s.checkRef(ctx, res.Info)
 		if err != nil {
 			return &provider.StatResponse{
-				Status: status.NewStatusFromErrType(ctx, "error resolving reference "+res.Info.Target, err),
+				Status: status.NewStatusFromErrType(ctx, "gateway: error resolving reference "+res.Info.Target, err),
 			}, nil
 		}
 
@@ -1655,7 +1655,7 @@ func (s *svc) Stat(ctx context.Context, req *provider.StatRequest) (*provider.St
 		ri, protocol, err := s.checkRef(ctx, statRes.Info)
 		if err != nil {
 			return &provider.StatResponse{
-				Status: status.NewStatusFromErrType(ctx, "error resolving reference "+statRes.Info.Target, err),
+				Status: status.NewStatusFromErrType(ctx, "gateway: error resolving reference "+statRes.Info.Target, err),
 			}, nil
 		}
 
@@ -1837,7 +1837,7 @@ func (s *svc) listSharesFolder(ctx context.Context) (*provider.ListContainerResp
 			// create status to log the proper messages
 			// this might arise when the shared resource has been moved to the recycle bin
 			// this might arise when the resource was unshared, but the share reference was not removed
-			status.NewStatusFromErrType(ctx, "error resolving reference "+lcr.Infos[i].Target, err)
+			status.NewStatusFromErrType(ctx, "gateway: error resolving reference "+lcr.Infos[i].Target, err)
 			// continue on errors so the user can see a list of the working shares
 			continue
 		}
@@ -1901,7 +1901,7 @@ func (s *svc) listContainer(ctx context.Context, req *provider.ListContainerRequ
 	providers, err := s.findProviders(ctx, req.Ref)
 	if err != nil {
 		return &provider.ListContainerResponse{
-			Status: status.NewStatusFromErrType(ctx, "listContainer ref: "+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: listContainer ref: "+req.Ref.String(), err),
 		}, nil
 	}
 	providers = s.getUniqueProviders(ctx, providers)
@@ -2033,7 +2033,7 @@ func (s *svc) ListContainer(ctx context.Context, req *provider.ListContainerRequ
 		ri, protocol, err := s.checkRef(ctx, statRes.Info)
 		if err != nil {
 			return &provider.ListContainerResponse{
-				Status: status.NewStatusFromErrType(ctx, "error resolving reference "+statRes.Info.Target, err),
+				Status: status.NewStatusFromErrType(ctx, "gateway: error resolving reference "+statRes.Info.Target, err),
 			}, nil
 		}
 
@@ -2109,7 +2109,7 @@ func (s *svc) ListContainer(ctx context.Context, req *provider.ListContainerRequ
 		ri, protocol, err := s.checkRef(ctx, statRes.Info)
 		if err != nil {
 			return &provider.ListContainerResponse{
-				Status: status.NewStatusFromErrType(ctx, "error resolving reference "+statRes.Info.Target, err),
+				Status: status.NewStatusFromErrType(ctx, "gateway: error resolving reference "+statRes.Info.Target, err),
 			}, nil
 		}
 
@@ -2177,7 +2177,7 @@ func (s *svc) getPath(ctx context.Context, ref *provider.Reference, keys ...stri
 		req := &provider.StatRequest{Ref: ref, ArbitraryMetadataKeys: keys}
 		res, err := s.stat(ctx, req)
 		if err != nil {
-			return "", status.NewStatusFromErrType(ctx, "getPath ref="+ref.String(), err)
+			return "", status.NewStatusFromErrType(ctx, "gateway: getPath ref="+ref.String(), err)
 		}
 		if res != nil && res.Status.Code != rpc.Code_CODE_OK {
 			return "", res.Status
@@ -2277,7 +2277,7 @@ func (s *svc) ListFileVersions(ctx context.Context, req *provider.ListFileVersio
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &provider.ListFileVersionsResponse{
-			Status: status.NewStatusFromErrType(ctx, "ListFileVersions ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: ListFileVersions ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -2296,7 +2296,7 @@ func (s *svc) RestoreFileVersion(ctx context.Context, req *provider.RestoreFileV
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &provider.RestoreFileVersionResponse{
-			Status: status.NewStatusFromErrType(ctx, "RestoreFileVersion ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: RestoreFileVersion ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -2320,7 +2320,7 @@ func (s *svc) ListRecycle(ctx context.Context, req *provider.ListRecycleRequest)
 	c, err := s.find(ctx, req.GetRef())
 	if err != nil {
 		return &provider.ListRecycleResponse{
-			Status: status.NewStatusFromErrType(ctx, "ListFileVersions ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: ListFileVersions ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -2339,7 +2339,7 @@ func (s *svc) RestoreRecycleItem(ctx context.Context, req *provider.RestoreRecyc
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &provider.RestoreRecycleItemResponse{
-			Status: status.NewStatusFromErrType(ctx, "RestoreRecycleItem ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: RestoreRecycleItem ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -2358,7 +2358,7 @@ func (s *svc) PurgeRecycle(ctx context.Context, req *provider.PurgeRecycleReques
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &provider.PurgeRecycleResponse{
-			Status: status.NewStatusFromErrType(ctx, "PurgeRecycle ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: PurgeRecycle ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -2376,7 +2376,7 @@ func (s *svc) GetQuota(ctx context.Context, req *gateway.GetQuotaRequest) (*prov
 	c, err := s.find(ctx, req.Ref)
 	if err != nil {
 		return &provider.GetQuotaResponse{
-			Status: status.NewStatusFromErrType(ctx, "GetQuota ref="+req.Ref.String(), err),
+			Status: status.NewStatusFromErrType(ctx, "gateway: GetQuota ref="+req.Ref.String(), err),
 		}, nil
 	}
 
@@ -74,16 +74,10 @@ func (s *svc) FindUsers(ctx context.Context, req *user.FindUsersRequest) (*user.
 	defer span.End()
 
 	if strings.HasPrefix(req.Filter, "sm:") {
-		c, err := pool.GetOCMInviteManagerClient(ctx, pool.Endpoint(s.c.OCMInviteManagerEndpoint))
-		if err != nil {
-			return &user.FindUsersResponse{
-				Status: status.NewInternal(ctx, err, "error getting auth client"),
-			}, nil
-		}
-
 		term := strings.TrimPrefix(req.Filter, "sm:")
 
-		res, err := c.FindAcceptedUsers(ctx, &invitepb.FindAcceptedUsersRequest{
+		// the accepted users are looked up in all the invite managers
+		res, err := s.FindAcceptedUsers(ctx, &invitepb.FindAcceptedUsersRequest{
 			Filter: term,
 		})
 		if err != nil {
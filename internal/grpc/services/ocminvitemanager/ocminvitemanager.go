@@ -26,6 +26,7 @@ import (
 	invitepb "github.com/cs3org/go-cs3apis/cs3/ocm/invite/v1beta1"
 	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
 	rpcv1beta1 "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
 	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/errtypes"
 	"github.com/cs3org/reva/pkg/ocm/client"
@@ -163,13 +164,27 @@ func (s *service) ListInviteTokens(ctx context.Context, req *invitepb.ListInvite
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "ListInviteTokens")
 	defer span.End()
 
+	opts, err := invite.ContextGetListTokensOptions(ctx)
+	if err != nil {
+		return &invitepb.ListInviteTokensResponse{
+			Status: status.NewInvalid(ctx, err.Error()),
+		}, nil
+	}
+
 	user := ctxpkg.ContextMustGetUser(ctx)
-	tokens, err := s.repo.ListTokens(ctx, user.Id)
+	tokens, next, err := s.repo.ListTokens(ctx, user.Id, opts)
 	if err != nil {
 		return &invitepb.ListInviteTokensResponse{
 			Status: status.NewInternal(ctx, err, "error listing tokens"),
 		}, nil
 	}
+	if next != "" {
+		if err := grpc.SetHeader(ctx, invite.EncodeNextCursor(next)); err != nil {
+			return &invitepb.ListInviteTokensResponse{
+				Status: status.NewInternal(ctx, err, "error sending the continuation token"),
+			}, nil
+		}
+	}
 	return &invitepb.ListInviteTokensResponse{
 		Status:       status.NewOK(ctx),
 		InviteTokens: tokens,
@@ -296,6 +311,11 @@ func (s *service) AcceptInvite(ctx context.Context, req *invitepb.AcceptInviteRe
 		}, nil
 	}
 
+	if err := s.repo.AcceptToken(ctx, token.GetToken()); err != nil {
+		// the remote user is already added, so the invite is accepted anyway
+		appctx.GetLogger(ctx).Error().Err(err).Str("token", token.GetToken()).Msg("error marking the token as accepted")
+	}
+
 	return &invitepb.AcceptInviteResponse{
 		Status:      status.NewOK(ctx),
 		UserId:      initiator.GetId(),
@@ -338,6 +358,11 @@ func (s *service) GetAcceptedUser(ctx context.Context, req *invitepb.GetAccepted
 	}
 	remoteUser, err := s.repo.GetRemoteUser(ctx, user.GetId(), req.GetRemoteUserId())
 	if err != nil {
+		if _, ok := err.(errtypes.NotFound); ok {
+			return &invitepb.GetAcceptedUserResponse{
+				Status: status.NewNotFound(ctx, "remote user not found"),
+			}, nil
+		}
 		return &invitepb.GetAcceptedUserResponse{
 			Status: status.NewInternal(ctx, err, "error fetching remote user details"),
 		}, nil
@@ -375,6 +400,13 @@ func (s *service) FindAcceptedUsers(ctx context.Context, req *invitepb.FindAccep
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "FindAcceptedUsers")
 	defer span.End()
 
+	page, err := invite.GetPage(req)
+	if err != nil {
+		return &invitepb.FindAcceptedUsersResponse{
+			Status: status.NewInvalid(ctx, err.Error()),
+		}, nil
+	}
+
 	user := ctxpkg.ContextMustGetUser(ctx)
 	acceptedUsers, err := s.repo.FindRemoteUsers(ctx, user.GetId(), req.GetFilter())
 	if err != nil {
@@ -383,8 +415,10 @@ func (s *service) FindAcceptedUsers(ctx context.Context, req *invitepb.FindAccep
 		}, nil
 	}
 
-	return &invitepb.FindAcceptedUsersResponse{
+	res := &invitepb.FindAcceptedUsersResponse{
 		Status:        status.NewOK(ctx),
-		AcceptedUsers: acceptedUsers,
-	}, nil
+		AcceptedUsers: invite.Paginate(acceptedUsers, page),
+	}
+	invite.SetPaginated(res)
+	return res, nil
 }
@@ -114,7 +114,7 @@ func (s *service) GetInfoByDomain(ctx context.Context, req *ocmprovider.GetInfoB
 	domainInfo, err := s.pa.GetInfoByDomain(ctx, req.Domain)
 	if err != nil {
 		return &ocmprovider.GetInfoByDomainResponse{
-			Status: status.NewInternal(ctx, err, "error getting provider info"),
+			Status: status.NewStatusFromErrType(ctx, "error getting provider info", err),
 		}, nil
 	}
 
@@ -128,10 +128,20 @@ func (s *service) IsProviderAllowed(ctx context.Context, req *ocmprovider.IsProv
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "IsProviderAllowed")
 	defer span.End()
 
-	err := s.pa.IsProviderAllowed(ctx, req.Provider)
+	providers, batch, err := provider.GetProviders(req)
 	if err != nil {
 		return &ocmprovider.IsProviderAllowedResponse{
-			Status: status.NewInternal(ctx, err, "error verifying mesh provider"),
+			Status: status.NewStatusFromErrType(ctx, "error reading the providers to verify", err),
+		}, nil
+	}
+	if batch {
+		return s.areProvidersAllowed(ctx, providers), nil
+	}
+
+	err = s.pa.IsProviderAllowed(ctx, req.Provider)
+	if err != nil {
+		return &ocmprovider.IsProviderAllowedResponse{
+			Status: status.NewStatusFromErrType(ctx, "error verifying mesh provider", err),
 		}, nil
 	}
 
@@ -140,6 +150,27 @@ func (s *service) IsProviderAllowed(ctx context.Context, req *ocmprovider.IsProv
 	}, nil
 }
 
+// areProvidersAllowed verifies several providers in one call, returning the
+// per-provider results in the opaque of the response.
+func (s *service) areProvidersAllowed(ctx context.Context, providers []*ocmprovider.ProviderInfo) *ocmprovider.IsProviderAllowedResponse {
+	errs, err := s.pa.AreProvidersAllowed(ctx, providers)
+	if err != nil {
+		return &ocmprovider.IsProviderAllowedResponse{
+			Status: status.NewStatusFromErrType(ctx, "error verifying mesh providers", err),
+		}
+	}
+
+	res := &ocmprovider.IsProviderAllowedResponse{
+		Status: status.NewOK(ctx),
+	}
+	if err := provider.SetProvidersAllowed(res, providers, errs); err != nil {
+		return &ocmprovider.IsProviderAllowedResponse{
+			Status: status.NewInternal(ctx, err, "error encoding the verification of the mesh providers"),
+		}
+	}
+	return res
+}
+
 func (s *service) ListAllProviders(ctx context.Context, req *ocmprovider.ListAllProvidersRequest) (*ocmprovider.ListAllProvidersResponse, error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "ListAllProviders")
 	defer span.End()
@@ -147,7 +178,7 @@ func (s *service) ListAllProviders(ctx context.Context, req *ocmprovider.ListAll
 	providers, err := s.pa.ListAllProviders(ctx)
 	if err != nil {
 		return &ocmprovider.ListAllProvidersResponse{
-			Status: status.NewInternal(ctx, err, "error retrieving mesh providers"),
+			Status: status.NewStatusFromErrType(ctx, "error retrieving mesh providers", err),
 		}, nil
 	}
 
@@ -159,7 +159,12 @@ func (s *service) Close() error {
 }
 
 func (s *service) UnprotectedEndpoints() []string {
-	return []string{"/cs3.sharing.ocm.v1beta1.OcmAPI/GetOCMShareByToken"}
+	return []string{
+		"/cs3.sharing.ocm.v1beta1.OcmAPI/GetOCMShareByToken",
+		// removing a share by token is used by the remote provider
+		// to notify that a share was declined or unshared
+		"/cs3.sharing.ocm.v1beta1.OcmAPI/RemoveOCMShare",
+	}
 }
 
 func getOCMEndpoint(originProvider *ocmprovider.ProviderInfo) (string, error) {
@@ -353,8 +358,31 @@ func (s *service) RemoveOCMShare(ctx context.Context, req *ocm.RemoveOCMShareReq
 
 	// TODO (gdelmont): notify the remote provider using the /notification ocm endpoint
 	// https://cs3org.github.io/OCM-API/docs.html?branch=develop&repo=OCM-API&user=cs3org#/paths/~1notifications/post
-	user := ctxpkg.ContextMustGetUser(ctx)
-	if err := s.repo.DeleteShare(ctx, user, req.Ref); err != nil {
+	user, ok := ctxpkg.ContextGetUser(ctx)
+	ref := req.Ref
+	if !ok {
+		// without a user the share can only be removed by the ones knowing
+		// its token, that is the remote provider notifying the share removal
+		if ref.GetToken() == "" {
+			return &ocm.RemoveOCMShareResponse{
+				Status: status.NewUnauthenticated(ctx, errtypes.PermissionDenied("missing user"), "the share can only be removed by token"),
+			}, nil
+		}
+		ocmshare, err := s.repo.GetShare(ctx, nil, ref)
+		if err != nil {
+			if errors.Is(err, share.ErrShareNotFound) {
+				return &ocm.RemoveOCMShareResponse{
+					Status: status.NewNotFound(ctx, "share does not exist"),
+				}, nil
+			}
+			return &ocm.RemoveOCMShareResponse{
+				Status: status.NewInternal(ctx, err, "error getting share"),
+			}, nil
+		}
+		user = &userpb.User{Id: ocmshare.Owner}
+		ref = &ocm.ShareReference{Spec: &ocm.ShareReference_Id{Id: ocmshare.Id}}
+	}
+	if err := s.repo.DeleteShare(ctx, user, ref); err != nil {
 		if errors.Is(err, share.ErrShareNotFound) {
 			return &ocm.RemoveOCMShareResponse{
 				Status: status.NewNotFound(ctx, "share does not exist"),
@@ -20,10 +20,16 @@ package publicshareprovider
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"regexp"
+	"strconv"
+	"time"
 
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/pkg/appctx"
 	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/errtypes"
@@ -32,6 +38,7 @@ import (
 	"github.com/cs3org/reva/pkg/rgrpc"
 	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/tracing"
+	"github.com/cs3org/reva/pkg/utils"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	"google.golang.org/grpc"
@@ -47,12 +54,34 @@ type config struct {
 	Driver                string                            `mapstructure:"driver"`
 	Drivers               map[string]map[string]interface{} `mapstructure:"drivers"`
 	AllowedPathsForShares []string                          `mapstructure:"allowed_paths_for_shares"`
+	DefaultExpiration     string                            `mapstructure:"default_expiration" docs:";The expiration of the shares created without one, e.g. 168h. Empty for no expiration."`
+	MaxExpiration         string                            `mapstructure:"max_expiration" docs:";The maximum lifetime of the shares, e.g. 720h. Empty for no limit."`
+
+	defaultExpiration time.Duration
+	maxExpiration     time.Duration
 }
 
-func (c *config) init() {
+func (c *config) init() error {
 	if c.Driver == "" {
 		c.Driver = "json"
 	}
+
+	if c.DefaultExpiration != "" {
+		d, err := time.ParseDuration(c.DefaultExpiration)
+		if err != nil {
+			return errors.Wrap(err, "error parsing default_expiration")
+		}
+		c.defaultExpiration = d
+	}
+	if c.MaxExpiration != "" {
+		d, err := time.ParseDuration(c.MaxExpiration)
+		if err != nil {
+			return errors.Wrap(err, "error parsing max_expiration")
+		}
+		c.maxExpiration = d
+	}
+
+	return nil
 }
 
 type service struct {
@@ -71,6 +100,17 @@ func getShareManager(c *config) (publicshare.Manager, error) {
 
 // TODO(labkode): add ctx to Close.
 func (s *service) Close() error {
+	if c, ok := s.sm.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// Health reports the health of the share manager, if it supports it.
+func (s *service) Health(ctx context.Context) error {
+	if r, ok := s.sm.(rgrpc.HealthReporter); ok {
+		return r.Health(ctx)
+	}
 	return nil
 }
 func (s *service) UnprotectedEndpoints() []string {
@@ -97,7 +137,9 @@ func New(m map[string]interface{}, ss *grpc.Server) (rgrpc.Service, error) {
 		return nil, err
 	}
 
-	c.init()
+	if err := c.init(); err != nil {
+		return nil, err
+	}
 
 	sm, err := getShareManager(c)
 	if err != nil {
@@ -134,6 +176,28 @@ func (s *service) isPathAllowed(path string) bool {
 	return false
 }
 
+// expiration returns the expiration to give to a share, given the requested one.
+// On creation, the shares without expiration get the default one.
+// The expirations exceeding the maximum lifetime, including the missing ones,
+// are clamped to it.
+func (s *service) expiration(ctx context.Context, requested *typesv1beta1.Timestamp, create bool) *typesv1beta1.Timestamp {
+	now := time.Now()
+	if requested == nil && create && s.conf.defaultExpiration > 0 {
+		requested = &typesv1beta1.Timestamp{Seconds: uint64(now.Add(s.conf.defaultExpiration).Unix())}
+	}
+
+	if s.conf.maxExpiration > 0 {
+		limit := now.Add(s.conf.maxExpiration)
+		if requested == nil || utils.TSToTime(requested).After(limit) {
+			appctx.GetLogger(ctx).Info().Interface("requested", requested).Time("max", limit).
+				Msg("public share expiration exceeds the maximum lifetime, clamping it")
+			requested = &typesv1beta1.Timestamp{Seconds: uint64(limit.Unix())}
+		}
+	}
+
+	return requested
+}
+
 func (s *service) CreatePublicShare(ctx context.Context, req *link.CreatePublicShareRequest) (*link.CreatePublicShareResponse, error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "CreatePublicShare")
 	defer span.End()
@@ -152,6 +216,10 @@ func (s *service) CreatePublicShare(ctx context.Context, req *link.CreatePublicS
 		log.Error().Msg("error getting user from context")
 	}
 
+	if req.Grant != nil {
+		req.Grant.Expiration = s.expiration(ctx, req.Grant.Expiration, true)
+	}
+
 	share, err := s.sm.CreatePublicShare(ctx, u, req.ResourceInfo, req.Grant, req.Description, req.Internal)
 	switch err.(type) {
 	case nil:
@@ -167,6 +235,10 @@ func (s *service) CreatePublicShare(ctx context.Context, req *link.CreatePublicS
 		return &link.CreatePublicShareResponse{
 			Status: status.NewAlreadyExists(ctx, err, "share already exists"),
 		}, nil
+	case errtypes.PermissionDenied:
+		return &link.CreatePublicShareResponse{
+			Status: status.NewPermissionDenied(ctx, err, string(err.(errtypes.PermissionDenied))),
+		}, nil
 	default:
 		return &link.CreatePublicShareResponse{
 			Status: status.NewInternal(ctx, err, "unknown error"),
@@ -246,6 +318,7 @@ func (s *service) GetPublicShare(ctx context.Context, req *link.GetPublicShareRe
 	case nil:
 		return &link.GetPublicShareResponse{
 			Status: status.NewOK(ctx),
+			Opaque: s.lastAccessedOpaque(ctx, []*link.PublicShare{found}),
 			Share:  found,
 		}, nil
 	case errtypes.NotFound:
@@ -267,9 +340,42 @@ func (s *service) ListPublicShares(ctx context.Context, req *link.ListPublicShar
 	log.Info().Str("publicshareprovider", "list").Msg("list public share")
 	user, _ := ctxpkg.ContextGetUser(ctx)
 
+	order, err := getListOrder(req)
+	if err != nil {
+		return &link.ListPublicSharesResponse{
+			Status: status.NewInvalidArg(ctx, err.Error()),
+		}, nil
+	}
+	if order != nil {
+		ctx = publicshare.ContextSetListOrder(ctx, order)
+	}
+	if v, ok := req.Opaque.GetMap()["include_internal"]; ok {
+		include, err := strconv.ParseBool(string(v.Value))
+		if err != nil {
+			return &link.ListPublicSharesResponse{
+				Status: status.NewInvalidArg(ctx, "invalid include_internal value "+string(v.Value)),
+			}, nil
+		}
+		ctx = publicshare.ContextSetIncludeInternal(ctx, include)
+	}
+	window, err := getExpirationWindow(req)
+	if err != nil {
+		return &link.ListPublicSharesResponse{
+			Status: status.NewInvalidArg(ctx, err.Error()),
+		}, nil
+	}
+	if window != nil {
+		ctx = publicshare.ContextSetExpirationWindow(ctx, window)
+	}
+
 	shares, err := s.sm.ListPublicShares(ctx, user, req.Filters, &provider.ResourceInfo{}, req.GetSign())
 	if err != nil {
 		log.Err(err).Msg("error listing shares")
+		if _, ok := err.(errtypes.IsBadRequest); ok {
+			return &link.ListPublicSharesResponse{
+				Status: status.NewInvalidArg(ctx, err.Error()),
+			}, nil
+		}
 		return &link.ListPublicSharesResponse{
 			Status: status.NewInternal(ctx, err, "error listing public shares"),
 		}, nil
@@ -277,11 +383,122 @@ func (s *service) ListPublicShares(ctx context.Context, req *link.ListPublicShar
 
 	res := &link.ListPublicSharesResponse{
 		Status: status.NewOK(ctx),
+		Opaque: s.lastAccessedOpaque(ctx, shares),
 		Share:  shares,
 	}
 	return res, nil
 }
 
+// updatePublicShareFields applies at once the several updates of the request,
+// which are only accepted by the managers supporting them, limiting their
+// expiration to the configured maximum.
+func (s *service) updatePublicShareFields(ctx context.Context, u *userpb.User, req *link.UpdatePublicShareRequest) (*link.PublicShare, error) {
+	bu, ok := s.sm.(publicshare.BatchUpdater)
+	if !ok {
+		return nil, errtypes.BadRequest("the updates of several fields at once are not supported")
+	}
+	updates, err := publicshare.GetUpdates(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, upd := range updates {
+		if upd.GetType() == link.UpdatePublicShareRequest_Update_TYPE_EXPIRATION && upd.Grant != nil {
+			upd.Grant.Expiration = s.expiration(ctx, upd.Grant.Expiration, false)
+		}
+	}
+	return bu.UpdatePublicShareFields(ctx, u, req.Ref, updates)
+}
+
+// lastAccessedOpaque returns the opaque holding the time of the last access
+// to the shares, if the manager tracks it. The tracking is best-effort,
+// so errors are only logged.
+func (s *service) lastAccessedOpaque(ctx context.Context, shares []*link.PublicShare) *typesv1beta1.Opaque {
+	t, ok := s.sm.(publicshare.AccessTracker)
+	if !ok || len(shares) == 0 {
+		return nil
+	}
+
+	ids := make([]string, 0, len(shares))
+	for _, share := range shares {
+		ids = append(ids, share.GetId().GetOpaqueId())
+	}
+	accessed, err := t.LastAccessed(ctx, ids)
+	if err != nil {
+		appctx.GetLogger(ctx).Error().Err(err).Msg("error getting the last access time of the public shares")
+		return nil
+	}
+	if len(accessed) == 0 {
+		return nil
+	}
+
+	lastAccessed := make(map[string]int64, len(accessed))
+	for id, at := range accessed {
+		lastAccessed[id] = at.Unix()
+	}
+	v, err := json.Marshal(lastAccessed)
+	if err != nil {
+		appctx.GetLogger(ctx).Error().Err(err).Msg("error marshalling the last access time of the public shares")
+		return nil
+	}
+	return &typesv1beta1.Opaque{
+		Map: map[string]*typesv1beta1.OpaqueEntry{
+			publicshare.LastAccessedOpaqueKey: {Decoder: "json", Value: v},
+		},
+	}
+}
+
+// getListOrder reads the optional ordering of the shares from the request opaque,
+// set in the `order_by` and `order_direction` (asc or desc) keys.
+func getListOrder(req *link.ListPublicSharesRequest) (*publicshare.ListOrder, error) {
+	if req.Opaque == nil || req.Opaque.Map == nil {
+		return nil, nil
+	}
+	by, ok := req.Opaque.Map["order_by"]
+	if !ok {
+		return nil, nil
+	}
+	order := &publicshare.ListOrder{By: string(by.Value)}
+	if dir, ok := req.Opaque.Map["order_direction"]; ok {
+		switch string(dir.Value) {
+		case "", "asc":
+		case "desc":
+			order.Descending = true
+		default:
+			return nil, errtypes.BadRequest("invalid order direction " + string(dir.Value))
+		}
+	}
+	return order, nil
+}
+
+// getExpirationWindow returns the window, in unix seconds, in which the listed
+// shares have to expire, from the expiration_from and expiration_to opaque entries.
+func getExpirationWindow(req *link.ListPublicSharesRequest) (*publicshare.ExpirationWindow, error) {
+	from, hasFrom := req.Opaque.GetMap()["expiration_from"]
+	to, hasTo := req.Opaque.GetMap()["expiration_to"]
+	if !hasFrom && !hasTo {
+		return nil, nil
+	}
+	window := &publicshare.ExpirationWindow{}
+	if hasFrom {
+		sec, err := strconv.ParseInt(string(from.Value), 10, 64)
+		if err != nil {
+			return nil, errtypes.BadRequest("invalid expiration_from value " + string(from.Value))
+		}
+		window.From = time.Unix(sec, 0)
+	}
+	if hasTo {
+		sec, err := strconv.ParseInt(string(to.Value), 10, 64)
+		if err != nil {
+			return nil, errtypes.BadRequest("invalid expiration_to value " + string(to.Value))
+		}
+		window.To = time.Unix(sec, 0)
+	}
+	if hasFrom && hasTo && window.To.Before(window.From) {
+		return nil, errtypes.BadRequest("the expiration window ends before it starts")
+	}
+	return window, nil
+}
+
 func (s *service) UpdatePublicShare(ctx context.Context, req *link.UpdatePublicShareRequest) (*link.UpdatePublicShareResponse, error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "UpdatePublicShare")
 	defer span.End()
@@ -294,7 +511,16 @@ func (s *service) UpdatePublicShare(ctx context.Context, req *link.UpdatePublicS
 		log.Error().Msg("error getting user from context")
 	}
 
-	updated, err := s.sm.UpdatePublicShare(ctx, u, req, nil)
+	var updated *link.PublicShare
+	var err error
+	if _, ok := req.GetOpaque().GetMap()[publicshare.UpdatesOpaqueKey]; ok {
+		updated, err = s.updatePublicShareFields(ctx, u, req)
+	} else {
+		if req.Update.GetType() == link.UpdatePublicShareRequest_Update_TYPE_EXPIRATION && req.Update.Grant != nil {
+			req.Update.Grant.Expiration = s.expiration(ctx, req.Update.Grant.Expiration, false)
+		}
+		updated, err = s.sm.UpdatePublicShare(ctx, u, req, nil)
+	}
 	switch err.(type) {
 	case nil:
 		return &link.UpdatePublicShareResponse{
@@ -305,6 +531,10 @@ func (s *service) UpdatePublicShare(ctx context.Context, req *link.UpdatePublicS
 		return &link.UpdatePublicShareResponse{
 			Status: status.NewNotFound(ctx, "share not found"),
 		}, nil
+	case errtypes.BadRequest:
+		return &link.UpdatePublicShareResponse{
+			Status: status.NewInvalidArg(ctx, err.Error()),
+		}, nil
 	default:
 		return &link.UpdatePublicShareResponse{
 			Status: status.NewInternal(ctx, err, "unknown error"),
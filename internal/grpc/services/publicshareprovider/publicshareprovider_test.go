@@ -0,0 +1,213 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package publicshareprovider
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/publicshare"
+	_ "github.com/cs3org/reva/pkg/publicshare/manager/memory"
+)
+
+func TestExpiration(t *testing.T) {
+	day := uint64((24 * time.Hour).Seconds())
+
+	tests := []struct {
+		name      string
+		conf      map[string]interface{}
+		requested uint64 // seconds from now, 0 for no expiration
+		expected  uint64 // seconds from now, 0 for no expiration
+		updated   uint64 // seconds from now of the expiration set on update
+	}{
+		{
+			name: "unlimited",
+			conf: map[string]interface{}{},
+		},
+		{
+			name:     "omitted",
+			conf:     map[string]interface{}{"default_expiration": "168h", "max_expiration": "720h"},
+			expected: 7 * day,
+			updated:  30 * day,
+		},
+		{
+			name:      "within max",
+			conf:      map[string]interface{}{"default_expiration": "168h", "max_expiration": "720h"},
+			requested: 10 * day,
+			expected:  10 * day,
+			updated:   30 * day,
+		},
+		{
+			name:      "over max",
+			conf:      map[string]interface{}{"default_expiration": "168h", "max_expiration": "720h"},
+			requested: 60 * day,
+			expected:  30 * day,
+			updated:   30 * day,
+		},
+		{
+			name:     "omitted without default",
+			conf:     map[string]interface{}{"max_expiration": "720h"},
+			expected: 30 * day,
+			updated:  30 * day,
+		},
+	}
+
+	ctx := ctxpkg.ContextSetUser(context.Background(), &userpb.User{
+		Id: &userpb.UserId{Idp: "idp", OpaqueId: "einstein"},
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := map[string]interface{}{"driver": "memory"}
+			for k, v := range tt.conf {
+				conf[k] = v
+			}
+			svc, err := New(conf, nil)
+			if err != nil {
+				t.Fatalf("error creating the service: %v", err)
+			}
+			s := svc.(*service)
+
+			now := uint64(time.Now().Unix())
+			grant := &link.Grant{Permissions: &link.PublicSharePermissions{}}
+			if tt.requested != 0 {
+				grant.Expiration = &typesv1beta1.Timestamp{Seconds: now + tt.requested}
+			}
+
+			res, err := s.CreatePublicShare(ctx, &link.CreatePublicShareRequest{
+				ResourceInfo: &provider.ResourceInfo{
+					Id:                &provider.ResourceId{StorageId: "storage", OpaqueId: "file"},
+					ArbitraryMetadata: &provider.ArbitraryMetadata{},
+				},
+				Grant: grant,
+			})
+			if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+				t.Fatalf("error creating the share: %v %v", err, res.GetStatus())
+			}
+			checkExpiration(t, res.Share.Expiration, now, tt.expected)
+
+			// removing the expiration is subject to the max as well
+			updateRes, err := s.UpdatePublicShare(ctx, &link.UpdatePublicShareRequest{
+				Ref: &link.PublicShareReference{
+					Spec: &link.PublicShareReference_Token{Token: res.Share.Token},
+				},
+				Update: &link.UpdatePublicShareRequest_Update{
+					Type:  link.UpdatePublicShareRequest_Update_TYPE_EXPIRATION,
+					Grant: &link.Grant{},
+				},
+			})
+			if err != nil || updateRes.Status.Code != rpc.Code_CODE_OK {
+				t.Fatalf("error updating the share: %v %v", err, updateRes.GetStatus())
+			}
+			checkExpiration(t, updateRes.Share.Expiration, now, tt.updated)
+		})
+	}
+}
+
+func checkExpiration(t *testing.T, got *typesv1beta1.Timestamp, now, expected uint64) {
+	t.Helper()
+	if expected == 0 {
+		if got != nil {
+			t.Fatalf("expected no expiration, got %v", got)
+		}
+		return
+	}
+	// allow for a second elapsed between now and the call
+	if got == nil || got.Seconds < now+expected || got.Seconds > now+expected+1 {
+		t.Fatalf("expected expiration %d, got %v", now+expected, got)
+	}
+}
+
+func TestBatchUpdatesNotSupported(t *testing.T) {
+	ctx := ctxpkg.ContextSetUser(context.Background(), &userpb.User{
+		Id: &userpb.UserId{Idp: "idp", OpaqueId: "einstein"},
+	})
+	svc, err := New(map[string]interface{}{"driver": "memory"}, nil)
+	if err != nil {
+		t.Fatalf("error creating the service: %v", err)
+	}
+
+	req := &link.UpdatePublicShareRequest{
+		Ref: &link.PublicShareReference{Spec: &link.PublicShareReference_Token{Token: "token"}},
+	}
+	if err := publicshare.SetUpdates(req, []*link.UpdatePublicShareRequest_Update{
+		{Type: link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME, DisplayName: "renamed"},
+		{Type: link.UpdatePublicShareRequest_Update_TYPE_DESCRIPTION, Description: "described"},
+	}); err != nil {
+		t.Fatalf("error setting the updates: %v", err)
+	}
+
+	// the managers not supporting them do not silently apply only the first update
+	res, err := svc.(*service).UpdatePublicShare(ctx, req)
+	if err != nil || res.Status.Code != rpc.Code_CODE_INVALID_ARGUMENT {
+		t.Fatalf("expected invalid argument status, got %v %v", err, res.GetStatus())
+	}
+}
+
+// batchUpdater records the updates applied at once.
+type batchUpdater struct {
+	publicshare.Manager
+	updates []*link.UpdatePublicShareRequest_Update
+}
+
+func (b *batchUpdater) UpdatePublicShareFields(ctx context.Context, u *userpb.User, ref *link.PublicShareReference, updates []*link.UpdatePublicShareRequest_Update) (*link.PublicShare, error) {
+	b.updates = updates
+	return &link.PublicShare{}, nil
+}
+
+func TestBatchUpdates(t *testing.T) {
+	ctx := ctxpkg.ContextSetUser(context.Background(), &userpb.User{
+		Id: &userpb.UserId{Idp: "idp", OpaqueId: "einstein"},
+	})
+	svc, err := New(map[string]interface{}{"driver": "memory", "max_expiration": "720h"}, nil)
+	if err != nil {
+		t.Fatalf("error creating the service: %v", err)
+	}
+	s := svc.(*service)
+	bu := &batchUpdater{Manager: s.sm}
+	s.sm = bu
+
+	req := &link.UpdatePublicShareRequest{
+		Ref: &link.PublicShareReference{Spec: &link.PublicShareReference_Token{Token: "token"}},
+	}
+	if err := publicshare.SetUpdates(req, []*link.UpdatePublicShareRequest_Update{
+		{Type: link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME, DisplayName: "renamed"},
+		{Type: link.UpdatePublicShareRequest_Update_TYPE_EXPIRATION, Grant: &link.Grant{}},
+	}); err != nil {
+		t.Fatalf("error setting the updates: %v", err)
+	}
+
+	now := uint64(time.Now().Unix())
+	res, err := s.UpdatePublicShare(ctx, req)
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+		t.Fatalf("error updating the share: %v %v", err, res.GetStatus())
+	}
+	if len(bu.updates) != 2 || bu.updates[0].DisplayName != "renamed" {
+		t.Fatalf("expected the updates to be applied at once, got %v", bu.updates)
+	}
+	// the max expiration applies to the updates applied at once as well
+	checkExpiration(t, bu.updates[1].Grant.Expiration, now, 30*uint64((24*time.Hour).Seconds()))
+}
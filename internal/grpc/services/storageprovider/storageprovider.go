@@ -784,6 +784,9 @@ func (s *service) Delete(ctx context.Context, req *provider.DeleteRequest) (*pro
 			// it is a binary key; its existence signals true. Although, do not assume.
 			ctx = context.WithValue(ctx, appctx.DeletingSharedResource, true)
 		}
+		if _, ok := req.Opaque.Map["skip_trash"]; ok {
+			ctx = context.WithValue(ctx, appctx.SkipTrash, true)
+		}
 	}
 
 	if err := s.storage.Delete(ctx, newRef); err != nil {
@@ -0,0 +1,202 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package accesslog logs one structured line per HTTP request,
+// with the status, the size and the latency of the response.
+package accesslog
+
+import (
+	"bufio"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/rhttp/global"
+	"github.com/cs3org/reva/pkg/rhttp/utils"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+)
+
+const (
+	// the access log wraps the other configurable middlewares,
+	// so that their latency is accounted as well.
+	defaultPriority = 100
+)
+
+func init() {
+	global.RegisterMiddleware("accesslog", New)
+}
+
+type config struct {
+	Priority int `mapstructure:"priority"`
+	// SampleRate is the fraction of the requests that are logged, between 0 and 1.
+	SampleRate float64 `mapstructure:"sample_rate"`
+	// Services overrides the settings for the services, keyed by prefix.
+	Services map[string]serviceConfig `mapstructure:"services"`
+}
+
+type serviceConfig struct {
+	Disabled   bool    `mapstructure:"disabled"`
+	SampleRate float64 `mapstructure:"sample_rate"`
+}
+
+func (c *config) init() error {
+	if c.Priority == 0 {
+		c.Priority = defaultPriority
+	}
+	if c.SampleRate == 0 {
+		c.SampleRate = 1
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return errors.Errorf("accesslog: invalid sample rate %v", c.SampleRate)
+	}
+
+	for prefix, s := range c.Services {
+		if s.SampleRate == 0 {
+			s.SampleRate = c.SampleRate
+		}
+		if s.SampleRate < 0 || s.SampleRate > 1 {
+			return errors.Errorf("accesslog: invalid sample rate %v for service %s", s.SampleRate, prefix)
+		}
+		c.Services[prefix] = s
+	}
+	return nil
+}
+
+// New returns a new HTTP middleware logging the requests.
+func New(m map[string]interface{}) (global.Middleware, int, error) {
+	conf := &config{}
+	if err := mapstructure.Decode(m, conf); err != nil {
+		return nil, 0, err
+	}
+	if err := conf.init(); err != nil {
+		return nil, 0, err
+	}
+
+	return func(h http.Handler) http.Handler {
+		return handler(conf, h)
+	}, conf.Priority, nil
+}
+
+// settings returns the settings of the service serving the path,
+// matched by the longest prefix as done by the router.
+func (c *config) settings(path string) (string, serviceConfig) {
+	var match string
+	var found bool
+	for prefix := range c.Services {
+		if utils.URLHasPrefix(path, prefix) && (!found || len(prefix) > len(match)) {
+			match, found = prefix, true
+		}
+	}
+	if !found {
+		return "", serviceConfig{SampleRate: c.SampleRate}
+	}
+	return match, c.Services[match]
+}
+
+func handler(conf *config, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the path is routed, and thus rewritten, by the inner handlers
+		prefix, settings := conf.settings(r.URL.Path)
+		if settings.Disabled {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rw := newResponseWriter(w)
+		h.ServeHTTP(rw, r)
+		duration := time.Since(start)
+
+		// server errors are always logged, regardless of the sampling
+		if rw.status < http.StatusInternalServerError && settings.SampleRate < 1 && rand.Float64() >= settings.SampleRate {
+			return
+		}
+
+		// the logger of the request already carries the trace ID, the method
+		// and the path, which does not include the query string
+		event := appctx.GetLogger(r.Context()).Info().
+			Str("service", prefix).
+			Int("status", rw.status).
+			Int64("size", rw.size).
+			Dur("duration", duration).
+			Str("user_agent", r.UserAgent())
+		if u, ok := ctxpkg.ContextGetUser(r.Context()); ok {
+			event = event.Str("user_id", u.GetId().GetOpaqueId())
+		}
+		event.Msg("access")
+	})
+}
+
+// responseWriter records the status and the number of bytes of the response,
+// passing them through without any buffering.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	// handlers not calling WriteHeader implicitly respond with 200
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		// informational responses can be followed by the final one
+		w.wroteHeader = code >= http.StatusOK || code == http.StatusSwitchingProtocols
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.wroteHeader = true
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("accesslog: the response writer does not implement http.Hijacker")
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil && !w.wroteHeader {
+		w.status = http.StatusSwitchingProtocols
+		w.wroteHeader = true
+	}
+	return conn, rw, err
+}
+
+// Unwrap returns the original response writer, for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,176 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(t *testing.T, m map[string]interface{}, path string, user *userpb.User, h http.HandlerFunc) (*httptest.ResponseRecorder, []map[string]interface{}) {
+	mw, _, err := New(m)
+	if err != nil {
+		t.Fatalf("not expected error while creating the middleware: %+v", err)
+	}
+
+	var buf bytes.Buffer
+	log := zerolog.New(&buf)
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	ctx := appctx.WithLogger(r.Context(), &log)
+	if user != nil {
+		ctx = ctxpkg.ContextSetUser(ctx, user)
+	}
+	r = r.WithContext(ctx)
+	r.Header.Set("User-Agent", "test-agent")
+	w := httptest.NewRecorder()
+	mw(h).ServeHTTP(w, r)
+
+	var entries []map[string]interface{}
+	dec := json.NewDecoder(&buf)
+	for dec.More() {
+		entry := map[string]interface{}{}
+		if err := dec.Decode(&entry); err != nil {
+			t.Fatalf("not expected error while decoding the log: %+v", err)
+		}
+		entries = append(entries, entry)
+	}
+	return w, entries
+}
+
+func TestCapturedStatus(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  int
+		size    int
+	}{
+		{
+			name:    "no write header and no body",
+			handler: func(w http.ResponseWriter, r *http.Request) {},
+			status:  http.StatusOK,
+		},
+		{
+			name: "no write header",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("hello"))
+			},
+			status: http.StatusOK,
+			size:   5,
+		},
+		{
+			name: "flush without write header",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.(http.Flusher).Flush()
+				w.WriteHeader(http.StatusNotFound)
+			},
+			status: http.StatusOK,
+		},
+		{
+			name: "explicit status",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusCreated)
+				_, _ = w.Write([]byte("created"))
+			},
+			status: http.StatusCreated,
+			size:   7,
+		},
+		{
+			name: "status written twice",
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusForbidden)
+				w.WriteHeader(http.StatusInternalServerError)
+			},
+			status: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w, entries := serve(t, nil, "/ocdav/files", nil, tt.handler)
+			assert.Equal(t, tt.status, w.Code)
+			if assert.Len(t, entries, 1) {
+				assert.Equal(t, "access", entries[0]["message"])
+				assert.Equal(t, float64(tt.status), entries[0]["status"])
+				assert.Equal(t, float64(tt.size), entries[0]["size"])
+				assert.Equal(t, "test-agent", entries[0]["user_agent"])
+				assert.Contains(t, entries[0], "duration")
+			}
+		})
+	}
+}
+
+func TestUser(t *testing.T) {
+	user := &userpb.User{Id: &userpb.UserId{OpaqueId: "einstein"}}
+	_, entries := serve(t, nil, "/ocdav/files", user, func(w http.ResponseWriter, r *http.Request) {})
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "einstein", entries[0]["user_id"])
+	}
+}
+
+func TestServices(t *testing.T) {
+	conf := map[string]interface{}{
+		"services": map[string]interface{}{
+			"metrics":      map[string]interface{}{"disabled": true},
+			"ocdav":        map[string]interface{}{"sample_rate": 1e-12},
+			"ocdav/public": map[string]interface{}{"sample_rate": 1},
+		},
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	failing := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadGateway) }
+
+	_, entries := serve(t, conf, "/metrics", nil, ok)
+	assert.Empty(t, entries)
+
+	_, entries = serve(t, conf, "/metricsextra", nil, ok)
+	assert.Len(t, entries, 1)
+
+	_, entries = serve(t, conf, "/ocdav/files", nil, ok)
+	assert.Empty(t, entries)
+
+	// server errors are never sampled out
+	_, entries = serve(t, conf, "/ocdav/files", nil, failing)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "ocdav", entries[0]["service"])
+	}
+
+	// the longest prefix wins
+	_, entries = serve(t, conf, "/ocdav/public/token", nil, ok)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, "ocdav/public", entries[0]["service"])
+	}
+}
+
+func TestInvalidSampleRate(t *testing.T) {
+	_, _, err := New(map[string]interface{}{"sample_rate": 2})
+	assert.Error(t, err)
+
+	_, _, err = New(map[string]interface{}{
+		"services": map[string]interface{}{"ocdav": map[string]interface{}{"sample_rate": -1}},
+	})
+	assert.Error(t, err)
+}
@@ -26,13 +26,17 @@ import (
 
 	"github.com/cs3org/reva/pkg/appctx"
 	"github.com/cs3org/reva/pkg/tracing"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
+	"google.golang.org/grpc/metadata"
 )
 
 const tracerName = "appctx"
 
-// New returns a new HTTP middleware that stores the log
-// in the context with request ID information.
+// New returns a new HTTP middleware that stores the log in the context,
+// with the trace and request IDs and the method and path of the request.
+// The request ID is taken from the X-Request-Id header, generated if absent,
+// and propagated to the gRPC services called while handling the request.
 func New(log zerolog.Logger) func(http.Handler) http.Handler {
 	chain := func(h http.Handler) http.Handler {
 		return handler(log, h)
@@ -45,8 +49,21 @@ func handler(log zerolog.Logger, h http.Handler) http.Handler {
 		r, span := tracing.SpanStartFromRequest(r, tracerName, "appctx Interceptor HTTP Handler")
 		defer span.End()
 
+		reqID := r.Header.Get(appctx.RequestIDHeader)
+		if reqID == "" {
+			reqID = uuid.New().String()
+		}
+		w.Header().Set(appctx.RequestIDHeader, reqID)
+
 		ctx := r.Context()
-		sub := log.With().Str("TraceID", span.SpanContext().TraceID().String()).Logger()
+		ctx = appctx.WithRequestID(ctx, reqID)
+		ctx = metadata.AppendToOutgoingContext(ctx, appctx.RequestIDHeader, reqID)
+		sub := log.With().
+			Str("TraceID", span.SpanContext().TraceID().String()).
+			Str("RequestID", reqID).
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Logger()
 		ctx = appctx.WithLogger(ctx, &sub)
 		r = r.WithContext(ctx)
 		h.ServeHTTP(w, r)
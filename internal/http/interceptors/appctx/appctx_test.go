@@ -0,0 +1,91 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package appctx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestLogger(t *testing.T) {
+	tests := []struct {
+		name      string
+		requestID string
+	}{
+		{
+			name:      "request id sent by the client",
+			requestID: "4e1d9b7c",
+		},
+		{
+			name: "no request id",
+		},
+	}
+
+	tp := tracesdk.NewTracerProvider()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			var reqID string
+			var outgoing metadata.MD
+			h := New(zerolog.New(&buf))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				appctx.GetLogger(r.Context()).Info().Msg("downstream")
+				reqID = appctx.RequestID(r.Context())
+				outgoing, _ = metadata.FromOutgoingContext(r.Context())
+			}))
+
+			ctx, span := tp.Tracer("test").Start(context.Background(), "test")
+			defer span.End()
+			r := httptest.NewRequest(http.MethodGet, "/ocm/shares", nil).WithContext(ctx)
+			if tt.requestID != "" {
+				r.Header.Set(appctx.RequestIDHeader, tt.requestID)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if tt.requestID != "" {
+				assert.Equal(t, tt.requestID, reqID)
+			} else {
+				assert.NotEmpty(t, reqID)
+			}
+			assert.Equal(t, reqID, w.Header().Get(appctx.RequestIDHeader))
+			assert.Equal(t, []string{reqID}, outgoing.Get(appctx.RequestIDHeader))
+
+			var entry map[string]interface{}
+			if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+				t.Fatalf("error decoding the log entry %q: %v", buf.String(), err)
+			}
+			assert.True(t, span.SpanContext().TraceID().IsValid())
+			assert.Equal(t, span.SpanContext().TraceID().String(), entry["TraceID"])
+			assert.Equal(t, reqID, entry["RequestID"])
+			assert.Equal(t, http.MethodGet, entry["method"])
+			assert.Equal(t, "/ocm/shares", entry["path"])
+			assert.Equal(t, "downstream", entry["message"])
+		})
+	}
+}
@@ -266,7 +266,9 @@ func authenticateUser(w http.ResponseWriter, r *http.Request, conf *config, toke
 
 	log.Debug().Msgf("AuthenticateRequest: type: %s, client_id: %s against %s", req.Type, req.ClientId, conf.GatewaySvc)
 
-	res, err := client.Authenticate(ctx, req)
+	// the auth providers can only see the address of the gateway, so the one of the client is forwarded
+	authCtx := metadata.AppendToOutgoingContext(ctx, ctxpkg.ForwardedForHeader, ctxpkg.ForwardedFor(r.Header.Values("X-Forwarded-For"), r.RemoteAddr))
+	res, err := client.Authenticate(authCtx, req)
 	if err != nil {
 		logError(isUnprotectedEndpoint, log, err, "error calling Authenticate", http.StatusUnauthorized, w)
 		return nil, err
@@ -21,10 +21,13 @@ package publicshares
 import (
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/cs3org/reva/internal/http/interceptors/auth/credential/registry"
 	"github.com/cs3org/reva/pkg/auth"
 	"github.com/cs3org/reva/pkg/tracing"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
 )
 
 const tracerName = "publicshares"
@@ -34,40 +37,98 @@ func init() {
 }
 
 const (
-	headerShareToken        = "public-token"
-	basicAuthPasswordPrefix = "password|"
+	headerShareToken               = "public-token"
+	headerShareSignature           = "public-token-signature"
+	headerShareSignatureExpiration = "public-token-signature-expiration"
+	headerSharePassword            = "public-token-password"
+	queryParamSharePassword        = "password"
+	basicAuthPasswordPrefix        = "password|"
+	signaturePrefix                = "signature|"
 )
 
-type strategy struct{}
+type config struct {
+	// AllowQueryPassword enables sending the share password in the query string.
+	// It is disabled by default, as the query string can end up in the logs.
+	AllowQueryPassword bool `mapstructure:"allow_query_password"`
+}
+
+type strategy struct {
+	c *config
+}
 
 // New returns a new auth strategy that handles public share verification.
 func New(m map[string]interface{}) (auth.CredentialStrategy, error) {
-	return &strategy{}, nil
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		return nil, errors.Wrap(err, "publicshares: error decoding config")
+	}
+	return &strategy{c: c}, nil
 }
 
-func (s *strategy) GetCredentials(w http.ResponseWriter, r *http.Request) (*auth.Credentials, error) {
-	r, span := tracing.SpanStartFromRequest(r, tracerName, "GetCredentials")
-	defer span.End()
-
+func getToken(r *http.Request) string {
 	token := r.Header.Get(headerShareToken)
 	if token == "" {
 		token = r.URL.Query().Get(headerShareToken)
 	}
+	return token
+}
+
+func (s *strategy) GetCredentials(w http.ResponseWriter, r *http.Request) (*auth.Credentials, error) {
+	r, span := tracing.SpanStartFromRequest(r, tracerName, "GetCredentials")
+	defer span.End()
+
+	token := getToken(r)
 	if token == "" {
 		return nil, fmt.Errorf("no public token provided")
 	}
 
+	// Password-protected shares can also be accessed with a signature instead of the password
+	sig := r.Header.Get(headerShareSignature)
+	expiration := r.Header.Get(headerShareSignatureExpiration)
+	if sig != "" || expiration != "" {
+		if sig == "" || expiration == "" {
+			return nil, fmt.Errorf("both the signature and its expiration must be provided")
+		}
+		if _, err := time.Parse(time.RFC3339, expiration); err != nil {
+			return nil, fmt.Errorf("invalid signature expiration %s: %w", expiration, err)
+		}
+		return &auth.Credentials{Type: "publicshares", ClientID: token, ClientSecret: signaturePrefix + sig + "|" + expiration}, nil
+	}
+
+	return &auth.Credentials{Type: "publicshares", ClientID: token, ClientSecret: basicAuthPasswordPrefix + s.getPassword(r)}, nil
+}
+
+// getPassword returns the share password from, in order, the dedicated header,
+// the basic auth credentials and, if allowed, the query string.
+func (s *strategy) getPassword(r *http.Request) string {
+	if password := r.Header.Get(headerSharePassword); password != "" {
+		return password
+	}
 	// We can ignore the username since it is always set to "public" in public shares.
-	sharePassword := basicAuthPasswordPrefix
-	_, password, ok := r.BasicAuth()
-	if ok {
-		sharePassword += password
+	if _, password, ok := r.BasicAuth(); ok {
+		return password
+	}
+	if s.c.AllowQueryPassword {
+		return r.URL.Query().Get(queryParamSharePassword)
 	}
-	return &auth.Credentials{Type: "publicshares", ClientID: token, ClientSecret: sharePassword}, nil
+	return ""
 }
 
 func (s *strategy) AddWWWAuthenticate(w http.ResponseWriter, r *http.Request, realm string) {
-	_, span := tracing.SpanStartFromRequest(r, tracerName, "AddWWWAuthenticate")
+	r, span := tracing.SpanStartFromRequest(r, tracerName, "AddWWWAuthenticate")
 	defer span.End()
+
+	// Only requests to public shares are challenged, so that browsers
+	// prompt for the password when a protected link is opened.
+	if getToken(r) == "" {
+		return
+	}
+
 	// TODO read realm from forwarded header?
+	if realm == "" {
+		// fall back to hostname if not configured
+		realm = r.Host
+	}
+	// The share token must never be part of the challenge.
+	w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s"`, realm))
 }
@@ -0,0 +1,194 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package publicshares
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetCredentials(t *testing.T) {
+	tests := []struct {
+		description string
+		conf        map[string]interface{}
+		url         string
+		header      map[string]string
+		basicAuth   string
+		expected    string
+		err         bool
+	}{
+		{
+			description: "no credentials",
+			url:         "/?public-token=a1b2c3",
+			expected:    "password|",
+		},
+		{
+			description: "no token",
+			url:         "/",
+			header:      map[string]string{headerSharePassword: "secret"},
+			err:         true,
+		},
+		{
+			description: "password header",
+			url:         "/",
+			header:      map[string]string{headerShareToken: "a1b2c3", headerSharePassword: "secret"},
+			expected:    "password|secret",
+		},
+		{
+			description: "basic auth",
+			url:         "/?public-token=a1b2c3",
+			basicAuth:   "secret",
+			expected:    "password|secret",
+		},
+		{
+			description: "password header over basic auth",
+			url:         "/?public-token=a1b2c3",
+			header:      map[string]string{headerSharePassword: "secret"},
+			basicAuth:   "other",
+			expected:    "password|secret",
+		},
+		{
+			description: "query password disabled",
+			url:         "/?public-token=a1b2c3&password=secret",
+			expected:    "password|",
+		},
+		{
+			description: "query password",
+			conf:        map[string]interface{}{"allow_query_password": true},
+			url:         "/?public-token=a1b2c3&password=secret",
+			expected:    "password|secret",
+		},
+		{
+			description: "basic auth over query password",
+			conf:        map[string]interface{}{"allow_query_password": true},
+			url:         "/?public-token=a1b2c3&password=other",
+			basicAuth:   "secret",
+			expected:    "password|secret",
+		},
+		{
+			description: "signature",
+			url:         "/?public-token=a1b2c3",
+			header:      map[string]string{headerShareSignature: "sig", headerShareSignatureExpiration: "2023-01-01T00:00:00Z"},
+			expected:    "signature|sig|2023-01-01T00:00:00Z",
+		},
+		{
+			description: "signature over basic auth",
+			url:         "/?public-token=a1b2c3",
+			header:      map[string]string{headerShareSignature: "sig", headerShareSignatureExpiration: "2023-01-01T00:00:00Z"},
+			basicAuth:   "secret",
+			expected:    "signature|sig|2023-01-01T00:00:00Z",
+		},
+		{
+			description: "signature without expiration",
+			url:         "/?public-token=a1b2c3",
+			header:      map[string]string{headerShareSignature: "sig"},
+			err:         true,
+		},
+		{
+			description: "expiration without signature",
+			url:         "/?public-token=a1b2c3",
+			header:      map[string]string{headerShareSignatureExpiration: "2023-01-01T00:00:00Z"},
+			err:         true,
+		},
+		{
+			description: "invalid RFC3339 expiration",
+			url:         "/?public-token=a1b2c3",
+			header:      map[string]string{headerShareSignature: "sig", headerShareSignatureExpiration: "01/01/2023"},
+			err:         true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			s, err := New(tt.conf)
+			if err != nil {
+				t.Fatalf("not expected error creating the strategy: %+v", err)
+			}
+
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			for k, v := range tt.header {
+				r.Header.Set(k, v)
+			}
+			if tt.basicAuth != "" {
+				r.SetBasicAuth("public", tt.basicAuth)
+			}
+
+			creds, err := s.GetCredentials(httptest.NewRecorder(), r)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected error, got credentials %+v", creds)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("not expected error getting the credentials: %+v", err)
+			}
+			if creds.ClientID != "a1b2c3" || creds.ClientSecret != tt.expected {
+				t.Fatalf("unexpected credentials. got=%s:%s expected=a1b2c3:%s", creds.ClientID, creds.ClientSecret, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAddWWWAuthenticate(t *testing.T) {
+	tests := []struct {
+		description string
+		realm       string
+		url         string
+		expected    []string
+	}{
+		{
+			description: "configured realm",
+			realm:       "cernbox",
+			url:         "http://example.org/remote.php/dav/public-files/a1b2c3?public-token=a1b2c3",
+			expected:    []string{`Basic realm="cernbox"`},
+		},
+		{
+			description: "hostname as realm",
+			url:         "http://example.org/remote.php/dav/public-files/a1b2c3?public-token=a1b2c3",
+			expected:    []string{`Basic realm="example.org"`},
+		},
+		{
+			description: "not a public share",
+			url:         "http://example.org/remote.php/dav/files/einstein",
+		},
+	}
+
+	s, _ := New(nil)
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			w := httptest.NewRecorder()
+
+			s.AddWWWAuthenticate(w, r, tt.realm)
+
+			got := w.Header().Values("WWW-Authenticate")
+			if len(got) != len(tt.expected) || (len(got) == 1 && got[0] != tt.expected[0]) {
+				t.Fatalf("unexpected WWW-Authenticate header. got=%v expected=%v", got, tt.expected)
+			}
+			for _, h := range got {
+				if strings.Contains(h, "a1b2c3") {
+					t.Fatalf("the share token leaked into the WWW-Authenticate header: %s", h)
+				}
+			}
+		})
+	}
+}
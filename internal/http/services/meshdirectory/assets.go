@@ -0,0 +1,242 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package meshdirectory
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// assetsMaxAge is the time in seconds browsers may cache the assets of the SPA
+// before revalidating them; their file names change with every build.
+const assetsMaxAge = 24 * 60 * 60
+
+// asset is a response of the SPA server kept in memory, as the bundle never changes.
+type asset struct {
+	header  http.Header
+	body    []byte
+	gzipped []byte // nil if compressing the asset is not worth it
+	etag    string
+}
+
+// assetsHandler serves the SPA assets, adding support for HEAD requests,
+// conditional requests and gzip compression.
+type assetsHandler struct {
+	serve http.HandlerFunc
+
+	mu     sync.RWMutex
+	assets map[string]*asset
+}
+
+func newAssetsHandler(serve http.HandlerFunc) *assetsHandler {
+	return &assetsHandler{
+		serve:  serve,
+		assets: map[string]*asset{},
+	}
+}
+
+func (h *assetsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	a, rec := h.load(r)
+	if a == nil {
+		// errors and redirects are passed on as they are
+		rec.writeTo(w, r.Method == http.MethodHead)
+		return
+	}
+
+	for k, v := range a.header {
+		w.Header()[k] = v
+	}
+	w.Header().Set("Vary", "Accept-Encoding")
+	w.Header().Set("ETag", a.etag)
+	if isIndex(r.URL.Path) {
+		// the index references the current assets, so it always has to be revalidated
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(assetsMaxAge))
+	}
+
+	if etagMatches(r.Header.Get("If-None-Match"), a.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	body := a.body
+	if a.gzipped != nil && acceptsGzip(r) {
+		w.Header().Set("Content-Encoding", "gzip")
+		body = a.gzipped
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(http.StatusOK)
+
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(body)
+	}
+}
+
+// load returns the asset for the requested path, reading it from the SPA server
+// the first time; if the response can't be cached, it is returned instead.
+func (h *assetsHandler) load(r *http.Request) (*asset, *recorder) {
+	p := path.Clean("/" + r.URL.Path)
+
+	h.mu.RLock()
+	a, ok := h.assets[p]
+	h.mu.RUnlock()
+	if ok {
+		return a, nil
+	}
+
+	// always fetch the full content, regardless of the request method and headers
+	req := r.Clone(r.Context())
+	req.Method = http.MethodGet
+	req.URL.Path = p
+	req.Header = http.Header{}
+	rec := newRecorder()
+	h.serve(rec, req)
+	if rec.status != http.StatusOK {
+		return nil, rec
+	}
+
+	a = newAsset(rec)
+	h.mu.Lock()
+	h.assets[p] = a
+	h.mu.Unlock()
+	return a, nil
+}
+
+func newAsset(rec *recorder) *asset {
+	a := &asset{
+		header: http.Header{},
+		body:   rec.body.Bytes(),
+		etag:   fmt.Sprintf(`"%x"`, sha256.Sum256(rec.body.Bytes())),
+	}
+
+	// the length and the ranges depend on how the asset is served
+	for k, v := range rec.header {
+		switch k {
+		case "Content-Length", "Accept-Ranges", "Last-Modified":
+		default:
+			a.header[k] = v
+		}
+	}
+
+	if isCompressible(a.header.Get("Content-Type")) {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(a.body); err == nil && gz.Close() == nil && buf.Len() < len(a.body) {
+			a.gzipped = buf.Bytes()
+		}
+	}
+	return a
+}
+
+func isIndex(p string) bool {
+	p = path.Clean("/" + p)
+	return p == "/" || p == "/index.html"
+}
+
+func isCompressible(contentType string) bool {
+	contentType = strings.ToLower(contentType)
+	for _, t := range []string{"text/", "application/javascript", "application/json", "image/svg+xml"} {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		params := strings.Split(enc, ";")
+		if !strings.EqualFold(strings.TrimSpace(params[0]), "gzip") {
+			continue
+		}
+		for _, p := range params[1:] {
+			p = strings.TrimSpace(p)
+			if !strings.HasPrefix(p, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(strings.TrimPrefix(p, "q="), 64); err == nil && q == 0 {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, t := range strings.Split(ifNoneMatch, ",") {
+		t = strings.TrimSpace(t)
+		if t == "*" || strings.TrimPrefix(t, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// recorder keeps the response of the SPA server in memory.
+type recorder struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func newRecorder() *recorder {
+	return &recorder{header: http.Header{}}
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *recorder) writeTo(w http.ResponseWriter, headOnly bool) {
+	for k, v := range rec.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(rec.status)
+	if !headOnly {
+		_, _ = w.Write(rec.body.Bytes())
+	}
+}
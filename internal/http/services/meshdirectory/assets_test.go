@@ -0,0 +1,93 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package meshdirectory
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	meshdirectoryweb "github.com/sciencemesh/meshdirectory-web"
+	"github.com/stretchr/testify/assert"
+)
+
+func serve(h http.Handler, method, path string, header map[string]string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestAssetsHandler(t *testing.T) {
+	h := newAssetsHandler(meshdirectoryweb.ServeMeshDirectorySPA)
+
+	get := serve(h, http.MethodGet, "/", nil)
+	assert.Equal(t, http.StatusOK, get.Code)
+	assert.Equal(t, "no-cache", get.Header().Get("Cache-Control"))
+	assert.Contains(t, get.Header().Get("Content-Type"), "text/html")
+	assert.Equal(t, strconv.Itoa(get.Body.Len()), get.Header().Get("Content-Length"))
+	etag := get.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	head := serve(h, http.MethodHead, "/", nil)
+	assert.Equal(t, http.StatusOK, head.Code)
+	assert.Equal(t, 0, head.Body.Len())
+	assert.Equal(t, get.Header().Get("Content-Length"), head.Header().Get("Content-Length"))
+	assert.Equal(t, etag, head.Header().Get("ETag"))
+
+	notModified := serve(h, http.MethodGet, "/", map[string]string{"If-None-Match": etag})
+	assert.Equal(t, http.StatusNotModified, notModified.Code)
+	assert.Equal(t, 0, notModified.Body.Len())
+
+	modified := serve(h, http.MethodGet, "/", map[string]string{"If-None-Match": `"other"`})
+	assert.Equal(t, http.StatusOK, modified.Code)
+	assert.Equal(t, get.Body.Bytes(), modified.Body.Bytes())
+
+	gzipped := serve(h, http.MethodGet, "/", map[string]string{"Accept-Encoding": "br, gzip"})
+	assert.Equal(t, http.StatusOK, gzipped.Code)
+	assert.Equal(t, "gzip", gzipped.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", gzipped.Header().Get("Vary"))
+	assert.Equal(t, strconv.Itoa(gzipped.Body.Len()), gzipped.Header().Get("Content-Length"))
+	gz, err := gzip.NewReader(gzipped.Body)
+	assert.NoError(t, err)
+	body, err := io.ReadAll(gz)
+	assert.NoError(t, err)
+	assert.Equal(t, get.Body.Bytes(), body)
+
+	identity := serve(h, http.MethodGet, "/", map[string]string{"Accept-Encoding": "gzip;q=0"})
+	assert.Empty(t, identity.Header().Get("Content-Encoding"))
+
+	icon := serve(h, http.MethodGet, "/favicon.ico", nil)
+	assert.Equal(t, http.StatusOK, icon.Code)
+	assert.Contains(t, icon.Header().Get("Cache-Control"), "max-age=")
+	assert.NotEqual(t, etag, icon.Header().Get("ETag"))
+
+	missing := serve(h, http.MethodGet, "/missing.js", nil)
+	assert.Equal(t, http.StatusNotFound, missing.Code)
+	assert.Empty(t, missing.Header().Get("ETag"))
+
+	post := serve(h, http.MethodPost, "/", nil)
+	assert.Equal(t, http.StatusMethodNotAllowed, post.Code)
+}
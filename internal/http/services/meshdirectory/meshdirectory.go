@@ -20,13 +20,21 @@ package meshdirectory
 
 import (
 	"context"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
 
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	providerv1beta1 "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	"github.com/cs3org/reva/internal/http/services/reqres"
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/rgrpc/status"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/pkg/rhttp/global"
 	"github.com/cs3org/reva/pkg/rhttp/router"
@@ -34,6 +42,7 @@ import (
 	"github.com/cs3org/reva/pkg/tracing"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
+	"github.com/rs/cors"
 	"github.com/rs/zerolog"
 	meshdirectoryweb "github.com/sciencemesh/meshdirectory-web"
 )
@@ -45,8 +54,18 @@ func init() {
 }
 
 type config struct {
-	Prefix     string `mapstructure:"prefix"`
-	GatewaySvc string `mapstructure:"gatewaysvc"`
+	Prefix     string     `mapstructure:"prefix"`
+	GatewaySvc string     `mapstructure:"gatewaysvc"`
+	CORS       corsConfig `mapstructure:"cors"`
+}
+
+// corsConfig is the CORS policy of the list of providers,
+// letting other portals fetch it from the browsers.
+// Without allowed origins, no CORS headers are sent.
+type corsConfig struct {
+	AllowedOrigins []string `mapstructure:"allowed_origins"`
+	AllowedMethods []string `mapstructure:"allowed_methods"`
+	AllowedHeaders []string `mapstructure:"allowed_headers"`
 }
 
 func (c *config) init() {
@@ -55,11 +74,47 @@ func (c *config) init() {
 	if c.Prefix == "" {
 		c.Prefix = "meshdir"
 	}
+
+	if len(c.CORS.AllowedMethods) == 0 {
+		c.CORS.AllowedMethods = []string{http.MethodGet, http.MethodHead}
+	}
+	if len(c.CORS.AllowedHeaders) == 0 {
+		c.CORS.AllowedHeaders = []string{"Accept", "Content-Type", "If-None-Match"}
+	}
+}
+
+// newCORS returns the handler of the CORS policy, or nil when no origin is allowed.
+func (c *corsConfig) newCORS() (*cors.Cors, error) {
+	if len(c.AllowedOrigins) == 0 {
+		return nil, nil
+	}
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			continue
+		}
+		u, err := url.Parse(o)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") {
+			return nil, errors.Errorf("invalid cors allowed origin %q", o)
+		}
+	}
+	return cors.New(cors.Options{
+		AllowedOrigins: c.AllowedOrigins,
+		AllowedMethods: c.AllowedMethods,
+		AllowedHeaders: c.AllowedHeaders,
+		ExposedHeaders: []string{"ETag"},
+	}), nil
 }
 
 type svc struct {
 	tracing.HTTPMiddleware
-	conf *config
+	conf   *config
+	assets *assetsHandler
+	cors   *cors.Cors
+
+	// the gateway client is shared by all the requests,
+	// and it is looked up again only after an error
+	clientMu sync.Mutex
+	client   gateway.GatewayAPIClient
 }
 
 func parseConfig(m map[string]interface{}) (*config, error) {
@@ -80,8 +135,25 @@ func New(m map[string]interface{}, log *zerolog.Logger) (global.Service, error)
 
 	c.init()
 
+	cors, err := c.CORS.newCORS()
+	if err != nil {
+		return nil, err
+	}
+
 	service := &svc{
-		conf: c,
+		conf:   c,
+		assets: newAssetsHandler(meshdirectoryweb.ServeMeshDirectorySPA),
+		cors:   cors,
+	}
+
+	// the hash of the index page is computed at startup, the ones of the
+	// other assets the first time they are requested
+	req, err := http.NewRequest(http.MethodGet, "/", nil)
+	if err != nil {
+		return nil, err
+	}
+	if a, _ := service.assets.load(req); a == nil {
+		log.Warn().Msg("meshdirectory: unable to load the index page of the mesh directory")
 	}
 	return service, nil
 }
@@ -104,15 +176,35 @@ func (s *svc) Close() error {
 func (s *svc) getClient(ctx context.Context) (gateway.GatewayAPIClient, error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "getClient")
 	defer span.End()
-	return pool.GetGatewayServiceClient(ctx, pool.Endpoint(s.conf.GatewaySvc))
+
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	if s.client != nil {
+		return s.client, nil
+	}
+
+	c, err := pool.GetGatewayServiceClient(ctx, pool.Endpoint(s.conf.GatewaySvc))
+	if err != nil {
+		return nil, err
+	}
+	s.client = c
+	return c, nil
+}
+
+// resetClient drops the cached gateway client, if it is still the given one,
+// so that the next request looks it up again.
+func (s *svc) resetClient(c gateway.GatewayAPIClient) {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	if s.client == c {
+		s.client = nil
+	}
 }
 
 func (s *svc) serveJSON(w http.ResponseWriter, r *http.Request) {
 	r, span := tracing.SpanStartFromRequest(r, tracerName, "serveJSON")
 	defer span.End()
 
-	w.Header().Set("Content-Type", "application/json")
-
 	ctx := r.Context()
 
 	gatewayClient, err := s.getClient(ctx)
@@ -122,26 +214,46 @@ func (s *svc) serveJSON(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	providers, err := gatewayClient.ListAllProviders(ctx, &providerv1beta1.ListAllProvidersRequest{})
+	res, err := gatewayClient.ListAllProviders(ctx, &providerv1beta1.ListAllProvidersRequest{})
 	if err != nil {
+		s.resetClient(gatewayClient)
 		reqres.WriteError(w, r, reqres.APIErrorServerError, "error listing all providers", err)
 		return
 	}
+	if res.Status.Code != rpc.Code_CODE_OK {
+		reqres.WriteError(w, r, reqres.APIErrorServerError, "error listing all providers", status.NewErrorFromCode(res.Status.Code, "meshdirectory"))
+		return
+	}
 
-	jsonResponse, err := json.Marshal(providers.Providers)
+	// the ETag lets the clients polling the list skip the download when it did not change;
+	// it is computed encoding the list once more, to avoid keeping the whole response in memory
+	etag, err := providersETag(res.Providers)
 	if err != nil {
 		reqres.WriteError(w, r, reqres.APIErrorServerError, "error marshalling providers data", err)
 		return
 	}
 
-	// Write response
-	_, err = w.Write(jsonResponse)
-	if err != nil {
-		reqres.WriteError(w, r, reqres.APIErrorServerError, "error writing providers data", err)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag)
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
+	if err := json.NewEncoder(w).Encode(res.Providers); err != nil {
+		// the status has already been sent, so the error can only be logged
+		appctx.GetLogger(ctx).Error().Err(err).Msg("error writing providers data")
+	}
+}
+
+// providersETag returns the ETag of the list of providers, as served by serveJSON.
+func providersETag(providers []*providerv1beta1.ProviderInfo) (string, error) {
+	h := sha256.New()
+	if err := json.NewEncoder(h).Encode(providers); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf(`"%x"`, h.Sum(nil)), nil
 }
 
 // HTTP service handler.
@@ -154,12 +266,48 @@ func (s *svc) Handler() http.Handler {
 		head, r.URL.Path = router.ShiftPath(r.URL.Path)
 		switch head {
 		case "providers":
-			s.serveJSON(w, r)
+			switch {
+			case s.cors == nil:
+				s.serveJSON(w, r)
+			case r.Method == http.MethodOptions:
+				// the preflight requests of the browsers are answered
+				// with the policy alone, only for the allowed origins
+				s.cors.HandlerFunc(w, r)
+			default:
+				s.cors.HandlerFunc(w, r)
+				s.serveJSON(w, r)
+			}
 			return
 		default:
-			r.URL.Path = head + r.URL.Path
-			meshdirectoryweb.ServeMeshDirectorySPA(w, r)
+			// the SPA is only read, for any other method it is not even looked up
+			if r.Method != http.MethodGet && r.Method != http.MethodHead {
+				w.Header().Set("Allow", "GET, HEAD")
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			p, ok := spaPath(head, r.URL.Path)
+			if !ok {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			r.URL.Path, r.URL.RawPath = p, ""
+			s.assets.ServeHTTP(w, r)
 			return
 		}
 	})
 }
+
+// spaPath rebuilds the path of the SPA asset from the components split by the router,
+// rejecting the paths that would escape the root of the SPA.
+func spaPath(head, tail string) (string, bool) {
+	p := "/" + head + tail
+	if strings.ContainsAny(p, "\\\x00") {
+		return "", false
+	}
+	for _, segment := range strings.Split(p, "/") {
+		if segment == ".." {
+			return "", false
+		}
+	}
+	return path.Clean(p), true
+}
@@ -0,0 +1,301 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package meshdirectory
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	providerv1beta1 "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	meshdirectoryweb "github.com/sciencemesh/meshdirectory-web"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+)
+
+// fakeGateway is a gateway serving a fixed list of providers.
+type fakeGateway struct {
+	gateway.UnimplementedGatewayAPIServer
+	code      rpc.Code
+	providers []*providerv1beta1.ProviderInfo
+}
+
+func (g *fakeGateway) ListAllProviders(ctx context.Context, req *providerv1beta1.ListAllProvidersRequest) (*providerv1beta1.ListAllProvidersResponse, error) {
+	return &providerv1beta1.ListAllProvidersResponse{
+		Status:    &rpc.Status{Code: g.code},
+		Providers: g.providers,
+	}, nil
+}
+
+func startGateway(t *testing.T, g *fakeGateway) string {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("not expected error while listening: %+v", err)
+	}
+	s := grpc.NewServer()
+	gateway.RegisterGatewayAPIServer(s, g)
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(s.Stop)
+	return l.Addr().String()
+}
+
+// statusRecorder records all the statuses written to the response.
+type statusRecorder struct {
+	*httptest.ResponseRecorder
+	statuses []int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.statuses = append(r.statuses, code)
+	r.ResponseRecorder.WriteHeader(code)
+}
+
+func serveProviders(t *testing.T, g *fakeGateway, header map[string]string) *statusRecorder {
+	s := &svc{conf: &config{GatewaySvc: startGateway(t, g)}}
+	r := httptest.NewRequest(http.MethodGet, "/providers", nil)
+	for k, v := range header {
+		r.Header.Set(k, v)
+	}
+	w := &statusRecorder{ResponseRecorder: httptest.NewRecorder()}
+	s.serveJSON(w, r)
+	return w
+}
+
+func TestServeJSON(t *testing.T) {
+	g := &fakeGateway{
+		code: rpc.Code_CODE_OK,
+		providers: []*providerv1beta1.ProviderInfo{
+			{Domain: "cern.ch", Name: "CERN"},
+			{Domain: "example.org", Name: "Example"},
+		},
+	}
+
+	w := serveProviders(t, g, nil)
+	assert.Equal(t, []int{http.StatusOK}, w.statuses)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	etag := w.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	var providers []*providerv1beta1.ProviderInfo
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &providers))
+	if assert.Len(t, providers, 2) {
+		assert.Equal(t, "cern.ch", providers[0].Domain)
+		assert.Equal(t, "example.org", providers[1].Domain)
+	}
+
+	w = serveProviders(t, g, map[string]string{"If-None-Match": etag})
+	assert.Equal(t, []int{http.StatusNotModified}, w.statuses)
+	assert.Equal(t, 0, w.Body.Len())
+}
+
+func TestServeJSONError(t *testing.T) {
+	w := serveProviders(t, &fakeGateway{code: rpc.Code_CODE_INTERNAL}, nil)
+	assert.Equal(t, []int{http.StatusInternalServerError}, w.statuses)
+	assert.Empty(t, w.Header().Get("ETag"))
+}
+
+// failingClient is a gateway client that can not reach the gateway.
+type failingClient struct {
+	gateway.GatewayAPIClient
+}
+
+func (c *failingClient) ListAllProviders(ctx context.Context, req *providerv1beta1.ListAllProvidersRequest, opts ...grpc.CallOption) (*providerv1beta1.ListAllProvidersResponse, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestClientReuse(t *testing.T) {
+	s := &svc{conf: &config{GatewaySvc: startGateway(t, &fakeGateway{code: rpc.Code_CODE_OK})}}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			s.serveJSON(w, httptest.NewRequest(http.MethodGet, "/providers", nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+		}()
+	}
+	wg.Wait()
+
+	c, err := s.getClient(context.Background())
+	assert.NoError(t, err)
+	assert.NotNil(t, c)
+
+	// the client is looked up again after an error
+	failing := &failingClient{}
+	s.client = failing
+	w := httptest.NewRecorder()
+	s.serveJSON(w, httptest.NewRequest(http.MethodGet, "/providers", nil))
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Nil(t, s.client)
+
+	w = httptest.NewRecorder()
+	s.serveJSON(w, httptest.NewRequest(http.MethodGet, "/providers", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotNil(t, s.client)
+}
+
+func TestCORS(t *testing.T) {
+	gatewaySvc := startGateway(t, &fakeGateway{code: rpc.Code_CODE_OK})
+
+	tests := []struct {
+		name         string
+		origins      []string
+		method       string
+		preflight    bool
+		origin       string
+		status       int
+		allowOrigin  string
+		allowMethods string
+	}{
+		{
+			name:   "no policy",
+			method: http.MethodGet,
+			origin: "https://portal.example.org",
+			status: http.StatusOK,
+		},
+		{
+			name:        "allowed origin",
+			origins:     []string{"https://portal.example.org"},
+			method:      http.MethodGet,
+			origin:      "https://portal.example.org",
+			status:      http.StatusOK,
+			allowOrigin: "https://portal.example.org",
+		},
+		{
+			name:    "origin not allowed",
+			origins: []string{"https://portal.example.org"},
+			method:  http.MethodGet,
+			origin:  "https://evil.example.com",
+			status:  http.StatusOK,
+		},
+		{
+			name:         "preflight of an allowed origin",
+			origins:      []string{"https://portal.example.org"},
+			method:       http.MethodOptions,
+			preflight:    true,
+			origin:       "https://portal.example.org",
+			status:       http.StatusNoContent,
+			allowOrigin:  "https://portal.example.org",
+			allowMethods: http.MethodGet,
+		},
+		{
+			name:      "preflight of an origin not allowed",
+			origins:   []string{"https://portal.example.org"},
+			method:    http.MethodOptions,
+			preflight: true,
+			origin:    "https://evil.example.com",
+			status:    http.StatusNoContent,
+		},
+		{
+			name:        "any origin",
+			origins:     []string{"*"},
+			method:      http.MethodGet,
+			origin:      "https://evil.example.com",
+			status:      http.StatusOK,
+			allowOrigin: "*",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &config{GatewaySvc: gatewaySvc, CORS: corsConfig{AllowedOrigins: tt.origins}}
+			c.init()
+			cors, err := c.CORS.newCORS()
+			assert.NoError(t, err)
+			s := &svc{conf: c, cors: cors}
+
+			r := httptest.NewRequest(tt.method, "/providers", nil)
+			r.Header.Set("Origin", tt.origin)
+			if tt.preflight {
+				r.Header.Set("Access-Control-Request-Method", http.MethodGet)
+			}
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, r)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.allowOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, tt.allowMethods, w.Header().Get("Access-Control-Allow-Methods"))
+			if tt.preflight {
+				assert.Equal(t, 0, w.Body.Len())
+			}
+		})
+	}
+}
+
+func TestInvalidCORSOrigin(t *testing.T) {
+	for _, origin := range []string{"portal.example.org", "ftp://portal.example.org", "https://portal.example.org/path"} {
+		c := &corsConfig{AllowedOrigins: []string{origin}}
+		_, err := c.newCORS()
+		assert.Error(t, err, origin)
+	}
+}
+
+func TestSPAFallback(t *testing.T) {
+	s := &svc{conf: &config{}, assets: newAssetsHandler(meshdirectoryweb.ServeMeshDirectorySPA)}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+	}{
+		{name: "index", method: http.MethodGet, path: "/", status: http.StatusOK},
+		{name: "head", method: http.MethodHead, path: "/", status: http.StatusOK},
+		{name: "post", method: http.MethodPost, path: "/", status: http.StatusMethodNotAllowed},
+		{name: "put", method: http.MethodPut, path: "/index.html", status: http.StatusMethodNotAllowed},
+		{name: "traversal", method: http.MethodGet, path: "../../etc/passwd", status: http.StatusBadRequest},
+		{name: "backslash", method: http.MethodGet, path: "/..\\..\\etc/passwd", status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, "/", nil)
+			r.URL.Path = tt.path
+			w := httptest.NewRecorder()
+			s.Handler().ServeHTTP(w, r)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusMethodNotAllowed {
+				assert.Equal(t, "GET, HEAD", w.Header().Get("Allow"))
+			}
+		})
+	}
+}
+
+func TestSPAPath(t *testing.T) {
+	p, ok := spaPath("assets", "/app.js")
+	assert.True(t, ok)
+	assert.Equal(t, "/assets/app.js", p)
+
+	p, ok = spaPath("", "/")
+	assert.True(t, ok)
+	assert.Equal(t, "/", p)
+
+	_, ok = spaPath("..", "/etc/passwd")
+	assert.False(t, ok)
+}
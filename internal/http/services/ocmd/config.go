@@ -22,6 +22,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/cs3org/reva/pkg/appctx"
 	"github.com/cs3org/reva/pkg/tracing"
@@ -33,25 +34,36 @@ type configData struct {
 	Host          string          `json:"host" xml:"host"`
 	Endpoint      string          `json:"endPoint" xml:"endPoint"`
 	Provider      string          `json:"provider" xml:"provider"`
-	ResourceTypes []resourceTypes `json:"resourceTypes" xml:"resourceTypes"`
+	ResourceTypes []resourceTypes `json:"resourceTypes" xml:"resourceTypes" mapstructure:"resource_types"`
 }
 
 type resourceTypes struct {
-	Name       string                 `json:"name"`
-	ShareTypes []string               `json:"shareTypes"`
-	Protocols  resourceTypesProtocols `json:"protocols"`
+	Name       string                 `json:"name" mapstructure:"name"`
+	ShareTypes []string               `json:"shareTypes" mapstructure:"share_types"`
+	Protocols  resourceTypesProtocols `json:"protocols" mapstructure:"protocols"`
 }
 
 type resourceTypesProtocols struct {
-	Webdav string `json:"webdav"`
+	Webdav string `json:"webdav,omitempty" mapstructure:"webdav"`
+	Webapp string `json:"webapp,omitempty" mapstructure:"webapp"`
+	Datatx string `json:"datatx,omitempty" mapstructure:"datatx"`
 }
 
 type configHandler struct {
-	c configData
+	c                     configData
+	prefix                string
+	trustForwardedHeaders bool
+	allowedForwardedHosts map[string]struct{}
 }
 
 func (h *configHandler) init(c *config) {
 	h.c = c.Config
+	h.prefix = c.Prefix
+	h.trustForwardedHeaders = c.TrustForwardedHeaders
+	h.allowedForwardedHosts = make(map[string]struct{}, len(c.AllowedForwardedHosts))
+	for _, host := range c.AllowedForwardedHosts {
+		h.allowedForwardedHosts[strings.ToLower(host)] = struct{}{}
+	}
 	if h.c.APIVersion == "" {
 		h.c.APIVersion = "1.0-proposal1"
 	}
@@ -62,18 +74,59 @@ func (h *configHandler) init(c *config) {
 		h.c.Provider = "cernbox"
 	}
 	h.c.Enabled = true
-	if len(c.Prefix) > 0 {
-		h.c.Endpoint = fmt.Sprintf("https://%s/%s", h.c.Host, c.Prefix)
-	} else {
-		h.c.Endpoint = fmt.Sprintf("https://%s", h.c.Host)
+	h.c.Endpoint = h.endpoint("https", h.c.Host)
+	if len(h.c.ResourceTypes) == 0 {
+		h.c.ResourceTypes = []resourceTypes{{
+			Name: "file",
+			Protocols: resourceTypesProtocols{
+				Webdav: fmt.Sprintf("/%s/ocm_webdav", h.c.Provider),
+			},
+		}}
+	}
+	for i := range h.c.ResourceTypes {
+		rt := &h.c.ResourceTypes[i]
+		if rt.Name == "" {
+			rt.Name = "file"
+		}
+		if len(rt.ShareTypes) == 0 {
+			rt.ShareTypes = []string{"user"}
+		}
+	}
+}
+
+func (h *configHandler) endpoint(scheme, host string) string {
+	if len(h.prefix) > 0 {
+		return fmt.Sprintf("%s://%s/%s", scheme, host, h.prefix)
+	}
+	return fmt.Sprintf("%s://%s", scheme, host)
+}
+
+// forwardedConfig returns the configuration with the host and endpoint
+// taken from the X-Forwarded-Host and X-Forwarded-Proto headers,
+// if forwarded headers are trusted and the host is allowed.
+func (h *configHandler) forwardedConfig(r *http.Request) (configData, bool) {
+	if !h.trustForwardedHeaders {
+		return h.c, false
 	}
-	h.c.ResourceTypes = []resourceTypes{{
-		Name:       "file",
-		ShareTypes: []string{"user"},
-		Protocols: resourceTypesProtocols{
-			Webdav: fmt.Sprintf("/%s/ocm_webdav", h.c.Provider),
-		},
-	}}
+
+	// only the first value is considered, as set by the closest proxy
+	host := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Host"), ",")[0])
+	if host == "" {
+		return h.c, false
+	}
+	if _, ok := h.allowedForwardedHosts[strings.ToLower(host)]; !ok {
+		return h.c, false
+	}
+
+	scheme := "https"
+	if proto := strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-Proto"), ",")[0]); proto == "http" || proto == "https" {
+		scheme = proto
+	}
+
+	c := h.c
+	c.Host = host
+	c.Endpoint = h.endpoint(scheme, host)
+	return c, true
 }
 
 // Send sends the configuration to the caller.
@@ -83,9 +136,14 @@ func (h *configHandler) Send(w http.ResponseWriter, r *http.Request) {
 
 	log := appctx.GetLogger(r.Context())
 
+	c, forwarded := h.forwardedConfig(r)
+	if forwarded {
+		log.Debug().Str("host", c.Host).Str("endpoint", c.Endpoint).Msg("using forwarded host for the ocm endpoint")
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	indentedConf, _ := json.MarshalIndent(h.c, "", "   ")
+	indentedConf, _ := json.MarshalIndent(c, "", "   ")
 	if _, err := w.Write(indentedConf); err != nil {
 		log.Err(err).Msg("Error writing to ResponseWriter")
 	}
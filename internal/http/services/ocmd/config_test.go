@@ -0,0 +1,234 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ocmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mitchellh/mapstructure"
+)
+
+func TestConfigDefaultResourceTypes(t *testing.T) {
+	h := new(configHandler)
+	h.init(&config{Prefix: "ocm", Config: configData{Host: "example.org"}})
+
+	w := httptest.NewRecorder()
+	h.Send(w, httptest.NewRequest(http.MethodGet, "/ocm/ocm-provider", nil))
+
+	expected := `{
+   "enabled": true,
+   "apiVersion": "1.0-proposal1",
+   "host": "example.org",
+   "endPoint": "https://example.org/ocm",
+   "provider": "cernbox",
+   "resourceTypes": [
+      {
+         "name": "file",
+         "shareTypes": [
+            "user"
+         ],
+         "protocols": {
+            "webdav": "/cernbox/ocm_webdav"
+         }
+      }
+   ]
+}`
+	if w.Body.String() != expected {
+		t.Fatalf("unexpected discovery document. got=%s expected=%s", w.Body.String(), expected)
+	}
+}
+
+func TestConfigResourceTypes(t *testing.T) {
+	c := &config{}
+	err := mapstructure.Decode(map[string]interface{}{
+		"prefix": "ocm",
+		"config": map[string]interface{}{
+			"host": "example.org",
+			"resource_types": []map[string]interface{}{
+				{
+					"name":        "file",
+					"share_types": []string{"user", "group"},
+					"protocols": map[string]interface{}{
+						"webdav": "/remote.php/dav/ocm",
+						"webapp": "/external/sciencemesh",
+						"datatx": "/remote.php/dav/ocm",
+					},
+				},
+				{
+					"protocols": map[string]interface{}{
+						"webdav": "/remote.php/dav/ocm",
+					},
+				},
+			},
+		},
+	}, c)
+	if err != nil {
+		t.Fatalf("not expected error decoding config: %+v", err)
+	}
+
+	h := new(configHandler)
+	h.init(c)
+
+	if len(h.c.ResourceTypes) != 2 {
+		t.Fatalf("expected 2 resource types, got %d", len(h.c.ResourceTypes))
+	}
+	rt := h.c.ResourceTypes[0]
+	if len(rt.ShareTypes) != 2 || rt.ShareTypes[1] != "group" {
+		t.Fatalf("unexpected share types %v", rt.ShareTypes)
+	}
+	if rt.Protocols.Webapp != "/external/sciencemesh" || rt.Protocols.Datatx != "/remote.php/dav/ocm" {
+		t.Fatalf("unexpected protocols %+v", rt.Protocols)
+	}
+	rt = h.c.ResourceTypes[1]
+	if rt.Name != "file" || len(rt.ShareTypes) != 1 || rt.ShareTypes[0] != "user" {
+		t.Fatalf("expected defaults for resource type, got %+v", rt)
+	}
+}
+
+func TestConfigForwardedHeaders(t *testing.T) {
+	tests := []struct {
+		description string
+		conf        *config
+		headers     map[string]string
+		expected    string
+	}{
+		{
+			description: "forwarded headers not trusted",
+			conf:        &config{Prefix: "ocm", Config: configData{Host: "internal.example.org"}, AllowedForwardedHosts: []string{"example.org"}},
+			headers:     map[string]string{"X-Forwarded-Host": "example.org"},
+			expected:    "https://internal.example.org/ocm",
+		},
+		{
+			description: "allowed forwarded host",
+			conf:        &config{Prefix: "ocm", Config: configData{Host: "internal.example.org"}, TrustForwardedHeaders: true, AllowedForwardedHosts: []string{"example.org"}},
+			headers:     map[string]string{"X-Forwarded-Host": "Example.org", "X-Forwarded-Proto": "http"},
+			expected:    "http://Example.org/ocm",
+		},
+		{
+			description: "forwarded host not in the allowlist",
+			conf:        &config{Prefix: "ocm", Config: configData{Host: "internal.example.org"}, TrustForwardedHeaders: true, AllowedForwardedHosts: []string{"example.org"}},
+			headers:     map[string]string{"X-Forwarded-Host": "evil.org"},
+			expected:    "https://internal.example.org/ocm",
+		},
+		{
+			description: "invalid forwarded proto",
+			conf:        &config{Prefix: "ocm", Config: configData{Host: "internal.example.org"}, TrustForwardedHeaders: true, AllowedForwardedHosts: []string{"example.org"}},
+			headers:     map[string]string{"X-Forwarded-Host": "example.org, proxy.example.org", "X-Forwarded-Proto": "javascript"},
+			expected:    "https://example.org/ocm",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			h := new(configHandler)
+			h.init(tt.conf)
+
+			r := httptest.NewRequest(http.MethodGet, "/ocm/ocm-provider", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.Send(w, r)
+
+			var c configData
+			if err := json.Unmarshal(w.Body.Bytes(), &c); err != nil {
+				t.Fatalf("not expected error unmarshaling the discovery document: %+v", err)
+			}
+			if c.Endpoint != tt.expected {
+				t.Fatalf("unexpected endpoint. got=%s expected=%s", c.Endpoint, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConfigWellKnown(t *testing.T) {
+	tests := []struct {
+		description string
+		conf        map[string]interface{}
+		headers     map[string]string
+		expected    string
+	}{
+		{
+			description: "default prefix",
+			conf:        map[string]interface{}{"config": map[string]interface{}{"host": "example.org"}},
+			expected:    "https://example.org/ocm",
+		},
+		{
+			description: "custom prefix",
+			conf:        map[string]interface{}{"prefix": "federation/ocm", "config": map[string]interface{}{"host": "example.org"}},
+			expected:    "https://example.org/federation/ocm",
+		},
+		{
+			description: "forwarded headers",
+			conf: map[string]interface{}{
+				"config":                  map[string]interface{}{"host": "internal.example.org"},
+				"trust_forwarded_headers": true,
+				"allowed_forwarded_hosts": []string{"example.org"},
+			},
+			headers:  map[string]string{"X-Forwarded-Host": "example.org", "X-Forwarded-Proto": "http"},
+			expected: "http://example.org/ocm",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			tt.conf["gatewaysvc"] = "localhost:19000"
+			s, err := New(tt.conf, nil)
+			if err != nil {
+				t.Fatalf("not expected error creating the service: %+v", err)
+			}
+
+			get := func(h http.Handler, path string) *httptest.ResponseRecorder {
+				r := httptest.NewRequest(http.MethodGet, path, nil)
+				for k, v := range tt.headers {
+					r.Header.Set(k, v)
+				}
+				w := httptest.NewRecorder()
+				h.ServeHTTP(w, r)
+				return w
+			}
+
+			// the rhttp server strips the service prefix before calling the handler
+			legacy := get(s.Handler(), "/ocm-provider")
+			h, ok := s.(*svc).RootHandlers()[wellKnownPath]
+			if !ok {
+				t.Fatalf("no handler registered at %s", wellKnownPath)
+			}
+			wellKnown := get(h, wellKnownPath)
+
+			if legacy.Code != http.StatusOK || wellKnown.Code != http.StatusOK {
+				t.Fatalf("unexpected status codes. legacy=%d well-known=%d", legacy.Code, wellKnown.Code)
+			}
+			if legacy.Body.String() != wellKnown.Body.String() {
+				t.Fatalf("discovery documents differ. legacy=%s well-known=%s", legacy.Body.String(), wellKnown.Body.String())
+			}
+
+			var c configData
+			if err := json.Unmarshal(wellKnown.Body.Bytes(), &c); err != nil {
+				t.Fatalf("not expected error unmarshaling the discovery document: %+v", err)
+			}
+			if c.Endpoint != tt.expected {
+				t.Fatalf("unexpected endpoint. got=%s expected=%s", c.Endpoint, tt.expected)
+			}
+		})
+	}
+}
@@ -19,20 +19,169 @@
 package ocmd
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
 	"net/http"
 
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	"github.com/cs3org/reva/internal/http/services/reqres"
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/pkg/tracing"
+	"github.com/cs3org/reva/pkg/utils"
+)
+
+// The notification types sent by the recipient provider of a share.
+const (
+	notificationShareAccepted = "SHARE_ACCEPTED"
+	notificationShareDeclined = "SHARE_DECLINED"
+	notificationShareUnshared = "SHARE_UNSHARED"
 )
 
 type notificationsHandler struct {
+	gatewayAddr string
 }
 
 func (h *notificationsHandler) init(c *config) {
+	h.gatewayAddr = c.GatewaySvc
 }
 
-// SendNotification is used to let the provider know that a user has removed a share.
+type notificationRequest struct {
+	NotificationType string       `json:"notificationType" validate:"required"`
+	ResourceType     string       `json:"resourceType" validate:"required"`
+	ProviderID       string       `json:"providerId" validate:"required"` // identifier of the share at provider side
+	Notification     notification `json:"notification"`
+}
+
+type notification struct {
+	SharedSecret string `json:"sharedSecret" validate:"required"`
+	Message      string `json:"message"`
+}
+
+// SendNotification is used by the recipient provider to let the provider know
+// that a share has been accepted, declined or unshared.
 func (h *notificationsHandler) SendNotification(w http.ResponseWriter, r *http.Request) {
-	_, span := tracing.SpanStartFromRequest(r, tracerName, "Notifications HTTP Handler")
+	r, span := tracing.SpanStartFromRequest(r, tracerName, "Notifications HTTP Handler")
 	defer span.End()
-	w.WriteHeader(http.StatusNotImplemented)
+
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	req, err := getNotificationRequest(r)
+	if err != nil {
+		reqres.WriteError(w, r, reqres.APIErrorInvalidParameter, err.Error(), nil)
+		return
+	}
+
+	switch req.NotificationType {
+	case notificationShareAccepted, notificationShareDeclined, notificationShareUnshared:
+	default:
+		reqres.WriteError(w, r, reqres.APIErrorInvalidParameter, fmt.Sprintf("notification type %q not supported", req.NotificationType), nil)
+		return
+	}
+
+	client, err := pool.GetGatewayServiceClient(ctx, pool.Endpoint(h.gatewayAddr))
+	if err != nil {
+		reqres.WriteError(w, r, reqres.APIErrorServerError, "error getting grpc gateway client", err)
+		return
+	}
+
+	shareRes, err := client.GetOCMShareByToken(ctx, &ocm.GetOCMShareByTokenRequest{
+		Token: req.Notification.SharedSecret,
+	})
+	switch {
+	case err != nil:
+		reqres.WriteError(w, r, reqres.APIErrorServerError, "error getting ocm share", err)
+		return
+	case shareRes.Status.Code == rpc.Code_CODE_NOT_FOUND:
+		if req.NotificationType != notificationShareAccepted {
+			// the share was already removed, most likely by a previous notification
+			log.Debug().Str("provider_id", req.ProviderID).Str("type", req.NotificationType).Msg("ocm share already removed")
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+		reqres.WriteError(w, r, reqres.APIErrorNotFound, "share not found", nil)
+		return
+	case shareRes.Status.Code != rpc.Code_CODE_OK:
+		reqres.WriteError(w, r, reqres.APIErrorServerError, "error getting ocm share", errors.New(shareRes.Status.Message))
+		return
+	}
+
+	share := shareRes.Share
+	if share.Id.GetOpaqueId() != req.ProviderID {
+		reqres.WriteError(w, r, reqres.APIErrorNotFound, "share not found", nil)
+		return
+	}
+
+	// only the provider of the recipient is allowed to notify about the share
+	clientIP, err := utils.GetClientIP(r)
+	if err != nil {
+		reqres.WriteError(w, r, reqres.APIErrorServerError, fmt.Sprintf("error retrieving client IP from request: %s", r.RemoteAddr), err)
+		return
+	}
+	providerAllowedResp, err := client.IsProviderAllowed(ctx, &ocmprovider.IsProviderAllowedRequest{
+		Provider: &ocmprovider.ProviderInfo{
+			Domain: share.Grantee.GetUserId().GetIdp(),
+			Services: []*ocmprovider.Service{
+				{
+					Host: clientIP,
+				},
+			},
+		},
+	})
+	if err != nil {
+		reqres.WriteError(w, r, reqres.APIErrorServerError, "error sending a grpc is provider allowed request", err)
+		return
+	}
+	if providerAllowedResp.Status.Code != rpc.Code_CODE_OK {
+		reqres.WriteError(w, r, reqres.APIErrorUnauthenticated, "provider not authorized", errors.New(providerAllowedResp.Status.Message))
+		return
+	}
+
+	switch req.NotificationType {
+	case notificationShareAccepted:
+		// the outgoing shares do not keep track of the state in the recipient
+		// side, the share is left untouched
+		log.Info().Str("share_id", share.Id.GetOpaqueId()).Msg("ocm share accepted by the recipient")
+	case notificationShareDeclined, notificationShareUnshared:
+		removeRes, err := client.RemoveOCMShare(ctx, &ocm.RemoveOCMShareRequest{
+			Ref: &ocm.ShareReference{
+				Spec: &ocm.ShareReference_Token{
+					Token: share.Token,
+				},
+			},
+		})
+		if err != nil {
+			reqres.WriteError(w, r, reqres.APIErrorServerError, "error removing ocm share", err)
+			return
+		}
+		if removeRes.Status.Code != rpc.Code_CODE_OK && removeRes.Status.Code != rpc.Code_CODE_NOT_FOUND {
+			reqres.WriteError(w, r, reqres.APIErrorServerError, "error removing ocm share", errors.New(removeRes.Status.Message))
+			return
+		}
+		log.Info().Str("share_id", share.Id.GetOpaqueId()).Str("type", req.NotificationType).Msg("ocm share removed by the recipient")
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func getNotificationRequest(r *http.Request) (*notificationRequest, error) {
+	var req notificationRequest
+	contentType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err == nil && contentType == "application/json" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, err
+		}
+	} else {
+		return nil, errors.New("body request not recognised")
+	}
+	// validate the request
+	if err := validate.Struct(req); err != nil {
+		return nil, err
+	}
+	return &req, nil
 }
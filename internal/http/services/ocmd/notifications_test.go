@@ -0,0 +1,221 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ocmd
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	ocm "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	providerpb "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/internal/http/services/reqres"
+	"google.golang.org/grpc"
+)
+
+// fakeGateway is a gateway keeping the outgoing ocm shares in memory.
+type fakeGateway struct {
+	gateway.UnimplementedGatewayAPIServer
+	mu             sync.Mutex
+	shares         map[string]*ocm.Share // by token
+	allowedDomains []string
+	removed        int
+}
+
+func (g *fakeGateway) GetOCMShareByToken(ctx context.Context, req *ocm.GetOCMShareByTokenRequest) (*ocm.GetOCMShareByTokenResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	s, ok := g.shares[req.Token]
+	if !ok {
+		return &ocm.GetOCMShareByTokenResponse{Status: &rpc.Status{Code: rpc.Code_CODE_NOT_FOUND}}, nil
+	}
+	return &ocm.GetOCMShareByTokenResponse{Status: &rpc.Status{Code: rpc.Code_CODE_OK}, Share: s}, nil
+}
+
+func (g *fakeGateway) IsProviderAllowed(ctx context.Context, req *ocmprovider.IsProviderAllowedRequest) (*ocmprovider.IsProviderAllowedResponse, error) {
+	for _, d := range g.allowedDomains {
+		if d == req.Provider.Domain {
+			return &ocmprovider.IsProviderAllowedResponse{Status: &rpc.Status{Code: rpc.Code_CODE_OK}}, nil
+		}
+	}
+	return &ocmprovider.IsProviderAllowedResponse{Status: &rpc.Status{Code: rpc.Code_CODE_PERMISSION_DENIED}}, nil
+}
+
+func (g *fakeGateway) RemoveOCMShare(ctx context.Context, req *ocm.RemoveOCMShareRequest) (*ocm.RemoveOCMShareResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.shares[req.Ref.GetToken()]; !ok {
+		return &ocm.RemoveOCMShareResponse{Status: &rpc.Status{Code: rpc.Code_CODE_NOT_FOUND}}, nil
+	}
+	delete(g.shares, req.Ref.GetToken())
+	g.removed++
+	return &ocm.RemoveOCMShareResponse{Status: &rpc.Status{Code: rpc.Code_CODE_OK}}, nil
+}
+
+func startGateway(t *testing.T, g *fakeGateway) string {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("not expected error while listening: %+v", err)
+	}
+	s := grpc.NewServer()
+	gateway.RegisterGatewayAPIServer(s, g)
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(s.Stop)
+	return l.Addr().String()
+}
+
+func newFakeGateway() *fakeGateway {
+	return &fakeGateway{
+		shares: map[string]*ocm.Share{
+			"secret": {
+				Id:    &ocm.ShareId{OpaqueId: "share-id"},
+				Token: "secret",
+				Grantee: &providerpb.Grantee{
+					Type: providerpb.GranteeType_GRANTEE_TYPE_USER,
+					Id:   &providerpb.Grantee_UserId{UserId: &userpb.UserId{OpaqueId: "einstein", Idp: "cern.ch"}},
+				},
+			},
+		},
+		allowedDomains: []string{"cern.ch"},
+	}
+}
+
+func sendNotification(t *testing.T, h *notificationsHandler, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/ocm/notifications", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	h.SendNotification(w, r)
+	return w
+}
+
+func notificationBody(notificationType, providerID, secret string) string {
+	return `{"notificationType": "` + notificationType + `", "resourceType": "file", "providerId": "` + providerID + `", "notification": {"sharedSecret": "` + secret + `"}}`
+}
+
+func TestSendNotification(t *testing.T) {
+	tests := []struct {
+		name             string
+		notificationType string
+		removed          int
+	}{
+		{name: "accepted", notificationType: "SHARE_ACCEPTED", removed: 0},
+		{name: "declined", notificationType: "SHARE_DECLINED", removed: 1},
+		{name: "unshared", notificationType: "SHARE_UNSHARED", removed: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGateway()
+			h := new(notificationsHandler)
+			h.init(&config{GatewaySvc: startGateway(t, g)})
+
+			// the notifications are idempotent
+			for i := 0; i < 2; i++ {
+				w := sendNotification(t, h, notificationBody(tt.notificationType, "share-id", "secret"))
+				if w.Code != http.StatusCreated {
+					t.Fatalf("unexpected status for notification %d: got=%d expected=%d body=%s", i, w.Code, http.StatusCreated, w.Body.String())
+				}
+			}
+			if g.removed != tt.removed {
+				t.Fatalf("unexpected removed shares: got=%d expected=%d", g.removed, tt.removed)
+			}
+		})
+	}
+}
+
+func TestSendNotificationErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   reqres.APIErrorCode
+	}{
+		{
+			name:   "unknown notification type",
+			body:   notificationBody("SHARE_FORGOTTEN", "share-id", "secret"),
+			status: http.StatusBadRequest,
+			code:   reqres.APIErrorInvalidParameter,
+		},
+		{
+			name:   "missing shared secret",
+			body:   notificationBody("SHARE_DECLINED", "share-id", ""),
+			status: http.StatusBadRequest,
+			code:   reqres.APIErrorInvalidParameter,
+		},
+		{
+			name:   "share id not matching the secret",
+			body:   notificationBody("SHARE_DECLINED", "other-share-id", "secret"),
+			status: http.StatusNotFound,
+			code:   reqres.APIErrorNotFound,
+		},
+		{
+			name:   "accepting a missing share",
+			body:   notificationBody("SHARE_ACCEPTED", "share-id", "other-secret"),
+			status: http.StatusNotFound,
+			code:   reqres.APIErrorNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newFakeGateway()
+			h := new(notificationsHandler)
+			h.init(&config{GatewaySvc: startGateway(t, g)})
+
+			w := sendNotification(t, h, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("unexpected status: got=%d expected=%d", w.Code, tt.status)
+			}
+			var apiErr reqres.APIError
+			if err := json.Unmarshal(w.Body.Bytes(), &apiErr); err != nil {
+				t.Fatalf("not expected error decoding response: %+v", err)
+			}
+			if apiErr.Code != tt.code {
+				t.Fatalf("unexpected error code: got=%s expected=%s", apiErr.Code, tt.code)
+			}
+			if g.removed != 0 {
+				t.Fatalf("no share was expected to be removed, got %d", g.removed)
+			}
+		})
+	}
+}
+
+func TestSendNotificationProviderNotAllowed(t *testing.T) {
+	g := newFakeGateway()
+	g.allowedDomains = []string{"example.org"}
+	h := new(notificationsHandler)
+	h.init(&config{GatewaySvc: startGateway(t, g)})
+
+	w := sendNotification(t, h, notificationBody("SHARE_UNSHARED", "share-id", "secret"))
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("unexpected status: got=%d expected=%d", w.Code, http.StatusUnauthorized)
+	}
+	if g.removed != 0 {
+		t.Fatalf("no share was expected to be removed, got %d", g.removed)
+	}
+}
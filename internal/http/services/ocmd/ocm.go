@@ -34,6 +34,9 @@ import (
 const serviceName = "ocmd"
 const tracerName = "ocmd"
 
+// wellKnownPath is where OCM peers discover the service, at the root of the server.
+const wellKnownPath = "/.well-known/ocm"
+
 func init() {
 	global.Register("ocmd", New)
 }
@@ -43,6 +46,8 @@ type config struct {
 	GatewaySvc                 string     `mapstructure:"gatewaysvc"`
 	Config                     configData `mapstructure:"config"`
 	ExposeRecipientDisplayName bool       `mapstructure:"expose_recipient_display_name"`
+	TrustForwardedHeaders      bool       `mapstructure:"trust_forwarded_headers"`
+	AllowedForwardedHosts      []string   `mapstructure:"allowed_forwarded_hosts"`
 }
 
 func (c *config) init() {
@@ -55,8 +60,9 @@ func (c *config) init() {
 
 type svc struct {
 	tracing.HTTPMiddleware
-	Conf   *config
-	router chi.Router
+	Conf          *config
+	router        chi.Router
+	configHandler *configHandler
 }
 
 // New returns a new ocmd object, that implements
@@ -85,19 +91,19 @@ func New(m map[string]interface{}, log *zerolog.Logger) (global.Service, error)
 }
 
 func (s *svc) routerInit(ctx context.Context) error {
-	configHandler := new(configHandler)
+	s.configHandler = new(configHandler)
 	sharesHandler := new(sharesHandler)
 	notificationsHandler := new(notificationsHandler)
 	invitesHandler := new(invitesHandler)
 
-	configHandler.init(s.Conf)
+	s.configHandler.init(s.Conf)
 	sharesHandler.init(s.Conf)
 	notificationsHandler.init(s.Conf)
 	if err := invitesHandler.init(ctx, s.Conf); err != nil {
 		return err
 	}
 
-	s.router.Get("/ocm-provider", configHandler.Send) // FIXME: where this endpoint is documented?
+	s.router.Get("/ocm-provider", s.configHandler.Send) // FIXME: where this endpoint is documented?
 	s.router.Post("/shares", sharesHandler.CreateShare)
 	s.router.Post("/notifications", notificationsHandler.SendNotification)
 	s.router.Post("/invite-accepted", invitesHandler.AcceptInvite)
@@ -118,6 +124,20 @@ func (s *svc) Unprotected() []string {
 	return []string{"/invite-accepted", "/shares", "/ocm-provider", "/notifications"}
 }
 
+// RootHandlers returns the discovery endpoint served at the root of the server,
+// in addition to the legacy one under the service prefix.
+func (s *svc) RootHandlers() map[string]http.Handler {
+	return map[string]http.Handler{
+		wellKnownPath: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			s.configHandler.Send(w, r)
+		}),
+	}
+}
+
 func (s *svc) Handler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		log := appctx.GetLogger(r.Context())
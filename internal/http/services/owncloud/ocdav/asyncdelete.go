@@ -0,0 +1,261 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ocdav
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ReneKroon/ttlcache/v2"
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/rhttp/router"
+	"github.com/cs3org/reva/pkg/tracing"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/cs3org/reva/pkg/worker"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	deleteJobPending   = "pending"
+	deleteJobCompleted = "completed"
+	deleteJobFailed    = "failed"
+)
+
+// deleteJob is the status of an asynchronous delete.
+type deleteJob struct {
+	ID         string `json:"id"`
+	Status     string `json:"status"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Message    string `json:"message,omitempty"`
+	owner      *userpb.UserId
+}
+
+// deleteJobs runs the asynchronous deletes, at most max at the same time,
+// and keeps track of them until their status expires.
+type deleteJobs struct {
+	cache   *ttlcache.Cache
+	workers *worker.Group
+	slots   chan struct{}
+}
+
+func newDeleteJobs(ttl time.Duration, max int) *deleteJobs {
+	cache := ttlcache.NewCache()
+	_ = cache.SetTTL(ttl)
+	cache.SkipTTLExtensionOnHit(true)
+	return &deleteJobs{
+		cache:   cache,
+		workers: worker.NewGroup(),
+		slots:   make(chan struct{}, max),
+	}
+}
+
+// start runs f in the background, unless the maximum number
+// of jobs are already running, in which case it returns false.
+func (j *deleteJobs) start(f func(ctx context.Context)) bool {
+	select {
+	case j.slots <- struct{}{}:
+	default:
+		return false
+	}
+	j.workers.Start("ocdav async delete", func(ctx context.Context) {
+		defer func() { <-j.slots }()
+		f(ctx)
+	})
+	return true
+}
+
+// close cancels the running jobs and waits until they have returned, or until ctx is done.
+func (j *deleteJobs) close(ctx context.Context) error {
+	err := j.workers.Stop(ctx)
+	_ = j.cache.Close()
+	return err
+}
+
+// create starts tracking a new pending job owned by the given user.
+// The job id is random, so that it cannot be guessed by other users.
+func (j *deleteJobs) create(owner *userpb.UserId) (*deleteJob, error) {
+	job := &deleteJob{
+		ID:     utils.RandString(32),
+		Status: deleteJobPending,
+		owner:  owner,
+	}
+	if err := j.cache.Set(job.ID, job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// finish records the final status of a job. The job is replaced
+// instead of being modified, as it could be read concurrently.
+func (j *deleteJobs) finish(job *deleteJob, status string, statusCode int, message string) {
+	_ = j.cache.Set(job.ID, &deleteJob{
+		ID:         job.ID,
+		Status:     status,
+		StatusCode: statusCode,
+		Message:    message,
+		owner:      job.owner,
+	})
+}
+
+// get returns the job with the given id, if owned by the given user.
+func (j *deleteJobs) get(id string, owner *userpb.UserId) (*deleteJob, bool) {
+	v, err := j.cache.Get(id)
+	if err != nil {
+		return nil, false
+	}
+	job := v.(*deleteJob)
+	if !utils.UserEqual(job.owner, owner) {
+		return nil, false
+	}
+	return job, true
+}
+
+// isAsyncDelete checks whether the resource has to be deleted asynchronously,
+// either because the client asked for it or because the folder is larger
+// than the configured threshold. The info is nil if the resource could not be stat'ed,
+// in which case it is deleted synchronously to let the delete request report the error.
+func (s *svc) isAsyncDelete(r *http.Request, info *provider.ResourceInfo) bool {
+	if preferRespondAsync(r) {
+		return true
+	}
+	if s.c.AsyncDeleteThreshold == 0 || info == nil {
+		return false
+	}
+	return info.Type == provider.ResourceType_RESOURCE_TYPE_CONTAINER && info.Size >= s.c.AsyncDeleteThreshold
+}
+
+// preferRespondAsync checks whether the client sent the `respond-async` preference,
+// see https://www.rfc-editor.org/rfc/rfc7240#section-4.1
+func preferRespondAsync(r *http.Request) bool {
+	for _, v := range r.Header.Values(HeaderPrefer) {
+		for _, p := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(p), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// handleAsyncDelete starts the deletion of the resource in background
+// and replies with the location where its status can be polled.
+func (s *svc) handleAsyncDelete(w http.ResponseWriter, r *http.Request, client gateway.GatewayAPIClient, req *provider.DeleteRequest, log zerolog.Logger) {
+	ctx := r.Context()
+
+	u, ok := ctxpkg.ContextGetUser(ctx)
+	if !ok {
+		log.Error().Msg("error getting user from context for asynchronous delete")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	token, _ := ctxpkg.ContextGetToken(ctx)
+
+	job, err := s.deleteJobs.create(u.Id)
+	if err != nil {
+		log.Error().Err(err).Msg("error creating delete job")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	// the deletion outlives the request, so it cannot use its context
+	started := s.deleteJobs.start(func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, time.Duration(s.c.AsyncDeleteTimeout)*time.Second)
+		defer cancel()
+		ctx = ctxpkg.ContextSetUser(ctx, u)
+		ctx = ctxpkg.ContextSetToken(ctx, token)
+		ctx = metadata.AppendToOutgoingContext(ctx, ctxpkg.TokenHeader, token)
+		ctx = appctx.WithLogger(ctx, &log)
+		s.runDeleteJob(ctx, client, req, job, log)
+	})
+	if !started {
+		_ = s.deleteJobs.cache.Remove(job.ID)
+		log.Warn().Msg("too many asynchronous deletes running")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	log.Debug().Str("job", job.ID).Msg("deleting resource asynchronously")
+	w.Header().Set(HeaderLocation, path.Join("/", s.Prefix(), "remote.php/dav/delete-jobs", job.ID))
+	w.Header().Set(HeaderPreferenceApplied, "respond-async")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (s *svc) runDeleteJob(ctx context.Context, client gateway.GatewayAPIClient, req *provider.DeleteRequest, job *deleteJob, log zerolog.Logger) {
+	res, err := client.Delete(ctx, req)
+	switch {
+	case err != nil:
+		log.Error().Err(err).Str("job", job.ID).Msg("error performing delete grpc request")
+		s.deleteJobs.finish(job, deleteJobFailed, http.StatusInternalServerError, "Error deleting "+req.Ref.Path)
+	case res.Status.Code != rpc.Code_CODE_OK:
+		httpStatus, e := deleteErrorStatus(req.Ref, res.Status, res.Opaque, log)
+		s.deleteJobs.finish(job, deleteJobFailed, httpStatus, e.message)
+	default:
+		log.Debug().Str("job", job.ID).Msg("asynchronous delete completed")
+		s.deleteJobs.finish(job, deleteJobCompleted, http.StatusNoContent, "")
+	}
+}
+
+// handleDeleteJobStatus reports the status of an asynchronous delete.
+func (s *svc) handleDeleteJobStatus(w http.ResponseWriter, r *http.Request) {
+	r, span := tracing.SpanStartFromRequest(r, tracerName, "handleDeleteJobStatus")
+	defer span.End()
+
+	ctx := r.Context()
+	log := appctx.GetLogger(ctx)
+
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, _ := router.ShiftPath(r.URL.Path)
+	u, ok := ctxpkg.ContextGetUser(ctx)
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	// jobs of other users are reported as not found, to not disclose their existence
+	job, ok := s.deleteJobs.get(id, u.Id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	b, err := json.Marshal(job)
+	if err != nil {
+		log.Error().Err(err).Msg("error marshalling delete job")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set(HeaderContentType, "application/json")
+	if _, err := w.Write(b); err != nil {
+		log.Error().Err(err).Msg("error writing response")
+	}
+}
@@ -171,6 +171,8 @@ func (h *DavHandler) Handler(s *svc) http.Handler {
 			ctx = context.WithValue(ctx, ctxKeyBaseURI, base)
 			r = r.WithContext(ctx)
 			h.MetaHandler.Handler(s).ServeHTTP(w, r)
+		case "delete-jobs":
+			s.handleDeleteJobStatus(w, r)
 		case "trash-bin":
 			base := path.Join(ctx.Value(ctxKeyBaseURI).(string), "trash-bin")
 			ctx := context.WithValue(ctx, ctxKeyBaseURI, base)
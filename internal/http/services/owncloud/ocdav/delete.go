@@ -19,14 +19,23 @@
 package ocdav
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"path"
+	"strconv"
+	"strings"
 
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/internal/grpc/services/storageprovider"
 	"github.com/cs3org/reva/pkg/appctx"
 	"github.com/cs3org/reva/pkg/tracing"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/cs3org/reva/pkg/utils/resourceid"
 	"github.com/rs/zerolog"
 )
 
@@ -45,55 +54,222 @@ func (s *svc) handleDelete(w http.ResponseWriter, r *http.Request, ref *provider
 	r, span := tracing.SpanStartFromRequest(r, tracerName, "handleDelete")
 	defer span.End()
 
-	ctx := r.Context()
-	client, err := s.getClient(ctx)
+	client, err := s.getClient(r.Context())
 	if err != nil {
 		log.Error().Err(err).Msg("error getting grpc client")
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	}
+	s.delete(w, r, client, ref, log)
+}
+
+// delete deletes the resource, returning the sync information and the checksum
+// of the deleted content to the clients unless disabled.
+func (s *svc) delete(w http.ResponseWriter, r *http.Request, client gateway.GatewayAPIClient, ref *provider.Reference, log zerolog.Logger) {
+	ctx := r.Context()
 
 	req := &provider.DeleteRequest{Ref: ref}
+	if isPermanentDelete(r) {
+		// signal the storage to delete the resource without moving it to the trash
+		req.Opaque = &types.Opaque{
+			Map: map[string]*types.OpaqueEntry{
+				"skip_trash": {
+					Value:   []byte("true"),
+					Decoder: "plain",
+				},
+			},
+		}
+	}
+
+	var info *provider.ResourceInfo
+	if !preferRespondAsync(r) && (s.c.AsyncDeleteThreshold > 0 || !s.c.SkipDeleteSyncInfo || !s.c.SkipDeleteChecksum) {
+		info = stat(ctx, client, ref, log)
+	}
+	if s.isAsyncDelete(r, info) {
+		s.handleAsyncDelete(w, r, client, req, log)
+		return
+	}
+
 	res, err := client.Delete(ctx, req)
 	if err != nil {
 		log.Error().Err(err).Msg("error performing delete grpc request")
 		w.WriteHeader(http.StatusInternalServerError)
 		return
 	} else if res.Status.Code != rpc.Code_CODE_OK {
-		if res.Status.Code == rpc.Code_CODE_NOT_FOUND {
-			w.WriteHeader(http.StatusNotFound)
-			// TODO path might be empty or relative...
-			m := fmt.Sprintf("Resource %v not found", ref.Path)
-			b, err := Marshal(exception{
-				code:    SabredavNotFound,
-				message: m,
-			})
-			HandleWebdavError(ctx, &log, w, b, err)
-		}
-		if res.Status.Code == rpc.Code_CODE_PERMISSION_DENIED {
-			w.WriteHeader(http.StatusForbidden)
-			// TODO path might be empty or relative...
-			m := fmt.Sprintf("Permission denied to delete %v", ref.Path)
-			b, err := Marshal(exception{
-				code:    SabredavPermissionDenied,
-				message: m,
-			})
-			HandleWebdavError(ctx, &log, w, b, err)
+		handleDeleteErrorStatus(w, r, ref, res.Status, res.Opaque, log)
+		return
+	}
+
+	if !s.c.SkipDeleteSyncInfo {
+		// let the sync clients update their journal without a PROPFIND
+		if info != nil {
+			w.Header().Set(HeaderOCFileID, resourceid.OwnCloudResourceIDWrap(info.Id))
 		}
-		if res.Status.Code == rpc.Code_CODE_INTERNAL && res.Status.Message == "can't delete mount path" {
-			w.WriteHeader(http.StatusForbidden)
-			b, err := Marshal(exception{
-				code:    SabredavPermissionDenied,
-				message: res.Status.Message,
-			})
-			HandleWebdavError(ctx, &log, w, b, err)
+		if parentRef, ok := parentReference(ref, info); ok {
+			if parent := stat(ctx, client, parentRef, log); parent != nil {
+				w.Header().Set(HeaderETag, parent.Etag)
+				w.Header().Set(HeaderOCETag, parent.Etag)
+			}
 		}
+	}
+	if !s.c.SkipDeleteChecksum && info != nil {
+		setDeletedContentHeaders(w, info)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-		HandleErrorStatus(ctx, &log, w, res.Status)
+// setDeletedContentHeaders lets the clients verify their copy of the deleted resource,
+// with the checksum of a file, if the storage has one, or the number of entries
+// and the size of a folder.
+func setDeletedContentHeaders(w http.ResponseWriter, info *provider.ResourceInfo) {
+	if info.Type == provider.ResourceType_RESOURCE_TYPE_CONTAINER {
+		w.Header().Set(HeaderOCTreeSize, strconv.FormatUint(info.Size, 10))
+		if count, ok := treeCount(info); ok {
+			w.Header().Set(HeaderOCTreeCount, strconv.FormatUint(count, 10))
+		}
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	// without checksum support the header is omitted
+	if xs := info.Checksum; xs != nil && xs.Sum != "" {
+		if t := storageprovider.GRPC2PKGXS(xs.Type); t != storageprovider.XSInvalid && t != storageprovider.XSUnset {
+			w.Header().Set(HeaderOCChecksum, fmt.Sprintf("%s:%s", strings.ToUpper(string(t)), xs.Sum))
+		}
+	}
+}
+
+// treeCount returns the number of entries under a folder,
+// when the storage reports it in the opaque of its info.
+func treeCount(info *provider.ResourceInfo) (uint64, bool) {
+	e, ok := info.GetOpaque().GetMap()["eos"]
+	if !ok || e.Decoder != "json" {
+		return 0, false
+	}
+	var sys struct {
+		TreeCount *uint64 `json:"tree_count"`
+	}
+	if err := json.Unmarshal(e.Value, &sys); err != nil || sys.TreeCount == nil {
+		return 0, false
+	}
+	return *sys.TreeCount, true
+}
+
+// stat returns the info of the resource, or nil if it could not be stat'ed.
+func stat(ctx context.Context, client gateway.GatewayAPIClient, ref *provider.Reference, log zerolog.Logger) *provider.ResourceInfo {
+	res, err := client.Stat(ctx, &provider.StatRequest{Ref: ref})
+	if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+		log.Debug().Err(err).Interface("ref", ref).Msg("error stating resource around delete")
+		return nil
+	}
+	return res.Info
+}
+
+// parentReference returns the reference to the parent of the resource,
+// falling back to the parent id in its info when the reference is to a space root.
+func parentReference(ref *provider.Reference, info *provider.ResourceInfo) (*provider.Reference, bool) {
+	if ref.ResourceId == nil {
+		if ref.Path == "" || ref.Path == "/" {
+			return nil, false
+		}
+		return &provider.Reference{Path: path.Dir(ref.Path)}, true
+	}
+	if ref.Path != "" && ref.Path != "." {
+		return &provider.Reference{ResourceId: ref.ResourceId, Path: utils.MakeRelativePath(path.Dir(ref.Path))}, true
+	}
+	if info.GetParentId() != nil {
+		return &provider.Reference{ResourceId: info.ParentId}, true
+	}
+	return nil, false
+}
+
+// isPermanentDelete checks whether the client asked to delete the resource
+// permanently, either with the X-Delete-Permanent header or the
+// `permanent` query parameter set to true. Otherwise the resource is moved
+// to the trash, if the storage supports it.
+func isPermanentDelete(r *http.Request) bool {
+	v := r.Header.Get(HeaderDeletePermanent)
+	if v == "" {
+		v = r.URL.Query().Get("permanent")
+	}
+	permanent, _ := strconv.ParseBool(v)
+	return permanent
+}
+
+// handleDeleteErrorStatus writes the http status and a sabredav exception body
+// for a failed delete request.
+func handleDeleteErrorStatus(w http.ResponseWriter, r *http.Request, ref *provider.Reference, st *rpc.Status, opaque *types.Opaque, log zerolog.Logger) {
+	httpStatus, e := deleteErrorStatus(ref, st, opaque, log)
+	w.WriteHeader(httpStatus)
+	b, err := Marshal(e)
+	HandleWebdavError(r.Context(), &log, w, b, err)
+}
+
+// deleteErrorStatus maps the status of a failed delete request
+// to an http status and a sabredav exception.
+func deleteErrorStatus(ref *provider.Reference, st *rpc.Status, opaque *types.Opaque, log zerolog.Logger) (int, exception) {
+	if reason, ok := lookupFailureReason(st, opaque); ok {
+		// expected failures, not errors of the service
+		log.Info().Str("reason", reason.id).Interface("status", st).Msg("delete refused by the storage")
+		return reason.httpStatus, reason.exception(ref.Path)
+	}
+
+	var (
+		httpStatus int
+		e          exception
+	)
+	// TODO path might be empty or relative...
+	switch {
+	case st.Code == rpc.Code_CODE_NOT_FOUND:
+		log.Debug().Interface("status", st).Msg("resource not found")
+		httpStatus = http.StatusNotFound
+		e = exception{
+			code:    SabredavNotFound,
+			message: fmt.Sprintf("Resource %v not found", ref.Path),
+		}
+	case st.Code == rpc.Code_CODE_PERMISSION_DENIED:
+		log.Debug().Interface("status", st).Msg("permission denied")
+		httpStatus = http.StatusForbidden
+		e = exception{
+			code:    SabredavPermissionDenied,
+			message: fmt.Sprintf("Permission denied to delete %v", ref.Path),
+		}
+	case st.Code == rpc.Code_CODE_INTERNAL && st.Message == "can't delete mount path":
+		log.Debug().Interface("status", st).Msg("can't delete mount path")
+		httpStatus = http.StatusForbidden
+		e = exception{
+			code:    SabredavPermissionDenied,
+			message: st.Message,
+		}
+	case st.Code == rpc.Code_CODE_UNAUTHENTICATED:
+		log.Debug().Interface("status", st).Msg("unauthenticated")
+		httpStatus = http.StatusUnauthorized
+		e = exception{
+			code:    SabredavNotAuthenticated,
+			message: fmt.Sprintf("Not authenticated to delete %v", ref.Path),
+		}
+	case st.Code == rpc.Code_CODE_FAILED_PRECONDITION:
+		log.Debug().Interface("status", st).Msg("failed precondition")
+		httpStatus = http.StatusConflict
+		e = exception{
+			code:    SabredavConflict,
+			message: fmt.Sprintf("Resource %v can't be deleted in its current state", ref.Path),
+		}
+	case st.Code == rpc.Code_CODE_ABORTED:
+		log.Debug().Interface("status", st).Msg("aborted")
+		httpStatus = http.StatusPreconditionFailed
+		e = exception{
+			code:    SabredavPreconditionFailed,
+			message: fmt.Sprintf("Delete of %v was aborted", ref.Path),
+		}
+	default:
+		var c code
+		httpStatus, c = errorStatus(&log, st)
+		e = exception{
+			code:    c,
+			message: fmt.Sprintf("Error deleting %v", ref.Path),
+		}
+	}
+	return httpStatus, e
 }
 
 func (s *svc) handleSpacesDelete(w http.ResponseWriter, r *http.Request, spaceID string) {
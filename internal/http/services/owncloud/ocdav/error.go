@@ -21,9 +21,12 @@ package ocdav
 import (
 	"context"
 	"encoding/xml"
+	"fmt"
 	"net/http"
+	"strings"
 
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
 	"github.com/cs3org/reva/pkg/tracing"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
@@ -47,6 +50,12 @@ const (
 	SabredavNotFound
 	// SabredavConflict maps to HTTP 409.
 	SabredavConflict
+	// SabredavInternal maps to HTTP 500.
+	SabredavInternal
+	// SabredavNotImplemented maps to HTTP 501.
+	SabredavNotImplemented
+	// SabredavInsufficientStorage maps to HTTP 507.
+	SabredavInsufficientStorage
 )
 
 var (
@@ -58,6 +67,9 @@ var (
 		"Sabre\\DAV\\Exception\\PermissionDenied",
 		"Sabre\\DAV\\Exception\\NotFound",
 		"Sabre\\DAV\\Exception\\Conflict",
+		"Sabre\\DAV\\Exception",
+		"Sabre\\DAV\\Exception\\NotImplemented",
+		"Sabre\\DAV\\Exception\\InsufficientStorage",
 	}
 )
 
@@ -96,40 +108,106 @@ type errorXML struct {
 
 var errInvalidPropfind = errors.New("webdav: invalid propfind")
 
+// failureReasonOpaqueKey is the key in the opaque of the storage responses
+// holding the id of the reason of a failure, when the storage reports it.
+const failureReasonOpaqueKey = "failure_reason"
+
+// failureReason is a failure of an operation on a resource that is expected,
+// e.g. because of the policies of the storage, and is explained to the user.
+type failureReason struct {
+	// id identifies the reason in the opaque of the response,
+	// or in the message of the status
+	id         string
+	httpStatus int
+	code       code
+	// message is the human-readable reason, formatted with the path of the resource
+	message string
+}
+
+// failureReasons are the expected failures reported by the storage providers.
+var failureReasons = []failureReason{
+	{
+		id:         "resource quarantined",
+		httpStatus: http.StatusForbidden,
+		code:       SabredavPermissionDenied,
+		message:    "%v is quarantined by the virus scanner",
+	},
+	{
+		id:         "legal hold",
+		httpStatus: http.StatusForbidden,
+		code:       SabredavPermissionDenied,
+		message:    "%v is frozen for legal hold",
+	},
+}
+
+// lookupFailureReason returns the expected failure reported in the opaque
+// of the response, or else in the message of the status.
+func lookupFailureReason(st *rpc.Status, opaque *types.Opaque) (*failureReason, bool) {
+	if e, ok := opaque.GetMap()[failureReasonOpaqueKey]; ok {
+		for i := range failureReasons {
+			if failureReasons[i].id == string(e.Value) {
+				return &failureReasons[i], true
+			}
+		}
+	}
+	msg := strings.ToLower(st.GetMessage())
+	for i := range failureReasons {
+		if strings.Contains(msg, failureReasons[i].id) {
+			return &failureReasons[i], true
+		}
+	}
+	return nil, false
+}
+
+// exception returns the sabredav exception explaining the failure on the resource.
+func (f *failureReason) exception(path string) exception {
+	return exception{
+		code:    f.code,
+		message: fmt.Sprintf(f.message, path),
+	}
+}
+
 // HandleErrorStatus checks the status code, logs a Debug or Error level message
 // and writes an appropriate http status.
 func HandleErrorStatus(ctx context.Context, log *zerolog.Logger, w http.ResponseWriter, s *rpc.Status) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "HandleErrorStatus")
 	defer span.End()
 
+	httpStatus, _ := errorStatus(log, s)
+	w.WriteHeader(httpStatus)
+}
+
+// errorStatus logs a Debug or Error level message for the status code and
+// maps it to an http status and to the code of a sabredav exception.
+func errorStatus(log *zerolog.Logger, s *rpc.Status) (int, code) {
 	switch s.Code {
 	case rpc.Code_CODE_OK:
 		log.Debug().Interface("status", s).Msg("ok")
-		w.WriteHeader(http.StatusOK)
+		return http.StatusOK, SabredavInternal
 	case rpc.Code_CODE_NOT_FOUND:
 		log.Debug().Interface("status", s).Msg("resource not found")
-		w.WriteHeader(http.StatusNotFound)
+		return http.StatusNotFound, SabredavNotFound
 	case rpc.Code_CODE_PERMISSION_DENIED:
 		log.Debug().Interface("status", s).Msg("permission denied")
-		w.WriteHeader(http.StatusForbidden)
+		return http.StatusForbidden, SabredavPermissionDenied
 	case rpc.Code_CODE_UNAUTHENTICATED:
 		log.Debug().Interface("status", s).Msg("unauthenticated")
-		w.WriteHeader(http.StatusUnauthorized)
+		return http.StatusUnauthorized, SabredavNotAuthenticated
 	case rpc.Code_CODE_INVALID_ARGUMENT:
 		log.Debug().Interface("status", s).Msg("bad request")
-		w.WriteHeader(http.StatusBadRequest)
+		return http.StatusBadRequest, SabredavBadRequest
 	case rpc.Code_CODE_UNIMPLEMENTED:
 		log.Debug().Interface("status", s).Msg("not implemented")
-		w.WriteHeader(http.StatusNotImplemented)
+		return http.StatusNotImplemented, SabredavNotImplemented
 	case rpc.Code_CODE_INSUFFICIENT_STORAGE:
 		log.Debug().Interface("status", s).Msg("insufficient storage")
-		w.WriteHeader(http.StatusInsufficientStorage)
+		return http.StatusInsufficientStorage, SabredavInsufficientStorage
 	case rpc.Code_CODE_FAILED_PRECONDITION:
 		log.Debug().Interface("status", s).Msg("destination does not exist")
-		w.WriteHeader(http.StatusConflict)
+		return http.StatusConflict, SabredavConflict
 	default:
 		log.Error().Interface("status", s).Msg("grpc request failed")
-		w.WriteHeader(http.StatusInternalServerError)
+		return http.StatusInternalServerError, SabredavInternal
 	}
 }
 
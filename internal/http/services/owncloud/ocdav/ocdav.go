@@ -50,6 +50,9 @@ import (
 const serviceName = "ocdav"
 const tracerName = "ocdav"
 
+// closeTimeout is the time given to the asynchronous deletes to stop on shutdown.
+const closeTimeout = 10 * time.Second
+
 type ctxKey int
 
 const (
@@ -114,6 +117,24 @@ type Config struct {
 	PublicURL              string                            `mapstructure:"public_url"`
 	FavoriteStorageDriver  string                            `mapstructure:"favorite_storage_driver"`
 	FavoriteStorageDrivers map[string]map[string]interface{} `mapstructure:"favorite_storage_drivers"`
+	// AsyncDeleteThreshold is the size in bytes of a folder above which it is deleted
+	// asynchronously. If 0, folders are deleted asynchronously only when the client
+	// sends the `Prefer: respond-async` header.
+	AsyncDeleteThreshold uint64 `mapstructure:"async_delete_threshold"`
+	// DeleteJobsTTL is the time in seconds the status of an asynchronous delete is kept.
+	DeleteJobsTTL int `mapstructure:"delete_jobs_ttl"`
+	// MaxAsyncDeletes is the number of asynchronous deletes running at the same time.
+	// Further asynchronous deletes are refused with a 503 until one of them completes.
+	MaxAsyncDeletes int `mapstructure:"max_async_deletes"`
+	// AsyncDeleteTimeout is the time in seconds after which an asynchronous delete
+	// is abandoned and reported as failed.
+	AsyncDeleteTimeout int `mapstructure:"async_delete_timeout"`
+	// SkipDeleteSyncInfo disables the stats around a delete used to return the fileid
+	// of the deleted resource and the new etag of its parent, saving the sync clients a PROPFIND.
+	SkipDeleteSyncInfo bool `mapstructure:"skip_delete_sync_info"`
+	// SkipDeleteChecksum disables returning the checksum of a deleted file, or the
+	// number of entries and the size of a deleted folder, taken from the stat before the delete.
+	SkipDeleteChecksum bool `mapstructure:"skip_delete_checksum"`
 }
 
 func (c *Config) init() {
@@ -127,6 +148,18 @@ func (c *Config) init() {
 	if c.OCMNamespace == "" {
 		c.OCMNamespace = "/ocm"
 	}
+
+	if c.DeleteJobsTTL == 0 {
+		c.DeleteJobsTTL = 3600
+	}
+
+	if c.MaxAsyncDeletes == 0 {
+		c.MaxAsyncDeletes = 10
+	}
+
+	if c.AsyncDeleteTimeout == 0 {
+		c.AsyncDeleteTimeout = 600
+	}
 }
 
 type svc struct {
@@ -136,6 +169,7 @@ type svc struct {
 	davHandler       *DavHandler
 	favoritesManager favorite.Manager
 	client           *http.Client
+	deleteJobs       *deleteJobs
 }
 
 func getFavoritesManager(c *Config) (favorite.Manager, error) {
@@ -168,6 +202,7 @@ func New(m map[string]interface{}, log *zerolog.Logger) (global.Service, error)
 			rhttp.Insecure(conf.Insecure),
 		),
 		favoritesManager: fm,
+		deleteJobs:       newDeleteJobs(time.Duration(conf.DeleteJobsTTL)*time.Second, conf.MaxAsyncDeletes),
 	}
 	// initialize handlers and set default configs
 	if err := s.webDavHandler.init(conf.WebdavNamespace, true); err != nil {
@@ -183,8 +218,11 @@ func (s *svc) Prefix() string {
 	return s.c.Prefix
 }
 
+// Close stops the asynchronous deletes still running.
 func (s *svc) Close() error {
-	return nil
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	return s.deleteJobs.close(ctx)
 }
 
 func (s *svc) Unprotected() []string {
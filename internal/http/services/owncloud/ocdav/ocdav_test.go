@@ -23,10 +23,19 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	providerv1beta1 "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/utils/resourceid"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
 )
 
 /*
@@ -159,3 +168,355 @@ func TestNameDoesNotContainRule(t *testing.T) {
 		}
 	}
 }
+
+func TestHandleDeleteErrorStatus(t *testing.T) {
+	reason := func(id string) *typesv1beta1.Opaque {
+		return &typesv1beta1.Opaque{Map: map[string]*typesv1beta1.OpaqueEntry{
+			failureReasonOpaqueKey: {Decoder: "plain", Value: []byte(id)},
+		}}
+	}
+	tests := []struct {
+		status    *rpc.Status
+		opaque    *typesv1beta1.Opaque
+		httpCode  int
+		exception string
+		message   string
+	}{
+		{&rpc.Status{Code: rpc.Code_CODE_NOT_FOUND}, nil, http.StatusNotFound, codesEnum[SabredavNotFound], ""},
+		{&rpc.Status{Code: rpc.Code_CODE_PERMISSION_DENIED}, nil, http.StatusForbidden, codesEnum[SabredavPermissionDenied], ""},
+		{&rpc.Status{Code: rpc.Code_CODE_INTERNAL, Message: "can't delete mount path"}, nil, http.StatusForbidden, codesEnum[SabredavPermissionDenied], ""},
+		{&rpc.Status{Code: rpc.Code_CODE_FAILED_PRECONDITION}, nil, http.StatusConflict, codesEnum[SabredavConflict], ""},
+		{&rpc.Status{Code: rpc.Code_CODE_ABORTED}, nil, http.StatusPreconditionFailed, codesEnum[SabredavPreconditionFailed], ""},
+		{&rpc.Status{Code: rpc.Code_CODE_INTERNAL}, nil, http.StatusInternalServerError, codesEnum[SabredavInternal], ""},
+		{&rpc.Status{Code: rpc.Code_CODE_UNAUTHENTICATED}, nil, http.StatusUnauthorized, codesEnum[SabredavNotAuthenticated], ""},
+		{&rpc.Status{Code: rpc.Code_CODE_INVALID_ARGUMENT}, nil, http.StatusBadRequest, codesEnum[SabredavBadRequest], "Error deleting /file"},
+		{&rpc.Status{Code: rpc.Code_CODE_UNIMPLEMENTED}, nil, http.StatusNotImplemented, codesEnum[SabredavNotImplemented], "Error deleting /file"},
+		{&rpc.Status{Code: rpc.Code_CODE_INSUFFICIENT_STORAGE}, nil, http.StatusInsufficientStorage, codesEnum[SabredavInsufficientStorage], "Error deleting /file"},
+		{&rpc.Status{Code: rpc.Code_CODE_INTERNAL, Message: "eos: Resource Quarantined by the scanner"}, nil, http.StatusForbidden, codesEnum[SabredavPermissionDenied], "/file is quarantined by the virus scanner"},
+		{&rpc.Status{Code: rpc.Code_CODE_INTERNAL}, reason("legal hold"), http.StatusForbidden, codesEnum[SabredavPermissionDenied], "/file is frozen for legal hold"},
+		{&rpc.Status{Code: rpc.Code_CODE_INTERNAL}, reason("unknown"), http.StatusInternalServerError, codesEnum[SabredavInternal], ""},
+	}
+
+	ref := &providerv1beta1.Reference{Path: "/file"}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodDelete, "https://example.org/remote.php/dav/files/file", nil)
+		handleDeleteErrorStatus(w, r, ref, tt.status, tt.opaque, zerolog.Nop())
+
+		if w.Code != tt.httpCode {
+			t.Errorf("%s: expected http status %d got %d", tt.status.Code, tt.httpCode, w.Code)
+		}
+		if !strings.Contains(w.Body.String(), "<s:exception>"+tt.exception+"</s:exception>") {
+			t.Errorf("%s: expected exception %s in body %s", tt.status.Code, tt.exception, w.Body.String())
+		}
+		if tt.message != "" && !strings.Contains(w.Body.String(), "<s:message>"+tt.message+"</s:message>") {
+			t.Errorf("%s: expected message %s in body %s", tt.status.Code, tt.message, w.Body.String())
+		}
+	}
+}
+
+func TestIsPermanentDelete(t *testing.T) {
+	tests := []struct {
+		url      string
+		header   string
+		expected bool
+	}{
+		{"https://example.org/remote.php/dav/files/file", "", false},
+		{"https://example.org/remote.php/dav/files/file", "true", true},
+		{"https://example.org/remote.php/dav/files/file", "false", false},
+		{"https://example.org/remote.php/dav/files/file?permanent=true", "", true},
+		{"https://example.org/remote.php/dav/files/file?permanent=1", "false", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodDelete, tt.url, nil)
+		if tt.header != "" {
+			r.Header.Set(HeaderDeletePermanent, tt.header)
+		}
+		if got := isPermanentDelete(r); got != tt.expected {
+			t.Errorf("url=%s header=%s: expected %t got %t", tt.url, tt.header, tt.expected, got)
+		}
+	}
+}
+
+func TestPreferRespondAsync(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected bool
+	}{
+		{"", false},
+		{"respond-async", true},
+		{"return=minimal, respond-async", true},
+		{"Respond-Async", true},
+		{"wait=10", false},
+	}
+
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodDelete, "https://example.org/remote.php/dav/files/folder", nil)
+		if tt.header != "" {
+			r.Header.Set(HeaderPrefer, tt.header)
+		}
+		if got := preferRespondAsync(r); got != tt.expected {
+			t.Errorf("header=%s: expected %t got %t", tt.header, tt.expected, got)
+		}
+	}
+}
+
+func TestDeleteJobStatus(t *testing.T) {
+	owner := &userpb.User{Id: &userpb.UserId{Idp: "idp", OpaqueId: "einstein"}}
+	other := &userpb.User{Id: &userpb.UserId{Idp: "idp", OpaqueId: "marie"}}
+
+	s := &svc{deleteJobs: newDeleteJobs(time.Minute, 1)}
+	job, err := s.deleteJobs.create(owner.Id)
+	if err != nil {
+		t.Fatalf("error creating delete job: %v", err)
+	}
+
+	status := func(u *userpb.User, id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "https://example.org/remote.php/dav/delete-jobs/"+id, nil)
+		r = r.WithContext(ctxpkg.ContextSetUser(r.Context(), u))
+		r.URL.Path = "/" + id
+		s.handleDeleteJobStatus(w, r)
+		return w
+	}
+
+	w := status(owner, job.ID)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"pending"`) {
+		t.Errorf("expected pending job, got %d %s", w.Code, w.Body.String())
+	}
+
+	s.deleteJobs.finish(job, deleteJobFailed, http.StatusForbidden, "Permission denied")
+	w = status(owner, job.ID)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"status":"failed","status_code":403`) {
+		t.Errorf("expected failed job, got %d %s", w.Code, w.Body.String())
+	}
+
+	if w = status(other, job.ID); w.Code != http.StatusNotFound {
+		t.Errorf("expected job of another user to be not found, got %d", w.Code)
+	}
+	if w = status(owner, "unknown"); w.Code != http.StatusNotFound {
+		t.Errorf("expected unknown job to be not found, got %d", w.Code)
+	}
+}
+
+func TestDeleteJobsLimit(t *testing.T) {
+	jobs := newDeleteJobs(time.Minute, 1)
+
+	running, stopped := make(chan struct{}), make(chan struct{})
+	if !jobs.start(func(ctx context.Context) {
+		close(running)
+		<-ctx.Done()
+		close(stopped)
+	}) {
+		t.Fatal("expected the first job to be started")
+	}
+	<-running
+
+	if jobs.start(func(ctx context.Context) {}) {
+		t.Error("expected the job to be refused while the maximum number of jobs is running")
+	}
+
+	if err := jobs.close(context.Background()); err != nil {
+		t.Fatalf("error closing the delete jobs: %v", err)
+	}
+	select {
+	case <-stopped:
+	default:
+		t.Error("expected the running job to be stopped on close")
+	}
+}
+
+// deleteGatewayClient is a gateway holding resources by path, counting the stat requests.
+type deleteGatewayClient struct {
+	gateway.GatewayAPIClient
+	infos map[string]*providerv1beta1.ResourceInfo
+	stats int
+}
+
+func (c *deleteGatewayClient) Stat(ctx context.Context, req *providerv1beta1.StatRequest, opts ...grpc.CallOption) (*providerv1beta1.StatResponse, error) {
+	c.stats++
+	info, ok := c.infos[req.Ref.Path]
+	if !ok {
+		return &providerv1beta1.StatResponse{Status: &rpc.Status{Code: rpc.Code_CODE_NOT_FOUND}}, nil
+	}
+	return &providerv1beta1.StatResponse{Status: &rpc.Status{Code: rpc.Code_CODE_OK}, Info: info}, nil
+}
+
+func (c *deleteGatewayClient) Delete(ctx context.Context, req *providerv1beta1.DeleteRequest, opts ...grpc.CallOption) (*providerv1beta1.DeleteResponse, error) {
+	if _, ok := c.infos[req.Ref.Path]; !ok {
+		return &providerv1beta1.DeleteResponse{Status: &rpc.Status{Code: rpc.Code_CODE_NOT_FOUND}}, nil
+	}
+	delete(c.infos, req.Ref.Path)
+	// deleting a resource changes the etag of its parent
+	c.infos["/home"].Etag = `"etag-home-2"`
+	return &providerv1beta1.DeleteResponse{Status: &rpc.Status{Code: rpc.Code_CODE_OK}}, nil
+}
+
+func TestDeleteSyncInfo(t *testing.T) {
+	newClient := func() *deleteGatewayClient {
+		return &deleteGatewayClient{infos: map[string]*providerv1beta1.ResourceInfo{
+			"/home": {
+				Type: providerv1beta1.ResourceType_RESOURCE_TYPE_CONTAINER,
+				Id:   &providerv1beta1.ResourceId{StorageId: "storageid", OpaqueId: "home"},
+				Etag: `"etag-home-1"`,
+			},
+			"/home/file.txt": {
+				Type: providerv1beta1.ResourceType_RESOURCE_TYPE_FILE,
+				Id:   &providerv1beta1.ResourceId{StorageId: "storageid", OpaqueId: "file"},
+				Etag: `"etag-file"`,
+			},
+			"/home/folder": {
+				Type: providerv1beta1.ResourceType_RESOURCE_TYPE_CONTAINER,
+				Id:   &providerv1beta1.ResourceId{StorageId: "storageid", OpaqueId: "folder"},
+				Etag: `"etag-folder"`,
+			},
+		}}
+	}
+
+	tests := []struct {
+		path   string
+		fileID string
+	}{
+		{path: "/home/file.txt", fileID: "file"},
+		{path: "/home/folder", fileID: "folder"},
+	}
+	for _, tt := range tests {
+		s := &svc{c: &Config{}}
+		client := newClient()
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodDelete, "https://example.org/remote.php/webdav"+tt.path, nil)
+		s.delete(w, r, client, &providerv1beta1.Reference{Path: tt.path}, zerolog.Nop())
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("path=%s: expected status %d got %d", tt.path, http.StatusNoContent, w.Code)
+		}
+		wrapped := resourceid.OwnCloudResourceIDWrap(&providerv1beta1.ResourceId{StorageId: "storageid", OpaqueId: tt.fileID})
+		if got := w.Header().Get(HeaderOCFileID); got != wrapped {
+			t.Errorf("path=%s: expected fileid %s got %s", tt.path, wrapped, got)
+		}
+		if got := w.Header().Get(HeaderOCETag); got != `"etag-home-2"` {
+			t.Errorf("path=%s: expected parent etag %s got %s", tt.path, `"etag-home-2"`, got)
+		}
+		if got := w.Header().Get(HeaderETag); got != `"etag-home-2"` {
+			t.Errorf("path=%s: expected parent etag %s got %s", tt.path, `"etag-home-2"`, got)
+		}
+	}
+
+	// the stats can be skipped
+	s := &svc{c: &Config{SkipDeleteSyncInfo: true, SkipDeleteChecksum: true}}
+	client := newClient()
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "https://example.org/remote.php/webdav/home/file.txt", nil)
+	s.delete(w, r, client, &providerv1beta1.Reference{Path: "/home/file.txt"}, zerolog.Nop())
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d got %d", http.StatusNoContent, w.Code)
+	}
+	if client.stats != 0 {
+		t.Errorf("expected no stat, got %d", client.stats)
+	}
+	for _, h := range []string{HeaderOCFileID, HeaderOCETag, HeaderETag} {
+		if got := w.Header().Get(h); got != "" {
+			t.Errorf("expected no %s header, got %s", h, got)
+		}
+	}
+}
+
+func TestDeleteChecksum(t *testing.T) {
+	newClient := func() *deleteGatewayClient {
+		return &deleteGatewayClient{infos: map[string]*providerv1beta1.ResourceInfo{
+			"/home": {
+				Type: providerv1beta1.ResourceType_RESOURCE_TYPE_CONTAINER,
+				Etag: `"etag-home-1"`,
+			},
+			"/home/file.txt": {
+				Type: providerv1beta1.ResourceType_RESOURCE_TYPE_FILE,
+				Id:   &providerv1beta1.ResourceId{StorageId: "storageid", OpaqueId: "file"},
+				Size: 12,
+				Checksum: &providerv1beta1.ResourceChecksum{
+					Type: providerv1beta1.ResourceChecksumType_RESOURCE_CHECKSUM_TYPE_ADLER32,
+					Sum:  "1c8a0498",
+				},
+			},
+			"/home/nochecksum.txt": {
+				Type:     providerv1beta1.ResourceType_RESOURCE_TYPE_FILE,
+				Size:     12,
+				Checksum: &providerv1beta1.ResourceChecksum{Type: providerv1beta1.ResourceChecksumType_RESOURCE_CHECKSUM_TYPE_UNSET},
+			},
+			"/home/folder": {
+				Type: providerv1beta1.ResourceType_RESOURCE_TYPE_CONTAINER,
+				Size: 4096,
+				Opaque: &typesv1beta1.Opaque{Map: map[string]*typesv1beta1.OpaqueEntry{
+					"eos": {Decoder: "json", Value: []byte(`{"tree_size":4096,"tree_count":7}`)},
+				}},
+			},
+			"/home/other": {
+				Type: providerv1beta1.ResourceType_RESOURCE_TYPE_CONTAINER,
+				Size: 2048,
+			},
+		}}
+	}
+
+	tests := []struct {
+		path    string
+		headers map[string]string
+	}{
+		{path: "/home/file.txt", headers: map[string]string{HeaderOCChecksum: "ADLER32:1c8a0498"}},
+		{path: "/home/nochecksum.txt", headers: map[string]string{}},
+		{path: "/home/folder", headers: map[string]string{HeaderOCTreeSize: "4096", HeaderOCTreeCount: "7"}},
+		{path: "/home/other", headers: map[string]string{HeaderOCTreeSize: "2048"}},
+	}
+	for _, tt := range tests {
+		s := &svc{c: &Config{SkipDeleteSyncInfo: true}}
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodDelete, "https://example.org/remote.php/webdav"+tt.path, nil)
+		s.delete(w, r, newClient(), &providerv1beta1.Reference{Path: tt.path}, zerolog.Nop())
+
+		if w.Code != http.StatusNoContent {
+			t.Fatalf("path=%s: expected status %d got %d", tt.path, http.StatusNoContent, w.Code)
+		}
+		for _, h := range []string{HeaderOCChecksum, HeaderOCTreeSize, HeaderOCTreeCount} {
+			// the headers not expected must be omitted, not empty
+			got := w.Header().Values(h)
+			expected, expectedOK := tt.headers[h]
+			if ok := len(got) != 0; ok != expectedOK || (ok && got[0] != expected) {
+				t.Errorf("path=%s: expected %s header %q (%t), got %q", tt.path, h, expected, expectedOK, got)
+			}
+		}
+	}
+
+	// the checksum can be skipped, keeping the sync information
+	s := &svc{c: &Config{SkipDeleteChecksum: true}}
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodDelete, "https://example.org/remote.php/webdav/home/file.txt", nil)
+	s.delete(w, r, newClient(), &providerv1beta1.Reference{Path: "/home/file.txt"}, zerolog.Nop())
+	if got := w.Header().Values(HeaderOCChecksum); len(got) != 0 {
+		t.Errorf("expected no %s header, got %q", HeaderOCChecksum, got)
+	}
+	if got := w.Header().Get(HeaderOCFileID); got == "" {
+		t.Errorf("expected the %s header", HeaderOCFileID)
+	}
+}
+
+func TestParentReference(t *testing.T) {
+	space := &providerv1beta1.ResourceId{StorageId: "storageid", OpaqueId: "space"}
+	parent := &providerv1beta1.ResourceId{StorageId: "storageid", OpaqueId: "parent"}
+	tests := []struct {
+		ref      *providerv1beta1.Reference
+		info     *providerv1beta1.ResourceInfo
+		expected *providerv1beta1.Reference
+	}{
+		{ref: &providerv1beta1.Reference{Path: "/home/file.txt"}, expected: &providerv1beta1.Reference{Path: "/home"}},
+		{ref: &providerv1beta1.Reference{Path: "/"}},
+		{ref: &providerv1beta1.Reference{ResourceId: space, Path: "./folder/file.txt"}, expected: &providerv1beta1.Reference{ResourceId: space, Path: "./folder"}},
+		{ref: &providerv1beta1.Reference{ResourceId: space, Path: "./file.txt"}, expected: &providerv1beta1.Reference{ResourceId: space, Path: "."}},
+		{ref: &providerv1beta1.Reference{ResourceId: space, Path: "."}},
+		{ref: &providerv1beta1.Reference{ResourceId: space}, info: &providerv1beta1.ResourceInfo{ParentId: parent}, expected: &providerv1beta1.Reference{ResourceId: parent}},
+	}
+	for _, tt := range tests {
+		got, ok := parentReference(tt.ref, tt.info)
+		if ok != (tt.expected != nil) || (ok && (got.Path != tt.expected.Path || got.ResourceId != tt.expected.ResourceId)) {
+			t.Errorf("ref=%v: expected %v got %v", tt.ref, tt.expected, got)
+		}
+	}
+}
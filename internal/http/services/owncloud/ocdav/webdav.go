@@ -54,6 +54,8 @@ const (
 	HeaderRange                      = "Range"
 	HeaderIfMatch                    = "If-Match"
 	HeaderChecksum                   = "Digest"
+	HeaderPrefer                     = "Prefer"
+	HeaderPreferenceApplied          = "Preference-Applied"
 )
 
 // Non standard HTTP headers.
@@ -61,6 +63,8 @@ const (
 	HeaderOCFileID             = "OC-FileId"
 	HeaderOCETag               = "OC-ETag"
 	HeaderOCChecksum           = "OC-Checksum"
+	HeaderOCTreeCount          = "OC-Tree-Count"
+	HeaderOCTreeSize           = "OC-Tree-Size"
 	HeaderOCPermissions        = "OC-Perm"
 	HeaderDepth                = "Depth"
 	HeaderDav                  = "DAV"
@@ -78,6 +82,8 @@ const (
 	HeaderOCMtime              = "X-OC-Mtime"
 	HeaderExpectedEntityLength = "X-Expected-Entity-Length"
 	HeaderTransferAuth         = "TransferHeaderAuthorization"
+	// HeaderDeletePermanent asks to delete a resource without moving it to the trash.
+	HeaderDeletePermanent = "X-Delete-Permanent"
 )
 
 // WebDavHandler implements a dav endpoint.
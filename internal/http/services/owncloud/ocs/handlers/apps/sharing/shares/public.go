@@ -158,6 +158,11 @@ func (h *Handler) createPublicLinkShare(w http.ResponseWriter, r *http.Request,
 
 	if createRes.Status.Code != rpc.Code_CODE_OK {
 		log.Debug().Err(errors.New("create public share failed")).Str("shares", "createShare").Msgf("create public share failed with status code: %v", createRes.Status.Code.String())
+		if createRes.Status.Code == rpc.Code_CODE_PERMISSION_DENIED {
+			// e.g. the limit of public shares was reached
+			response.WriteOCSError(w, r, http.StatusForbidden, createRes.Status.Message, nil)
+			return
+		}
 		response.WriteOCSError(w, r, response.MetaServerError.StatusCode, "grpc create public share request failed", err)
 		return
 	}
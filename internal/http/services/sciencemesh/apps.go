@@ -19,24 +19,43 @@
 package sciencemesh
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
+	"os"
+	"path"
+	"strconv"
 	"strings"
 
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	rpcv1beta1 "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	ocmpb "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	providerpb "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/internal/http/services/datagateway"
 	"github.com/cs3org/reva/internal/http/services/reqres"
 	"github.com/cs3org/reva/pkg/errtypes"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/pkg/rhttp"
 	"github.com/cs3org/reva/pkg/rhttp/router"
 )
 
+// maxNameSuffix bounds the suffixes tried to find a free name for a new file.
+const maxNameSuffix = 100
+
+// maxCreateAttempts bounds the attempts to create a new file, when the free
+// name found is taken by another client before the file is created.
+const maxCreateAttempts = 3
+
 type appsHandler struct {
-	gatewayClient gateway.GatewayAPIClient
-	ocmMountPoint string
+	gatewayClient  gateway.GatewayAPIClient
+	ocmMountPoint  string
+	insecure       bool
+	templates      map[string][]byte // file content by extension
+	auditResources string
 }
 
 func (h *appsHandler) init(ctx context.Context, c *config) error {
@@ -46,10 +65,38 @@ func (h *appsHandler) init(ctx context.Context, c *config) error {
 		return err
 	}
 	h.ocmMountPoint = c.OCMMountPoint
+	h.insecure = c.DataTransfersInsecure
+
+	switch c.AuditResources {
+	case auditResourcesHash, auditResourcesPlain, auditResourcesNone:
+		h.auditResources = c.AuditResources
+	default:
+		return fmt.Errorf("invalid audit_resources %q", c.AuditResources)
+	}
+	if err := registerViews(); err != nil {
+		return err
+	}
+
+	h.templates = make(map[string][]byte, len(c.AppTemplates))
+	for ext, p := range c.AppTemplates {
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("error reading template for %s files: %w", ext, err)
+		}
+		h.templates[normalizeExt(ext)] = content
+	}
 
 	return nil
 }
 
+func normalizeExt(ext string) string {
+	ext = strings.ToLower(ext)
+	if !strings.HasPrefix(ext, ".") {
+		ext = "." + ext
+	}
+	return ext
+}
+
 func (h *appsHandler) shareInfo(p string) (*ocmpb.ShareId, string) {
 	p = strings.TrimPrefix(p, h.ocmMountPoint)
 	shareID, rel := router.ShiftPath(p)
@@ -60,8 +107,6 @@ func (h *appsHandler) shareInfo(p string) (*ocmpb.ShareId, string) {
 }
 
 func (h *appsHandler) OpenInApp(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
 	if err := r.ParseForm(); err != nil {
 		reqres.WriteError(w, r, reqres.APIErrorInvalidParameter, "parameters could not be parsed", nil)
 		return
@@ -73,32 +118,250 @@ func (h *appsHandler) OpenInApp(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	h.writeAppURL(w, r, actionOpen, path)
+}
+
+// CreateInApp creates a new file in a folder of a received share,
+// and opens it in the app the same way OpenInApp does.
+// The file is created empty, or from the template configured for its extension.
+// If a file with the same name already exists, the name is suffixed with " (1)",
+// " (2)" and so on until a free one is found. The folder must be in a received share.
+func (h *appsHandler) CreateInApp(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if err := r.ParseForm(); err != nil {
+		reqres.WriteError(w, r, reqres.APIErrorInvalidParameter, "parameters could not be parsed", nil)
+		return
+	}
+
+	folder := r.Form.Get("folder")
+	if folder == "" {
+		reqres.WriteError(w, r, reqres.APIErrorInvalidParameter, "missing folder", nil)
+		return
+	}
+	folder = path.Clean(folder)
+	if !strings.HasPrefix(folder, strings.TrimSuffix(h.ocmMountPoint, "/")+"/") {
+		reqres.WriteError(w, r, reqres.APIErrorInvalidParameter, "the folder must be in a received share", nil)
+		return
+	}
+
+	filename := r.Form.Get("filename")
+	if filename == "" {
+		reqres.WriteError(w, r, reqres.APIErrorInvalidParameter, "missing filename", nil)
+		return
+	}
+	if strings.Contains(filename, "/") || filename == "." || filename == ".." {
+		reqres.WriteError(w, r, reqres.APIErrorInvalidParameter, "the filename must not contain a path segment", nil)
+		return
+	}
+
+	// the free name can be taken by another client before the file
+	// is created, in which case a new one is looked for
+	var file string
+	for attempt := 1; ; attempt++ {
+		var err error
+		file, err = h.freeName(ctx, path.Join(folder, filename))
+		if err != nil {
+			writeStorageError(w, r, "error looking for a free file name", err)
+			return
+		}
+
+		err = h.createFile(ctx, file, h.templates[strings.ToLower(path.Ext(file))])
+		var alreadyExists errtypes.AlreadyExists
+		if errors.As(err, &alreadyExists) && attempt < maxCreateAttempts {
+			continue
+		}
+		if err != nil {
+			writeStorageError(w, r, "error creating the file", err)
+			return
+		}
+		break
+	}
+
+	h.writeAppURL(w, r, actionCreate, file)
+}
+
+func (h *appsHandler) writeAppURL(w http.ResponseWriter, r *http.Request, action, path string) {
+	ctx := r.Context()
+
 	shareID, rel := h.shareInfo(path)
 
-	template, err := h.webappTemplate(ctx, shareID)
+	share, template, err := h.webappTemplate(ctx, shareID)
+	var url string
+	defer func() { h.audit(ctx, action, shareID, share, rel, url, err) }()
 	if err != nil {
 		var e errtypes.NotFound
 		if errors.As(err, &e) {
 			reqres.WriteError(w, r, reqres.APIErrorNotFound, e.Error(), nil)
+			return
 		}
 		reqres.WriteError(w, r, reqres.APIErrorServerError, err.Error(), err)
 		return
 	}
 
-	url := resolveTemplate(template, rel)
+	url = resolveTemplate(template, rel)
 
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]any{
 		"app_url": url,
 	}); err != nil {
 		reqres.WriteError(w, r, reqres.APIErrorServerError, "error marshalling JSON response", err)
 		return
 	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+// freeName returns the first name, among p and its suffixed variants,
+// not used by any resource.
+func (h *appsHandler) freeName(ctx context.Context, p string) (string, error) {
+	dir, name := path.Split(p)
+	ext := path.Ext(name)
+	if ext == name {
+		// dot files, like .bashrc, have no extension
+		ext = ""
+	}
+	base := strings.TrimSuffix(name, ext)
+
+	for i := 0; i <= maxNameSuffix; i++ {
+		candidate := p
+		if i > 0 {
+			candidate = dir + fmt.Sprintf("%s (%d)%s", base, i, ext)
+		}
+
+		res, err := h.gatewayClient.Stat(ctx, &providerpb.StatRequest{
+			Ref: &providerpb.Reference{Path: candidate},
+		})
+		if err != nil {
+			return "", err
+		}
+		switch res.Status.Code {
+		case rpcv1beta1.Code_CODE_NOT_FOUND:
+			return candidate, nil
+		case rpcv1beta1.Code_CODE_OK:
+			continue
+		case rpcv1beta1.Code_CODE_PERMISSION_DENIED:
+			return "", errtypes.PermissionDenied(res.Status.Message)
+		default:
+			return "", errtypes.InternalError(res.Status.Message)
+		}
+	}
+	return "", errtypes.AlreadyExists(p)
+}
+
+// createFile creates a new file in p with the content, failing
+// with errtypes.AlreadyExists if p has been created in the meantime.
+func (h *appsHandler) createFile(ctx context.Context, p string, content []byte) error {
+	if len(content) == 0 {
+		return h.touchFile(ctx, p)
+	}
+
+	res, err := h.gatewayClient.InitiateFileUpload(ctx, &providerpb.InitiateFileUploadRequest{
+		Ref:     &providerpb.Reference{Path: p},
+		Options: &providerpb.InitiateFileUploadRequest_IfNotExist{IfNotExist: true},
+		Opaque: &typespb.Opaque{
+			Map: map[string]*typespb.OpaqueEntry{
+				"Upload-Length": {
+					Decoder: "plain",
+					Value:   []byte(strconv.Itoa(len(content))),
+				},
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	switch res.Status.Code {
+	case rpcv1beta1.Code_CODE_OK:
+	case rpcv1beta1.Code_CODE_PERMISSION_DENIED:
+		return errtypes.PermissionDenied(res.Status.Message)
+	case rpcv1beta1.Code_CODE_NOT_FOUND:
+		return errtypes.NotFound(res.Status.Message)
+	case rpcv1beta1.Code_CODE_ALREADY_EXISTS, rpcv1beta1.Code_CODE_FAILED_PRECONDITION:
+		return errtypes.AlreadyExists(p)
+	default:
+		return errtypes.InternalError(res.Status.Message)
+	}
+
+	var ep, token string
+	for _, p := range res.Protocols {
+		if p.Protocol == "simple" {
+			ep, token = p.UploadEndpoint, p.Token
+		}
+	}
+	if ep == "" {
+		return errtypes.InternalError("simple upload protocol not available")
+	}
+
+	httpReq, err := rhttp.NewRequest(ctx, http.MethodPut, ep, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set(datagateway.TokenTransportHeader, token)
+
+	httpRes, err := rhttp.GetHTTPClient(
+		rhttp.Context(ctx),
+		rhttp.Insecure(h.insecure),
+	).Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer httpRes.Body.Close()
+
+	switch httpRes.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusForbidden:
+		return errtypes.PermissionDenied("upload of " + p + " forbidden")
+	case http.StatusPreconditionFailed:
+		return errtypes.AlreadyExists(p)
+	default:
+		return errtypes.InternalError("upload of " + p + " failed with status " + httpRes.Status)
+	}
+}
+
+// touchFile creates a new empty file in p, failing
+// with errtypes.AlreadyExists if p has been created in the meantime.
+func (h *appsHandler) touchFile(ctx context.Context, p string) error {
+	res, err := h.gatewayClient.TouchFile(ctx, &providerpb.TouchFileRequest{
+		Ref: &providerpb.Reference{Path: p},
+	})
+	if err != nil {
+		return err
+	}
+	switch res.Status.Code {
+	case rpcv1beta1.Code_CODE_OK:
+		return nil
+	case rpcv1beta1.Code_CODE_PERMISSION_DENIED:
+		return errtypes.PermissionDenied(res.Status.Message)
+	case rpcv1beta1.Code_CODE_NOT_FOUND:
+		return errtypes.NotFound(res.Status.Message)
+	case rpcv1beta1.Code_CODE_ALREADY_EXISTS:
+		return errtypes.AlreadyExists(p)
+	default:
+		return errtypes.InternalError(res.Status.Message)
+	}
+}
+
+func writeStorageError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	var (
+		permissionDenied errtypes.PermissionDenied
+		notFound         errtypes.NotFound
+		alreadyExists    errtypes.AlreadyExists
+	)
+	switch {
+	case errors.As(err, &permissionDenied):
+		reqres.WriteError(w, r, reqres.APIErrorPermissionDenied, permissionDenied.Error(), nil)
+	case errors.As(err, &notFound):
+		reqres.WriteError(w, r, reqres.APIErrorNotFound, notFound.Error(), nil)
+	case errors.As(err, &alreadyExists):
+		reqres.WriteError(w, r, reqres.APIErrorAlreadyExist, alreadyExists.Error(), nil)
+	default:
+		reqres.WriteError(w, r, reqres.APIErrorServerError, message, err)
+	}
 }
 
-func (h *appsHandler) webappTemplate(ctx context.Context, id *ocmpb.ShareId) (string, error) {
+// webappTemplate returns the received share and the template of the
+// URL of the app to open its resources.
+func (h *appsHandler) webappTemplate(ctx context.Context, id *ocmpb.ShareId) (*ocmpb.ReceivedShare, string, error) {
 	res, err := h.gatewayClient.GetReceivedOCMShare(ctx, &ocmpb.GetReceivedOCMShareRequest{
 		Ref: &ocmpb.ShareReference{
 			Spec: &ocmpb.ShareReference_Id{
@@ -107,21 +370,21 @@ func (h *appsHandler) webappTemplate(ctx context.Context, id *ocmpb.ShareId) (st
 		},
 	})
 	if err != nil {
-		return "", err
+		return nil, "", err
 	}
 	if res.Status.Code != rpcv1beta1.Code_CODE_OK {
 		if res.Status.Code == rpcv1beta1.Code_CODE_NOT_FOUND {
-			return "", errtypes.NotFound(res.Status.Message)
+			return nil, "", errtypes.NotFound(res.Status.Message)
 		}
-		return "", errtypes.InternalError(res.Status.Message)
+		return nil, "", errtypes.InternalError(res.Status.Message)
 	}
 
 	webapp, ok := getWebappProtocol(res.Share.Protocols)
 	if !ok {
-		return "", errtypes.BadRequest("share does not contain webapp protocol")
+		return res.Share, "", errtypes.BadRequest("share does not contain webapp protocol")
 	}
 
-	return webapp.UriTemplate, nil
+	return res.Share, webapp.UriTemplate, nil
 }
 
 func getWebappProtocol(protocols []*ocmpb.Protocol) (*ocmpb.WebappProtocol, bool) {
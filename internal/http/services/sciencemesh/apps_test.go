@@ -0,0 +1,400 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sciencemesh
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpcv1beta1 "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	ocmpb "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	providerpb "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/internal/http/services/datagateway"
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/rs/zerolog"
+	"go.opencensus.io/stats/view"
+	"google.golang.org/grpc"
+)
+
+type fakeGateway struct {
+	gateway.GatewayAPIClient
+	existing map[string]bool
+	// racing are the paths created by other clients between their stat and their creation
+	racing  map[string]bool
+	touched map[string]bool
+	// when set, the codes returned by Stat, InitiateFileUpload and TouchFile
+	statCode     rpcv1beta1.Code
+	uploadCode   rpcv1beta1.Code
+	touchCode    rpcv1beta1.Code
+	dataEndpoint string
+}
+
+func (g *fakeGateway) Stat(_ context.Context, req *providerpb.StatRequest, _ ...grpc.CallOption) (*providerpb.StatResponse, error) {
+	if g.statCode != rpcv1beta1.Code_CODE_INVALID {
+		return &providerpb.StatResponse{Status: &rpcv1beta1.Status{Code: g.statCode}}, nil
+	}
+	if g.existing[req.Ref.Path] {
+		return &providerpb.StatResponse{Status: &rpcv1beta1.Status{Code: rpcv1beta1.Code_CODE_OK}}, nil
+	}
+	return &providerpb.StatResponse{Status: &rpcv1beta1.Status{Code: rpcv1beta1.Code_CODE_NOT_FOUND}}, nil
+}
+
+func (g *fakeGateway) InitiateFileUpload(_ context.Context, req *providerpb.InitiateFileUploadRequest, _ ...grpc.CallOption) (*gateway.InitiateFileUploadResponse, error) {
+	if g.uploadCode != rpcv1beta1.Code_CODE_INVALID {
+		return &gateway.InitiateFileUploadResponse{Status: &rpcv1beta1.Status{Code: g.uploadCode}}, nil
+	}
+	if req.GetIfNotExist() && (g.existing[req.Ref.Path] || g.racing[req.Ref.Path]) {
+		g.existing[req.Ref.Path] = true
+		return &gateway.InitiateFileUploadResponse{Status: &rpcv1beta1.Status{Code: rpcv1beta1.Code_CODE_ALREADY_EXISTS}}, nil
+	}
+	return &gateway.InitiateFileUploadResponse{
+		Status: &rpcv1beta1.Status{Code: rpcv1beta1.Code_CODE_OK},
+		Protocols: []*gateway.FileUploadProtocol{
+			{Protocol: "simple", UploadEndpoint: g.dataEndpoint, Token: req.Ref.Path},
+		},
+	}, nil
+}
+
+func (g *fakeGateway) TouchFile(_ context.Context, req *providerpb.TouchFileRequest, _ ...grpc.CallOption) (*providerpb.TouchFileResponse, error) {
+	if g.touchCode != rpcv1beta1.Code_CODE_INVALID {
+		return &providerpb.TouchFileResponse{Status: &rpcv1beta1.Status{Code: g.touchCode}}, nil
+	}
+	if g.existing[req.Ref.Path] || g.racing[req.Ref.Path] {
+		g.existing[req.Ref.Path] = true
+		return &providerpb.TouchFileResponse{Status: &rpcv1beta1.Status{Code: rpcv1beta1.Code_CODE_ALREADY_EXISTS}}, nil
+	}
+	g.touched[req.Ref.Path] = true
+	return &providerpb.TouchFileResponse{Status: &rpcv1beta1.Status{Code: rpcv1beta1.Code_CODE_OK}}, nil
+}
+
+func (g *fakeGateway) GetReceivedOCMShare(_ context.Context, req *ocmpb.GetReceivedOCMShareRequest, _ ...grpc.CallOption) (*ocmpb.GetReceivedOCMShareResponse, error) {
+	if req.Ref.GetId().GetOpaqueId() != "share-id" {
+		return &ocmpb.GetReceivedOCMShareResponse{Status: &rpcv1beta1.Status{Code: rpcv1beta1.Code_CODE_NOT_FOUND}}, nil
+	}
+	return &ocmpb.GetReceivedOCMShareResponse{
+		Status: &rpcv1beta1.Status{Code: rpcv1beta1.Code_CODE_OK},
+		Share: &ocmpb.ReceivedShare{
+			Owner: &userpb.UserId{Idp: "cernbox.cern.ch", OpaqueId: "einstein"},
+			Protocols: []*ocmpb.Protocol{
+				{Term: &ocmpb.Protocol_WebappOptions{WebappOptions: &ocmpb.WebappProtocol{
+					UriTemplate: "https://app.example.org/s/hash/{relative-path-to-shared-resource}",
+				}}},
+			},
+		},
+	}, nil
+}
+
+func TestCreateInApp(t *testing.T) {
+	tests := []struct {
+		name       string
+		folder     string
+		filename   string
+		existing   []string
+		racing     []string
+		statCode   rpcv1beta1.Code
+		uploadCode rpcv1beta1.Code
+		touchCode  rpcv1beta1.Code
+		status     int
+		appURL     string
+		created    string
+		touched    string
+		content    string
+	}{
+		{
+			name:     "empty file",
+			filename: "notes.txt",
+			status:   http.StatusOK,
+			appURL:   "https://app.example.org/s/hash/docs/notes.txt",
+			touched:  "/ocm/share-id/docs/notes.txt",
+		},
+		{
+			name:      "empty file permission denied",
+			filename:  "notes.txt",
+			touchCode: rpcv1beta1.Code_CODE_PERMISSION_DENIED,
+			status:    http.StatusForbidden,
+		},
+		{
+			name:     "empty file created concurrently",
+			filename: "notes.txt",
+			racing:   []string{"/ocm/share-id/docs/notes.txt"},
+			status:   http.StatusOK,
+			appURL:   "https://app.example.org/s/hash/docs/notes (1).txt",
+			touched:  "/ocm/share-id/docs/notes (1).txt",
+		},
+		{
+			name:     "from template",
+			filename: "report.DOCX",
+			status:   http.StatusOK,
+			appURL:   "https://app.example.org/s/hash/docs/report.DOCX",
+			created:  "/ocm/share-id/docs/report.DOCX",
+			content:  "docx template",
+		},
+		{
+			name:     "name collision",
+			filename: "report.docx",
+			existing: []string{"/ocm/share-id/docs/report.docx", "/ocm/share-id/docs/report (1).docx"},
+			status:   http.StatusOK,
+			appURL:   "https://app.example.org/s/hash/docs/report (2).docx",
+			created:  "/ocm/share-id/docs/report (2).docx",
+			content:  "docx template",
+		},
+		{
+			name:     "file created concurrently",
+			filename: "report.docx",
+			racing:   []string{"/ocm/share-id/docs/report.docx"},
+			status:   http.StatusOK,
+			appURL:   "https://app.example.org/s/hash/docs/report (1).docx",
+			created:  "/ocm/share-id/docs/report (1).docx",
+			content:  "docx template",
+		},
+		{
+			name:     "always created concurrently",
+			filename: "report.docx",
+			racing:   []string{"/ocm/share-id/docs/report.docx", "/ocm/share-id/docs/report (1).docx", "/ocm/share-id/docs/report (2).docx"},
+			status:   http.StatusConflict,
+		},
+		{
+			name:     "folder out of the received shares",
+			folder:   "/home/einstein",
+			filename: "report.docx",
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "folder escaping the received shares",
+			folder:   "/ocm/../home/einstein",
+			filename: "report.docx",
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "path in the filename",
+			filename: "../report.docx",
+			status:   http.StatusBadRequest,
+		},
+		{
+			name:     "stat permission denied",
+			filename: "report.docx",
+			statCode: rpcv1beta1.Code_CODE_PERMISSION_DENIED,
+			status:   http.StatusForbidden,
+		},
+		{
+			name:       "upload permission denied",
+			filename:   "report.docx",
+			uploadCode: rpcv1beta1.Code_CODE_PERMISSION_DENIED,
+			status:     http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			uploaded := map[string]string{}
+			data := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				b, _ := io.ReadAll(r.Body)
+				uploaded[r.Header.Get(datagateway.TokenTransportHeader)] = string(b)
+			}))
+			defer data.Close()
+
+			existing := map[string]bool{}
+			for _, p := range tt.existing {
+				existing[p] = true
+			}
+			racing := map[string]bool{}
+			for _, p := range tt.racing {
+				racing[p] = true
+			}
+			gw := &fakeGateway{
+				existing:     existing,
+				racing:       racing,
+				touched:      map[string]bool{},
+				statCode:     tt.statCode,
+				uploadCode:   tt.uploadCode,
+				touchCode:    tt.touchCode,
+				dataEndpoint: data.URL,
+			}
+			h := &appsHandler{
+				gatewayClient: gw,
+				ocmMountPoint: "/ocm",
+				templates:     map[string][]byte{".docx": []byte("docx template")},
+			}
+
+			folder := tt.folder
+			if folder == "" {
+				folder = "/ocm/share-id/docs"
+			}
+			form := url.Values{"folder": {folder}, "filename": {tt.filename}}
+			r := httptest.NewRequest(http.MethodPost, "/create-in-app", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			h.CreateInApp(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, expected %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			var res map[string]string
+			if err := json.NewDecoder(w.Body).Decode(&res); err != nil {
+				t.Fatalf("error decoding the response: %v", err)
+			}
+			if res["app_url"] != tt.appURL {
+				t.Fatalf("got app url %q, expected %q", res["app_url"], tt.appURL)
+			}
+			if tt.touched != "" {
+				if !gw.touched[tt.touched] || len(uploaded) != 0 {
+					t.Fatalf("%s not touched: %v, uploaded %v", tt.touched, gw.touched, uploaded)
+				}
+				return
+			}
+			content, ok := uploaded[tt.created]
+			if !ok {
+				t.Fatalf("%s not created: %v", tt.created, uploaded)
+			}
+			if content != tt.content {
+				t.Fatalf("got content %q, expected %q", content, tt.content)
+			}
+		})
+	}
+}
+
+// appOpensCount returns the number of resources opened in the apps
+// recorded in the metrics for the provider with the given outcome.
+func appOpensCount(t *testing.T, provider, outcome string) int64 {
+	rows, err := view.RetrieveData(appOpens.Name())
+	if err != nil {
+		t.Fatalf("error retrieving the metrics: %v", err)
+	}
+	for _, r := range rows {
+		tags := map[string]string{}
+		for _, t := range r.Tags {
+			tags[t.Key.Name()] = t.Value
+		}
+		if tags["action"] == actionOpen && tags["provider"] == provider && tags["outcome"] == outcome {
+			return r.Data.(*view.CountData).Value
+		}
+	}
+	return 0
+}
+
+func TestOpenInAppAudit(t *testing.T) {
+	if err := registerViews(); err != nil {
+		t.Fatalf("error registering the views: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		file           string
+		auditResources string
+		status         int
+		provider       string
+		outcome        string
+		resource       string
+	}{
+		{
+			name:           "hashed resource",
+			file:           "/ocm/share-id/docs/notes.txt",
+			auditResources: auditResourcesHash,
+			status:         http.StatusOK,
+			provider:       "cernbox.cern.ch",
+			outcome:        "ok",
+			resource:       "de063167de20b077ca2c2b91f52afcb7d85ee7b9d354a60880c89367f3dcf7cd",
+		},
+		{
+			name:           "plain resource",
+			file:           "/ocm/share-id/docs/notes.txt",
+			auditResources: auditResourcesPlain,
+			status:         http.StatusOK,
+			provider:       "cernbox.cern.ch",
+			outcome:        "ok",
+			resource:       "share-id/docs/notes.txt",
+		},
+		{
+			name:           "no resource",
+			file:           "/ocm/share-id/docs/notes.txt",
+			auditResources: auditResourcesNone,
+			status:         http.StatusOK,
+			provider:       "cernbox.cern.ch",
+			outcome:        "ok",
+		},
+		{
+			name:           "share not found",
+			file:           "/ocm/other-id/docs/notes.txt",
+			auditResources: auditResourcesPlain,
+			status:         http.StatusNotFound,
+			outcome:        "not_found",
+			resource:       "other-id/docs/notes.txt",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &appsHandler{
+				gatewayClient:  &fakeGateway{},
+				ocmMountPoint:  "/ocm",
+				auditResources: tt.auditResources,
+			}
+
+			var logs bytes.Buffer
+			log := zerolog.New(&logs)
+			ctx := appctx.WithLogger(context.Background(), &log)
+			ctx = ctxpkg.ContextSetUser(ctx, &userpb.User{Id: &userpb.UserId{Idp: "example.org", OpaqueId: "marie"}})
+
+			before := appOpensCount(t, tt.provider, tt.outcome)
+			form := url.Values{"file": {tt.file}}
+			r := httptest.NewRequest(http.MethodPost, "/open-in-app", strings.NewReader(form.Encode())).WithContext(ctx)
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			w := httptest.NewRecorder()
+			h.OpenInApp(w, r)
+
+			if w.Code != tt.status {
+				t.Fatalf("got status %d, expected %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if got := appOpensCount(t, tt.provider, tt.outcome) - before; got != 1 {
+				t.Fatalf("got %d more app opens in the metrics, expected 1", got)
+			}
+
+			var entry map[string]any
+			if err := json.Unmarshal(logs.Bytes(), &entry); err != nil {
+				t.Fatalf("error decoding the log entry %q: %v", logs.String(), err)
+			}
+			expected := map[string]any{"user": "marie", "user_idp": "example.org", "action": actionOpen, "provider": tt.provider, "outcome": tt.outcome}
+			if tt.status == http.StatusOK {
+				expected["app"] = "app.example.org"
+			}
+			for k, v := range expected {
+				if entry[k] != v {
+					t.Fatalf("got %s %v in the log entry, expected %v: %v", k, entry[k], v, entry)
+				}
+			}
+			if resource, ok := entry["resource"]; tt.resource == "" && ok || tt.resource != "" && resource != tt.resource {
+				t.Fatalf("got resource %v in the log entry, expected %q", resource, tt.resource)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sciencemesh
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+
+	ocmpb "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// The ways the resources opened in the apps are recorded in the logs.
+const (
+	auditResourcesHash  = "hash"
+	auditResourcesPlain = "plain"
+	auditResourcesNone  = "none"
+)
+
+// The actions leading to a resource opened in an app.
+const (
+	actionOpen   = "open"
+	actionCreate = "create"
+)
+
+var (
+	appOpens = stats.Int64("sciencemesh_app_opens_total", "The number of resources of the received OCM shares opened in the remote apps", stats.UnitDimensionless)
+
+	actionKey   = tag.MustNewKey("action")
+	providerKey = tag.MustNewKey("provider")
+	outcomeKey  = tag.MustNewKey("outcome")
+
+	registerOnce    sync.Once
+	errRegistration error
+)
+
+func registerViews() error {
+	registerOnce.Do(func() {
+		errRegistration = view.Register(&view.View{
+			Name:        appOpens.Name(),
+			Description: appOpens.Description(),
+			Measure:     appOpens,
+			TagKeys:     []tag.Key{actionKey, providerKey, outcomeKey},
+			Aggregation: view.Count(),
+		})
+	})
+	return errRegistration
+}
+
+// audit records in the logs and in the metrics that the user opened, or
+// failed to open, the resource rel of the received share in the app at appURL.
+// The share is nil when it could not be retrieved.
+// Failing to audit never fails the request.
+func (h *appsHandler) audit(ctx context.Context, action string, id *ocmpb.ShareId, share *ocmpb.ReceivedShare, rel, appURL string, err error) {
+	provider := share.GetOwner().GetIdp()
+	outcome := auditOutcome(err)
+	_ = stats.RecordWithTags(ctx,
+		[]tag.Mutator{tag.Upsert(actionKey, action), tag.Upsert(providerKey, provider), tag.Upsert(outcomeKey, outcome)},
+		appOpens.M(1))
+
+	log := appctx.GetLogger(ctx)
+	event := log.Info()
+	if err != nil {
+		event = log.Warn().Err(err)
+	}
+	if u, ok := ctxpkg.ContextGetUser(ctx); ok {
+		event = event.Str("user", u.GetId().GetOpaqueId()).Str("user_idp", u.GetId().GetIdp())
+	}
+	if resource, ok := h.auditResource(id, rel); ok {
+		event = event.Str("resource", resource)
+	}
+	var app string
+	if u, err := url.Parse(appURL); err == nil {
+		app = u.Host
+	}
+	event.Str("action", action).Str("provider", provider).Str("app", app).Str("outcome", outcome).Msg("sciencemesh: resource opened in app")
+}
+
+// auditResource returns the identifier of the resource rel of the share,
+// as configured to be recorded in the logs.
+func (h *appsHandler) auditResource(id *ocmpb.ShareId, rel string) (string, bool) {
+	resource := fmt.Sprintf("%s/%s", id.GetOpaqueId(), rel)
+	switch h.auditResources {
+	case auditResourcesPlain:
+		return resource, true
+	case auditResourcesNone:
+		return "", false
+	default:
+		sum := sha256.Sum256([]byte(resource))
+		return hex.EncodeToString(sum[:]), true
+	}
+}
+
+func auditOutcome(err error) string {
+	var notFound errtypes.NotFound
+	switch {
+	case err == nil:
+		return "ok"
+	case errors.As(err, &notFound):
+		return "not_found"
+	default:
+		return "error"
+	}
+}
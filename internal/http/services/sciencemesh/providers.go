@@ -23,17 +23,26 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/ReneKroon/ttlcache/v2"
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	providerpb "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	"github.com/cs3org/reva/internal/http/services/reqres"
+	"github.com/cs3org/reva/pkg/appctx"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
+	"github.com/cs3org/reva/pkg/rhttp"
 )
 
 type providersHandler struct {
 	gatewayClient gateway.GatewayAPIClient
+	httpClient    *http.Client
+	checkCache    *ttlcache.Cache
+	concurrency   int
 }
 
 func (h *providersHandler) init(ctx context.Context, c *config) error {
@@ -43,12 +52,29 @@ func (h *providersHandler) init(ctx context.Context, c *config) error {
 		return err
 	}
 
+	h.httpClient = rhttp.GetHTTPClient(
+		rhttp.Timeout(time.Duration(c.ProvidersCheckTimeout)*time.Second),
+		rhttp.Insecure(c.ProvidersCheckInsecure),
+	)
+	h.checkCache = ttlcache.NewCache()
+	_ = h.checkCache.SetTTL(time.Duration(c.ProvidersCheckCacheTTL) * time.Second)
+	h.checkCache.SkipTTLExtensionOnHit(true)
+	h.concurrency = c.ProvidersCheckConcurrency
+
 	return nil
 }
 
 type provider struct {
-	FullName string `json:"full_name"`
-	Domain   string `json:"domain"`
+	FullName    string     `json:"full_name"`
+	Domain      string     `json:"domain"`
+	Online      *bool      `json:"online,omitempty"`
+	LastChecked *time.Time `json:"last_checked,omitempty"`
+}
+
+// providerStatus is the result of probing the OCM endpoint of a provider.
+type providerStatus struct {
+	online      bool
+	lastChecked time.Time
 }
 
 // ListProviders lists all the providers filtering by the `search` query parameter.
@@ -68,6 +94,7 @@ func (h *providersHandler) ListProviders(w http.ResponseWriter, r *http.Request)
 	}
 
 	filtered := []*provider{}
+	infos := []*providerpb.ProviderInfo{}
 	for _, p := range listRes.Providers {
 		if strings.Contains(strings.ToLower(p.FullName), term) ||
 			strings.Contains(strings.ToLower(p.Domain), term) {
@@ -75,9 +102,14 @@ func (h *providersHandler) ListProviders(w http.ResponseWriter, r *http.Request)
 				FullName: p.FullName,
 				Domain:   p.Domain,
 			})
+			infos = append(infos, p)
 		}
 	}
 
+	if check, _ := strconv.ParseBool(r.URL.Query().Get("check")); check {
+		h.checkProviders(ctx, filtered, infos)
+	}
+
 	if err := json.NewEncoder(w).Encode(filtered); err != nil {
 		reqres.WriteError(w, r, reqres.APIErrorServerError, "error encoding response in json", err)
 		return
@@ -86,3 +118,76 @@ func (h *providersHandler) ListProviders(w http.ResponseWriter, r *http.Request)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 }
+
+// checkProviders probes the OCM endpoint of the given providers, with a bounded
+// number of concurrent requests, and sets their reachability status.
+func (h *providersHandler) checkProviders(ctx context.Context, providers []*provider, infos []*providerpb.ProviderInfo) {
+	sem := make(chan struct{}, h.concurrency)
+	var wg sync.WaitGroup
+
+	for i := range providers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(p *provider, info *providerpb.ProviderInfo) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			st := h.getProviderStatus(ctx, info)
+			p.Online = &st.online
+			p.LastChecked = &st.lastChecked
+		}(providers[i], infos[i])
+	}
+	wg.Wait()
+}
+
+// getProviderStatus returns the reachability status of the provider,
+// from the cache if it was recently checked.
+func (h *providersHandler) getProviderStatus(ctx context.Context, info *providerpb.ProviderInfo) *providerStatus {
+	if v, err := h.checkCache.Get(info.Domain); err == nil {
+		return v.(*providerStatus)
+	}
+
+	st := &providerStatus{
+		online:      h.isOnline(ctx, info),
+		lastChecked: time.Now(),
+	}
+	_ = h.checkCache.Set(info.Domain, st)
+	return st
+}
+
+// isOnline sends a HEAD request to the OCM discovery endpoint of the provider.
+// The provider is considered online if it responds without a server error.
+func (h *providersHandler) isOnline(ctx context.Context, info *providerpb.ProviderInfo) bool {
+	log := appctx.GetLogger(ctx)
+
+	endpoint, err := getOCMEndpoint(info)
+	if err != nil {
+		return false
+	}
+
+	// the reva token must not be forwarded to remote providers,
+	// so rhttp.NewRequest is not used here
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, strings.TrimSuffix(endpoint, "/")+"/ocm-provider", nil)
+	if err != nil {
+		log.Debug().Err(err).Str("domain", info.Domain).Msg("error creating request to check provider")
+		return false
+	}
+	res, err := h.httpClient.Do(req)
+	if err != nil {
+		log.Debug().Err(err).Str("domain", info.Domain).Msg("provider is not reachable")
+		return false
+	}
+	defer res.Body.Close()
+
+	return res.StatusCode < http.StatusInternalServerError
+}
+
+func getOCMEndpoint(info *providerpb.ProviderInfo) (string, error) {
+	for _, s := range info.Services {
+		if s.Endpoint.Type.Name == "OCM" {
+			return s.Endpoint.Path, nil
+		}
+	}
+	return "", errors.New("ocm endpoint not specified for mesh provider")
+}
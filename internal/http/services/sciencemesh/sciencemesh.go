@@ -79,12 +79,38 @@ type config struct {
 	BodyTemplatePath   string                      `mapstructure:"body_template_path"`
 	OCMMountPoint      string                      `mapstructure:"ocm_mount_point"`
 	InviteLinkTemplate string                      `mapstructure:"invite_link_template"`
+
+	// AppTemplates maps a file extension to the template
+	// used to create the new files with that extension.
+	AppTemplates          map[string]string `mapstructure:"app_templates"`
+	DataTransfersInsecure bool              `mapstructure:"data_transfers_insecure"`
+
+	// AuditResources sets how the resources opened in the apps are recorded
+	// in the logs: hashed (hash, the default), as they are (plain), or not at all (none).
+	AuditResources string `mapstructure:"audit_resources"`
+
+	ProvidersCheckTimeout     int  `mapstructure:"providers_check_timeout"`
+	ProvidersCheckCacheTTL    int  `mapstructure:"providers_check_cache_ttl"`
+	ProvidersCheckConcurrency int  `mapstructure:"providers_check_concurrency"`
+	ProvidersCheckInsecure    bool `mapstructure:"providers_check_insecure"`
 }
 
 func (c *config) init() {
 	if c.Prefix == "" {
 		c.Prefix = "sciencemesh"
 	}
+	if c.AuditResources == "" {
+		c.AuditResources = auditResourcesHash
+	}
+	if c.ProvidersCheckTimeout == 0 {
+		c.ProvidersCheckTimeout = 2
+	}
+	if c.ProvidersCheckCacheTTL == 0 {
+		c.ProvidersCheckCacheTTL = 300
+	}
+	if c.ProvidersCheckConcurrency == 0 {
+		c.ProvidersCheckConcurrency = 10
+	}
 
 	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
 }
@@ -116,6 +142,7 @@ func (s *svc) routerInit(ctx context.Context) error {
 	s.router.Get("/find-accepted-users", tokenHandler.FindAccepted)
 	s.router.Get("/list-providers", providersHandler.ListProviders)
 	s.router.Post("/open-in-app", appsHandler.OpenInApp)
+	s.router.Post("/create-in-app", appsHandler.CreateInApp)
 
 	return nil
 }
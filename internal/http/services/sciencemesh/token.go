@@ -25,6 +25,7 @@ import (
 	"html/template"
 	"mime"
 	"net/http"
+	"strconv"
 	"strings"
 
 	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
@@ -35,6 +36,7 @@ import (
 	"github.com/cs3org/reva/internal/http/services/reqres"
 	"github.com/cs3org/reva/pkg/appctx"
 	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/ocm/invite"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/pkg/smtpclient"
 )
@@ -244,24 +246,63 @@ func getAcceptInviteRequest(r *http.Request) (*acceptInviteRequest, error) {
 	return &req, nil
 }
 
-// FindAccepted returns the list of all the users that accepted the invitation
-// to the authenticated user.
+// FindAccepted returns the list of the users that accepted the invitation
+// to the authenticated user, sorted by display name.
+// The users can be filtered with the `filter` query parameter, and paginated
+// with the `page` (starting from 1) and `size` query parameters.
 func (h *tokenHandler) FindAccepted(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	res, err := h.gatewayClient.FindAcceptedUsers(ctx, &invitepb.FindAcceptedUsersRequest{})
+	req, err := getFindAcceptedUsersRequest(r)
+	if err != nil {
+		reqres.WriteError(w, r, reqres.APIErrorInvalidParameter, err.Error(), err)
+		return
+	}
+
+	res, err := h.gatewayClient.FindAcceptedUsers(ctx, req)
 	if err != nil {
 		reqres.WriteError(w, r, reqres.APIErrorServerError, "error sending a grpc find accepted users request", err)
 		return
 	}
 
+	switch res.Status.Code {
+	case rpc.Code_CODE_OK:
+	case rpc.Code_CODE_INVALID_ARGUMENT:
+		reqres.WriteError(w, r, reqres.APIErrorInvalidParameter, res.Status.Message, errors.New(res.Status.Message))
+		return
+	default:
+		reqres.WriteError(w, r, reqres.APIErrorServerError, res.Status.Message, errors.New(res.Status.Message))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(res.AcceptedUsers); err != nil {
 		reqres.WriteError(w, r, reqres.APIErrorServerError, "error marshalling token data", err)
 		return
 	}
+}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
+func getFindAcceptedUsersRequest(r *http.Request) (*invitepb.FindAcceptedUsersRequest, error) {
+	q := r.URL.Query()
+	req := &invitepb.FindAcceptedUsersRequest{Filter: q.Get("filter")}
+	if !q.Has("page") && !q.Has("size") {
+		return req, nil
+	}
+
+	page := &invite.Page{Number: 1}
+	var err error
+	if v := q.Get("page"); v != "" {
+		if page.Number, err = strconv.Atoi(v); err != nil || page.Number < 1 {
+			return nil, errors.New("invalid page " + v)
+		}
+	}
+	if v := q.Get("size"); v != "" {
+		if page.Size, err = strconv.Atoi(v); err != nil || page.Size < 0 {
+			return nil, errors.New("invalid size " + v)
+		}
+	}
+	invite.SetPage(req, page)
+	return req, nil
 }
 
 func (h *tokenHandler) ListInvite(w http.ResponseWriter, r *http.Request) {
@@ -19,7 +19,9 @@
 package siteacc
 
 import (
+	"context"
 	"net/http"
+	"time"
 
 	"github.com/cs3org/reva/pkg/rhttp/global"
 	"github.com/cs3org/reva/pkg/siteacc"
@@ -33,6 +35,8 @@ import (
 const serviceName = "siteacc"
 const tracerName = "siteacc"
 
+const closeTimeout = 10 * time.Second
+
 func init() {
 	global.Register(serviceName, New)
 }
@@ -47,7 +51,11 @@ type svc struct {
 
 // Close is called when this service is being stopped.
 func (s *svc) Close() error {
-	return nil
+	// Give the pending alerts and emails some time to be sent
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+
+	return s.siteacc.Close(ctx)
 }
 
 // Prefix returns the main endpoint of this service.
@@ -67,6 +75,8 @@ func (s *svc) Handler() http.Handler {
 
 func parseConfig(m map[string]interface{}) (*config.Configuration, error) {
 	conf := &config.Configuration{}
+	// The retries are set before decoding, as 0 disables them
+	conf.Connectors.Retries = 3
 	if err := mapstructure.Decode(m, &conf); err != nil {
 		return nil, errors.Wrap(err, "error decoding configuration")
 	}
@@ -101,6 +111,14 @@ func applyDefaultConfig(conf *config.Configuration) {
 	if conf.Webserver.SessionTimeout < 60 {
 		conf.Webserver.SessionTimeout = 5 * 60
 	}
+
+	// Verification emails are valid for one day and can be resent every 10 minutes by default
+	if conf.Verification.TokenTimeout == 0 {
+		conf.Verification.TokenTimeout = 24 * 60 * 60
+	}
+	if conf.Verification.ResendInterval == 0 {
+		conf.Verification.ResendInterval = 10 * 60
+	}
 }
 
 // New returns a new Site Accounts service.
@@ -20,6 +20,7 @@ package app
 
 import (
 	"context"
+	"encoding/json"
 
 	appprovider "github.com/cs3org/go-cs3apis/cs3/app/provider/v1beta1"
 	registry "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
@@ -44,3 +45,53 @@ type Provider interface {
 	GetAppURL(ctx context.Context, resource *provider.ResourceInfo, viewMode appprovider.ViewMode, token string, opaqueMap map[string]*typespb.OpaqueEntry, language string) (*appprovider.OpenInAppURL, error)
 	GetAppProviderInfo(ctx context.Context) (*registry.ProviderInfo, error)
 }
+
+// CapabilitiesOpaqueKey is the key of the opaque entry of a provider info
+// holding the capabilities of the app provider for each mime type.
+const CapabilitiesOpaqueKey = "capabilities"
+
+// MimeTypeCapabilities describes what an app provider can do
+// with the files of a given mime type.
+type MimeTypeCapabilities struct {
+	View      bool   `json:"view" mapstructure:"view"`
+	Edit      bool   `json:"edit" mapstructure:"edit"`
+	Create    bool   `json:"create" mapstructure:"create"`
+	Extension string `json:"extension,omitempty" mapstructure:"extension"`
+}
+
+// GetCapabilities returns the capabilities per mime type announced
+// in the opaque of the given provider info, if any.
+func GetCapabilities(p *registry.ProviderInfo) (map[string]*MimeTypeCapabilities, error) {
+	if p.Opaque == nil || p.Opaque.Map == nil {
+		return nil, nil
+	}
+	entry, ok := p.Opaque.Map[CapabilitiesOpaqueKey]
+	if !ok {
+		return nil, nil
+	}
+	var caps map[string]*MimeTypeCapabilities
+	if err := json.Unmarshal(entry.Value, &caps); err != nil {
+		return nil, err
+	}
+	return caps, nil
+}
+
+// SetCapabilities stores the capabilities per mime type
+// in the opaque of the given provider info.
+func SetCapabilities(p *registry.ProviderInfo, caps map[string]*MimeTypeCapabilities) error {
+	v, err := json.Marshal(caps)
+	if err != nil {
+		return err
+	}
+	if p.Opaque == nil {
+		p.Opaque = &typespb.Opaque{}
+	}
+	if p.Opaque.Map == nil {
+		p.Opaque.Map = map[string]*typespb.OpaqueEntry{}
+	}
+	p.Opaque.Map[CapabilitiesOpaqueKey] = &typespb.OpaqueEntry{
+		Decoder: "json",
+		Value:   v,
+	}
+	return nil
+}
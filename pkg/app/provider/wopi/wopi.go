@@ -337,26 +337,49 @@ func (p *wopiProvider) GetAppURL(ctx context.Context, resource *provider.Resourc
 }
 
 func (p *wopiProvider) GetAppProviderInfo(ctx context.Context) (*appregistry.ProviderInfo, error) {
-	// Initially we store the mime types in a map to avoid duplicates
-	mimeTypesMap := make(map[string]bool)
-	for _, extensions := range p.appURLs {
+	// Initially we store the mime types in a map to avoid duplicates,
+	// together with the capabilities of the app for each of them
+	caps := make(map[string]*app.MimeTypeCapabilities)
+	for access, extensions := range p.appURLs {
 		for ext := range extensions {
 			m := mime.Detect(false, ext)
-			mimeTypesMap[m] = true
+			c, ok := caps[m]
+			if !ok {
+				c = &app.MimeTypeCapabilities{}
+				caps[m] = c
+			}
+			switch access {
+			case "view":
+				c.View = true
+			case "edit":
+				// an app able to edit a file is also able to edit a newly created one
+				c.Edit = true
+				c.Create = true
+			case "editnew":
+				// keep the choice of the extension stable across registrations
+				e := strings.TrimPrefix(ext, ".")
+				if c.Extension == "" || e < c.Extension {
+					c.Extension = e
+				}
+			}
 		}
 	}
 
-	mimeTypes := make([]string, 0, len(mimeTypesMap))
-	for m := range mimeTypesMap {
+	mimeTypes := make([]string, 0, len(caps))
+	for m := range caps {
 		mimeTypes = append(mimeTypes, m)
 	}
 
-	return &appregistry.ProviderInfo{
+	pInfo := &appregistry.ProviderInfo{
 		Name:        p.conf.AppName,
 		Icon:        p.conf.AppIconURI,
 		DesktopOnly: p.conf.AppDesktopOnly,
 		MimeTypes:   mimeTypes,
-	}, nil
+	}
+	if err := app.SetCapabilities(pInfo, caps); err != nil {
+		return nil, err
+	}
+	return pInfo, nil
 }
 
 func getAppURLs(c *config) (map[string]map[string]string, error) {
@@ -21,15 +21,18 @@ package static
 import (
 	"container/heap"
 	"context"
-	"fmt"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	registrypb "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	"github.com/cs3org/reva/pkg/app"
 	"github.com/cs3org/reva/pkg/app/registry/registry"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/golang/protobuf/proto" //nolint:staticcheck
 	"github.com/mitchellh/mapstructure"
 	"github.com/rs/zerolog/log"
 	orderedmap "github.com/wk8/go-ordered-map"
@@ -49,12 +52,56 @@ type mimeTypeConfig struct {
 	Icon          string `mapstructure:"icon"`
 	DefaultApp    string `mapstructure:"default_app"`
 	AllowCreation bool   `mapstructure:"allow_creation"`
-	apps          providerHeap
+	// AppCapabilities overrides the capabilities of the app providers
+	// for this mime type, indexed by the name or the address of the provider.
+	AppCapabilities map[string]*app.MimeTypeCapabilities `mapstructure:"app_capabilities"`
+	apps            providerHeap
 }
 
 type config struct {
 	Providers []*registrypb.ProviderInfo `mapstructure:"providers"`
 	MimeTypes []*mimeTypeConfig          `mapstructure:"mime_types"`
+	// DefaultToSingleProvider makes the only provider of a mime type
+	// its default one, when no default is configured.
+	DefaultToSingleProvider bool `mapstructure:"default_to_single_provider"`
+	// ProviderTTL is the time in seconds after which the app providers
+	// that did not register again are evicted. Disabled if 0.
+	// The providers of the configuration that never register are not evicted.
+	ProviderTTL int `mapstructure:"provider_ttl"`
+	// restrictions are configured alongside each provider entry
+	restrictions []*providerRestrictions
+}
+
+// providerRestrictions limits the users who can see an app provider,
+// identified by its address or its name, for example during a pilot.
+// A provider without allowed users nor groups is visible to everyone.
+type providerRestrictions struct {
+	Address       string   `mapstructure:"address"`
+	Name          string   `mapstructure:"name"`
+	AllowedUsers  []string `mapstructure:"allowed_users"`
+	AllowedGroups []string `mapstructure:"allowed_groups"`
+}
+
+func (r *providerRestrictions) restricted() bool {
+	return len(r.AllowedUsers) != 0 || len(r.AllowedGroups) != 0
+}
+
+// allows checks whether the user, matched by username or opaque id,
+// or one of its groups is allowed to see the provider.
+func (r *providerRestrictions) allows(u *userpb.User) bool {
+	for _, allowed := range r.AllowedUsers {
+		if allowed == u.Username || allowed == u.GetId().GetOpaqueId() {
+			return true
+		}
+	}
+	for _, allowed := range r.AllowedGroups {
+		for _, g := range u.Groups {
+			if allowed == g {
+				return true
+			}
+		}
+	}
+	return false
 }
 
 func (c *config) init() {
@@ -68,12 +115,23 @@ func parseConfig(m map[string]interface{}) (*config, error) {
 	if err := mapstructure.Decode(m, c); err != nil {
 		return nil, err
 	}
+	if err := mapstructure.Decode(m["providers"], &c.restrictions); err != nil {
+		return nil, err
+	}
 	return c, nil
 }
 
 type manager struct {
 	providers map[string]*registrypb.ProviderInfo
-	mimetypes *orderedmap.OrderedMap // map[string]*mimeTypeConfig  ->  map the mime type to the addresses of the corresponding providers
+	// lastSeen keeps the time of the last registration
+	// of each provider, indexed by address
+	lastSeen    map[string]time.Time
+	providerTTL time.Duration
+	// restrictions indexed by address and by name of the provider
+	restrictions map[string]*providerRestrictions
+	mimetypes    *orderedmap.OrderedMap // map[string]*mimeTypeConfig  ->  map the mime type to the addresses of the corresponding providers
+	// defaultToSingleProvider is set from the configuration
+	defaultToSingleProvider bool
 	sync.RWMutex
 }
 
@@ -106,15 +164,35 @@ func New(m map[string]interface{}) (app.Registry, error) {
 					mtc := v.(*mimeTypeConfig)
 					registerProvider(p, mtc)
 				} else {
-					return nil, errtypes.NotFound(fmt.Sprintf("mimetype %s not found in the configuration", m))
+					// served without a name, an icon nor a description,
+					// unless the app registry service has them in its catalogue
+					mimetypes.Set(m, dummyMimeType(m, []*registrypb.ProviderInfo{p}))
 				}
 			}
 		}
 	}
 
+	restrictions := make(map[string]*providerRestrictions)
+	for _, r := range c.restrictions {
+		if r == nil || !r.restricted() {
+			continue
+		}
+		if r.Address != "" {
+			restrictions[r.Address] = r
+		}
+		if r.Name != "" {
+			restrictions[r.Name] = r
+		}
+	}
+
 	newManager := manager{
-		providers: providerMap,
-		mimetypes: mimetypes,
+		providers:    providerMap,
+		lastSeen:     make(map[string]time.Time),
+		providerTTL:  time.Duration(c.ProviderTTL) * time.Second,
+		restrictions: restrictions,
+		mimetypes:    mimetypes,
+
+		defaultToSingleProvider: c.DefaultToSingleProvider,
 	}
 	return &newManager, nil
 }
@@ -151,6 +229,8 @@ func getPriority(p *registrypb.ProviderInfo) uint64 {
 }
 
 func (m *manager) FindProviders(ctx context.Context, mimeType string) ([]*registrypb.ProviderInfo, error) {
+	m.evictExpired()
+
 	// find longest match
 	var match string
 
@@ -172,31 +252,55 @@ func (m *manager) FindProviders(ctx context.Context, mimeType string) ([]*regist
 	mimeMatch := mimeInterface.(*mimeTypeConfig)
 	var providers = make([]*registrypb.ProviderInfo, 0, len(mimeMatch.apps))
 	for _, p := range mimeMatch.apps {
-		providers = append(providers, m.providers[p.provider.Address])
+		if !m.isAllowed(ctx, p.provider.Address, p.provider.Name) {
+			continue
+		}
+		providers = append(providers, withCapabilities(m.providers[p.provider.Address], mimeMatch))
 	}
 	return providers, nil
 }
 
+// isAllowed checks whether the user in the context can see the provider
+// identified by the given address or name. Requests without a user
+// only see the unrestricted providers.
+func (m *manager) isAllowed(ctx context.Context, keys ...string) bool {
+	for _, k := range keys {
+		r, ok := m.restrictions[k]
+		if k == "" || !ok {
+			continue
+		}
+		u, ok := ctxpkg.ContextGetUser(ctx)
+		return ok && r.allows(u)
+	}
+	return true
+}
+
+// filterAllowed returns the providers the user in the context can see.
+func (m *manager) filterAllowed(ctx context.Context, providers []*registrypb.ProviderInfo) []*registrypb.ProviderInfo {
+	allowed := make([]*registrypb.ProviderInfo, 0, len(providers))
+	for _, p := range providers {
+		if m.isAllowed(ctx, p.Address, p.Name) {
+			allowed = append(allowed, p)
+		}
+	}
+	return allowed
+}
+
+// AddProvider registers an app provider, replacing any previous registration
+// with the same address, e.g. when a provider restarts with different mime types.
 func (m *manager) AddProvider(ctx context.Context, p *registrypb.ProviderInfo) error {
 	m.Lock()
 	defer m.Unlock()
 
-	// check if the provider was already registered
-	// if it's the case, we have to unregister it
-	// from all the old mime types
-	if oldP, ok := m.providers[p.Address]; ok {
-		oldMimeTypes := oldP.MimeTypes
-		for _, mimeName := range oldMimeTypes {
-			mimeIf, ok := m.mimetypes.Get(mimeName)
-			if !ok {
-				continue
-			}
-			mime := mimeIf.(*mimeTypeConfig)
-			unregisterProvider(p, mime)
-		}
+	if last, ok := m.lastSeen[p.Address]; ok {
+		log.Debug().Str("address", p.Address).Time("last_registration", last).Msg("app provider registered again, replacing the previous registration")
+	} else if _, ok := m.providers[p.Address]; ok {
+		log.Debug().Str("address", p.Address).Msg("app provider registered again, replacing the previous registration")
 	}
+	m.removeProvider(p.Address)
 
 	m.providers[p.Address] = p
+	m.lastSeen[p.Address] = time.Now()
 
 	for _, mime := range p.MimeTypes {
 		if mimeTypeInterface, ok := m.mimetypes.Get(mime); ok {
@@ -213,7 +317,48 @@ func (m *manager) AddProvider(ctx context.Context, p *registrypb.ProviderInfo) e
 	return nil
 }
 
+// removeProvider drops the provider registered with the given address
+// from the providers and from all the mime types it was registered to.
+// It's a no-op if no provider is registered with that address.
+func (m *manager) removeProvider(address string) {
+	old, ok := m.providers[address]
+	if !ok {
+		return
+	}
+	for _, mimeName := range old.MimeTypes {
+		mimeIf, ok := m.mimetypes.Get(mimeName)
+		if !ok {
+			continue
+		}
+		mime := mimeIf.(*mimeTypeConfig)
+		// match by address, the provider may have registered under a different name
+		for i := getIndexByAddress(mime.apps, address); i >= 0; i = getIndexByAddress(mime.apps, address) {
+			heap.Remove(&mime.apps, i)
+		}
+	}
+	delete(m.providers, address)
+	delete(m.lastSeen, address)
+}
+
+// evictExpired removes the app providers that did not register again within the ttl.
+func (m *manager) evictExpired() {
+	if m.providerTTL == 0 {
+		return
+	}
+	m.Lock()
+	defer m.Unlock()
+
+	for address, last := range m.lastSeen {
+		if time.Since(last) > m.providerTTL {
+			log.Info().Str("address", address).Time("last_registration", last).Msg("app provider not registered again within the ttl, evicting it")
+			m.removeProvider(address)
+		}
+	}
+}
+
 func (m *manager) ListProviders(ctx context.Context) ([]*registrypb.ProviderInfo, error) {
+	m.evictExpired()
+
 	m.RLock()
 	defer m.RUnlock()
 
@@ -221,10 +366,12 @@ func (m *manager) ListProviders(ctx context.Context) ([]*registrypb.ProviderInfo
 	for _, p := range m.providers {
 		providers = append(providers, p)
 	}
-	return providers, nil
+	return m.filterAllowed(ctx, providers), nil
 }
 
 func (m *manager) ListSupportedMimeTypes(ctx context.Context) ([]*registrypb.MimeTypeInfo, error) {
+	m.evictExpired()
+
 	m.RLock()
 	defer m.RUnlock()
 
@@ -233,15 +380,25 @@ func (m *manager) ListSupportedMimeTypes(ctx context.Context) ([]*registrypb.Mim
 	for pair := m.mimetypes.Oldest(); pair != nil; pair = pair.Next() {
 		mime := pair.Value.(*mimeTypeConfig)
 
+		providers := m.filterAllowed(ctx, mime.getProvidersWithCapabilities())
+		if len(providers) == 0 && len(mime.apps) != 0 {
+			// the mime type is only supported by providers the user cannot see
+			continue
+		}
+		defaultApp := mime.DefaultApp
+		if !m.isAllowed(ctx, defaultApp) {
+			defaultApp = ""
+		}
+
 		res = append(res, &registrypb.MimeTypeInfo{
 			MimeType:           mime.MimeType,
 			Ext:                mime.Extension,
 			Name:               mime.Name,
 			Description:        mime.Description,
 			Icon:               mime.Icon,
-			AppProviders:       mime.apps.getOrderedProviderByPriority(),
+			AppProviders:       providers,
 			AllowCreation:      mime.AllowCreation,
-			DefaultApplication: mime.DefaultApp,
+			DefaultApplication: defaultApp,
 		})
 	}
 
@@ -256,6 +413,52 @@ func (h providerHeap) getOrderedProviderByPriority() []*registrypb.ProviderInfo
 	return providers
 }
 
+func (mime *mimeTypeConfig) getProvidersWithCapabilities() []*registrypb.ProviderInfo {
+	providers := mime.apps.getOrderedProviderByPriority()
+	for i, p := range providers {
+		providers[i] = withCapabilities(p, mime)
+	}
+	return providers
+}
+
+// withCapabilities returns a copy of the provider info, holding in its opaque
+// the capabilities of the provider for the given mime type.
+// A copy is needed as the same provider is shared among several mime types.
+func withCapabilities(p *registrypb.ProviderInfo, mime *mimeTypeConfig) *registrypb.ProviderInfo {
+	c := proto.Clone(p).(*registrypb.ProviderInfo)
+	caps := map[string]*app.MimeTypeCapabilities{
+		mime.MimeType: getCapabilities(p, mime),
+	}
+	if err := app.SetCapabilities(c, caps); err != nil {
+		log.Warn().Err(err).Msgf("error setting capabilities of app provider %s", p.Address)
+	}
+	return c
+}
+
+// getCapabilities resolves the capabilities of a provider for a mime type.
+// The ones configured in the mime type take precedence over the ones
+// announced by the provider. If none of them is set, they are derived
+// from the capability of the provider and the mime type configuration.
+func getCapabilities(p *registrypb.ProviderInfo, mime *mimeTypeConfig) *app.MimeTypeCapabilities {
+	var caps app.MimeTypeCapabilities
+	if c, ok := mime.AppCapabilities[p.Address]; ok {
+		caps = *c
+	} else if c, ok := mime.AppCapabilities[p.Name]; ok && p.Name != "" {
+		caps = *c
+	} else if announced, err := app.GetCapabilities(p); err == nil && announced[mime.MimeType] != nil {
+		caps = *announced[mime.MimeType]
+	} else {
+		caps.View = true
+		caps.Edit = p.Capability != registrypb.ProviderInfo_CAPABILITY_VIEWER
+		caps.Create = caps.Edit && mime.AllowCreation
+	}
+
+	if caps.Extension == "" {
+		caps.Extension = mime.Extension
+	}
+	return &caps
+}
+
 func getIndex(h providerHeap, s *registrypb.ProviderInfo) (int, bool) {
 	for i, e := range h {
 		if equalsProviderInfo(e.provider, s) {
@@ -265,6 +468,15 @@ func getIndex(h providerHeap, s *registrypb.ProviderInfo) (int, bool) {
 	return -1, false
 }
 
+func getIndexByAddress(h providerHeap, address string) int {
+	for i, e := range h {
+		if e.provider.Address == address {
+			return i
+		}
+	}
+	return -1
+}
+
 func (m *manager) SetDefaultProviderForMimeType(ctx context.Context, mimeType string, p *registrypb.ProviderInfo) error {
 	m.Lock()
 	defer m.Unlock()
@@ -304,6 +516,8 @@ func dummyMimeType(m string, apps []*registrypb.ProviderInfo) *mimeTypeConfig {
 }
 
 func (m *manager) GetDefaultProviderForMimeType(ctx context.Context, mimeType string) (*registrypb.ProviderInfo, error) {
+	m.evictExpired()
+
 	m.RLock()
 	defer m.RUnlock()
 
@@ -311,13 +525,19 @@ func (m *manager) GetDefaultProviderForMimeType(ctx context.Context, mimeType st
 	if ok {
 		mime := mimeInterface.(*mimeTypeConfig)
 		// default by provider address
-		if p, ok := m.providers[mime.DefaultApp]; ok {
+		if p, ok := m.providers[mime.DefaultApp]; ok && m.isAllowed(ctx, p.Address, p.Name) {
 			return p, nil
 		}
 
 		// default by provider name
 		for _, p := range m.providers {
-			if p.Name == mime.DefaultApp {
+			if p.Name == mime.DefaultApp && m.isAllowed(ctx, p.Address, p.Name) {
+				return p, nil
+			}
+		}
+
+		if m.defaultToSingleProvider {
+			if p, ok := m.singleProvider(ctx, mime); ok {
 				return p, nil
 			}
 		}
@@ -326,6 +546,25 @@ func (m *manager) GetDefaultProviderForMimeType(ctx context.Context, mimeType st
 	return nil, errtypes.NotFound("default application provider not set for mime type " + mimeType)
 }
 
+// singleProvider returns the provider of the mime type, with its capabilities,
+// if it is the only one visible to the user.
+func (m *manager) singleProvider(ctx context.Context, mime *mimeTypeConfig) (*registrypb.ProviderInfo, bool) {
+	var single *registrypb.ProviderInfo
+	for _, p := range mime.apps {
+		if !m.isAllowed(ctx, p.provider.Address, p.provider.Name) {
+			continue
+		}
+		if single != nil {
+			return nil, false
+		}
+		single = p.provider
+	}
+	if single == nil {
+		return nil, false
+	}
+	return withCapabilities(single, mime), true
+}
+
 func equalsProviderInfo(p1, p2 *registrypb.ProviderInfo) bool {
 	return p1.Name == p2.Name
 }
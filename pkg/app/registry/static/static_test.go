@@ -21,10 +21,15 @@ package static
 import (
 	"context"
 	"reflect"
+	"sort"
 	"testing"
+	"time"
 
 	registrypb "github.com/cs3org/go-cs3apis/cs3/app/registry/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/app"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/errtypes"
 )
 
@@ -802,6 +807,169 @@ func TestAddProvider(t *testing.T) {
 	}
 }
 
+func TestAddProviderTwice(t *testing.T) {
+	testCases := []struct {
+		name          string
+		first         *registrypb.ProviderInfo
+		second        *registrypb.ProviderInfo
+		expectedMimes map[string][]string
+	}{
+		{
+			name: "same provider registered again",
+			first: &registrypb.ProviderInfo{
+				MimeTypes: []string{"text/json"},
+				Address:   "ip-provider1",
+				Name:      "provider1",
+			},
+			second: &registrypb.ProviderInfo{
+				MimeTypes: []string{"text/json"},
+				Address:   "ip-provider1",
+				Name:      "provider1",
+			},
+			expectedMimes: map[string][]string{
+				"text/json": {"ip-provider1"},
+			},
+		},
+		{
+			name: "provider restarted with different mime types",
+			first: &registrypb.ProviderInfo{
+				MimeTypes: []string{"text/json", "text/xml"},
+				Address:   "ip-provider1",
+				Name:      "provider1",
+			},
+			second: &registrypb.ProviderInfo{
+				MimeTypes: []string{"text/json", "text/plain"},
+				Address:   "ip-provider1",
+				Name:      "provider1",
+			},
+			expectedMimes: map[string][]string{
+				"text/json":  {"ip-provider1"},
+				"text/xml":   {},
+				"text/plain": {"ip-provider1"},
+			},
+		},
+		{
+			name: "provider restarted with a different name",
+			first: &registrypb.ProviderInfo{
+				MimeTypes: []string{"text/json"},
+				Address:   "ip-provider1",
+				Name:      "provider1",
+			},
+			second: &registrypb.ProviderInfo{
+				MimeTypes: []string{"text/json"},
+				Address:   "ip-provider1",
+				Name:      "provider1-renamed",
+			},
+			expectedMimes: map[string][]string{
+				"text/json": {"ip-provider1"},
+			},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+
+			registry, err := New(map[string]interface{}{
+				"mime_types": []*mimeTypeConfig{
+					{MimeType: "text/json", Extension: "json"},
+					{MimeType: "text/xml", Extension: "xml"},
+				},
+			})
+			if err != nil {
+				t.Fatal("unexpected error creating the registry:", err)
+			}
+
+			for _, p := range []*registrypb.ProviderInfo{tt.first, tt.second} {
+				if err := registry.AddProvider(ctx, p); err != nil {
+					t.Fatal("unexpected error adding a provider:", err)
+				}
+			}
+
+			providers, err := registry.ListProviders(ctx)
+			if err != nil {
+				t.Fatal("unexpected error listing the providers:", err)
+			}
+			if len(providers) != 1 || providers[0] != tt.second {
+				t.Errorf("expected only the last registration: got=%v", providers)
+			}
+
+			for mime, expAddrs := range tt.expectedMimes {
+				providers, err := registry.FindProviders(ctx, mime)
+				if err != nil {
+					t.Fatalf("unexpected error finding the providers of %s: %v", mime, err)
+				}
+				addrs := []string{}
+				for _, p := range providers {
+					addrs = append(addrs, p.Address)
+				}
+				if !reflect.DeepEqual(expAddrs, addrs) {
+					t.Errorf("providers of %s different from expected: got=%v expected=%v", mime, addrs, expAddrs)
+				}
+			}
+		})
+	}
+}
+
+func TestProviderTTL(t *testing.T) {
+	ctx := context.TODO()
+
+	reg, err := New(map[string]interface{}{
+		"mime_types": []*mimeTypeConfig{
+			{MimeType: "text/json", Extension: "json", DefaultApp: "ip-stale"},
+		},
+		"providers": []*registrypb.ProviderInfo{
+			{MimeTypes: []string{"text/json"}, Address: "ip-configured", Name: "configured"},
+		},
+		"provider_ttl": 60,
+	})
+	if err != nil {
+		t.Fatal("unexpected error creating the registry:", err)
+	}
+	for _, p := range []*registrypb.ProviderInfo{
+		{MimeTypes: []string{"text/json"}, Address: "ip-stale", Name: "stale"},
+		{MimeTypes: []string{"text/json"}, Address: "ip-alive", Name: "alive"},
+	} {
+		if err := reg.AddProvider(ctx, p); err != nil {
+			t.Fatal("unexpected error adding a provider:", err)
+		}
+	}
+	// the stale provider did not register again within the ttl
+	reg.(*manager).lastSeen["ip-stale"] = time.Now().Add(-2 * time.Minute)
+
+	providers, err := reg.ListProviders(ctx)
+	if err != nil {
+		t.Fatal("unexpected error listing the providers:", err)
+	}
+	addrs := []string{}
+	for _, p := range providers {
+		addrs = append(addrs, p.Address)
+	}
+	sort.Strings(addrs)
+	if expected := []string{"ip-alive", "ip-configured"}; !reflect.DeepEqual(expected, addrs) {
+		t.Errorf("providers different from expected: got=%v expected=%v", addrs, expected)
+	}
+
+	providers, err = reg.FindProviders(ctx, "text/json")
+	if err != nil {
+		t.Fatal("unexpected error finding the providers:", err)
+	}
+	if len(providers) != 2 {
+		t.Errorf("expected the stale provider not to be found: got=%v", providers)
+	}
+	if _, err := reg.GetDefaultProviderForMimeType(ctx, "text/json"); err == nil {
+		t.Error("expected the stale default provider not to be found")
+	}
+
+	// the provider is served again once it registers again
+	if err := reg.AddProvider(ctx, &registrypb.ProviderInfo{MimeTypes: []string{"text/json"}, Address: "ip-stale", Name: "stale"}); err != nil {
+		t.Fatal("unexpected error adding a provider:", err)
+	}
+	if p, err := reg.GetDefaultProviderForMimeType(ctx, "text/json"); err != nil || p.Address != "ip-stale" {
+		t.Errorf("expected the provider registered again to be the default: got=%v err=%v", p, err)
+	}
+}
+
 func TestListSupportedMimeTypes(t *testing.T) {
 	testCases := []struct {
 		name         string
@@ -1175,6 +1343,136 @@ func TestSetDefaultProviderForMimeType(t *testing.T) {
 	}
 }
 
+func TestRestrictedProviders(t *testing.T) {
+	registry, err := New(map[string]interface{}{
+		"providers": []map[string]interface{}{
+			{
+				"address":   "127.0.0.1:65535",
+				"name":      "Collabora",
+				"mimetypes": []string{"application/vnd.oasis.opendocument.text"},
+			},
+			{
+				"address":        "127.0.0.1:65534",
+				"name":           "NewEditor",
+				"mimetypes":      []string{"application/vnd.oasis.opendocument.text", "text/markdown"},
+				"allowed_users":  []string{"einstein"},
+				"allowed_groups": []string{"pilot"},
+			},
+		},
+		"mime_types": []map[string]interface{}{
+			{
+				"mime_type":   "application/vnd.oasis.opendocument.text",
+				"extension":   "odt",
+				"default_app": "NewEditor",
+			},
+			{
+				"mime_type": "text/markdown",
+				"extension": "md",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal("unexpected error creating a new registry:", err)
+	}
+
+	testCases := []struct {
+		name       string
+		user       *userpb.User
+		providers  []string
+		mimeTypes  []string
+		defaultApp string
+	}{
+		{
+			name:       "user in group",
+			user:       &userpb.User{Id: &userpb.UserId{OpaqueId: "marie"}, Username: "marie", Groups: []string{"physics", "pilot"}},
+			providers:  []string{"Collabora", "NewEditor"},
+			mimeTypes:  []string{"application/vnd.oasis.opendocument.text", "text/markdown"},
+			defaultApp: "NewEditor",
+		},
+		{
+			name:       "allowed user",
+			user:       &userpb.User{Id: &userpb.UserId{OpaqueId: "einstein"}, Username: "einstein"},
+			providers:  []string{"Collabora", "NewEditor"},
+			mimeTypes:  []string{"application/vnd.oasis.opendocument.text", "text/markdown"},
+			defaultApp: "NewEditor",
+		},
+		{
+			name:      "user not in group",
+			user:      &userpb.User{Id: &userpb.UserId{OpaqueId: "richard"}, Username: "richard", Groups: []string{"physics"}},
+			providers: []string{"Collabora"},
+			mimeTypes: []string{"application/vnd.oasis.opendocument.text"},
+		},
+		{
+			name:      "anonymous",
+			providers: []string{"Collabora"},
+			mimeTypes: []string{"application/vnd.oasis.opendocument.text"},
+		},
+	}
+
+	names := func(providers []*registrypb.ProviderInfo) []string {
+		n := []string{}
+		for _, p := range providers {
+			n = append(n, p.Name)
+		}
+		sort.Strings(n)
+		return n
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.TODO()
+			if tt.user != nil {
+				ctx = ctxpkg.ContextSetUser(ctx, tt.user)
+			}
+
+			providers, err := registry.FindProviders(ctx, "application/vnd.oasis.opendocument.text")
+			if err != nil {
+				t.Fatal("unexpected error finding the providers:", err)
+			}
+			if got := names(providers); !reflect.DeepEqual(got, tt.providers) {
+				t.Errorf("providers differ from expected:\n\tgot=%v\n\texp=%v", got, tt.providers)
+			}
+
+			providers, err = registry.ListProviders(ctx)
+			if err != nil {
+				t.Fatal("unexpected error listing the providers:", err)
+			}
+			if got := names(providers); !reflect.DeepEqual(got, tt.providers) {
+				t.Errorf("listed providers differ from expected:\n\tgot=%v\n\texp=%v", got, tt.providers)
+			}
+
+			mimeTypes, err := registry.ListSupportedMimeTypes(ctx)
+			if err != nil {
+				t.Fatal("unexpected error listing the mime types:", err)
+			}
+			got := []string{}
+			for _, m := range mimeTypes {
+				got = append(got, m.MimeType)
+				if m.MimeType == "application/vnd.oasis.opendocument.text" {
+					if p := names(m.AppProviders); !reflect.DeepEqual(p, tt.providers) {
+						t.Errorf("mime type providers differ from expected:\n\tgot=%v\n\texp=%v", p, tt.providers)
+					}
+					if m.DefaultApplication != tt.defaultApp {
+						t.Errorf("default app differs from expected: got=%q exp=%q", m.DefaultApplication, tt.defaultApp)
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.mimeTypes) {
+				t.Errorf("mime types differ from expected:\n\tgot=%v\n\texp=%v", got, tt.mimeTypes)
+			}
+
+			p, err := registry.GetDefaultProviderForMimeType(ctx, "application/vnd.oasis.opendocument.text")
+			if tt.defaultApp == "" {
+				if _, ok := err.(errtypes.IsNotFound); !ok {
+					t.Errorf("expected no default provider, got %v %v", p, err)
+				}
+			} else if err != nil || p.Name != tt.defaultApp {
+				t.Errorf("expected default provider %s, got %v %v", tt.defaultApp, p, err)
+			}
+		})
+	}
+}
+
 func mimeTypesEquals(l1, l2 []*registrypb.MimeTypeInfo) bool {
 	if len(l1) != len(l2) {
 		return false
@@ -1197,3 +1495,117 @@ func equalsMimeTypeInfo(m1, m2 *registrypb.MimeTypeInfo) bool {
 		m1.Name == m2.Name &&
 		m1.DefaultApplication == m2.DefaultApplication
 }
+
+func TestMimeTypesMissingFromConfig(t *testing.T) {
+	registry, err := New(map[string]interface{}{
+		"providers": []map[string]interface{}{
+			{
+				"address":   "127.0.0.1:65535",
+				"name":      "Collabora",
+				"mimetypes": []string{"application/vnd.oasis.opendocument.text", "text/markdown"},
+			},
+			{
+				"address":   "127.0.0.1:65534",
+				"name":      "CodiMD",
+				"mimetypes": []string{"text/markdown"},
+			},
+		},
+		"mime_types": []map[string]interface{}{
+			{
+				"mime_type": "application/vnd.oasis.opendocument.text",
+				"extension": "odt",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal("unexpected error creating a new registry:", err)
+	}
+
+	providers, err := registry.FindProviders(context.Background(), "text/markdown")
+	if err != nil {
+		t.Fatal("unexpected error finding the providers:", err)
+	}
+	if len(providers) != 2 {
+		t.Errorf("expected 2 providers for text/markdown, got %d", len(providers))
+	}
+
+	mimeTypes, err := registry.ListSupportedMimeTypes(context.Background())
+	if err != nil {
+		t.Fatal("unexpected error listing the mime types:", err)
+	}
+	if len(mimeTypes) != 2 {
+		t.Errorf("expected 2 mime types, got %d", len(mimeTypes))
+	}
+}
+
+func TestDefaultToSingleProvider(t *testing.T) {
+	conf := map[string]interface{}{
+		"providers": []map[string]interface{}{
+			{
+				"address":   "127.0.0.1:65535",
+				"name":      "Collabora",
+				"mimetypes": []string{"application/vnd.oasis.opendocument.text", "application/vnd.oasis.opendocument.spreadsheet"},
+			},
+			{
+				"address":   "127.0.0.1:65534",
+				"name":      "OnlyOffice",
+				"mimetypes": []string{"application/vnd.oasis.opendocument.text"},
+			},
+		},
+		"mime_types": []map[string]interface{}{
+			{
+				"mime_type": "application/vnd.oasis.opendocument.text",
+				"extension": "odt",
+			},
+			{
+				"mime_type": "application/vnd.oasis.opendocument.spreadsheet",
+				"extension": "ods",
+			},
+			{
+				"mime_type": "text/markdown",
+				"extension": "md",
+			},
+		},
+	}
+
+	testCases := []struct {
+		name     string
+		mimeType string
+		enabled  bool
+		expected string
+	}{
+		{name: "no provider", mimeType: "text/markdown", enabled: true},
+		{name: "unknown mime type", mimeType: "text/plain", enabled: true},
+		{name: "single provider", mimeType: "application/vnd.oasis.opendocument.spreadsheet", enabled: true, expected: "Collabora"},
+		{name: "many providers", mimeType: "application/vnd.oasis.opendocument.text", enabled: true},
+		{name: "single provider, disabled", mimeType: "application/vnd.oasis.opendocument.spreadsheet"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			conf["default_to_single_provider"] = tt.enabled
+			registry, err := New(conf)
+			if err != nil {
+				t.Fatal("unexpected error creating a new registry:", err)
+			}
+
+			p, err := registry.GetDefaultProviderForMimeType(context.Background(), tt.mimeType)
+			if tt.expected == "" {
+				if _, ok := err.(errtypes.IsNotFound); !ok {
+					t.Fatalf("expected a not found error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal("unexpected error getting the default provider:", err)
+			}
+			if p.Name != tt.expected {
+				t.Errorf("expected the default provider %s, got %s", tt.expected, p.Name)
+			}
+			caps, err := app.GetCapabilities(p)
+			if err != nil || caps[tt.mimeType] == nil {
+				t.Errorf("expected the capabilities of the provider for %s, got %v, error %v", tt.mimeType, caps, err)
+			}
+		})
+	}
+}
@@ -27,6 +27,11 @@ import (
 // DeletingSharedResource flags to a storage a shared resource is being deleted not by the owner.
 var DeletingSharedResource struct{}
 
+type skipTrashKey struct{}
+
+// SkipTrash flags to a storage that a resource must be deleted permanently instead of moving it to the trash.
+var SkipTrash skipTrashKey
+
 // WithLogger returns a context with an associated logger.
 func WithLogger(ctx context.Context, l *zerolog.Logger) context.Context {
 	return l.WithContext(ctx)
@@ -34,6 +34,16 @@ type Manager interface {
 	Authenticate(ctx context.Context, clientID, clientSecret string) (*user.User, map[string]*authpb.Scope, error)
 }
 
+// Reloader is implemented by the auth managers able to reload
+// their configuration, e.g. a users mapping file, without being restarted.
+type Reloader interface {
+	// Reload replaces the configuration in use. On error, the previous one is kept.
+	Reload(ctx context.Context) error
+	// ConfigFiles returns the files the configuration is read from,
+	// watched to reload it as soon as they change.
+	ConfigFiles() []string
+}
+
 // Credentials contains the auth type, client id and secret.
 type Credentials struct {
 	Type         string
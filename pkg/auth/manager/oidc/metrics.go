@@ -0,0 +1,106 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package oidc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// The outcomes of the authentications, as tagged in the metrics.
+const (
+	outcomeOK              = "ok"
+	outcomeProviderError   = "provider_error"
+	outcomeUserInfoError   = "userinfo_error"
+	outcomeAudienceDenied  = "audience_denied"
+	outcomeMissingClaim    = "missing_claim"
+	outcomeGroupDenied     = "group_denied"
+	outcomeMappingDenied   = "mapping_denied"
+	outcomeUserLookupError = "user_lookup_error"
+	outcomeGroupFetchError = "group_fetch_error"
+	outcomeError           = "error"
+)
+
+var (
+	authentications   = stats.Int64("oidc_authentications_total", "The number of authentications with OIDC tokens", stats.UnitDimensionless)
+	userInfoLatency   = stats.Float64("oidc_userinfo_latency", "The latency of the UserInfo calls to the IdP", stats.UnitMilliseconds)
+	userGroupsLatency = stats.Float64("oidc_user_groups_latency", "The latency of the lookups of the groups of the users", stats.UnitMilliseconds)
+	outcomeKey        = tag.MustNewKey("outcome")
+	registerOnce      sync.Once
+	errRegistration   error
+)
+
+// registerViews registers the views of the metrics, which are shared
+// by all the managers.
+func registerViews() error {
+	registerOnce.Do(func() {
+		latencyBuckets := view.Distribution(1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000)
+		errRegistration = view.Register(
+			&view.View{
+				Name:        authentications.Name(),
+				Description: authentications.Description(),
+				Measure:     authentications,
+				TagKeys:     []tag.Key{outcomeKey},
+				Aggregation: view.Count(),
+			},
+			&view.View{
+				Name:        userInfoLatency.Name(),
+				Description: userInfoLatency.Description(),
+				Measure:     userInfoLatency,
+				TagKeys:     []tag.Key{outcomeKey},
+				Aggregation: latencyBuckets,
+			},
+			&view.View{
+				Name:        userGroupsLatency.Name(),
+				Description: userGroupsLatency.Description(),
+				Measure:     userGroupsLatency,
+				TagKeys:     []tag.Key{outcomeKey},
+				Aggregation: latencyBuckets,
+			},
+		)
+	})
+	return errRegistration
+}
+
+// callOutcome returns the outcome of a call failed with err, as tagged in the metrics.
+func callOutcome(err error) string {
+	if err != nil {
+		return outcomeError
+	}
+	return outcomeOK
+}
+
+// recordAuthentication counts the authentication by outcome.
+func recordAuthentication(outcome string) {
+	_ = stats.RecordWithTags(context.Background(),
+		[]tag.Mutator{tag.Upsert(outcomeKey, outcome)},
+		authentications.M(1))
+}
+
+// recordLatency records the latency of a call in the measure, by its outcome.
+func recordLatency(m *stats.Float64Measure, d time.Duration, err error) {
+	_ = stats.RecordWithTags(context.Background(),
+		[]tag.Mutator{tag.Upsert(outcomeKey, callOutcome(err))},
+		m.M(float64(d)/float64(time.Millisecond)))
+}
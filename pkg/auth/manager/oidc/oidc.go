@@ -24,8 +24,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"regexp"
 	"strings"
+	"sync"
+	"text/template"
 	"time"
 
 	oidc "github.com/coreos/go-oidc"
@@ -55,13 +60,27 @@ func init() {
 }
 
 type mgr struct {
-	provider         *oidc.Provider // cached on first request
-	c                *config
+	providerMu     sync.Mutex
+	provider       *oidc.Provider // cached on first request
+	httpClient     *http.Client   // shared by all requests to the IdP, with the discovery timeout
+	userInfoClient *http.Client   // same as httpClient, with the userinfo timeout
+	c              *config
+	claimRewrites  []*compiledClaimRewrite
+	displayNameTpl *template.Template
+
+	// the users mapping is replaced as a whole when reloaded
+	mappingMu        sync.RWMutex
 	oidcUsersMapping map[string]*oidcUserMapping
+
+	serviceAccounts []*compiledServiceAccount
 }
 
 type config struct {
 	Insecure     bool   `mapstructure:"insecure" docs:"false;Whether to skip certificate checks when sending requests."`
+	HTTPTimeout  int    `mapstructure:"http_timeout" docs:"10;Timeout in seconds of the requests to the OIDC provider."`
+	MaxIdleConns int    `mapstructure:"max_idle_conns" docs:"10;Maximum number of idle connections kept open to the OIDC provider."`
+	IdleTimeout  int    `mapstructure:"idle_conn_timeout" docs:"90;Time in seconds after which idle connections to the OIDC provider are closed."`
+	HTTPProxy    string `mapstructure:"http_proxy" docs:";URL of the proxy the requests to the OIDC provider are sent through. If not set, the HTTP_PROXY and HTTPS_PROXY environment variables are honored."`
 	Issuer       string `mapstructure:"issuer" docs:";The issuer of the OIDC token."`
 	IDClaim      string `mapstructure:"id_claim" docs:"sub;The claim containing the ID of the user."`
 	UIDClaim     string `mapstructure:"uid_claim" docs:";The claim containing the UID of the user."`
@@ -69,6 +88,67 @@ type config struct {
 	GatewaySvc   string `mapstructure:"gatewaysvc" docs:";The endpoint at which the GRPC gateway is exposed."`
 	UsersMapping string `mapstructure:"users_mapping" docs:"; The optional OIDC users mapping file path"`
 	GroupClaim   string `mapstructure:"group_claim" docs:"; The group claim to be looked up to map the user (default to 'groups')."`
+
+	// the operations on the IdP have different latencies, e.g. a cold IdP may be slow to serve the discovery
+	DiscoveryTimeout int `mapstructure:"discovery_timeout" docs:"10;Timeout in seconds of the discovery of the OIDC provider and of its keys. Defaults to the http_timeout."`
+	UserInfoTimeout  int `mapstructure:"userinfo_timeout" docs:"10;Timeout in seconds of the requests to the userinfo endpoint. Defaults to the http_timeout."`
+
+	DisplayNameClaim    string `mapstructure:"display_name_claim" docs:"name;The claim containing the display name of the user. If missing, the name claim is used."`
+	DisplayNameTemplate string `mapstructure:"display_name_template" docs:"{{.DisplayName}}{{if .Guest}} ({{.MailDomain}}){{end}};Template used to format the display name of all the users. Besides DisplayName, Username, Mail, MailDomain and Guest, true for the lightweight and federated accounts, the claims are available by name, e.g. {{.name}} [{{.iss}}]. If the template fails, the display name is kept as it comes from the claims."`
+
+	GroupsFromClaim bool `mapstructure:"groups_from_claim" docs:"false;Whether to take the groups of the user from the group claim, instead of looking them up through the gateway."`
+
+	AuthorizedGroups []string `mapstructure:"authorized_groups" docs:";If set, only the members of at least one of these groups are allowed to log in."`
+	AllowedGroups    []string `mapstructure:"allowed_groups" docs:";Alias of authorized_groups, the two lists are merged."`
+	DeniedGroups     []string `mapstructure:"denied_groups" docs:";The members of any of these groups are not allowed to log in."`
+
+	AllowedAudiences []string `mapstructure:"allowed_audiences" docs:";If set, only the tokens issued for at least one of these audiences are accepted. The audience is taken from the userinfo or, if missing there, from the access token, that has then to be a JWT signed by the OIDC provider."`
+
+	PostProcessing postProcessingConfig `mapstructure:"post_processing" docs:";Rules applied to the claims of lightweight and federated accounts."`
+
+	ServiceAccounts []*serviceAccount `mapstructure:"service_accounts" docs:";The clients authenticating as service accounts with the tokens of the client credentials grant, which carry no profile nor email."`
+}
+
+// serviceAccount maps the tokens of a client, obtained with the client
+// credentials grant, to a synthetic user with a restricted scope.
+// The tokens are recognized by their client_id claim, or by their audience
+// when it is dedicated to the client, and only if they carry no user profile.
+type serviceAccount struct {
+	ClientID string `mapstructure:"client_id" docs:";The client_id claim of the tokens of the service account."`
+	Audience string `mapstructure:"audience" docs:";The audience of the tokens of the service account."`
+	Username string `mapstructure:"username" docs:";The username of the service account."`
+	UserType string `mapstructure:"user_type" docs:"application;The type of the service account, either application or service."`
+	Path     string `mapstructure:"path" docs:";The absolute path of the resources the service account has access to. Required."`
+	Role     string `mapstructure:"role" docs:"viewer;The role of the service account on its resources, either viewer or editor."`
+}
+
+type compiledServiceAccount struct {
+	*serviceAccount
+	userType user.UserType
+	role     authpb.Role
+}
+
+// postProcessingConfig holds the rules used to rewrite the claims
+// of lightweight and federated (guest) accounts.
+type postProcessingConfig struct {
+	ClaimRewrites                []*claimRewrite `mapstructure:"claim_rewrites" docs:";List of rewrite rules applied to the claims."`
+	StripMailPrefixes            []string        `mapstructure:"strip_mail_prefixes" docs:";Prefixes stripped from the email, applied before the claim_rewrites. If not set, the email is used verbatim."`
+	StripGuestPrefix             bool            `mapstructure:"strip_guest_prefix" docs:"false;Whether to strip the 'guest: ' prefix from the email, as sent by the LDAP at CERN for the guest accounts."`
+	DisableDisplayNameDecoration bool            `mapstructure:"disable_display_name_decoration" docs:"false;Whether to keep the display name as it comes from the claims, instead of applying the display_name_template."`
+}
+
+// claimRewrite replaces the first match of the Match regex
+// in the given claim with Replacement.
+type claimRewrite struct {
+	Claim       string `mapstructure:"claim" json:"claim"`
+	Match       string `mapstructure:"match" json:"match"`
+	Replacement string `mapstructure:"replacement" json:"replacement"`
+}
+
+type compiledClaimRewrite struct {
+	claim       string
+	re          *regexp.Regexp
+	replacement string
 }
 
 type oidcUserMapping struct {
@@ -85,12 +165,43 @@ func (c *config) init() {
 	if c.GroupClaim == "" {
 		c.GroupClaim = "groups"
 	}
+	if c.DisplayNameClaim == "" {
+		c.DisplayNameClaim = "name"
+	}
 	if c.UIDClaim == "" {
 		c.UIDClaim = "uid"
 	}
 	if c.GIDClaim == "" {
 		c.GIDClaim = "gid"
 	}
+	if c.HTTPTimeout == 0 {
+		c.HTTPTimeout = 10
+	}
+	if c.DiscoveryTimeout == 0 {
+		c.DiscoveryTimeout = c.HTTPTimeout
+	}
+	if c.UserInfoTimeout == 0 {
+		c.UserInfoTimeout = c.HTTPTimeout
+	}
+	if c.MaxIdleConns == 0 {
+		c.MaxIdleConns = 10
+	}
+	if c.IdleTimeout == 0 {
+		c.IdleTimeout = 90
+	}
+	if c.PostProcessing.StripGuestPrefix {
+		// the email of guest accounts at CERN comes with a `guest: ` prefix (from LDAP?)
+		c.PostProcessing.StripMailPrefixes = append(c.PostProcessing.StripMailPrefixes, "guest: ")
+	}
+	if c.DisplayNameTemplate == "" {
+		// only the display name of lightweight and federated accounts is decorated,
+		// to make it different from the one of the primary accounts
+		c.DisplayNameTemplate = "{{.DisplayName}}{{if .Guest}} ({{.MailDomain}}){{end}}"
+	}
+
+	// the groups are compared as normalized, whatever the form sent by the IdP
+	c.AuthorizedGroups = normalizeGroups(append(c.AuthorizedGroups, c.AllowedGroups...))
+	c.DeniedGroups = normalizeGroups(c.DeniedGroups)
 
 	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
 }
@@ -122,29 +233,153 @@ func (am *mgr) Configure(m map[string]interface{}) error {
 	c.init()
 	am.c = c
 
-	am.oidcUsersMapping = map[string]*oidcUserMapping{}
-	if c.UsersMapping == "" {
-		// no mapping defined, leave the map empty and move on
+	if err := registerViews(); err != nil {
+		return errors.Wrap(err, "oidc: error registering the metrics")
+	}
+
+	// A single client is used for all the requests to the IdP, so that
+	// connections are reused instead of opening new ones for every request.
+	// Sometimes for testing we need to skip the TLS check, that's why we need a
+	// custom HTTP client.
+	opts := []rhttp.Option{
+		rhttp.Timeout(time.Duration(c.DiscoveryTimeout) * time.Second),
+		rhttp.Insecure(c.Insecure),
+		rhttp.MaxIdleConns(c.MaxIdleConns),
+		rhttp.IdleConnTimeout(time.Duration(c.IdleTimeout) * time.Second),
+	}
+	if c.HTTPProxy != "" {
+		proxy, err := url.Parse(c.HTTPProxy)
+		if err != nil || proxy.Host == "" {
+			return fmt.Errorf("oidc: invalid http_proxy \"%s\"", c.HTTPProxy)
+		}
+		opts = append(opts, rhttp.Proxy(proxy))
+	}
+	am.httpClient = rhttp.GetHTTPClient(opts...)
+	// the clients share the transport, and thus the connections
+	userInfoClient := *am.httpClient
+	userInfoClient.Timeout = time.Duration(c.UserInfoTimeout) * time.Second
+	am.userInfoClient = &userInfoClient
+
+	am.claimRewrites = make([]*compiledClaimRewrite, 0, len(c.PostProcessing.StripMailPrefixes)+len(c.PostProcessing.ClaimRewrites))
+	for _, p := range c.PostProcessing.StripMailPrefixes {
+		am.claimRewrites = append(am.claimRewrites, &compiledClaimRewrite{
+			claim: "email",
+			re:    regexp.MustCompile("^" + regexp.QuoteMeta(p)),
+		})
+	}
+	for _, r := range c.PostProcessing.ClaimRewrites {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return fmt.Errorf("oidc: error compiling rewrite rule for claim \"%s\": %+v", r.Claim, err)
+		}
+		am.claimRewrites = append(am.claimRewrites, &compiledClaimRewrite{
+			claim:       r.Claim,
+			re:          re,
+			replacement: r.Replacement,
+		})
+	}
+	am.displayNameTpl, err = template.New("displayname").Option("missingkey=error").Parse(c.DisplayNameTemplate)
+	if err != nil {
+		return fmt.Errorf("oidc: error parsing the display name template: %+v", err)
+	}
+
+	mapping, err := am.loadUsersMapping()
+	if err != nil {
+		return err
+	}
+	am.oidcUsersMapping = mapping
+
+	am.serviceAccounts, err = compileServiceAccounts(c.ServiceAccounts)
+	return err
+}
+
+func compileServiceAccounts(accounts []*serviceAccount) ([]*compiledServiceAccount, error) {
+	compiled := make([]*compiledServiceAccount, 0, len(accounts))
+	for _, a := range accounts {
+		if a.Username == "" || (a.ClientID == "" && a.Audience == "") {
+			return nil, errors.New("oidc: service accounts require a username and either a client_id or an audience")
+		}
+		if !strings.HasPrefix(a.Path, "/") {
+			return nil, fmt.Errorf("oidc: service account \"%s\" requires an absolute path", a.Username)
+		}
+		sa := &compiledServiceAccount{serviceAccount: a}
+		switch a.UserType {
+		case "", "application":
+			sa.userType = user.UserType_USER_TYPE_APPLICATION
+		case "service":
+			sa.userType = user.UserType_USER_TYPE_SERVICE
+		default:
+			return nil, fmt.Errorf("oidc: invalid user_type \"%s\" of service account \"%s\"", a.UserType, a.Username)
+		}
+		switch a.Role {
+		case "", "viewer":
+			sa.role = authpb.Role_ROLE_VIEWER
+		case "editor":
+			sa.role = authpb.Role_ROLE_EDITOR
+		default:
+			return nil, fmt.Errorf("oidc: invalid role \"%s\" of service account \"%s\"", a.Role, a.Username)
+		}
+		compiled = append(compiled, sa)
+	}
+	return compiled, nil
+}
+
+// Reload reads again the users mapping file, replacing the mapping in use.
+// On error, the previous mapping is kept.
+func (am *mgr) Reload(ctx context.Context) error {
+	if am.c.UsersMapping == "" {
 		return nil
 	}
 
-	f, err := os.ReadFile(c.UsersMapping)
+	mapping, err := am.loadUsersMapping()
 	if err != nil {
-		return fmt.Errorf("oidc: error reading the users mapping file: +%v", err)
+		return err
+	}
+
+	am.mappingMu.Lock()
+	am.oidcUsersMapping = mapping
+	am.mappingMu.Unlock()
+	return nil
+}
+
+// ConfigFiles returns the users mapping file, if any.
+func (am *mgr) ConfigFiles() []string {
+	if am.c.UsersMapping == "" {
+		return nil
+	}
+	return []string{am.c.UsersMapping}
+}
+
+func (am *mgr) usersMapping() map[string]*oidcUserMapping {
+	am.mappingMu.RLock()
+	defer am.mappingMu.RUnlock()
+	return am.oidcUsersMapping
+}
+
+// loadUsersMapping reads the users mapping file, indexing the mappings by group.
+func (am *mgr) loadUsersMapping() (map[string]*oidcUserMapping, error) {
+	mapping := map[string]*oidcUserMapping{}
+	if am.c.UsersMapping == "" {
+		// no mapping defined, leave the map empty and move on
+		return mapping, nil
+	}
+
+	f, err := os.ReadFile(am.c.UsersMapping)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: error reading the users mapping file: +%v", err)
 	}
 	oidcUsers := []*oidcUserMapping{}
 	err = json.Unmarshal(f, &oidcUsers)
 	if err != nil {
-		return fmt.Errorf("oidc: error unmarshalling the users mapping file: +%v", err)
+		return nil, fmt.Errorf("oidc: error unmarshalling the users mapping file: +%v", err)
 	}
 	for _, u := range oidcUsers {
-		if _, found := am.oidcUsersMapping[u.OIDCGroup]; found {
-			return fmt.Errorf("oidc: mapping error, group \"%s\" is mapped to multiple users", u.OIDCGroup)
+		if _, found := mapping[u.OIDCGroup]; found {
+			return nil, fmt.Errorf("oidc: mapping error, group \"%s\" is mapped to multiple users", u.OIDCGroup)
 		}
-		am.oidcUsersMapping[u.OIDCGroup] = u
+		mapping[u.OIDCGroup] = u
 	}
-
-	return nil
+	return mapping, nil
 }
 
 // The clientID would be empty as we only need to validate the clientSecret variable
@@ -154,11 +389,16 @@ func (am *mgr) Authenticate(ctx context.Context, _, clientSecret string) (*user.
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "Authenticate")
 	defer span.End()
 
-	ctx = am.getOAuthCtx(ctx)
+	ctx = am.getOAuthCtx(ctx, am.userInfoClient)
 	log := appctx.GetLogger(ctx)
 
+	// the outcome is set right before returning
+	outcome := outcomeError
+	defer func() { recordAuthentication(outcome) }()
+
 	oidcProvider, err := am.getOIDCProvider(ctx)
 	if err != nil {
+		outcome = outcomeProviderError
 		return nil, nil, fmt.Errorf("oidc: error creating oidc provider: +%v", err)
 	}
 
@@ -167,8 +407,11 @@ func (am *mgr) Authenticate(ctx context.Context, _, clientSecret string) (*user.
 	}
 
 	// query the oidc provider for user info
+	start := time.Now()
 	userInfo, err := oidcProvider.UserInfo(ctx, oauth2.StaticTokenSource(oauth2Token))
+	recordLatency(userInfoLatency, time.Since(start), err)
 	if err != nil {
+		outcome = outcomeUserInfoError
 		return nil, nil, fmt.Errorf("oidc: error getting userinfo: +%v", err)
 	}
 
@@ -178,14 +421,29 @@ func (am *mgr) Authenticate(ctx context.Context, _, clientSecret string) (*user.
 	// TODO(labkode): may do like K8s does it: https://github.com/kubernetes/kubernetes/blob/master/staging/src/k8s.io/apiserver/plugin/pkg/authenticator/token/oidc/oidc.go
 	var claims map[string]interface{}
 	if err := userInfo.Claims(&claims); err != nil {
+		outcome = outcomeUserInfoError
 		return nil, nil, fmt.Errorf("oidc: error unmarshaling userinfo claims: %v", err)
 	}
 
 	log.Debug().Interface("claims", claims).Interface("userInfo", userInfo).Msg("unmarshalled userinfo")
 
+	if err := am.checkAudience(ctx, oidcProvider, clientSecret, claims); err != nil {
+		outcome = outcomeAudienceDenied
+		return nil, nil, err
+	}
+
 	if claims["iss"] == nil { // This is not set in simplesamlphp
 		claims["iss"] = am.c.Issuer
 	}
+
+	if sa := am.serviceAccount(claims); sa != nil {
+		u, scopes, err := am.authenticateServiceAccount(ctx, sa, claims)
+		if err == nil {
+			outcome = outcomeOK
+		}
+		return u, scopes, err
+	}
+
 	if claims["email_verified"] == nil { // This is not set in simplesamlphp
 		claims["email_verified"] = false
 	}
@@ -199,14 +457,28 @@ func (am *mgr) Authenticate(ctx context.Context, _, clientSecret string) (*user.
 		claims["name"] = claims[am.c.IDClaim]
 	}
 	if claims["name"] == nil {
+		outcome = outcomeMissingClaim
 		return nil, nil, fmt.Errorf("no \"name\" attribute found in userinfo: maybe the client did not request the oidc \"profile\"-scope")
 	}
 	if claims["email"] == nil {
+		outcome = outcomeMissingClaim
+		if clientID, ok := claims["client_id"].(string); ok {
+			return nil, nil, errtypes.PermissionDenied(fmt.Sprintf("oidc: client \"%s\" is not a service account", clientID))
+		}
 		return nil, nil, fmt.Errorf("no \"email\" attribute found in userinfo: maybe the client did not request the oidc \"email\"-scope")
 	}
 
+	if err := am.checkGroups(claims); err != nil {
+		outcome = outcomeGroupDenied
+		return nil, nil, err
+	}
+
 	err = am.resolveUser(ctx, claims, userInfo.Subject)
 	if err != nil {
+		outcome = outcomeUserLookupError
+		if _, ok := err.(errtypes.PermissionDenied); ok {
+			outcome = outcomeMappingDenied
+		}
 		return nil, nil, errors.Wrapf(err, "oidc: error resolving username for external user '%v'", claims["email"])
 	}
 
@@ -215,42 +487,41 @@ func (am *mgr) Authenticate(ctx context.Context, _, clientSecret string) (*user.
 		Idp:      claims["iss"].(string),        // in the scope of this issuer
 		Type:     getUserType(claims[am.c.IDClaim].(string)),
 	}
-
-	gwc, err := pool.GetGatewayServiceClient(ctx, pool.Endpoint(am.c.GatewaySvc))
-	if err != nil {
-		return nil, nil, errors.Wrap(err, "oidc: error getting gateway grpc client")
+	if isGuest(userID) {
+		am.rewriteClaims(claims)
 	}
-	getGroupsResp, err := gwc.GetUserGroups(ctx, &user.GetUserGroupsRequest{
-		UserId: userID,
-	})
-	if err != nil {
-		return nil, nil, errors.Wrapf(err, "oidc: error getting user groups for '%+v'", userID)
-	}
-	if getGroupsResp.Status.Code != rpc.Code_CODE_OK {
-		return nil, nil, status.NewErrorFromCode(getGroupsResp.Status.Code, "oidc")
+
+	var groups []string
+	if am.c.GroupsFromClaim {
+		groups = normalizeGroups(getGroups(claims[am.c.GroupClaim]))
+	} else {
+		start := time.Now()
+		groups, err = am.getUserGroups(ctx, userID)
+		recordLatency(userGroupsLatency, time.Since(start), err)
+		if err != nil {
+			outcome = outcomeGroupFetchError
+			return nil, nil, err
+		}
 	}
 
 	u := &user.User{
 		Id:           userID,
 		Username:     claims["preferred_username"].(string),
-		Groups:       getGroupsResp.Groups,
+		Groups:       groups,
 		Mail:         claims["email"].(string),
 		MailVerified: claims["email_verified"].(bool),
-		DisplayName:  claims["name"].(string),
+		DisplayName:  am.displayName(claims),
 		UidNumber:    claims[am.c.UIDClaim].(int64),
 		GidNumber:    claims[am.c.GIDClaim].(int64),
 	}
+	am.decorateDisplayName(ctx, u, claims)
 
 	var scopes map[string]*authpb.Scope
-	if userID != nil && (userID.Type == user.UserType_USER_TYPE_LIGHTWEIGHT || userID.Type == user.UserType_USER_TYPE_FEDERATED) {
+	if isGuest(userID) {
 		scopes, err = scope.AddLightweightAccountScope(authpb.Role_ROLE_OWNER, nil)
 		if err != nil {
 			return nil, nil, err
 		}
-		// strip the `guest:` prefix if present in the email claim (appears to come from LDAP at CERN?)
-		u.Mail = strings.Replace(u.Mail, "guest: ", "", 1)
-		// and decorate the display name with the email domain to make it different from a primary account
-		u.DisplayName = u.DisplayName + " (" + strings.Split(u.Mail, "@")[1] + ")"
 	} else {
 		scopes, err = scope.AddOwnerScope(nil)
 		if err != nil {
@@ -258,9 +529,215 @@ func (am *mgr) Authenticate(ctx context.Context, _, clientSecret string) (*user.
 		}
 	}
 
+	outcome = outcomeOK
 	return u, scopes, nil
 }
 
+// serviceAccount returns the service account the claims belong to, if any.
+// Only the tokens without a user profile identify a service account, so that
+// the tokens of the users are never mapped to it when the client or the
+// audience is shared with the login client.
+func (am *mgr) serviceAccount(claims map[string]interface{}) *compiledServiceAccount {
+	if hasUserProfile(claims) {
+		return nil
+	}
+	clientID, _ := claims["client_id"].(string)
+	audiences := getGroups(claims["aud"])
+	for _, sa := range am.serviceAccounts {
+		if sa.ClientID != "" && sa.ClientID == clientID {
+			return sa
+		}
+		if sa.Audience != "" && len(intersect.Simple(audiences, []string{sa.Audience})) > 0 {
+			return sa
+		}
+	}
+	return nil
+}
+
+// hasUserProfile returns whether the claims describe a user, as the tokens
+// of the client credentials grant carry no email nor name.
+func hasUserProfile(claims map[string]interface{}) bool {
+	for _, c := range []string{"email", "name", "given_name", "family_name"} {
+		if v, ok := claims[c].(string); ok && v != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticateServiceAccount returns the synthetic user of the service account,
+// with access only to its path, without requiring the profile and the email
+// of the users.
+func (am *mgr) authenticateServiceAccount(ctx context.Context, sa *compiledServiceAccount, claims map[string]interface{}) (*user.User, map[string]*authpb.Scope, error) {
+	iss, _ := claims["iss"].(string)
+	u := &user.User{
+		Id: &user.UserId{
+			OpaqueId: sa.Username,
+			Idp:      iss,
+			Type:     sa.userType,
+		},
+		Username:    sa.Username,
+		DisplayName: sa.Username,
+	}
+
+	// the id of the path is unknown without a token to stat it,
+	// hence the scope is bound to the path only
+	scopes, err := scope.AddResourcePathScope(sa.Path, sa.role, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	appctx.GetLogger(ctx).Debug().Str("username", sa.Username).Interface("client_id", claims["client_id"]).Msg("oidc: authenticated service account")
+	return u, scopes, nil
+}
+
+// getUserGroups looks up the groups of the user through the gateway.
+func (am *mgr) getUserGroups(ctx context.Context, userID *user.UserId) ([]string, error) {
+	gwc, err := pool.GetGatewayServiceClient(ctx, pool.Endpoint(am.c.GatewaySvc))
+	if err != nil {
+		return nil, errors.Wrap(err, "oidc: error getting gateway grpc client")
+	}
+	getGroupsResp, err := gwc.GetUserGroups(ctx, &user.GetUserGroupsRequest{
+		UserId: userID,
+	})
+	if err != nil {
+		return nil, errors.Wrapf(err, "oidc: error getting user groups for '%+v'", userID)
+	}
+	if getGroupsResp.Status.Code != rpc.Code_CODE_OK {
+		return nil, status.NewErrorFromCode(getGroupsResp.Status.Code, "oidc")
+	}
+	return getGroupsResp.Groups, nil
+}
+
+// normalizeGroups returns the group names as used by the group providers:
+// IdPs like Keycloak can send the full path of the groups, with a leading slash.
+// Empty and duplicated groups are removed.
+func normalizeGroups(groups []string) []string {
+	normalized := make([]string, 0, len(groups))
+	seen := make(map[string]bool, len(groups))
+	for _, g := range groups {
+		g = strings.TrimPrefix(strings.TrimSpace(g), "/")
+		if g == "" || seen[g] {
+			continue
+		}
+		seen[g] = true
+		normalized = append(normalized, g)
+	}
+	return normalized
+}
+
+// checkGroups verifies that the groups in the group claim are allowed to log in.
+func (am *mgr) checkGroups(claims map[string]interface{}) error {
+	if len(am.c.AuthorizedGroups) == 0 && len(am.c.DeniedGroups) == 0 {
+		return nil
+	}
+
+	groups := normalizeGroups(getGroups(claims[am.c.GroupClaim]))
+	if len(am.c.DeniedGroups) > 0 && len(intersect.Simple(groups, am.c.DeniedGroups)) > 0 {
+		return errtypes.PermissionDenied("oidc: user belongs to a denied group")
+	}
+	if len(am.c.AuthorizedGroups) > 0 && len(intersect.Simple(groups, am.c.AuthorizedGroups)) == 0 {
+		return errtypes.PermissionDenied("oidc: user does not belong to any authorized group")
+	}
+	return nil
+}
+
+// checkAudience verifies that the token was issued for one of the allowed audiences.
+// Most providers do not return the audience in the userinfo: in that case the
+// access token has to be a JWT, whose signature is verified with the keys of the provider.
+func (am *mgr) checkAudience(ctx context.Context, provider *oidc.Provider, accessToken string, claims map[string]interface{}) error {
+	if len(am.c.AllowedAudiences) == 0 {
+		return nil
+	}
+
+	// like the group claim, the audience is either a single value or a list
+	audiences := getGroups(claims["aud"])
+	if len(audiences) == 0 {
+		token, err := provider.Verifier(&oidc.Config{SkipClientIDCheck: true}).Verify(ctx, accessToken)
+		if err != nil {
+			return errtypes.InvalidCredentials("oidc: error verifying the audience of the token: " + err.Error())
+		}
+		audiences = token.Audience
+	}
+	if len(intersect.Simple(audiences, am.c.AllowedAudiences)) == 0 {
+		return errtypes.InvalidCredentials("oidc: the token was not issued for an allowed audience")
+	}
+	return nil
+}
+
+// getGroups returns the groups of a group claim,
+// that can be either a single group or a list of groups.
+func getGroups(claim interface{}) []string {
+	switch g := claim.(type) {
+	case string:
+		return []string{g}
+	case []string:
+		return g
+	case []interface{}:
+		groups := make([]string, 0, len(g))
+		for _, v := range g {
+			if s, ok := v.(string); ok {
+				groups = append(groups, s)
+			}
+		}
+		return groups
+	}
+	return nil
+}
+
+// rewriteClaims applies the configured rewrite rules to the string claims.
+// Only the first match of each rule is replaced.
+func (am *mgr) rewriteClaims(claims map[string]interface{}) {
+	for _, r := range am.claimRewrites {
+		v, ok := claims[r.claim].(string)
+		if !ok {
+			continue
+		}
+		m := r.re.FindStringSubmatchIndex(v)
+		if m == nil {
+			continue
+		}
+		repl := r.re.ExpandString(nil, r.replacement, v, m)
+		claims[r.claim] = v[:m[0]] + string(repl) + v[m[1]:]
+	}
+}
+
+// displayName returns the display name of the user from the configured claim,
+// or from the name claim if missing.
+func (am *mgr) displayName(claims map[string]interface{}) string {
+	if name, ok := claims[am.c.DisplayNameClaim].(string); ok && name != "" {
+		return name
+	}
+	return claims["name"].(string)
+}
+
+// decorateDisplayName renders the configured display name template for the user,
+// with the claims available to it. When the template fails, for example
+// referring to a missing claim, the display name is left undecorated.
+func (am *mgr) decorateDisplayName(ctx context.Context, u *user.User, claims map[string]interface{}) {
+	if am.c.PostProcessing.DisableDisplayNameDecoration {
+		return
+	}
+	data := make(map[string]interface{}, len(claims)+5)
+	for k, v := range claims {
+		data[k] = v
+	}
+	data["DisplayName"] = u.DisplayName
+	data["Username"] = u.Username
+	data["Mail"] = u.Mail
+	data["Guest"] = isGuest(u.Id)
+	data["MailDomain"] = ""
+	if parts := strings.Split(u.Mail, "@"); len(parts) > 1 {
+		data["MailDomain"] = parts[1]
+	}
+	b := &strings.Builder{}
+	if err := am.displayNameTpl.Execute(b, data); err != nil {
+		appctx.GetLogger(ctx).Warn().Err(err).Str("username", u.Username).Msg("oidc: error executing the display name template, keeping the display name undecorated")
+		return
+	}
+	u.DisplayName = b.String()
+}
+
 func (am *mgr) getUserID(claims map[string]interface{}) (int64, int64) {
 	uidf, _ := claims[am.c.UIDClaim].(float64)
 	uid := int64(uidf)
@@ -270,21 +747,12 @@ func (am *mgr) getUserID(claims map[string]interface{}) (int64, int64) {
 	return uid, gid
 }
 
-func (am *mgr) getOAuthCtx(ctx context.Context) context.Context {
+// getOAuthCtx returns the context of the requests to the IdP sent with the given client.
+func (am *mgr) getOAuthCtx(ctx context.Context, client *http.Client) context.Context {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "getOAuthCtx")
 	defer span.End()
 
-	// Sometimes for testing we need to skip the TLS check, that's why we need a
-	// custom HTTP client.
-	customHTTPClient := rhttp.GetHTTPClient(
-		rhttp.Context(ctx),
-		rhttp.Timeout(time.Second*10),
-		rhttp.Insecure(am.c.Insecure),
-		// Fixes connection fd leak which might be caused by provider-caching
-		rhttp.DisableKeepAlive(true),
-	)
-	ctx = context.WithValue(ctx, oauth2.HTTPClient, customHTTPClient)
-	return ctx
+	return context.WithValue(ctx, oauth2.HTTPClient, client)
 }
 
 // getOIDCProvider returns a singleton OIDC provider.
@@ -292,9 +760,10 @@ func (am *mgr) getOIDCProvider(ctx context.Context) (*oidc.Provider, error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "getOIDCProvider")
 	defer span.End()
 
-	ctx = am.getOAuthCtx(ctx)
 	log := appctx.GetLogger(ctx)
 
+	am.providerMu.Lock()
+	defer am.providerMu.Unlock()
 	if am.provider != nil {
 		return am.provider, nil
 	}
@@ -303,7 +772,9 @@ func (am *mgr) getOIDCProvider(ctx context.Context) (*oidc.Provider, error) {
 	// Once initialized this is a singleton that is reused for further requests.
 	// The provider is responsible to verify the token sent by the client
 	// against the security keys oftentimes available in the .well-known endpoint.
-	provider, err := oidc.NewProvider(ctx, am.c.Issuer)
+	// The provider keeps the context to fetch the keys later on, so it must not
+	// be bound to the current request.
+	provider, err := oidc.NewProvider(am.getOAuthCtx(context.Background(), am.httpClient), am.c.Issuer)
 
 	if err != nil {
 		log.Error().Err(err).Msg("oidc: error creating a new oidc provider")
@@ -314,6 +785,14 @@ func (am *mgr) getOIDCProvider(ctx context.Context) (*oidc.Provider, error) {
 	return am.provider, nil
 }
 
+// Health checks that the discovery document of the OIDC provider can be retrieved.
+func (am *mgr) Health(ctx context.Context) error {
+	if _, err := oidc.NewProvider(am.getOAuthCtx(ctx, am.httpClient), am.c.Issuer); err != nil {
+		return errors.Wrap(err, "oidc: error discovering the oidc provider")
+	}
+	return nil
+}
+
 func (am *mgr) resolveUser(ctx context.Context, claims map[string]interface{}, subject string) error {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "resolveUser")
 	defer span.End()
@@ -329,14 +808,14 @@ func (am *mgr) resolveUser(ctx context.Context, claims map[string]interface{}, s
 		claims[am.c.GIDClaim] = gid
 	}
 
-	if len(am.oidcUsersMapping) > 0 {
+	if usersMapping := am.usersMapping(); len(usersMapping) > 0 {
 		// map and discover the user's username when a mapping is defined
 		if claims[am.c.GroupClaim] == nil {
 			// we are required to perform a user mapping but the group claim is not available
 			return fmt.Errorf("no \"%s\" claim found in userinfo to map user", am.c.GroupClaim)
 		}
-		mappings := make([]string, 0, len(am.oidcUsersMapping))
-		for _, m := range am.oidcUsersMapping {
+		mappings := make([]string, 0, len(usersMapping))
+		for _, m := range usersMapping {
 			if m.OIDCIssuer == claims["iss"] {
 				mappings = append(mappings, m.OIDCGroup)
 			}
@@ -351,7 +830,7 @@ func (am *mgr) resolveUser(ctx context.Context, claims map[string]interface{}, s
 			return errtypes.PermissionDenied("no user mapping found for the given group claim(s)")
 		}
 		for _, m := range intersection {
-			value = am.oidcUsersMapping[m.(string)].Username
+			value = usersMapping[m.(string)].Username
 		}
 		resolve = true
 	} else if uid == 0 || gid == 0 {
@@ -393,6 +872,10 @@ func (am *mgr) resolveUser(ctx context.Context, claims map[string]interface{}, s
 	return nil
 }
 
+func isGuest(u *user.UserId) bool {
+	return u != nil && (u.Type == user.UserType_USER_TYPE_LIGHTWEIGHT || u.Type == user.UserType_USER_TYPE_FEDERATED)
+}
+
 func getUserType(upn string) user.UserType {
 	var t user.UserType
 	switch {
@@ -0,0 +1,1037 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	oidc "github.com/coreos/go-oidc"
+	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/auth"
+	"github.com/cs3org/reva/pkg/auth/scope"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/rhttp"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"go.opencensus.io/plugin/ochttp"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"golang.org/x/oauth2"
+	jose "gopkg.in/square/go-jose.v2"
+)
+
+func newTestManager(t *testing.T, m map[string]interface{}) *mgr {
+	am := &mgr{}
+	if err := am.Configure(m); err != nil {
+		t.Fatalf("error configuring the manager: %v", err)
+	}
+	return am
+}
+
+func TestDefaultPostProcessing(t *testing.T) {
+	am := newTestManager(t, map[string]interface{}{})
+
+	// the email is used verbatim
+	claims := map[string]interface{}{
+		"email": "guest: jdoe@example.org",
+		"name":  "John Doe",
+	}
+	am.rewriteClaims(claims)
+	assert.Equal(t, "guest: jdoe@example.org", claims["email"])
+
+	claims["email"] = "jdoe@example.org"
+
+	// only the display name of the guests is decorated
+	u := &user.User{Id: &user.UserId{Type: user.UserType_USER_TYPE_LIGHTWEIGHT}, Mail: claims["email"].(string), DisplayName: claims["name"].(string)}
+	am.decorateDisplayName(context.Background(), u, claims)
+	assert.Equal(t, "John Doe (example.org)", u.DisplayName)
+
+	u = &user.User{Id: &user.UserId{Type: user.UserType_USER_TYPE_PRIMARY}, Mail: claims["email"].(string), DisplayName: claims["name"].(string)}
+	am.decorateDisplayName(context.Background(), u, claims)
+	assert.Equal(t, "John Doe", u.DisplayName)
+}
+
+func TestDefaultPostProcessingWithoutPrefix(t *testing.T) {
+	am := newTestManager(t, map[string]interface{}{})
+
+	claims := map[string]interface{}{
+		"email": "jdoe@example.org",
+	}
+	am.rewriteClaims(claims)
+	assert.Equal(t, "jdoe@example.org", claims["email"])
+}
+
+func TestCustomClaimRewrites(t *testing.T) {
+	am := newTestManager(t, map[string]interface{}{
+		"post_processing": map[string]interface{}{
+			"claim_rewrites": []map[string]interface{}{
+				{"claim": "email", "match": `^ext-(\w+)@`, "replacement": "${1}@"},
+				{"claim": "name", "match": `\s*\(external\)$`},
+				{"claim": "uid", "match": ".*", "replacement": "ignored"},
+			},
+		},
+	})
+
+	claims := map[string]interface{}{
+		"email": "ext-jdoe@example.org",
+		"name":  "John Doe (external)",
+		"uid":   float64(1000),
+	}
+	am.rewriteClaims(claims)
+	assert.Equal(t, "jdoe@example.org", claims["email"])
+	assert.Equal(t, "John Doe", claims["name"])
+	assert.Equal(t, float64(1000), claims["uid"])
+}
+
+func TestStripMailPrefixes(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     map[string]interface{}
+		email    string
+		expected string
+	}{
+		{
+			name:     "guest prefix",
+			conf:     map[string]interface{}{"strip_guest_prefix": true},
+			email:    "guest: jdoe@example.org",
+			expected: "jdoe@example.org",
+		},
+		{
+			name:     "guest prefix not at the start",
+			conf:     map[string]interface{}{"strip_guest_prefix": true},
+			email:    "jdoe+guest: @example.org",
+			expected: "jdoe+guest: @example.org",
+		},
+		{
+			name:     "configured prefixes",
+			conf:     map[string]interface{}{"strip_mail_prefixes": []string{"ext: ", "guest: "}},
+			email:    "ext: jdoe@example.org",
+			expected: "jdoe@example.org",
+		},
+		{
+			name: "prefixes before the rewrites",
+			conf: map[string]interface{}{
+				"strip_guest_prefix": true,
+				"claim_rewrites":     []map[string]interface{}{{"claim": "email", "match": "@example.org$", "replacement": "@example.com"}},
+			},
+			email:    "guest: jdoe@example.org",
+			expected: "jdoe@example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			am := newTestManager(t, map[string]interface{}{"post_processing": tt.conf})
+			claims := map[string]interface{}{"email": tt.email}
+			am.rewriteClaims(claims)
+			assert.Equal(t, tt.expected, claims["email"])
+		})
+	}
+}
+
+func TestEmptyClaimRewrites(t *testing.T) {
+	am := newTestManager(t, map[string]interface{}{
+		"post_processing": map[string]interface{}{
+			"claim_rewrites": []map[string]interface{}{},
+		},
+	})
+
+	claims := map[string]interface{}{
+		"email": "guest: jdoe@example.org",
+	}
+	am.rewriteClaims(claims)
+	assert.Equal(t, "guest: jdoe@example.org", claims["email"])
+}
+
+func TestInvalidClaimRewrite(t *testing.T) {
+	am := &mgr{}
+	err := am.Configure(map[string]interface{}{
+		"post_processing": map[string]interface{}{
+			"claim_rewrites": []map[string]interface{}{
+				{"claim": "email", "match": "("},
+			},
+		},
+	})
+	assert.Error(t, err)
+}
+
+func TestDisplayNameDecoration(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     map[string]interface{}
+		expected string
+	}{
+		{
+			"custom template",
+			map[string]interface{}{
+				"display_name_template": "{{.DisplayName}} [{{.Username}}]",
+			},
+			"John Doe [jdoe]",
+		},
+		{
+			"claims in the template",
+			map[string]interface{}{
+				"display_name_template": "{{.name}} [{{.iss}}]",
+			},
+			"Johnny [https://idp.example.org]",
+		},
+		{
+			"missing claim in the template",
+			map[string]interface{}{
+				"display_name_template": "{{.DisplayName}} ({{.affiliation}})",
+			},
+			"John Doe",
+		},
+		{
+			"disabled decoration",
+			map[string]interface{}{
+				"display_name_template": "{{.DisplayName}} [{{.Username}}]",
+				"post_processing": map[string]interface{}{
+					"disable_display_name_decoration": true,
+				},
+			},
+			"John Doe",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			am := newTestManager(t, tt.conf)
+			u := &user.User{Id: &user.UserId{Type: user.UserType_USER_TYPE_PRIMARY}, Username: "jdoe", Mail: "jdoe@example.org", DisplayName: "John Doe"}
+			am.decorateDisplayName(context.Background(), u, map[string]interface{}{"name": "Johnny", "iss": "https://idp.example.org"})
+			assert.Equal(t, tt.expected, u.DisplayName)
+		})
+	}
+}
+
+func TestCheckGroups(t *testing.T) {
+	tests := []struct {
+		name       string
+		conf       map[string]interface{}
+		claims     map[string]interface{}
+		authorized bool
+	}{
+		{
+			"no groups configured",
+			map[string]interface{}{},
+			map[string]interface{}{},
+			true,
+		},
+		{
+			"member of an authorized group",
+			map[string]interface{}{"authorized_groups": []string{"cernbox-users"}},
+			map[string]interface{}{"groups": []interface{}{"it-dep", "cernbox-users"}},
+			true,
+		},
+		{
+			"not member of an authorized group",
+			map[string]interface{}{"authorized_groups": []string{"cernbox-users"}},
+			map[string]interface{}{"groups": []interface{}{"it-dep"}},
+			false,
+		},
+		{
+			"member of a denied group",
+			map[string]interface{}{"authorized_groups": []string{"cernbox-users"}, "denied_groups": []string{"banned"}},
+			map[string]interface{}{"groups": []interface{}{"cernbox-users", "banned"}},
+			false,
+		},
+		{
+			"not member of a denied group",
+			map[string]interface{}{"denied_groups": []string{"banned"}},
+			map[string]interface{}{"groups": "cernbox-users"},
+			true,
+		},
+		{
+			"custom group claim",
+			map[string]interface{}{"group_claim": "roles", "authorized_groups": []string{"cernbox-users"}},
+			map[string]interface{}{"roles": []interface{}{"cernbox-users"}},
+			true,
+		},
+		{
+			"missing group claim with authorized groups",
+			map[string]interface{}{"authorized_groups": []string{"cernbox-users"}},
+			map[string]interface{}{},
+			false,
+		},
+		{
+			"missing group claim with denied groups",
+			map[string]interface{}{"denied_groups": []string{"banned"}},
+			map[string]interface{}{},
+			true,
+		},
+		{
+			"member of an allowed group",
+			map[string]interface{}{"allowed_groups": []string{"cernbox-users"}},
+			map[string]interface{}{"groups": []interface{}{"it-dep", "cernbox-users"}},
+			true,
+		},
+		{
+			"allowed and authorized groups are merged",
+			map[string]interface{}{"allowed_groups": []string{"cernbox-users"}, "authorized_groups": []string{"it-dep"}},
+			map[string]interface{}{"groups": []interface{}{"it-dep"}},
+			true,
+		},
+		{
+			"normalized groups",
+			map[string]interface{}{"allowed_groups": []string{"/cernbox-users "}, "denied_groups": []string{"banned"}},
+			map[string]interface{}{"groups": []interface{}{"/cernbox-users"}},
+			true,
+		},
+		{
+			"normalized denied groups",
+			map[string]interface{}{"denied_groups": []string{"banned"}},
+			map[string]interface{}{"groups": []interface{}{"/banned"}},
+			false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			am := newTestManager(t, tt.conf)
+			err := am.checkGroups(tt.claims)
+			if tt.authorized {
+				assert.NoError(t, err)
+			} else {
+				assert.IsType(t, errtypes.PermissionDenied(""), err)
+			}
+		})
+	}
+}
+
+func newTestIdP(t *testing.T, claims map[string]interface{}) *httptest.Server {
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"issuer":                 srv.URL,
+				"authorization_endpoint": srv.URL + "/auth",
+				"token_endpoint":         srv.URL + "/token",
+				"jwks_uri":               srv.URL + "/keys",
+				"userinfo_endpoint":      srv.URL + "/userinfo",
+			})
+		case "/userinfo":
+			_ = json.NewEncoder(w).Encode(claims)
+		case "/keys":
+			_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{
+				Keys: []jose.JSONWebKey{{Key: testKey(t).Public(), KeyID: "test", Algorithm: "RS256", Use: "sig"}},
+			})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+var (
+	testKeyOnce sync.Once
+	testRSAKey  *rsa.PrivateKey
+)
+
+// testKey returns the key signing the tokens of the test IdP.
+func testKey(t *testing.T) *rsa.PrivateKey {
+	testKeyOnce.Do(func() {
+		var err error
+		if testRSAKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+			t.Fatalf("error generating the signing key: %v", err)
+		}
+	})
+	return testRSAKey
+}
+
+// signTestToken returns a JWT with the given claims, signed by the test IdP.
+func signTestToken(t *testing.T, claims map[string]interface{}) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: testKey(t)}, (&jose.SignerOptions{}).WithHeader("kid", "test"))
+	if err != nil {
+		t.Fatalf("error creating the signer: %v", err)
+	}
+	payload, _ := json.Marshal(claims)
+	jws, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatalf("error signing the token: %v", err)
+	}
+	token, err := jws.CompactSerialize()
+	if err != nil {
+		t.Fatalf("error serializing the token: %v", err)
+	}
+	return token
+}
+
+// countDials makes the client count the connections it opens.
+func countDials(client *http.Client) *int32 {
+	var dials int32
+	tr := client.Transport.(*ochttp.Transport).Base.(*http.Transport)
+	dialer := &net.Dialer{}
+	tr.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		atomic.AddInt32(&dials, 1)
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return &dials
+}
+
+func TestConnectionReuse(t *testing.T) {
+	const requests = 5
+	srv := newTestIdP(t, map[string]interface{}{"sub": "einstein"})
+	tokenSource := oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "token"})
+
+	// a client per request without keep-alives, as done previously,
+	// opens a new connection for every request
+	var legacyDials int32
+	for i := 0; i < requests; i++ {
+		client := rhttp.GetHTTPClient(rhttp.DisableKeepAlive(true))
+		dials := countDials(client)
+		ctx := context.WithValue(context.Background(), oauth2.HTTPClient, client)
+		provider, err := oidc.NewProvider(ctx, srv.URL)
+		assert.NoError(t, err)
+		_, err = provider.UserInfo(ctx, tokenSource)
+		assert.NoError(t, err)
+		legacyDials += *dials
+	}
+	assert.Equal(t, int32(2*requests), legacyDials)
+
+	// the client of the manager keeps the connection open
+	am := newTestManager(t, map[string]interface{}{"issuer": srv.URL})
+	dials := countDials(am.httpClient)
+	for i := 0; i < requests; i++ {
+		ctx := am.getOAuthCtx(context.Background(), am.userInfoClient)
+		provider, err := am.getOIDCProvider(ctx)
+		assert.NoError(t, err)
+		info, err := provider.UserInfo(ctx, tokenSource)
+		assert.NoError(t, err)
+		assert.Equal(t, "einstein", info.Subject)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(dials))
+}
+
+func TestOperationTimeouts(t *testing.T) {
+	am := newTestManager(t, map[string]interface{}{})
+	assert.Equal(t, 10*time.Second, am.httpClient.Timeout)
+	assert.Equal(t, 10*time.Second, am.userInfoClient.Timeout)
+
+	am = newTestManager(t, map[string]interface{}{"http_timeout": 5, "discovery_timeout": 30})
+	assert.Equal(t, 30*time.Second, am.httpClient.Timeout)
+	assert.Equal(t, 5*time.Second, am.userInfoClient.Timeout)
+	assert.Same(t, am.httpClient.Transport, am.userInfoClient.Transport)
+
+	// a slow userinfo endpoint fails the authentication, but not the discovery
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{
+				"issuer":            srv.URL,
+				"jwks_uri":          srv.URL + "/keys",
+				"userinfo_endpoint": srv.URL + "/userinfo",
+			})
+		case "/userinfo":
+			time.Sleep(1500 * time.Millisecond)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"sub": "einstein", "name": "Albert Einstein", "email": "einstein@example.org"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	am = newTestManager(t, map[string]interface{}{"issuer": srv.URL, "userinfo_timeout": 1})
+	assert.NoError(t, am.Health(context.Background()))
+	_, _, err := am.Authenticate(context.Background(), "", "token")
+	assert.Error(t, err)
+}
+
+func TestHTTPProxy(t *testing.T) {
+	srv := newTestIdP(t, map[string]interface{}{
+		"sub":   "einstein",
+		"name":  "Albert Einstein",
+		"email": "einstein@example.org",
+		"uid":   1000,
+		"gid":   1000,
+	})
+
+	// the stub proxy forwards the requests to the IdP, recording their paths
+	var mu sync.Mutex
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		proxied = append(proxied, r.URL.Path)
+		mu.Unlock()
+
+		req, _ := http.NewRequest(r.Method, r.URL.String(), r.Body)
+		req.Header = r.Header.Clone()
+		res, err := (&http.Transport{}).RoundTrip(req)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer res.Body.Close()
+		for k, v := range res.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(res.StatusCode)
+		_, _ = io.Copy(w, res.Body)
+	}))
+	t.Cleanup(proxy.Close)
+
+	am := newTestManager(t, map[string]interface{}{
+		"issuer":            srv.URL,
+		"uid_claim":         "uid",
+		"gid_claim":         "gid",
+		"groups_from_claim": true,
+		"http_proxy":        proxy.URL,
+	})
+	u, _, err := am.Authenticate(context.Background(), "", "token")
+	assert.NoError(t, err)
+	if assert.NotNil(t, u) {
+		assert.Equal(t, "einstein", u.Id.OpaqueId)
+	}
+	// both the discovery and the userinfo go through the proxy
+	assert.Equal(t, []string{"/.well-known/openid-configuration", "/userinfo"}, proxied)
+
+	err = (&mgr{}).Configure(map[string]interface{}{"issuer": srv.URL, "http_proxy": "proxy:3128"})
+	assert.Error(t, err)
+}
+
+func TestNormalizeGroups(t *testing.T) {
+	assert.Equal(t, []string{}, normalizeGroups(nil))
+	assert.Equal(t,
+		[]string{"cernbox-users", "it-dep", "projects/cernbox"},
+		normalizeGroups([]string{"cernbox-users", "/it-dep", " /cernbox-users ", "", "/projects/cernbox"}),
+	)
+}
+
+func TestGroupsFromClaim(t *testing.T) {
+	srv := newTestIdP(t, map[string]interface{}{
+		"sub":    "einstein",
+		"name":   "Albert Einstein",
+		"email":  "einstein@example.org",
+		"uid":    1000,
+		"gid":    1000,
+		"groups": []interface{}{"/cernbox-users", "it-dep", "cernbox-users"},
+	})
+	conf := map[string]interface{}{
+		"issuer":     srv.URL,
+		"uid_claim":  "uid",
+		"gid_claim":  "gid",
+		"gatewaysvc": "localhost:1", // nothing is listening there
+	}
+
+	// the groups are taken from the claim, without calling the gateway
+	conf["groups_from_claim"] = true
+	am := newTestManager(t, conf)
+	u, _, err := am.Authenticate(context.Background(), "", "token")
+	assert.NoError(t, err)
+	if assert.NotNil(t, u) {
+		assert.Equal(t, []string{"cernbox-users", "it-dep"}, u.Groups)
+	}
+
+	// by default the groups are looked up through the gateway
+	conf["groups_from_claim"] = false
+	am = newTestManager(t, conf)
+	_, _, err = am.Authenticate(context.Background(), "", "token")
+	assert.Error(t, err)
+}
+
+func TestAllowedAudiences(t *testing.T) {
+	userinfo := map[string]interface{}{
+		"sub":   "einstein",
+		"name":  "Albert Einstein",
+		"email": "einstein@example.org",
+		"uid":   1000,
+		"gid":   1000,
+	}
+	withAudience := func(aud interface{}) map[string]interface{} {
+		claims := map[string]interface{}{"aud": aud}
+		for k, v := range userinfo {
+			claims[k] = v
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name      string
+		allowed   []string
+		userinfo  map[string]interface{}
+		token     func(issuer string) string
+		forbidden bool
+	}{
+		{
+			name:     "no allowlist",
+			userinfo: withAudience("other-client"),
+		},
+		{
+			name:     "audience in the userinfo",
+			allowed:  []string{"cernbox"},
+			userinfo: withAudience("cernbox"),
+		},
+		{
+			name:     "audience list in the userinfo",
+			allowed:  []string{"cernbox", "cernbox-mobile"},
+			userinfo: withAudience([]interface{}{"other-client", "cernbox-mobile"}),
+		},
+		{
+			name:      "other audience in the userinfo",
+			allowed:   []string{"cernbox"},
+			userinfo:  withAudience([]interface{}{"other-client"}),
+			forbidden: true,
+		},
+		{
+			name:     "audience in the access token",
+			allowed:  []string{"cernbox"},
+			userinfo: userinfo,
+			token: func(issuer string) string {
+				return signTestToken(t, map[string]interface{}{
+					"iss": issuer, "sub": "einstein", "aud": []string{"cernbox"}, "exp": time.Now().Add(time.Hour).Unix(),
+				})
+			},
+		},
+		{
+			name:     "other audience in the access token",
+			allowed:  []string{"cernbox"},
+			userinfo: userinfo,
+			token: func(issuer string) string {
+				return signTestToken(t, map[string]interface{}{
+					"iss": issuer, "sub": "einstein", "aud": "other-client", "exp": time.Now().Add(time.Hour).Unix(),
+				})
+			},
+			forbidden: true,
+		},
+		{
+			name:     "expired access token",
+			allowed:  []string{"cernbox"},
+			userinfo: userinfo,
+			token: func(issuer string) string {
+				return signTestToken(t, map[string]interface{}{
+					"iss": issuer, "sub": "einstein", "aud": "cernbox", "exp": time.Now().Add(-time.Hour).Unix(),
+				})
+			},
+			forbidden: true,
+		},
+		{
+			name:      "opaque access token",
+			allowed:   []string{"cernbox"},
+			userinfo:  userinfo,
+			forbidden: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestIdP(t, tt.userinfo)
+			am := newTestManager(t, map[string]interface{}{
+				"issuer":            srv.URL,
+				"uid_claim":         "uid",
+				"gid_claim":         "gid",
+				"groups_from_claim": true,
+				"allowed_audiences": tt.allowed,
+			})
+			token := "token"
+			if tt.token != nil {
+				token = tt.token(srv.URL)
+			}
+
+			u, _, err := am.Authenticate(context.Background(), "", token)
+			if tt.forbidden {
+				assert.IsType(t, errtypes.InvalidCredentials(""), err)
+				return
+			}
+			assert.NoError(t, err)
+			if assert.NotNil(t, u) {
+				assert.Equal(t, "einstein", u.Id.OpaqueId)
+			}
+		})
+	}
+}
+
+func TestReloadUsersMapping(t *testing.T) {
+	mappingFile := filepath.Join(t.TempDir(), "mapping.json")
+	writeMapping := func(content string) {
+		if err := os.WriteFile(mappingFile, []byte(content), 0600); err != nil {
+			t.Fatalf("error writing the users mapping file: %v", err)
+		}
+	}
+	writeMapping(`[{"oidc_issuer": "https://idp.example.org", "oidc_group": "cernbox-users", "username": "einstein"}]`)
+
+	am := newTestManager(t, map[string]interface{}{"users_mapping": mappingFile})
+	var _ auth.Reloader = am
+	assert.Equal(t, "einstein", am.usersMapping()["cernbox-users"].Username)
+
+	writeMapping(`[
+		{"oidc_issuer": "https://idp.example.org", "oidc_group": "cernbox-users", "username": "einstein"},
+		{"oidc_issuer": "https://idp.example.org", "oidc_group": "it-dep", "username": "marie"}
+	]`)
+	assert.NoError(t, am.Reload(context.Background()))
+	assert.Len(t, am.usersMapping(), 2)
+	assert.Equal(t, "marie", am.usersMapping()["it-dep"].Username)
+
+	// on error, the previous mapping is kept
+	writeMapping(`[{"oidc_group": `)
+	assert.Error(t, am.Reload(context.Background()))
+	assert.Len(t, am.usersMapping(), 2)
+
+	writeMapping(`[
+		{"oidc_issuer": "https://idp.example.org", "oidc_group": "cernbox-users", "username": "einstein"},
+		{"oidc_issuer": "https://idp.example.org", "oidc_group": "cernbox-users", "username": "marie"}
+	]`)
+	assert.Error(t, am.Reload(context.Background()))
+	assert.Len(t, am.usersMapping(), 2)
+	assert.Equal(t, "einstein", am.usersMapping()["cernbox-users"].Username)
+}
+
+func TestReloadUsersMappingConcurrentAuthenticate(t *testing.T) {
+	srv := newTestIdP(t, map[string]interface{}{
+		"sub":    "einstein",
+		"name":   "Albert Einstein",
+		"email":  "einstein@example.org",
+		"uid":    1000,
+		"gid":    1000,
+		"groups": []interface{}{"physics"},
+	})
+
+	// the groups of the user are never mapped, so that no user is looked up
+	valid := []byte(`[{"oidc_issuer": "` + srv.URL + `", "oidc_group": "cernbox-users", "username": "einstein"}]`)
+	mappingFile := filepath.Join(t.TempDir(), "mapping.json")
+	assert.NoError(t, os.WriteFile(mappingFile, valid, 0600))
+
+	am := newTestManager(t, map[string]interface{}{
+		"issuer":        srv.URL,
+		"uid_claim":     "uid",
+		"gid_claim":     "gid",
+		"users_mapping": mappingFile,
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			content := valid
+			if i%2 == 1 {
+				content = []byte(`[{"oidc_group": `)
+			}
+			assert.NoError(t, os.WriteFile(mappingFile, content, 0600))
+			_ = am.Reload(context.Background())
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				_, _, err := am.Authenticate(context.Background(), "", "token")
+				assert.IsType(t, errtypes.PermissionDenied(""), errors.Cause(err))
+			}
+		}()
+	}
+	wg.Wait()
+	<-done
+
+	assert.Len(t, am.usersMapping(), 1)
+}
+
+func TestServiceAccounts(t *testing.T) {
+	tests := []struct {
+		name      string
+		userinfo  map[string]interface{}
+		username  string
+		userType  user.UserType
+		role      authpb.Role
+		path      string
+		forbidden bool
+		notMapped bool
+	}{
+		{
+			name:     "client_id in the allowlist",
+			userinfo: map[string]interface{}{"sub": "service-account-batch", "client_id": "batch"},
+			username: "batch-jobs",
+			userType: user.UserType_USER_TYPE_APPLICATION,
+			role:     authpb.Role_ROLE_VIEWER,
+			path:     "/eos/project/b/batch",
+		},
+		{
+			name:     "audience of the service account",
+			userinfo: map[string]interface{}{"sub": "service-account-backup", "aud": []interface{}{"backup"}},
+			username: "backup",
+			userType: user.UserType_USER_TYPE_SERVICE,
+			role:     authpb.Role_ROLE_EDITOR,
+			path:     "/eos/project/b/backup",
+		},
+		{
+			name:      "unknown client_id",
+			userinfo:  map[string]interface{}{"sub": "service-account-other", "client_id": "other"},
+			forbidden: true,
+		},
+		{
+			name:      "user token with the audience of the service account",
+			userinfo:  map[string]interface{}{"sub": "jdoe", "email": "jdoe@example.org", "name": "John Doe", "aud": []interface{}{"backup"}},
+			notMapped: true,
+		},
+		{
+			name:      "user token with the client_id of the service account",
+			userinfo:  map[string]interface{}{"sub": "jdoe", "email": "jdoe@example.org", "name": "John Doe", "client_id": "batch"},
+			notMapped: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestIdP(t, tt.userinfo)
+			am := newTestManager(t, map[string]interface{}{
+				"issuer":     srv.URL,
+				"gatewaysvc": "localhost:1", // nothing is listening there
+				"service_accounts": []map[string]interface{}{
+					{"client_id": "batch", "username": "batch-jobs", "path": "/eos/project/b/batch"},
+					{"audience": "backup", "username": "backup", "user_type": "service", "role": "editor", "path": "/eos/project/b/backup"},
+				},
+			})
+
+			u, scopes, err := am.Authenticate(context.Background(), "", "token")
+			if tt.notMapped {
+				// the user is looked up as usual, failing without a gateway
+				assert.Error(t, err)
+				assert.Nil(t, u)
+				assert.Nil(t, scopes)
+				return
+			}
+			if tt.forbidden {
+				assert.IsType(t, errtypes.PermissionDenied(""), err)
+				return
+			}
+			assert.NoError(t, err)
+			if assert.NotNil(t, u) {
+				assert.Equal(t, tt.username, u.Username)
+				assert.Equal(t, tt.username, u.Id.OpaqueId)
+				assert.Equal(t, srv.URL, u.Id.Idp)
+				assert.Equal(t, tt.userType, u.Id.Type)
+				assert.Empty(t, u.Mail)
+			}
+			// the service accounts never get the owner scope
+			assert.NotContains(t, scopes, "user")
+			if assert.Contains(t, scopes, "resourcepath:"+tt.path) {
+				assert.Len(t, scopes, 1)
+				assert.Equal(t, tt.role, scopes["resourcepath:"+tt.path].Role)
+			}
+			ok, err := scope.VerifyScope(context.Background(), scopes, &provider.StatRequest{Ref: &provider.Reference{Path: tt.path + "/file"}})
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			ok, err = scope.VerifyScope(context.Background(), scopes, &provider.StatRequest{Ref: &provider.Reference{Path: "/eos/project/b"}})
+			assert.NoError(t, err)
+			assert.False(t, ok)
+		})
+	}
+}
+
+func TestInvalidServiceAccounts(t *testing.T) {
+	for _, sa := range []map[string]interface{}{
+		{"client_id": "batch", "path": "/eos/project/b/batch"},
+		{"username": "batch-jobs", "path": "/eos/project/b/batch"},
+		{"client_id": "batch", "username": "batch-jobs", "path": "/eos/project/b/batch", "user_type": "primary"},
+		{"client_id": "batch", "username": "batch-jobs", "path": "/eos/project/b/batch", "role": "owner"},
+		{"client_id": "batch", "username": "batch-jobs"},
+		{"client_id": "batch", "username": "batch-jobs", "path": "eos/project/b/batch"},
+	} {
+		am := &mgr{}
+		err := am.Configure(map[string]interface{}{"service_accounts": []map[string]interface{}{sa}})
+		assert.Error(t, err, sa)
+	}
+}
+
+func TestDisplayNameClaim(t *testing.T) {
+	srv := newTestIdP(t, map[string]interface{}{
+		"sub":      "einstein",
+		"name":     "Albert Einstein",
+		"nickname": "Albert",
+		"email":    "einstein@example.org",
+		"uid":      1000,
+		"gid":      1000,
+	})
+	conf := map[string]interface{}{
+		"issuer":            srv.URL,
+		"uid_claim":         "uid",
+		"gid_claim":         "gid",
+		"groups_from_claim": true,
+	}
+
+	for claim, expected := range map[string]string{"": "Albert Einstein", "nickname": "Albert", "missing": "Albert Einstein"} {
+		conf["display_name_claim"] = claim
+		am := newTestManager(t, conf)
+		u, _, err := am.Authenticate(context.Background(), "", "token")
+		assert.NoError(t, err)
+		if assert.NotNil(t, u) {
+			assert.Equal(t, expected, u.DisplayName, claim)
+		}
+	}
+}
+
+// authenticationCount returns the number of authentications recorded in the metrics with the outcome.
+func authenticationCount(t *testing.T, outcome string) int64 {
+	rows, err := view.RetrieveData(authentications.Name())
+	if err != nil {
+		t.Fatalf("not expected error while retrieving the metrics: %+v", err)
+	}
+	for _, r := range rows {
+		if len(r.Tags) == 1 && r.Tags[0].Value == outcome {
+			return r.Data.(*view.CountData).Value
+		}
+	}
+	return 0
+}
+
+// latencyCount returns the number of calls recorded in the latency metrics with the outcome.
+func latencyCount(t *testing.T, m *stats.Float64Measure, outcome string) int64 {
+	rows, err := view.RetrieveData(m.Name())
+	if err != nil {
+		t.Fatalf("not expected error while retrieving the metrics: %+v", err)
+	}
+	for _, r := range rows {
+		if len(r.Tags) == 1 && r.Tags[0].Value == outcome {
+			return r.Data.(*view.DistributionData).Count
+		}
+	}
+	return 0
+}
+
+func TestAuthenticationMetrics(t *testing.T) {
+	claims := map[string]interface{}{
+		"sub":    "einstein",
+		"name":   "Albert Einstein",
+		"email":  "einstein@example.org",
+		"uid":    1000,
+		"gid":    1000,
+		"groups": []interface{}{"cernbox-users"},
+	}
+	srv := newTestIdP(t, claims)
+	conf := map[string]interface{}{
+		"issuer":     srv.URL,
+		"uid_claim":  "uid",
+		"gid_claim":  "gid",
+		"gatewaysvc": "localhost:1", // nothing is listening there
+	}
+
+	type counts struct {
+		ok, groupFetch, missingClaim, userInfo int64
+		userInfoOK, userInfoErr, userGroupsErr int64
+	}
+	get := func() counts {
+		return counts{
+			ok:            authenticationCount(t, outcomeOK),
+			groupFetch:    authenticationCount(t, outcomeGroupFetchError),
+			missingClaim:  authenticationCount(t, outcomeMissingClaim),
+			userInfo:      authenticationCount(t, outcomeUserInfoError),
+			userInfoOK:    latencyCount(t, userInfoLatency, outcomeOK),
+			userInfoErr:   latencyCount(t, userInfoLatency, outcomeError),
+			userGroupsErr: latencyCount(t, userGroupsLatency, outcomeError),
+		}
+	}
+
+	// the groups can't be fetched from the gateway
+	am := newTestManager(t, conf)
+	before := get()
+	_, _, err := am.Authenticate(context.Background(), "", "token")
+	assert.Error(t, err)
+	after := get()
+	assert.Equal(t, before.groupFetch+1, after.groupFetch)
+	assert.Equal(t, before.userInfoOK+1, after.userInfoOK)
+	assert.Equal(t, before.userGroupsErr+1, after.userGroupsErr)
+	assert.Equal(t, before.ok, after.ok)
+
+	conf["groups_from_claim"] = true
+	am = newTestManager(t, conf)
+	before = get()
+	_, _, err = am.Authenticate(context.Background(), "", "token")
+	assert.NoError(t, err)
+	after = get()
+	assert.Equal(t, before.ok+1, after.ok)
+	assert.Equal(t, before.userInfoOK+1, after.userInfoOK)
+	assert.Equal(t, before.userGroupsErr, after.userGroupsErr)
+
+	delete(claims, "email")
+	before = get()
+	_, _, err = am.Authenticate(context.Background(), "", "token")
+	assert.Error(t, err)
+	after = get()
+	assert.Equal(t, before.missingClaim+1, after.missingClaim)
+
+	// the provider is already discovered, but the IdP is down
+	srv.Close()
+	before = get()
+	_, _, err = am.Authenticate(context.Background(), "", "token")
+	assert.Error(t, err)
+	after = get()
+	assert.Equal(t, before.userInfo+1, after.userInfo)
+	assert.Equal(t, before.userInfoErr+1, after.userInfoErr)
+}
+
+func TestAllowedGroups(t *testing.T) {
+	claims := map[string]interface{}{
+		"sub":    "einstein",
+		"name":   "Albert Einstein",
+		"email":  "einstein@example.org",
+		"uid":    1000,
+		"gid":    1000,
+		"groups": []interface{}{"/cernbox-users", "it-dep"},
+	}
+	srv := newTestIdP(t, claims)
+	conf := map[string]interface{}{
+		"issuer":            srv.URL,
+		"groups_from_claim": true,
+		"gatewaysvc":        "localhost:1", // nothing is listening there
+	}
+
+	// without allowlist every user logs in
+	am := newTestManager(t, conf)
+	_, _, err := am.Authenticate(context.Background(), "", "token")
+	assert.NoError(t, err)
+
+	conf["allowed_groups"] = []string{"cernbox-users", "cernbox-admins"}
+	am = newTestManager(t, conf)
+	u, _, err := am.Authenticate(context.Background(), "", "token")
+	assert.NoError(t, err)
+	if assert.NotNil(t, u) {
+		assert.Equal(t, []string{"cernbox-users", "it-dep"}, u.Groups)
+	}
+
+	// the users are rejected before being resolved, that would fail
+	delete(claims, "uid")
+	delete(claims, "gid")
+	claims["groups"] = []interface{}{"it-dep"}
+	_, _, err = am.Authenticate(context.Background(), "", "token")
+	assert.IsType(t, errtypes.PermissionDenied(""), err)
+
+	delete(claims, "groups")
+	_, _, err = am.Authenticate(context.Background(), "", "token")
+	assert.IsType(t, errtypes.PermissionDenied(""), err)
+}
@@ -150,7 +150,8 @@ func (m *manager) Authenticate(ctx context.Context, token, secret string) (*user
 		roleStr = "editor"
 	}
 
-	scope, err := scope.AddPublicShareScope(share, role, nil)
+	// the token is restricted to the subtree and the permissions of the share
+	scope, err := scope.AddPublicSharePermissionsScope(share, role, nil)
 	if err != nil {
 		return nil, nil, err
 	}
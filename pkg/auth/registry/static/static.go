@@ -20,6 +20,14 @@ package static
 
 import (
 	"context"
+	"encoding/json"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
 
 	registrypb "github.com/cs3org/go-cs3apis/cs3/auth/registry/v1beta1"
 	"github.com/cs3org/reva/pkg/auth"
@@ -28,6 +36,8 @@ import (
 	"github.com/cs3org/reva/pkg/sharedconf"
 	"github.com/cs3org/reva/pkg/tracing"
 	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 )
 
 const tracerName = "static"
@@ -37,7 +47,14 @@ func init() {
 }
 
 type config struct {
-	Rules map[string]string `mapstructure:"rules"`
+	Rules            map[string]string `mapstructure:"rules"`
+	EnableRegexRules bool              `mapstructure:"enable_regex_rules"`
+	// RulesFile is a JSON file mapping the auth types to the providers addresses.
+	// When set, it is used instead of the rules in the configuration.
+	RulesFile string `mapstructure:"rules_file"`
+	// ReloadInterval is the interval in seconds at which the rules file is reloaded.
+	// If 0, the rules are loaded only once.
+	ReloadInterval int `mapstructure:"reload_interval"`
 }
 
 func (c *config) init() {
@@ -49,14 +66,26 @@ func (c *config) init() {
 }
 
 type reg struct {
-	rules map[string]string
+	c        *config
+	rules    map[string]string
+	patterns []*pattern
+	sync.RWMutex
+}
+
+// pattern is a rule whose auth type is interpreted as a regular expression.
+type pattern struct {
+	re      *regexp.Regexp
+	address string
 }
 
 func (r *reg) ListProviders(ctx context.Context) ([]*registrypb.ProviderInfo, error) {
 	_, span := tracing.SpanStartFromContext(ctx, tracerName, "ListProviders")
 	defer span.End()
 
-	providers := make([]*registrypb.ProviderInfo, len(r.rules))
+	r.RLock()
+	defer r.RUnlock()
+
+	providers := make([]*registrypb.ProviderInfo, 0, len(r.rules))
 	for k, v := range r.rules {
 		providers = append(providers, &registrypb.ProviderInfo{
 			ProviderType: k,
@@ -70,12 +99,23 @@ func (r *reg) GetProvider(ctx context.Context, authType string) (*registrypb.Pro
 	_, span := tracing.SpanStartFromContext(ctx, tracerName, "GetProvider")
 	defer span.End()
 
+	r.RLock()
+	defer r.RUnlock()
+
 	if address, ok := r.rules[authType]; ok {
 		return &registrypb.ProviderInfo{
 			ProviderType: authType,
 			Address:      address,
 		}, nil
 	}
+	for _, p := range r.patterns {
+		if p.re.MatchString(authType) {
+			return &registrypb.ProviderInfo{
+				ProviderType: authType,
+				Address:      p.address,
+			}, nil
+		}
+	}
 	return nil, errtypes.NotFound("static: auth type not found: " + authType)
 }
 
@@ -94,5 +134,80 @@ func New(m map[string]interface{}) (auth.Registry, error) {
 		return nil, err
 	}
 	c.init()
-	return &reg{rules: c.Rules}, nil
+
+	r := &reg{c: c}
+	if err := r.load(); err != nil {
+		return nil, err
+	}
+	if c.RulesFile != "" && c.ReloadInterval > 0 {
+		go r.reloadRules()
+	}
+	return r, nil
+}
+
+// load reads the rules and atomically replaces the ones in use.
+// On error, the rules in use are kept.
+func (r *reg) load() error {
+	rules := r.c.Rules
+	if r.c.RulesFile != "" {
+		f, err := os.ReadFile(r.c.RulesFile)
+		if err != nil {
+			return errors.Wrap(err, "static: error reading the rules file")
+		}
+		rules = map[string]string{}
+		if err := json.Unmarshal(f, &rules); err != nil {
+			return errors.Wrap(err, "static: error unmarshalling the rules file")
+		}
+	}
+
+	var patterns []*pattern
+	if r.c.EnableRegexRules {
+		var err error
+		if patterns, err = compilePatterns(rules); err != nil {
+			return err
+		}
+	}
+
+	r.Lock()
+	defer r.Unlock()
+	r.rules = rules
+	r.patterns = patterns
+	return nil
+}
+
+func (r *reg) reloadRules() {
+	ticker := time.NewTicker(time.Duration(r.c.ReloadInterval) * time.Second)
+	work := make(chan os.Signal, 1)
+	signal.Notify(work, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT)
+
+	for {
+		select {
+		case <-work:
+			return
+		case <-ticker.C:
+			if err := r.load(); err != nil {
+				log.Error().Err(err).Msg("static: error reloading the auth registry rules, keeping the previous ones")
+			}
+		}
+	}
+}
+
+// compilePatterns compiles the auth types of the rules as regular expressions
+// matching the whole auth type, sorted to have a deterministic match order.
+func compilePatterns(rules map[string]string) ([]*pattern, error) {
+	authTypes := make([]string, 0, len(rules))
+	for k := range rules {
+		authTypes = append(authTypes, k)
+	}
+	sort.Strings(authTypes)
+
+	patterns := make([]*pattern, 0, len(authTypes))
+	for _, t := range authTypes {
+		re, err := regexp.Compile("^" + t + "$")
+		if err != nil {
+			return nil, errors.Wrapf(err, "static: error compiling rule for auth type %s", t)
+		}
+		patterns = append(patterns, &pattern{re: re, address: rules[t]})
+	}
+	return patterns, nil
 }
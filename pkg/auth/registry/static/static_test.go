@@ -0,0 +1,175 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package static
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/stretchr/testify/assert"
+)
+
+var ctx = context.Background()
+
+func TestGetProvider(t *testing.T) {
+	tests := []struct {
+		name     string
+		conf     map[string]interface{}
+		authType string
+		address  string
+		notFound bool
+	}{
+		{
+			name:     "exact rule",
+			conf:     map[string]interface{}{"rules": map[string]string{"basic": "localhost:1"}},
+			authType: "basic",
+			address:  "localhost:1",
+		},
+		{
+			name:     "patterns disabled",
+			conf:     map[string]interface{}{"rules": map[string]string{"bearer-.*": "localhost:1"}},
+			authType: "bearer-partnerA",
+			notFound: true,
+		},
+		{
+			name: "pattern rule",
+			conf: map[string]interface{}{
+				"enable_regex_rules": true,
+				"rules":              map[string]string{"basic": "localhost:1", "bearer-.*": "localhost:2"},
+			},
+			authType: "bearer-partnerA",
+			address:  "localhost:2",
+		},
+		{
+			name: "patterns match the whole auth type",
+			conf: map[string]interface{}{
+				"enable_regex_rules": true,
+				"rules":              map[string]string{"bearer-.*": "localhost:2"},
+			},
+			authType: "oidc-bearer-partnerA",
+			notFound: true,
+		},
+		{
+			name: "exact rule takes precedence",
+			conf: map[string]interface{}{
+				"enable_regex_rules": true,
+				"rules":              map[string]string{"bearer-.*": "localhost:2", "bearer-partnerB": "localhost:3"},
+			},
+			authType: "bearer-partnerB",
+			address:  "localhost:3",
+		},
+		{
+			name: "patterns are matched in order",
+			conf: map[string]interface{}{
+				"enable_regex_rules": true,
+				"rules":              map[string]string{"bearer-.*": "localhost:2", "bearer-partner.*": "localhost:3"},
+			},
+			authType: "bearer-partnerC",
+			address:  "localhost:2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := New(tt.conf)
+			assert.NoError(t, err)
+
+			p, err := r.GetProvider(ctx, tt.authType)
+			if tt.notFound {
+				assert.IsType(t, errtypes.NotFound(""), err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.authType, p.ProviderType)
+			assert.Equal(t, tt.address, p.Address)
+		})
+	}
+}
+
+func TestInvalidPattern(t *testing.T) {
+	_, err := New(map[string]interface{}{
+		"enable_regex_rules": true,
+		"rules":              map[string]string{"bearer-(": "localhost:1"},
+	})
+	assert.Error(t, err)
+}
+
+func TestListProviders(t *testing.T) {
+	r, err := New(map[string]interface{}{
+		"rules": map[string]string{"basic": "localhost:1", "bearer": "localhost:2"},
+	})
+	assert.NoError(t, err)
+
+	providers, err := r.ListProviders(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, providers, 2)
+
+	got := map[string]string{}
+	for _, p := range providers {
+		if assert.NotNil(t, p) {
+			got[p.ProviderType] = p.Address
+		}
+	}
+	assert.Equal(t, map[string]string{"basic": "localhost:1", "bearer": "localhost:2"}, got)
+}
+
+func TestReloadRules(t *testing.T) {
+	rulesFile := filepath.Join(t.TempDir(), "rules.json")
+	assert.NoError(t, os.WriteFile(rulesFile, []byte(`{"basic": "localhost:1"}`), 0600))
+
+	r, err := New(map[string]interface{}{
+		"rules_file":         rulesFile,
+		"enable_regex_rules": true,
+	})
+	assert.NoError(t, err)
+
+	p, err := r.GetProvider(ctx, "basic")
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:1", p.Address)
+	_, err = r.GetProvider(ctx, "bearer-partnerA")
+	assert.IsType(t, errtypes.NotFound(""), err)
+
+	assert.NoError(t, os.WriteFile(rulesFile, []byte(`{"basic": "localhost:2", "bearer-.*": "localhost:3"}`), 0600))
+	assert.NoError(t, r.(*reg).load())
+
+	p, err = r.GetProvider(ctx, "basic")
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:2", p.Address)
+	p, err = r.GetProvider(ctx, "bearer-partnerA")
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:3", p.Address)
+
+	// invalid rules are not loaded, and the previous ones are kept
+	assert.NoError(t, os.WriteFile(rulesFile, []byte(`{"bearer-(": "localhost:4"}`), 0600))
+	assert.Error(t, r.(*reg).load())
+
+	p, err = r.GetProvider(ctx, "basic")
+	assert.NoError(t, err)
+	assert.Equal(t, "localhost:2", p.Address)
+}
+
+func TestMissingRulesFile(t *testing.T) {
+	_, err := New(map[string]interface{}{
+		"rules_file": filepath.Join(t.TempDir(), "missing.json"),
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,76 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package scope
+
+import (
+	"context"
+
+	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	collaboration "github.com/cs3org/go-cs3apis/cs3/sharing/collaboration/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	ocmv1beta1 "github.com/cs3org/go-cs3apis/cs3/sharing/ocm/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/tracing"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/rs/zerolog"
+)
+
+func impersonationScope(ctx context.Context, scope *authpb.Scope, resource interface{}, _ *zerolog.Logger) (bool, error) {
+	_, span := tracing.SpanStartFromContext(ctx, tracerName, "impersonationScope")
+	defer span.End()
+
+	switch resource.(type) {
+	// The impersonated users cannot share the resources.
+	case *collaboration.CreateShareRequest, *link.CreatePublicShareRequest, *ocmv1beta1.CreateOCMShareRequest,
+		*provider.AddGrantRequest, *provider.UpdateGrantRequest:
+		return false, nil
+
+	// Editor role
+	case *provider.CreateContainerRequest, *provider.TouchFileRequest, *provider.DeleteRequest,
+		*provider.MoveRequest, *provider.InitiateFileUploadRequest, *provider.SetArbitraryMetadataRequest,
+		*provider.UnsetArbitraryMetadataRequest, *provider.RestoreFileVersionRequest,
+		*provider.RestoreRecycleItemRequest, *provider.PurgeRecycleRequest, *provider.RemoveGrantRequest:
+		return hasRoleEditor(*scope), nil
+	}
+
+	// The other resources can be accessed as by the user.
+	return true, nil
+}
+
+// AddImpersonationScope adds the scope of a user impersonated by a trusted service,
+// with access to all the resources of the user in the given role, but the sharing.
+func AddImpersonationScope(role authpb.Role, scopes map[string]*authpb.Scope) (map[string]*authpb.Scope, error) {
+	ref := &provider.Reference{Path: "/"}
+	val, err := utils.MarshalProtoV1ToJSON(ref)
+	if err != nil {
+		return nil, err
+	}
+	if scopes == nil {
+		scopes = make(map[string]*authpb.Scope)
+	}
+	scopes["impersonation"] = &authpb.Scope{
+		Resource: &types.OpaqueEntry{
+			Decoder: "json",
+			Value:   val,
+		},
+		Role: role,
+	}
+	return scopes, nil
+}
@@ -21,6 +21,7 @@ package scope
 import (
 	"context"
 	"fmt"
+	"path"
 	"strings"
 
 	appprovider "github.com/cs3org/go-cs3apis/cs3/app/provider/v1beta1"
@@ -48,6 +49,10 @@ func publicshareScope(ctx context.Context, scope *authpb.Scope, resource interfa
 		return false, err
 	}
 
+	if !publicSharePermits(&share, resource) {
+		return false, nil
+	}
+
 	switch v := resource.(type) {
 	// Viewer role
 	case *registry.GetStorageProvidersRequest:
@@ -126,6 +131,26 @@ func checkPublicShareRef(s *link.PublicShare, ref *link.PublicShareReference) bo
 func AddPublicShareScope(share *link.PublicShare, role authpb.Role, scopes map[string]*authpb.Scope) (map[string]*authpb.Scope, error) {
 	// Create a new "scope share" to only expose the required fields `ResourceId` and `Token` to the scope.
 	scopeShare := &link.PublicShare{ResourceId: share.ResourceId, Token: share.Token}
+	return addPublicShareScope(share.Id, scopeShare, role, scopes)
+}
+
+// AddPublicSharePermissionsScope adds the scope to allow access to a public share
+// and the subtree of the shared resource, restricted to the permissions of the share:
+// the requests needing other permissions are denied even if allowed by the role.
+func AddPublicSharePermissionsScope(share *link.PublicShare, role authpb.Role, scopes map[string]*authpb.Scope) (map[string]*authpb.Scope, error) {
+	perms := share.GetPermissions().GetPermissions()
+	if perms == nil {
+		perms = &provider.ResourcePermissions{}
+	}
+	scopeShare := &link.PublicShare{
+		ResourceId:  share.ResourceId,
+		Token:       share.Token,
+		Permissions: &link.PublicSharePermissions{Permissions: perms},
+	}
+	return addPublicShareScope(share.Id, scopeShare, role, scopes)
+}
+
+func addPublicShareScope(id *link.PublicShareId, scopeShare *link.PublicShare, role authpb.Role, scopes map[string]*authpb.Scope) (map[string]*authpb.Scope, error) {
 	val, err := utils.MarshalProtoV1ToJSON(scopeShare)
 	if err != nil {
 		return nil, err
@@ -133,7 +158,7 @@ func AddPublicShareScope(share *link.PublicShare, role authpb.Role, scopes map[s
 	if scopes == nil {
 		scopes = make(map[string]*authpb.Scope)
 	}
-	scopes["publicshare:"+share.Id.OpaqueId] = &authpb.Scope{
+	scopes["publicshare:"+id.GetOpaqueId()] = &authpb.Scope{
 		Resource: &types.OpaqueEntry{
 			Decoder: "json",
 			Value:   val,
@@ -143,6 +168,65 @@ func AddPublicShareScope(share *link.PublicShare, role authpb.Role, scopes map[s
 	return scopes, nil
 }
 
+// VerifyPublicSharePermissions checks that the permissions of the public share
+// in the scope grant the ones needed by the request, returning a PermissionDenied
+// error otherwise. The scopes without permissions are only restricted by their role.
+func VerifyPublicSharePermissions(scope *authpb.Scope, req interface{}) error {
+	var share link.PublicShare
+	if err := utils.UnmarshalJSONToProtoV1(scope.Resource.Value, &share); err != nil {
+		return err
+	}
+	if !publicSharePermits(&share, req) {
+		return errtypes.PermissionDenied("request not allowed by the permissions of the public share")
+	}
+	return nil
+}
+
+// publicSharePermits returns whether the permissions of the share grant the ones needed by the request.
+func publicSharePermits(share *link.PublicShare, req interface{}) bool {
+	if share.Permissions == nil {
+		return true
+	}
+	needed, ok := requiredPermissions(req)
+	if !ok {
+		return true
+	}
+	granted := share.Permissions.GetPermissions()
+	if granted == nil {
+		granted = &provider.ResourcePermissions{}
+	}
+	return utils.HasPermissions(granted, needed)
+}
+
+// requiredPermissions returns the permissions needed by the requests on the storage.
+func requiredPermissions(req interface{}) (*provider.ResourcePermissions, bool) {
+	switch req.(type) {
+	case *provider.StatRequest, *provider.GetLockRequest:
+		return &provider.ResourcePermissions{Stat: true}, true
+	case *provider.ListContainerRequest:
+		return &provider.ResourcePermissions{ListContainer: true}, true
+	case *provider.InitiateFileDownloadRequest, *appprovider.OpenInAppRequest, *gateway.OpenInAppRequest:
+		return &provider.ResourcePermissions{InitiateFileDownload: true}, true
+	case *provider.CreateContainerRequest:
+		return &provider.ResourcePermissions{CreateContainer: true}, true
+	case *provider.TouchFileRequest, *provider.InitiateFileUploadRequest,
+		*provider.SetArbitraryMetadataRequest, *provider.UnsetArbitraryMetadataRequest,
+		*provider.SetLockRequest, *provider.RefreshLockRequest, *provider.UnlockRequest:
+		return &provider.ResourcePermissions{InitiateFileUpload: true}, true
+	case *provider.DeleteRequest:
+		return &provider.ResourcePermissions{Delete: true}, true
+	case *provider.MoveRequest:
+		return &provider.ResourcePermissions{Move: true}, true
+	}
+	return nil, false
+}
+
+// IsInSubtree returns whether the path is the root of the subtree or one of its descendants.
+func IsInSubtree(root, p string) bool {
+	root, p = path.Clean(root), path.Clean(p)
+	return p == root || strings.HasPrefix(p, strings.TrimSuffix(root, "/")+"/")
+}
+
 // GetPublicSharesFromScopes returns all public shares in the given scope.
 func GetPublicSharesFromScopes(scopes map[string]*authpb.Scope) ([]*link.PublicShare, error) {
 	var shares []*link.PublicShare
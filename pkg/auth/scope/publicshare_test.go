@@ -0,0 +1,154 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package scope
+
+import (
+	"context"
+	"testing"
+
+	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestPublicShare(perms *provider.ResourcePermissions) *link.PublicShare {
+	return &link.PublicShare{
+		Id:          &link.PublicShareId{OpaqueId: "1"},
+		Token:       "token",
+		ResourceId:  &provider.ResourceId{StorageId: "storage", OpaqueId: "shared"},
+		Permissions: &link.PublicSharePermissions{Permissions: perms},
+	}
+}
+
+func TestPublicSharePermissionsScopeSubtree(t *testing.T) {
+	share := newTestPublicShare(&provider.ResourcePermissions{Stat: true, ListContainer: true, InitiateFileDownload: true})
+	scopes, err := AddPublicSharePermissionsScope(share, authpb.Role_ROLE_VIEWER, nil)
+	if err != nil {
+		t.Fatalf("not expected error adding the scope: %+v", err)
+	}
+	s := scopes["publicshare:1"]
+	if assert.NotNil(t, s) {
+		shares, err := GetPublicSharesFromScopes(scopes)
+		assert.NoError(t, err)
+		if assert.Len(t, shares, 1) {
+			assert.Equal(t, "token", shares[0].Token)
+			assert.True(t, shares[0].Permissions.Permissions.InitiateFileDownload)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		req     interface{}
+		allowed bool
+	}{
+		{name: "shared resource", req: &provider.StatRequest{Ref: &provider.Reference{ResourceId: share.ResourceId}}, allowed: true},
+		{name: "public path", req: &provider.ListContainerRequest{Ref: &provider.Reference{Path: "/public/token/folder"}}, allowed: true},
+		{name: "resource in the public storage", req: &provider.InitiateFileDownloadRequest{Ref: &provider.Reference{
+			ResourceId: &provider.ResourceId{StorageId: "public", OpaqueId: "token/folder/file"},
+		}}, allowed: true},
+		{name: "other resource", req: &provider.StatRequest{Ref: &provider.Reference{ResourceId: &provider.ResourceId{StorageId: "storage", OpaqueId: "other"}}}},
+		{name: "other public share", req: &provider.StatRequest{Ref: &provider.Reference{Path: "/public/other"}}},
+		{name: "storage path", req: &provider.StatRequest{Ref: &provider.Reference{Path: "/home/einstein"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := VerifyScope(context.Background(), scopes, tt.req)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.allowed, ok)
+		})
+	}
+}
+
+func TestPublicSharePermissionsScopeDowngrade(t *testing.T) {
+	// an editor link whose permissions were downgraded to upload only
+	share := newTestPublicShare(&provider.ResourcePermissions{Stat: true, ListContainer: true, CreateContainer: true, InitiateFileUpload: true})
+	ref := &provider.Reference{ResourceId: share.ResourceId}
+
+	tests := []struct {
+		name    string
+		req     interface{}
+		allowed bool
+	}{
+		{name: "stat", req: &provider.StatRequest{Ref: ref}, allowed: true},
+		{name: "upload", req: &provider.InitiateFileUploadRequest{Ref: ref}, allowed: true},
+		{name: "mkcol", req: &provider.CreateContainerRequest{Ref: ref}, allowed: true},
+		{name: "download", req: &provider.InitiateFileDownloadRequest{Ref: ref}},
+		{name: "delete", req: &provider.DeleteRequest{Ref: ref}},
+		{name: "move", req: &provider.MoveRequest{Source: ref, Destination: ref}},
+	}
+
+	scopes, err := AddPublicSharePermissionsScope(share, authpb.Role_ROLE_EDITOR, nil)
+	if err != nil {
+		t.Fatalf("not expected error adding the scope: %+v", err)
+	}
+	legacy, err := AddPublicShareScope(share, authpb.Role_ROLE_EDITOR, nil)
+	if err != nil {
+		t.Fatalf("not expected error adding the scope: %+v", err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := VerifyScope(context.Background(), scopes, tt.req)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.allowed, ok)
+
+			err = VerifyPublicSharePermissions(scopes["publicshare:1"], tt.req)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.IsType(t, errtypes.PermissionDenied(""), err)
+			}
+
+			// the scopes without permissions are only restricted by the role
+			ok, err = VerifyScope(context.Background(), legacy, tt.req)
+			assert.NoError(t, err)
+			assert.True(t, ok)
+			assert.NoError(t, VerifyPublicSharePermissions(legacy["publicshare:1"], tt.req))
+		})
+	}
+
+	// a share without permissions grants nothing
+	share.Permissions = nil
+	scopes, err = AddPublicSharePermissionsScope(share, authpb.Role_ROLE_VIEWER, nil)
+	if err != nil {
+		t.Fatalf("not expected error adding the scope: %+v", err)
+	}
+	ok, err := VerifyScope(context.Background(), scopes, &provider.StatRequest{Ref: ref})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestIsInSubtree(t *testing.T) {
+	tests := []struct {
+		root, path string
+		expected   bool
+	}{
+		{"/eos/user/e/einstein/shared", "/eos/user/e/einstein/shared", true},
+		{"/eos/user/e/einstein/shared", "/eos/user/e/einstein/shared/file.txt", true},
+		{"/eos/user/e/einstein/shared/", "/eos/user/e/einstein/shared/folder/file.txt", true},
+		{"/eos/user/e/einstein/shared", "/eos/user/e/einstein/shared-other/file.txt", false},
+		{"/eos/user/e/einstein/shared", "/eos/user/e/einstein/shared/../private", false},
+		{"/eos/user/e/einstein/shared", "/eos/user/e/einstein", false},
+		{"/", "/eos", true},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, IsInSubtree(tt.root, tt.path), "root=%s path=%s", tt.root, tt.path)
+	}
+}
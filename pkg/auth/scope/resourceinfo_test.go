@@ -0,0 +1,44 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package scope
+
+import (
+	"testing"
+
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckResourceInfo(t *testing.T) {
+	id := &provider.ResourceId{StorageId: "storage", OpaqueId: "folder"}
+	inf := &provider.ResourceInfo{Id: id, Path: ""}
+	tests := []struct {
+		name    string
+		ref     *provider.Reference
+		allowed bool
+	}{
+		{name: "id", ref: &provider.Reference{ResourceId: id}, allowed: true},
+		{name: "relative path", ref: &provider.Reference{ResourceId: id, Path: "./sub"}, allowed: true},
+		{name: "other id", ref: &provider.Reference{ResourceId: &provider.ResourceId{StorageId: "storage", OpaqueId: "other"}}, allowed: false},
+		{name: "other id with relative path", ref: &provider.Reference{ResourceId: &provider.ResourceId{StorageId: "storage", OpaqueId: "other"}, Path: "./sub"}, allowed: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.allowed, checkResourceInfo(inf, tt.ref), tt.name)
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package scope
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	registry "github.com/cs3org/go-cs3apis/cs3/storage/registry/v1beta1"
+	types "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/tracing"
+	"github.com/cs3org/reva/pkg/utils"
+	"github.com/rs/zerolog"
+)
+
+// resourcepathScope grants access to the subtree of a path, for the tokens
+// that cannot be bound to the id of the resource when they are minted.
+// As the id of the resource is unknown, the references by id are denied.
+func resourcepathScope(ctx context.Context, scope *authpb.Scope, resource interface{}, logger *zerolog.Logger) (bool, error) {
+	_, span := tracing.SpanStartFromContext(ctx, tracerName, "resourcepathScope")
+	defer span.End()
+
+	var root provider.Reference
+	err := utils.UnmarshalJSONToProtoV1(scope.Resource.Value, &root)
+	if err != nil {
+		return false, err
+	}
+
+	switch v := resource.(type) {
+	// Viewer role
+	case *registry.GetStorageProvidersRequest:
+		return checkResourcePathRef(root.Path, v.GetRef()), nil
+	case *provider.StatRequest:
+		return checkResourcePathRef(root.Path, v.GetRef()), nil
+	case *provider.ListContainerRequest:
+		return checkResourcePathRef(root.Path, v.GetRef()), nil
+	case *provider.InitiateFileDownloadRequest:
+		return checkResourcePathRef(root.Path, v.GetRef()), nil
+	case *gateway.OpenInAppRequest:
+		return checkResourcePathRef(root.Path, v.GetRef()), nil
+
+	// Editor role
+	case *provider.CreateContainerRequest:
+		return hasRoleEditor(*scope) && checkResourcePathRef(root.Path, v.GetRef()), nil
+	case *provider.TouchFileRequest:
+		return hasRoleEditor(*scope) && checkResourcePathRef(root.Path, v.GetRef()), nil
+	case *provider.DeleteRequest:
+		return hasRoleEditor(*scope) && checkResourcePathRef(root.Path, v.GetRef()), nil
+	case *provider.MoveRequest:
+		return hasRoleEditor(*scope) && checkResourcePathRef(root.Path, v.GetSource()) && checkResourcePathRef(root.Path, v.GetDestination()), nil
+	case *provider.InitiateFileUploadRequest:
+		return hasRoleEditor(*scope) && checkResourcePathRef(root.Path, v.GetRef()), nil
+	case *provider.SetArbitraryMetadataRequest:
+		return hasRoleEditor(*scope) && checkResourcePathRef(root.Path, v.GetRef()), nil
+	case *provider.UnsetArbitraryMetadataRequest:
+		return hasRoleEditor(*scope) && checkResourcePathRef(root.Path, v.GetRef()), nil
+
+	case string:
+		return checkResourcePath(v), nil
+	}
+
+	msg := fmt.Sprintf("resource type assertion failed: %+v", resource)
+	logger.Debug().Str("scope", "resourcepathScope").Msg(msg)
+	return false, errtypes.InternalError(msg)
+}
+
+// checkResourcePathRef checks that the reference is an absolute path in the subtree of root.
+func checkResourcePathRef(root string, ref *provider.Reference) bool {
+	if ref.GetResourceId() != nil || !strings.HasPrefix(root, "/") || !strings.HasPrefix(ref.GetPath(), "/") {
+		return false
+	}
+	return IsInSubtree(root, ref.GetPath())
+}
+
+// AddResourcePathScope adds the scope to allow access to the subtree of the given absolute path.
+func AddResourcePathScope(path string, role authpb.Role, scopes map[string]*authpb.Scope) (map[string]*authpb.Scope, error) {
+	val, err := utils.MarshalProtoV1ToJSON(&provider.Reference{Path: path})
+	if err != nil {
+		return nil, err
+	}
+	if scopes == nil {
+		scopes = make(map[string]*authpb.Scope)
+	}
+	scopes["resourcepath:"+path] = &authpb.Scope{
+		Resource: &types.OpaqueEntry{
+			Decoder: "json",
+			Value:   val,
+		},
+		Role: role,
+	}
+	return scopes, nil
+}
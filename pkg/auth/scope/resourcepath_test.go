@@ -0,0 +1,101 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package scope
+
+import (
+	"context"
+	"testing"
+
+	authpb "github.com/cs3org/go-cs3apis/cs3/auth/provider/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResourcePathScope(t *testing.T) {
+	scopes, err := AddResourcePathScope("/eos/a", authpb.Role_ROLE_VIEWER, nil)
+	if err != nil {
+		t.Fatalf("not expected error adding the scope: %+v", err)
+	}
+	s, ok := scopes["resourcepath:/eos/a"]
+	if assert.True(t, ok) {
+		f, err := FormatScope("resourcepath:/eos/a", s)
+		assert.NoError(t, err)
+		assert.Equal(t, `path:"/eos/a" ROLE_VIEWER`, f)
+	}
+
+	tests := []struct {
+		name    string
+		req     interface{}
+		allowed bool
+	}{
+		{name: "root", req: &provider.StatRequest{Ref: &provider.Reference{Path: "/eos/a"}}, allowed: true},
+		{name: "root with trailing slash", req: &provider.ListContainerRequest{Ref: &provider.Reference{Path: "/eos/a/"}}, allowed: true},
+		{name: "subtree", req: &provider.InitiateFileDownloadRequest{Ref: &provider.Reference{Path: "/eos/a/b/c"}}, allowed: true},
+		{name: "sibling with the same prefix", req: &provider.StatRequest{Ref: &provider.Reference{Path: "/eos/ab"}}},
+		{name: "parent", req: &provider.StatRequest{Ref: &provider.Reference{Path: "/eos"}}},
+		{name: "escaping the subtree", req: &provider.StatRequest{Ref: &provider.Reference{Path: "/eos/a/../b"}}},
+		{name: "empty path", req: &provider.StatRequest{Ref: &provider.Reference{}}},
+		{name: "relative path", req: &provider.StatRequest{Ref: &provider.Reference{Path: "eos/a"}}},
+		{name: "id", req: &provider.StatRequest{Ref: &provider.Reference{ResourceId: &provider.ResourceId{StorageId: "storage", OpaqueId: "a"}}}},
+		{name: "id with relative path", req: &provider.StatRequest{Ref: &provider.Reference{
+			ResourceId: &provider.ResourceId{StorageId: "storage", OpaqueId: "a"},
+			Path:       "./b",
+		}}},
+		{name: "write as viewer", req: &provider.DeleteRequest{Ref: &provider.Reference{Path: "/eos/a/b"}}},
+		{name: "data endpoint", req: "/data/token", allowed: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := VerifyScope(context.Background(), scopes, tt.req)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.allowed, ok)
+		})
+	}
+}
+
+func TestResourcePathScopeEditor(t *testing.T) {
+	scopes, err := AddResourcePathScope("/eos/a", authpb.Role_ROLE_EDITOR, nil)
+	if err != nil {
+		t.Fatalf("not expected error adding the scope: %+v", err)
+	}
+
+	tests := []struct {
+		name    string
+		req     interface{}
+		allowed bool
+	}{
+		{name: "upload", req: &provider.InitiateFileUploadRequest{Ref: &provider.Reference{Path: "/eos/a/file"}}, allowed: true},
+		{name: "move in the subtree", req: &provider.MoveRequest{
+			Source:      &provider.Reference{Path: "/eos/a/b"},
+			Destination: &provider.Reference{Path: "/eos/a/c"},
+		}, allowed: true},
+		{name: "move out of the subtree", req: &provider.MoveRequest{
+			Source:      &provider.Reference{Path: "/eos/a/b"},
+			Destination: &provider.Reference{Path: "/eos/b"},
+		}},
+		{name: "delete outside of the subtree", req: &provider.DeleteRequest{Ref: &provider.Reference{Path: "/eos/b"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := VerifyScope(context.Background(), scopes, tt.req)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.allowed, ok)
+		})
+	}
+}
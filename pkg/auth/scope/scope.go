@@ -37,10 +37,12 @@ var supportedScopes = map[string]Verifier{
 	"user":          userScope,
 	"publicshare":   publicshareScope,
 	"resourceinfo":  resourceinfoScope,
+	"resourcepath":  resourcepathScope,
 	"share":         shareScope,
 	"receivedshare": receivedShareScope,
 	"lightweight":   lightweightAccountScope,
 	"ocmshare":      ocmShareScope,
+	"impersonation": impersonationScope,
 }
 
 // VerifyScope is the function to be called when dismantling tokens to check if
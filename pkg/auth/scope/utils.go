@@ -33,7 +33,7 @@ import (
 func FormatScope(scopeType string, scope *authpb.Scope) (string, error) {
 	// TODO(gmgigi96): check decoder type
 	switch {
-	case strings.HasPrefix(scopeType, "user"):
+	case strings.HasPrefix(scopeType, "user"), strings.HasPrefix(scopeType, "impersonation"):
 		// user scope
 		var ref provider.Reference
 		err := utils.UnmarshalJSONToProtoV1(scope.Resource.Value, &ref)
@@ -56,6 +56,13 @@ func FormatScope(scopeType string, scope *authpb.Scope) (string, error) {
 			return "", err
 		}
 		return fmt.Sprintf("path:\"%s\" %s", resInfo.Path, scope.Role.String()), nil
+	case strings.HasPrefix(scopeType, "resourcepath"):
+		var ref provider.Reference
+		err := utils.UnmarshalJSONToProtoV1(scope.Resource.Value, &ref)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("path:\"%s\" %s", ref.Path, scope.Role.String()), nil
 	default:
 		return "", errtypes.NotSupported("scope not yet supported")
 	}
@@ -0,0 +1,154 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cs3org/reva/pkg/errtypes"
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+)
+
+// The operations on the shares, as tagged in the metrics.
+const (
+	opCreate     = "create"
+	opUpdate     = "update"
+	opGet        = "get"
+	opGetByToken = "get_by_token"
+	opList       = "list"
+	opCount      = "count"
+	opRevoke     = "revoke"
+	opRestore    = "restore"
+)
+
+// The queries on the database, as tagged in the metrics.
+const (
+	queryInsert       = "insert"
+	queryUpdate       = "update"
+	queryGetByToken   = "get_by_token"
+	queryGetByID      = "get_by_id"
+	queryList         = "list"
+	queryCount        = "count"
+	queryRevoke       = "revoke"
+	queryRestore      = "restore"
+	queryLastAccessed = "last_accessed"
+)
+
+var (
+	operations      = stats.Int64("publicshare_sql_operations_total", "The number of operations on the public shares", stats.UnitDimensionless)
+	queryLatency    = stats.Float64("publicshare_sql_query_latency", "The latency of the queries on the public shares database", stats.UnitMilliseconds)
+	janitorRuns     = stats.Int64("publicshare_sql_janitor_runs_total", "The number of runs of the public shares janitor", stats.UnitDimensionless)
+	orphanedShares  = stats.Int64("publicshare_sql_janitor_orphaned_shares", "The number of expired public shares orphaned by the last run of the janitor", stats.UnitDimensionless)
+	deletedShares   = stats.Int64("publicshare_sql_janitor_deleted_shares", "The number of orphaned public shares deleted by the last run of the janitor", stats.UnitDimensionless)
+	operationKey    = tag.MustNewKey("operation")
+	outcomeKey      = tag.MustNewKey("outcome")
+	queryKey        = tag.MustNewKey("query")
+	registerOnce    sync.Once
+	errRegistration error
+)
+
+// registerViews registers the views of the metrics, which are shared
+// by all the managers.
+func registerViews() error {
+	registerOnce.Do(func() {
+		errRegistration = view.Register(
+			&view.View{
+				Name:        operations.Name(),
+				Description: operations.Description(),
+				Measure:     operations,
+				TagKeys:     []tag.Key{operationKey, outcomeKey},
+				Aggregation: view.Count(),
+			},
+			&view.View{
+				Name:        queryLatency.Name(),
+				Description: queryLatency.Description(),
+				Measure:     queryLatency,
+				TagKeys:     []tag.Key{queryKey},
+				Aggregation: view.Distribution(1, 5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000),
+			},
+			&view.View{
+				Name:        janitorRuns.Name(),
+				Description: janitorRuns.Description(),
+				Measure:     janitorRuns,
+				TagKeys:     []tag.Key{outcomeKey},
+				Aggregation: view.Count(),
+			},
+			&view.View{
+				Name:        orphanedShares.Name(),
+				Description: orphanedShares.Description(),
+				Measure:     orphanedShares,
+				Aggregation: view.LastValue(),
+			},
+			&view.View{
+				Name:        deletedShares.Name(),
+				Description: deletedShares.Description(),
+				Measure:     deletedShares,
+				Aggregation: view.LastValue(),
+			},
+		)
+	})
+	return errRegistration
+}
+
+// outcome returns the outcome of an operation failed with err, as tagged in the metrics.
+func outcome(err error) string {
+	switch err.(type) {
+	case nil:
+		return "ok"
+	case errtypes.NotFound:
+		return "not_found"
+	case errtypes.PermissionDenied:
+		return "permission_denied"
+	case errtypes.InvalidCredentials:
+		return "invalid_credentials"
+	case errtypes.BadRequest:
+		return "bad_request"
+	case errtypes.AlreadyExists:
+		return "already_exists"
+	default:
+		return "error"
+	}
+}
+
+// recordOperation counts the operation on the shares by outcome.
+func recordOperation(op string, err error) {
+	_ = stats.RecordWithTags(context.Background(),
+		[]tag.Mutator{tag.Upsert(operationKey, op), tag.Upsert(outcomeKey, outcome(err))},
+		operations.M(1))
+}
+
+// recordQueryLatency records the latency of the query on the database.
+func recordQueryLatency(query string, d time.Duration) {
+	_ = stats.RecordWithTags(context.Background(),
+		[]tag.Mutator{tag.Upsert(queryKey, query)},
+		queryLatency.M(float64(d)/float64(time.Millisecond)))
+}
+
+// recordJanitorRun records the outcome of a run of the janitor,
+// and the number of shares it orphaned and deleted.
+func recordJanitorRun(orphaned, deleted int64, err error) {
+	_ = stats.RecordWithTags(context.Background(),
+		[]tag.Mutator{tag.Upsert(outcomeKey, outcome(err))},
+		janitorRuns.M(1))
+	stats.Record(context.Background(), orphanedShares.M(orphaned), deletedShares.M(deleted))
+}
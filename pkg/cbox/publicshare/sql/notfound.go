@@ -0,0 +1,124 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bluele/gcache"
+	"github.com/rs/zerolog/log"
+)
+
+// notFoundCache remembers the tokens recently not found in the database,
+// so that the lookups of the tokens guessed by the scanners are rejected
+// without querying it again. The cache is local to the manager: the shares
+// created through other instances are found once the entries expire.
+type notFoundCache struct {
+	cache gcache.Cache
+	// with lowercase tokens, a share can be found through any case of its token
+	lowercase bool
+	// evictions counts the evictions, so that a token looked up while
+	// a share is being created is not remembered as not found
+	evictions atomic.Uint64
+}
+
+func newNotFoundCache(size int, ttl time.Duration, lowercase bool) *notFoundCache {
+	return &notFoundCache{
+		cache:     gcache.New(size).LRU().Expiration(ttl).Build(),
+		lowercase: lowercase,
+	}
+}
+
+// has reports whether the token was recently not found.
+func (c *notFoundCache) has(token string) bool {
+	if c == nil {
+		return false
+	}
+	_, err := c.cache.Get(token)
+	return err == nil
+}
+
+// generation returns the number of the evictions, to be taken before looking up a token.
+func (c *notFoundCache) generation() uint64 {
+	if c == nil {
+		return 0
+	}
+	return c.evictions.Load()
+}
+
+// add remembers that the token was not found, unless a share has been
+// created since the given generation, as it might have that token.
+func (c *notFoundCache) add(token string, generation uint64) {
+	if c == nil || c.evictions.Load() != generation {
+		return
+	}
+	_ = c.cache.Set(token, struct{}{})
+}
+
+// evict forgets the token, as a share with it has been created.
+func (c *notFoundCache) evict(token string) {
+	if c == nil {
+		return
+	}
+	c.evictions.Add(1)
+	c.cache.Remove(token)
+	if c.lowercase {
+		for _, k := range c.cache.Keys(false) {
+			if s, ok := k.(string); ok && strings.EqualFold(s, token) {
+				c.cache.Remove(k)
+			}
+		}
+	}
+}
+
+// notFoundLookups counts the lookups of tokens not found, to detect the scanners.
+type notFoundLookups struct {
+	total  atomic.Int64
+	cached atomic.Int64
+}
+
+func (l *notFoundLookups) record(cached bool) {
+	l.total.Add(1)
+	if cached {
+		l.cached.Add(1)
+	}
+}
+
+// logNotFoundLookups periodically logs the number of the lookups of tokens
+// not found in the last interval, if any.
+func (m *manager) logNotFoundLookups(ctx context.Context) {
+	interval := time.Duration(m.c.NotFoundLogInterval) * time.Second
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			total, cached := m.notFound.total.Swap(0), m.notFound.cached.Swap(0)
+			if total > 0 {
+				log.Info().Int64("count", total).Int64("cached", cached).Dur("interval", interval).
+					Msg("sql: lookups of public share tokens not found")
+			}
+		}
+	}
+}
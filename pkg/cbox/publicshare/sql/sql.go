@@ -22,35 +22,73 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
-	"os"
-	"os/signal"
+	"math/rand"
+	"net"
+	"net/url"
 	"strconv"
 	"strings"
-	"syscall"
+	"sync"
 	"time"
 
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
 	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
 	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
 	conversions "github.com/cs3org/reva/pkg/cbox/utils"
 	"github.com/cs3org/reva/pkg/errtypes"
 	"github.com/cs3org/reva/pkg/publicshare"
+	"github.com/cs3org/reva/pkg/publicshare/events"
 	"github.com/cs3org/reva/pkg/publicshare/manager/registry"
 	"github.com/cs3org/reva/pkg/rgrpc/todo/pool"
 	"github.com/cs3org/reva/pkg/sharedconf"
 	"github.com/cs3org/reva/pkg/tracing"
-	"github.com/cs3org/reva/pkg/utils"
+	"github.com/cs3org/reva/pkg/worker"
+	"github.com/go-sql-driver/mysql"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.step.sm/crypto/randutil"
 	"golang.org/x/crypto/bcrypt"
 )
 
 const tracerName = "sql"
 
+// The attributes set on the spans of the operations on the shares.
 const (
-	publicShareType = 3
+	attrShareType    = attribute.Key("share.type")
+	attrUpdateType   = attribute.Key("share.update_type")
+	attrFilterCount  = attribute.Key("db.filter_count")
+	attrRowsReturned = attribute.Key("db.rows_returned")
+	attrRowsAffected = attribute.Key("db.rows_affected")
+	attrShareID      = attribute.Key("share.id")
+	attrTokenHash    = attribute.Key("share.token_hash")
+	attrResourceID   = attribute.Key("share.resource_id")
+)
+
+const (
+	// the default type of the public shares in the oc_share table
+	defaultPublicShareType = 3
+
+	// maximum number of queued updates of the last access time of the shares
+	accessesQueueSize = 1000
+
+	// how long closing the manager waits for a running janitor to complete
+	closeTimeout = 30 * time.Second
+
+	// the MySQL error number of a duplicate key
+	errDuplicateEntry = 1062
+
+	alphanumericAlphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	// the human-friendly tokens leave out the characters easily mistaken for others,
+	// and are grouped with dashes
+	humanFriendlyAlphabet  = "23456789abcdefghijkmnpqrstuvwxyz"
+	humanFriendlyGroupSize = 5
 
 	projectInstancesPrefix        = "newproject"
 	projectSpaceGroupsPrefix      = "cernbox-project-"
@@ -65,17 +103,77 @@ type config struct {
 	SharePasswordHashCost      int    `mapstructure:"password_hash_cost"`
 	JanitorRunInterval         int    `mapstructure:"janitor_run_interval"`
 	EnableExpiredSharesCleanup bool   `mapstructure:"enable_expired_shares_cleanup"`
+	OrphanRetentionDays        int    `mapstructure:"orphan_retention_days"` // counted from the expiration of the shares, not from when they have been orphaned
+	JanitorBatchSize           int    `mapstructure:"janitor_batch_size"`
 	DBUsername                 string `mapstructure:"db_username"`
 	DBPassword                 string `mapstructure:"db_password"`
 	DBHost                     string `mapstructure:"db_host"`
 	DBPort                     int    `mapstructure:"db_port"`
 	DBName                     string `mapstructure:"db_name"`
+	DBTLS                      string `mapstructure:"db_tls"`
+	DBParams                   string `mapstructure:"db_params"`
 	GatewaySvc                 string `mapstructure:"gatewaysvc"`
+	ListOrderBy                string `mapstructure:"list_order_by"`
+	ListOrderDescending        bool   `mapstructure:"list_order_descending"`
+	ListIncludeInternal        bool   `mapstructure:"list_include_internal"`
+	SlowQueryThreshold         int    `mapstructure:"slow_query_threshold"`
+	MaxSharesPerUser           int    `mapstructure:"max_shares_per_user"`
+	MaxSharesPerResource       int    `mapstructure:"max_shares_per_resource"`
+	TrackLastAccessed          bool   `mapstructure:"track_last_accessed"`
+	TokenLength                int    `mapstructure:"token_length"`
+	TokenAlphabet              string `mapstructure:"token_alphabet"`
+	HumanFriendlyTokens        bool   `mapstructure:"human_friendly_tokens"`
+	TokenRetries               int    `mapstructure:"token_retries"`
+	LowercaseTokens            bool   `mapstructure:"lowercase_tokens"`
+	HealthCheckQuery           bool   `mapstructure:"health_check_query"`
+	HealthCheckTimeout         int    `mapstructure:"health_check_timeout"`
+	PublicShareType            int    `mapstructure:"public_share_type"`
+	// EnableNotFoundCache remembers the tokens not found for not_found_cache_ttl seconds,
+	// rejecting their lookups without querying the database.
+	EnableNotFoundCache bool `mapstructure:"enable_not_found_cache"`
+	NotFoundCacheSize   int  `mapstructure:"not_found_cache_size"`
+	NotFoundCacheTTL    int  `mapstructure:"not_found_cache_ttl"`
+	// NotFoundLogInterval is how often, in seconds, the number of the lookups
+	// of tokens not found is logged; 0 disables it.
+	NotFoundLogInterval int `mapstructure:"not_found_log_interval"`
+	// EnforcePassword denies the updates removing the password of the shares,
+	// as when the password is enforced in the capabilities of the clients.
+	EnforcePassword bool `mapstructure:"enforce_password"`
+	// Events configures where the creations, updates and revocations of the shares are notified.
+	Events events.Config `mapstructure:"events"`
+	// SigningKeys are the server secrets mixed in the signatures of the shares.
+	SigningKeys publicshare.SigningKeys `mapstructure:",squash"`
 }
 
 type manager struct {
 	c  *config
 	db *sql.DB
+
+	// limitsMu serializes the creation of the shares of this instance when the
+	// limits are enabled. Across instances, the creations are only serialized
+	// by the locks taken by the count queries, as far as the database does so
+	limitsMu sync.Mutex
+
+	// accesses queues the updates of the last access time of the shares
+	accesses chan access
+
+	// newToken generates the tokens of the shares
+	newToken func() (string, error)
+
+	// events notifies the changes of the shares
+	events *events.Emitter
+
+	// notFoundCache remembers the tokens recently not found, if enabled
+	notFoundCache *notFoundCache
+	notFound      notFoundLookups
+
+	// workers runs the janitor and the updates of the last access times
+	workers *worker.Group
+}
+
+type access struct {
+	id string
+	at time.Time
 }
 
 func (c *config) init() {
@@ -85,29 +183,122 @@ func (c *config) init() {
 	if c.JanitorRunInterval == 0 {
 		c.JanitorRunInterval = 3600
 	}
+	if c.JanitorBatchSize == 0 {
+		c.JanitorBatchSize = 1000
+	}
+	if c.SlowQueryThreshold == 0 {
+		c.SlowQueryThreshold = 500
+	}
+	if c.TokenLength == 0 {
+		c.TokenLength = 15
+	}
+	if c.TokenAlphabet == "" {
+		c.TokenAlphabet = alphanumericAlphabet
+		if c.HumanFriendlyTokens {
+			c.TokenAlphabet = humanFriendlyAlphabet
+		}
+	}
+	if c.TokenRetries == 0 {
+		c.TokenRetries = 5
+	}
+	if c.LowercaseTokens {
+		c.TokenAlphabet = lowercaseAlphabet(c.TokenAlphabet)
+	}
+	if c.HealthCheckTimeout == 0 {
+		c.HealthCheckTimeout = 5
+	}
+	if c.PublicShareType == 0 {
+		c.PublicShareType = defaultPublicShareType
+	}
+	if c.NotFoundCacheSize == 0 {
+		c.NotFoundCacheSize = 10000
+	}
+	if c.NotFoundCacheTTL == 0 {
+		c.NotFoundCacheTTL = 30
+	}
 
 	c.GatewaySvc = sharedconf.GetGatewaySVC(c.GatewaySvc)
 }
 
-func (m *manager) startJanitorRun() {
-	if !m.c.EnableExpiredSharesCleanup {
-		return
+// dsn builds the data source name used to connect to the database.
+// The time values are parsed in UTC, unless overridden in the db_params.
+func (c *config) dsn() (string, error) {
+	cfg := mysql.NewConfig()
+	cfg.User = c.DBUsername
+	cfg.Passwd = c.DBPassword
+	cfg.Net = "tcp"
+	cfg.Addr = net.JoinHostPort(c.DBHost, strconv.Itoa(c.DBPort))
+	cfg.DBName = c.DBName
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	cfg.TLSConfig = c.DBTLS
+
+	if c.DBParams != "" {
+		params, err := url.ParseQuery(c.DBParams)
+		if err != nil {
+			return "", errors.Wrap(err, "invalid db_params")
+		}
+		cfg.Params = make(map[string]string, len(params))
+		for k, v := range params {
+			if len(v) != 1 {
+				return "", errtypes.BadRequest("invalid db_params: multiple values for " + k)
+			}
+			cfg.Params[k] = v[0]
+		}
+	}
+
+	// validate the resulting parameters, e.g. the name of the TLS configuration
+	dsn := cfg.FormatDSN()
+	if _, err := mysql.ParseDSN(dsn); err != nil {
+		return "", errors.Wrap(err, "invalid database configuration")
 	}
+	return dsn, nil
+}
+
+func (m *manager) startJanitorRun(ctx context.Context) {
+	defer log.Info().Msg("sql: public shares janitor stopped")
 
-	ticker := time.NewTicker(time.Duration(m.c.JanitorRunInterval) * time.Second)
-	work := make(chan os.Signal, 1)
-	signal.Notify(work, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT)
+	// delay the first run by a random offset, so that multiple
+	// replicas of the service do not run the janitor at the same time
+	interval := time.Duration(m.c.JanitorRunInterval) * time.Second
+	delay := time.NewTimer(time.Duration(rand.Int63n(int64(interval))))
+	defer delay.Stop()
+	select {
+	case <-ctx.Done():
+		return
+	case <-delay.C:
+		m.runJanitor()
+	}
 
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 	for {
 		select {
-		case <-work:
+		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			_ = m.cleanupExpiredShares()
+			m.runJanitor()
 		}
 	}
 }
 
+func (m *manager) runJanitor() {
+	orphaned, err := m.cleanupExpiredShares()
+	if err != nil {
+		log.Error().Err(err).Msg("sql: error orphaning expired public shares")
+		recordJanitorRun(orphaned, 0, err)
+		return
+	}
+	n, err := m.deleteOrphanedShares()
+	if err != nil {
+		log.Error().Err(err).Msg("sql: error deleting orphaned public shares")
+	}
+	recordJanitorRun(orphaned, n, err)
+	if n > 0 {
+		log.Info().Int64("count", n).Msg("sql: deleted orphaned public shares")
+	}
+}
+
 // New returns a new public share manager.
 func New(m map[string]interface{}) (publicshare.Manager, error) {
 	c := &config{}
@@ -116,33 +307,115 @@ func New(m map[string]interface{}) (publicshare.Manager, error) {
 	}
 	c.init()
 
-	db, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:%d)/%s", c.DBUsername, c.DBPassword, c.DBHost, c.DBPort, c.DBName))
+	if _, ok := orderByColumns[c.ListOrderBy]; c.ListOrderBy != "" && !ok {
+		return nil, errtypes.BadRequest("invalid list_order_by field " + c.ListOrderBy)
+	}
+	if c.TokenLength < 0 || len(c.TokenAlphabet) < 2 {
+		return nil, errtypes.BadRequest("invalid token_length or token_alphabet")
+	}
+	if err := c.SigningKeys.Init(); err != nil {
+		return nil, err
+	}
+
+	dsn, err := c.dsn()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := registerViews(); err != nil {
+		return nil, err
+	}
+
+	emitter, err := events.NewEmitter(&c.Events)
 	if err != nil {
 		return nil, err
 	}
 
 	mgr := manager{
-		c:  c,
-		db: db,
+		c:       c,
+		db:      db,
+		events:  emitter,
+		workers: worker.NewGroup(),
+	}
+	mgr.newToken = mgr.generateToken
+	if c.EnableNotFoundCache {
+		mgr.notFoundCache = newNotFoundCache(c.NotFoundCacheSize, time.Duration(c.NotFoundCacheTTL)*time.Second, c.LowercaseTokens)
+	}
+	if c.NotFoundLogInterval > 0 {
+		mgr.workers.Start("publicshare sql not found lookups", mgr.logNotFoundLookups)
+	}
+	if c.EnableExpiredSharesCleanup {
+		mgr.workers.Start("publicshare sql janitor", mgr.startJanitorRun)
+	}
+	if c.TrackLastAccessed {
+		mgr.accesses = make(chan access, accessesQueueSize)
+		mgr.workers.Start("publicshare sql last accessed", mgr.updateLastAccessed)
 	}
-	go mgr.startJanitorRun()
 
 	return &mgr, nil
 }
 
-func (m *manager) CreatePublicShare(ctx context.Context, u *user.User, rInfo *provider.ResourceInfo, g *link.Grant, description string, internal bool) (*link.PublicShare, error) {
+// Close stops the background workers of the manager and closes the
+// connections to the database.
+func (m *manager) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+	defer cancel()
+	if err := m.workers.Stop(ctx); err != nil {
+		return err
+	}
+	if err := m.events.Close(ctx); err != nil {
+		return err
+	}
+	return m.db.Close()
+}
+
+// Health checks the connection to the database.
+func (m *manager) Health(ctx context.Context) error {
+	return m.Check(ctx)
+}
+
+// Check returns an error if the database is not reachable within the
+// health_check_timeout. With health_check_query, it also runs a trivial
+// query, to detect the databases accepting connections but not serving them.
+func (m *manager) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(m.c.HealthCheckTimeout)*time.Second)
+	defer cancel()
+
+	if err := m.db.PingContext(ctx); err != nil {
+		return errors.Wrap(err, "sql: error connecting to the database")
+	}
+	if m.c.HealthCheckQuery {
+		var one int
+		if err := m.db.QueryRowContext(ctx, "SELECT 1").Scan(&one); err != nil {
+			return errors.Wrap(err, "sql: error querying the database")
+		}
+	}
+	return nil
+}
+
+func (m *manager) CreatePublicShare(ctx context.Context, u *user.User, rInfo *provider.ResourceInfo, g *link.Grant, description string, internal bool) (_ *link.PublicShare, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "CreatePublicShare")
-	defer span.End()
+	defer func() {
+		recordOperation(opCreate, err)
+		tracing.EndSpan(span, err)
+	}()
+
+	shareType := "public"
+	if internal {
+		shareType = "internal"
+	}
+	span.SetAttributes(attrShareType.String(shareType), resourceIDAttribute(rInfo.Id))
 
-	tkn := utils.RandString(15)
 	now := time.Now().Unix()
 
 	quicklink, _ := strconv.ParseBool(rInfo.ArbitraryMetadata.Metadata["quicklink"])
 
-	displayName, ok := rInfo.ArbitraryMetadata.Metadata["name"]
-	if !ok {
-		displayName = tkn
-	}
+	name, hasName := rInfo.ArbitraryMetadata.Metadata["name"]
 	createdAt := &typespb.Timestamp{
 		Seconds: uint64(now),
 	}
@@ -153,15 +426,15 @@ func (m *manager) CreatePublicShare(ctx context.Context, u *user.User, rInfo *pr
 	itemType := conversions.ResourceTypeToItem(rInfo.Type)
 	prefix := rInfo.Id.StorageId
 	itemSource := rInfo.Id.OpaqueId
-	fileSource, err := strconv.ParseUint(itemSource, 10, 64)
-	if err != nil {
+	fileSource, perr := strconv.ParseUint(itemSource, 10, 64)
+	if perr != nil {
 		// it can be the case that the item source may be a character string
 		// we leave fileSource blank in that case
 		fileSource = 0
 	}
 
-	query := "insert into oc_share set share_type=?,uid_owner=?,uid_initiator=?,item_type=?,fileid_prefix=?,item_source=?,file_source=?,permissions=?,stime=?,token=?,share_name=?,quicklink=?,description=?,internal=?"
-	params := []interface{}{publicShareType, owner, creator, itemType, prefix, itemSource, fileSource, permissions, now, tkn, displayName, quicklink, description, internal}
+	query := "insert into oc_share set share_type=?,uid_owner=?,uid_initiator=?,item_type=?,fileid_prefix=?,item_source=?,file_source=?,permissions=?,stime=?,quicklink=?,description=?,internal=?"
+	params := []interface{}{m.c.PublicShareType, owner, creator, itemType, prefix, itemSource, fileSource, permissions, now, quicklink, description, internal}
 
 	var passwordProtected bool
 	password := g.Password
@@ -182,20 +455,31 @@ func (m *manager) CreatePublicShare(ctx context.Context, u *user.User, rInfo *pr
 		params = append(params, t)
 	}
 
-	stmt, err := m.db.Prepare(query)
-	if err != nil {
-		return nil, err
-	}
-	result, err := stmt.Exec(params...)
-	if err != nil {
-		return nil, err
-	}
-	lastID, err := result.LastInsertId()
-	if err != nil {
-		return nil, err
+	// the token is set last, as a new one is generated on collisions
+	query += ",token=?,share_name=?"
+	var tkn, displayName string
+	var lastID int64
+	for attempt := 0; ; attempt++ {
+		if tkn, err = m.newToken(); err != nil {
+			return nil, errors.Wrap(err, "could not generate share token")
+		}
+		displayName = name
+		if !hasName {
+			displayName = tkn
+		}
+
+		lastID, err = m.insertShare(ctx, creator, rInfo.Id, internal, query, append(params, tkn, displayName))
+		if err == nil {
+			break
+		}
+		if !isDuplicateEntry(err) || attempt >= m.c.TokenRetries {
+			return nil, err
+		}
+		appctx.GetLogger(ctx).Warn().Int("attempt", attempt+1).Msg("public share token already in use, retrying with a new one")
 	}
+	m.notFoundCache.evict(tkn)
 
-	return &link.PublicShare{
+	share := &link.PublicShare{
 		Id: &link.PublicShareId{
 			OpaqueId: strconv.FormatInt(lastID, 10),
 		},
@@ -211,77 +495,219 @@ func (m *manager) CreatePublicShare(ctx context.Context, u *user.User, rInfo *pr
 		DisplayName:       displayName,
 		Quicklink:         quicklink,
 		Description:       description,
-	}, nil
+	}
+	span.SetAttributes(attrShareID.String(share.Id.OpaqueId), tracing.Hashed(attrTokenHash, tkn))
+	m.events.Emit(ctx, events.ShareCreated, share)
+	return share, nil
 }
 
-func (m *manager) UpdatePublicShare(ctx context.Context, u *user.User, req *link.UpdatePublicShareRequest, g *link.Grant) (*link.PublicShare, error) {
-	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "UpdatePublicShare")
-	defer span.End()
+// insertShare inserts the share in the database, checking in the same
+// transaction that the creator and the resource do not exceed the configured
+// limits of shares, and returns the id of the new share.
+func (m *manager) insertShare(ctx context.Context, creator string, id *provider.ResourceId, internal bool, query string, params []interface{}) (int64, error) {
+	limited := !internal && (m.c.MaxSharesPerUser > 0 || m.c.MaxSharesPerResource > 0)
+	if limited {
+		m.limitsMu.Lock()
+		defer m.limitsMu.Unlock()
+	}
 
-	query := "update oc_share set "
-	paramsMap := map[string]interface{}{}
-	params := []interface{}{}
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
 
-	now := time.Now().Unix()
-	uid := conversions.FormatUserID(u.Id)
+	if limited {
+		if err := m.checkLimits(ctx, tx, creator, id); err != nil {
+			return 0, err
+		}
+	}
 
-	switch req.GetUpdate().GetType() {
-	case link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME:
-		paramsMap["share_name"] = req.Update.GetDisplayName()
-	case link.UpdatePublicShareRequest_Update_TYPE_PERMISSIONS:
-		paramsMap["permissions"] = conversions.SharePermToInt(req.Update.GetGrant().GetPermissions().Permissions)
-	case link.UpdatePublicShareRequest_Update_TYPE_EXPIRATION:
-		paramsMap["expiration"] = time.Unix(int64(req.Update.GetGrant().Expiration.Seconds), 0)
-	case link.UpdatePublicShareRequest_Update_TYPE_PASSWORD:
-		if req.Update.GetGrant().Password == "" {
-			paramsMap["share_with"] = ""
-		} else {
-			h, err := hashPassword(req.Update.GetGrant().Password, m.c.SharePasswordHashCost)
-			if err != nil {
-				return nil, errors.Wrap(err, "could not hash share password")
-			}
-			paramsMap["share_with"] = h
+	start := time.Now()
+	result, err := tx.ExecContext(ctx, query, params...)
+	m.logSlowQuery(ctx, queryInsert, query, time.Since(start))
+	if err != nil {
+		return 0, err
+	}
+	lastID, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return lastID, nil
+}
+
+// checkLimits returns a PermissionDenied error if the creator or the resource
+// already reached the configured maximum number of shares.
+// The internal, expired and orphaned shares are not counted.
+func (m *manager) checkLimits(ctx context.Context, tx *sql.Tx, creator string, id *provider.ResourceId) error {
+	query := "select count(*) from oc_share where share_type=? AND internal=false AND (orphan = 0 or orphan IS NULL) AND (expiration IS NULL OR expiration >= ?)"
+	now := time.Now().UTC().Format("2006-01-02 15:04:05")
+
+	if m.c.MaxSharesPerUser > 0 {
+		var count int
+		if err := tx.QueryRowContext(ctx, query+" AND uid_initiator=? FOR UPDATE", m.c.PublicShareType, now, creator).Scan(&count); err != nil {
+			return err
 		}
-	case link.UpdatePublicShareRequest_Update_TYPE_DESCRIPTION:
-		paramsMap["description"] = req.Update.GetDescription()
-	default:
-		return nil, fmt.Errorf("invalid update type: %v", req.GetUpdate().GetType())
+		if count >= m.c.MaxSharesPerUser {
+			return errtypes.PermissionDenied(fmt.Sprintf("share limit exceeded: the user cannot create more than %d public links", m.c.MaxSharesPerUser))
+		}
+	}
+
+	if m.c.MaxSharesPerResource > 0 {
+		var count int
+		if err := tx.QueryRowContext(ctx, query+" AND fileid_prefix=? AND item_source=? FOR UPDATE", m.c.PublicShareType, now, id.StorageId, id.OpaqueId).Scan(&count); err != nil {
+			return err
+		}
+		if count >= m.c.MaxSharesPerResource {
+			return errtypes.PermissionDenied(fmt.Sprintf("share limit exceeded: the resource cannot have more than %d public links", m.c.MaxSharesPerResource))
+		}
+	}
+
+	return nil
+}
+
+func (m *manager) UpdatePublicShare(ctx context.Context, u *user.User, req *link.UpdatePublicShareRequest, g *link.Grant) (*link.PublicShare, error) {
+	updates, err := publicshare.GetUpdates(req)
+	if err != nil {
+		return nil, err
 	}
+	return m.UpdatePublicShareFields(ctx, u, req.Ref, updates)
+}
 
-	for k, v := range paramsMap {
-		query += k + "=?"
-		params = append(params, v)
+// UpdatePublicShareFields applies all the updates to the share in a single statement.
+func (m *manager) UpdatePublicShareFields(ctx context.Context, u *user.User, ref *link.PublicShareReference, updates []*link.UpdatePublicShareRequest_Update) (_ *link.PublicShare, err error) {
+	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "UpdatePublicShare")
+	defer func() {
+		recordOperation(opUpdate, err)
+		tracing.EndSpan(span, err)
+	}()
+	span.SetAttributes(refAttribute(ref))
+
+	types := make([]string, 0, len(updates))
+	for _, upd := range updates {
+		types = append(types, upd.GetType().String())
+	}
+	span.SetAttributes(attrUpdateType.String(strings.Join(types, ",")))
+
+	// all the updates are validated before changing the share,
+	// so that either all or none of them are applied
+	columns, params, err := m.updatedColumns(updates)
+	if err != nil {
+		return nil, err
 	}
 
+	now := time.Now().Unix()
+	uid := conversions.FormatUserID(u.Id)
+	query := "update oc_share set " + strings.Join(columns, ",")
+	tokenParam := -1
+
 	switch {
-	case req.Ref.GetId() != nil:
+	case ref.GetId() != nil:
 		query += ",stime=? where id=? AND (uid_owner=? or uid_initiator=?)"
-		params = append(params, now, req.Ref.GetId().OpaqueId, uid, uid)
-	case req.Ref.GetToken() != "":
+		params = append(params, now, ref.GetId().OpaqueId, uid, uid)
+	case ref.GetToken() != "":
 		query += ",stime=? where token=? AND (uid_owner=? or uid_initiator=?)"
-		params = append(params, now, req.Ref.GetToken(), uid, uid)
+		tokenParam = len(params) + 1
+		params = append(params, now, ref.GetToken(), uid, uid)
 	default:
-		return nil, errtypes.NotFound(req.Ref.String())
+		return nil, errtypes.NotFound(ref.String())
 	}
 
-	stmt, err := m.db.Prepare(query)
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	res, err := m.execByToken(func(params ...interface{}) (sql.Result, error) {
+		start := time.Now()
+		res, err := tx.ExecContext(ctx, query, params...)
+		m.logSlowQuery(ctx, queryUpdate, query, time.Since(start))
+		return res, err
+	}, params, tokenParam)
 	if err != nil {
 		return nil, err
 	}
-	if _, err = stmt.Exec(params...); err != nil {
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
+	if rowCnt, err := res.RowsAffected(); err == nil {
+		span.SetAttributes(attrRowsAffected.Int64(rowCnt))
+	}
 
-	return m.GetPublicShare(ctx, u, req.Ref, false)
+	share, err := m.GetPublicShare(ctx, u, ref, false)
+	if err != nil {
+		return nil, err
+	}
+	m.events.Emit(ctx, events.ShareUpdated, share)
+	return share, nil
 }
 
-func (m *manager) getByToken(ctx context.Context, token string, u *user.User) (*link.PublicShare, string, error) {
+// updatedColumns returns the assignments of the columns changed by the updates,
+// with their parameters, failing if any of the updates is invalid.
+func (m *manager) updatedColumns(updates []*link.UpdatePublicShareRequest_Update) ([]string, []interface{}, error) {
+	columns := make([]string, 0, len(updates))
+	params := make([]interface{}, 0, len(updates))
+	seen := make(map[link.UpdatePublicShareRequest_Update_Type]bool, len(updates))
+	for _, upd := range updates {
+		if seen[upd.GetType()] {
+			return nil, nil, errtypes.BadRequest(fmt.Sprintf("duplicated update type: %v", upd.GetType()))
+		}
+		seen[upd.GetType()] = true
+
+		var column string
+		var param interface{}
+		switch upd.GetType() {
+		case link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME:
+			column, param = "share_name", upd.GetDisplayName()
+		case link.UpdatePublicShareRequest_Update_TYPE_PERMISSIONS:
+			column, param = "permissions", conversions.SharePermToInt(upd.GetGrant().GetPermissions().GetPermissions())
+		case link.UpdatePublicShareRequest_Update_TYPE_EXPIRATION:
+			column = "expiration"
+			if exp := upd.GetGrant().GetExpiration(); exp != nil {
+				param = time.Unix(int64(exp.Seconds), 0)
+			}
+		case link.UpdatePublicShareRequest_Update_TYPE_PASSWORD:
+			column, param = "share_with", ""
+			if upd.GetGrant().GetPassword() == "" {
+				if m.c.EnforcePassword {
+					return nil, nil, errtypes.BadRequest("the password of the share cannot be removed")
+				}
+				break
+			}
+			h, err := hashPassword(upd.GetGrant().GetPassword(), m.c.SharePasswordHashCost)
+			if err != nil {
+				return nil, nil, errors.Wrap(err, "could not hash share password")
+			}
+			param = h
+		case link.UpdatePublicShareRequest_Update_TYPE_DESCRIPTION:
+			column, param = "description", upd.GetDescription()
+		default:
+			return nil, nil, errtypes.BadRequest(fmt.Sprintf("invalid update type: %v", upd.GetType()))
+		}
+		columns = append(columns, column+"=?")
+		params = append(params, param)
+	}
+	return columns, params, nil
+}
+
+func (m *manager) getByToken(ctx context.Context, token string, u *user.User) (_ *link.PublicShare, _ string, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "getByToken")
-	defer span.End()
+	defer func() { tracing.EndSpan(span, err) }()
+	span.SetAttributes(tracing.Hashed(attrTokenHash, token))
 
 	s := conversions.DBShare{Token: token}
 	query := "select coalesce(uid_owner, '') as uid_owner, coalesce(uid_initiator, '') as uid_initiator, coalesce(share_with, '') as share_with, coalesce(fileid_prefix, '') as fileid_prefix, coalesce(item_source, '') as item_source, coalesce(item_type, '') as item_type, coalesce(expiration, '') as expiration, coalesce(share_name, '') as share_name, id, stime, permissions, quicklink, description FROM oc_share WHERE (orphan = 0 or orphan IS NULL) AND share_type=? AND token=?"
-	if err := m.db.QueryRow(query, publicShareType, token).Scan(&s.UIDOwner, &s.UIDInitiator, &s.ShareWith, &s.Prefix, &s.ItemSource, &s.ItemType, &s.Expiration, &s.ShareName, &s.ID, &s.STime, &s.Permissions, &s.Quicklink, &s.Description); err != nil {
+	start := time.Now()
+	err = m.queryRowByToken(query, token, &s.UIDOwner, &s.UIDInitiator, &s.ShareWith, &s.Prefix, &s.ItemSource, &s.ItemType, &s.Expiration, &s.ShareName, &s.ID, &s.STime, &s.Permissions, &s.Quicklink, &s.Description)
+	m.logSlowQuery(ctx, queryGetByToken, query, time.Since(start))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, "", errtypes.NotFound(token)
 		}
@@ -290,14 +716,18 @@ func (m *manager) getByToken(ctx context.Context, token string, u *user.User) (*
 	return conversions.ConvertToCS3PublicShare(s), s.ShareWith, nil
 }
 
-func (m *manager) getByID(ctx context.Context, id *link.PublicShareId, u *user.User) (*link.PublicShare, string, error) {
+func (m *manager) getByID(ctx context.Context, id *link.PublicShareId, u *user.User) (_ *link.PublicShare, _ string, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "getByID")
-	defer span.End()
+	defer func() { tracing.EndSpan(span, err) }()
+	span.SetAttributes(attrShareID.String(id.GetOpaqueId()))
 
 	uid := conversions.FormatUserID(u.Id)
 	s := conversions.DBShare{ID: id.OpaqueId}
 	query := "select coalesce(uid_owner, '') as uid_owner, coalesce(uid_initiator, '') as uid_initiator, coalesce(share_with, '') as share_with, coalesce(fileid_prefix, '') as fileid_prefix, coalesce(item_source, '') as item_source, coalesce(item_type, '') as item_type, coalesce(token,'') as token, coalesce(expiration, '') as expiration, coalesce(share_name, '') as share_name, stime, permissions, quicklink, description FROM oc_share WHERE (orphan = 0 or orphan IS NULL) AND share_type=? AND id=? AND (uid_owner=? OR uid_initiator=?)"
-	if err := m.db.QueryRow(query, publicShareType, id.OpaqueId, uid, uid).Scan(&s.UIDOwner, &s.UIDInitiator, &s.ShareWith, &s.Prefix, &s.ItemSource, &s.ItemType, &s.Token, &s.Expiration, &s.ShareName, &s.STime, &s.Permissions, &s.Quicklink, &s.Description); err != nil {
+	start := time.Now()
+	err = m.db.QueryRow(query, m.c.PublicShareType, id.OpaqueId, uid, uid).Scan(&s.UIDOwner, &s.UIDInitiator, &s.ShareWith, &s.Prefix, &s.ItemSource, &s.ItemType, &s.Token, &s.Expiration, &s.ShareName, &s.STime, &s.Permissions, &s.Quicklink, &s.Description)
+	m.logSlowQuery(ctx, queryGetByID, query, time.Since(start))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, "", errtypes.NotFound(id.OpaqueId)
 		}
@@ -306,13 +736,16 @@ func (m *manager) getByID(ctx context.Context, id *link.PublicShareId, u *user.U
 	return conversions.ConvertToCS3PublicShare(s), s.ShareWith, nil
 }
 
-func (m *manager) GetPublicShare(ctx context.Context, u *user.User, ref *link.PublicShareReference, sign bool) (*link.PublicShare, error) {
+func (m *manager) GetPublicShare(ctx context.Context, u *user.User, ref *link.PublicShareReference, sign bool) (_ *link.PublicShare, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "GetPublicShare")
-	defer span.End()
+	defer func() {
+		recordOperation(opGet, err)
+		tracing.EndSpan(span, err)
+	}()
+	span.SetAttributes(refAttribute(ref))
 
 	var s *link.PublicShare
 	var pw string
-	var err error
 	switch {
 	case ref.GetId() != nil:
 		s, pw, err = m.getByID(ctx, ref.GetId(), u)
@@ -326,14 +759,14 @@ func (m *manager) GetPublicShare(ctx context.Context, u *user.User, ref *link.Pu
 	}
 
 	if expired(s) {
-		if err := m.cleanupExpiredShares(); err != nil {
+		if _, err := m.cleanupExpiredShares(); err != nil {
 			return nil, err
 		}
 		return nil, errtypes.NotFound(ref.String())
 	}
 
 	if s.PasswordProtected && sign {
-		if err := publicshare.AddSignature(s, pw); err != nil {
+		if err := m.c.SigningKeys.AddSignature(s, pw); err != nil {
 			return nil, err
 		}
 	}
@@ -341,14 +774,99 @@ func (m *manager) GetPublicShare(ctx context.Context, u *user.User, ref *link.Pu
 	return s, nil
 }
 
-func (m *manager) ListPublicShares(ctx context.Context, u *user.User, filters []*link.ListPublicSharesRequest_Filter, md *provider.ResourceInfo, sign bool) ([]*link.PublicShare, error) {
+func (m *manager) ListPublicShares(ctx context.Context, u *user.User, filters []*link.ListPublicSharesRequest_Filter, md *provider.ResourceInfo, sign bool) (_ []*link.PublicShare, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "ListPublicShares")
-	defer span.End()
+	defer func() {
+		recordOperation(opList, err)
+		tracing.EndSpan(span, err)
+	}()
+	span.SetAttributes(attrFilterCount.Int(len(filters)))
+
+	where, params, err := m.listWhereClause(ctx, u, filters)
+	if err != nil {
+		return nil, err
+	}
+	query := "select coalesce(uid_owner, '') as uid_owner, coalesce(uid_initiator, '') as uid_initiator, coalesce(share_with, '') as share_with, coalesce(fileid_prefix, '') as fileid_prefix, coalesce(item_source, '') as item_source, coalesce(item_type, '') as item_type, coalesce(token,'') as token, coalesce(expiration, '') as expiration, coalesce(share_name, '') as share_name, id, stime, permissions, quicklink, description FROM oc_share WHERE " + where
+
+	orderBy, err := m.orderBy(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if orderBy != "" {
+		query = fmt.Sprintf("%s ORDER BY %s", query, orderBy)
+	}
+
+	start := time.Now()
+	rows, err := m.db.Query(query, params...)
+	m.logSlowQuery(ctx, queryList, query, time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var s conversions.DBShare
+	shares := []*link.PublicShare{}
+	for rows.Next() {
+		if err := rows.Scan(&s.UIDOwner, &s.UIDInitiator, &s.ShareWith, &s.Prefix, &s.ItemSource, &s.ItemType, &s.Token, &s.Expiration, &s.ShareName, &s.ID, &s.STime, &s.Permissions, &s.Quicklink, &s.Description); err != nil {
+			continue
+		}
+		cs3Share := conversions.ConvertToCS3PublicShare(s)
+		if expired(cs3Share) {
+			_, _ = m.cleanupExpiredShares()
+		} else {
+			if cs3Share.PasswordProtected && sign {
+				if err := m.c.SigningKeys.AddSignature(cs3Share, s.ShareWith); err != nil {
+					return nil, err
+				}
+			}
+			shares = append(shares, cs3Share)
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	span.SetAttributes(attrRowsReturned.Int(len(shares)))
+	return shares, nil
+}
+
+// CountPublicShares returns the number of public shares, not expired,
+// that ListPublicShares would return with the given filters.
+func (m *manager) CountPublicShares(ctx context.Context, u *user.User, filters []*link.ListPublicSharesRequest_Filter) (_ int, err error) {
+	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "CountPublicShares")
+	defer func() {
+		recordOperation(opCount, err)
+		tracing.EndSpan(span, err)
+	}()
+	span.SetAttributes(attrFilterCount.Int(len(filters)))
+
+	where, params, err := m.listWhereClause(ctx, u, filters)
+	if err != nil {
+		return 0, err
+	}
+	query := "select count(*) FROM oc_share WHERE " + where + " AND (expiration IS NULL OR expiration >= ?)"
+	params = append(params, time.Now().UTC().Format("2006-01-02 15:04:05"))
+
+	var count int
+	start := time.Now()
+	err = m.db.QueryRow(query, params...).Scan(&count)
+	m.logSlowQuery(ctx, queryCount, query, time.Since(start))
+	if err != nil {
+		return 0, err
+	}
+	return count, nil
+}
 
-	query := "select coalesce(uid_owner, '') as uid_owner, coalesce(uid_initiator, '') as uid_initiator, coalesce(share_with, '') as share_with, coalesce(fileid_prefix, '') as fileid_prefix, coalesce(item_source, '') as item_source, coalesce(item_type, '') as item_type, coalesce(token,'') as token, coalesce(expiration, '') as expiration, coalesce(share_name, '') as share_name, id, stime, permissions, quicklink, description FROM oc_share WHERE (orphan = 0 or orphan IS NULL) AND (share_type=?) AND internal=false"
+// listWhereClause builds the conditions and the related parameters
+// selecting the public shares visible to the user with the given filters.
+func (m *manager) listWhereClause(ctx context.Context, u *user.User, filters []*link.ListPublicSharesRequest_Filter) (string, []interface{}, error) {
+	where := "(orphan = 0 or orphan IS NULL) AND (share_type=?)"
+	if !m.includeInternal(ctx) {
+		where += " AND internal=false"
+	}
 	var resourceFilters, ownerFilters, creatorFilters string
 	var resourceParams, ownerParams, creatorParams []interface{}
-	params := []interface{}{publicShareType}
+	params := []interface{}{m.c.PublicShareType}
 	for _, f := range filters {
 		switch f.Type {
 		case link.ListPublicSharesRequest_Filter_TYPE_RESOURCE_ID:
@@ -373,65 +891,58 @@ func (m *manager) ListPublicShares(ctx context.Context, u *user.User, filters []
 	}
 
 	if resourceFilters != "" {
-		query = fmt.Sprintf("%s AND (%s)", query, resourceFilters)
+		where = fmt.Sprintf("%s AND (%s)", where, resourceFilters)
 		params = append(params, resourceParams...)
 	}
 	if ownerFilters != "" {
-		query = fmt.Sprintf("%s AND (%s)", query, ownerFilters)
+		where = fmt.Sprintf("%s AND (%s)", where, ownerFilters)
 		params = append(params, ownerParams...)
 	}
 	if creatorFilters != "" {
-		query = fmt.Sprintf("%s AND (%s)", query, creatorFilters)
+		where = fmt.Sprintf("%s AND (%s)", where, creatorFilters)
 		params = append(params, creatorParams...)
 	}
 
+	if window, ok := publicshare.ContextGetExpirationWindow(ctx); ok {
+		// the shares that never expire are not in any window
+		where += " AND expiration IS NOT NULL AND expiration != ''"
+		if !window.From.IsZero() {
+			where += " AND expiration >= ?"
+			params = append(params, window.From.UTC().Format("2006-01-02 15:04:05"))
+		}
+		if !window.To.IsZero() {
+			where += " AND expiration <= ?"
+			params = append(params, window.To.UTC().Format("2006-01-02 15:04:05"))
+		}
+	}
+
 	uidOwnersQuery, uidOwnersParams, err := m.uidOwnerFilters(ctx, u, filters)
 	if err != nil {
-		return nil, err
+		return "", nil, err
 	}
 	params = append(params, uidOwnersParams...)
 	if uidOwnersQuery != "" {
-		query = fmt.Sprintf("%s AND (%s)", query, uidOwnersQuery)
-	}
-
-	rows, err := m.db.Query(query, params...)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var s conversions.DBShare
-	shares := []*link.PublicShare{}
-	for rows.Next() {
-		if err := rows.Scan(&s.UIDOwner, &s.UIDInitiator, &s.ShareWith, &s.Prefix, &s.ItemSource, &s.ItemType, &s.Token, &s.Expiration, &s.ShareName, &s.ID, &s.STime, &s.Permissions, &s.Quicklink, &s.Description); err != nil {
-			continue
-		}
-		cs3Share := conversions.ConvertToCS3PublicShare(s)
-		if expired(cs3Share) {
-			_ = m.cleanupExpiredShares()
-		} else {
-			if cs3Share.PasswordProtected && sign {
-				if err := publicshare.AddSignature(cs3Share, s.ShareWith); err != nil {
-					return nil, err
-				}
-			}
-			shares = append(shares, cs3Share)
-		}
-	}
-	if err = rows.Err(); err != nil {
-		return nil, err
+		where = fmt.Sprintf("%s AND (%s)", where, uidOwnersQuery)
 	}
 
-	return shares, nil
+	return where, params, nil
 }
 
-func (m *manager) RevokePublicShare(ctx context.Context, u *user.User, ref *link.PublicShareReference) error {
+func (m *manager) RevokePublicShare(ctx context.Context, u *user.User, ref *link.PublicShareReference) (err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "RevokePublicShare")
-	defer span.End()
+	defer func() {
+		recordOperation(opRevoke, err)
+		tracing.EndSpan(span, err)
+	}()
+	span.SetAttributes(refAttribute(ref))
+
+	// the share is read before it is deleted, to notify its data
+	share := m.revokedShare(ctx, u, ref)
 
 	uid := conversions.FormatUserID(u.Id)
 	query := "delete from oc_share where "
 	params := []interface{}{}
+	tokenParam := -1
 
 	switch {
 	case ref.GetId() != nil && ref.GetId().OpaqueId != "":
@@ -439,6 +950,7 @@ func (m *manager) RevokePublicShare(ctx context.Context, u *user.User, ref *link
 		params = append(params, ref.GetId().OpaqueId, uid, uid)
 	case ref.GetToken() != "":
 		query += "token=? AND (uid_owner=? or uid_initiator=?)"
+		tokenParam = 0
 		params = append(params, ref.GetToken(), uid, uid)
 	default:
 		return errtypes.NotFound(ref.String())
@@ -448,7 +960,12 @@ func (m *manager) RevokePublicShare(ctx context.Context, u *user.User, ref *link
 	if err != nil {
 		return err
 	}
-	res, err := stmt.Exec(params...)
+	res, err := m.execByToken(func(params ...interface{}) (sql.Result, error) {
+		start := time.Now()
+		res, err := stmt.Exec(params...)
+		m.logSlowQuery(ctx, queryRevoke, query, time.Since(start))
+		return res, err
+	}, params, tokenParam)
 	if err != nil {
 		return err
 	}
@@ -457,115 +974,444 @@ func (m *manager) RevokePublicShare(ctx context.Context, u *user.User, ref *link
 	if err != nil {
 		return err
 	}
+	span.SetAttributes(attrRowsAffected.Int64(rowCnt))
 	if rowCnt == 0 {
 		return errtypes.NotFound(ref.String())
 	}
+	m.events.Emit(ctx, events.ShareRevoked, share)
 	return nil
 }
 
-func (m *manager) GetPublicShareByToken(ctx context.Context, token string, auth *link.PublicShareAuthentication, sign bool) (*link.PublicShare, error) {
+// revokedShare returns the share about to be revoked; if it can't be read,
+// only its reference is returned.
+func (m *manager) revokedShare(ctx context.Context, u *user.User, ref *link.PublicShareReference) *link.PublicShare {
+	var s *link.PublicShare
+	var err error
+	switch {
+	case ref.GetId() != nil && ref.GetId().OpaqueId != "":
+		s, _, err = m.getByID(ctx, ref.GetId(), u)
+	case ref.GetToken() != "":
+		s, _, err = m.getByToken(ctx, ref.GetToken(), u)
+	}
+	if err != nil || s == nil {
+		return &link.PublicShare{Id: ref.GetId(), Token: ref.GetToken()}
+	}
+	return s
+}
+
+// RestorePublicShare restores a share of the user that was orphaned once expired,
+// e.g. by mistake because of a clock skew. If the share is expired, a new
+// expiration in the future has to be given.
+func (m *manager) RestorePublicShare(ctx context.Context, u *user.User, ref *link.PublicShareReference, expiration *typespb.Timestamp) (_ *link.PublicShare, err error) {
+	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "RestorePublicShare")
+	defer func() {
+		recordOperation(opRestore, err)
+		tracing.EndSpan(span, err)
+	}()
+	span.SetAttributes(refAttribute(ref))
+
+	var id, uidOwner, uidInitiator, exp string
+	var orphan bool
+	query := "select id, coalesce(uid_owner, '') as uid_owner, coalesce(uid_initiator, '') as uid_initiator, coalesce(expiration, '') as expiration, coalesce(orphan, 0) as orphan FROM oc_share WHERE share_type=? AND "
+	switch {
+	case ref.GetId() != nil && ref.GetId().OpaqueId != "":
+		err = m.db.QueryRow(query+"id=?", m.c.PublicShareType, ref.GetId().OpaqueId).Scan(&id, &uidOwner, &uidInitiator, &exp, &orphan)
+	case ref.GetToken() != "":
+		err = m.queryRowByToken(query+"token=?", ref.GetToken(), &id, &uidOwner, &uidInitiator, &exp, &orphan)
+	default:
+		return nil, errtypes.NotFound(ref.String())
+	}
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errtypes.NotFound(ref.String())
+		}
+		return nil, err
+	}
+
+	uid := conversions.FormatUserID(u.Id)
+	if uid != uidOwner && uid != uidInitiator {
+		return nil, errtypes.PermissionDenied("public share not owned by the user: " + ref.String())
+	}
+	if !orphan {
+		return nil, errtypes.BadRequest("public share is not orphaned: " + ref.String())
+	}
+
+	query = "update oc_share set orphan=0"
+	params := []interface{}{}
+	if expiration != nil && expiration.Seconds != 0 {
+		t := time.Unix(int64(expiration.Seconds), 0)
+		if !t.After(time.Now()) {
+			return nil, errtypes.BadRequest("the new expiration of the public share is in the past")
+		}
+		query += ",expiration=?"
+		params = append(params, t.UTC().Format("2006-01-02 15:04:05"))
+	} else if t, err := time.Parse("2006-01-02 15:04:05", exp); err == nil && !t.After(time.Now()) {
+		return nil, errtypes.BadRequest("public share is expired, a new expiration is required to restore it: " + ref.String())
+	}
+	query += " where id=?"
+	params = append(params, id)
+
+	start := time.Now()
+	_, err = m.db.ExecContext(ctx, query, params...)
+	m.logSlowQuery(ctx, queryRestore, query, time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+
+	share, err := m.GetPublicShare(ctx, u, &link.PublicShareReference{Spec: &link.PublicShareReference_Id{Id: &link.PublicShareId{OpaqueId: id}}}, false)
+	if err != nil {
+		return nil, err
+	}
+	m.events.Emit(ctx, events.ShareRestored, share)
+	return share, nil
+}
+
+func (m *manager) GetPublicShareByToken(ctx context.Context, token string, auth *link.PublicShareAuthentication, sign bool) (_ *link.PublicShare, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "GetPublicShareByToken")
-	defer span.End()
+	defer func() {
+		recordOperation(opGetByToken, err)
+		tracing.EndSpan(span, err)
+	}()
+	span.SetAttributes(tracing.Hashed(attrTokenHash, token))
+
+	if m.notFoundCache.has(token) {
+		m.notFound.record(true)
+		return nil, errtypes.NotFound(token)
+	}
 
+	generation := m.notFoundCache.generation()
 	s := conversions.DBShare{Token: token}
 	query := "select coalesce(uid_owner, '') as uid_owner, coalesce(uid_initiator, '') as uid_initiator, coalesce(share_with, '') as share_with, coalesce(fileid_prefix, '') as fileid_prefix, coalesce(item_source, '') as item_source, coalesce(item_type, '') as item_type, coalesce(expiration, '') as expiration, coalesce(share_name, '') as share_name, id, stime, permissions, quicklink, description FROM oc_share WHERE share_type=? AND token=?"
-	if err := m.db.QueryRow(query, publicShareType, token).Scan(&s.UIDOwner, &s.UIDInitiator, &s.ShareWith, &s.Prefix, &s.ItemSource, &s.ItemType, &s.Expiration, &s.ShareName, &s.ID, &s.STime, &s.Permissions, &s.Quicklink, &s.Description); err != nil {
+	start := time.Now()
+	err = m.queryRowByToken(query, token, &s.UIDOwner, &s.UIDInitiator, &s.ShareWith, &s.Prefix, &s.ItemSource, &s.ItemType, &s.Expiration, &s.ShareName, &s.ID, &s.STime, &s.Permissions, &s.Quicklink, &s.Description)
+	m.logSlowQuery(ctx, queryGetByToken, query, time.Since(start))
+	if err != nil {
 		if err == sql.ErrNoRows {
+			m.notFound.record(false)
+			m.notFoundCache.add(token, generation)
 			return nil, errtypes.NotFound(token)
 		}
 		return nil, err
 	}
 	cs3Share := conversions.ConvertToCS3PublicShare(s)
 	if expired(cs3Share) {
-		if err := m.cleanupExpiredShares(); err != nil {
+		if _, err := m.cleanupExpiredShares(); err != nil {
 			return nil, err
 		}
 		return nil, errtypes.NotFound(token)
 	}
 	if s.ShareWith != "" {
-		if !authenticate(cs3Share, s.ShareWith, auth) {
+		if !authenticate(cs3Share, s.ShareWith, auth, &m.c.SigningKeys) {
 			// if check := checkPasswordHash(auth.Password, s.ShareWith); !check {
 			return nil, errtypes.InvalidCredentials(token)
 		}
 
 		if sign {
-			if err := publicshare.AddSignature(cs3Share, s.ShareWith); err != nil {
+			if err := m.c.SigningKeys.AddSignature(cs3Share, s.ShareWith); err != nil {
 				return nil, err
 			}
 		}
 	}
 
+	m.trackAccess(s.ID)
 	return cs3Share, nil
 }
 
-func (m *manager) cleanupExpiredShares() error {
+// LastAccessed returns the time of the last access to the shares through their token.
+// Nothing is returned if the tracking of the accesses is disabled.
+func (m *manager) LastAccessed(ctx context.Context, ids []string) (_ map[string]time.Time, err error) {
+	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "LastAccessed")
+	defer func() { tracing.EndSpan(span, err) }()
+
+	accessed := map[string]time.Time{}
+	if !m.c.TrackLastAccessed {
+		return accessed, nil
+	}
+
+	params := make([]interface{}, 0, len(ids))
+	for _, id := range ids {
+		// the ids of the shares are integers, others cannot match any share
+		if n, err := strconv.ParseInt(id, 10, 64); err == nil {
+			params = append(params, n)
+		}
+	}
+	if len(params) == 0 {
+		return accessed, nil
+	}
+	query := "select id, last_accessed from oc_share where last_accessed IS NOT NULL AND id in (?" + strings.Repeat(",?", len(params)-1) + ")"
+
+	start := time.Now()
+	rows, err := m.db.QueryContext(ctx, query, params...)
+	m.logSlowQuery(ctx, queryLastAccessed, query, time.Since(start))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var id string
+	var lastAccessed int64
+	for rows.Next() {
+		if err := rows.Scan(&id, &lastAccessed); err != nil {
+			return nil, err
+		}
+		accessed[id] = time.Unix(lastAccessed, 0)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attrRowsReturned.Int(len(accessed)))
+	return accessed, nil
+}
+
+// trackAccess queues the update of the last access time of the share.
+// The update is best-effort: it is dropped if too many are already queued.
+func (m *manager) trackAccess(id string) {
+	if !m.c.TrackLastAccessed {
+		return
+	}
+	select {
+	case m.accesses <- access{id: id, at: time.Now()}:
+	default:
+		log.Warn().Str("id", id).Msg("too many queued updates of the last access time of the public shares, dropping one")
+	}
+}
+
+func (m *manager) updateLastAccessed(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case a := <-m.accesses:
+			if _, err := m.db.Exec("update oc_share set last_accessed=? where id=?", a.at.Unix(), a.id); err != nil {
+				log.Error().Err(err).Str("id", a.id).Msg("error updating the last access time of the public share")
+			}
+		}
+	}
+}
+
+// logSlowQuery records the given parameterized query in the span of the
+// operation and its latency in the metrics, and logs it at warn level when
+// its execution took longer than the configured threshold.
+func (m *manager) logSlowQuery(ctx context.Context, name, query string, d time.Duration) {
+	traceQuery(ctx, query)
+	recordQueryLatency(name, d)
+	if d < time.Duration(m.c.SlowQueryThreshold)*time.Millisecond {
+		return
+	}
+	appctx.GetLogger(ctx).Warn().Str("query", query).Dur("duration", d).Msg("slow query on the public shares database")
+}
+
+// traceQuery records the given parameterized query in the span of the operation.
+func traceQuery(ctx context.Context, query string) {
+	trace.SpanFromContext(ctx).SetAttributes(semconv.DBStatementKey.String(query))
+}
+
+// refAttribute returns the attribute identifying the referenced share
+// in the span of the operation: its id, or the hash of its token.
+func refAttribute(ref *link.PublicShareReference) attribute.KeyValue {
+	if ref.GetId() != nil {
+		return attrShareID.String(ref.GetId().GetOpaqueId())
+	}
+	return tracing.Hashed(attrTokenHash, ref.GetToken())
+}
+
+// resourceIDAttribute returns the attribute identifying the shared resource.
+func resourceIDAttribute(id *provider.ResourceId) attribute.KeyValue {
+	return attrResourceID.String(id.GetStorageId() + "!" + id.GetOpaqueId())
+}
+
+// cleanupExpiredShares orphans the expired public shares, and returns
+// the number of orphaned shares.
+func (m *manager) cleanupExpiredShares() (int64, error) {
 	if !m.c.EnableExpiredSharesCleanup {
-		return nil
+		return 0, nil
 	}
 
 	query := "update oc_share set orphan = 1 where expiration IS NOT NULL AND expiration < ?"
-	params := []interface{}{time.Now().Format("2006-01-02 03:04:05")}
+	params := []interface{}{time.Now().UTC().Format("2006-01-02 15:04:05")}
 
 	stmt, err := m.db.Prepare(query)
 	if err != nil {
-		return err
+		return 0, err
 	}
-	if _, err = stmt.Exec(params...); err != nil {
-		return err
+	defer stmt.Close()
+	res, err := stmt.Exec(params...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// deleteOrphanedShares deletes the public shares that have been orphaned
+// and expired for longer than the retention period, in batches to avoid
+// locking the table for long. The retention is counted from the expiration,
+// as the time the shares have been orphaned is not stored. It returns the
+// number of deleted shares.
+func (m *manager) deleteOrphanedShares() (int64, error) {
+	if !m.c.EnableExpiredSharesCleanup || m.c.OrphanRetentionDays <= 0 {
+		return 0, nil
+	}
+
+	retention := time.Duration(m.c.OrphanRetentionDays) * 24 * time.Hour
+	query := "delete from oc_share where share_type=? AND expiration < ? AND orphan = 1 LIMIT ?"
+	params := []interface{}{m.c.PublicShareType, time.Now().UTC().Add(-retention).Format("2006-01-02 15:04:05"), m.c.JanitorBatchSize}
+
+	stmt, err := m.db.Prepare(query)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var total int64
+	for {
+		res, err := stmt.Exec(params...)
+		if err != nil {
+			return total, err
+		}
+		n, err := res.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < int64(m.c.JanitorBatchSize) {
+			return total, nil
+		}
 	}
-	return nil
 }
 
-func (m *manager) uidOwnerFilters(ctx context.Context, u *user.User, filters []*link.ListPublicSharesRequest_Filter) (string, []interface{}, error) {
+func (m *manager) uidOwnerFilters(ctx context.Context, u *user.User, filters []*link.ListPublicSharesRequest_Filter) (_ string, _ []interface{}, err error) {
 	ctx, span := tracing.SpanStartFromContext(ctx, tracerName, "uidOwnerFilters")
-	defer span.End()
+	defer func() { tracing.EndSpan(span, err) }()
 
 	uid := conversions.FormatUserID(u.Id)
 
 	query := "uid_owner=? or uid_initiator=?"
 	params := []interface{}{uid, uid}
 
-	client, err := pool.GetGatewayServiceClient(ctx, pool.Endpoint(m.c.GatewaySvc))
+	// For shares inside project spaces, if the user is an admin, we list all shares
+	// of the resource, including the ones created by other admins. The resource
+	// is matched instead of the project owners, as in revaold the uid of the share
+	// creator is stored as uid_owner.
+	resources, err := m.projectAdminResources(ctx, u, filters)
 	if err != nil {
 		return "", nil, err
 	}
+	for _, id := range resources {
+		query += " or (fileid_prefix=? AND item_source=?)"
+		params = append(params, id.StorageId, id.OpaqueId)
+	}
 
-	for _, f := range filters {
-		if f.Type == link.ListPublicSharesRequest_Filter_TYPE_RESOURCE_ID {
-			// For shares inside project spaces, if the user is an admin, we try to list all shares created by other admins
-			if strings.HasPrefix(f.GetResourceId().GetStorageId(), projectInstancesPrefix) {
-				res, err := client.Stat(ctx, &provider.StatRequest{Ref: &provider.Reference{ResourceId: f.GetResourceId()}})
-				if err != nil || res.Status.Code != rpc.Code_CODE_OK {
-					continue
-				}
+	return query, params, nil
+}
 
-				// The path will look like /eos/project/c/cernbox, we need to extract the project name
-				parts := strings.SplitN(res.Info.Path, "/", 6)
-				if len(parts) < 5 {
-					continue
-				}
+// projectAdminResources returns the resources filtered by id inside the project
+// spaces the user is an admin of. Every resource is stat'ed at most once.
+func (m *manager) projectAdminResources(ctx context.Context, u *user.User, filters []*link.ListPublicSharesRequest_Filter) ([]*provider.ResourceId, error) {
+	var client gateway.GatewayAPIClient
+	seen := map[string]bool{}
+	admin := map[string]bool{} // project name -> whether the user is an admin
+	resources := []*provider.ResourceId{}
 
-				adminGroup := projectSpaceGroupsPrefix + parts[4] + projectSpaceAdminGroupsSuffix
-				for _, g := range u.Groups {
-					if g == adminGroup {
-						// User belongs to the admin group, list all shares for the resource
-
-						// TODO: this only works if shares for a single project are requested.
-						// If shares for multiple projects are requested, then we're not checking if the
-						// user is an admin for all of those. We can append the query ` or uid_owner=?`
-						// for all the project owners, which works fine for new reva
-						// but won't work for revaold since there, we store the uid of the share creator as uid_owner.
-						// For this to work across the two versions, this change would have to be made in revaold
-						// but it won't be straightforward as there, the storage provider doesn't return the
-						// resource owners.
-						return "", []interface{}{}, nil
-					}
-				}
+	for _, f := range filters {
+		id := f.GetResourceId()
+		if f.Type != link.ListPublicSharesRequest_Filter_TYPE_RESOURCE_ID || !strings.HasPrefix(id.GetStorageId(), projectInstancesPrefix) {
+			continue
+		}
+		key := id.StorageId + "!" + id.OpaqueId
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if client == nil {
+			var err error
+			client, err = pool.GetGatewayServiceClient(ctx, pool.Endpoint(m.c.GatewaySvc))
+			if err != nil {
+				return nil, err
 			}
 		}
+
+		res, err := client.Stat(ctx, &provider.StatRequest{Ref: &provider.Reference{ResourceId: id}})
+		if err != nil || res.Status.Code != rpc.Code_CODE_OK {
+			continue
+		}
+
+		// The path will look like /eos/project/c/cernbox, we need to extract the project name
+		parts := strings.SplitN(res.Info.Path, "/", 6)
+		if len(parts) < 5 {
+			continue
+		}
+
+		project := parts[4]
+		isAdmin, ok := admin[project]
+		if !ok {
+			isAdmin = isGroupMember(u, projectSpaceGroupsPrefix+project+projectSpaceAdminGroupsSuffix)
+			admin[project] = isAdmin
+		}
+		if isAdmin {
+			resources = append(resources, id)
+		}
 	}
 
-	return query, params, nil
+	return resources, nil
+}
+
+func isGroupMember(u *user.User, group string) bool {
+	for _, g := range u.Groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// orderByColumns maps the fields shares can be ordered by to the selected columns.
+// Note that expiration and share_name refer to the coalesced values.
+var orderByColumns = map[string]string{
+	publicshare.OrderByCtime:       "stime",
+	publicshare.OrderByExpiration:  "expiration",
+	publicshare.OrderByDisplayName: "share_name",
+}
+
+// orderBy returns the ORDER BY clause for listing shares, using the ordering
+// from the context or, if not present, the one from the configuration.
+// The id is used as tiebreaker to keep the order deterministic.
+func (m *manager) orderBy(ctx context.Context) (string, error) {
+	order, ok := publicshare.ContextGetListOrder(ctx)
+	if _, inWindow := publicshare.ContextGetExpirationWindow(ctx); !ok && inWindow {
+		// the shares expiring in a window are listed from the first to expire
+		order, ok = &publicshare.ListOrder{By: publicshare.OrderByExpiration}, true
+	}
+	if !ok {
+		if m.c.ListOrderBy == "" {
+			return "", nil
+		}
+		order = &publicshare.ListOrder{By: m.c.ListOrderBy, Descending: m.c.ListOrderDescending}
+	}
+
+	column, ok := orderByColumns[order.By]
+	if !ok {
+		return "", errtypes.BadRequest("invalid order by field " + order.By)
+	}
+	direction := "ASC"
+	if order.Descending {
+		direction = "DESC"
+	}
+
+	if order.By == publicshare.OrderByExpiration {
+		// shares that never expire always come last
+		return fmt.Sprintf("expiration = '', expiration %s, id %s", direction, direction), nil
+	}
+	return fmt.Sprintf("%s %s, id %s", column, direction, direction), nil
+}
+
+// includeInternal returns whether the internal shares are listed, as set
+// in the context or, if not present, in the configuration.
+func (m *manager) includeInternal(ctx context.Context) bool {
+	if include, ok := publicshare.ContextGetIncludeInternal(ctx); ok {
+		return include
+	}
+	return m.c.ListIncludeInternal
 }
 
 func expired(s *link.PublicShare) bool {
@@ -577,6 +1423,79 @@ func expired(s *link.PublicShare) bool {
 	return false
 }
 
+// generateToken returns a random token with the configured length and alphabet,
+// grouped with dashes if human-friendly.
+func (m *manager) generateToken() (string, error) {
+	tkn, err := randutil.String(m.c.TokenLength, m.c.TokenAlphabet)
+	if err != nil || !m.c.HumanFriendlyTokens {
+		return tkn, err
+	}
+
+	groups := make([]string, 0, len(tkn)/humanFriendlyGroupSize+1)
+	for len(tkn) > humanFriendlyGroupSize {
+		groups = append(groups, tkn[:humanFriendlyGroupSize])
+		tkn = tkn[humanFriendlyGroupSize:]
+	}
+	groups = append(groups, tkn)
+	return strings.Join(groups, "-"), nil
+}
+
+// queryRowByToken runs the query selecting a public share by its token, given
+// as the last parameter, and scans the result into dest.
+// With lowercase_tokens, the tokens not found as given are looked up in their
+// canonical lowercase form, as some clients change the case of the tokens;
+// the exact match is tried first, so that the shares created before with a
+// mixed case token are still found.
+func (m *manager) queryRowByToken(query, token string, dest ...interface{}) error {
+	err := m.db.QueryRow(query, m.c.PublicShareType, token).Scan(dest...)
+	if err == sql.ErrNoRows && m.c.LowercaseTokens {
+		if canonical := strings.ToLower(token); canonical != token {
+			err = m.db.QueryRow(query, m.c.PublicShareType, canonical).Scan(dest...)
+		}
+	}
+	return err
+}
+
+// execByToken runs the statement changing a public share with the parameters,
+// the token being the one at the index tokenParam, if not negative.
+// With lowercase_tokens, the statement is run again with the canonical
+// lowercase token if no share was changed, as in queryRowByToken.
+func (m *manager) execByToken(exec func(params ...interface{}) (sql.Result, error), params []interface{}, tokenParam int) (sql.Result, error) {
+	res, err := exec(params...)
+	if err != nil || tokenParam < 0 || !m.c.LowercaseTokens {
+		return res, err
+	}
+	token, _ := params[tokenParam].(string)
+	canonical := strings.ToLower(token)
+	if canonical == token {
+		return res, nil
+	}
+	if rowCnt, err := res.RowsAffected(); err != nil || rowCnt != 0 {
+		return res, nil
+	}
+	canonicalParams := append([]interface{}{}, params...)
+	canonicalParams[tokenParam] = canonical
+	return exec(canonicalParams...)
+}
+
+// lowercaseAlphabet returns the lowercase alphabet, without the duplicate characters.
+func lowercaseAlphabet(alphabet string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(alphabet) {
+		if !strings.ContainsRune(b.String(), r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// isDuplicateEntry checks whether the insertion failed because of a duplicate key.
+// The only unique key set on the insertion of a share is the token.
+func isDuplicateEntry(err error) bool {
+	var e *mysql.MySQLError
+	return errors.As(err, &e) && e.Number == errDuplicateEntry
+}
+
 func hashPassword(password string, cost int) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), cost)
 	return "1|" + string(bytes), err
@@ -587,7 +1506,7 @@ func checkPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-func authenticate(share *link.PublicShare, pw string, auth *link.PublicShareAuthentication) bool {
+func authenticate(share *link.PublicShare, pw string, auth *link.PublicShareAuthentication, keys *publicshare.SigningKeys) bool {
 	switch {
 	case auth.GetPassword() != "":
 		return checkPasswordHash(auth.GetPassword(), pw)
@@ -598,13 +1517,7 @@ func authenticate(share *link.PublicShare, pw string, auth *link.PublicShareAuth
 		if now.After(expiration) {
 			return false
 		}
-		s, err := publicshare.CreateSignature(share.Token, pw, expiration)
-		if err != nil {
-			// TODO(labkode): pass context to call to log err.
-			// No we are blind
-			return false
-		}
-		return sig.GetSignature() == s
+		return keys.Verify(share.Token, pw, sig.GetSignature(), expiration)
 	}
 	return false
 }
@@ -0,0 +1,1797 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package sql
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gateway "github.com/cs3org/go-cs3apis/cs3/gateway/v1beta1"
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/publicshare"
+	"github.com/cs3org/reva/pkg/publicshare/events"
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	"github.com/dolthub/go-mysql-server/server"
+	"github.com/dolthub/go-mysql-server/sql"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/rs/zerolog"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+	"go.uber.org/goleak"
+	"google.golang.org/grpc"
+)
+
+const (
+	dbName     = "reva_tests"
+	shareTable = "oc_share"
+)
+
+var owner = &userpb.User{Id: &userpb.UserId{Idp: "idp", OpaqueId: "einstein"}}
+
+// dbShare is a row of the oc_share table used to initialize the test database.
+type dbShare struct {
+	id          int64
+	token       string
+	name        string
+	stime       int64
+	password    string
+	expiration  *time.Time
+	shareType   int8
+	orphan      bool
+	internal    bool
+	itemSource  string
+	prefix      string
+	owner       string
+	initiator   string
+	description string
+}
+
+func startDatabase(ctx *sql.Context, table *memory.Table) (engine *sqle.Engine, port int, cleanup func()) {
+	db := memory.NewDatabase(dbName)
+	db.AddTable(shareTable, table)
+
+	engine = sqle.NewDefault(memory.NewMemoryDBProvider(db))
+	s, err := server.NewDefaultServer(server.Config{
+		Protocol: "tcp",
+		Address:  "localhost:0",
+	}, engine)
+	if err != nil {
+		panic(err)
+	}
+
+	go func() {
+		if err := s.Start(); err != nil {
+			panic(err)
+		}
+	}()
+	cleanup = func() {
+		if err := s.Close(); err != nil {
+			panic(err)
+		}
+	}
+	return engine, s.Listener.Addr().(*net.TCPAddr).Port, cleanup
+}
+
+func createShareTable(ctx *sql.Context, initData []*dbShare) *memory.Table {
+	table := memory.NewTable(shareTable, sql.NewPrimaryKeySchema(sql.Schema{
+		{Name: "id", Type: sql.Int64, Nullable: false, Source: shareTable, PrimaryKey: true, AutoIncrement: true},
+		{Name: "share_type", Type: sql.Int8, Nullable: false, Source: shareTable},
+		{Name: "share_with", Type: sql.Text, Nullable: true, Source: shareTable},
+		{Name: "uid_owner", Type: sql.Text, Nullable: false, Source: shareTable},
+		{Name: "uid_initiator", Type: sql.Text, Nullable: true, Source: shareTable},
+		{Name: "item_type", Type: sql.Text, Nullable: false, Source: shareTable},
+		{Name: "fileid_prefix", Type: sql.Text, Nullable: true, Source: shareTable},
+		{Name: "item_source", Type: sql.Text, Nullable: true, Source: shareTable},
+		{Name: "file_source", Type: sql.Int64, Nullable: true, Source: shareTable},
+		{Name: "permissions", Type: sql.Int8, Nullable: false, Source: shareTable},
+		{Name: "stime", Type: sql.Int64, Nullable: false, Source: shareTable},
+		{Name: "token", Type: sql.Text, Nullable: true, Source: shareTable},
+		// the go-mysql-server engine does not coalesce datetime values to strings
+		{Name: "expiration", Type: sql.Text, Nullable: true, Source: shareTable},
+		{Name: "share_name", Type: sql.Text, Nullable: true, Source: shareTable},
+		{Name: "quicklink", Type: sql.Boolean, Nullable: false, Source: shareTable},
+		{Name: "description", Type: sql.Text, Nullable: false, Source: shareTable},
+		{Name: "internal", Type: sql.Boolean, Nullable: false, Source: shareTable},
+		{Name: "orphan", Type: sql.Boolean, Nullable: true, Source: shareTable},
+		{Name: "last_accessed", Type: sql.Int64, Nullable: true, Source: shareTable},
+	}), &memory.ForeignKeyCollection{})
+	// the tokens are expected to be unique
+	must(table.CreateIndex(ctx, "token", sql.IndexUsing_BTree, sql.IndexConstraint_Unique, []sql.IndexColumn{{Name: "token"}}, ""))
+
+	for _, s := range initData {
+		var password, expiration interface{}
+		if s.password != "" {
+			password = s.password
+		}
+		if s.expiration != nil {
+			expiration = s.expiration.Format("2006-01-02 15:04:05")
+		}
+		shareType := s.shareType
+		if shareType == 0 {
+			shareType = defaultPublicShareType
+		}
+		itemSource := s.itemSource
+		if itemSource == "" {
+			itemSource = "10"
+		}
+		prefix := s.prefix
+		if prefix == "" {
+			prefix = "storage"
+		}
+		uidOwner := s.owner
+		if uidOwner == "" {
+			uidOwner = owner.Id.OpaqueId
+		}
+		uidInitiator := s.initiator
+		if uidInitiator == "" {
+			uidInitiator = uidOwner
+		}
+		must(table.Insert(ctx, sql.NewRow(s.id, shareType, password, uidOwner, uidInitiator, "folder", prefix, itemSource, int64(10), int8(1), s.stime, s.token, expiration, s.name, int8(0), s.description, boolToInt8(s.internal), boolToInt8(s.orphan), nil)))
+	}
+	return table
+}
+
+func boolToInt8(b bool) int8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+func newTestManager(t *testing.T, shares []*dbShare, conf map[string]interface{}) (*manager, *sqle.Engine) {
+	ctx := sql.NewEmptyContext()
+	engine, port, cleanup := startDatabase(ctx, createShareTable(ctx, shares))
+	t.Cleanup(cleanup)
+
+	c := map[string]interface{}{
+		"db_username": "root",
+		"db_password": "",
+		"db_host":     "localhost",
+		"db_port":     port,
+		"db_name":     dbName,
+		"gatewaysvc":  "localhost:19000",
+	}
+	for k, v := range conf {
+		c[k] = v
+	}
+	m, err := New(c)
+	if err != nil {
+		t.Fatalf("not expected error while creating public share manager: %+v", err)
+	}
+	return m.(*manager), engine
+}
+
+func ids(shares []*link.PublicShare) []string {
+	l := make([]string, 0, len(shares))
+	for _, s := range shares {
+		l = append(l, s.Id.OpaqueId)
+	}
+	return l
+}
+
+func TestListPublicSharesOrder(t *testing.T) {
+	in := func(d time.Duration) *time.Time {
+		t := time.Now().Add(d).UTC().Truncate(time.Second)
+		return &t
+	}
+	shares := []*dbShare{
+		{id: 1, token: "a", name: "charlie", stime: 300, expiration: in(48 * time.Hour)},
+		{id: 2, token: "b", name: "alpha", stime: 100, password: "1|hash"},
+		{id: 3, token: "c", name: "bravo", stime: 200, password: "1|hash", expiration: in(24 * time.Hour)},
+		{id: 4, token: "d", name: "alpha", stime: 200},
+	}
+
+	tests := []struct {
+		description string
+		conf        map[string]interface{}
+		order       *publicshare.ListOrder
+		expected    []string
+	}{
+		{
+			description: "by ctime",
+			order:       &publicshare.ListOrder{By: publicshare.OrderByCtime},
+			expected:    []string{"2", "3", "4", "1"},
+		},
+		{
+			description: "by ctime descending",
+			order:       &publicshare.ListOrder{By: publicshare.OrderByCtime, Descending: true},
+			expected:    []string{"1", "4", "3", "2"},
+		},
+		{
+			description: "by expiration",
+			order:       &publicshare.ListOrder{By: publicshare.OrderByExpiration},
+			expected:    []string{"3", "1", "2", "4"},
+		},
+		{
+			description: "by display name",
+			order:       &publicshare.ListOrder{By: publicshare.OrderByDisplayName},
+			expected:    []string{"2", "4", "3", "1"},
+		},
+		{
+			description: "by configured default",
+			conf:        map[string]interface{}{"list_order_by": publicshare.OrderByDisplayName, "list_order_descending": true},
+			expected:    []string{"1", "3", "4", "2"},
+		},
+		{
+			description: "request order overrides the configured default",
+			conf:        map[string]interface{}{"list_order_by": publicshare.OrderByDisplayName},
+			order:       &publicshare.ListOrder{By: publicshare.OrderByCtime, Descending: true},
+			expected:    []string{"1", "4", "3", "2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			m, _ := newTestManager(t, shares, tt.conf)
+
+			ctx := context.Background()
+			if tt.order != nil {
+				ctx = publicshare.ContextSetListOrder(ctx, tt.order)
+			}
+			got, err := m.ListPublicShares(ctx, owner, nil, nil, false)
+			if err != nil {
+				t.Fatalf("not expected error while listing shares: %+v", err)
+			}
+
+			if !reflect.DeepEqual(ids(got), tt.expected) {
+				t.Fatalf("shares are not in the expected order. got=%v expected=%v", ids(got), tt.expected)
+			}
+		})
+	}
+}
+
+func TestListPublicSharesInvalidOrder(t *testing.T) {
+	m, _ := newTestManager(t, nil, nil)
+
+	ctx := publicshare.ContextSetListOrder(context.Background(), &publicshare.ListOrder{By: "owner"})
+	_, err := m.ListPublicShares(ctx, owner, nil, nil, false)
+	if _, ok := err.(errtypes.IsBadRequest); !ok {
+		t.Fatalf("expected bad request error, got %+v", err)
+	}
+}
+
+func TestDeleteOrphanedShares(t *testing.T) {
+	ago := func(d time.Duration) *time.Time {
+		t := time.Now().Add(-d).UTC()
+		return &t
+	}
+	shares := []*dbShare{
+		{id: 1, token: "a", stime: 100, orphan: true, expiration: ago(30 * 24 * time.Hour)},
+		{id: 2, token: "b", stime: 100, orphan: true, expiration: ago(20 * 24 * time.Hour)},
+		{id: 3, token: "c", stime: 100, orphan: true, expiration: ago(2 * 24 * time.Hour)},
+		{id: 4, token: "d", stime: 100, expiration: ago(30 * 24 * time.Hour)},
+		{id: 5, token: "e", stime: 100, orphan: true, expiration: ago(30 * 24 * time.Hour), shareType: 1},
+		{id: 6, token: "f", stime: 100},
+	}
+
+	m, engine := newTestManager(t, shares, map[string]interface{}{
+		"enable_expired_shares_cleanup": true,
+		"orphan_retention_days":         7,
+		"janitor_batch_size":            1,
+		"janitor_run_interval":          3600,
+	})
+
+	n, err := m.deleteOrphanedShares()
+	if err != nil {
+		t.Fatalf("not expected error while deleting orphaned shares: %+v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 deleted shares, got %d", n)
+	}
+
+	ctx := sql.NewEmptyContext()
+	_, _, err = engine.Query(ctx, "USE "+dbName)
+	if err != nil {
+		t.Fatalf("not expected error while using the database: %+v", err)
+	}
+	_, iter, err := engine.Query(ctx, "SELECT id FROM oc_share ORDER BY id")
+	if err != nil {
+		t.Fatalf("not expected error while querying the shares: %+v", err)
+	}
+	rows, err := sql.RowIterToRows(ctx, nil, iter)
+	if err != nil {
+		t.Fatalf("not expected error while reading the shares: %+v", err)
+	}
+	got := []int64{}
+	for _, r := range rows {
+		got = append(got, r[0].(int64))
+	}
+	if !reflect.DeepEqual(got, []int64{3, 4, 5, 6}) {
+		t.Fatalf("unexpected remaining shares. got=%v expected=%v", got, []int64{3, 4, 5, 6})
+	}
+}
+
+// fakeGateway is a gateway resolving the paths of the resources
+// and counting the stat requests it receives.
+type fakeGateway struct {
+	gateway.UnimplementedGatewayAPIServer
+	paths map[string]string // opaque id -> path
+
+	mu    sync.Mutex
+	stats int
+}
+
+func (g *fakeGateway) Stat(ctx context.Context, req *provider.StatRequest) (*provider.StatResponse, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.stats++
+
+	path, ok := g.paths[req.Ref.ResourceId.OpaqueId]
+	if !ok {
+		return &provider.StatResponse{Status: &rpc.Status{Code: rpc.Code_CODE_NOT_FOUND}}, nil
+	}
+	return &provider.StatResponse{
+		Status: &rpc.Status{Code: rpc.Code_CODE_OK},
+		Info:   &provider.ResourceInfo{Id: req.Ref.ResourceId, Path: path},
+	}, nil
+}
+
+func startGateway(t *testing.T, g *fakeGateway) string {
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("not expected error while listening: %+v", err)
+	}
+	s := grpc.NewServer()
+	gateway.RegisterGatewayAPIServer(s, g)
+	go func() { _ = s.Serve(l) }()
+	t.Cleanup(s.Stop)
+	return l.Addr().String()
+}
+
+func TestListPublicSharesProjectAdmins(t *testing.T) {
+	g := &fakeGateway{paths: map[string]string{
+		"10": "/eos/project/a/alpha/docs",
+		"11": "/eos/project/a/alpha/data",
+		"20": "/eos/project/b/bravo/docs",
+	}}
+	addr := startGateway(t, g)
+
+	shares := []*dbShare{
+		{id: 1, token: "a", stime: 100, prefix: "newproject-a", itemSource: "10"},
+		{id: 2, token: "b", stime: 100, prefix: "newproject-a", itemSource: "10", owner: "marie"},
+		{id: 3, token: "c", stime: 100, prefix: "newproject-a", itemSource: "11", owner: "marie"},
+		{id: 4, token: "d", stime: 100, prefix: "newproject-b", itemSource: "20"},
+		{id: 5, token: "e", stime: 100, prefix: "newproject-b", itemSource: "20", owner: "marie"},
+		{id: 6, token: "f", stime: 100, prefix: "newproject-c", itemSource: "30", owner: "marie"},
+	}
+	m, _ := newTestManager(t, shares, map[string]interface{}{
+		"gatewaysvc":    addr,
+		"list_order_by": publicshare.OrderByCtime,
+	})
+
+	filter := func(storageID, opaqueID string) *link.ListPublicSharesRequest_Filter {
+		return &link.ListPublicSharesRequest_Filter{
+			Type: link.ListPublicSharesRequest_Filter_TYPE_RESOURCE_ID,
+			Term: &link.ListPublicSharesRequest_Filter_ResourceId{
+				ResourceId: &provider.ResourceId{StorageId: storageID, OpaqueId: opaqueID},
+			},
+		}
+	}
+	filters := []*link.ListPublicSharesRequest_Filter{
+		filter("newproject-a", "10"),
+		filter("newproject-b", "20"),
+		filter("newproject-a", "11"),
+		filter("newproject-a", "10"),
+		filter("newproject-c", "30"),
+	}
+
+	// the user is an admin of the project a, but not of the project b
+	u := &userpb.User{Id: owner.Id, Groups: []string{"cernbox-project-alpha-admins", "cernbox-project-bravo-writers"}}
+	got, err := m.ListPublicShares(context.Background(), u, filters, nil, false)
+	if err != nil {
+		t.Fatalf("not expected error while listing shares: %+v", err)
+	}
+
+	expected := []string{"1", "2", "3", "4"}
+	if !reflect.DeepEqual(ids(got), expected) {
+		t.Fatalf("unexpected shares. got=%v expected=%v", ids(got), expected)
+	}
+	if g.stats != 4 {
+		t.Fatalf("expected every resource to be stat'ed once, got %d stat requests", g.stats)
+	}
+}
+
+func TestListInternalPublicShares(t *testing.T) {
+	ctx := context.Background()
+	resourceFilter := []*link.ListPublicSharesRequest_Filter{
+		publicshare.ResourceIDFilter(&provider.ResourceId{StorageId: "storage", OpaqueId: "10"}),
+	}
+
+	tests := []struct {
+		description string
+		conf        map[string]interface{}
+		ctx         context.Context
+		filters     []*link.ListPublicSharesRequest_Filter
+		internal    bool
+	}{
+		{
+			description: "internal shares excluded by default",
+			ctx:         ctx,
+		},
+		{
+			description: "internal shares included from the context",
+			ctx:         publicshare.ContextSetIncludeInternal(ctx, true),
+			internal:    true,
+		},
+		{
+			description: "internal shares included from the configuration",
+			conf:        map[string]interface{}{"list_include_internal": true},
+			ctx:         ctx,
+			internal:    true,
+		},
+		{
+			description: "context takes precedence over the configuration",
+			conf:        map[string]interface{}{"list_include_internal": true},
+			ctx:         publicshare.ContextSetIncludeInternal(ctx, false),
+		},
+		{
+			description: "internal shares included with other filters",
+			ctx:         publicshare.ContextSetIncludeInternal(ctx, true),
+			filters:     resourceFilter,
+			internal:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			m, _ := newTestManager(t, nil, tt.conf)
+			normal, err := m.CreatePublicShare(ctx, owner, newResourceInfo("10"), viewerGrant, "", false)
+			if err != nil {
+				t.Fatalf("not expected error while creating share: %+v", err)
+			}
+			internal, err := m.CreatePublicShare(ctx, owner, newResourceInfo("10"), viewerGrant, "", true)
+			if err != nil {
+				t.Fatalf("not expected error while creating share: %+v", err)
+			}
+			// not matching the resource filter
+			other, err := m.CreatePublicShare(ctx, owner, newResourceInfo("20"), viewerGrant, "", true)
+			if err != nil {
+				t.Fatalf("not expected error while creating share: %+v", err)
+			}
+
+			expected := []string{normal.Id.OpaqueId}
+			if tt.internal {
+				expected = append(expected, internal.Id.OpaqueId)
+				if tt.filters == nil {
+					expected = append(expected, other.Id.OpaqueId)
+				}
+			}
+
+			shares, err := m.ListPublicShares(tt.ctx, owner, tt.filters, nil, false)
+			if err != nil {
+				t.Fatalf("not expected error while listing shares: %+v", err)
+			}
+			if got := ids(shares); !reflect.DeepEqual(got, expected) {
+				t.Fatalf("expected shares %v, got %v", expected, got)
+			}
+			count, err := m.CountPublicShares(tt.ctx, owner, tt.filters)
+			if err != nil {
+				t.Fatalf("not expected error while counting shares: %+v", err)
+			}
+			if count != len(expected) {
+				t.Fatalf("expected %d shares, got %d", len(expected), count)
+			}
+		})
+	}
+}
+
+func TestCountPublicShares(t *testing.T) {
+	past := time.Now().Add(-24 * time.Hour).UTC()
+	shares := []*dbShare{
+		{id: 1, token: "a", itemSource: "10"},
+		{id: 2, token: "b", itemSource: "10", initiator: "marie"},
+		{id: 3, token: "c", itemSource: "20", initiator: "marie"},
+		{id: 4, token: "d", itemSource: "20", owner: "marie", initiator: "einstein"},
+		{id: 5, token: "e", itemSource: "30", owner: "marie"},
+		// not visible in the listings
+		{id: 6, token: "f", itemSource: "10", expiration: &past},
+		{id: 7, token: "g", itemSource: "10", orphan: true},
+		{id: 8, token: "h", itemSource: "10", internal: true},
+	}
+	m, _ := newTestManager(t, shares, nil)
+
+	ownerFilter := func(id string) *link.ListPublicSharesRequest_Filter {
+		return &link.ListPublicSharesRequest_Filter{
+			Type: link.ListPublicSharesRequest_Filter_TYPE_OWNER,
+			Term: &link.ListPublicSharesRequest_Filter_Owner{Owner: &userpb.UserId{OpaqueId: id}},
+		}
+	}
+	creatorFilter := func(id string) *link.ListPublicSharesRequest_Filter {
+		return &link.ListPublicSharesRequest_Filter{
+			Type: link.ListPublicSharesRequest_Filter_TYPE_CREATOR,
+			Term: &link.ListPublicSharesRequest_Filter_Creator{Creator: &userpb.UserId{OpaqueId: id}},
+		}
+	}
+	resourceFilter := func(opaqueID string) *link.ListPublicSharesRequest_Filter {
+		return &link.ListPublicSharesRequest_Filter{
+			Type: link.ListPublicSharesRequest_Filter_TYPE_RESOURCE_ID,
+			Term: &link.ListPublicSharesRequest_Filter_ResourceId{
+				ResourceId: &provider.ResourceId{StorageId: "storage", OpaqueId: opaqueID},
+			},
+		}
+	}
+
+	tests := []struct {
+		description string
+		filters     []*link.ListPublicSharesRequest_Filter
+		expected    int
+	}{
+		{
+			description: "no filters",
+			expected:    4,
+		},
+		{
+			description: "owner",
+			filters:     []*link.ListPublicSharesRequest_Filter{ownerFilter("einstein")},
+			expected:    3,
+		},
+		{
+			description: "creator",
+			filters:     []*link.ListPublicSharesRequest_Filter{creatorFilter("marie")},
+			expected:    2,
+		},
+		{
+			description: "resource",
+			filters:     []*link.ListPublicSharesRequest_Filter{resourceFilter("10")},
+			expected:    2,
+		},
+		{
+			description: "multiple resources",
+			filters:     []*link.ListPublicSharesRequest_Filter{resourceFilter("20"), resourceFilter("30")},
+			expected:    2,
+		},
+		{
+			description: "owner and creator",
+			filters:     []*link.ListPublicSharesRequest_Filter{ownerFilter("einstein"), creatorFilter("marie")},
+			expected:    2,
+		},
+		{
+			description: "creator and resource",
+			filters:     []*link.ListPublicSharesRequest_Filter{creatorFilter("marie"), resourceFilter("20")},
+			expected:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			count, err := m.CountPublicShares(context.Background(), owner, tt.filters)
+			if err != nil {
+				t.Fatalf("not expected error while counting shares: %+v", err)
+			}
+			if count != tt.expected {
+				t.Fatalf("unexpected count of shares. got=%d expected=%d", count, tt.expected)
+			}
+
+			list, err := m.ListPublicShares(context.Background(), owner, tt.filters, nil, false)
+			if err != nil {
+				t.Fatalf("not expected error while listing shares: %+v", err)
+			}
+			if count != len(list) {
+				t.Fatalf("count does not match the listed shares. count=%d listed=%v", count, ids(list))
+			}
+		})
+	}
+}
+
+func TestTrackLastAccessed(t *testing.T) {
+	hash, err := hashPassword("secret", 4)
+	if err != nil {
+		t.Fatalf("error hashing password: %v", err)
+	}
+	shares := []*dbShare{
+		{id: 1, token: "a", name: "alpha", stime: 100},
+		{id: 2, token: "b", name: "bravo", stime: 100, password: hash},
+		{id: 3, token: "c", name: "charlie", stime: 100},
+	}
+	ctx := context.Background()
+	lastAccessed := func(m *manager) map[string]time.Time {
+		accessed, err := m.LastAccessed(ctx, []string{"1", "2", "3"})
+		if err != nil {
+			t.Fatalf("not expected error while getting the last access times: %+v", err)
+		}
+		return accessed
+	}
+
+	// the accesses are not tracked by default
+	m, _ := newTestManager(t, shares, nil)
+	if _, err := m.GetPublicShareByToken(ctx, "a", nil, false); err != nil {
+		t.Fatalf("not expected error while getting share by token: %+v", err)
+	}
+	if accessed := lastAccessed(m); len(accessed) != 0 {
+		t.Fatalf("expected no access to be tracked, got %v", accessed)
+	}
+
+	m, _ = newTestManager(t, shares, map[string]interface{}{"track_last_accessed": true})
+	if accessed := lastAccessed(m); len(accessed) != 0 {
+		t.Fatalf("expected no access to be tracked, got %v", accessed)
+	}
+
+	before := time.Now().Truncate(time.Second)
+	if _, err := m.GetPublicShareByToken(ctx, "a", nil, false); err != nil {
+		t.Fatalf("not expected error while getting share by token: %+v", err)
+	}
+	// failed authentications are not accesses
+	_, err = m.GetPublicShareByToken(ctx, "b", &link.PublicShareAuthentication{Spec: &link.PublicShareAuthentication_Password{Password: "wrong"}}, false)
+	if _, ok := err.(errtypes.InvalidCredentials); !ok {
+		t.Fatalf("expected invalid credentials error, got %+v", err)
+	}
+	if _, err := m.GetPublicShareByToken(ctx, "c", nil, false); err != nil {
+		t.Fatalf("not expected error while getting share by token: %+v", err)
+	}
+
+	// the updates are asynchronous
+	var accessed map[string]time.Time
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if accessed = lastAccessed(m); len(accessed) == 2 {
+			break
+		}
+	}
+	if _, ok := accessed["1"]; !ok {
+		t.Fatalf("expected access to share 1 to be tracked, got %v", accessed)
+	}
+	if _, ok := accessed["3"]; !ok {
+		t.Fatalf("expected access to share 3 to be tracked, got %v", accessed)
+	}
+	if _, ok := accessed["2"]; ok {
+		t.Fatalf("expected failed access to share 2 not to be tracked")
+	}
+	if accessed["1"].Before(before) {
+		t.Fatalf("expected last access after %v, got %v", before, accessed["1"])
+	}
+}
+
+func TestCreatePublicShareTokenCollision(t *testing.T) {
+	shares := []*dbShare{{id: 1, token: "taken", name: "alpha", stime: 100}}
+	tokens := func(m *manager, l ...string) *int {
+		var calls int
+		m.newToken = func() (string, error) {
+			tkn := l[len(l)-1]
+			if calls < len(l) {
+				tkn = l[calls]
+			}
+			calls++
+			return tkn, nil
+		}
+		return &calls
+	}
+
+	m, _ := newTestManager(t, shares, nil)
+	calls := tokens(m, "taken", "fresh")
+	s, err := m.CreatePublicShare(context.Background(), owner, newResourceInfo("10"), viewerGrant, "", false)
+	if err != nil {
+		t.Fatalf("not expected error while creating share: %+v", err)
+	}
+	if s.Token != "fresh" || s.DisplayName != "fresh" || *calls != 2 {
+		t.Fatalf("expected the share to be created with a fresh token after a collision, got %q after %d attempts", s.Token, *calls)
+	}
+	if got, err := m.GetPublicShareByToken(context.Background(), "taken", nil, false); err != nil || got.Id.OpaqueId != "1" {
+		t.Fatalf("expected the existing share to keep its token, got %+v %+v", got, err)
+	}
+
+	// the creation fails when the retries are exhausted
+	m, _ = newTestManager(t, shares, map[string]interface{}{"token_retries": 2})
+	calls = tokens(m, "taken")
+	if _, err := m.CreatePublicShare(context.Background(), owner, newResourceInfo("10"), viewerGrant, "", false); !isDuplicateEntry(err) {
+		t.Fatalf("expected duplicate entry error, got %+v", err)
+	}
+	if *calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", *calls)
+	}
+}
+
+func TestGenerateToken(t *testing.T) {
+	tests := []struct {
+		description string
+		conf        map[string]interface{}
+		pattern     string
+	}{
+		{
+			description: "default",
+			pattern:     "^[0-9A-Za-z]{15}$",
+		},
+		{
+			description: "custom length and alphabet",
+			conf:        map[string]interface{}{"token_length": 32, "token_alphabet": "abcdef-_"},
+			pattern:     "^[a-f_-]{32}$",
+		},
+		{
+			description: "human friendly",
+			conf:        map[string]interface{}{"human_friendly_tokens": true, "token_length": 12},
+			pattern:     "^[2-9a-km-z]{5}-[2-9a-km-z]{5}-[2-9a-km-z]{2}$",
+		},
+		{
+			description: "lowercase",
+			conf:        map[string]interface{}{"lowercase_tokens": true},
+			pattern:     "^[0-9a-z]{15}$",
+		},
+		{
+			description: "lowercase custom alphabet",
+			conf:        map[string]interface{}{"lowercase_tokens": true, "token_alphabet": "ABCabc"},
+			pattern:     "^[a-c]{15}$",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			m, _ := newTestManager(t, nil, tt.conf)
+			tkn, err := m.generateToken()
+			if err != nil {
+				t.Fatalf("not expected error while generating token: %+v", err)
+			}
+			if !regexp.MustCompile(tt.pattern).MatchString(tkn) {
+				t.Fatalf("expected token matching %s, got %s", tt.pattern, tkn)
+			}
+		})
+	}
+}
+
+func TestLowercaseTokens(t *testing.T) {
+	shares := []*dbShare{
+		{id: 1, token: "lowercase", name: "alpha", stime: 100},
+		{id: 2, token: "MixedCase", name: "bravo", stime: 100},
+	}
+	ctx := context.Background()
+	getByToken := func(m *manager, token string) (string, error) {
+		s, err := m.GetPublicShareByToken(ctx, token, nil, false)
+		if err != nil {
+			return "", err
+		}
+		ref := &link.PublicShareReference{Spec: &link.PublicShareReference_Token{Token: token}}
+		owned, err := m.GetPublicShare(ctx, owner, ref, false)
+		if err != nil {
+			return "", err
+		}
+		if owned.Id.OpaqueId != s.Id.OpaqueId {
+			t.Fatalf("expected the same share by token and by reference, got %s and %s", s.Id.OpaqueId, owned.Id.OpaqueId)
+		}
+		return s.Id.OpaqueId, nil
+	}
+
+	// the tokens are matched exactly by default
+	m, _ := newTestManager(t, shares, nil)
+	if _, err := getByToken(m, "LowerCase"); !isNotFound(err) {
+		t.Fatalf("expected not found error, got %+v", err)
+	}
+
+	m, _ = newTestManager(t, shares, map[string]interface{}{"lowercase_tokens": true})
+	tests := []struct {
+		token    string
+		expected string
+	}{
+		{token: "lowercase", expected: "1"},
+		{token: "LOWERCASE", expected: "1"},
+		{token: "LowerCase", expected: "1"},
+		// the tokens created before with mixed case are still found,
+		// but only in their original case
+		{token: "MixedCase", expected: "2"},
+		{token: "MIXEDCASE"},
+		{token: "mixedcase"},
+	}
+	for _, tt := range tests {
+		id, err := getByToken(m, tt.token)
+		if tt.expected == "" {
+			if !isNotFound(err) {
+				t.Fatalf("expected not found error for token %s, got %+v", tt.token, err)
+			}
+			continue
+		}
+		if err != nil || id != tt.expected {
+			t.Fatalf("expected share %s for token %s, got %s %+v", tt.expected, tt.token, id, err)
+		}
+	}
+}
+
+func TestLowercaseTokensUpdateRevoke(t *testing.T) {
+	shares := []*dbShare{
+		{id: 1, token: "lowercase", name: "alpha", stime: 100},
+		{id: 2, token: "MixedCase", name: "bravo", stime: 100},
+	}
+	ctx := context.Background()
+	tokenRef := func(token string) *link.PublicShareReference {
+		return &link.PublicShareReference{Spec: &link.PublicShareReference_Token{Token: token}}
+	}
+	rename := func(m *manager, token, name string) (*link.PublicShare, error) {
+		return m.UpdatePublicShare(ctx, owner, &link.UpdatePublicShareRequest{
+			Ref:    tokenRef(token),
+			Update: &link.UpdatePublicShareRequest_Update{Type: link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME, DisplayName: name},
+		}, nil)
+	}
+
+	m, _ := newTestManager(t, shares, map[string]interface{}{"lowercase_tokens": true})
+	s, err := rename(m, "LowerCase", "renamed")
+	if err != nil {
+		t.Fatalf("not expected error while updating share by token: %+v", err)
+	}
+	if s.Id.OpaqueId != "1" || s.DisplayName != "renamed" {
+		t.Fatalf("expected share 1 to be renamed, got %+v", s)
+	}
+	// the tokens created before with mixed case are only changed in their original case
+	if _, err := rename(m, "mixedcase", "renamed"); !isNotFound(err) {
+		t.Fatalf("expected not found error, got %+v", err)
+	}
+	if s, err := rename(m, "MixedCase", "renamed"); err != nil || s.Id.OpaqueId != "2" {
+		t.Fatalf("expected share 2 to be renamed, got %+v %+v", s, err)
+	}
+
+	if err := m.RevokePublicShare(ctx, owner, tokenRef("LOWERCASE")); err != nil {
+		t.Fatalf("not expected error while revoking share by token: %+v", err)
+	}
+	if _, err := m.GetPublicShareByToken(ctx, "lowercase", nil, false); !isNotFound(err) {
+		t.Fatalf("expected not found error after revoking the share, got %+v", err)
+	}
+	if err := m.RevokePublicShare(ctx, owner, tokenRef("mixedcase")); !isNotFound(err) {
+		t.Fatalf("expected not found error, got %+v", err)
+	}
+
+	// the tokens are matched exactly by default
+	m, _ = newTestManager(t, shares, nil)
+	if err := m.RevokePublicShare(ctx, owner, tokenRef("LOWERCASE")); !isNotFound(err) {
+		t.Fatalf("expected not found error, got %+v", err)
+	}
+}
+
+func TestNotFoundCache(t *testing.T) {
+	ctx := context.Background()
+	insert := func(m *manager, token string) {
+		if _, err := m.db.Exec("insert into oc_share set share_type=3,uid_owner='einstein',item_type='file',permissions=1,stime=100,token=?,quicklink=0,description='',internal=0", token); err != nil {
+			t.Fatalf("not expected error while inserting share: %+v", err)
+		}
+	}
+
+	// the tokens not found are looked up again by default
+	m, _ := newTestManager(t, nil, nil)
+	if _, err := m.GetPublicShareByToken(ctx, "guessed", nil, false); !isNotFound(err) {
+		t.Fatalf("expected not found error, got %+v", err)
+	}
+	insert(m, "guessed")
+	if _, err := m.GetPublicShareByToken(ctx, "guessed", nil, false); err != nil {
+		t.Fatalf("not expected error while getting share by token: %+v", err)
+	}
+
+	m, _ = newTestManager(t, nil, map[string]interface{}{"enable_not_found_cache": true})
+	if _, err := m.GetPublicShareByToken(ctx, "guessed", nil, false); !isNotFound(err) {
+		t.Fatalf("expected not found error, got %+v", err)
+	}
+	insert(m, "guessed")
+	if _, err := m.GetPublicShareByToken(ctx, "guessed", nil, false); !isNotFound(err) {
+		t.Fatalf("expected not found error from the cache, got %+v", err)
+	}
+	if total, cached := m.notFound.total.Load(), m.notFound.cached.Load(); total != 2 || cached != 1 {
+		t.Fatalf("expected 2 lookups not found, 1 from the cache, got %d and %d", total, cached)
+	}
+
+	// the token of a new share is evicted from the cache
+	if _, err := m.GetPublicShareByToken(ctx, "created", nil, false); !isNotFound(err) {
+		t.Fatalf("expected not found error, got %+v", err)
+	}
+	m.newToken = func() (string, error) { return "created", nil }
+	if _, err := m.CreatePublicShare(ctx, owner, newResourceInfo("10"), viewerGrant, "", false); err != nil {
+		t.Fatalf("not expected error while creating share: %+v", err)
+	}
+	if _, err := m.GetPublicShareByToken(ctx, "created", nil, false); err != nil {
+		t.Fatalf("not expected error while getting share by token: %+v", err)
+	}
+
+	// the entries expire
+	m, _ = newTestManager(t, nil, map[string]interface{}{"enable_not_found_cache": true, "not_found_cache_ttl": 1})
+	if _, err := m.GetPublicShareByToken(ctx, "guessed", nil, false); !isNotFound(err) {
+		t.Fatalf("expected not found error, got %+v", err)
+	}
+	insert(m, "guessed")
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := m.GetPublicShareByToken(ctx, "guessed", nil, false); err != nil {
+		t.Fatalf("not expected error while getting share by token: %+v", err)
+	}
+}
+
+func TestNotFoundCacheLowercaseTokens(t *testing.T) {
+	c := newNotFoundCache(10, time.Minute, true)
+	c.add("ABC", c.generation())
+	c.add("Abc", c.generation())
+	c.add("other", c.generation())
+	c.evict("abc")
+	if c.has("ABC") || c.has("Abc") || !c.has("other") {
+		t.Fatal("expected all the cases of the token of the new share to be evicted")
+	}
+
+	// a token looked up while a share is created is not remembered
+	generation := c.generation()
+	c.evict("new")
+	c.add("new", generation)
+	if c.has("new") {
+		t.Fatal("expected the token not to be remembered")
+	}
+}
+
+func isNotFound(err error) bool {
+	_, ok := err.(errtypes.NotFound)
+	return ok
+}
+
+func TestLogSlowQuery(t *testing.T) {
+	m, _ := newTestManager(t, []*dbShare{{id: 1, token: "a", name: "alpha", stime: 100}}, map[string]interface{}{"slow_query_threshold": 100})
+
+	var buf bytes.Buffer
+	l := zerolog.New(&buf)
+	ctx := appctx.WithLogger(context.Background(), &l)
+
+	// queries below the threshold are not logged
+	if _, err := m.ListPublicShares(ctx, owner, nil, nil, false); err != nil {
+		t.Fatalf("not expected error while listing shares: %+v", err)
+	}
+	m.logSlowQuery(ctx, queryGetByToken, "select id from oc_share where token=?", 99*time.Millisecond)
+	if buf.Len() != 0 {
+		t.Fatalf("expected nothing to be logged, got %s", buf.String())
+	}
+
+	m.logSlowQuery(ctx, queryGetByToken, "select id from oc_share where token=?", 150*time.Millisecond)
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single json log entry, got %s", buf.String())
+	}
+	if entry["level"] != "warn" || entry["query"] != "select id from oc_share where token=?" || entry["duration"] != float64(150) {
+		t.Fatalf("unexpected log entry %v", entry)
+	}
+}
+
+func newResourceInfo(itemSource string) *provider.ResourceInfo {
+	return &provider.ResourceInfo{
+		Id:                &provider.ResourceId{StorageId: "storage", OpaqueId: itemSource},
+		Owner:             owner.Id,
+		Type:              provider.ResourceType_RESOURCE_TYPE_CONTAINER,
+		ArbitraryMetadata: &provider.ArbitraryMetadata{Metadata: map[string]string{}},
+	}
+}
+
+var viewerGrant = &link.Grant{
+	Permissions: &link.PublicSharePermissions{
+		Permissions: &provider.ResourcePermissions{Stat: true, ListContainer: true, InitiateFileDownload: true},
+	},
+}
+
+func TestCreatePublicShareLimits(t *testing.T) {
+	past := time.Now().Add(-24 * time.Hour).UTC()
+	shares := []*dbShare{
+		{id: 1, token: "a", itemSource: "10"},
+		{id: 2, token: "b", itemSource: "10"},
+		{id: 3, token: "c", itemSource: "20"},
+		// expired, orphaned and internal shares are not counted
+		{id: 4, token: "d", itemSource: "10", expiration: &past},
+		{id: 5, token: "e", itemSource: "10", orphan: true},
+		{id: 6, token: "f", itemSource: "10", internal: true},
+	}
+
+	tests := []struct {
+		description string
+		conf        map[string]interface{}
+		itemSource  string
+		internal    bool
+		denied      bool
+	}{
+		{
+			description: "no limits",
+			itemSource:  "10",
+		},
+		{
+			description: "below the user limit",
+			conf:        map[string]interface{}{"max_shares_per_user": 4},
+			itemSource:  "30",
+		},
+		{
+			description: "user limit reached",
+			conf:        map[string]interface{}{"max_shares_per_user": 3},
+			itemSource:  "30",
+			denied:      true,
+		},
+		{
+			description: "below the resource limit",
+			conf:        map[string]interface{}{"max_shares_per_resource": 2},
+			itemSource:  "20",
+		},
+		{
+			description: "resource limit reached",
+			conf:        map[string]interface{}{"max_shares_per_resource": 2},
+			itemSource:  "10",
+			denied:      true,
+		},
+		{
+			description: "internal shares are not limited",
+			conf:        map[string]interface{}{"max_shares_per_user": 1, "max_shares_per_resource": 1},
+			itemSource:  "10",
+			internal:    true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			m, _ := newTestManager(t, shares, tt.conf)
+
+			s, err := m.CreatePublicShare(context.Background(), owner, newResourceInfo(tt.itemSource), viewerGrant, "", tt.internal)
+			if tt.denied {
+				if _, ok := err.(errtypes.PermissionDenied); !ok {
+					t.Fatalf("expected permission denied error, got %+v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("not expected error while creating share: %+v", err)
+			}
+			if s.Id.OpaqueId == "" || s.Token == "" {
+				t.Fatalf("expected the created share to have an id and a token, got %+v", s)
+			}
+		})
+	}
+}
+
+func TestCreatePublicShareConcurrentLimit(t *testing.T) {
+	m, _ := newTestManager(t, []*dbShare{{id: 1, token: "a", itemSource: "10"}}, map[string]interface{}{"max_shares_per_resource": 2})
+
+	// only one of the concurrent creations can take the last slot
+	const n = 5
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := m.CreatePublicShare(context.Background(), owner, newResourceInfo("10"), viewerGrant, "", false)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	var created int
+	for err := range errs {
+		switch err.(type) {
+		case nil:
+			created++
+		case errtypes.PermissionDenied:
+		default:
+			t.Fatalf("not expected error while creating share: %+v", err)
+		}
+	}
+	if created != 1 {
+		t.Fatalf("expected a single share to be created, got %d", created)
+	}
+}
+
+func TestDSN(t *testing.T) {
+	base := config{DBUsername: "reva", DBPassword: "p@ss:word/", DBHost: "db.example.org", DBPort: 3306, DBName: "cernbox"}
+
+	tests := []struct {
+		description string
+		tls         string
+		params      string
+		expected    string
+		invalid     bool
+	}{
+		{
+			description: "defaults",
+			expected:    "reva:p@ss:word/@tcp(db.example.org:3306)/cernbox?parseTime=true",
+		},
+		{
+			description: "tls",
+			tls:         "skip-verify",
+			expected:    "reva:p@ss:word/@tcp(db.example.org:3306)/cernbox?parseTime=true&tls=skip-verify",
+		},
+		{
+			description: "custom params",
+			params:      "charset=utf8mb4&timeout=5s",
+			expected:    "reva:p@ss:word/@tcp(db.example.org:3306)/cernbox?parseTime=true&charset=utf8mb4&timeout=5s",
+		},
+		{
+			description: "unknown tls configuration",
+			tls:         "missing",
+			invalid:     true,
+		},
+		{
+			description: "malformed params",
+			params:      "charset=%zz",
+			invalid:     true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			c := base
+			c.DBTLS, c.DBParams = tt.tls, tt.params
+
+			dsn, err := c.dsn()
+			if tt.invalid {
+				if err == nil {
+					t.Fatalf("expected error for invalid configuration, got dsn %s", dsn)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("not expected error while building dsn: %+v", err)
+			}
+			if dsn != tt.expected {
+				t.Fatalf("unexpected dsn. got=%s expected=%s", dsn, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestManager(t, nil, map[string]interface{}{"health_check_query": true})
+
+	if err := m.Check(ctx); err != nil {
+		t.Fatalf("not expected error while checking the database: %+v", err)
+	}
+
+	if err := m.db.Close(); err != nil {
+		t.Fatalf("not expected error while closing the database: %+v", err)
+	}
+	if err := m.Check(ctx); err == nil {
+		t.Fatal("expected an error checking a closed database")
+	}
+}
+
+func TestCheckUnreachable(t *testing.T) {
+	m, err := New(map[string]interface{}{
+		"db_username":          "root",
+		"db_host":              "127.0.0.1",
+		"db_port":              1,
+		"db_name":              dbName,
+		"health_check_timeout": 1,
+	})
+	if err != nil {
+		t.Fatalf("not expected error while creating public share manager: %+v", err)
+	}
+	if err := m.(*manager).Check(context.Background()); err == nil {
+		t.Fatal("expected an error checking an unreachable database")
+	}
+}
+
+func TestPublicShareEvents(t *testing.T) {
+	ctx := context.Background()
+	received := make(chan *events.Event, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var e events.Event
+		if err := json.NewDecoder(r.Body).Decode(&e); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		received <- &e
+	}))
+	defer srv.Close()
+
+	m, _ := newTestManager(t, nil, map[string]interface{}{
+		"events": map[string]interface{}{"sink": "webhook", "webhook_url": srv.URL},
+	})
+
+	s, err := m.CreatePublicShare(ctx, owner, newResourceInfo("10"), viewerGrant, "", false)
+	if err != nil {
+		t.Fatalf("not expected error while creating share: %+v", err)
+	}
+	ref := &link.PublicShareReference{Spec: &link.PublicShareReference_Id{Id: s.Id}}
+	if _, err := m.UpdatePublicShare(ctx, owner, &link.UpdatePublicShareRequest{
+		Ref:    ref,
+		Update: &link.UpdatePublicShareRequest_Update{Type: link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME, DisplayName: "renamed"},
+	}, nil); err != nil {
+		t.Fatalf("not expected error while updating share: %+v", err)
+	}
+	if err := m.RevokePublicShare(ctx, owner, ref); err != nil {
+		t.Fatalf("not expected error while revoking share: %+v", err)
+	}
+
+	for _, eventType := range []string{events.ShareCreated, events.ShareUpdated, events.ShareRevoked} {
+		select {
+		case e := <-received:
+			if e.Type != eventType || e.Share.ID != s.Id.OpaqueId || e.Share.Token != s.Token || e.Share.PasswordProtected || e.Share.Expiration != nil {
+				t.Fatalf("expected %s event for share %s, got %+v", eventType, s.Id.OpaqueId, e)
+			}
+			if e.Share.ResourceID == nil || e.Share.ResourceID.OpaqueID != "10" || e.Share.Owner == nil || e.Share.Owner.OpaqueID != "einstein" {
+				t.Fatalf("expected the event to hold the resource and the owner of the share, got %+v", e.Share)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected %s event to be delivered", eventType)
+		}
+	}
+}
+
+func TestPublicShareEventsWebhookDown(t *testing.T) {
+	m, _ := newTestManager(t, nil, map[string]interface{}{
+		"events": map[string]interface{}{"sink": "webhook", "webhook_url": "http://127.0.0.1:1", "webhook_retries": -1},
+	})
+
+	// the share operations never fail because of the events
+	s, err := m.CreatePublicShare(context.Background(), owner, newResourceInfo("10"), viewerGrant, "", false)
+	if err != nil {
+		t.Fatalf("not expected error while creating share with the webhook down: %+v", err)
+	}
+	if err := m.RevokePublicShare(context.Background(), owner, &link.PublicShareReference{Spec: &link.PublicShareReference_Token{Token: s.Token}}); err != nil {
+		t.Fatalf("not expected error while revoking share with the webhook down: %+v", err)
+	}
+}
+
+func TestRestorePublicShare(t *testing.T) {
+	ctx := context.Background()
+	past := time.Now().Add(-24 * time.Hour).UTC()
+	future := time.Now().Add(24 * time.Hour).UTC()
+	shares := []*dbShare{
+		{id: 1, token: "expired", stime: 100, expiration: &past},
+		{id: 2, token: "skewed", stime: 100, expiration: &future, orphan: true},
+		{id: 3, token: "other", stime: 100, expiration: &past, orphan: true, owner: "marie"},
+		{id: 4, token: "active", stime: 100},
+	}
+	m, _ := newTestManager(t, shares, map[string]interface{}{"enable_expired_shares_cleanup": true})
+	byID := func(id string) *link.PublicShareReference {
+		return &link.PublicShareReference{Spec: &link.PublicShareReference_Id{Id: &link.PublicShareId{OpaqueId: id}}}
+	}
+
+	// the expired share is orphaned when it is accessed
+	if _, err := m.GetPublicShare(ctx, owner, byID("1"), false); !isNotFound(err) {
+		t.Fatalf("expected not found error for the expired share, got %+v", err)
+	}
+	if _, err := m.GetPublicShare(ctx, owner, byID("1"), false); !isNotFound(err) {
+		t.Fatalf("expected not found error for the orphaned share, got %+v", err)
+	}
+
+	// it can't be restored without a new expiration, which has to be in the future
+	if _, err := m.RestorePublicShare(ctx, owner, byID("1"), nil); !isBadRequest(err) {
+		t.Fatalf("expected bad request error restoring an expired share, got %+v", err)
+	}
+	if _, err := m.RestorePublicShare(ctx, owner, byID("1"), &typespb.Timestamp{Seconds: uint64(past.Unix())}); !isBadRequest(err) {
+		t.Fatalf("expected bad request error restoring a share with an expiration in the past, got %+v", err)
+	}
+	s, err := m.RestorePublicShare(ctx, owner, byID("1"), &typespb.Timestamp{Seconds: uint64(future.Unix())})
+	if err != nil {
+		t.Fatalf("not expected error while restoring share: %+v", err)
+	}
+	if s.Token != "expired" || s.Expiration.GetSeconds() != uint64(future.Unix()) {
+		t.Fatalf("expected the share to be restored with the new expiration, got %+v", s)
+	}
+	if _, err := m.GetPublicShare(ctx, owner, byID("1"), false); err != nil {
+		t.Fatalf("not expected error while getting the restored share: %+v", err)
+	}
+
+	// a share orphaned by mistake can be restored by token, keeping its expiration
+	s, err = m.RestorePublicShare(ctx, owner, &link.PublicShareReference{Spec: &link.PublicShareReference_Token{Token: "skewed"}}, nil)
+	if err != nil {
+		t.Fatalf("not expected error while restoring share: %+v", err)
+	}
+	if s.Id.OpaqueId != "2" || s.Expiration.GetSeconds() != uint64(future.Unix()) {
+		t.Fatalf("expected the share to be restored with its expiration, got %+v", s)
+	}
+
+	if _, err := m.RestorePublicShare(ctx, owner, byID("3"), &typespb.Timestamp{Seconds: uint64(future.Unix())}); !isPermissionDenied(err) {
+		t.Fatalf("expected permission denied error restoring a share of another user, got %+v", err)
+	}
+	if _, err := m.RestorePublicShare(ctx, owner, byID("4"), nil); !isBadRequest(err) {
+		t.Fatalf("expected bad request error restoring a share that is not orphaned, got %+v", err)
+	}
+	if _, err := m.RestorePublicShare(ctx, owner, byID("5"), nil); !isNotFound(err) {
+		t.Fatalf("expected not found error restoring a missing share, got %+v", err)
+	}
+}
+
+func isBadRequest(err error) bool {
+	_, ok := err.(errtypes.BadRequest)
+	return ok
+}
+
+func isPermissionDenied(err error) bool {
+	_, ok := err.(errtypes.PermissionDenied)
+	return ok
+}
+
+// recordSpans returns a context whose spans are recorded in the returned exporter.
+func recordSpans(t *testing.T) (context.Context, *tracetest.InMemoryExporter) {
+	rec := tracetest.NewInMemoryExporter()
+	tp := tracesdk.NewTracerProvider(tracesdk.WithSyncer(rec))
+	ctx, span := tp.Tracer(t.Name()).Start(context.Background(), t.Name())
+	t.Cleanup(func() { span.End() })
+	return ctx, rec
+}
+
+// spanAttributes returns the attributes and the status of the last recorded span with the given name.
+func spanAttributes(t *testing.T, rec *tracetest.InMemoryExporter, name string) (map[attribute.Key]attribute.Value, codes.Code) {
+	spans := rec.GetSpans()
+	for i := len(spans) - 1; i >= 0; i-- {
+		if spans[i].Name == name {
+			attrs := map[attribute.Key]attribute.Value{}
+			for _, kv := range spans[i].Attributes {
+				attrs[kv.Key] = kv.Value
+			}
+			return attrs, spans[i].Status.Code
+		}
+	}
+	t.Fatalf("expected span %s to be recorded", name)
+	return nil, codes.Unset
+}
+
+func TestTracingAttributes(t *testing.T) {
+	ctx, rec := recordSpans(t)
+	shares := []*dbShare{
+		{id: 1, token: "a", itemSource: "10"},
+		{id: 2, token: "b", itemSource: "10"},
+		{id: 3, token: "c", itemSource: "20"},
+	}
+	m, _ := newTestManager(t, shares, nil)
+
+	if _, err := m.CreatePublicShare(ctx, owner, newResourceInfo("30"), viewerGrant, "", true); err != nil {
+		t.Fatalf("not expected error while creating share: %+v", err)
+	}
+	attrs, code := spanAttributes(t, rec, "CreatePublicShare")
+	if attrs[attrShareType].AsString() != "internal" || attrs[attrResourceID].AsString() != "storage!30" || attrs[attrShareID].AsString() == "" || code == codes.Error {
+		t.Fatalf("unexpected attributes of the create span: %v, status %v", attrs, code)
+	}
+
+	filters := []*link.ListPublicSharesRequest_Filter{{
+		Type: link.ListPublicSharesRequest_Filter_TYPE_RESOURCE_ID,
+		Term: &link.ListPublicSharesRequest_Filter_ResourceId{
+			ResourceId: &provider.ResourceId{StorageId: "storage", OpaqueId: "10"},
+		},
+	}}
+	if _, err := m.ListPublicShares(ctx, owner, filters, nil, false); err != nil {
+		t.Fatalf("not expected error while listing shares: %+v", err)
+	}
+	attrs, code = spanAttributes(t, rec, "ListPublicShares")
+	if attrs[attrFilterCount].AsInt64() != 1 || attrs[attrRowsReturned].AsInt64() != 2 || code == codes.Error {
+		t.Fatalf("unexpected attributes of the list span: %v, status %v", attrs, code)
+	}
+	if !strings.Contains(attrs[semconv.DBStatementKey].AsString(), "fileid_prefix=? AND item_source=?") {
+		t.Fatalf("expected the query to be recorded in the list span, got %v", attrs)
+	}
+
+	if err := m.RevokePublicShare(ctx, owner, &link.PublicShareReference{Spec: &link.PublicShareReference_Token{Token: "a"}}); err != nil {
+		t.Fatalf("not expected error while revoking share: %+v", err)
+	}
+	attrs, _ = spanAttributes(t, rec, "RevokePublicShare")
+	if attrs[attrRowsAffected].AsInt64() != 1 {
+		t.Fatalf("unexpected attributes of the revoke span: %v", attrs)
+	}
+
+	// the errors are recorded on the span
+	if err := m.RevokePublicShare(ctx, owner, &link.PublicShareReference{Spec: &link.PublicShareReference_Token{Token: "a"}}); err == nil {
+		t.Fatalf("expected error while revoking a missing share")
+	}
+	attrs, code = spanAttributes(t, rec, "RevokePublicShare")
+	if attrs[attrRowsAffected].AsInt64() != 0 || code != codes.Error {
+		t.Fatalf("expected the error to be recorded on the revoke span: %v, status %v", attrs, code)
+	}
+	spans := rec.GetSpans()
+	if events := spans[len(spans)-1].Events; len(events) == 0 || events[0].Name != "exception" {
+		t.Fatalf("expected the error to be recorded as an event of the span, got %v", events)
+	}
+
+	// the tokens are only recorded hashed
+	if _, err := m.GetPublicShareByToken(ctx, "missing", nil, false); err == nil {
+		t.Fatalf("expected error while getting a missing share")
+	}
+	attrs, code = spanAttributes(t, rec, "GetPublicShareByToken")
+	if hash := attrs[attrTokenHash].AsString(); hash == "" || hash == "missing" || code != codes.Error {
+		t.Fatalf("expected the error and the token hash to be recorded on the get span: %v, status %v", attrs, code)
+	}
+
+	if _, err := m.GetPublicShare(ctx, owner, &link.PublicShareReference{Spec: &link.PublicShareReference_Id{Id: &link.PublicShareId{OpaqueId: "42"}}}, false); err == nil {
+		t.Fatalf("expected error while getting a missing share")
+	}
+	for _, name := range []string{"getByID", "GetPublicShare"} {
+		attrs, code = spanAttributes(t, rec, name)
+		if attrs[attrShareID].AsString() != "42" || code != codes.Error {
+			t.Fatalf("expected the error and the share id to be recorded on the %s span: %v, status %v", name, attrs, code)
+		}
+	}
+}
+
+func TestPublicShareType(t *testing.T) {
+	ctx := context.Background()
+	shares := []*dbShare{
+		{id: 1, token: "a", shareType: 3},
+		{id: 2, token: "b", shareType: 4},
+	}
+	m, engine := newTestManager(t, shares, map[string]interface{}{
+		"public_share_type": 4,
+	})
+
+	list, err := m.ListPublicShares(ctx, owner, nil, nil, false)
+	if err != nil {
+		t.Fatalf("not expected error while listing shares: %+v", err)
+	}
+	if !reflect.DeepEqual(ids(list), []string{"2"}) {
+		t.Fatalf("expected only the shares of the configured type, got %v", ids(list))
+	}
+	if _, err := m.GetPublicShareByToken(ctx, "a", nil, false); err == nil {
+		t.Fatalf("expected the share of another type not to be found")
+	}
+
+	s, err := m.CreatePublicShare(ctx, owner, newResourceInfo("10"), viewerGrant, "", false)
+	if err != nil {
+		t.Fatalf("not expected error while creating share: %+v", err)
+	}
+
+	sqlCtx := sql.NewEmptyContext()
+	if _, _, err := engine.Query(sqlCtx, "USE "+dbName); err != nil {
+		t.Fatalf("not expected error while using the database: %+v", err)
+	}
+	_, iter, err := engine.Query(sqlCtx, "SELECT share_type FROM oc_share WHERE id="+s.Id.OpaqueId)
+	if err != nil {
+		t.Fatalf("not expected error while querying the shares: %+v", err)
+	}
+	rows, err := sql.RowIterToRows(sqlCtx, nil, iter)
+	if err != nil {
+		t.Fatalf("not expected error while reading the shares: %+v", err)
+	}
+	if len(rows) != 1 || rows[0][0] != int8(4) {
+		t.Fatalf("expected the share to be created with the configured type, got %v", rows)
+	}
+}
+
+func TestListPublicSharesExpirationWindow(t *testing.T) {
+	now := time.Now()
+	in := func(d time.Duration) *time.Time {
+		t := now.Add(d).UTC().Truncate(time.Second)
+		return &t
+	}
+	shares := []*dbShare{
+		{id: 1, token: "a", itemSource: "10", expiration: in(-24 * time.Hour)},     // expired
+		{id: 2, token: "b", itemSource: "10", expiration: in(5 * 24 * time.Hour)},  // expiring soon
+		{id: 3, token: "c", itemSource: "20", expiration: in(2 * 24 * time.Hour)},  // expiring soon
+		{id: 4, token: "d", itemSource: "10", expiration: in(30 * 24 * time.Hour)}, // far future
+		{id: 5, token: "e", itemSource: "10"},                                      // never expiring
+	}
+	m, _ := newTestManager(t, shares, nil)
+
+	resourceFilter := &link.ListPublicSharesRequest_Filter{
+		Type: link.ListPublicSharesRequest_Filter_TYPE_RESOURCE_ID,
+		Term: &link.ListPublicSharesRequest_Filter_ResourceId{
+			ResourceId: &provider.ResourceId{StorageId: "storage", OpaqueId: "10"},
+		},
+	}
+
+	tests := []struct {
+		description string
+		window      *publicshare.ExpirationWindow
+		filters     []*link.ListPublicSharesRequest_Filter
+		order       *publicshare.ListOrder
+		expected    []string
+	}{
+		{
+			description: "expiring in the next 7 days",
+			window:      &publicshare.ExpirationWindow{From: now, To: now.Add(7 * 24 * time.Hour)},
+			expected:    []string{"3", "2"},
+		},
+		{
+			description: "composed with the other filters",
+			window:      &publicshare.ExpirationWindow{From: now, To: now.Add(7 * 24 * time.Hour)},
+			filters:     []*link.ListPublicSharesRequest_Filter{resourceFilter},
+			expected:    []string{"2"},
+		},
+		{
+			description: "open ended",
+			window:      &publicshare.ExpirationWindow{From: now},
+			expected:    []string{"3", "2", "4"},
+		},
+		{
+			description: "request order overrides the expiration order",
+			window:      &publicshare.ExpirationWindow{From: now},
+			order:       &publicshare.ListOrder{By: publicshare.OrderByExpiration, Descending: true},
+			expected:    []string{"4", "2", "3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			ctx := publicshare.ContextSetExpirationWindow(context.Background(), tt.window)
+			if tt.order != nil {
+				ctx = publicshare.ContextSetListOrder(ctx, tt.order)
+			}
+			got, err := m.ListPublicShares(ctx, owner, tt.filters, nil, false)
+			if err != nil {
+				t.Fatalf("not expected error while listing shares: %+v", err)
+			}
+			if !reflect.DeepEqual(ids(got), tt.expected) {
+				t.Fatalf("unexpected shares in the expiration window. got=%v expected=%v", ids(got), tt.expected)
+			}
+
+			count, err := m.CountPublicShares(ctx, owner, tt.filters)
+			if err != nil {
+				t.Fatalf("not expected error while counting shares: %+v", err)
+			}
+			if count != len(tt.expected) {
+				t.Fatalf("count does not match the listed shares. count=%d expected=%d", count, len(tt.expected))
+			}
+		})
+	}
+}
+
+func TestClose(t *testing.T) {
+	ctx := sql.NewEmptyContext()
+	_, port, cleanup := startDatabase(ctx, createShareTable(ctx, []*dbShare{{id: 1, token: "a", stime: 100}}))
+	defer cleanup()
+	running := goleak.IgnoreCurrent()
+
+	m, err := New(map[string]interface{}{
+		"db_username":                   "root",
+		"db_password":                   "",
+		"db_host":                       "localhost",
+		"db_port":                       port,
+		"db_name":                       dbName,
+		"enable_expired_shares_cleanup": true,
+		"track_last_accessed":           true,
+	})
+	if err != nil {
+		t.Fatalf("not expected error while creating public share manager: %+v", err)
+	}
+	if _, err := m.GetPublicShareByToken(context.Background(), "a", nil, false); err != nil {
+		t.Fatalf("not expected error while getting share by token: %+v", err)
+	}
+
+	if err := m.(*manager).Close(); err != nil {
+		t.Fatalf("not expected error while closing the manager: %+v", err)
+	}
+	goleak.VerifyNone(t, running)
+}
+
+func TestSigningKeysRotation(t *testing.T) {
+	hash, err := hashPassword("secret", 4)
+	if err != nil {
+		t.Fatalf("error hashing password: %v", err)
+	}
+	shares := []*dbShare{{id: 1, token: "a", stime: 100, password: hash}}
+	ctx := context.Background()
+	keys := func(current string, keys map[string]string) map[string]interface{} {
+		return map[string]interface{}{"signing_key_id": current, "signing_keys": keys}
+	}
+
+	m, _ := newTestManager(t, shares, keys("2023", map[string]string{"2023": "old secret"}))
+	s, err := m.GetPublicShareByToken(ctx, "a", &link.PublicShareAuthentication{
+		Spec: &link.PublicShareAuthentication_Password{Password: "secret"},
+	}, true)
+	if err != nil {
+		t.Fatalf("not expected error while getting share by token: %+v", err)
+	}
+	if !strings.HasPrefix(s.Signature.GetSignature(), "2023:") {
+		t.Fatalf("expected the signature to embed the key id, got %s", s.Signature.GetSignature())
+	}
+	auth := &link.PublicShareAuthentication{
+		Spec: &link.PublicShareAuthentication_Signature{Signature: s.Signature},
+	}
+
+	// the signatures of the previous key are valid while it is kept
+	m, _ = newTestManager(t, shares, keys("2024", map[string]string{"2023": "old secret", "2024": "new secret"}))
+	if _, err := m.GetPublicShareByToken(ctx, "a", auth, false); err != nil {
+		t.Fatalf("not expected error while authenticating with the previous key: %+v", err)
+	}
+
+	m, _ = newTestManager(t, shares, keys("2024", map[string]string{"2024": "new secret"}))
+	_, err = m.GetPublicShareByToken(ctx, "a", auth, false)
+	if _, ok := err.(errtypes.InvalidCredentials); !ok {
+		t.Fatalf("expected invalid credentials error with a retired key, got %+v", err)
+	}
+}
+
+func TestInvalidSigningKeys(t *testing.T) {
+	_, err := New(map[string]interface{}{"signing_key_id": "2024", "signing_keys": map[string]string{"2023": "old secret"}})
+	if _, ok := err.(errtypes.BadRequest); !ok {
+		t.Fatalf("expected bad request error, got %+v", err)
+	}
+}
+
+// operationCount returns the number of operations recorded in the metrics
+// with the given operation and outcome.
+func operationCount(t *testing.T, op, outcome string) int64 {
+	rows, err := view.RetrieveData(operations.Name())
+	if err != nil {
+		t.Fatalf("not expected error while retrieving the metrics: %+v", err)
+	}
+	for _, r := range rows {
+		tags := map[tag.Key]string{}
+		for _, t := range r.Tags {
+			tags[t.Key] = t.Value
+		}
+		if tags[operationKey] == op && tags[outcomeKey] == outcome {
+			return r.Data.(*view.CountData).Value
+		}
+	}
+	return 0
+}
+
+// latencyCount returns the number of queries recorded in the latency metrics with the given name.
+func latencyCount(t *testing.T, query string) int64 {
+	rows, err := view.RetrieveData(queryLatency.Name())
+	if err != nil {
+		t.Fatalf("not expected error while retrieving the metrics: %+v", err)
+	}
+	for _, r := range rows {
+		if len(r.Tags) == 1 && r.Tags[0].Key == queryKey && r.Tags[0].Value == query {
+			return r.Data.(*view.DistributionData).Count
+		}
+	}
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestManager(t, nil, nil)
+	// the views are registered only once by all the managers
+	other, err := New(map[string]interface{}{"db_host": "localhost", "db_name": dbName})
+	if err != nil {
+		t.Fatalf("not expected error while creating a second public share manager: %+v", err)
+	}
+	defer other.(*manager).Close()
+
+	// the views are shared by the whole process, only their increments are checked
+	counts := func() map[string]int64 {
+		return map[string]int64{
+			"create ok":              operationCount(t, opCreate, "ok"),
+			"update ok":              operationCount(t, opUpdate, "ok"),
+			"get_by_token ok":        operationCount(t, opGetByToken, "ok"),
+			"get_by_token not_found": operationCount(t, opGetByToken, "not_found"),
+			"revoke ok":              operationCount(t, opRevoke, "ok"),
+			"revoke not_found":       operationCount(t, opRevoke, "not_found"),
+			"insert query":           latencyCount(t, queryInsert),
+			"revoke query":           latencyCount(t, queryRevoke),
+		}
+	}
+	before := counts()
+
+	s, err := m.CreatePublicShare(ctx, owner, newResourceInfo("10"), viewerGrant, "", false)
+	if err != nil {
+		t.Fatalf("not expected error while creating share: %+v", err)
+	}
+	ref := &link.PublicShareReference{Spec: &link.PublicShareReference_Id{Id: s.Id}}
+	if _, err := m.UpdatePublicShare(ctx, owner, &link.UpdatePublicShareRequest{
+		Ref:    ref,
+		Update: &link.UpdatePublicShareRequest_Update{Type: link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME, DisplayName: "renamed"},
+	}, nil); err != nil {
+		t.Fatalf("not expected error while updating share: %+v", err)
+	}
+	if _, err := m.GetPublicShareByToken(ctx, s.Token, nil, false); err != nil {
+		t.Fatalf("not expected error while getting share: %+v", err)
+	}
+	if err := m.RevokePublicShare(ctx, owner, ref); err != nil {
+		t.Fatalf("not expected error while revoking share: %+v", err)
+	}
+	if err := m.RevokePublicShare(ctx, owner, ref); !isNotFound(err) {
+		t.Fatalf("expected not found error revoking a revoked share, got %+v", err)
+	}
+	if _, err := m.GetPublicShareByToken(ctx, s.Token, nil, false); !isNotFound(err) {
+		t.Fatalf("expected not found error getting a revoked share, got %+v", err)
+	}
+
+	after := counts()
+	for k, v := range map[string]int64{
+		"create ok":              1,
+		"update ok":              1,
+		"get_by_token ok":        1,
+		"get_by_token not_found": 1,
+		"revoke ok":              1,
+		"revoke not_found":       1,
+		"insert query":           1,
+		"revoke query":           2,
+	} {
+		if got := after[k] - before[k]; got != v {
+			t.Errorf("expected %d more %s in the metrics, got %d", v, k, got)
+		}
+	}
+}
+
+func TestBatchUpdatePublicShare(t *testing.T) {
+	ctx := context.Background()
+	m, _ := newTestManager(t, nil, map[string]interface{}{"enforce_password": true})
+	s, err := m.CreatePublicShare(ctx, owner, newResourceInfo("10"), viewerGrant, "", false)
+	if err != nil {
+		t.Fatalf("not expected error while creating share: %+v", err)
+	}
+	ref := &link.PublicShareReference{Spec: &link.PublicShareReference_Id{Id: s.Id}}
+	expiration := &typespb.Timestamp{Seconds: uint64(time.Now().Add(24 * time.Hour).Unix())}
+	editor := &provider.ResourcePermissions{Stat: true, ListContainer: true, InitiateFileDownload: true, InitiateFileUpload: true}
+	update := func(updates ...*link.UpdatePublicShareRequest_Update) (*link.PublicShare, error) {
+		return m.UpdatePublicShareFields(ctx, owner, ref, updates)
+	}
+
+	// the updates are applied all at once
+	updated, err := update(
+		&link.UpdatePublicShareRequest_Update{Type: link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME, DisplayName: "renamed"},
+		&link.UpdatePublicShareRequest_Update{Type: link.UpdatePublicShareRequest_Update_TYPE_PASSWORD, Grant: &link.Grant{Password: "secret"}},
+		&link.UpdatePublicShareRequest_Update{Type: link.UpdatePublicShareRequest_Update_TYPE_EXPIRATION, Grant: &link.Grant{Expiration: expiration}},
+		&link.UpdatePublicShareRequest_Update{Type: link.UpdatePublicShareRequest_Update_TYPE_PERMISSIONS, Grant: &link.Grant{Permissions: &link.PublicSharePermissions{Permissions: editor}}},
+	)
+	if err != nil {
+		t.Fatalf("not expected error while updating share: %+v", err)
+	}
+	if updated.DisplayName != "renamed" || !updated.PasswordProtected || updated.Expiration.GetSeconds() != expiration.Seconds || !updated.Permissions.Permissions.InitiateFileUpload {
+		t.Fatalf("expected all the updates to be applied, got %+v", updated)
+	}
+
+	// none of the updates is applied when any of them is invalid
+	for name, invalid := range map[string]*link.UpdatePublicShareRequest_Update{
+		"password removal": {Type: link.UpdatePublicShareRequest_Update_TYPE_PASSWORD, Grant: &link.Grant{}},
+		"invalid type":     {Type: link.UpdatePublicShareRequest_Update_TYPE_INVALID},
+		"duplicated type":  {Type: link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME, DisplayName: "duplicated"},
+	} {
+		_, err := update(
+			&link.UpdatePublicShareRequest_Update{Type: link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME, DisplayName: "not applied"},
+			&link.UpdatePublicShareRequest_Update{Type: link.UpdatePublicShareRequest_Update_TYPE_DESCRIPTION, Description: "not applied"},
+			invalid,
+		)
+		if !isBadRequest(err) {
+			t.Fatalf("expected bad request error for the %s, got %+v", name, err)
+		}
+		got, err := m.GetPublicShare(ctx, owner, ref, false)
+		if err != nil {
+			t.Fatalf("not expected error while getting share: %+v", err)
+		}
+		if got.DisplayName != "renamed" || got.Description != "" || !got.PasswordProtected {
+			t.Fatalf("expected the share to be unchanged after the %s, got %+v", name, got)
+		}
+	}
+
+	// the updates in the opaque of the request are applied at once as well
+	req := &link.UpdatePublicShareRequest{Ref: ref}
+	if err := publicshare.SetUpdates(req, []*link.UpdatePublicShareRequest_Update{
+		{Type: link.UpdatePublicShareRequest_Update_TYPE_DISPLAYNAME, DisplayName: "renamed again"},
+		{Type: link.UpdatePublicShareRequest_Update_TYPE_DESCRIPTION, Description: "described"},
+	}); err != nil {
+		t.Fatalf("not expected error while setting the updates: %+v", err)
+	}
+	updated, err = m.UpdatePublicShare(ctx, owner, req, nil)
+	if err != nil {
+		t.Fatalf("not expected error while updating share: %+v", err)
+	}
+	if updated.DisplayName != "renamed again" || updated.Description != "described" {
+		t.Fatalf("expected the updates of the request to be applied, got %+v", updated)
+	}
+
+	// the single update of the old clients is still applied
+	updated, err = m.UpdatePublicShare(ctx, owner, &link.UpdatePublicShareRequest{
+		Ref:    ref,
+		Update: &link.UpdatePublicShareRequest_Update{Type: link.UpdatePublicShareRequest_Update_TYPE_DESCRIPTION, Description: "single"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("not expected error while updating share: %+v", err)
+	}
+	if updated.Description != "single" || updated.DisplayName != "renamed again" {
+		t.Fatalf("expected the single update to be applied, got %+v", updated)
+	}
+}
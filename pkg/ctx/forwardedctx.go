@@ -0,0 +1,61 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ctx
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// ForwardedForHeader is the header holding the addresses of the client and of
+// the services its request went through, as the X-Forwarded-For HTTP header.
+const ForwardedForHeader = "x-forwarded-for"
+
+// ForwardedFor returns the chain of addresses already forwarded, followed by
+// the address of the remote end of the connection, as a comma separated list.
+func ForwardedFor(forwarded []string, remoteAddr string) string {
+	hops := make([]string, 0, len(forwarded)+1)
+	for _, f := range forwarded {
+		if f = strings.TrimSpace(f); f != "" {
+			hops = append(hops, f)
+		}
+	}
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		remoteAddr = host
+	}
+	if remoteAddr != "" {
+		hops = append(hops, remoteAddr)
+	}
+	return strings.Join(hops, ", ")
+}
+
+// ContextGetForwardedFor returns the chain of addresses the incoming gRPC
+// request went through, including the address of its peer.
+func ContextGetForwardedFor(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var remoteAddr string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteAddr = p.Addr.String()
+	}
+	return ForwardedFor(md.Get(ForwardedForHeader), remoteAddr)
+}
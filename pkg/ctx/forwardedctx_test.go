@@ -0,0 +1,75 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package ctx
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+func TestForwardedFor(t *testing.T) {
+	tests := []struct {
+		description string
+		forwarded   []string
+		remoteAddr  string
+		expected    string
+	}{
+		{
+			description: "direct client",
+			remoteAddr:  "192.0.2.1:41234",
+			expected:    "192.0.2.1",
+		},
+		{
+			description: "behind proxies",
+			forwarded:   []string{"192.0.2.1, 10.0.0.1", "10.0.0.2"},
+			remoteAddr:  "10.0.0.3:41234",
+			expected:    "192.0.2.1, 10.0.0.1, 10.0.0.2, 10.0.0.3",
+		},
+		{
+			description: "ipv6 peer",
+			remoteAddr:  "[2001:db8::1]:41234",
+			expected:    "2001:db8::1",
+		},
+		{
+			description: "no address",
+			forwarded:   []string{" "},
+			expected:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			if got := ForwardedFor(tt.forwarded, tt.remoteAddr); got != tt.expected {
+				t.Fatalf("result does not match with expected. got=%s expected=%s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestContextGetForwardedFor(t *testing.T) {
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs(ForwardedForHeader, "192.0.2.1"))
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 41234}})
+	if got := ContextGetForwardedFor(ctx); got != "192.0.2.1, 10.0.0.1" {
+		t.Fatalf("result does not match with expected. got=%s expected=192.0.2.1, 10.0.0.1", got)
+	}
+}
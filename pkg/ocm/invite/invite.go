@@ -34,8 +34,13 @@ type Repository interface {
 	// GetToken gets the token from the repository.
 	GetToken(ctx context.Context, token string) (*invitepb.InviteToken, error)
 
-	// ListTokens gets the valid tokens from the repository (i.e. not expired).
-	ListTokens(ctx context.Context, initiator *userpb.UserId) ([]*invitepb.InviteToken, error)
+	// ListTokens gets a page of the tokens of the initiator matching the options,
+	// by default the valid ones (i.e. not expired), with the continuation token
+	// of the next page, empty in the last page.
+	ListTokens(ctx context.Context, initiator *userpb.UserId, opts *ListTokensOptions) ([]*invitepb.InviteToken, string, error)
+
+	// AcceptToken marks the token as accepted by a remote user.
+	AcceptToken(ctx context.Context, token string) error
 
 	// AddRemoteUser stores the remote user.
 	AddRemoteUser(ctx context.Context, initiator *userpb.UserId, remoteUser *userpb.User) error
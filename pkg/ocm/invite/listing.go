@@ -0,0 +1,102 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package invite
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"google.golang.org/grpc/metadata"
+)
+
+// The ListInviteTokens requests and responses have no opaque map,
+// so the accepted users are exchanged in the grpc metadata.
+const (
+	// IncludeAcceptedUsersHeader is the request metadata asking for the accepted users.
+	IncludeAcceptedUsersHeader = "x-ocm-include-accepted-users"
+	// AcceptedUsersHeader is the response header holding the Listing of the
+	// accepted users, encoded as json. The -bin suffix makes grpc encode it
+	// as binary, as the names of the users are not restricted to ascii.
+	AcceptedUsersHeader = "x-ocm-accepted-users-bin"
+)
+
+// Listing holds the users who accepted the invites of the initiator of the
+// tokens, with the info of their providers. The repositories keep the accepted
+// users per initiator, so they are the same for all the listed tokens.
+// Error is set when the accepted users could not be retrieved.
+type Listing struct {
+	AcceptedUsers []*AcceptedUser `json:"accepted_users"`
+	Error         string          `json:"error,omitempty"`
+}
+
+// AcceptedUser is a user who accepted an invite, with the info of its provider.
+// Error is set when the info of the provider could not be retrieved.
+type AcceptedUser struct {
+	User     *userpb.User              `json:"user"`
+	Provider *ocmprovider.ProviderInfo `json:"provider,omitempty"`
+	Error    string                    `json:"error,omitempty"`
+}
+
+// ContextSetIncludeAcceptedUsers returns a context asking
+// for the accepted users in the ListInviteTokens calls.
+func ContextSetIncludeAcceptedUsers(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, IncludeAcceptedUsersHeader, "true")
+}
+
+// ContextGetIncludeAcceptedUsers returns whether the incoming
+// ListInviteTokens call asks for the accepted users.
+func ContextGetIncludeAcceptedUsers(ctx context.Context) bool {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return false
+	}
+	v := md.Get(IncludeAcceptedUsersHeader)
+	if len(v) == 0 {
+		return false
+	}
+	include, _ := strconv.ParseBool(v[0])
+	return include
+}
+
+// EncodeListing returns the response header holding the accepted users.
+func EncodeListing(l *Listing) (metadata.MD, error) {
+	v, err := json.Marshal(l)
+	if err != nil {
+		return nil, err
+	}
+	return metadata.Pairs(AcceptedUsersHeader, string(v)), nil
+}
+
+// DecodeListing reads the accepted users from the response header.
+// It returns false if they are missing.
+func DecodeListing(md metadata.MD) (*Listing, bool, error) {
+	v := md.Get(AcceptedUsersHeader)
+	if len(v) == 0 {
+		return nil, false, nil
+	}
+	var l Listing
+	if err := json.Unmarshal([]byte(v[0]), &l); err != nil {
+		return nil, true, errtypes.InternalError("invalid list of accepted users: " + err.Error())
+	}
+	return &l, true, nil
+}
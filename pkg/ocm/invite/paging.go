@@ -0,0 +1,136 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package invite
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	invitepb "github.com/cs3org/go-cs3apis/cs3/ocm/invite/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+)
+
+// Keys in the opaque map of the FindAcceptedUsers requests and responses
+// used to paginate the accepted users, encoded as plain text.
+const (
+	// PageOpaqueKey holds the requested page, starting from 1.
+	PageOpaqueKey = "page"
+	// PageSizeOpaqueKey holds the number of users in a page.
+	PageSizeOpaqueKey = "page_size"
+	// PaginatedOpaqueKey is set in the response by the invite managers
+	// that already applied the filter and the pagination of the request.
+	PaginatedOpaqueKey = "paginated"
+)
+
+// Page is a page of the accepted users.
+// A zero size means that all the users are returned.
+type Page struct {
+	Number int
+	Size   int
+}
+
+// GetPage reads the requested page from the opaque map of the request.
+func GetPage(req *invitepb.FindAcceptedUsersRequest) (*Page, error) {
+	p := &Page{Number: 1}
+	if req.Opaque == nil || req.Opaque.Map == nil {
+		return p, nil
+	}
+	var err error
+	if e, ok := req.Opaque.Map[PageOpaqueKey]; ok {
+		if p.Number, err = strconv.Atoi(string(e.Value)); err != nil || p.Number < 1 {
+			return nil, errtypes.BadRequest("invalid page " + string(e.Value))
+		}
+	}
+	if e, ok := req.Opaque.Map[PageSizeOpaqueKey]; ok {
+		if p.Size, err = strconv.Atoi(string(e.Value)); err != nil || p.Size < 0 {
+			return nil, errtypes.BadRequest("invalid page size " + string(e.Value))
+		}
+	}
+	return p, nil
+}
+
+// SetPage sets the requested page in the opaque map of the request.
+func SetPage(req *invitepb.FindAcceptedUsersRequest, p *Page) {
+	if req.Opaque == nil {
+		req.Opaque = &typespb.Opaque{}
+	}
+	if req.Opaque.Map == nil {
+		req.Opaque.Map = map[string]*typespb.OpaqueEntry{}
+	}
+	req.Opaque.Map[PageOpaqueKey] = &typespb.OpaqueEntry{Decoder: "plain", Value: []byte(strconv.Itoa(p.Number))}
+	req.Opaque.Map[PageSizeOpaqueKey] = &typespb.OpaqueEntry{Decoder: "plain", Value: []byte(strconv.Itoa(p.Size))}
+}
+
+// SetPaginated marks the response as already filtered and paginated.
+func SetPaginated(res *invitepb.FindAcceptedUsersResponse) {
+	if res.Opaque == nil {
+		res.Opaque = &typespb.Opaque{}
+	}
+	if res.Opaque.Map == nil {
+		res.Opaque.Map = map[string]*typespb.OpaqueEntry{}
+	}
+	res.Opaque.Map[PaginatedOpaqueKey] = &typespb.OpaqueEntry{Decoder: "plain", Value: []byte("true")}
+}
+
+// IsPaginated returns whether the response was already filtered and paginated.
+func IsPaginated(res *invitepb.FindAcceptedUsersResponse) bool {
+	if res.Opaque == nil || res.Opaque.Map == nil {
+		return false
+	}
+	_, ok := res.Opaque.Map[PaginatedOpaqueKey]
+	return ok
+}
+
+// UserMatches returns whether the username, the display name, the mail or the
+// opaque id of the user contain the filter, ignoring the case.
+func UserMatches(u *userpb.User, filter string) bool {
+	filter = strings.ToLower(filter)
+	return strings.Contains(strings.ToLower(u.Username), filter) || strings.Contains(strings.ToLower(u.DisplayName), filter) ||
+		strings.Contains(strings.ToLower(u.Mail), filter) || strings.Contains(strings.ToLower(u.GetId().GetOpaqueId()), filter)
+}
+
+// Paginate sorts the users by display name, so that the pages are stable,
+// and returns the requested page.
+func Paginate(users []*userpb.User, p *Page) []*userpb.User {
+	sort.SliceStable(users, func(i, j int) bool {
+		a, b := users[i], users[j]
+		if a.DisplayName != b.DisplayName {
+			return a.DisplayName < b.DisplayName
+		}
+		if a.GetId().GetIdp() != b.GetId().GetIdp() {
+			return a.GetId().GetIdp() < b.GetId().GetIdp()
+		}
+		return a.GetId().GetOpaqueId() < b.GetId().GetOpaqueId()
+	})
+	if p == nil || p.Size == 0 {
+		return users
+	}
+	if pages := (len(users) + p.Size - 1) / p.Size; p.Number > pages {
+		return []*userpb.User{}
+	}
+	start := (p.Number - 1) * p.Size
+	end := start + p.Size
+	if end > len(users) {
+		end = len(users)
+	}
+	return users[start:end]
+}
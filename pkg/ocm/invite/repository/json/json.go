@@ -23,7 +23,6 @@ import (
 	"encoding/json"
 	"io"
 	"os"
-	"strings"
 	"sync"
 	"time"
 
@@ -42,6 +41,8 @@ type inviteModel struct {
 	File          string
 	Invites       map[string]*invitepb.InviteToken `json:"invites"`
 	AcceptedUsers map[string][]*userpb.User        `json:"accepted_users"`
+	// AcceptedTokens holds the tokens accepted by at least a remote user
+	AcceptedTokens map[string]bool `json:"accepted_tokens"`
 }
 
 type manager struct {
@@ -129,6 +130,9 @@ func loadOrCreate(file string) (*inviteModel, error) {
 	if model.AcceptedUsers == nil {
 		model.AcceptedUsers = make(map[string][]*userpb.User)
 	}
+	if model.AcceptedTokens == nil {
+		model.AcceptedTokens = make(map[string]bool)
+	}
 
 	model.File = file
 	return model, nil
@@ -168,21 +172,33 @@ func (m *manager) GetToken(ctx context.Context, token string) (*invitepb.InviteT
 	return nil, invite.ErrTokenNotFound
 }
 
-func (m *manager) ListTokens(ctx context.Context, initiator *userpb.UserId) ([]*invitepb.InviteToken, error) {
+func (m *manager) ListTokens(ctx context.Context, initiator *userpb.UserId, opts *invite.ListTokensOptions) ([]*invitepb.InviteToken, string, error) {
 	m.RLock()
 	defer m.RUnlock()
 
+	now := time.Now()
 	tokens := []*invitepb.InviteToken{}
 	for _, token := range m.model.Invites {
-		if utils.UserEqual(token.UserId, initiator) && !tokenIsExpired(token) {
+		if utils.UserEqual(token.UserId, initiator) && opts.Matches(token, m.model.AcceptedTokens[token.GetToken()], now) {
 			tokens = append(tokens, token)
 		}
 	}
-	return tokens, nil
+	tokens, next := invite.PaginateTokens(tokens, opts)
+	return tokens, next, nil
 }
 
-func tokenIsExpired(token *invitepb.InviteToken) bool {
-	return token.Expiration != nil && token.Expiration.Seconds > uint64(time.Now().Unix())
+func (m *manager) AcceptToken(ctx context.Context, token string) error {
+	m.Lock()
+	defer m.Unlock()
+
+	if _, ok := m.model.Invites[token]; !ok {
+		return invite.ErrTokenNotFound
+	}
+	m.model.AcceptedTokens[token] = true
+	if err := m.model.save(); err != nil {
+		return errors.Wrap(err, "json: error saving model")
+	}
+	return nil
 }
 
 func (m *manager) AddRemoteUser(ctx context.Context, initiator *userpb.UserId, remoteUser *userpb.User) error {
@@ -227,15 +243,9 @@ func (m *manager) FindRemoteUsers(ctx context.Context, initiator *userpb.UserId,
 
 	users := []*userpb.User{}
 	for _, acceptedUser := range m.model.AcceptedUsers[initiator.GetOpaqueId()] {
-		if query == "" || userContains(acceptedUser, query) {
+		if query == "" || invite.UserMatches(acceptedUser, query) {
 			users = append(users, acceptedUser)
 		}
 	}
 	return users, nil
 }
-
-func userContains(u *userpb.User, query string) bool {
-	query = strings.ToLower(query)
-	return strings.Contains(strings.ToLower(u.Username), query) || strings.Contains(strings.ToLower(u.DisplayName), query) ||
-		strings.Contains(strings.ToLower(u.Mail), query) || strings.Contains(strings.ToLower(u.Id.OpaqueId), query)
-}
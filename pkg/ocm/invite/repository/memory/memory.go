@@ -20,8 +20,8 @@ package memory
 
 import (
 	"context"
-	"strings"
 	"sync"
+	"time"
 
 	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	invitepb "github.com/cs3org/go-cs3apis/cs3/ocm/invite/v1beta1"
@@ -38,14 +38,16 @@ func init() {
 // New returns a new invite manager.
 func New(m map[string]interface{}) (invite.Repository, error) {
 	return &manager{
-		Invites:       sync.Map{},
-		AcceptedUsers: sync.Map{},
+		Invites:        sync.Map{},
+		AcceptedUsers:  sync.Map{},
+		AcceptedTokens: sync.Map{},
 	}, nil
 }
 
 type manager struct {
-	Invites       sync.Map
-	AcceptedUsers sync.Map
+	Invites        sync.Map
+	AcceptedUsers  sync.Map
+	AcceptedTokens sync.Map
 }
 
 func (m *manager) AddToken(ctx context.Context, token *invitepb.InviteToken) error {
@@ -60,16 +62,27 @@ func (m *manager) GetToken(ctx context.Context, token string) (*invitepb.InviteT
 	return nil, invite.ErrTokenNotFound
 }
 
-func (m *manager) ListTokens(ctx context.Context, initiator *userpb.UserId) ([]*invitepb.InviteToken, error) {
+func (m *manager) ListTokens(ctx context.Context, initiator *userpb.UserId, opts *invite.ListTokensOptions) ([]*invitepb.InviteToken, string, error) {
+	now := time.Now()
 	tokens := []*invitepb.InviteToken{}
 	m.Invites.Range(func(_, value any) bool {
 		token := value.(*invitepb.InviteToken)
-		if utils.UserEqual(token.UserId, initiator) {
+		_, accepted := m.AcceptedTokens.Load(token.GetToken())
+		if utils.UserEqual(token.UserId, initiator) && opts.Matches(token, accepted, now) {
 			tokens = append(tokens, token)
 		}
 		return true
 	})
-	return tokens, nil
+	tokens, next := invite.PaginateTokens(tokens, opts)
+	return tokens, next, nil
+}
+
+func (m *manager) AcceptToken(ctx context.Context, token string) error {
+	if _, ok := m.Invites.Load(token); !ok {
+		return invite.ErrTokenNotFound
+	}
+	m.AcceptedTokens.Store(token, struct{}{})
+	return nil
 }
 
 func (m *manager) AddRemoteUser(ctx context.Context, initiator *userpb.UserId, remoteUser *userpb.User) error {
@@ -115,15 +128,9 @@ func (m *manager) FindRemoteUsers(ctx context.Context, initiator *userpb.UserId,
 	users := []*userpb.User{}
 	acceptedUsers := usersList.([]*userpb.User)
 	for _, acceptedUser := range acceptedUsers {
-		if query == "" || userContains(acceptedUser, query) {
+		if query == "" || invite.UserMatches(acceptedUser, query) {
 			users = append(users, acceptedUser)
 		}
 	}
 	return users, nil
 }
-
-func userContains(u *userpb.User, query string) bool {
-	query = strings.ToLower(query)
-	return strings.Contains(strings.ToLower(u.Username), query) || strings.Contains(strings.ToLower(u.DisplayName), query) ||
-		strings.Contains(strings.ToLower(u.Mail), query) || strings.Contains(strings.ToLower(u.Id.OpaqueId), query)
-}
@@ -41,7 +41,7 @@ import (
 // This module implement the invite.Repository interface as a mysql driver.
 //
 // The OCM Invitation tokens are saved in the table:
-//     ocm_tokens(*token*, initiator, expiration, description)
+//     ocm_tokens(*token*, initiator, expiration, description, accepted)
 //
 // The OCM remote user are saved in the table:
 //     ocm_remote_users(*initiator*, *opaque_user_id*, *idp*, email, display_name)
@@ -138,14 +138,40 @@ func convertToInviteToken(tkn dbToken) *invitepb.InviteToken {
 	}
 }
 
-func (m *mgr) ListTokens(ctx context.Context, initiator *userpb.UserId) ([]*invitepb.InviteToken, error) {
-	query := "SELECT token, initiator, expiration, description FROM ocm_tokens WHERE initiator=? AND expiration > NOW()"
+func (m *mgr) ListTokens(ctx context.Context, initiator *userpb.UserId, opts *invite.ListTokensOptions) ([]*invitepb.InviteToken, string, error) {
+	query := "SELECT token, initiator, expiration, description FROM ocm_tokens WHERE initiator=?"
+	params := []any{conversions.FormatUserID(initiator)}
+
+	if opts == nil {
+		opts = &invite.ListTokensOptions{}
+	}
+	switch opts.State {
+	case invite.TokenStatePending:
+		query += " AND NOT accepted AND expiration > NOW()"
+	case invite.TokenStateAccepted:
+		query += " AND accepted"
+	case invite.TokenStateExpired:
+		query += " AND NOT accepted AND expiration <= NOW()"
+	default:
+		query += " AND expiration > NOW()"
+	}
+	if opts.Cursor != "" {
+		query += " AND token > ?"
+		params = append(params, opts.Cursor)
+	}
+	query += " ORDER BY token"
+	if opts.PageSize != 0 {
+		// one more token tells whether there is a next page
+		query += " LIMIT ?"
+		params = append(params, opts.PageSize+1)
+	}
 
 	tokens := []*invitepb.InviteToken{}
-	rows, err := m.db.QueryContext(ctx, query, conversions.FormatUserID(initiator))
+	rows, err := m.db.QueryContext(ctx, query, params...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	defer rows.Close()
 
 	var tkn dbToken
 	for rows.Next() {
@@ -154,8 +180,23 @@ func (m *mgr) ListTokens(ctx context.Context, initiator *userpb.UserId) ([]*invi
 		}
 		tokens = append(tokens, convertToInviteToken(tkn))
 	}
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	var next string
+	if opts.PageSize != 0 && len(tokens) > opts.PageSize {
+		tokens = tokens[:opts.PageSize]
+		next = tokens[len(tokens)-1].Token
+	}
+	return tokens, next, nil
+}
 
-	return tokens, nil
+// AcceptToken marks the token as accepted by a remote user.
+func (m *mgr) AcceptToken(ctx context.Context, token string) error {
+	query := "UPDATE ocm_tokens SET accepted=TRUE WHERE token=?"
+	_, err := m.db.ExecContext(ctx, query, token)
+	return err
 }
 
 // AddRemoteUser stores the remote user.
@@ -0,0 +1,191 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package invite
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	invitepb "github.com/cs3org/go-cs3apis/cs3/ocm/invite/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"google.golang.org/grpc/metadata"
+)
+
+// As for the accepted users, the options of the ListInviteTokens
+// requests and the continuation token are exchanged in the grpc metadata.
+const (
+	// TokensPageSizeHeader is the request metadata holding the maximum number of tokens returned.
+	TokensPageSizeHeader = "x-ocm-tokens-page-size"
+	// TokensCursorHeader is the request metadata holding the continuation token
+	// returned with the previous page.
+	TokensCursorHeader = "x-ocm-tokens-cursor"
+	// TokensStateHeader is the request metadata holding the state of the listed tokens.
+	TokensStateHeader = "x-ocm-tokens-state"
+	// TokensNextCursorHeader is the response header holding the continuation token
+	// of the next page. It is missing in the last page.
+	TokensNextCursorHeader = "x-ocm-tokens-next-cursor"
+)
+
+// TokenState is the state of an invite token.
+type TokenState string
+
+const (
+	// TokenStatePending is the state of the tokens not yet accepted nor expired.
+	TokenStatePending TokenState = "pending"
+	// TokenStateAccepted is the state of the tokens accepted by at least a remote user.
+	TokenStateAccepted TokenState = "accepted"
+	// TokenStateExpired is the state of the tokens expired before being accepted.
+	TokenStateExpired TokenState = "expired"
+)
+
+// ListTokensOptions filters and paginates the listed tokens.
+// A zero page size means that all the tokens are returned. Without a state,
+// the valid tokens are returned, i.e. the ones not expired.
+type ListTokensOptions struct {
+	PageSize int
+	Cursor   string
+	State    TokenState
+}
+
+// GetTokenState returns the state of the token.
+func GetTokenState(token *invitepb.InviteToken, accepted bool, now time.Time) TokenState {
+	switch {
+	case accepted:
+		return TokenStateAccepted
+	case isExpired(token, now):
+		return TokenStateExpired
+	default:
+		return TokenStatePending
+	}
+}
+
+func isExpired(token *invitepb.InviteToken, now time.Time) bool {
+	return token.Expiration != nil && token.Expiration.Seconds <= uint64(now.Unix())
+}
+
+// Matches returns whether the token is listed with the options.
+func (o *ListTokensOptions) Matches(token *invitepb.InviteToken, accepted bool, now time.Time) bool {
+	if o == nil || o.State == "" {
+		// the accepted tokens are listed as well, until they expire
+		return !isExpired(token, now)
+	}
+	return GetTokenState(token, accepted, now) == o.State
+}
+
+// PaginateTokens sorts the tokens, so that the pages are stable, and returns
+// the page following the cursor, with the continuation token of the next page.
+// The cursor is the last token of the previous page.
+func PaginateTokens(tokens []*invitepb.InviteToken, o *ListTokensOptions) ([]*invitepb.InviteToken, string) {
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].Token < tokens[j].Token
+	})
+	if o == nil {
+		return tokens, ""
+	}
+	if o.Cursor != "" {
+		start := sort.Search(len(tokens), func(i int) bool {
+			return tokens[i].Token > o.Cursor
+		})
+		tokens = tokens[start:]
+	}
+	if o.PageSize == 0 || len(tokens) <= o.PageSize {
+		return tokens, ""
+	}
+	tokens = tokens[:o.PageSize]
+	return tokens, tokens[len(tokens)-1].Token
+}
+
+// ContextSetListTokensOptions returns a context holding the options
+// of the ListInviteTokens calls.
+func ContextSetListTokensOptions(ctx context.Context, o *ListTokensOptions) context.Context {
+	kv := []string{}
+	if o.PageSize != 0 {
+		kv = append(kv, TokensPageSizeHeader, strconv.Itoa(o.PageSize))
+	}
+	if o.Cursor != "" {
+		kv = append(kv, TokensCursorHeader, o.Cursor)
+	}
+	if o.State != "" {
+		kv = append(kv, TokensStateHeader, string(o.State))
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// ContextGetListTokensOptions returns the options of the incoming ListInviteTokens call.
+func ContextGetListTokensOptions(ctx context.Context) (*ListTokensOptions, error) {
+	o := &ListTokensOptions{}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return o, nil
+	}
+	if v := md.Get(TokensPageSizeHeader); len(v) != 0 {
+		var err error
+		if o.PageSize, err = strconv.Atoi(v[0]); err != nil || o.PageSize < 0 {
+			return nil, errtypes.BadRequest("invalid page size " + v[0])
+		}
+	}
+	if v := md.Get(TokensCursorHeader); len(v) != 0 {
+		o.Cursor = v[0]
+	}
+	if v := md.Get(TokensStateHeader); len(v) != 0 {
+		switch s := TokenState(v[0]); s {
+		case TokenStatePending, TokenStateAccepted, TokenStateExpired:
+			o.State = s
+		default:
+			return nil, errtypes.BadRequest("invalid token state " + v[0])
+		}
+	}
+	return o, nil
+}
+
+// ForwardListTokensOptions returns a context forwarding the options
+// of the incoming ListInviteTokens call to the outgoing one.
+func ForwardListTokensOptions(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	kv := []string{}
+	for _, k := range []string{TokensPageSizeHeader, TokensCursorHeader, TokensStateHeader} {
+		for _, v := range md.Get(k) {
+			kv = append(kv, k, v)
+		}
+	}
+	if len(kv) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// EncodeNextCursor returns the response header holding the continuation token.
+func EncodeNextCursor(cursor string) metadata.MD {
+	return metadata.Pairs(TokensNextCursorHeader, cursor)
+}
+
+// DecodeNextCursor reads the continuation token from the response header.
+// It is empty in the last page.
+func DecodeNextCursor(md metadata.MD) string {
+	v := md.Get(TokensNextCursorHeader)
+	if len(v) == 0 {
+		return ""
+	}
+	return v[0]
+}
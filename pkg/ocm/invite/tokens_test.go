@@ -0,0 +1,94 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package invite
+
+import (
+	"testing"
+	"time"
+
+	invitepb "github.com/cs3org/go-cs3apis/cs3/ocm/invite/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListTokensOptionsMatches(t *testing.T) {
+	now := time.Now()
+	valid := &invitepb.InviteToken{Token: "valid", Expiration: &typespb.Timestamp{Seconds: uint64(now.Add(time.Hour).Unix())}}
+	expired := &invitepb.InviteToken{Token: "expired", Expiration: &typespb.Timestamp{Seconds: uint64(now.Add(-time.Hour).Unix())}}
+
+	tests := []struct {
+		state    TokenState
+		token    *invitepb.InviteToken
+		accepted bool
+		expected bool
+	}{
+		{state: "", token: valid, expected: true},
+		{state: "", token: valid, accepted: true, expected: true},
+		{state: "", token: expired, expected: false},
+		{state: "", token: expired, accepted: true, expected: false},
+		{state: TokenStatePending, token: valid, expected: true},
+		{state: TokenStatePending, token: valid, accepted: true, expected: false},
+		{state: TokenStatePending, token: expired, expected: false},
+		{state: TokenStateAccepted, token: valid, accepted: true, expected: true},
+		{state: TokenStateAccepted, token: expired, accepted: true, expected: true},
+		{state: TokenStateAccepted, token: valid, expected: false},
+		{state: TokenStateExpired, token: expired, expected: true},
+		{state: TokenStateExpired, token: expired, accepted: true, expected: false},
+		{state: TokenStateExpired, token: valid, expected: false},
+	}
+
+	for _, tt := range tests {
+		o := &ListTokensOptions{State: tt.state}
+		assert.Equal(t, tt.expected, o.Matches(tt.token, tt.accepted, now), "state %q, token %s, accepted %v", tt.state, tt.token.Token, tt.accepted)
+	}
+}
+
+func TestPaginateTokens(t *testing.T) {
+	tokens := func() []*invitepb.InviteToken {
+		return []*invitepb.InviteToken{{Token: "c"}, {Token: "a"}, {Token: "e"}, {Token: "b"}, {Token: "d"}}
+	}
+	names := func(tokens []*invitepb.InviteToken) []string {
+		n := []string{}
+		for _, t := range tokens {
+			n = append(n, t.Token)
+		}
+		return n
+	}
+
+	page, next := PaginateTokens(tokens(), nil)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, names(page))
+	assert.Empty(t, next)
+
+	page, next = PaginateTokens(tokens(), &ListTokensOptions{PageSize: 2})
+	assert.Equal(t, []string{"a", "b"}, names(page))
+	assert.Equal(t, "b", next)
+
+	page, next = PaginateTokens(tokens(), &ListTokensOptions{PageSize: 2, Cursor: next})
+	assert.Equal(t, []string{"c", "d"}, names(page))
+	assert.Equal(t, "d", next)
+
+	page, next = PaginateTokens(tokens(), &ListTokensOptions{PageSize: 2, Cursor: next})
+	assert.Equal(t, []string{"e"}, names(page))
+	assert.Empty(t, next)
+
+	// the cursor does not need to be an existing token, e.g. if it was removed in the meantime
+	page, next = PaginateTokens(tokens(), &ListTokensOptions{Cursor: "bb"})
+	assert.Equal(t, []string{"c", "d", "e"}, names(page))
+	assert.Empty(t, next)
+}
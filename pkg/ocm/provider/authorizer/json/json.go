@@ -24,15 +24,21 @@ import (
 	"net"
 	"net/url"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
+	"time"
 
 	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
 	"github.com/cs3org/reva/pkg/errtypes"
 	"github.com/cs3org/reva/pkg/ocm/provider"
 	"github.com/cs3org/reva/pkg/ocm/provider/authorizer/registry"
+	"github.com/fsnotify/fsnotify"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
 )
 
 func init() {
@@ -48,21 +54,25 @@ func New(m map[string]interface{}) (provider.Authorizer, error) {
 	}
 	c.init()
 
-	f, err := os.ReadFile(c.Providers)
-	if err != nil {
-		return nil, err
+	a := &authorizer{
+		providerIPs: sync.Map{},
+		conf:        c,
 	}
-	providers := []*ocmprovider.ProviderInfo{}
-	err = json.Unmarshal(f, &providers)
-	if err != nil {
+	if err := a.load(); err != nil {
 		return nil, err
 	}
 
-	a := &authorizer{
-		providerIPs: sync.Map{},
-		conf:        c,
+	var watcher *fsnotify.Watcher
+	if c.WatchProviders {
+		w, err := a.watch()
+		if err != nil {
+			return nil, err
+		}
+		watcher = w
+	}
+	if c.RefreshInterval > 0 || watcher != nil {
+		go a.refreshProviders(watcher)
 	}
-	a.providers = a.getOCMProviders(providers)
 
 	return a, nil
 }
@@ -70,6 +80,11 @@ func New(m map[string]interface{}) (provider.Authorizer, error) {
 type config struct {
 	Providers             string `mapstructure:"providers"`
 	VerifyRequestHostname bool   `mapstructure:"verify_request_hostname"`
+	// RefreshInterval is the interval in seconds at which the providers file is reloaded.
+	// If 0, the file is not reloaded periodically.
+	RefreshInterval int `mapstructure:"refresh_interval"`
+	// WatchProviders reloads the providers file as soon as it changes on disk.
+	WatchProviders bool `mapstructure:"watch_providers"`
 }
 
 func (c *config) init() {
@@ -82,6 +97,95 @@ type authorizer struct {
 	providers   []*ocmprovider.ProviderInfo
 	providerIPs sync.Map
 	conf        *config
+	mutex       sync.RWMutex
+}
+
+// load reads the providers file and atomically replaces the providers in use.
+// On error, the providers in use are kept.
+func (a *authorizer) load() error {
+	f, err := os.ReadFile(a.conf.Providers)
+	if err != nil {
+		return errors.Wrap(err, "json: error reading the providers file")
+	}
+	providers := []*ocmprovider.ProviderInfo{}
+	if err := json.Unmarshal(f, &providers); err != nil {
+		return errors.Wrap(err, "json: error unmarshalling the providers file")
+	}
+	providers = a.getOCMProviders(providers)
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.providers = providers
+	return nil
+}
+
+// getProviders returns the snapshot of the providers currently in use,
+// which is never modified in place.
+func (a *authorizer) getProviders() []*ocmprovider.ProviderInfo {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.providers
+}
+
+// watch watches the directory of the providers file rather than the file itself,
+// so that the changes made by replacing the file are noticed too.
+func (a *authorizer) watch() (*fsnotify.Watcher, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, errors.Wrap(err, "json: error creating the providers file watcher")
+	}
+	if err := watcher.Add(filepath.Dir(a.conf.Providers)); err != nil {
+		watcher.Close()
+		return nil, errors.Wrap(err, "json: error watching the providers file")
+	}
+	return watcher, nil
+}
+
+func (a *authorizer) refreshProviders(watcher *fsnotify.Watcher) {
+	var tick <-chan time.Time
+	if a.conf.RefreshInterval > 0 {
+		ticker := time.NewTicker(time.Duration(a.conf.RefreshInterval) * time.Second)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	var events <-chan fsnotify.Event
+	var watchErrors <-chan error
+	if watcher != nil {
+		defer watcher.Close()
+		events = watcher.Events
+		watchErrors = watcher.Errors
+	}
+	work := make(chan os.Signal, 1)
+	signal.Notify(work, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT)
+
+	for {
+		select {
+		case <-work:
+			return
+		case <-tick:
+			a.reload()
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if filepath.Clean(ev.Name) == filepath.Clean(a.conf.Providers) && ev.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+				a.reload()
+			}
+		case err, ok := <-watchErrors:
+			if !ok {
+				watchErrors = nil
+				continue
+			}
+			log.Error().Err(err).Msg("json: error watching the providers file")
+		}
+	}
+}
+
+func (a *authorizer) reload() {
+	if err := a.load(); err != nil {
+		log.Error().Err(err).Msg("json: error reloading the ocm providers, keeping the previous ones")
+	}
 }
 
 func normalizeDomain(d string) (string, error) {
@@ -105,7 +209,7 @@ func (a *authorizer) GetInfoByDomain(ctx context.Context, domain string) (*ocmpr
 	if err != nil {
 		return nil, err
 	}
-	for _, p := range a.providers {
+	for _, p := range a.getProviders() {
 		if strings.Contains(p.Domain, normalizedDomain) {
 			return p, nil
 		}
@@ -119,9 +223,10 @@ func (a *authorizer) IsProviderAllowed(ctx context.Context, pi *ocmprovider.Prov
 	if err != nil {
 		return err
 	}
+	providers := a.getProviders()
 	var providerAuthorized bool
 	if normalizedDomain != "" {
-		for _, p := range a.providers {
+		for _, p := range providers {
 			if p.Domain == normalizedDomain {
 				providerAuthorized = true
 				break
@@ -141,7 +246,7 @@ func (a *authorizer) IsProviderAllowed(ctx context.Context, pi *ocmprovider.Prov
 	}
 
 	var ocmHost string
-	for _, p := range a.providers {
+	for _, p := range providers {
 		if p.Domain == normalizedDomain {
 			ocmHost, err = a.getOCMHost(p)
 			if err != nil {
@@ -182,8 +287,17 @@ func (a *authorizer) IsProviderAllowed(ctx context.Context, pi *ocmprovider.Prov
 	return nil
 }
 
+// AreProvidersAllowed checks the providers one by one against the list loaded in memory.
+func (a *authorizer) AreProvidersAllowed(ctx context.Context, providers []*ocmprovider.ProviderInfo) ([]error, error) {
+	errs := make([]error, 0, len(providers))
+	for _, pi := range providers {
+		errs = append(errs, a.IsProviderAllowed(ctx, pi))
+	}
+	return errs, nil
+}
+
 func (a *authorizer) ListAllProviders(ctx context.Context) ([]*ocmprovider.ProviderInfo, error) {
-	return a.providers, nil
+	return a.getProviders(), nil
 }
 
 func (a *authorizer) getOCMProviders(providers []*ocmprovider.ProviderInfo) (po []*ocmprovider.ProviderInfo) {
@@ -0,0 +1,93 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package json
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/stretchr/testify/assert"
+)
+
+const providers = `[
+	{"domain": "cern.ch", "services": [{"endpoint": {"type": {"name": "OCM"}}, "host": "https://sciencemesh.cern.ch/ocm"}]},
+	{"domain": "example.org", "services": [{"endpoint": {"type": {"name": "OCM"}}, "host": "https://example.org/ocm"}]}
+]`
+
+func TestAreProvidersAllowed(t *testing.T) {
+	providersFile := filepath.Join(t.TempDir(), "providers.json")
+	assert.NoError(t, os.WriteFile(providersFile, []byte(providers), 0600))
+
+	a, err := New(map[string]interface{}{"providers": providersFile})
+	assert.NoError(t, err)
+
+	errs, err := a.AreProvidersAllowed(context.Background(), []*ocmprovider.ProviderInfo{
+		{Domain: "cern.ch"},
+		{Domain: "unknown.org"},
+		{Domain: "https://example.org"},
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, errs, 3) {
+		assert.NoError(t, errs[0])
+		assert.IsType(t, errtypes.NotFound(""), errs[1])
+		assert.NoError(t, errs[2])
+	}
+}
+
+func TestReloadProviders(t *testing.T) {
+	providersFile := filepath.Join(t.TempDir(), "providers.json")
+	assert.NoError(t, os.WriteFile(providersFile, []byte(providers), 0600))
+
+	a, err := New(map[string]interface{}{"providers": providersFile})
+	assert.NoError(t, err)
+	assert.IsType(t, errtypes.NotFound(""), a.IsProviderAllowed(context.Background(), &ocmprovider.ProviderInfo{Domain: "other.org"}))
+
+	assert.NoError(t, os.WriteFile(providersFile, []byte(`[
+		{"domain": "other.org", "services": [{"endpoint": {"type": {"name": "OCM"}}, "host": "https://other.org/ocm"}]}
+	]`), 0600))
+	assert.NoError(t, a.(*authorizer).load())
+
+	assert.NoError(t, a.IsProviderAllowed(context.Background(), &ocmprovider.ProviderInfo{Domain: "other.org"}))
+	assert.IsType(t, errtypes.NotFound(""), a.IsProviderAllowed(context.Background(), &ocmprovider.ProviderInfo{Domain: "cern.ch"}))
+
+	// an invalid file is not loaded, and the previous providers are kept
+	assert.NoError(t, os.WriteFile(providersFile, []byte(`[{"domain": `), 0600))
+	assert.Error(t, a.(*authorizer).load())
+	assert.NoError(t, a.IsProviderAllowed(context.Background(), &ocmprovider.ProviderInfo{Domain: "other.org"}))
+}
+
+func TestWatchProviders(t *testing.T) {
+	providersFile := filepath.Join(t.TempDir(), "providers.json")
+	assert.NoError(t, os.WriteFile(providersFile, []byte(providers), 0600))
+
+	a, err := New(map[string]interface{}{"providers": providersFile, "watch_providers": true})
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(providersFile, []byte(`[
+		{"domain": "other.org", "services": [{"endpoint": {"type": {"name": "OCM"}}, "host": "https://other.org/ocm"}]}
+	]`), 0600))
+	assert.Eventually(t, func() bool {
+		return a.IsProviderAllowed(context.Background(), &ocmprovider.ProviderInfo{Domain: "other.org"}) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -167,6 +167,23 @@ func (a *authorizer) IsProviderAllowed(ctx context.Context, pi *ocmprovider.Prov
 	if err != nil {
 		return err
 	}
+	return a.isProviderAllowed(pi, providers)
+}
+
+// AreProvidersAllowed fetches the providers from Mentix only once to check all of them.
+func (a *authorizer) AreProvidersAllowed(ctx context.Context, pis []*ocmprovider.ProviderInfo) ([]error, error) {
+	providers, err := a.fetchProviders()
+	if err != nil {
+		return nil, err
+	}
+	errs := make([]error, 0, len(pis))
+	for _, pi := range pis {
+		errs = append(errs, a.isProviderAllowed(pi, providers))
+	}
+	return errs, nil
+}
+
+func (a *authorizer) isProviderAllowed(pi *ocmprovider.ProviderInfo, providers []*ocmprovider.ProviderInfo) error {
 	normalizedDomain, err := normalizeDomain(pi.Domain)
 	if err != nil {
 		return err
@@ -0,0 +1,403 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package meshdirectory
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/ocm/provider"
+	"github.com/cs3org/reva/pkg/ocm/provider/authorizer/registry"
+	"github.com/cs3org/reva/pkg/rhttp"
+	"github.com/mitchellh/mapstructure"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// PublicKeyProperty is the property of the providers holding their public key.
+// When the public keys are verified, the providers requesting access have to
+// present the same key registered for their domain in the mesh directory.
+const PublicKeyProperty = "public_key"
+
+func init() {
+	registry.Register("meshdirectory", New)
+}
+
+type config struct {
+	// URL is the endpoint of the mesh directory serving the list of providers.
+	URL     string `mapstructure:"url"`
+	Timeout int64  `mapstructure:"timeout"`
+	// RefreshInterval is the interval in seconds at which the providers are fetched again,
+	// 300 by default; a negative value disables the periodic refresh.
+	RefreshInterval int64 `mapstructure:"refresh_interval"`
+	// CacheFile is where the last fetched providers are stored, to be used
+	// on startup when the mesh directory is unreachable.
+	CacheFile             string `mapstructure:"cache_file"`
+	VerifyRequestHostname bool   `mapstructure:"verify_request_hostname"`
+	VerifyPublicKey       bool   `mapstructure:"verify_public_key" docs:"false;Whether the providers have to present the public key registered for their domain."`
+	Insecure              bool   `mapstructure:"insecure" docs:"false;Whether to skip certificate checks when sending requests."`
+}
+
+func (c *config) init() {
+	if c.URL == "" {
+		c.URL = "http://localhost:19001/meshdir/providers"
+	}
+	if c.Timeout == 0 {
+		c.Timeout = 10
+	}
+	if c.RefreshInterval == 0 {
+		c.RefreshInterval = 300
+	}
+}
+
+// cachedProviders is the content of the cache file.
+type cachedProviders struct {
+	ETag      string                      `json:"etag"`
+	Providers []*ocmprovider.ProviderInfo `json:"providers"`
+}
+
+type authorizer struct {
+	conf        *config
+	client      *http.Client
+	providerIPs sync.Map
+
+	// the snapshot of the providers currently in use, with the ETag it was served with
+	mutex     sync.RWMutex
+	providers []*ocmprovider.ProviderInfo
+	etag      string
+
+	// only one fetch at a time, without blocking the readers of the snapshot
+	fetching sync.Mutex
+}
+
+// New returns a new authorizer object.
+func New(m map[string]interface{}) (provider.Authorizer, error) {
+	c := &config{}
+	if err := mapstructure.Decode(m, c); err != nil {
+		err = errors.Wrap(err, "error decoding conf")
+		return nil, err
+	}
+	c.init()
+
+	a := &authorizer{
+		conf: c,
+		client: rhttp.GetHTTPClient(
+			rhttp.Context(context.Background()),
+			rhttp.Timeout(time.Duration(c.Timeout*int64(time.Second))),
+			rhttp.Insecure(c.Insecure),
+		),
+	}
+
+	if err := a.loadCache(); err != nil {
+		log.Warn().Err(err).Msg("meshdirectory: error loading the cached ocm providers")
+	}
+	if err := a.refresh(context.Background()); err != nil {
+		// the providers are fetched again at the next refresh
+		log.Error().Err(err).Msg("meshdirectory: error fetching the ocm providers, using the cached ones")
+	}
+	if c.RefreshInterval > 0 {
+		go a.refreshProviders()
+	}
+
+	return a, nil
+}
+
+// getProviders returns the snapshot of the providers currently in use,
+// which is never modified in place.
+func (a *authorizer) getProviders() []*ocmprovider.ProviderInfo {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	return a.providers
+}
+
+func (a *authorizer) setProviders(providers []*ocmprovider.ProviderInfo, etag string) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.providers = providers
+	a.etag = etag
+}
+
+// refresh fetches the providers from the mesh directory, sending the ETag of
+// the snapshot in use to skip the download if they did not change.
+// On error, the snapshot in use is kept.
+func (a *authorizer) refresh(ctx context.Context) error {
+	a.fetching.Lock()
+	defer a.fetching.Unlock()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.conf.URL, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json; charset=utf-8")
+
+	a.mutex.RLock()
+	if a.providers != nil && a.etag != "" {
+		req.Header.Set("If-None-Match", a.etag)
+	}
+	a.mutex.RUnlock()
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("meshdirectory: error fetching provider list from: %s", a.conf.URL))
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusNotModified:
+		return nil
+	case http.StatusOK:
+	default:
+		return errtypes.InternalError(fmt.Sprintf("meshdirectory: unexpected status %d fetching provider list from: %s", res.StatusCode, a.conf.URL))
+	}
+
+	providers := make([]*ocmprovider.ProviderInfo, 0)
+	if err := json.NewDecoder(res.Body).Decode(&providers); err != nil {
+		return errors.Wrap(err, "meshdirectory: error decoding the provider list")
+	}
+	providers = a.getOCMProviders(providers)
+	etag := res.Header.Get("ETag")
+
+	a.setProviders(providers, etag)
+	if err := a.storeCache(providers, etag); err != nil {
+		log.Warn().Err(err).Msg("meshdirectory: error caching the ocm providers")
+	}
+	return nil
+}
+
+func (a *authorizer) refreshProviders() {
+	ticker := time.NewTicker(time.Duration(a.conf.RefreshInterval) * time.Second)
+	defer ticker.Stop()
+	work := make(chan os.Signal, 1)
+	signal.Notify(work, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT)
+
+	for {
+		select {
+		case <-work:
+			return
+		case <-ticker.C:
+			if err := a.refresh(context.Background()); err != nil {
+				log.Error().Err(err).Msg("meshdirectory: error refreshing the ocm providers, keeping the previous ones")
+			}
+		}
+	}
+}
+
+func (a *authorizer) loadCache() error {
+	if a.conf.CacheFile == "" {
+		return nil
+	}
+	f, err := os.ReadFile(a.conf.CacheFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var cache cachedProviders
+	if err := json.Unmarshal(f, &cache); err != nil {
+		return err
+	}
+	a.setProviders(a.getOCMProviders(cache.Providers), cache.ETag)
+	return nil
+}
+
+// storeCache writes the cache file atomically, so that a crash while writing it
+// does not leave a truncated list behind.
+func (a *authorizer) storeCache(providers []*ocmprovider.ProviderInfo, etag string) error {
+	if a.conf.CacheFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(&cachedProviders{ETag: etag, Providers: providers})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(a.conf.CacheFile), filepath.Base(a.conf.CacheFile)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), a.conf.CacheFile)
+}
+
+func normalizeDomain(d string) (string, error) {
+	var urlString string
+	if strings.Contains(d, "://") {
+		urlString = d
+	} else {
+		urlString = "https://" + d
+	}
+
+	u, err := url.Parse(urlString)
+	if err != nil {
+		return "", err
+	}
+
+	return u.Hostname(), nil
+}
+
+func (a *authorizer) GetInfoByDomain(ctx context.Context, domain string) (*ocmprovider.ProviderInfo, error) {
+	normalizedDomain, err := normalizeDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range a.getProviders() {
+		if strings.Contains(p.Domain, normalizedDomain) {
+			return p, nil
+		}
+	}
+	return nil, errtypes.NotFound(domain)
+}
+
+func (a *authorizer) IsProviderAllowed(ctx context.Context, pi *ocmprovider.ProviderInfo) error {
+	return a.isProviderAllowed(pi, a.getProviders())
+}
+
+// AreProvidersAllowed checks all the providers against the same snapshot.
+func (a *authorizer) AreProvidersAllowed(ctx context.Context, pis []*ocmprovider.ProviderInfo) ([]error, error) {
+	providers := a.getProviders()
+	errs := make([]error, 0, len(pis))
+	for _, pi := range pis {
+		errs = append(errs, a.isProviderAllowed(pi, providers))
+	}
+	return errs, nil
+}
+
+func (a *authorizer) isProviderAllowed(pi *ocmprovider.ProviderInfo, providers []*ocmprovider.ProviderInfo) error {
+	normalizedDomain, err := normalizeDomain(pi.Domain)
+	if err != nil {
+		return err
+	}
+
+	var known *ocmprovider.ProviderInfo
+	for _, p := range providers {
+		if p.Domain == normalizedDomain {
+			known = p
+			break
+		}
+	}
+
+	switch {
+	case normalizedDomain == "" && !a.conf.VerifyPublicKey:
+		return nil
+	case known == nil:
+		return errtypes.NotFound(pi.GetDomain())
+	}
+
+	if a.conf.VerifyPublicKey {
+		if err := verifyPublicKey(pi, known); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case !a.conf.VerifyRequestHostname:
+		return nil
+	case len(pi.Services) == 0:
+		return errtypes.NotSupported(
+			fmt.Sprintf("meshdirectory: provider %s has no supported services", pi.GetDomain()))
+	}
+
+	ocmHost, err := a.getOCMHost(known)
+	if err != nil {
+		return err
+	}
+
+	var ipList []string
+	if hostIPs, ok := a.providerIPs.Load(ocmHost); ok {
+		ipList = hostIPs.([]string)
+	} else {
+		addr, err := net.LookupIP(ocmHost)
+		if err != nil {
+			return errors.Wrap(err,
+				fmt.Sprintf("meshdirectory: error looking up IPs for OCM endpoint %s", ocmHost))
+		}
+		for _, a := range addr {
+			ipList = append(ipList, a.String())
+		}
+		a.providerIPs.Store(ocmHost, ipList)
+	}
+
+	for _, ip := range ipList {
+		if ip == pi.Services[0].Host {
+			return nil
+		}
+	}
+	return errtypes.BadRequest(
+		fmt.Sprintf(
+			"Invalid requesting OCM endpoint IP %s of provider %s",
+			pi.Services[0].Host, pi.GetDomain()))
+}
+
+// verifyPublicKey checks that the provider presents the public key
+// registered in the mesh directory for its domain.
+func verifyPublicKey(pi, known *ocmprovider.ProviderInfo) error {
+	key := strings.TrimSpace(known.GetProperties()[PublicKeyProperty])
+	if key == "" {
+		return errtypes.NotSupported(
+			fmt.Sprintf("meshdirectory: provider %s has no public key registered", pi.GetDomain()))
+	}
+	if strings.TrimSpace(pi.GetProperties()[PublicKeyProperty]) != key {
+		return errtypes.PermissionDenied(
+			fmt.Sprintf("meshdirectory: provider %s did not present its registered public key", pi.GetDomain()))
+	}
+	return nil
+}
+
+func (a *authorizer) ListAllProviders(ctx context.Context) ([]*ocmprovider.ProviderInfo, error) {
+	return a.getProviders(), nil
+}
+
+func (a *authorizer) getOCMProviders(providers []*ocmprovider.ProviderInfo) (po []*ocmprovider.ProviderInfo) {
+	for _, p := range providers {
+		_, err := a.getOCMHost(p)
+		if err == nil {
+			po = append(po, p)
+		}
+	}
+	return
+}
+
+func (a *authorizer) getOCMHost(pi *ocmprovider.ProviderInfo) (string, error) {
+	for _, s := range pi.Services {
+		if s.GetEndpoint().GetType().GetName() == "OCM" {
+			return s.Host, nil
+		}
+	}
+	return "", errtypes.NotFound("OCM Host")
+}
@@ -0,0 +1,156 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package meshdirectory
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/stretchr/testify/assert"
+)
+
+const cernProvider = `{"domain": "cern.ch", "properties": {"public_key": "cern-key"}, "services": [{"endpoint": {"type": {"name": "OCM"}}, "host": "https://sciencemesh.cern.ch/ocm"}]}`
+const exampleProvider = `{"domain": "example.org", "services": [{"endpoint": {"type": {"name": "OCM"}}, "host": "https://example.org/ocm"}]}`
+
+// directory is a fake mesh directory serving a list of providers with its ETag.
+type directory struct {
+	mu          sync.Mutex
+	providers   string
+	etag        string
+	down        bool
+	fetched     int
+	notModified int
+}
+
+func (d *directory) set(providers, etag string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.providers, d.etag = providers, etag
+}
+
+func (d *directory) setDown(down bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.down = down
+}
+
+func (d *directory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.down {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("ETag", d.etag)
+	if r.Header.Get("If-None-Match") == d.etag {
+		d.notModified++
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	d.fetched++
+	_, _ = w.Write([]byte(d.providers))
+}
+
+func newDirectory(t *testing.T, providers, etag string) (*directory, string) {
+	d := &directory{providers: providers, etag: etag}
+	srv := httptest.NewServer(d)
+	t.Cleanup(srv.Close)
+	return d, srv.URL
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	d, url := newDirectory(t, "["+cernProvider+"]", `"v1"`)
+
+	a, err := New(map[string]interface{}{"url": url, "refresh_interval": -1})
+	assert.NoError(t, err)
+	assert.NoError(t, a.IsProviderAllowed(ctx, &ocmprovider.ProviderInfo{Domain: "cern.ch"}))
+	assert.IsType(t, errtypes.NotFound(""), a.IsProviderAllowed(ctx, &ocmprovider.ProviderInfo{Domain: "example.org"}))
+
+	// the list is not downloaded again if it did not change
+	assert.NoError(t, a.(*authorizer).refresh(ctx))
+	assert.Equal(t, 1, d.fetched)
+	assert.Equal(t, 1, d.notModified)
+
+	d.set("["+cernProvider+","+exampleProvider+"]", `"v2"`)
+	assert.NoError(t, a.(*authorizer).refresh(ctx))
+	assert.Equal(t, 2, d.fetched)
+	assert.NoError(t, a.IsProviderAllowed(ctx, &ocmprovider.ProviderInfo{Domain: "example.org"}))
+
+	// the last good list is kept while the directory is down
+	d.setDown(true)
+	assert.Error(t, a.(*authorizer).refresh(ctx))
+	assert.NoError(t, a.IsProviderAllowed(ctx, &ocmprovider.ProviderInfo{Domain: "example.org"}))
+	providers, err := a.ListAllProviders(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, providers, 2)
+}
+
+func TestCacheFile(t *testing.T) {
+	ctx := context.Background()
+	cacheFile := filepath.Join(t.TempDir(), "providers.json")
+	d, url := newDirectory(t, "["+cernProvider+"]", `"v1"`)
+
+	_, err := New(map[string]interface{}{"url": url, "cache_file": cacheFile, "refresh_interval": -1})
+	assert.NoError(t, err)
+
+	// a new instance starting while the directory is down uses the cached list
+	d.setDown(true)
+	a, err := New(map[string]interface{}{"url": url, "cache_file": cacheFile, "refresh_interval": -1})
+	assert.NoError(t, err)
+	assert.NoError(t, a.IsProviderAllowed(ctx, &ocmprovider.ProviderInfo{Domain: "cern.ch"}))
+
+	// and revalidates it with the cached ETag once the directory is back
+	d.setDown(false)
+	assert.NoError(t, a.(*authorizer).refresh(ctx))
+	assert.Equal(t, 1, d.fetched)
+	assert.Equal(t, 1, d.notModified)
+}
+
+func TestVerifyPublicKey(t *testing.T) {
+	ctx := context.Background()
+	_, url := newDirectory(t, "["+cernProvider+","+exampleProvider+"]", `"v1"`)
+
+	a, err := New(map[string]interface{}{"url": url, "refresh_interval": -1, "verify_public_key": true})
+	assert.NoError(t, err)
+
+	errs, err := a.AreProvidersAllowed(ctx, []*ocmprovider.ProviderInfo{
+		{Domain: "cern.ch", Properties: map[string]string{PublicKeyProperty: "cern-key"}},
+		{Domain: "cern.ch", Properties: map[string]string{PublicKeyProperty: "other-key"}},
+		{Domain: "cern.ch"},
+		{Domain: "example.org", Properties: map[string]string{PublicKeyProperty: "example-key"}},
+		{Domain: "unknown.org", Properties: map[string]string{PublicKeyProperty: "cern-key"}},
+		{Properties: map[string]string{PublicKeyProperty: "cern-key"}},
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, errs, 6) {
+		assert.NoError(t, errs[0])
+		assert.IsType(t, errtypes.PermissionDenied(""), errs[1])
+		assert.IsType(t, errtypes.PermissionDenied(""), errs[2])
+		assert.IsType(t, errtypes.NotSupported(""), errs[3])
+		assert.IsType(t, errtypes.NotFound(""), errs[4])
+		assert.IsType(t, errtypes.NotFound(""), errs[5])
+	}
+}
@@ -89,6 +89,10 @@ func (a *authorizer) IsProviderAllowed(ctx context.Context, provider *ocmprovide
 	return nil
 }
 
+func (a *authorizer) AreProvidersAllowed(ctx context.Context, providers []*ocmprovider.ProviderInfo) ([]error, error) {
+	return make([]error, len(providers)), nil
+}
+
 func (a *authorizer) ListAllProviders(ctx context.Context) ([]*ocmprovider.ProviderInfo, error) {
 	return a.providers, nil
 }
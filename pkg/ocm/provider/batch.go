@@ -0,0 +1,122 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package provider
+
+import (
+	"encoding/json"
+
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+)
+
+// Keys in the opaque map of the IsProviderAllowed requests and responses
+// used to check several providers in one call, encoded as json.
+const (
+	// ProvidersOpaqueKey holds the list of providers to check, in place of the single provider.
+	ProvidersOpaqueKey = "providers"
+	// ProvidersAllowedOpaqueKey holds the results of the check, in the order of the providers.
+	ProvidersAllowedOpaqueKey = "providers_allowed"
+)
+
+// ProviderAllowed is the result of the check of a provider in a batch.
+type ProviderAllowed struct {
+	Domain  string `json:"domain"`
+	Allowed bool   `json:"allowed"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// GetProviders reads the providers to check from the opaque map of the request.
+// It returns false if the request is for a single provider.
+func GetProviders(req *ocmprovider.IsProviderAllowedRequest) ([]*ocmprovider.ProviderInfo, bool, error) {
+	if req.Opaque == nil || req.Opaque.Map == nil {
+		return nil, false, nil
+	}
+	e, ok := req.Opaque.Map[ProvidersOpaqueKey]
+	if !ok {
+		return nil, false, nil
+	}
+	var providers []*ocmprovider.ProviderInfo
+	if err := json.Unmarshal(e.Value, &providers); err != nil {
+		return nil, true, errtypes.BadRequest("invalid list of providers: " + err.Error())
+	}
+	return providers, true, nil
+}
+
+// SetProviders sets the providers to check in the opaque map of the request.
+func SetProviders(req *ocmprovider.IsProviderAllowedRequest, providers []*ocmprovider.ProviderInfo) error {
+	v, err := json.Marshal(providers)
+	if err != nil {
+		return err
+	}
+	setOpaque(&req.Opaque, ProvidersOpaqueKey, v)
+	return nil
+}
+
+// GetProvidersAllowed reads the results of the check of several providers
+// from the opaque map of the response. It returns false if they are missing,
+// for example because the authorizer does not support the checks in batch.
+func GetProvidersAllowed(res *ocmprovider.IsProviderAllowedResponse) ([]*ProviderAllowed, bool, error) {
+	if res.Opaque == nil || res.Opaque.Map == nil {
+		return nil, false, nil
+	}
+	e, ok := res.Opaque.Map[ProvidersAllowedOpaqueKey]
+	if !ok {
+		return nil, false, nil
+	}
+	var results []*ProviderAllowed
+	if err := json.Unmarshal(e.Value, &results); err != nil {
+		return nil, true, err
+	}
+	return results, true, nil
+}
+
+// SetProvidersAllowed sets the results of the check of the providers, as returned
+// by AreProvidersAllowed, in the opaque map of the response.
+func SetProvidersAllowed(res *ocmprovider.IsProviderAllowedResponse, providers []*ocmprovider.ProviderInfo, errs []error) error {
+	results := make([]*ProviderAllowed, 0, len(providers))
+	for i, p := range providers {
+		r := &ProviderAllowed{Domain: p.GetDomain()}
+		switch {
+		case i >= len(errs):
+			r.Reason = "provider not checked"
+		case errs[i] != nil:
+			r.Reason = errs[i].Error()
+		default:
+			r.Allowed = true
+		}
+		results = append(results, r)
+	}
+	v, err := json.Marshal(results)
+	if err != nil {
+		return err
+	}
+	setOpaque(&res.Opaque, ProvidersAllowedOpaqueKey, v)
+	return nil
+}
+
+func setOpaque(o **typespb.Opaque, key string, value []byte) {
+	if *o == nil {
+		*o = &typespb.Opaque{}
+	}
+	if (*o).Map == nil {
+		(*o).Map = map[string]*typespb.OpaqueEntry{}
+	}
+	(*o).Map[key] = &typespb.OpaqueEntry{Decoder: "json", Value: value}
+}
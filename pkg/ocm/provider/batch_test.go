@@ -0,0 +1,66 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package provider
+
+import (
+	"errors"
+	"testing"
+
+	ocmprovider "github.com/cs3org/go-cs3apis/cs3/ocm/provider/v1beta1"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvidersOpaque(t *testing.T) {
+	req := &ocmprovider.IsProviderAllowedRequest{}
+	_, batch, err := GetProviders(req)
+	assert.NoError(t, err)
+	assert.False(t, batch)
+
+	providers := []*ocmprovider.ProviderInfo{{Domain: "cern.ch"}, {Domain: "example.org"}, {Domain: "unchecked.org"}}
+	assert.NoError(t, SetProviders(req, providers))
+	got, batch, err := GetProviders(req)
+	assert.NoError(t, err)
+	assert.True(t, batch)
+	if assert.Len(t, got, 3) {
+		assert.Equal(t, "example.org", got[1].Domain)
+	}
+
+	res := &ocmprovider.IsProviderAllowedResponse{}
+	_, ok, err := GetProvidersAllowed(res)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	assert.NoError(t, SetProvidersAllowed(res, providers, []error{nil, errors.New("not found")}))
+	results, ok, err := GetProvidersAllowed(res)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []*ProviderAllowed{
+		{Domain: "cern.ch", Allowed: true},
+		{Domain: "example.org", Reason: "not found"},
+		{Domain: "unchecked.org", Reason: "provider not checked"},
+	}, results)
+}
+
+func TestInvalidProvidersOpaque(t *testing.T) {
+	req := &ocmprovider.IsProviderAllowedRequest{}
+	setOpaque(&req.Opaque, ProvidersOpaqueKey, []byte("cern.ch"))
+	_, batch, err := GetProviders(req)
+	assert.True(t, batch)
+	assert.Error(t, err)
+}
@@ -32,6 +32,11 @@ type Authorizer interface {
 	// IsProviderAllowed checks if a given system provider is integrated into the OCM or not.
 	IsProviderAllowed(ctx context.Context, provider *ocmprovider.ProviderInfo) error
 
+	// AreProvidersAllowed checks several system providers at once. It returns, in the same
+	// order as the providers, nil for the allowed ones and the reason of the denial otherwise.
+	// The returned error is set only if none of the providers could be checked.
+	AreProvidersAllowed(ctx context.Context, providers []*ocmprovider.ProviderInfo) ([]error, error)
+
 	// ListAllProviders returns the information of all the providers registered in the mesh.
 	ListAllProviders(ctx context.Context) ([]*ocmprovider.ProviderInfo, error)
 }
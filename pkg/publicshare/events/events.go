@@ -0,0 +1,253 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package events notifies the changes of the public shares to an external sink,
+// e.g. to let a security team know when a public link is created.
+package events
+
+import (
+	"context"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/cs3org/reva/pkg/worker"
+	"github.com/rs/zerolog"
+)
+
+// Version is the version of the format of the events.
+// It is increased on every change that is not backwards compatible.
+const Version = 1
+
+// The types of the events.
+const (
+	ShareCreated = "public_share.created"
+	ShareUpdated = "public_share.updated"
+	ShareRevoked = "public_share.revoked"
+	// ShareRestored is emitted when an orphaned share is restored.
+	ShareRestored = "public_share.restored"
+)
+
+// The sinks the events can be sent to.
+const (
+	SinkLog     = "log"
+	SinkWebhook = "webhook"
+)
+
+// Event is a change of a public share.
+type Event struct {
+	Version   int       `json:"version"`
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Share     *Share    `json:"share"`
+}
+
+// Share holds the data of the public share in an event.
+type Share struct {
+	ID                string                        `json:"id"`
+	Token             string                        `json:"token"`
+	ResourceID        *ResourceID                   `json:"resource_id,omitempty"`
+	Owner             *UserID                       `json:"owner,omitempty"`
+	Creator           *UserID                       `json:"creator,omitempty"`
+	Permissions       *provider.ResourcePermissions `json:"permissions,omitempty"`
+	PasswordProtected bool                          `json:"password_protected"`
+	// Expiration is nil if the share never expires.
+	Expiration *time.Time `json:"expiration,omitempty"`
+}
+
+// ResourceID identifies the shared resource.
+type ResourceID struct {
+	StorageID string `json:"storage_id"`
+	OpaqueID  string `json:"opaque_id"`
+}
+
+// UserID identifies a user.
+type UserID struct {
+	Idp      string `json:"idp"`
+	OpaqueID string `json:"opaque_id"`
+}
+
+// NewEvent returns the event of the given type for a public share.
+func NewEvent(eventType string, s *link.PublicShare) *Event {
+	share := &Share{
+		ID:                s.GetId().GetOpaqueId(),
+		Token:             s.GetToken(),
+		Owner:             newUserID(s.GetOwner()),
+		Creator:           newUserID(s.GetCreator()),
+		Permissions:       s.GetPermissions().GetPermissions(),
+		PasswordProtected: s.GetPasswordProtected(),
+	}
+	if id := s.GetResourceId(); id != nil {
+		share.ResourceID = &ResourceID{StorageID: id.StorageId, OpaqueID: id.OpaqueId}
+	}
+	if exp := s.GetExpiration(); exp != nil && exp.Seconds != 0 {
+		t := time.Unix(int64(exp.Seconds), 0).UTC()
+		share.Expiration = &t
+	}
+	return &Event{
+		Version:   Version,
+		Type:      eventType,
+		Timestamp: time.Now().UTC(),
+		Share:     share,
+	}
+}
+
+func newUserID(id *userpb.UserId) *UserID {
+	if id == nil {
+		return nil
+	}
+	return &UserID{Idp: id.Idp, OpaqueID: id.OpaqueId}
+}
+
+// Sink receives the events.
+type Sink interface {
+	Send(ctx context.Context, e *Event) error
+}
+
+// Config configures where the events are sent.
+type Config struct {
+	// Sink is either "log" (the default) or "webhook".
+	Sink          string `mapstructure:"sink"`
+	WebhookURL    string `mapstructure:"webhook_url"`
+	WebhookSecret string `mapstructure:"webhook_secret"`
+	// WebhookTimeout is the timeout in seconds of the calls to the webhook.
+	WebhookTimeout int `mapstructure:"webhook_timeout"`
+	// WebhookRetries is the number of times a failed delivery is retried.
+	// Set it to -1 to disable the retries.
+	WebhookRetries int `mapstructure:"webhook_retries"`
+	// WebhookRetryBackoff is the wait in milliseconds before the first retry,
+	// doubled at every following one.
+	WebhookRetryBackoff int  `mapstructure:"webhook_retry_backoff"`
+	WebhookInsecure     bool `mapstructure:"webhook_insecure"`
+	// QueueSize is the number of events waiting to be sent, after which the new ones are dropped.
+	QueueSize int `mapstructure:"queue_size"`
+}
+
+func (c *Config) init() {
+	if c.Sink == "" {
+		c.Sink = SinkLog
+	}
+	if c.WebhookTimeout == 0 {
+		c.WebhookTimeout = 10
+	}
+	if c.WebhookRetries == 0 {
+		c.WebhookRetries = 3
+	}
+	if c.WebhookRetryBackoff == 0 {
+		c.WebhookRetryBackoff = 500
+	}
+	if c.QueueSize == 0 {
+		c.QueueSize = 1000
+	}
+}
+
+type queuedEvent struct {
+	log   *zerolog.Logger
+	event *Event
+}
+
+// Emitter sends the events to the sink asynchronously, in the order they are emitted.
+type Emitter struct {
+	sink    Sink
+	queue   chan *queuedEvent
+	workers *worker.Group
+}
+
+// NewEmitter returns an emitter sending the events to the configured sink.
+func NewEmitter(c *Config) (*Emitter, error) {
+	c.init()
+
+	var sink Sink
+	switch c.Sink {
+	case SinkLog:
+		sink = &LogSink{}
+	case SinkWebhook:
+		if c.WebhookURL == "" {
+			return nil, errtypes.BadRequest("events: webhook_url is required by the webhook sink")
+		}
+		sink = NewWebhookSink(c)
+	default:
+		return nil, errtypes.BadRequest("events: unknown sink " + c.Sink)
+	}
+	return newEmitter(sink, c.QueueSize), nil
+}
+
+func newEmitter(sink Sink, queueSize int) *Emitter {
+	e := &Emitter{
+		sink:    sink,
+		queue:   make(chan *queuedEvent, queueSize),
+		workers: worker.NewGroup(),
+	}
+	e.workers.Start("publicshare events", e.run)
+	return e
+}
+
+// Close stops sending the events, waiting for the one being sent
+// until ctx is done. The events still queued are dropped.
+func (e *Emitter) Close(ctx context.Context) error {
+	if e == nil {
+		return nil
+	}
+	return e.workers.Stop(ctx)
+}
+
+// Emit queues the event of the given type for the public share.
+// It never blocks: if the queue is full, the event is dropped.
+func (e *Emitter) Emit(ctx context.Context, eventType string, s *link.PublicShare) {
+	if e == nil || s == nil {
+		return
+	}
+	log := appctx.GetLogger(ctx)
+	ev := NewEvent(eventType, s)
+	select {
+	case e.queue <- &queuedEvent{log: log, event: ev}:
+	default:
+		log.Warn().Str("type", eventType).Str("share", ev.Share.ID).Msg("events: queue full, dropping public share event")
+	}
+}
+
+func (e *Emitter) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case q := <-e.queue:
+			// the event being sent is not interrupted when stopping
+			sendCtx := appctx.WithLogger(context.Background(), q.log)
+			if err := e.sink.Send(sendCtx, q.event); err != nil {
+				q.log.Error().Err(err).Str("type", q.event.Type).Str("share", q.event.Share.ID).Msg("events: error sending public share event")
+			}
+		}
+	}
+}
+
+// LogSink writes the events to the log.
+type LogSink struct{}
+
+// Send logs the event.
+func (LogSink) Send(ctx context.Context, e *Event) error {
+	appctx.GetLogger(ctx).Info().
+		Str("type", e.Type).
+		Int("version", e.Version).
+		Interface("share", e.Share).
+		Msg("public share event")
+	return nil
+}
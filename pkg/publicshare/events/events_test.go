@@ -0,0 +1,192 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
+	typespb "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/stretchr/testify/assert"
+)
+
+var testShare = &link.PublicShare{
+	Id:         &link.PublicShareId{OpaqueId: "42"},
+	Token:      "abcdef",
+	ResourceId: &provider.ResourceId{StorageId: "project", OpaqueId: "10"},
+	Owner:      &userpb.UserId{Idp: "cernbox.cern.ch", OpaqueId: "einstein"},
+	Creator:    &userpb.UserId{Idp: "cernbox.cern.ch", OpaqueId: "marie"},
+	Permissions: &link.PublicSharePermissions{
+		Permissions: &provider.ResourcePermissions{Stat: true, InitiateFileDownload: true},
+	},
+}
+
+// webhook is a test server recording the events it receives.
+type webhook struct {
+	mu       sync.Mutex
+	failures int
+	status   int
+	calls    int
+	received chan *http.Request
+	bodies   chan []byte
+}
+
+func newWebhook(t *testing.T, failures, status int) (*webhook, string) {
+	w := &webhook{failures: failures, status: status, received: make(chan *http.Request, 10), bodies: make(chan []byte, 10)}
+	srv := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		w.mu.Lock()
+		w.calls++
+		fail := w.calls <= w.failures
+		w.mu.Unlock()
+		if fail {
+			rw.WriteHeader(w.status)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		w.received <- r
+		w.bodies <- body
+	}))
+	t.Cleanup(srv.Close)
+	return w, srv.URL
+}
+
+func (w *webhook) getCalls() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.calls
+}
+
+func TestNewEvent(t *testing.T) {
+	e := NewEvent(ShareCreated, testShare)
+	assert.Equal(t, Version, e.Version)
+	assert.Equal(t, ShareCreated, e.Type)
+	assert.Equal(t, "42", e.Share.ID)
+	assert.Equal(t, "abcdef", e.Share.Token)
+	assert.Equal(t, &ResourceID{StorageID: "project", OpaqueID: "10"}, e.Share.ResourceID)
+	assert.Equal(t, &UserID{Idp: "cernbox.cern.ch", OpaqueID: "einstein"}, e.Share.Owner)
+	assert.False(t, e.Share.PasswordProtected)
+	assert.Nil(t, e.Share.Expiration)
+
+	share := *testShare
+	share.PasswordProtected = true
+	share.Expiration = &typespb.Timestamp{Seconds: 1700000000}
+	e = NewEvent(ShareUpdated, &share)
+	assert.True(t, e.Share.PasswordProtected)
+	if assert.NotNil(t, e.Share.Expiration) {
+		assert.Equal(t, int64(1700000000), e.Share.Expiration.Unix())
+	}
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	w, url := newWebhook(t, 0, 0)
+	emitter, err := NewEmitter(&Config{Sink: SinkWebhook, WebhookURL: url, WebhookSecret: "secret"})
+	assert.NoError(t, err)
+
+	emitter.Emit(context.Background(), ShareCreated, testShare)
+
+	select {
+	case r := <-w.received:
+		body := <-w.bodies
+		assert.Equal(t, ShareCreated, r.Header.Get(EventHeader))
+		assert.Equal(t, "1", r.Header.Get(VersionHeader))
+		assert.Equal(t, Sign([]byte("secret"), body), r.Header.Get(SignatureHeader))
+		assert.NotEqual(t, Sign([]byte("other"), body), r.Header.Get(SignatureHeader))
+
+		var e Event
+		assert.NoError(t, json.Unmarshal(body, &e))
+		assert.Equal(t, Version, e.Version)
+		assert.Equal(t, ShareCreated, e.Type)
+		assert.Equal(t, "42", e.Share.ID)
+		assert.Equal(t, "abcdef", e.Share.Token)
+		assert.True(t, e.Share.Permissions.InitiateFileDownload)
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the event to be delivered to the webhook")
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	w, url := newWebhook(t, 2, http.StatusServiceUnavailable)
+	c := &Config{Sink: SinkWebhook, WebhookURL: url, WebhookRetryBackoff: 1}
+	c.init()
+	assert.NoError(t, NewWebhookSink(c).Send(context.Background(), NewEvent(ShareCreated, testShare)))
+	assert.Equal(t, 3, w.getCalls())
+
+	// the client errors are not retried
+	w, url = newWebhook(t, 5, http.StatusBadRequest)
+	c = &Config{Sink: SinkWebhook, WebhookURL: url, WebhookRetryBackoff: 1}
+	c.init()
+	assert.Error(t, NewWebhookSink(c).Send(context.Background(), NewEvent(ShareCreated, testShare)))
+	assert.Equal(t, 1, w.getCalls())
+
+	// and the server errors only as many times as configured
+	w, url = newWebhook(t, 5, http.StatusInternalServerError)
+	c = &Config{Sink: SinkWebhook, WebhookURL: url, WebhookRetryBackoff: 1, WebhookRetries: 2}
+	c.init()
+	assert.Error(t, NewWebhookSink(c).Send(context.Background(), NewEvent(ShareCreated, testShare)))
+	assert.Equal(t, 3, w.getCalls())
+}
+
+// blockingSink never returns until it is released.
+type blockingSink struct {
+	release chan struct{}
+}
+
+func (s *blockingSink) Send(ctx context.Context, e *Event) error {
+	<-s.release
+	return nil
+}
+
+func TestEmitNeverBlocks(t *testing.T) {
+	sink := &blockingSink{release: make(chan struct{})}
+	defer close(sink.release)
+	emitter := newEmitter(sink, 1)
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 10; i++ {
+			emitter.Emit(context.Background(), ShareCreated, testShare)
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the events to be dropped instead of blocking")
+	}
+
+	// a nil emitter, e.g. in the tests of the managers, does nothing
+	var nilEmitter *Emitter
+	nilEmitter.Emit(context.Background(), ShareCreated, testShare)
+}
+
+func TestNewEmitterInvalidConfig(t *testing.T) {
+	_, err := NewEmitter(&Config{Sink: SinkWebhook})
+	assert.Error(t, err)
+	_, err = NewEmitter(&Config{Sink: "kafka"})
+	assert.Error(t, err)
+}
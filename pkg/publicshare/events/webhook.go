@@ -0,0 +1,130 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/cs3org/reva/pkg/appctx"
+	"github.com/cs3org/reva/pkg/rhttp"
+	"github.com/pkg/errors"
+)
+
+// Headers of the requests to the webhook.
+const (
+	// EventHeader holds the type of the event.
+	EventHeader = "X-Reva-Event"
+	// VersionHeader holds the version of the format of the event.
+	VersionHeader = "X-Reva-Event-Version"
+	// SignatureHeader holds the hex encoded HMAC-SHA256 of the body,
+	// keyed with the shared secret and prefixed with "sha256=".
+	SignatureHeader = "X-Reva-Signature"
+)
+
+// WebhookSink posts the events as json to an HTTP endpoint.
+type WebhookSink struct {
+	url     string
+	secret  []byte
+	retries int
+	backoff time.Duration
+	client  *http.Client
+}
+
+// NewWebhookSink returns a sink posting the events to the configured webhook.
+func NewWebhookSink(c *Config) *WebhookSink {
+	return &WebhookSink{
+		url:     c.WebhookURL,
+		secret:  []byte(c.WebhookSecret),
+		retries: c.WebhookRetries,
+		backoff: time.Duration(c.WebhookRetryBackoff) * time.Millisecond,
+		client: rhttp.GetHTTPClient(
+			rhttp.Timeout(time.Duration(c.WebhookTimeout)*time.Second),
+			rhttp.Insecure(c.WebhookInsecure),
+		),
+	}
+}
+
+// Sign returns the signature of the body sent with the given secret.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	_, _ = mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Send posts the event, retrying with an exponential backoff while the
+// webhook is unreachable or fails with a server error.
+func (s *WebhookSink) Send(ctx context.Context, e *Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	backoff := s.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := s.post(ctx, e, body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= s.retries {
+			return err
+		}
+		appctx.GetLogger(ctx).Warn().Err(err).Int("attempt", attempt+1).Msg("events: error calling the webhook, retrying")
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (s *WebhookSink) post(ctx context.Context, e *Event, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, e.Type)
+	req.Header.Set(VersionHeader, strconv.Itoa(e.Version))
+	if len(s.secret) > 0 {
+		req.Header.Set(SignatureHeader, Sign(s.secret, body))
+	}
+
+	res, err := s.client.Do(req)
+	if err != nil {
+		return true, errors.Wrap(err, "events: error calling the webhook")
+	}
+	defer res.Body.Close()
+	_, _ = io.Copy(io.Discard, res.Body)
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return false, nil
+	case res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests:
+		return true, fmt.Errorf("events: webhook responded with status %d", res.StatusCode)
+	default:
+		return false, fmt.Errorf("events: webhook rejected the event with status %d", res.StatusCode)
+	}
+}
@@ -56,6 +56,9 @@ func New(c map[string]interface{}) (publicshare.Manager, error) {
 	}
 
 	conf.init()
+	if err := conf.SigningKeys.Init(); err != nil {
+		return nil, err
+	}
 
 	m := manager{
 		mutex:                      &sync.Mutex{},
@@ -63,6 +66,7 @@ func New(c map[string]interface{}) (publicshare.Manager, error) {
 		passwordHashCost:           conf.SharePasswordHashCost,
 		janitorRunInterval:         conf.JanitorRunInterval,
 		enableExpiredSharesCleanup: conf.EnableExpiredSharesCleanup,
+		signingKeys:                conf.SigningKeys,
 	}
 
 	// attempt to create the db file
@@ -95,6 +99,8 @@ type config struct {
 	SharePasswordHashCost      int    `mapstructure:"password_hash_cost"`
 	JanitorRunInterval         int    `mapstructure:"janitor_run_interval"`
 	EnableExpiredSharesCleanup bool   `mapstructure:"enable_expired_shares_cleanup"`
+	// SigningKeys are the server secrets mixed in the signatures of the shares.
+	SigningKeys publicshare.SigningKeys `mapstructure:",squash"`
 }
 
 func (c *config) init() {
@@ -116,6 +122,7 @@ type manager struct {
 	passwordHashCost           int
 	janitorRunInterval         int
 	enableExpiredSharesCleanup bool
+	signingKeys                publicshare.SigningKeys
 }
 
 func (m *manager) startJanitorRun() {
@@ -306,7 +313,7 @@ func (m *manager) GetPublicShare(ctx context.Context, u *user.User, ref *link.Pu
 			return nil, errors.New("no shares found by token")
 		}
 		if ps.PasswordProtected && sign {
-			err := publicshare.AddSignature(ps, pw)
+			err := m.signingKeys.AddSignature(ps, pw)
 			if err != nil {
 				return nil, err
 			}
@@ -339,7 +346,7 @@ func (m *manager) GetPublicShare(ctx context.Context, u *user.User, ref *link.Pu
 				return nil, errors.New("no shares found by id:" + ref.GetId().String())
 			}
 			if ps.PasswordProtected && sign {
-				err := publicshare.AddSignature(&ps, passDB)
+				err := m.signingKeys.AddSignature(&ps, passDB)
 				if err != nil {
 					return nil, err
 				}
@@ -374,7 +381,7 @@ func (m *manager) ListPublicShares(ctx context.Context, u *user.User, filters []
 		}
 
 		if local.PublicShare.PasswordProtected && sign {
-			if err := publicshare.AddSignature(&local.PublicShare, local.Password); err != nil {
+			if err := m.signingKeys.AddSignature(&local.PublicShare, local.Password); err != nil {
 				return nil, err
 			}
 		}
@@ -519,9 +526,9 @@ func (m *manager) GetPublicShareByToken(ctx context.Context, token string, auth
 			}
 
 			if local.PasswordProtected {
-				if authenticate(&local, passDB, auth) {
+				if authenticate(&local, passDB, auth, &m.signingKeys) {
 					if sign {
-						err := publicshare.AddSignature(&local, passDB)
+						err := m.signingKeys.AddSignature(&local, passDB)
 						if err != nil {
 							return nil, err
 						}
@@ -559,7 +566,7 @@ func (m *manager) writeDB(db map[string]interface{}) error {
 	return os.WriteFile(m.file, dbAsJSON, 0644)
 }
 
-func authenticate(share *link.PublicShare, pw string, auth *link.PublicShareAuthentication) bool {
+func authenticate(share *link.PublicShare, pw string, auth *link.PublicShareAuthentication, keys *publicshare.SigningKeys) bool {
 	switch {
 	case auth.GetPassword() != "":
 		if err := bcrypt.CompareHashAndPassword([]byte(pw), []byte(auth.GetPassword())); err == nil {
@@ -572,13 +579,7 @@ func authenticate(share *link.PublicShare, pw string, auth *link.PublicShareAuth
 		if now.After(expiration) {
 			return false
 		}
-		s, err := publicshare.CreateSignature(share.Token, pw, expiration)
-		if err != nil {
-			// TODO(labkode): pass ctx to log error
-			// Now we are blind
-			return false
-		}
-		return sig.GetSignature() == s
+		return keys.Verify(share.Token, pw, sig.GetSignature(), expiration)
 	}
 	return false
 }
@@ -20,16 +20,15 @@ package publicshare
 
 import (
 	"context"
-	"crypto/hmac"
 	"crypto/sha256"
-	"crypto/sha512"
-	"encoding/hex"
+	"encoding/json"
 	"time"
 
 	user "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
 	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
 	provider "github.com/cs3org/go-cs3apis/cs3/storage/provider/v1beta1"
 	typesv1beta1 "github.com/cs3org/go-cs3apis/cs3/types/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
 	"github.com/cs3org/reva/pkg/utils"
 )
 
@@ -43,6 +42,93 @@ type Manager interface {
 	GetPublicShareByToken(ctx context.Context, token string, auth *link.PublicShareAuthentication, sign bool) (*link.PublicShare, error)
 }
 
+// LastAccessedOpaqueKey is the key in the opaque map of the GetPublicShare and
+// ListPublicShares responses holding the unix time of the last access to the
+// returned shares, as a json object indexed by share id. The shares that were
+// never accessed are omitted.
+const LastAccessedOpaqueKey = "last_accessed"
+
+// AccessTracker is implemented by the managers tracking when
+// the public shares are accessed through their token.
+type AccessTracker interface {
+	// LastAccessed returns the time of the last access to the shares with the given ids.
+	// The shares that were never accessed are omitted.
+	LastAccessed(ctx context.Context, ids []string) (map[string]time.Time, error)
+}
+
+// Restorer is implemented by the managers able to restore the shares
+// orphaned once expired.
+type Restorer interface {
+	// RestorePublicShare restores an orphaned share of the user, optionally with a new expiration.
+	RestorePublicShare(ctx context.Context, u *user.User, ref *link.PublicShareReference, expiration *typesv1beta1.Timestamp) (*link.PublicShare, error)
+}
+
+// UpdatesOpaqueKey is the key in the opaque map of the UpdatePublicShare request
+// holding several updates to apply at once, as a json array of updates.
+// When set, the update of the request is ignored.
+const UpdatesOpaqueKey = "updates"
+
+// BatchUpdater is implemented by the managers able to apply atomically
+// several updates to a public share.
+type BatchUpdater interface {
+	Manager
+	// UpdatePublicShareFields applies all the updates to the share,
+	// or none of them if any is invalid, and returns the updated share.
+	UpdatePublicShareFields(ctx context.Context, u *user.User, ref *link.PublicShareReference, updates []*link.UpdatePublicShareRequest_Update) (*link.PublicShare, error)
+}
+
+// GetUpdates returns the updates of the request: the ones in the opaque,
+// if any, or else the single update of the request.
+func GetUpdates(req *link.UpdatePublicShareRequest) ([]*link.UpdatePublicShareRequest_Update, error) {
+	e, ok := req.GetOpaque().GetMap()[UpdatesOpaqueKey]
+	if !ok {
+		return []*link.UpdatePublicShareRequest_Update{req.GetUpdate()}, nil
+	}
+	if e.Decoder != "json" {
+		return nil, errtypes.BadRequest("unsupported decoder of the updates " + e.Decoder)
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal(e.Value, &raw); err != nil {
+		return nil, errtypes.BadRequest("invalid updates: " + err.Error())
+	}
+	if len(raw) == 0 {
+		return nil, errtypes.BadRequest("no updates")
+	}
+	updates := make([]*link.UpdatePublicShareRequest_Update, 0, len(raw))
+	for _, r := range raw {
+		u := &link.UpdatePublicShareRequest_Update{}
+		if err := utils.UnmarshalJSONToProtoV1(r, u); err != nil {
+			return nil, errtypes.BadRequest("invalid update: " + err.Error())
+		}
+		updates = append(updates, u)
+	}
+	return updates, nil
+}
+
+// SetUpdates sets the updates to apply at once in the opaque of the request.
+func SetUpdates(req *link.UpdatePublicShareRequest, updates []*link.UpdatePublicShareRequest_Update) error {
+	raw := make([]json.RawMessage, 0, len(updates))
+	for _, u := range updates {
+		b, err := utils.MarshalProtoV1ToJSON(u)
+		if err != nil {
+			return err
+		}
+		raw = append(raw, b)
+	}
+	v, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	if req.Opaque == nil {
+		req.Opaque = &typesv1beta1.Opaque{}
+	}
+	if req.Opaque.Map == nil {
+		req.Opaque.Map = map[string]*typesv1beta1.OpaqueEntry{}
+	}
+	req.Opaque.Map[UpdatesOpaqueKey] = &typesv1beta1.OpaqueEntry{Decoder: "json", Value: v}
+	return nil
+}
+
 // CreateSignature calculates a signature for a public share.
 func CreateSignature(token, pw string, expiration time.Time) (string, error) {
 	h := sha256.New()
@@ -54,27 +140,25 @@ func CreateSignature(token, pw string, expiration time.Time) (string, error) {
 	key := make([]byte, 0, 32)
 	key = h.Sum(key)
 
-	mac := hmac.New(sha512.New512_256, key)
-	_, err = mac.Write([]byte(token + "|" + expiration.Format(time.RFC3339)))
-	if err != nil {
-		return "", err
-	}
-
-	sig := make([]byte, 0, 32)
-	sig = mac.Sum(sig)
-
-	return hex.EncodeToString(sig), nil
+	return createSignature(key, token, expiration)
 }
 
+// signatureValidity is how long the signatures of the shares are valid.
+const signatureValidity = 30 * time.Minute
+
 // AddSignature augments a public share with a signature.
 // The signature has a validity of 30 minutes.
 func AddSignature(share *link.PublicShare, pw string) error {
-	expiration := time.Now().Add(time.Minute * 30)
+	expiration := time.Now().Add(signatureValidity)
 	sig, err := CreateSignature(share.Token, pw, expiration)
 	if err != nil {
 		return err
 	}
+	setSignature(share, sig, expiration)
+	return nil
+}
 
+func setSignature(share *link.PublicShare, sig string, expiration time.Time) {
 	share.Signature = &link.ShareSignature{
 		Signature: sig,
 		SignatureExpiration: &typesv1beta1.Timestamp{
@@ -82,7 +166,6 @@ func AddSignature(share *link.PublicShare, pw string) error {
 			Nanos:   uint32(expiration.UnixNano() % 1000000000),
 		},
 	}
-	return nil
 }
 
 // ResourceIDFilter is an abstraction for creating filter by resource id.
@@ -143,3 +226,65 @@ func IsExpired(s *link.PublicShare) bool {
 	expiration := time.Unix(int64(s.Expiration.GetSeconds()), int64(s.Expiration.GetNanos()))
 	return s.Expiration != nil && expiration.Before(time.Now())
 }
+
+// Fields by which public shares can be ordered when listing them.
+const (
+	OrderByCtime       = "ctime"
+	OrderByExpiration  = "expiration"
+	OrderByDisplayName = "display_name"
+)
+
+// ListOrder defines how the public shares returned by ListPublicShares are sorted.
+type ListOrder struct {
+	By         string
+	Descending bool
+}
+
+type listOrderKey struct{}
+
+// ContextSetListOrder stores the ordering for listing public shares in the context.
+func ContextSetListOrder(ctx context.Context, o *ListOrder) context.Context {
+	return context.WithValue(ctx, listOrderKey{}, o)
+}
+
+// ContextGetListOrder returns the ordering for listing public shares stored in the context.
+func ContextGetListOrder(ctx context.Context) (*ListOrder, bool) {
+	o, ok := ctx.Value(listOrderKey{}).(*ListOrder)
+	return o, ok
+}
+
+type includeInternalKey struct{}
+
+// ContextSetIncludeInternal stores in the context whether ListPublicShares
+// also returns the internal shares, which are otherwise excluded.
+func ContextSetIncludeInternal(ctx context.Context, include bool) context.Context {
+	return context.WithValue(ctx, includeInternalKey{}, include)
+}
+
+// ContextGetIncludeInternal returns whether the internal shares have to be listed,
+// as stored in the context.
+func ContextGetIncludeInternal(ctx context.Context) (bool, bool) {
+	include, ok := ctx.Value(includeInternalKey{}).(bool)
+	return include, ok
+}
+
+// ExpirationWindow restricts the public shares returned by ListPublicShares
+// to the ones expiring in the window, whose bounds are ignored when zero.
+// The shares that never expire are excluded.
+type ExpirationWindow struct {
+	From time.Time
+	To   time.Time
+}
+
+type expirationWindowKey struct{}
+
+// ContextSetExpirationWindow stores the expiration window for listing public shares in the context.
+func ContextSetExpirationWindow(ctx context.Context, w *ExpirationWindow) context.Context {
+	return context.WithValue(ctx, expirationWindowKey{}, w)
+}
+
+// ContextGetExpirationWindow returns the expiration window for listing public shares stored in the context.
+func ContextGetExpirationWindow(ctx context.Context) (*ExpirationWindow, bool) {
+	w, ok := ctx.Value(expirationWindowKey{}).(*ExpirationWindow)
+	return w, ok
+}
@@ -0,0 +1,155 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package publicshare
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	link "github.com/cs3org/go-cs3apis/cs3/sharing/link/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+)
+
+// keyIDSeparator separates the key id from the signature in the versioned signatures.
+const keyIDSeparator = ":"
+
+// SigningKeys are the server secrets mixed in the signatures of the public shares,
+// by key id. The signatures embed the id of the key they are created with, so that
+// the keys being rotated out keep verifying the signatures issued before the
+// rotation. Without keys, the signatures are derived from the share password only
+// and carry no key id.
+type SigningKeys struct {
+	// CurrentKey is the id of the key signing the shares.
+	CurrentKey string            `mapstructure:"signing_key_id"`
+	Keys       map[string]string `mapstructure:"signing_keys"`
+	// GracePeriod is the time in seconds during which the signatures without key id,
+	// issued before the keys were configured, are still accepted.
+	// Defaults to the validity of the signatures.
+	GracePeriod int `mapstructure:"signing_grace_period"`
+
+	unversionedUntil time.Time
+}
+
+// Init validates the keys and starts the grace period of the signatures without key id.
+func (k *SigningKeys) Init() error {
+	if err := k.Validate(); err != nil {
+		return err
+	}
+	grace := signatureValidity
+	if k.GracePeriod > 0 {
+		grace = time.Duration(k.GracePeriod) * time.Second
+	}
+	k.unversionedUntil = time.Now().Add(grace)
+	return nil
+}
+
+// Validate checks that the current key is one of the keys, and that the ids
+// can be embedded in the signatures.
+func (k *SigningKeys) Validate() error {
+	if k.CurrentKey == "" {
+		if len(k.Keys) != 0 {
+			return errtypes.BadRequest("signing_key_id is required by signing_keys")
+		}
+		return nil
+	}
+	if _, ok := k.Keys[k.CurrentKey]; !ok {
+		return errtypes.BadRequest("signing key " + k.CurrentKey + " not found in signing_keys")
+	}
+	for id, secret := range k.Keys {
+		if id == "" || strings.Contains(id, keyIDSeparator) {
+			return errtypes.BadRequest("invalid signing key id " + id)
+		}
+		if secret == "" {
+			return errtypes.BadRequest("empty signing key " + id)
+		}
+	}
+	return nil
+}
+
+// Sign creates the signature of the token, valid until the expiration,
+// with the current key.
+func (k *SigningKeys) Sign(token, pw string, expiration time.Time) (string, error) {
+	if k == nil || k.CurrentKey == "" {
+		return CreateSignature(token, pw, expiration)
+	}
+	sig, err := createSignature(signingKey(k.Keys[k.CurrentKey], pw), token, expiration)
+	if err != nil {
+		return "", err
+	}
+	return k.CurrentKey + keyIDSeparator + sig, nil
+}
+
+// Verify checks the signature of the token with the key it embeds.
+// The signatures without key id are only accepted when no key is configured,
+// or during the grace period following the configuration of the keys.
+func (k *SigningKeys) Verify(token, pw, sig string, expiration time.Time) bool {
+	var expected string
+	var err error
+	if id, _, versioned := strings.Cut(sig, keyIDSeparator); versioned {
+		if k == nil {
+			return false
+		}
+		secret, ok := k.Keys[id]
+		if !ok {
+			return false
+		}
+		expected, err = createSignature(signingKey(secret, pw), token, expiration)
+		expected = id + keyIDSeparator + expected
+	} else {
+		if k != nil && k.CurrentKey != "" && !time.Now().Before(k.unversionedUntil) {
+			return false
+		}
+		expected, err = CreateSignature(token, pw, expiration)
+	}
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(expected))
+}
+
+// AddSignature augments a public share with a signature created with the current key.
+// The signature has a validity of 30 minutes.
+func (k *SigningKeys) AddSignature(share *link.PublicShare, pw string) error {
+	expiration := time.Now().Add(signatureValidity)
+	sig, err := k.Sign(share.Token, pw, expiration)
+	if err != nil {
+		return err
+	}
+	setSignature(share, sig, expiration)
+	return nil
+}
+
+// signingKey derives the signing key of a share from the server secret and its password.
+func signingKey(secret, pw string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	_, _ = mac.Write([]byte(pw))
+	return mac.Sum(nil)
+}
+
+func createSignature(key []byte, token string, expiration time.Time) (string, error) {
+	mac := hmac.New(sha512.New512_256, key)
+	if _, err := mac.Write([]byte(token + "|" + expiration.Format(time.RFC3339))); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
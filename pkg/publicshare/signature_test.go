@@ -0,0 +1,131 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package publicshare
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigningKeysRotation(t *testing.T) {
+	expiration := time.Now().Add(time.Hour)
+	old := &SigningKeys{CurrentKey: "2023", Keys: map[string]string{"2023": "old secret"}}
+	rotated := &SigningKeys{CurrentKey: "2024", Keys: map[string]string{"2023": "old secret", "2024": "new secret"}}
+	retired := &SigningKeys{CurrentKey: "2024", Keys: map[string]string{"2024": "new secret"}}
+
+	sig, err := old.Sign("token", "hash", expiration)
+	if err != nil {
+		t.Fatalf("not expected error while signing: %v", err)
+	}
+	if !strings.HasPrefix(sig, "2023:") {
+		t.Fatalf("expected the signature to embed the key id, got %s", sig)
+	}
+
+	tests := []struct {
+		name     string
+		keys     *SigningKeys
+		token    string
+		pw       string
+		sig      string
+		expected bool
+	}{
+		{name: "same key", keys: old, token: "token", pw: "hash", sig: sig, expected: true},
+		{name: "rotated key", keys: rotated, token: "token", pw: "hash", sig: sig, expected: true},
+		{name: "retired key", keys: retired, token: "token", pw: "hash", sig: sig},
+		{name: "other token", keys: rotated, token: "other", pw: "hash", sig: sig},
+		{name: "other password", keys: rotated, token: "token", pw: "other", sig: sig},
+		{name: "forged key id", keys: rotated, token: "token", pw: "hash", sig: "2024" + strings.TrimPrefix(sig, "2023")},
+		{name: "versioned without keys", keys: &SigningKeys{}, token: "token", pw: "hash", sig: sig},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.keys.Verify(tt.token, tt.pw, tt.sig, expiration); got != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestSigningKeysUnversioned(t *testing.T) {
+	expiration := time.Now().Add(time.Hour)
+	sig, err := CreateSignature("token", "hash", expiration)
+	if err != nil {
+		t.Fatalf("not expected error while signing: %v", err)
+	}
+
+	none := &SigningKeys{}
+	if s, _ := none.Sign("token", "hash", expiration); s != sig {
+		t.Fatalf("expected unversioned signature %s without keys, got %s", sig, s)
+	}
+	if !none.Verify("token", "hash", sig, expiration) {
+		t.Fatalf("expected unversioned signature to be valid without keys")
+	}
+
+	keys := &SigningKeys{CurrentKey: "2024", Keys: map[string]string{"2024": "secret"}}
+	if keys.Verify("token", "hash", sig, expiration) {
+		t.Fatalf("expected unversioned signature to be rejected with keys")
+	}
+}
+
+func TestSigningKeysGracePeriod(t *testing.T) {
+	expiration := time.Now().Add(time.Hour)
+	sig, err := CreateSignature("token", "hash", expiration)
+	if err != nil {
+		t.Fatalf("not expected error while signing: %v", err)
+	}
+
+	keys := &SigningKeys{CurrentKey: "2024", Keys: map[string]string{"2024": "secret"}, GracePeriod: 60}
+	if err := keys.Init(); err != nil {
+		t.Fatalf("not expected error while initializing the keys: %v", err)
+	}
+	if !keys.Verify("token", "hash", sig, expiration) {
+		t.Fatalf("expected unversioned signature to be valid during the grace period")
+	}
+	if keys.Verify("token", "other", sig, expiration) {
+		t.Fatalf("expected unversioned signature of another password to be rejected during the grace period")
+	}
+
+	keys.unversionedUntil = time.Now().Add(-time.Second)
+	if keys.Verify("token", "hash", sig, expiration) {
+		t.Fatalf("expected unversioned signature to be rejected after the grace period")
+	}
+}
+
+func TestSigningKeysValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		keys  SigningKeys
+		valid bool
+	}{
+		{name: "no keys", valid: true},
+		{name: "current key", keys: SigningKeys{CurrentKey: "a", Keys: map[string]string{"a": "s", "b": "t"}}, valid: true},
+		{name: "missing current key", keys: SigningKeys{CurrentKey: "a", Keys: map[string]string{"b": "t"}}},
+		{name: "keys without current key", keys: SigningKeys{Keys: map[string]string{"b": "t"}}},
+		{name: "separator in id", keys: SigningKeys{CurrentKey: "a", Keys: map[string]string{"a": "s", "b:c": "t"}}},
+		{name: "empty secret", keys: SigningKeys{CurrentKey: "a", Keys: map[string]string{"a": ""}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.keys.Validate(); (err == nil) != tt.valid {
+				t.Fatalf("expected valid %v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
@@ -19,10 +19,13 @@
 package rgrpc
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"net"
 	"sort"
+	"sync"
+	"time"
 
 	"github.com/cs3org/reva/internal/grpc/interceptors/appctx"
 	"github.com/cs3org/reva/internal/grpc/interceptors/auth"
@@ -32,14 +35,21 @@ import (
 	"github.com/cs3org/reva/internal/grpc/interceptors/useragent"
 	"github.com/cs3org/reva/pkg/sharedconf"
 	"github.com/cs3org/reva/pkg/tracing"
+	"github.com/cs3org/reva/pkg/worker"
 	grpc_middleware "github.com/grpc-ecosystem/go-grpc-middleware"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 )
 
+// workersStopTimeout is how long stopping the server waits for the
+// background workers to complete.
+const workersStopTimeout = 5 * time.Second
+
 // UnaryInterceptors is a map of registered unary grpc interceptors.
 var UnaryInterceptors = map[string]NewUnaryInterceptor{}
 
@@ -82,6 +92,14 @@ type Service interface {
 	tracing.GrpcMiddlewarer
 }
 
+// HealthReporter is implemented by the services, and the drivers they use,
+// that can report whether they are able to serve requests, for example by
+// checking the connection to their database.
+// Services not implementing it are always reported as serving.
+type HealthReporter interface {
+	Health(ctx context.Context) error
+}
+
 type unaryInterceptorTriple struct {
 	Name        string
 	Priority    int
@@ -101,6 +119,8 @@ type config struct {
 	Services         map[string]map[string]interface{} `mapstructure:"services"`
 	Interceptors     map[string]map[string]interface{} `mapstructure:"interceptors"`
 	EnableReflection bool                              `mapstructure:"enable_reflection"`
+	// HealthCheckInterval is the interval in seconds between the health checks of the services.
+	HealthCheckInterval int `mapstructure:"health_check_interval"`
 }
 
 func (c *config) init() {
@@ -108,6 +128,10 @@ func (c *config) init() {
 		c.Network = "tcp"
 	}
 
+	if c.HealthCheckInterval == 0 {
+		c.HealthCheckInterval = 10
+	}
+
 	if c.Address == "" {
 		c.Address = sharedconf.GetGatewaySVC("0.0.0.0:19000")
 	}
@@ -120,6 +144,9 @@ type Server struct {
 	listener net.Listener
 	log      zerolog.Logger
 	services map[string]Service
+	health   *health.Server
+	done     chan struct{}
+	stopOnce sync.Once
 }
 
 // NewServer returns a new Server.
@@ -131,7 +158,13 @@ func NewServer(m interface{}, log zerolog.Logger) (*Server, error) {
 
 	conf.init()
 
-	server := &Server{conf: conf, log: log, services: map[string]Service{}}
+	server := &Server{
+		conf:     conf,
+		log:      log,
+		services: map[string]Service{},
+		health:   health.NewServer(),
+		done:     make(chan struct{}),
+	}
 
 	return server, nil
 }
@@ -143,6 +176,9 @@ func (s *Server) Start(ln net.Listener) error {
 		return err
 	}
 
+	s.checkHealth()
+	go s.watchHealth()
+
 	s.listener = ln
 	s.log.Info().Msgf("grpc server listening at %s:%s", s.Network(), s.Address())
 	err := s.s.Serve(s.listener)
@@ -188,7 +224,7 @@ func (s *Server) registerServices() error {
 	}
 
 	// obtain list of unprotected endpoints
-	unprotected := []string{}
+	unprotected := []string{"/grpc.health.v1.Health"}
 	for _, svc := range s.services {
 		unprotected = append(unprotected, svc.UnprotectedEndpoints()...)
 	}
@@ -204,6 +240,8 @@ func (s *Server) registerServices() error {
 		svc.Register(grpcServer)
 	}
 
+	healthpb.RegisterHealthServer(grpcServer, s.health)
+
 	if s.conf.EnableReflection {
 		s.log.Info().Msg("rgrpc: grpc server reflection enabled")
 		reflection.Register(grpcServer)
@@ -214,6 +252,49 @@ func (s *Server) registerServices() error {
 	return nil
 }
 
+// checkHealth updates the serving status of every service with the one
+// reported by the service, and the overall status of the server.
+func (s *Server) checkHealth() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.conf.HealthCheckInterval)*time.Second)
+	defer cancel()
+
+	overall := healthpb.HealthCheckResponse_SERVING
+	for name, svc := range s.services {
+		status := healthpb.HealthCheckResponse_SERVING
+		if r, ok := svc.(HealthReporter); ok {
+			if err := r.Health(ctx); err != nil {
+				s.log.Warn().Err(err).Msgf("rgrpc: grpc service %s is not healthy", name)
+				status = healthpb.HealthCheckResponse_NOT_SERVING
+				overall = healthpb.HealthCheckResponse_NOT_SERVING
+			}
+		}
+		s.health.SetServingStatus(name, status)
+	}
+	s.health.SetServingStatus("", overall)
+}
+
+func (s *Server) watchHealth() {
+	t := time.NewTicker(time.Duration(s.conf.HealthCheckInterval) * time.Second)
+	defer t.Stop()
+	for {
+		select {
+		case <-s.done:
+			return
+		case <-t.C:
+			s.checkHealth()
+		}
+	}
+}
+
+// shutdownHealth reports all the services as not serving, so that the
+// load balancers stop sending requests while the server is stopping.
+func (s *Server) shutdownHealth() {
+	s.stopOnce.Do(func() {
+		close(s.done)
+		s.health.Shutdown()
+	})
+}
+
 // TODO(labkode): make closing with deadline.
 func (s *Server) cleanupServices() {
 	for name, svc := range s.services {
@@ -225,16 +306,30 @@ func (s *Server) cleanupServices() {
 	}
 }
 
+// stopWorkers stops the background workers of the process, so that they
+// complete before it exits.
+func (s *Server) stopWorkers() {
+	ctx, cancel := context.WithTimeout(context.Background(), workersStopTimeout)
+	defer cancel()
+	if err := worker.Stop(ctx); err != nil {
+		s.log.Error().Err(err).Msg("error stopping the background workers")
+	}
+}
+
 // Stop stops the server.
 func (s *Server) Stop() error {
+	s.shutdownHealth()
 	s.cleanupServices()
+	s.stopWorkers()
 	s.s.Stop()
 	return nil
 }
 
 // GracefulStop gracefully stops the server.
 func (s *Server) GracefulStop() error {
+	s.shutdownHealth()
 	s.cleanupServices()
+	s.stopWorkers()
 	s.s.GracefulStop()
 	return nil
 }
@@ -277,6 +372,11 @@ func (s *Server) getInterceptors(unprotected []string) ([]grpc.ServerOption, err
 		return nil, errors.Wrap(err, "rgrpc: error creating unary auth interceptor")
 	}
 
+	recoveryUnary, err := recovery.NewUnary(s.conf.Interceptors["recovery"])
+	if err != nil {
+		return nil, errors.Wrap(err, "rgrpc: error creating unary recovery interceptor")
+	}
+
 	unaryInterceptors := []grpc.UnaryServerInterceptor{authUnary}
 	for _, t := range unaryTriples {
 		unaryInterceptors = append(unaryInterceptors, t.Interceptor)
@@ -289,7 +389,7 @@ func (s *Server) getInterceptors(unprotected []string) ([]grpc.ServerOption, err
 		token.NewUnary(),
 		useragent.NewUnary(),
 		log.NewUnary(),
-		recovery.NewUnary(),
+		recoveryUnary,
 	}, unaryInterceptors...)
 
 	unaryChain := grpc_middleware.ChainUnaryServer(unaryInterceptors...)
@@ -320,6 +420,11 @@ func (s *Server) getInterceptors(unprotected []string) ([]grpc.ServerOption, err
 		return nil, errors.Wrap(err, "rgrpc: error creating stream auth interceptor")
 	}
 
+	recoveryStream, err := recovery.NewStream(s.conf.Interceptors["recovery"])
+	if err != nil {
+		return nil, errors.Wrap(err, "rgrpc: error creating stream recovery interceptor")
+	}
+
 	streamInterceptors := []grpc.StreamServerInterceptor{authStream}
 	for _, t := range streamTriples {
 		streamInterceptors = append(streamInterceptors, t.Interceptor)
@@ -333,7 +438,7 @@ func (s *Server) getInterceptors(unprotected []string) ([]grpc.ServerOption, err
 		token.NewStream(),
 		useragent.NewStream(),
 		log.NewStream(),
-		recovery.NewStream(),
+		recoveryStream,
 	}, streamInterceptors...)
 
 	streamChain := grpc_middleware.ChainStreamServer(streamInterceptors...)
@@ -0,0 +1,144 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package rgrpc
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+
+	_ "github.com/cs3org/reva/pkg/token/manager/jwt"
+	"github.com/cs3org/reva/pkg/tracing"
+	"github.com/rs/zerolog"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// healthService is a service whose health can be changed,
+// recording the overall status of the server when it is closed.
+type healthService struct {
+	tracing.GrpcMiddleware
+	client healthpb.HealthClient
+
+	mu            sync.Mutex
+	err           error
+	closingStatus healthpb.HealthCheckResponse_ServingStatus
+}
+
+func (s *healthService) Register(ss *grpc.Server)       {}
+func (s *healthService) UnprotectedEndpoints() []string { return nil }
+
+func (s *healthService) Health(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.err
+}
+
+func (s *healthService) Close() error {
+	res, err := s.client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		return err
+	}
+	s.closingStatus = res.Status
+	return nil
+}
+
+// plainService is a service not reporting its health.
+type plainService struct {
+	tracing.GrpcMiddleware
+}
+
+func (s *plainService) Register(ss *grpc.Server)       {}
+func (s *plainService) UnprotectedEndpoints() []string { return nil }
+func (s *plainService) Close() error                   { return nil }
+
+func checkHealth(t *testing.T, client healthpb.HealthClient, service string, expected healthpb.HealthCheckResponse_ServingStatus) {
+	t.Helper()
+	res, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: service})
+	if err != nil {
+		t.Fatalf("not expected error checking the health of %q: %+v", service, err)
+	}
+	if res.Status != expected {
+		t.Fatalf("unexpected status for %q: got %v, expected %v", service, res.Status, expected)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	hs := &healthService{}
+	Register("healthtest", func(conf map[string]interface{}, ss *grpc.Server) (Service, error) { return hs, nil })
+	Register("plaintest", func(conf map[string]interface{}, ss *grpc.Server) (Service, error) { return &plainService{}, nil })
+	defer delete(Services, "healthtest")
+	defer delete(Services, "plaintest")
+
+	l, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatalf("not expected error while listening: %+v", err)
+	}
+	s, err := NewServer(map[string]interface{}{
+		"services": map[string]map[string]interface{}{
+			"healthtest": {},
+			"plaintest":  {},
+		},
+		"interceptors": map[string]map[string]interface{}{
+			"auth": {
+				"token_managers": map[string]map[string]interface{}{"jwt": {"secret": "changemeplease"}},
+			},
+		},
+	}, zerolog.Nop())
+	if err != nil {
+		t.Fatalf("not expected error creating the server: %+v", err)
+	}
+	go func() { _ = s.Start(l) }()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithBlock())
+	if err != nil {
+		t.Fatalf("not expected error connecting to the server: %+v", err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+	hs.client = client
+
+	checkHealth(t, client, "", healthpb.HealthCheckResponse_SERVING)
+	checkHealth(t, client, "healthtest", healthpb.HealthCheckResponse_SERVING)
+	checkHealth(t, client, "plaintest", healthpb.HealthCheckResponse_SERVING)
+
+	hs.mu.Lock()
+	hs.err = errors.New("database unreachable")
+	hs.mu.Unlock()
+	s.checkHealth()
+	checkHealth(t, client, "", healthpb.HealthCheckResponse_NOT_SERVING)
+	checkHealth(t, client, "healthtest", healthpb.HealthCheckResponse_NOT_SERVING)
+	checkHealth(t, client, "plaintest", healthpb.HealthCheckResponse_SERVING)
+
+	hs.mu.Lock()
+	hs.err = nil
+	hs.mu.Unlock()
+	s.checkHealth()
+	checkHealth(t, client, "", healthpb.HealthCheckResponse_SERVING)
+
+	if err := s.GracefulStop(); err != nil {
+		t.Fatalf("not expected error stopping the server: %+v", err)
+	}
+	if hs.closingStatus != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Fatalf("expected the server not to be serving while closing the services, got %v", hs.closingStatus)
+	}
+}
@@ -127,6 +127,16 @@ func NewAlreadyExists(ctx context.Context, err error, msg string) *rpc.Status {
 	}
 }
 
+// NewUnavailable returns a Status with CODE_UNAVAILABLE and logs the msg.
+func NewUnavailable(ctx context.Context, err error, msg string) *rpc.Status {
+	log := appctx.GetLogger(ctx).With().CallerWithSkipFrameCount(3).Logger()
+	log.Debug().Err(err).Msg(msg)
+	return &rpc.Status{
+		Code:    rpc.Code_CODE_UNAVAILABLE,
+		Message: msg,
+	}
+}
+
 // NewInvalidArg returns a Status with CODE_INVALID_ARGUMENT.
 func NewInvalidArg(ctx context.Context, msg string) *rpc.Status {
 	return &rpc.Status{Code: rpc.Code_CODE_INVALID_ARGUMENT,
@@ -153,21 +163,27 @@ func NewFailedPrecondition(ctx context.Context, err error, msg string) *rpc.Stat
 }
 
 // NewStatusFromErrType returns a status that corresponds to the given errtype.
+// The msg is prefixed to the error message; errors that can't be mapped result in CODE_INTERNAL.
 func NewStatusFromErrType(ctx context.Context, msg string, err error) *rpc.Status {
-	switch e := err.(type) {
-	case nil:
-		NewOK(ctx)
+	if err == nil {
+		return NewOK(ctx)
+	}
+
+	msg = msg + ": " + err.Error()
+	switch err.(type) {
 	case errtypes.IsNotFound:
-		return NewNotFound(ctx, "gateway: "+msg+": "+err.Error())
+		return NewNotFound(ctx, msg)
 	case errtypes.IsInvalidCredentials:
 		// TODO this maps badly
-		return NewUnauthenticated(ctx, err, "gateway: "+msg+": "+err.Error())
-	case errtypes.PermissionDenied:
-		return NewPermissionDenied(ctx, e, "gateway: "+msg+": "+err.Error())
+		return NewUnauthenticated(ctx, err, msg)
+	case errtypes.IsPermissionDenied:
+		return NewPermissionDenied(ctx, err, msg)
+	case errtypes.IsAlreadyExists:
+		return NewAlreadyExists(ctx, err, msg)
 	case errtypes.IsNotSupported:
-		return NewUnimplemented(ctx, err, "gateway: "+msg+":"+err.Error())
-	case errtypes.BadRequest:
-		return NewInvalidArg(ctx, "gateway: "+msg+":"+err.Error())
+		return NewUnimplemented(ctx, err, msg)
+	case errtypes.IsBadRequest:
+		return NewInvalidArg(ctx, msg)
 	}
 
 	// map GRPC status codes coming from the auth middleware
@@ -177,11 +193,11 @@ func NewStatusFromErrType(ctx context.Context, msg string, err error) *rpc.Statu
 		if ok {
 			switch st.Code() {
 			case codes.NotFound:
-				return NewNotFound(ctx, "gateway: "+msg+": "+err.Error())
+				return NewNotFound(ctx, msg)
 			case codes.Unauthenticated:
-				return NewUnauthenticated(ctx, err, "gateway: "+msg+": "+err.Error())
+				return NewUnauthenticated(ctx, err, msg)
 			case codes.PermissionDenied:
-				return NewPermissionDenied(ctx, err, "gateway: "+msg+": "+err.Error())
+				return NewPermissionDenied(ctx, err, msg)
 			}
 		}
 		// the actual error can be wrapped multiple times
@@ -191,7 +207,7 @@ func NewStatusFromErrType(ctx context.Context, msg string, err error) *rpc.Statu
 		}
 	}
 
-	return NewInternal(ctx, err, "gateway: "+msg+":"+err.Error())
+	return NewInternal(ctx, err, msg)
 }
 
 // NewErrorFromCode returns a standardized Error for a given RPC code.
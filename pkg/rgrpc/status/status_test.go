@@ -0,0 +1,67 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package status
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/cs3org/reva/pkg/errtypes"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestNewStatusFromErrType(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		err  error
+		code rpc.Code
+	}{
+		{name: "nil", err: nil, code: rpc.Code_CODE_OK},
+		{name: "not found", err: errtypes.NotFound("file"), code: rpc.Code_CODE_NOT_FOUND},
+		{name: "permission denied", err: errtypes.PermissionDenied("file"), code: rpc.Code_CODE_PERMISSION_DENIED},
+		{name: "invalid credentials", err: errtypes.InvalidCredentials("user"), code: rpc.Code_CODE_UNAUTHENTICATED},
+		{name: "already exists", err: errtypes.AlreadyExists("file"), code: rpc.Code_CODE_ALREADY_EXISTS},
+		{name: "not supported", err: errtypes.NotSupported("operation"), code: rpc.Code_CODE_UNIMPLEMENTED},
+		{name: "bad request", err: errtypes.BadRequest("path"), code: rpc.Code_CODE_INVALID_ARGUMENT},
+		{name: "grpc not found", err: status.Error(codes.NotFound, "file"), code: rpc.Code_CODE_NOT_FOUND},
+		{name: "grpc unauthenticated", err: status.Error(codes.Unauthenticated, "user"), code: rpc.Code_CODE_UNAUTHENTICATED},
+		{name: "wrapped grpc permission denied", err: fmt.Errorf("wrapped: %w", status.Error(codes.PermissionDenied, "file")), code: rpc.Code_CODE_PERMISSION_DENIED},
+		{name: "internal error", err: errtypes.InternalError("storage"), code: rpc.Code_CODE_INTERNAL},
+		{name: "unknown error", err: errors.New("something went wrong"), code: rpc.Code_CODE_INTERNAL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := NewStatusFromErrType(ctx, "operation failed", tt.err)
+			assert.Equal(t, tt.code, st.Code)
+			if tt.err == nil {
+				assert.Empty(t, st.Message)
+			} else {
+				assert.Equal(t, "operation failed: "+tt.err.Error(), st.Message)
+			}
+		})
+	}
+}
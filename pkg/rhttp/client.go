@@ -36,6 +36,18 @@ func GetHTTPClient(opts ...Option) *http.Client {
 
 	tr := http.DefaultTransport.(*http.Transport).Clone()
 	tr.DisableKeepAlives = options.DisableKeepAlive
+	if options.MaxIdleConns > 0 {
+		tr.MaxIdleConnsPerHost = options.MaxIdleConns
+		if tr.MaxIdleConns < options.MaxIdleConns {
+			tr.MaxIdleConns = options.MaxIdleConns
+		}
+	}
+	if options.IdleConnTimeout > 0 {
+		tr.IdleConnTimeout = options.IdleConnTimeout
+	}
+	if options.Proxy != nil {
+		tr.Proxy = http.ProxyURL(options.Proxy)
+	}
 	tr.TLSClientConfig = &tls.Config{
 		InsecureSkipVerify: options.Insecure,
 	}
@@ -61,3 +61,10 @@ type Service interface {
 	Unprotected() []string
 	tracing.HTTPMiddlewarer
 }
+
+// RootHandlersProvider is implemented by services that serve additional endpoints
+// at fixed paths relative to the root of the server, regardless of their prefix,
+// like well-known discovery endpoints. These endpoints are always unprotected.
+type RootHandlersProvider interface {
+	RootHandlers() map[string]http.Handler
+}
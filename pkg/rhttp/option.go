@@ -20,6 +20,7 @@ package rhttp
 
 import (
 	"context"
+	"net/url"
 	"time"
 )
 
@@ -32,6 +33,9 @@ type Options struct {
 	Timeout          time.Duration
 	Insecure         bool
 	DisableKeepAlive bool
+	MaxIdleConns     int
+	IdleConnTimeout  time.Duration
+	Proxy            *url.URL
 }
 
 // newOptions initializes the available default options.
@@ -72,3 +76,25 @@ func DisableKeepAlive(disable bool) Option {
 		o.DisableKeepAlive = disable
 	}
 }
+
+// MaxIdleConns provides a function to set the maximum number of idle connections kept per host.
+func MaxIdleConns(n int) Option {
+	return func(o *Options) {
+		o.MaxIdleConns = n
+	}
+}
+
+// IdleConnTimeout provides a function to set the time after which idle connections are closed.
+func IdleConnTimeout(t time.Duration) Option {
+	return func(o *Options) {
+		o.IdleConnTimeout = t
+	}
+}
+
+// Proxy provides a function to set the proxy the requests are sent through.
+// If not set, the proxy is taken from the HTTP_PROXY and HTTPS_PROXY environment variables.
+func Proxy(u *url.URL) Option {
+	return func(o *Options) {
+		o.Proxy = u
+	}
+}
@@ -35,11 +35,16 @@ import (
 	"github.com/cs3org/reva/pkg/rhttp/global"
 	"github.com/cs3org/reva/pkg/rhttp/utils"
 	"github.com/cs3org/reva/pkg/tracing"
+	"github.com/cs3org/reva/pkg/worker"
 	"github.com/mitchellh/mapstructure"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 )
 
+// workersStopTimeout is how long stopping the server waits for the
+// background workers to complete.
+const workersStopTimeout = 5 * time.Second
+
 // New returns a new server.
 func New(m interface{}, l zerolog.Logger) (*Server, error) {
 	conf := &config{}
@@ -127,6 +132,7 @@ func (s *Server) Start(ln net.Listener) error {
 // Stop stops the server.
 func (s *Server) Stop() error {
 	s.closeServices()
+	s.stopWorkers()
 	// TODO(labkode): set ctx deadline to zero
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
@@ -156,9 +162,20 @@ func (s *Server) Address() string {
 	return s.conf.Address
 }
 
+// stopWorkers stops the background workers of the process, so that they
+// complete before it exits.
+func (s *Server) stopWorkers() {
+	ctx, cancel := context.WithTimeout(context.Background(), workersStopTimeout)
+	defer cancel()
+	if err := worker.Stop(ctx); err != nil {
+		s.log.Error().Err(err).Msg("error stopping the background workers")
+	}
+}
+
 // GracefulStop gracefully stops the server.
 func (s *Server) GracefulStop() error {
 	s.closeServices()
+	s.stopWorkers()
 	return s.httpServer.Shutdown(context.Background())
 }
 
@@ -215,6 +232,28 @@ func (s *Server) registerServices() error {
 			return errors.New(message)
 		}
 	}
+	return s.registerRootHandlers()
+}
+
+// registerRootHandlers mounts the endpoints that services serve at the root
+// of the server, once all the service prefixes are known.
+func (s *Server) registerRootHandlers() error {
+	for prefix, svc := range s.svcs {
+		rh, ok := svc.(global.RootHandlersProvider)
+		if !ok {
+			continue
+		}
+		for p, h := range rh.RootHandlers() {
+			// handlers are stored like the service prefixes, without leading slash
+			p = strings.Trim(p, "/")
+			if _, ok := s.handlers[p]; ok {
+				return fmt.Errorf("http service at /%s: path /%s is already in use", prefix, p)
+			}
+			s.handlers[p] = h
+			s.unprotected = append(s.unprotected, path.Join("/", p))
+			s.log.Info().Msgf("http root endpoint enabled: /%s for service at /%s", p, prefix)
+		}
+	}
 	return nil
 }
 
@@ -232,38 +271,10 @@ func getUnprotected(prefix string, unprotected []string) []string {
 	return unprotected
 }
 
-// clean the url putting a slash (/) at the beginning if it does not have it
-// and removing the slashes at the end
-// if the url is "/", the output is "".
-func cleanURL(url string) string {
-	if len(url) > 0 {
-		if url[0] != '/' {
-			url = "/" + url
-		}
-		url = strings.TrimRight(url, "/")
-	}
-	return url
-}
-
+// URLHasPrefix reports whether the url starts with all the segments of the prefix.
+// See utils.URLHasPrefix for the details.
 func URLHasPrefix(url, prefix string) bool {
-	url = cleanURL(url)
-	prefix = cleanURL(prefix)
-
-	partsURL := strings.Split(url, "/")
-	partsPrefix := strings.Split(prefix, "/")
-
-	if len(partsPrefix) > len(partsURL) {
-		return false
-	}
-
-	for i, p := range partsPrefix {
-		u := partsURL[i]
-		if p != u {
-			return false
-		}
-	}
-
-	return true
+	return utils.URLHasPrefix(url, prefix)
 }
 
 func (s *Server) getHandlerLongestCommongURL(url string) (http.Handler, string, bool) {
@@ -19,9 +19,15 @@
 package rhttp
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
 	"testing"
 
+	"github.com/cs3org/reva/pkg/rhttp/global"
 	"github.com/cs3org/reva/pkg/rhttp/utils"
+	"github.com/cs3org/reva/pkg/tracing"
+	"github.com/rs/zerolog"
 )
 
 func TestURLHasPrefix(t *testing.T) {
@@ -65,6 +71,66 @@ func TestURLHasPrefix(t *testing.T) {
 			prefix:   "/api/v0/",
 			expected: true,
 		},
+		"url_end_slash": {
+			url:      "/api/v0/",
+			prefix:   "/api/v0",
+			expected: true,
+		},
+		"exact_match": {
+			url:      "/api/v0",
+			prefix:   "/api/v0",
+			expected: true,
+		},
+		"no_leading_slash": {
+			url:      "api/v0/project",
+			prefix:   "api/v0",
+			expected: true,
+		},
+		"partial_segment": {
+			url:      "/api/v0extra",
+			prefix:   "/api/v0",
+			expected: false,
+		},
+		"partial_segment_prefix_end_slash": {
+			url:      "/api/v0extra/project",
+			prefix:   "/api/v0/",
+			expected: false,
+		},
+		"longer_prefix": {
+			url:      "/api",
+			prefix:   "/api/v0",
+			expected: false,
+		},
+		"empty_url": {
+			url:      "",
+			prefix:   "/api",
+			expected: false,
+		},
+		"empty_url_and_prefix": {
+			url:      "",
+			prefix:   "",
+			expected: true,
+		},
+		"query_string": {
+			url:      "/api/v0?project=test",
+			prefix:   "/api/v0",
+			expected: true,
+		},
+		"query_string_partial_segment": {
+			url:      "/api/v0extra?project=test",
+			prefix:   "/api/v0",
+			expected: false,
+		},
+		"query_string_after_slash": {
+			url:      "/api/v0/?project=test",
+			prefix:   "/api/v0",
+			expected: true,
+		},
+		"fragment": {
+			url:      "/api/v0#project",
+			prefix:   "/api/v0",
+			expected: true,
+		},
 	}
 
 	for name, test := range tests {
@@ -76,3 +142,222 @@ func TestURLHasPrefix(t *testing.T) {
 		})
 	}
 }
+
+func TestGetSubURL(t *testing.T) {
+	tests := map[string]struct {
+		url      string
+		prefix   string
+		expected string
+	}{
+		"root": {
+			url:      "/",
+			prefix:   "/",
+			expected: "",
+		},
+		"empty_prefix": {
+			url:      "/api/v0",
+			prefix:   "",
+			expected: "/api/v0",
+		},
+		"exact_match": {
+			url:      "/api/v0/",
+			prefix:   "/api/v0",
+			expected: "",
+		},
+		"suburl": {
+			url:      "/api/v0/",
+			prefix:   "/api",
+			expected: "/v0",
+		},
+		"prefix_end_slash": {
+			url:      "/api/v0/project",
+			prefix:   "/api/",
+			expected: "/v0/project",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			res := utils.GetSubURL(test.url, test.prefix)
+			if res != test.expected {
+				t.Fatalf("%s got an unexpected result: %q instead of %q", t.Name(), res, test.expected)
+			}
+		})
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	m, err := utils.NewMatcher(
+		"/",
+		"/remote.php/webdav/**",
+		"/remote.php/dav/**",
+		"/remote.php/dav/files/:user/**",
+		"/remote.php/dav/*/public",
+		"/api/v0",
+		"/api/v0/**",
+		"/data/a%2Fb/**",
+		"/ocs/v1.php/**",
+	)
+	if err != nil {
+		t.Fatalf("not expected error while creating the matcher: %+v", err)
+	}
+
+	tests := map[string]struct {
+		path    string
+		pattern string
+		params  map[string]string
+		rest    string
+		noMatch bool
+	}{
+		"root":                          {path: "/", pattern: "/"},
+		"root_no_match":                 {path: "/unknown", noMatch: true},
+		"exact":                         {path: "/api/v0", pattern: "/api/v0"},
+		"exact_trailing_slash":          {path: "/api/v0/", pattern: "/api/v0"},
+		"exact_query_string":            {path: "/api/v0?project=test", pattern: "/api/v0"},
+		"exact_before_catch_all":        {path: "/api/v0", pattern: "/api/v0"},
+		"catch_all":                     {path: "/api/v0/project", pattern: "/api/v0/**", rest: "/project"},
+		"partial_segment":               {path: "/api/v0extra", noMatch: true},
+		"sibling_prefixes":              {path: "/remote.php/webdav/file.txt", pattern: "/remote.php/webdav/**", rest: "/file.txt"},
+		"sibling_prefixes_no_overlap":   {path: "/remote.php/dav/file.txt", pattern: "/remote.php/dav/**", rest: "/file.txt"},
+		"parameter_longest_match":       {path: "/remote.php/dav/files/einstein/notes.txt", pattern: "/remote.php/dav/files/:user/**", params: map[string]string{"user": "einstein"}, rest: "/notes.txt"},
+		"parameter_no_rest":             {path: "/remote.php/dav/files/einstein", pattern: "/remote.php/dav/files/:user/**", params: map[string]string{"user": "einstein"}},
+		"parameter_missing":             {path: "/remote.php/dav/files", pattern: "/remote.php/dav/**", rest: "/files"},
+		"parameter_encoded":             {path: "/remote.php/dav/files/albert%20einstein/a%2Fb", pattern: "/remote.php/dav/files/:user/**", params: map[string]string{"user": "albert einstein"}, rest: "/a%2Fb"},
+		"parameter_encoded_slash":       {path: "/remote.php/dav/files/a%2Fb", pattern: "/remote.php/dav/files/:user/**", params: map[string]string{"user": "a/b"}},
+		"wildcard":                      {path: "/remote.php/dav/spaces/public", pattern: "/remote.php/dav/*/public"},
+		"same_length_without_catch_all": {path: "/remote.php/dav/files/public", pattern: "/remote.php/dav/*/public"},
+		"wildcard_too_long":             {path: "/remote.php/dav/spaces/public/file", pattern: "/remote.php/dav/**", rest: "/spaces/public/file"},
+		"double_slashes":                {path: "//remote.php//webdav///file.txt", pattern: "/remote.php/webdav/**", rest: "/file.txt"},
+		"no_leading_slash":              {path: "remote.php/webdav", pattern: "/remote.php/webdav/**"},
+		"encoded_literal":               {path: "/remote%2Ephp/webdav", pattern: "/remote.php/webdav/**"},
+		"encoded_slash_literal":         {path: "/data/a%2Fb/file", pattern: "/data/a%2Fb/**", rest: "/file"},
+		"encoded_slash_not_separator":   {path: "/remote.php%2Fwebdav", noMatch: true},
+		"slash_not_encoded_slash":       {path: "/data/a/b/file", noMatch: true},
+		"invalid_escape":                {path: "/remote.php/dav/files/%zz", pattern: "/remote.php/dav/files/:user/**", params: map[string]string{"user": "%zz"}},
+		"fragment":                      {path: "/ocs/v1.php#fragment", pattern: "/ocs/v1.php/**"},
+		"trailing_slash_catch_all":      {path: "/ocs/v1.php/", pattern: "/ocs/v1.php/**"},
+		"query_string_with_slashes":     {path: "/ocs/v1.php?path=/a/b", pattern: "/ocs/v1.php/**"},
+		"empty_path":                    {path: "", pattern: "/"},
+		"catch_all_with_query_string":   {path: "/api/v0/project/?a=b", pattern: "/api/v0/**", rest: "/project"},
+		"catch_all_root_sibling_miss":   {path: "/ocs/v2.php/cloud", noMatch: true},
+		"parameter_with_double_slash":   {path: "/remote.php/dav/files//einstein//", pattern: "/remote.php/dav/files/:user/**", params: map[string]string{"user": "einstein"}},
+		"wildcard_with_trailing_slash":  {path: "/remote.php/dav/spaces/public/", pattern: "/remote.php/dav/*/public"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			match, ok := m.Match(test.path)
+			if test.noMatch {
+				if ok {
+					t.Fatalf("%s expected no match, got %+v", t.Name(), match)
+				}
+				return
+			}
+			if !ok {
+				t.Fatalf("%s expected a match with %s, got none", t.Name(), test.pattern)
+			}
+			if match.Pattern != test.pattern || match.Rest != test.rest {
+				t.Fatalf("%s got an unexpected match: %+v instead of %s with rest %q", t.Name(), match, test.pattern, test.rest)
+			}
+			params := test.params
+			if params == nil {
+				params = map[string]string{}
+			}
+			if !reflect.DeepEqual(match.Params, params) {
+				t.Fatalf("%s got unexpected parameters: %+v instead of %+v", t.Name(), match.Params, params)
+			}
+		})
+	}
+}
+
+func TestMatcherInvalidPatterns(t *testing.T) {
+	for _, p := range []string{"/a/**/b", "/a/:", "/a/:x/:x", "/a/%zz"} {
+		if _, err := utils.NewMatcher(p); err == nil {
+			t.Fatalf("expected an error for the pattern %s", p)
+		}
+	}
+}
+
+func TestMatcherFirstAddedWins(t *testing.T) {
+	m, err := utils.NewMatcher("/a/:x", "/a/*")
+	if err != nil {
+		t.Fatalf("not expected error while creating the matcher: %+v", err)
+	}
+	if match, ok := m.Match("/a/b"); !ok || match.Pattern != "/a/:x" {
+		t.Fatalf("expected the first pattern to match, got %+v", match)
+	}
+}
+
+type rootService struct {
+	tracing.HTTPMiddleware
+	prefix string
+	root   map[string]http.Handler
+}
+
+func (s *rootService) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("service"))
+	})
+}
+func (s *rootService) Prefix() string                        { return s.prefix }
+func (s *rootService) Close() error                          { return nil }
+func (s *rootService) Unprotected() []string                 { return nil }
+func (s *rootService) RootHandlers() map[string]http.Handler { return s.root }
+
+func newTestServer(svcs ...global.Service) *Server {
+	s := &Server{
+		svcs:     map[string]global.Service{},
+		handlers: map[string]http.Handler{},
+		log:      zerolog.Nop(),
+	}
+	for _, svc := range svcs {
+		s.svcs[svc.Prefix()] = svc
+		s.handlers[svc.Prefix()] = svc.Handler()
+	}
+	return s
+}
+
+func TestRegisterRootHandlers(t *testing.T) {
+	svc := &rootService{
+		prefix: "federation/ocm",
+		root: map[string]http.Handler{
+			"/.well-known/ocm": http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("well-known"))
+			}),
+		},
+	}
+	s := newTestServer(svc)
+	if err := s.registerRootHandlers(); err != nil {
+		t.Fatalf("not expected error registering root handlers: %+v", err)
+	}
+
+	if len(s.unprotected) != 1 || s.unprotected[0] != "/.well-known/ocm" {
+		t.Fatalf("root endpoint not unprotected: %v", s.unprotected)
+	}
+
+	for path, expected := range map[string]string{
+		"/.well-known/ocm":             "well-known",
+		"/federation/ocm/ocm-provider": "service",
+	} {
+		h, _, ok := s.getHandlerLongestCommongURL(path)
+		if !ok {
+			t.Fatalf("no handler found for %s", path)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Body.String() != expected {
+			t.Fatalf("unexpected response for %s. got=%s expected=%s", path, w.Body.String(), expected)
+		}
+	}
+}
+
+func TestRegisterRootHandlersConflict(t *testing.T) {
+	svc := &rootService{
+		prefix: "ocm",
+		root:   map[string]http.Handler{"/.well-known/ocm": http.NotFoundHandler()},
+	}
+	s := newTestServer(svc, &rootService{prefix: ".well-known/ocm"})
+	if err := s.registerRootHandlers(); err == nil {
+		t.Fatalf("expected an error registering a root handler on an used path")
+	}
+}
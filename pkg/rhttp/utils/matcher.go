@@ -0,0 +1,197 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package utils
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// wildcardSegment matches exactly one segment of the path.
+	wildcardSegment = "*"
+	// catchAllSegment matches all the remaining segments of the path,
+	// including none. It can only be the last segment of a pattern.
+	catchAllSegment = "**"
+	// paramPrefix starts the segments matching exactly one segment of the
+	// path, that is returned as a parameter with the name following the prefix.
+	paramPrefix = ":"
+)
+
+// Matcher selects the pattern matching a path among a set of patterns.
+//
+// Patterns are made of segments separated by slashes, each one being either:
+//   - a literal, matching a segment with the same value, compared after
+//     decoding the percent-encoded characters;
+//   - "*", matching any segment;
+//   - ":name", matching any segment, returned as the parameter name;
+//   - "**", as the last segment only, matching all the remaining segments,
+//     including none, so that the pattern matches the paths it is a prefix of.
+//
+// Paths are split on the slashes before being decoded, so an encoded slash
+// (%2F) is part of a segment. Empty segments, i.e. the leading, trailing and
+// repeated slashes, are ignored, as well as the query string and the fragment.
+//
+// When several patterns match a path, the longest one wins: the one with more
+// segments, excluding the catch-all, then the one with more literals, then the
+// one without the catch-all. Among equivalent patterns the first added wins.
+type Matcher struct {
+	patterns []*pattern
+}
+
+type pattern struct {
+	raw      string
+	segments []string
+	catchAll bool
+	literals int
+}
+
+// Match is the result of matching a path.
+type Match struct {
+	// Pattern is the matching pattern, as it was added.
+	Pattern string
+	// Params holds the decoded segments matched by the parameters.
+	Params map[string]string
+	// Rest is the part of the path matched by the catch-all,
+	// starting with a slash, or empty if no segment is left.
+	Rest string
+}
+
+// NewMatcher returns a matcher for the given patterns.
+func NewMatcher(patterns ...string) (*Matcher, error) {
+	m := &Matcher{}
+	for _, p := range patterns {
+		if err := m.Add(p); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// Add adds a pattern to the matcher.
+func (m *Matcher) Add(p string) error {
+	pt := &pattern{raw: p}
+	params := map[string]bool{}
+	segments := splitPath(p)
+	for i, s := range segments {
+		switch {
+		case s == catchAllSegment:
+			if i != len(segments)-1 {
+				return errors.Errorf("rhttp: catch-all not at the end of pattern %s", p)
+			}
+			pt.catchAll = true
+			continue
+		case strings.HasPrefix(s, paramPrefix):
+			name := strings.TrimPrefix(s, paramPrefix)
+			if name == "" || params[name] {
+				return errors.Errorf("rhttp: invalid or duplicate parameter %s in pattern %s", s, p)
+			}
+			params[name] = true
+		case s != wildcardSegment:
+			dec, err := url.PathUnescape(s)
+			if err != nil {
+				return errors.Wrapf(err, "rhttp: invalid segment %s in pattern %s", s, p)
+			}
+			s = dec
+			pt.literals++
+		}
+		pt.segments = append(pt.segments, s)
+	}
+	m.patterns = append(m.patterns, pt)
+	return nil
+}
+
+// Match returns the longest pattern matching the path, that has to be
+// escaped, as returned by url.URL.EscapedPath.
+func (m *Matcher) Match(path string) (*Match, bool) {
+	segments := splitPath(stripQuery(path))
+	decoded := make([]string, len(segments))
+	for i, s := range segments {
+		dec, err := url.PathUnescape(s)
+		if err != nil {
+			// invalid escapes can only match the wildcards and the parameters
+			dec = s
+		}
+		decoded[i] = dec
+	}
+
+	var best *pattern
+	var params map[string]string
+	for _, p := range m.patterns {
+		ps, ok := p.match(decoded)
+		if ok && (best == nil || p.longer(best)) {
+			best, params = p, ps
+		}
+	}
+	if best == nil {
+		return nil, false
+	}
+
+	match := &Match{Pattern: best.raw, Params: params}
+	if rest := segments[len(best.segments):]; len(rest) > 0 {
+		match.Rest = "/" + strings.Join(rest, "/")
+	}
+	return match, true
+}
+
+// MatchURL returns the longest pattern matching the escaped path of the url.
+func (m *Matcher) MatchURL(u *url.URL) (*Match, bool) {
+	return m.Match(u.EscapedPath())
+}
+
+func (p *pattern) match(segments []string) (map[string]string, bool) {
+	if len(segments) < len(p.segments) || (!p.catchAll && len(segments) != len(p.segments)) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, s := range p.segments {
+		switch {
+		case s == wildcardSegment:
+		case strings.HasPrefix(s, paramPrefix):
+			params[strings.TrimPrefix(s, paramPrefix)] = segments[i]
+		case s != segments[i]:
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// longer reports whether the pattern takes precedence over the other one.
+func (p *pattern) longer(other *pattern) bool {
+	if len(p.segments) != len(other.segments) {
+		return len(p.segments) > len(other.segments)
+	}
+	if p.literals != other.literals {
+		return p.literals > other.literals
+	}
+	return !p.catchAll && other.catchAll
+}
+
+// splitPath returns the non-empty segments of the path.
+func splitPath(path string) []string {
+	segments := []string{}
+	for _, s := range strings.Split(path, "/") {
+		if s != "" {
+			segments = append(segments, s)
+		}
+	}
+	return segments
+}
@@ -33,30 +33,31 @@ func cleanURL(url string) string {
 	return url
 }
 
-func URLHasPrefix(url, prefix string) bool {
-	url = cleanURL(url)
-	prefix = cleanURL(prefix)
-
-	partsURL := strings.Split(url, "/")
-	partsPrefix := strings.Split(prefix, "/")
-
-	if len(partsPrefix) > len(partsURL) {
-		return false
+// stripQuery removes the query string and the fragment from the url, if any.
+func stripQuery(url string) string {
+	if i := strings.IndexAny(url, "?#"); i >= 0 {
+		return url[:i]
 	}
+	return url
+}
 
-	for i, p := range partsPrefix {
-		u := partsURL[i]
-		if p != u {
-			return false
-		}
-	}
+// URLHasPrefix reports whether the path of the url starts with all the
+// segments of the prefix. Segments are compared as a whole, so the prefix
+// "/api/v0" matches "/api/v0" and "/api/v0/project", but not "/api/v0extra".
+// Leading and trailing slashes are not significant, an empty prefix or "/"
+// matches every url, and the query string and the fragment of the url,
+// if present, are ignored.
+func URLHasPrefix(url, prefix string) bool {
+	url = cleanURL(stripQuery(url))
+	prefix = cleanURL(prefix)
 
-	return true
+	return prefix == "" || url == prefix || strings.HasPrefix(url, prefix+"/")
 }
 
+// GetSubURL returns the part of the url following the prefix, that has to
+// be a prefix of the url as reported by URLHasPrefix.
+// For example, with url = "/api/v0/" and prefix = "/api", the result is "/v0".
 func GetSubURL(url, prefix string) string {
-	// pre cond: prefix is a prefix for url
-	// example: url = "/api/v0/", prefix = "/api", res = "/v0"
 	url = cleanURL(url)
 	prefix = cleanURL(prefix)
 
@@ -19,7 +19,11 @@
 package alerting
 
 import (
+	"context"
+	"fmt"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cs3org/reva/pkg/siteacc/config"
 	"github.com/cs3org/reva/pkg/siteacc/data"
@@ -36,6 +40,11 @@ type Dispatcher struct {
 	log  *zerolog.Logger
 
 	smtp *smtpclient.SMTPCredentials
+
+	// dispatching tracks the alerts being dispatched, so that they can be flushed on shutdown
+	dispatching sync.WaitGroup
+	closed      bool
+	mutex       sync.RWMutex
 }
 
 func (dispatcher *Dispatcher) initialize(conf *config.Configuration, log *zerolog.Logger) error {
@@ -59,6 +68,15 @@ func (dispatcher *Dispatcher) initialize(conf *config.Configuration, log *zerolo
 
 // DispatchAlerts sends the provided alert(s) via email to the appropriate recipients.
 func (dispatcher *Dispatcher) DispatchAlerts(alerts *template.Data, accounts data.Accounts) error {
+	dispatcher.mutex.RLock()
+	if dispatcher.closed {
+		dispatcher.mutex.RUnlock()
+		return errors.Errorf("the alerts dispatcher has been closed")
+	}
+	dispatcher.dispatching.Add(1)
+	dispatcher.mutex.RUnlock()
+	defer dispatcher.dispatching.Done()
+
 	for _, alert := range alerts.Alerts {
 		opID, ok := alert.Labels["operator_id"]
 		if !ok {
@@ -95,6 +113,28 @@ func (dispatcher *Dispatcher) DispatchAlerts(alerts *template.Data, accounts dat
 	return nil
 }
 
+// DispatchProvisioningFailure sends an alert about an account whose access to a downstream system
+// could not be provisioned to the global receiver.
+func (dispatcher *Dispatcher) DispatchProvisioningFailure(account *data.Account, connector string, err error) error {
+	alert := template.Alert{
+		Status:   "firing",
+		StartsAt: time.Now(),
+		Labels: template.KV{
+			"alertname":    "AccessProvisioningFailed",
+			"service_type": connector,
+			"severity":     "warning",
+			"operator":     account.Operator,
+			"operator_id":  account.Operator,
+		},
+		Annotations: template.KV{
+			"summary":     fmt.Sprintf("The %v access of %v could not be provisioned", connector, account.Email),
+			"description": err.Error(),
+		},
+	}
+	// Only the global receiver is notified, not the operator accounts
+	return dispatcher.DispatchAlerts(&template.Data{Alerts: template.Alerts{alert}}, nil)
+}
+
 func (dispatcher *Dispatcher) dispatchAlert(alert template.Alert, account *data.Account) error {
 	alertValues := map[string]string{
 		"Status":      alert.Status,
@@ -119,6 +159,29 @@ func (dispatcher *Dispatcher) dispatchAlert(alert template.Alert, account *data.
 	return email.SendAlertNotification(account, []string{account.Email}, alertValues, *dispatcher.conf)
 }
 
+// Close stops accepting new alerts and waits until the pending ones have been sent, or until the context is done.
+// It can be called multiple times.
+func (dispatcher *Dispatcher) Close(ctx context.Context) error {
+	dispatcher.mutex.Lock()
+	dispatcher.closed = true
+	dispatcher.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		dispatcher.dispatching.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "not all alerts have been dispatched")
+	}
+
+	// The alerts are sent as emails in the background
+	return email.Wait(ctx)
+}
+
 // NewDispatcher creates a new dispatcher instance.
 func NewDispatcher(conf *config.Configuration, log *zerolog.Logger) (*Dispatcher, error) {
 	dispatcher := &Dispatcher{}
@@ -0,0 +1,87 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package alerting
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cs3org/reva/pkg/siteacc/config"
+	"github.com/cs3org/reva/pkg/siteacc/data"
+	"github.com/prometheus/alertmanager/template"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestDispatcher(t *testing.T) *Dispatcher {
+	log := zerolog.Nop()
+	dispatcher, err := NewDispatcher(&config.Configuration{}, &log)
+	if err != nil {
+		t.Fatalf("not expected error while creating the dispatcher: %+v", err)
+	}
+	return dispatcher
+}
+
+func TestClose(t *testing.T) {
+	dispatcher := newTestDispatcher(t)
+	alerts := &template.Data{Alerts: template.Alerts{{Labels: template.KV{"operator_id": "cern"}}}}
+	accounts := data.Accounts{{Email: "einstein@example.org", Operator: "cern"}}
+
+	assert.NoError(t, dispatcher.DispatchAlerts(alerts, accounts))
+
+	assert.NoError(t, dispatcher.Close(context.Background()))
+	assert.NoError(t, dispatcher.Close(context.Background()))
+	assert.Error(t, dispatcher.DispatchAlerts(alerts, accounts))
+}
+
+func TestCloseWaitsForPendingAlerts(t *testing.T) {
+	dispatcher := newTestDispatcher(t)
+
+	// An alert being dispatched
+	dispatcher.dispatching.Add(1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.Error(t, dispatcher.Close(ctx))
+
+	closed := make(chan error)
+	go func() {
+		closed <- dispatcher.Close(context.Background())
+	}()
+	select {
+	case <-closed:
+		t.Fatal("expected the dispatcher to wait for the pending alert")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	dispatcher.dispatching.Done()
+	assert.NoError(t, <-closed)
+}
+
+func TestDispatchProvisioningFailure(t *testing.T) {
+	dispatcher := newTestDispatcher(t)
+	account := &data.Account{Email: "einstein@example.org", Operator: "cern"}
+
+	assert.NoError(t, dispatcher.DispatchProvisioningFailure(account, "gocdb", errors.New("unavailable")))
+
+	assert.NoError(t, dispatcher.Close(context.Background()))
+	assert.Error(t, dispatcher.DispatchProvisioningFailure(account, "gocdb", errors.New("unavailable")))
+}
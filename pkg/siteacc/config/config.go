@@ -38,6 +38,7 @@ type Configuration struct {
 		File struct {
 			OperatorsFile string `mapstructure:"operators_file"`
 			AccountsFile  string `mapstructure:"accounts_file"`
+			AuditFile     string `mapstructure:"audit_file"`
 		} `mapstructure:"file"`
 	} `mapstructure:"storage"`
 
@@ -46,6 +47,11 @@ type Configuration struct {
 		NotificationsMail string                      `mapstructure:"notifications_mail"`
 	} `mapstructure:"email"`
 
+	Verification struct {
+		TokenTimeout   int `mapstructure:"token_timeout"`
+		ResendInterval int `mapstructure:"resend_interval"`
+	} `mapstructure:"verification"`
+
 	Mentix struct {
 		URL                      string `mapstructure:"url"`
 		DataEndpoint             string `mapstructure:"data_endpoint"`
@@ -66,6 +72,15 @@ type Configuration struct {
 
 		APIKey string `mapstructure:"apikey"`
 	} `mapstructure:"gocdb"`
+
+	Connectors struct {
+		// Enabled lists the connectors provisioning the access of the accounts to the downstream systems.
+		Enabled []string `mapstructure:"enabled"`
+
+		// Retries is how many times a provisioning failing with a transient error is retried; 0 disables the retries.
+		Retries       int `mapstructure:"retries"`
+		RetryInterval int `mapstructure:"retry_interval"`
+	} `mapstructure:"connectors"`
 }
 
 // Cleanup cleans up certain settings, normalizing them.
@@ -84,4 +99,15 @@ func (cfg *Configuration) Cleanup() {
 	if cfg.GOCDB.WriteURL != "" && !strings.HasSuffix(cfg.GOCDB.WriteURL, "/") {
 		cfg.GOCDB.WriteURL += "/"
 	}
+
+	// GOCDB is the only connector enabled by default
+	if cfg.Connectors.Enabled == nil {
+		cfg.Connectors.Enabled = []string{"gocdb"}
+	}
+	if cfg.Connectors.Retries < 0 {
+		cfg.Connectors.Retries = 0
+	}
+	if cfg.Connectors.RetryInterval <= 0 {
+		cfg.Connectors.RetryInterval = 5
+	}
 }
@@ -21,6 +21,10 @@ package config
 const (
 	// EndpointAdministration is the endpoint path of the web interface administration panel.
 	EndpointAdministration = "/admin"
+	// EndpointAdministrationExport is the endpoint path for exporting data from the administration panel.
+	EndpointAdministrationExport = "/admin/export"
+	// EndpointExport is the endpoint path for exporting all accounts as CSV or JSON.
+	EndpointExport = "/export"
 	// EndpointAccount is the endpoint path of the web interface account panel.
 	EndpointAccount = "/account"
 
@@ -56,10 +60,19 @@ const (
 	// EndpointVerifyUserToken is the endpoint path for user token validation.
 	EndpointVerifyUserToken = "/verify-user-token"
 
+	// EndpointVerifyEmail is the endpoint path for verifying the email address of an account.
+	EndpointVerifyEmail = "/verify"
+	// EndpointResendVerification is the endpoint path for resending the email verification.
+	EndpointResendVerification = "/resend-verification"
+	// EndpointConfirmEmail is the endpoint path for confirming the email address of an account without verification.
+	EndpointConfirmEmail = "/confirm-email"
+
 	// EndpointGrantSitesAccess is the endpoint path for granting or revoking Sites access.
 	EndpointGrantSitesAccess = "/grant-sites-access"
 	// EndpointGrantGOCDBAccess is the endpoint path for granting or revoking GOCDB access.
 	EndpointGrantGOCDBAccess = "/grant-gocdb-access"
+	// EndpointSetAccessRole is the endpoint path for assigning access roles.
+	EndpointSetAccessRole = "/set-access-role"
 
 	// EndpointDispatchAlert is the endpoint path for dispatching alerts from Prometheus.
 	EndpointDispatchAlert = "/dispatch-alert"
@@ -44,12 +44,36 @@ type Account struct {
 
 	Data     AccountData     `json:"data"`
 	Settings AccountSettings `json:"settings"`
+
+	Verification *AccountVerification `json:"verification,omitempty"`
 }
 
 // AccountData holds additional data for a sites account.
 type AccountData struct {
-	GOCDBAccess bool `json:"gocdbAccess"`
-	SitesAccess bool `json:"sitesAccess"`
+	GOCDBAccess bool   `json:"gocdbAccess"`
+	SitesAccess bool   `json:"sitesAccess"`
+	AccessRole  string `json:"accessRole,omitempty"`
+
+	// Connectors holds the status of the access to the downstream systems, indexed by connector name.
+	Connectors map[string]*ConnectorStatus `json:"connectors,omitempty"`
+}
+
+const (
+	// ConnectorStatusDone means that the access has been provisioned.
+	ConnectorStatusDone = "done"
+	// ConnectorStatusRetrying means that the provisioning failed and is being retried.
+	ConnectorStatusRetrying = "retrying"
+	// ConnectorStatusFailed means that the provisioning failed for good.
+	ConnectorStatusFailed = "failed"
+)
+
+// ConnectorStatus holds the status of the access of an account to a downstream system, provisioned by a connector.
+type ConnectorStatus struct {
+	Granted  bool      `json:"granted"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Attempts int       `json:"attempts"`
+	Updated  time.Time `json:"updated"`
 }
 
 // AccountSettings holds additional settings for a sites account.
@@ -57,6 +81,14 @@ type AccountSettings struct {
 	ReceiveAlerts bool `json:"receiveAlerts"`
 }
 
+// AccountVerification holds the state of the verification of the email address of an account.
+type AccountVerification struct {
+	Verified bool `json:"verified"`
+
+	TokenHash string    `json:"tokenHash,omitempty"`
+	SentAt    time.Time `json:"sentAt,omitempty"`
+}
+
 // Accounts holds an array of sites accounts.
 type Accounts = []*Account
 
@@ -81,7 +113,10 @@ func (acc *Account) Update(other *Account, setPassword bool, copyData bool) erro
 	}
 
 	if copyData {
+		// The status of the connectors is only tracked by the service
+		connectors := acc.Data.Connectors
 		acc.Data = other.Data
+		acc.Data.Connectors = connectors
 	}
 
 	return nil
@@ -103,17 +138,40 @@ func (acc *Account) UpdatePassword(pwd string) error {
 	return nil
 }
 
-// Clone creates a copy of the account; if erasePassword is set to true, the password will be cleared in the cloned object.
+// Clone creates a copy of the account; if erasePassword is set to true, the password (and the verification token) will be cleared in the cloned object.
 func (acc *Account) Clone(erasePassword bool) *Account {
 	clone := *acc
 
+	if acc.Verification != nil {
+		verification := *acc.Verification
+		clone.Verification = &verification
+	}
+
+	if acc.Data.Connectors != nil {
+		clone.Data.Connectors = make(map[string]*ConnectorStatus, len(acc.Data.Connectors))
+		for name, status := range acc.Data.Connectors {
+			statusCopy := *status
+			clone.Data.Connectors[name] = &statusCopy
+		}
+	}
+
 	if erasePassword {
 		clone.Password.Clear()
+
+		if clone.Verification != nil {
+			clone.Verification.TokenHash = ""
+		}
 	}
 
 	return &clone
 }
 
+// IsEmailVerified checks whether the email address of the account has been verified.
+// Accounts created before the introduction of the verification are considered as verified.
+func (acc *Account) IsEmailVerified() bool {
+	return acc.Verification == nil || acc.Verification.Verified
+}
+
 // CheckScopeAccess checks whether the user can access the specified scope.
 func (acc *Account) CheckScopeAccess(scope string) bool {
 	hasAccess := false
@@ -206,6 +264,9 @@ func NewAccount(email string, title, firstName, lastName string, operator, role
 		Settings: AccountSettings{
 			ReceiveAlerts: true,
 		},
+		Verification: &AccountVerification{
+			Verified: false,
+		},
 	}
 
 	// Set the user password, which also makes sure that the given password is strong enough
@@ -0,0 +1,69 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package data
+
+import (
+	"time"
+)
+
+const (
+	// AuditActionCreate is the audit action for account creation.
+	AuditActionCreate = "create"
+	// AuditActionUpdate is the audit action for account updates.
+	AuditActionUpdate = "update"
+	// AuditActionConfigure is the audit action for account configuration.
+	AuditActionConfigure = "configure"
+	// AuditActionGrantSitesAccess is the audit action for granting Sites access.
+	AuditActionGrantSitesAccess = "grant-sites-access"
+	// AuditActionRevokeSitesAccess is the audit action for revoking Sites access.
+	AuditActionRevokeSitesAccess = "revoke-sites-access"
+	// AuditActionGrantGOCDBAccess is the audit action for granting GOCDB access.
+	AuditActionGrantGOCDBAccess = "grant-gocdb-access"
+	// AuditActionRevokeGOCDBAccess is the audit action for revoking GOCDB access.
+	AuditActionRevokeGOCDBAccess = "revoke-gocdb-access"
+	// AuditActionRemove is the audit action for account removal.
+	AuditActionRemove = "remove"
+	// AuditActionVerifyEmail is the audit action for the verification of the email address by the account owner.
+	AuditActionVerifyEmail = "verify-email"
+	// AuditActionConfirmEmail is the audit action for confirming the email address without verification.
+	AuditActionConfirmEmail = "confirm-email"
+	// AuditActionSetAccessRole is the audit action for assigning an access role.
+	AuditActionSetAccessRole = "set-access-role"
+)
+
+// AuditEntry represents a single state-changing operation performed on an account.
+type AuditEntry struct {
+	Timestamp time.Time `json:"timestamp"`
+	Actor     string    `json:"actor"`
+	Action    string    `json:"action"`
+	Target    string    `json:"target"`
+}
+
+// AuditLog holds an array of audit entries.
+type AuditLog = []*AuditEntry
+
+// NewAuditEntry creates a new audit entry using the current time.
+func NewAuditEntry(actor, action, target string) *AuditEntry {
+	return &AuditEntry{
+		Timestamp: time.Now(),
+		Actor:     actor,
+		Action:    action,
+		Target:    target,
+	}
+}
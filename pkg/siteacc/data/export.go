@@ -0,0 +1,203 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package data
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// ExportFormatCSV exports the data as CSV.
+	ExportFormatCSV = "csv"
+	// ExportFormatJSON exports the data as JSON.
+	ExportFormatJSON = "json"
+
+	// AccountStatusUnverified is the status of accounts whose email address has not been verified yet.
+	AccountStatusUnverified = "unverified"
+	// AccountStatusPending is the status of accounts that have not been granted any access yet.
+	AccountStatusPending = "pending"
+	// AccountStatusActive is the status of accounts that have been granted access to the Sites or GOCDB.
+	AccountStatusActive = "active"
+)
+
+var exportFormatTypes = map[string]string{
+	ExportFormatCSV:  "text/csv",
+	ExportFormatJSON: "application/json",
+}
+
+// ExportedAccount holds the account data handed out by the exports; passwords and settings are never exported.
+type ExportedAccount struct {
+	Email         string    `json:"email"`
+	Title         string    `json:"title"`
+	FirstName     string    `json:"firstName"`
+	LastName      string    `json:"lastName"`
+	Operator      string    `json:"operator"`
+	OperatorName  string    `json:"operatorName"`
+	Role          string    `json:"role"`
+	PhoneNumber   string    `json:"phoneNumber"`
+	Status        string    `json:"status"`
+	DateCreated   time.Time `json:"dateCreated"`
+	DateModified  time.Time `json:"dateModified"`
+	EmailVerified bool      `json:"emailVerified"`
+	SitesAccess   bool      `json:"sitesAccess"`
+	GOCDBAccess   bool      `json:"gocdbAccess"`
+}
+
+var exportedAccountHeader = []string{"Email", "Title", "First name", "Last name", "Operator", "Operator name", "Role", "Phone", "Status", "Joined", "Last modified", "Email verified", "Sites access", "GOCDB access"}
+
+// NewExportedAccount creates the exported data of an account.
+func NewExportedAccount(acc *Account, operatorName string) *ExportedAccount {
+	return &ExportedAccount{
+		Email:         acc.Email,
+		Title:         acc.Title,
+		FirstName:     acc.FirstName,
+		LastName:      acc.LastName,
+		Operator:      acc.Operator,
+		OperatorName:  operatorName,
+		Role:          acc.Role,
+		PhoneNumber:   acc.PhoneNumber,
+		Status:        GetAccountStatus(acc),
+		DateCreated:   acc.DateCreated,
+		DateModified:  acc.DateModified,
+		EmailVerified: acc.IsEmailVerified(),
+		SitesAccess:   acc.Data.SitesAccess,
+		GOCDBAccess:   acc.Data.GOCDBAccess,
+	}
+}
+
+func (acc *ExportedAccount) record() []string {
+	return []string{
+		acc.Email, acc.Title, acc.FirstName, acc.LastName, acc.Operator, acc.OperatorName, acc.Role, acc.PhoneNumber, acc.Status,
+		acc.DateCreated.Format(time.RFC3339), acc.DateModified.Format(time.RFC3339),
+		strconv.FormatBool(acc.EmailVerified), strconv.FormatBool(acc.SitesAccess), strconv.FormatBool(acc.GOCDBAccess),
+	}
+}
+
+// GetAccountStatus returns whether the account is unverified, pending or active.
+func GetAccountStatus(acc *Account) string {
+	switch {
+	case !acc.IsEmailVerified():
+		return AccountStatusUnverified
+	case acc.Data.SitesAccess || acc.Data.GOCDBAccess:
+		return AccountStatusActive
+	default:
+		return AccountStatusPending
+	}
+}
+
+// GetExportFormat determines the export format from the format parameter or, if missing, the Accept header; CSV is the default.
+func GetExportFormat(r *http.Request) (string, error) {
+	if format := strings.ToLower(r.URL.Query().Get("format")); format != "" {
+		if _, ok := exportFormatTypes[format]; !ok {
+			return "", errors.Errorf("unsupported export format %v", format)
+		}
+		return format, nil
+	}
+
+	accept := r.Header.Get("Accept")
+	if accept == "" {
+		return ExportFormatCSV, nil
+	}
+	// The first supported media type wins
+	for _, entry := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(entry))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "*/*", "text/*", exportFormatTypes[ExportFormatCSV]:
+			return ExportFormatCSV, nil
+		case "application/*", exportFormatTypes[ExportFormatJSON]:
+			return ExportFormatJSON, nil
+		}
+	}
+	return "", errors.Errorf("unsupported export media type %v", accept)
+}
+
+// SetExportHeaders sets the content type and the file name of an export.
+func SetExportHeaders(w http.ResponseWriter, name string, format string) {
+	w.Header().Set("Content-Type", exportFormatTypes[format]+"; charset=UTF-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%v.%v\"", name, format))
+}
+
+// WriteAccounts streams the accounts one by one in the given format, so that the output is never held in memory as a whole.
+// The operator names are resolved only once per operator; if operatorName is nil, they are left empty.
+func WriteAccounts(w io.Writer, format string, accounts Accounts, operatorName func(string) string) error {
+	operatorNames := make(map[string]string)
+	export := func(acc *Account) *ExportedAccount {
+		opName, ok := operatorNames[acc.Operator]
+		if !ok && operatorName != nil {
+			opName = operatorName(acc.Operator)
+			operatorNames[acc.Operator] = opName
+		}
+		return NewExportedAccount(acc, opName)
+	}
+
+	switch format {
+	case ExportFormatCSV:
+		return writeAccountsCSV(w, accounts, export)
+	case ExportFormatJSON:
+		return writeAccountsJSON(w, accounts, export)
+	default:
+		return errors.Errorf("unsupported export format %v", format)
+	}
+}
+
+func writeAccountsCSV(w io.Writer, accounts Accounts, export func(*Account) *ExportedAccount) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(exportedAccountHeader); err != nil {
+		return err
+	}
+	for _, acc := range accounts {
+		if err := writer.Write(export(acc).record()); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeAccountsJSON(w io.Writer, accounts Accounts, export func(*Account) *ExportedAccount) error {
+	if _, err := w.Write([]byte("[")); err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(w)
+	for i, acc := range accounts {
+		if i > 0 {
+			if _, err := w.Write([]byte(",")); err != nil {
+				return err
+			}
+		}
+		if err := encoder.Encode(export(acc)); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write([]byte("]\n"))
+	return err
+}
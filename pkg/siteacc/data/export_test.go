@@ -0,0 +1,127 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package data
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testAccounts() Accounts {
+	created := time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)
+	return Accounts{
+		{Email: "jane@example.org", Title: "Dr.", FirstName: "Jane", LastName: "Doe", Operator: "op-a", Role: "Admin", DateCreated: created, DateModified: created, Data: AccountData{SitesAccess: true}},
+		{Email: "john@example.org", FirstName: "John", LastName: "Roe", Operator: "op-b", DateCreated: created},
+		{Email: "new@example.org", FirstName: "New", LastName: "User", Operator: "op-a", DateCreated: created, Verification: &AccountVerification{}},
+	}
+}
+
+func TestGetExportFormat(t *testing.T) {
+	tests := []struct {
+		name   string
+		url    string
+		accept string
+		format string
+		err    bool
+	}{
+		{name: "default", url: "/export", format: ExportFormatCSV},
+		{name: "format parameter", url: "/export?format=JSON", format: ExportFormatJSON},
+		{name: "format parameter wins", url: "/export?format=csv", accept: "application/json", format: ExportFormatCSV},
+		{name: "accept json", url: "/export", accept: "application/json; charset=UTF-8", format: ExportFormatJSON},
+		{name: "first supported type", url: "/export", accept: "text/html, application/json, text/csv", format: ExportFormatJSON},
+		{name: "any type", url: "/export", accept: "*/*", format: ExportFormatCSV},
+		{name: "unsupported format", url: "/export?format=xml", err: true},
+		{name: "unsupported type", url: "/export", accept: "text/html", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.accept != "" {
+				r.Header.Set("Accept", tt.accept)
+			}
+			format, err := GetExportFormat(r)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.format, format)
+		})
+	}
+}
+
+func TestWriteAccountsCSV(t *testing.T) {
+	var buf bytes.Buffer
+	queried := 0
+	operatorName := func(opID string) string {
+		queried++
+		return strings.ToUpper(opID)
+	}
+	assert.NoError(t, WriteAccounts(&buf, ExportFormatCSV, testAccounts(), operatorName))
+	assert.Equal(t, 2, queried, "the operator names are resolved once per operator")
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	assert.NoError(t, err)
+	zero := time.Time{}.Format(time.RFC3339)
+	assert.Equal(t, [][]string{
+		{"Email", "Title", "First name", "Last name", "Operator", "Operator name", "Role", "Phone", "Status", "Joined", "Last modified", "Email verified", "Sites access", "GOCDB access"},
+		{"jane@example.org", "Dr.", "Jane", "Doe", "op-a", "OP-A", "Admin", "", AccountStatusActive, "2023-03-01T12:00:00Z", "2023-03-01T12:00:00Z", "true", "true", "false"},
+		{"john@example.org", "", "John", "Roe", "op-b", "OP-B", "", "", AccountStatusPending, "2023-03-01T12:00:00Z", zero, "true", "false", "false"},
+		{"new@example.org", "", "New", "User", "op-a", "OP-A", "", "", AccountStatusUnverified, "2023-03-01T12:00:00Z", zero, "false", "false", "false"},
+	}, records)
+}
+
+func TestWriteAccountsJSON(t *testing.T) {
+	var buf bytes.Buffer
+	assert.NoError(t, WriteAccounts(&buf, ExportFormatJSON, testAccounts(), nil))
+
+	var records []*ExportedAccount
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &records))
+	if assert.Len(t, records, 3) {
+		assert.Equal(t, "jane@example.org", records[0].Email)
+		assert.Equal(t, "Jane", records[0].FirstName)
+		assert.Equal(t, "op-a", records[0].Operator)
+		assert.Empty(t, records[0].OperatorName)
+		assert.Equal(t, AccountStatusActive, records[0].Status)
+		assert.True(t, records[0].SitesAccess)
+		assert.True(t, records[0].DateCreated.Equal(time.Date(2023, 3, 1, 12, 0, 0, 0, time.UTC)))
+		assert.Equal(t, AccountStatusUnverified, records[2].Status)
+	}
+
+	// no accounts still result in a valid list
+	buf.Reset()
+	assert.NoError(t, WriteAccounts(&buf, ExportFormatJSON, Accounts{}, nil))
+	assert.JSONEq(t, "[]", buf.String())
+}
+
+func TestSetExportHeaders(t *testing.T) {
+	w := httptest.NewRecorder()
+	SetExportHeaders(w, "accounts", ExportFormatCSV)
+	assert.Equal(t, "text/csv; charset=UTF-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="accounts.csv"`, w.Header().Get("Content-Disposition"))
+}
@@ -37,6 +37,7 @@ type FileStorage struct {
 
 	operatorsFilePath string
 	accountsFilePath  string
+	auditFilePath     string
 }
 
 func (storage *FileStorage) initialize(conf *config.Configuration, log *zerolog.Logger) error {
@@ -60,9 +61,16 @@ func (storage *FileStorage) initialize(conf *config.Configuration, log *zerolog.
 	}
 	storage.accountsFilePath = conf.Storage.File.AccountsFile
 
+	// The audit log is stored next to the accounts by default
+	storage.auditFilePath = conf.Storage.File.AuditFile
+	if storage.auditFilePath == "" {
+		storage.auditFilePath = filepath.Join(filepath.Dir(storage.accountsFilePath), "audit.json")
+	}
+
 	// Create the file directories if necessary
 	_ = os.MkdirAll(filepath.Dir(storage.operatorsFilePath), 0755)
 	_ = os.MkdirAll(filepath.Dir(storage.accountsFilePath), 0755)
+	_ = os.MkdirAll(filepath.Dir(storage.auditFilePath), 0755)
 
 	return nil
 }
@@ -99,6 +107,19 @@ func (storage *FileStorage) ReadAccounts() (*Accounts, error) {
 	return accounts, nil
 }
 
+// ReadAuditLog reads the entire audit log.
+func (storage *FileStorage) ReadAuditLog() (*AuditLog, error) {
+	auditLog := &AuditLog{}
+	if _, err := os.Stat(storage.auditFilePath); os.IsNotExist(err) {
+		// No actions have been audited yet
+		return auditLog, nil
+	}
+	if err := storage.readData(storage.auditFilePath, auditLog); err != nil {
+		return nil, errors.Wrap(err, "error reading the audit log")
+	}
+	return auditLog, nil
+}
+
 func (storage *FileStorage) writeData(file string, obj interface{}) error {
 	// Write the data to the specified file
 	jsonData, _ := json.MarshalIndent(obj, "", "\t")
@@ -124,6 +145,61 @@ func (storage *FileStorage) WriteAccounts(accounts *Accounts) error {
 	return nil
 }
 
+// WriteAccountsAudited writes all stored accounts from the given data object and appends the entry to the audit log;
+// either both changes are persisted or none of them.
+func (storage *FileStorage) WriteAccountsAudited(accounts *Accounts, entry *AuditEntry) error {
+	auditLog, err := storage.ReadAuditLog()
+	if err != nil {
+		return err
+	}
+	*auditLog = append(*auditLog, entry)
+
+	// Write all data to temporary files first, so that a failure leaves the stored data untouched
+	accountsTmpFile := storage.accountsFilePath + ".tmp"
+	auditTmpFile := storage.auditFilePath + ".tmp"
+	defer func() {
+		_ = os.Remove(accountsTmpFile)
+		_ = os.Remove(auditTmpFile)
+	}()
+
+	if err := storage.writeData(accountsTmpFile, accounts); err != nil {
+		return errors.Wrap(err, "error writing accounts")
+	}
+	if err := storage.writeData(auditTmpFile, auditLog); err != nil {
+		return errors.Wrap(err, "error writing the audit log")
+	}
+
+	// Keep the current audit log so that it can be restored if the accounts can't be replaced
+	auditData, err := os.ReadFile(storage.auditFilePath)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "error reading the audit log")
+	}
+
+	if err := os.Rename(auditTmpFile, storage.auditFilePath); err != nil {
+		return errors.Wrap(err, "error writing the audit log")
+	}
+	if err := os.Rename(accountsTmpFile, storage.accountsFilePath); err != nil {
+		storage.restoreFile(storage.auditFilePath, auditData)
+		return errors.Wrap(err, "error writing accounts")
+	}
+
+	return nil
+}
+
+func (storage *FileStorage) restoreFile(file string, data []byte) {
+	var err error
+	if data == nil {
+		// The file didn't exist before
+		err = os.Remove(file)
+	} else {
+		err = os.WriteFile(file, data, 0755)
+	}
+
+	if err != nil {
+		storage.log.Error().Err(err).Str("file", file).Msg("unable to restore file")
+	}
+}
+
 // OperatorAdded is called when a sites has been added.
 func (storage *FileStorage) OperatorAdded(op *Operator) {
 	// Simply skip this action; all data is saved solely in WriteSites
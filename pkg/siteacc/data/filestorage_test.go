@@ -0,0 +1,88 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package data
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cs3org/reva/pkg/siteacc/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestStorage(t *testing.T) *FileStorage {
+	dir := t.TempDir()
+	conf := &config.Configuration{}
+	conf.Storage.File.OperatorsFile = filepath.Join(dir, "operators.json")
+	conf.Storage.File.AccountsFile = filepath.Join(dir, "accounts.json")
+
+	log := zerolog.Nop()
+	storage, err := NewFileStorage(conf, &log)
+	if err != nil {
+		t.Fatalf("not expected error while creating the file storage: %+v", err)
+	}
+	return storage
+}
+
+func TestWriteAccountsAudited(t *testing.T) {
+	storage := newTestStorage(t)
+
+	auditLog, err := storage.ReadAuditLog()
+	assert.NoError(t, err)
+	assert.Empty(t, *auditLog)
+
+	accounts := Accounts{{Email: "einstein@example.org"}}
+	assert.NoError(t, storage.WriteAccountsAudited(&accounts, NewAuditEntry("admin", AuditActionCreate, "einstein@example.org")))
+	accounts = append(accounts, &Account{Email: "marie@example.org"})
+	assert.NoError(t, storage.WriteAccountsAudited(&accounts, NewAuditEntry("marie@example.org", AuditActionCreate, "marie@example.org")))
+
+	stored, err := storage.ReadAccounts()
+	assert.NoError(t, err)
+	assert.Len(t, *stored, 2)
+
+	auditLog, err = storage.ReadAuditLog()
+	assert.NoError(t, err)
+	if assert.Len(t, *auditLog, 2) {
+		assert.Equal(t, "admin", (*auditLog)[0].Actor)
+		assert.Equal(t, "einstein@example.org", (*auditLog)[0].Target)
+		assert.Equal(t, "marie@example.org", (*auditLog)[1].Actor)
+	}
+}
+
+func TestWriteAccountsAuditedRollback(t *testing.T) {
+	storage := newTestStorage(t)
+
+	accounts := Accounts{{Email: "einstein@example.org"}}
+	assert.NoError(t, storage.WriteAccountsAudited(&accounts, NewAuditEntry("admin", AuditActionCreate, "einstein@example.org")))
+
+	// Make the accounts file impossible to replace
+	assert.NoError(t, os.Remove(storage.accountsFilePath))
+	assert.NoError(t, os.MkdirAll(filepath.Join(storage.accountsFilePath, "blocked"), 0755))
+
+	assert.Error(t, storage.WriteAccountsAudited(&Accounts{}, NewAuditEntry("admin", AuditActionRemove, "einstein@example.org")))
+
+	// The audit log must not contain the failed change
+	auditLog, err := storage.ReadAuditLog()
+	assert.NoError(t, err)
+	if assert.Len(t, *auditLog, 1) {
+		assert.Equal(t, AuditActionCreate, (*auditLog)[0].Action)
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package data
+
+const (
+	// RoleAccountOwner is the access role of accounts that may only manage themselves.
+	RoleAccountOwner = "account-owner"
+	// RoleOperator is the access role of accounts that may also manage the sites of their operator.
+	RoleOperator = "operator"
+	// RoleAdmin is the access role of accounts that may manage all accounts, operators and sites.
+	RoleAdmin = "admin"
+)
+
+var roleRanks = map[string]int{
+	RoleAccountOwner: 1,
+	RoleOperator:     2,
+	RoleAdmin:        3,
+}
+
+// IsValidRole checks whether the given role is a known access role.
+func IsValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok
+}
+
+// RoleSatisfies checks whether the given role grants at least the privileges of the required one.
+// An empty required role is satisfied by everyone, including anonymous users without any role.
+func RoleSatisfies(role, required string) bool {
+	if required == "" {
+		return true
+	}
+	rank, ok := roleRanks[role]
+	return ok && rank >= roleRanks[required]
+}
@@ -36,6 +36,9 @@ type Storage interface {
 	ReadAccounts() (*Accounts, error)
 	// WriteAccounts writes all stored accounts from the given data object.
 	WriteAccounts(accounts *Accounts) error
+	// WriteAccountsAudited writes all stored accounts from the given data object and appends the entry to the audit log;
+	// either both changes are persisted or none of them.
+	WriteAccountsAudited(accounts *Accounts, entry *AuditEntry) error
 
 	// AccountAdded is called when an account has been added.
 	AccountAdded(account *Account)
@@ -43,4 +46,7 @@ type Storage interface {
 	AccountUpdated(account *Account)
 	// AccountRemoved is called when an account has been removed.
 	AccountRemoved(account *Account)
+
+	// ReadAuditLog reads the entire audit log.
+	ReadAuditLog() (*AuditLog, error)
 }
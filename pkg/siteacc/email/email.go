@@ -20,7 +20,9 @@ package email
 
 import (
 	"bytes"
+	"context"
 	"strings"
+	"sync"
 	"text/template"
 
 	"github.com/cs3org/reva/pkg/siteacc/config"
@@ -38,6 +40,9 @@ type emailData struct {
 	Params map[string]string
 }
 
+// pending tracks the emails being sent in the background, so that they can be waited for on shutdown.
+var pending sync.WaitGroup
+
 // SendFunction is the definition of email send functions.
 type SendFunction = func(*data.Account, []string, map[string]string, config.Configuration) error
 
@@ -55,6 +60,11 @@ func SendAccountCreated(account *data.Account, recipients []string, params map[s
 	return send(recipients, "ScienceMesh: Site Administrator Account created", accountCreatedTemplate, getEmailData(account, conf, params), conf.Email.SMTP)
 }
 
+// SendEmailVerification sends an email containing the link to verify the email address of an account.
+func SendEmailVerification(account *data.Account, recipients []string, params map[string]string, conf config.Configuration) error {
+	return send(recipients, "ScienceMesh: Verify your email address", emailVerificationTemplate, getEmailData(account, conf, params), conf.Email.SMTP)
+}
+
 // SendSitesAccessGranted sends an email about granted Sites access.
 func SendSitesAccessGranted(account *data.Account, recipients []string, params map[string]string, conf config.Configuration) error {
 	return send(recipients, "ScienceMesh: Sites access granted", sitesAccessGrantedTemplate, getEmailData(account, conf, params), conf.Email.SMTP)
@@ -110,7 +120,9 @@ func send(recipients []string, subject string, bodyTemplate string, data interfa
 		}
 
 		// Send the mail w/o blocking the main thread
+		pending.Add(1)
 		go func(recipient string) {
+			defer pending.Done()
 			_ = smtp.SendMail(recipient, subject, body.String())
 		}(recipient)
 	}
@@ -118,6 +130,22 @@ func send(recipients []string, subject string, bodyTemplate string, data interfa
 	return nil
 }
 
+// Wait waits until all emails being sent in the background are delivered, or until the context is done.
+func Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "not all emails have been sent")
+	}
+}
+
 func prepareEmailTemplate(tpl *template.Template) {
 	// Add some custom helper functions to the template
 	tpl.Funcs(template.FuncMap{
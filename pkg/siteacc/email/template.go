@@ -32,6 +32,19 @@ Kind regards,
 The ScienceMesh Team
 `
 
+const emailVerificationTemplate = `
+Dear {{.Account.FirstName}} {{.Account.LastName}},
+
+Please verify the email address of your ScienceMesh Site Administrator Account by visiting the following link:
+{{.Params.Link}}
+
+The link is valid for a limited time and can only be used once. If it has expired, you can request a new one from the login page.
+Your account can only be granted access to the ScienceMesh services once your email address has been verified.
+
+Kind regards,
+The ScienceMesh Team
+`
+
 const sitesAccessGrantedTemplate = `
 Dear {{.Account.FirstName}} {{.Account.LastName}},
 
@@ -26,6 +26,7 @@ import (
 	"net/url"
 	"strings"
 
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
 	"github.com/cs3org/reva/pkg/siteacc/config"
 	"github.com/cs3org/reva/pkg/siteacc/data"
 	"github.com/cs3org/reva/pkg/siteacc/html"
@@ -38,16 +39,35 @@ const (
 	invokerUser = "user"
 )
 
-type methodCallback = func(*SiteAccounts, url.Values, []byte, *html.Session) (interface{}, error)
-type accessSetterCallback = func(*manager.AccountsManager, *data.Account, bool) error
+type methodCallback = func(*SiteAccounts, url.Values, []byte, *html.Session, string) (interface{}, error)
+type accessSetterCallback = func(*manager.AccountsManager, *data.Account, bool, string) error
 
 type endpoint struct {
 	Path            string
 	Handler         func(*SiteAccounts, endpoint, http.ResponseWriter, *http.Request, *html.Session)
 	MethodCallbacks map[string]methodCallback
 	IsPublic        bool
+	MinRole         string
 }
 
+// Every request to a method endpoint results in a standardized JSON response
+type methodResponse struct {
+	Success bool        `json:"success"`
+	Error   string      `json:"error,omitempty"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+const forbiddenPage = `<!DOCTYPE html>
+<html>
+<head><title>Access denied</title></head>
+<body style="font-family: arial;">
+	<h2>Access denied</h2>
+	<p>You are not allowed to access this page.</p>
+	<p>Please <a href="%vaccount/?path=login">log in</a> with an account that has the required privileges.</p>
+</body>
+</html>
+`
+
 func createMethodCallbacks(cbGet methodCallback, cbPost methodCallback) map[string]methodCallback {
 	callbacks := make(map[string]methodCallback)
 
@@ -65,31 +85,38 @@ func createMethodCallbacks(cbGet methodCallback, cbPost methodCallback) map[stri
 func getEndpoints() []endpoint {
 	endpoints := []endpoint{
 		// Form/panel endpoints
-		{config.EndpointAdministration, callAdministrationEndpoint, nil, false},
-		{config.EndpointAccount, callAccountEndpoint, nil, true},
+		{config.EndpointAdministration, callAdministrationEndpoint, nil, false, data.RoleAdmin},
+		{config.EndpointAdministrationExport, callAdministrationExportEndpoint, nil, false, data.RoleAdmin},
+		{config.EndpointExport, callExportEndpoint, nil, false, data.RoleAdmin},
+		{config.EndpointAccount, callAccountEndpoint, nil, true, ""},
 		// General account endpoints
-		{config.EndpointList, callMethodEndpoint, createMethodCallbacks(handleList, nil), false},
-		{config.EndpointFind, callMethodEndpoint, createMethodCallbacks(handleFind, nil), false},
-		{config.EndpointCreate, callMethodEndpoint, createMethodCallbacks(nil, handleCreate), true},
-		{config.EndpointUpdate, callMethodEndpoint, createMethodCallbacks(nil, handleUpdate), true},
-		{config.EndpointConfigure, callMethodEndpoint, createMethodCallbacks(nil, handleConfigure), true},
-		{config.EndpointRemove, callMethodEndpoint, createMethodCallbacks(nil, handleRemove), false},
+		{config.EndpointList, callMethodEndpoint, createMethodCallbacks(handleList, nil), false, data.RoleAdmin},
+		{config.EndpointFind, callMethodEndpoint, createMethodCallbacks(handleFind, nil), false, data.RoleAdmin},
+		{config.EndpointCreate, callMethodEndpoint, createMethodCallbacks(nil, handleCreate), true, ""},
+		{config.EndpointUpdate, callMethodEndpoint, createMethodCallbacks(nil, handleUpdate), true, data.RoleAccountOwner},
+		{config.EndpointConfigure, callMethodEndpoint, createMethodCallbacks(nil, handleConfigure), true, data.RoleAccountOwner},
+		{config.EndpointRemove, callMethodEndpoint, createMethodCallbacks(nil, handleRemove), false, data.RoleAdmin},
 		// Site endpoints
-		{config.EndpointSiteGet, callMethodEndpoint, createMethodCallbacks(handleSiteGet, nil), false},
+		{config.EndpointSiteGet, callMethodEndpoint, createMethodCallbacks(handleSiteGet, nil), false, data.RoleOperator},
 		// Sites endpoints
-		{config.EndpointSitesConfigure, callMethodEndpoint, createMethodCallbacks(nil, handleSitesConfigure), true},
+		{config.EndpointSitesConfigure, callMethodEndpoint, createMethodCallbacks(nil, handleSitesConfigure), true, data.RoleOperator},
 		// Login endpoints
-		{config.EndpointLogin, callMethodEndpoint, createMethodCallbacks(nil, handleLogin), true},
-		{config.EndpointLogout, callMethodEndpoint, createMethodCallbacks(handleLogout, nil), true},
-		{config.EndpointResetPassword, callMethodEndpoint, createMethodCallbacks(nil, handleResetPassword), true},
-		{config.EndpointContact, callMethodEndpoint, createMethodCallbacks(nil, handleContact), true},
+		{config.EndpointLogin, callMethodEndpoint, createMethodCallbacks(nil, handleLogin), true, ""},
+		{config.EndpointLogout, callMethodEndpoint, createMethodCallbacks(handleLogout, nil), true, ""},
+		{config.EndpointResetPassword, callMethodEndpoint, createMethodCallbacks(nil, handleResetPassword), true, ""},
+		{config.EndpointContact, callMethodEndpoint, createMethodCallbacks(nil, handleContact), true, data.RoleAccountOwner},
 		// Authentication endpoints
-		{config.EndpointVerifyUserToken, callMethodEndpoint, createMethodCallbacks(handleVerifyUserToken, nil), true},
+		{config.EndpointVerifyUserToken, callMethodEndpoint, createMethodCallbacks(handleVerifyUserToken, nil), true, ""},
+		// Email verification endpoints
+		{config.EndpointVerifyEmail, callVerifyEmailEndpoint, nil, true, ""},
+		{config.EndpointResendVerification, callMethodEndpoint, createMethodCallbacks(nil, handleResendVerification), true, ""},
+		{config.EndpointConfirmEmail, callMethodEndpoint, createMethodCallbacks(nil, handleConfirmEmail), false, data.RoleAdmin},
 		// Access management endpoints
-		{config.EndpointGrantSitesAccess, callMethodEndpoint, createMethodCallbacks(nil, handleGrantSitesAccess), false},
-		{config.EndpointGrantGOCDBAccess, callMethodEndpoint, createMethodCallbacks(nil, handleGrantGOCDBAccess), false},
+		{config.EndpointGrantSitesAccess, callMethodEndpoint, createMethodCallbacks(nil, handleGrantSitesAccess), false, data.RoleAdmin},
+		{config.EndpointGrantGOCDBAccess, callMethodEndpoint, createMethodCallbacks(nil, handleGrantGOCDBAccess), false, data.RoleAdmin},
+		{config.EndpointSetAccessRole, callMethodEndpoint, createMethodCallbacks(nil, handleSetAccessRole), false, data.RoleAdmin},
 		// Alerting endpoints
-		{config.EndpointDispatchAlert, callMethodEndpoint, createMethodCallbacks(nil, handleDispatchAlert), false},
+		{config.EndpointDispatchAlert, callMethodEndpoint, createMethodCallbacks(nil, handleDispatchAlert), false, data.RoleAdmin},
 	}
 
 	return endpoints
@@ -102,6 +129,33 @@ func callAdministrationEndpoint(siteacc *SiteAccounts, ep endpoint, w http.Respo
 	}
 }
 
+func callAdministrationExportEndpoint(siteacc *SiteAccounts, ep endpoint, w http.ResponseWriter, r *http.Request, session *html.Session) {
+	if err := siteacc.ExportAdministrationData(w, r); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(fmt.Sprintf("Unable to export the administration data: %v", err)))
+	}
+}
+
+func callExportEndpoint(siteacc *SiteAccounts, ep endpoint, w http.ResponseWriter, r *http.Request, session *html.Session) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	format, err := data.GetExportFormat(r)
+	if err != nil {
+		w.WriteHeader(http.StatusNotAcceptable)
+		_, _ = w.Write([]byte(fmt.Sprintf("Unable to export the accounts: %v", err)))
+		return
+	}
+
+	// The response is streamed, so errors can't be reported to the client anymore
+	if err := siteacc.ExportAccounts(w, format); err != nil {
+		siteacc.log.Err(err).Msg("an error occurred while exporting the accounts")
+	}
+}
+
 func callAccountEndpoint(siteacc *SiteAccounts, ep endpoint, w http.ResponseWriter, r *http.Request, session *html.Session) {
 	if err := siteacc.ShowAccountPanel(w, r, session); err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -109,16 +163,29 @@ func callAccountEndpoint(siteacc *SiteAccounts, ep endpoint, w http.ResponseWrit
 	}
 }
 
-func callMethodEndpoint(siteacc *SiteAccounts, ep endpoint, w http.ResponseWriter, r *http.Request, session *html.Session) {
-	// Every request to the accounts service results in a standardized JSON response
-	type Response struct {
-		Success bool        `json:"success"`
-		Error   string      `json:"error,omitempty"`
-		Data    interface{} `json:"data,omitempty"`
+func callVerifyEmailEndpoint(siteacc *SiteAccounts, ep endpoint, w http.ResponseWriter, r *http.Request, session *html.Session) {
+	// This endpoint is opened from the verification emails, so it responds with a readable message instead of JSON
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte("No verification token specified"))
+		return
 	}
 
+	if err := siteacc.AccountsManager().VerifyEmail(token); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(fmt.Sprintf("Unable to verify your email address: %v\nYou can request a new verification email from the login page: %vaccount/?path=login", err, siteacc.conf.Webserver.URL)))
+		return
+	}
+
+	_, _ = w.Write([]byte(fmt.Sprintf("Your email address has been verified successfully!\nYou can now log in to your account: %vaccount/?path=login", siteacc.conf.Webserver.URL)))
+}
+
+func callMethodEndpoint(siteacc *SiteAccounts, ep endpoint, w http.ResponseWriter, r *http.Request, session *html.Session) {
 	// The default response is an unknown requestHandler (for the specified method)
-	resp := Response{
+	resp := methodResponse{
 		Success: false,
 		Error:   fmt.Sprintf("unknown endpoint %v for method %v", r.URL.Path, r.Method),
 		Data:    nil,
@@ -130,7 +197,7 @@ func callMethodEndpoint(siteacc *SiteAccounts, ep endpoint, w http.ResponseWrite
 			if method == r.Method {
 				body, _ := io.ReadAll(r.Body)
 
-				if respData, err := cb(siteacc, r.URL.Query(), body, session); err == nil {
+				if respData, err := cb(siteacc, r.URL.Query(), body, session, getActor(r, session)); err == nil {
 					resp.Success = true
 					resp.Error = ""
 					resp.Data = respData
@@ -144,22 +211,42 @@ func callMethodEndpoint(siteacc *SiteAccounts, ep endpoint, w http.ResponseWrite
 	}
 
 	// Any failure during query handling results in a bad request
+	status := http.StatusOK
 	if !resp.Success {
-		w.WriteHeader(http.StatusBadRequest)
+		status = http.StatusBadRequest
 	}
+	writeMethodResponse(w, status, &resp)
+}
+
+func callForbiddenEndpoint(siteacc *SiteAccounts, ep endpoint, w http.ResponseWriter, r *http.Request) {
+	// API calls are answered with JSON, while the panels show a readable page
+	if ep.MethodCallbacks != nil {
+		writeMethodResponse(w, http.StatusForbidden, &methodResponse{
+			Success: false,
+			Error:   fmt.Sprintf("access to endpoint %v denied", r.URL.Path),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+	w.WriteHeader(http.StatusForbidden)
+	_, _ = w.Write([]byte(fmt.Sprintf(forbiddenPage, siteacc.conf.Webserver.URL)))
+}
 
+func writeMethodResponse(w http.ResponseWriter, status int, resp *methodResponse) {
 	// Responses here are always JSON
 	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
 
-	jsonData, _ := json.MarshalIndent(&resp, "", "\t")
+	jsonData, _ := json.MarshalIndent(resp, "", "\t")
 	_, _ = w.Write(jsonData)
 }
 
-func handleList(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleList(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	return siteacc.AccountsManager().CloneAccounts(true), nil
 }
 
-func handleFind(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleFind(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	account, err := findAccount(siteacc, values.Get("by"), values.Get("value"))
 	if err != nil {
 		return nil, err
@@ -167,21 +254,26 @@ func handleFind(siteacc *SiteAccounts, values url.Values, body []byte, session *
 	return map[string]interface{}{"account": account.Clone(true)}, nil
 }
 
-func handleCreate(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleCreate(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	account, err := unmarshalRequestData(body)
 	if err != nil {
 		return nil, err
 	}
 
+	// Accounts are usually created by their owners
+	if actor == "" {
+		actor = account.Email
+	}
+
 	// Create a new account through the accounts manager
-	if err := siteacc.AccountsManager().CreateAccount(account); err != nil {
+	if err := siteacc.AccountsManager().CreateAccount(account, actor); err != nil {
 		return nil, errors.Wrap(err, "unable to create account")
 	}
 
 	return nil, nil
 }
 
-func handleUpdate(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleUpdate(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	account, err := unmarshalRequestData(body)
 	if err != nil {
 		return nil, err
@@ -194,14 +286,14 @@ func handleUpdate(siteacc *SiteAccounts, values url.Values, body []byte, session
 	account.Email = email
 
 	// Update the account through the accounts manager
-	if err := siteacc.AccountsManager().UpdateAccount(account, setPassword, false); err != nil {
+	if err := siteacc.AccountsManager().UpdateAccount(account, setPassword, false, actor); err != nil {
 		return nil, errors.Wrap(err, "unable to update account")
 	}
 
 	return nil, nil
 }
 
-func handleConfigure(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleConfigure(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	account, err := unmarshalRequestData(body)
 	if err != nil {
 		return nil, err
@@ -214,40 +306,44 @@ func handleConfigure(siteacc *SiteAccounts, values url.Values, body []byte, sess
 	account.Email = email
 
 	// Configure the account through the accounts manager
-	if err := siteacc.AccountsManager().ConfigureAccount(account); err != nil {
+	if err := siteacc.AccountsManager().ConfigureAccount(account, actor); err != nil {
 		return nil, errors.Wrap(err, "unable to configure account")
 	}
 
 	return nil, nil
 }
 
-func handleRemove(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleRemove(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	account, err := unmarshalRequestData(body)
 	if err != nil {
 		return nil, err
 	}
 
 	// Remove the account through the accounts manager
-	if err := siteacc.AccountsManager().RemoveAccount(account); err != nil {
+	if err := siteacc.AccountsManager().RemoveAccount(account, actor); err != nil {
 		return nil, errors.Wrap(err, "unable to remove account")
 	}
 
 	return nil, nil
 }
 
-func handleSiteGet(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleSiteGet(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	siteID := values.Get("site")
 	if siteID == "" {
 		return nil, errors.Errorf("no site specified")
 	}
-	_, site := siteacc.OperatorsManager().FindSite(siteID)
+	op, site := siteacc.OperatorsManager().FindSite(siteID)
 	if site == nil {
 		return nil, errors.Errorf("no site with ID %v exists", siteID)
 	}
+	// Operators logged in through the account panel may only access the sites of their own operator
+	if user := session.LoggedInUser(); user != nil && user.Role == data.RoleOperator && !strings.EqualFold(op.ID, user.Account.Operator) {
+		return nil, errors.Errorf("no site with ID %v exists", siteID)
+	}
 	return map[string]interface{}{"site": site.Clone(false)}, nil
 }
 
-func handleSitesConfigure(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleSitesConfigure(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	email, _, err := processInvoker(siteacc, values, session)
 	if err != nil {
 		return nil, err
@@ -274,7 +370,7 @@ func handleSitesConfigure(siteacc *SiteAccounts, values url.Values, body []byte,
 	return nil, nil
 }
 
-func handleLogin(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleLogin(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	account, err := unmarshalRequestData(body)
 	if err != nil {
 		return nil, err
@@ -289,27 +385,27 @@ func handleLogin(siteacc *SiteAccounts, values url.Values, body []byte, session
 	return token, nil
 }
 
-func handleLogout(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleLogout(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	// Logout the user through the users manager
 	siteacc.UsersManager().LogoutUser(session)
 	return nil, nil
 }
 
-func handleResetPassword(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleResetPassword(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	account, err := unmarshalRequestData(body)
 	if err != nil {
 		return nil, err
 	}
 
 	// Reset the password through the users manager
-	if err := siteacc.AccountsManager().ResetPassword(account.Email); err != nil {
+	if err := siteacc.AccountsManager().ResetPassword(account.Email, actor); err != nil {
 		return nil, errors.Wrap(err, "unable to reset password")
 	}
 
 	return nil, nil
 }
 
-func handleContact(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleContact(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	if !session.IsUserLoggedIn() {
 		return nil, errors.Errorf("no user is currently logged in")
 	}
@@ -328,7 +424,7 @@ func handleContact(siteacc *SiteAccounts, values url.Values, body []byte, sessio
 	return nil, nil
 }
 
-func handleVerifyUserToken(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleVerifyUserToken(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	token := values.Get("token")
 	if token == "" {
 		return nil, errors.Errorf("no token specified")
@@ -348,7 +444,35 @@ func handleVerifyUserToken(siteacc *SiteAccounts, values url.Values, body []byte
 	return newToken, nil
 }
 
-func handleDispatchAlert(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleResendVerification(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
+	account, err := unmarshalRequestData(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resend the verification email through the accounts manager
+	if err := siteacc.AccountsManager().ResendVerification(account); err != nil {
+		return nil, errors.Wrap(err, "unable to resend the verification email")
+	}
+
+	return nil, nil
+}
+
+func handleConfirmEmail(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
+	account, err := unmarshalRequestData(body)
+	if err != nil {
+		return nil, err
+	}
+
+	// Confirm the email address of the account through the accounts manager
+	if err := siteacc.AccountsManager().ConfirmEmail(account, actor); err != nil {
+		return nil, errors.Wrap(err, "unable to confirm the email address")
+	}
+
+	return nil, nil
+}
+
+func handleDispatchAlert(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	alertsData := &template.Data{}
 	if err := json.Unmarshal(body, alertsData); err != nil {
 		return nil, errors.Wrap(err, "unable to unmarshal the alerts data")
@@ -362,15 +486,15 @@ func handleDispatchAlert(siteacc *SiteAccounts, values url.Values, body []byte,
 	return nil, nil
 }
 
-func handleGrantSitesAccess(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
-	return handleGrantAccess((*manager.AccountsManager).GrantSitesAccess, siteacc, values, body, session)
+func handleGrantSitesAccess(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
+	return handleGrantAccess((*manager.AccountsManager).GrantSitesAccess, siteacc, values, body, session, actor)
 }
 
-func handleGrantGOCDBAccess(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
-	return handleGrantAccess((*manager.AccountsManager).GrantGOCDBAccess, siteacc, values, body, session)
+func handleGrantGOCDBAccess(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
+	return handleGrantAccess((*manager.AccountsManager).GrantGOCDBAccess, siteacc, values, body, session, actor)
 }
 
-func handleGrantAccess(accessSetter accessSetterCallback, siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session) (interface{}, error) {
+func handleGrantAccess(accessSetter accessSetterCallback, siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
 	account, err := unmarshalRequestData(body)
 	if err != nil {
 		return nil, err
@@ -390,7 +514,7 @@ func handleGrantAccess(accessSetter accessSetterCallback, siteacc *SiteAccounts,
 		}
 
 		// Grant access to the account through the accounts manager
-		if err := accessSetter(siteacc.AccountsManager(), account, grantAccess); err != nil {
+		if err := accessSetter(siteacc.AccountsManager(), account, grantAccess, actor); err != nil {
 			return nil, errors.Wrap(err, "unable to change the access status of the account")
 		}
 	} else {
@@ -400,6 +524,25 @@ func handleGrantAccess(accessSetter accessSetterCallback, siteacc *SiteAccounts,
 	return nil, nil
 }
 
+func handleSetAccessRole(siteacc *SiteAccounts, values url.Values, body []byte, session *html.Session, actor string) (interface{}, error) {
+	account, err := unmarshalRequestData(body)
+	if err != nil {
+		return nil, err
+	}
+
+	role := strings.ToLower(values.Get("role"))
+	if role == "" {
+		return nil, errors.Errorf("no access role provided")
+	}
+
+	// Assign the access role through the accounts manager
+	if err := siteacc.AccountsManager().SetAccessRole(account, role, actor); err != nil {
+		return nil, errors.Wrap(err, "unable to set the access role of the account")
+	}
+
+	return nil, nil
+}
+
 func unmarshalRequestData(body []byte) (*data.Account, error) {
 	account := &data.Account{}
 	if err := json.Unmarshal(body, account); err != nil {
@@ -442,3 +585,32 @@ func processInvoker(siteacc *SiteAccounts, values url.Values, session *html.Sess
 
 	return email, invokedByUser, nil
 }
+
+func getActor(r *http.Request, session *html.Session) string {
+	// Protected endpoints are called by authenticated users, public ones might be called by a logged in account
+	if user, ok := ctxpkg.ContextGetUser(r.Context()); ok {
+		if user.Username != "" {
+			return user.Username
+		}
+		return user.Id.GetOpaqueId()
+	}
+
+	if session != nil && session.IsUserLoggedIn() {
+		return session.LoggedInUser().Account.Email
+	}
+
+	return ""
+}
+
+func getRole(r *http.Request, session *html.Session) string {
+	// Protected endpoints are only reachable by users authenticated through Reva, who have always administered the service
+	if _, ok := ctxpkg.ContextGetUser(r.Context()); ok {
+		return data.RoleAdmin
+	}
+
+	if session != nil {
+		return session.LoggedInRole()
+	}
+
+	return ""
+}
@@ -51,6 +51,7 @@ type Session struct {
 type SessionUser struct {
 	Account  *data.Account
 	Operator *data.Operator
+	Role     string
 }
 
 func getRemoteAddress(r *http.Request) string {
@@ -67,14 +68,23 @@ func (sess *Session) LoggedInUser() *SessionUser {
 	return sess.loggedInUser
 }
 
-// LoginUser logs in the provided user.
-func (sess *Session) LoginUser(acc *data.Account, op *data.Operator) {
+// LoginUser logs in the provided user using the given (resolved) access role.
+func (sess *Session) LoginUser(acc *data.Account, op *data.Operator, role string) {
 	sess.loggedInUser = &SessionUser{
 		Account:  acc,
 		Operator: op,
+		Role:     role,
 	}
 }
 
+// LoggedInRole retrieves the access role of the currently logged in user or an empty string if none is logged in.
+func (sess *Session) LoggedInRole() string {
+	if sess.loggedInUser == nil {
+		return ""
+	}
+	return sess.loggedInUser.Role
+}
+
 // LogoutUser logs out the currently logged in user.
 func (sess *Session) LogoutUser() {
 	sess.loggedInUser = nil
@@ -19,16 +19,21 @@
 package html
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sync"
 	"time"
 
 	"github.com/cs3org/reva/pkg/siteacc/config"
+	"github.com/cs3org/reva/pkg/worker"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 )
 
+// sessionsPurgeInterval is how often the expired sessions are removed.
+const sessionsPurgeInterval = time.Minute
+
 // SessionManager manages HTML sessions.
 type SessionManager struct {
 	conf *config.Configuration
@@ -38,6 +43,8 @@ type SessionManager struct {
 
 	sessionName string
 
+	workers *worker.Group
+
 	mutex sync.Mutex
 }
 
@@ -59,6 +66,10 @@ func (mngr *SessionManager) initialize(name string, conf *config.Configuration,
 
 	mngr.sessions = make(map[string]*Session, 100)
 
+	// Remove the expired sessions in the background
+	mngr.workers = worker.NewGroup()
+	mngr.workers.Start("siteacc sessions purge", mngr.purgeSessionsPeriodically)
+
 	return nil
 }
 
@@ -139,6 +150,26 @@ func (mngr *SessionManager) PurgeSessions() {
 	}
 }
 
+func (mngr *SessionManager) purgeSessionsPeriodically(ctx context.Context) {
+	ticker := time.NewTicker(sessionsPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			mngr.PurgeSessions()
+		}
+	}
+}
+
+// Close stops purging the expired sessions, waiting until the context is done at most.
+// It can be called multiple times.
+func (mngr *SessionManager) Close(ctx context.Context) error {
+	return mngr.workers.Stop(ctx)
+}
+
 func (mngr *SessionManager) createSession(r *http.Request) *Session {
 	session := NewSession(mngr.sessionName, time.Duration(mngr.conf.Webserver.SessionTimeout)*time.Second, r)
 	mngr.sessions[session.ID] = session
@@ -160,7 +191,7 @@ func (mngr *SessionManager) migrateSession(session *Session, r *http.Request) (*
 	sessionNew.Data = session.Data
 
 	if user := session.LoggedInUser(); user != nil {
-		sessionNew.LoginUser(user.Account, user.Operator)
+		sessionNew.LoginUser(user.Account, user.Operator, user.Role)
 	} else {
 		sessionNew.LogoutUser()
 	}
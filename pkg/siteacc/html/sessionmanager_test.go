@@ -0,0 +1,42 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package html
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cs3org/reva/pkg/siteacc/config"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/goleak"
+)
+
+func TestSessionManagerClose(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	log := zerolog.Nop()
+	mngr, err := NewSessionManager("siteacc_session", &config.Configuration{}, &log)
+	if err != nil {
+		t.Fatalf("not expected error while creating the session manager: %+v", err)
+	}
+
+	assert.NoError(t, mngr.Close(context.Background()))
+	assert.NoError(t, mngr.Close(context.Background()))
+}
@@ -19,14 +19,15 @@
 package manager
 
 import (
+	"context"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/cs3org/reva/pkg/siteacc/alerting"
 	"github.com/cs3org/reva/pkg/siteacc/config"
 	"github.com/cs3org/reva/pkg/siteacc/data"
 	"github.com/cs3org/reva/pkg/siteacc/email"
-	"github.com/cs3org/reva/pkg/siteacc/manager/gocdb"
 	"github.com/cs3org/reva/pkg/smtpclient"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
@@ -47,6 +48,7 @@ type AccountsManager struct {
 
 	accounts          data.Accounts
 	accountsListeners []AccountsListener
+	accessProvisioner *AccessProvisioner
 
 	smtp *smtpclient.SMTPCredentials
 
@@ -73,10 +75,11 @@ func (mngr *AccountsManager) initialize(storage data.Storage, conf *config.Confi
 	mngr.readAllAccounts()
 
 	// Register accounts listeners
-	if listener, err := gocdb.NewListener(mngr.conf, mngr.log); err == nil {
-		mngr.accountsListeners = append(mngr.accountsListeners, listener)
+	if provisioner, err := newAccessProvisioner(mngr.conf, mngr.log, mngr.setConnectorStatus); err == nil {
+		mngr.accessProvisioner = provisioner
+		mngr.accountsListeners = append(mngr.accountsListeners, provisioner)
 	} else {
-		return errors.Wrap(err, "unable to create the GOCDB accounts listener")
+		return errors.Wrap(err, "unable to create the access provisioner")
 	}
 
 	// Create the SMTP client
@@ -96,10 +99,12 @@ func (mngr *AccountsManager) readAllAccounts() {
 	}
 }
 
-func (mngr *AccountsManager) writeAllAccounts() {
-	if err := mngr.storage.WriteAccounts(&mngr.accounts); err != nil {
+func (mngr *AccountsManager) writeAllAccounts(actor, action string, account *data.Account) {
+	// Every change is recorded in the audit log along with the accounts
+	entry := data.NewAuditEntry(actor, action, account.Email)
+	if err := mngr.storage.WriteAccountsAudited(&mngr.accounts, entry); err != nil {
 		// Just warn when not being able to write accounts
-		mngr.log.Warn().Err(err).Msg("error while writing accounts")
+		mngr.log.Warn().Err(err).Str("actor", actor).Str("action", action).Str("target", account.Email).Msg("error while writing accounts")
 	}
 }
 
@@ -134,7 +139,7 @@ func (mngr *AccountsManager) findAccountByPredicate(predicate func(*data.Account
 }
 
 // CreateAccount creates a new account; if an account with the same email address already exists, an error is returned.
-func (mngr *AccountsManager) CreateAccount(accountData *data.Account) error {
+func (mngr *AccountsManager) CreateAccount(accountData *data.Account, actor string) error {
 	mngr.mutex.Lock()
 	defer mngr.mutex.Unlock()
 
@@ -144,11 +149,18 @@ func (mngr *AccountsManager) CreateAccount(accountData *data.Account) error {
 	}
 
 	if account, err := data.NewAccount(accountData.Email, accountData.Title, accountData.FirstName, accountData.LastName, accountData.Operator, accountData.Role, accountData.PhoneNumber, accountData.Password.Value); err == nil {
+		// New accounts need to verify their email address before access can be granted
+		link, err := mngr.prepareVerification(account)
+		if err != nil {
+			return errors.Wrap(err, "error while creating account")
+		}
+
 		mngr.accounts = append(mngr.accounts, account)
 		mngr.storage.AccountAdded(account)
-		mngr.writeAllAccounts()
+		mngr.writeAllAccounts(actor, data.AuditActionCreate, account)
 
 		mngr.sendEmail(account, nil, email.SendAccountCreated)
+		mngr.sendVerificationEmail(account, link)
 		mngr.callListeners(account, AccountsListener.AccountCreated)
 	} else {
 		return errors.Wrap(err, "error while creating account")
@@ -158,7 +170,7 @@ func (mngr *AccountsManager) CreateAccount(accountData *data.Account) error {
 }
 
 // UpdateAccount updates the account identified by the account email; if no such account exists, an error is returned.
-func (mngr *AccountsManager) UpdateAccount(accountData *data.Account, setPassword bool, copyData bool) error {
+func (mngr *AccountsManager) UpdateAccount(accountData *data.Account, setPassword bool, copyData bool, actor string) error {
 	mngr.mutex.Lock()
 	defer mngr.mutex.Unlock()
 
@@ -171,7 +183,7 @@ func (mngr *AccountsManager) UpdateAccount(accountData *data.Account, setPasswor
 		account.DateModified = time.Now()
 
 		mngr.storage.AccountUpdated(account)
-		mngr.writeAllAccounts()
+		mngr.writeAllAccounts(actor, data.AuditActionUpdate, account)
 
 		mngr.callListeners(account, AccountsListener.AccountUpdated)
 	} else {
@@ -182,7 +194,7 @@ func (mngr *AccountsManager) UpdateAccount(accountData *data.Account, setPasswor
 }
 
 // ConfigureAccount configures the account identified by the account email; if no such account exists, an error is returned.
-func (mngr *AccountsManager) ConfigureAccount(accountData *data.Account) error {
+func (mngr *AccountsManager) ConfigureAccount(accountData *data.Account, actor string) error {
 	mngr.mutex.Lock()
 	defer mngr.mutex.Unlock()
 
@@ -195,7 +207,7 @@ func (mngr *AccountsManager) ConfigureAccount(accountData *data.Account) error {
 		account.DateModified = time.Now()
 
 		mngr.storage.AccountUpdated(account)
-		mngr.writeAllAccounts()
+		mngr.writeAllAccounts(actor, data.AuditActionConfigure, account)
 
 		mngr.callListeners(account, AccountsListener.AccountUpdated)
 	} else {
@@ -206,7 +218,7 @@ func (mngr *AccountsManager) ConfigureAccount(accountData *data.Account) error {
 }
 
 // ResetPassword resets the password for the given user.
-func (mngr *AccountsManager) ResetPassword(name string) error {
+func (mngr *AccountsManager) ResetPassword(name string, actor string) error {
 	account, err := mngr.findAccount(FindByEmail, name)
 	if err != nil {
 		return errors.Wrap(err, "user to reset password for not found")
@@ -214,7 +226,7 @@ func (mngr *AccountsManager) ResetPassword(name string) error {
 	accountUpd := account.Clone(true)
 	accountUpd.Password.Value = password.MustGenerate(defaultPasswordLength, 2, 0, false, true)
 
-	err = mngr.UpdateAccount(accountUpd, true, false)
+	err = mngr.UpdateAccount(accountUpd, true, false, actor)
 	if err == nil {
 		mngr.sendEmail(accountUpd, nil, email.SendPasswordReset)
 	}
@@ -245,7 +257,7 @@ func (mngr *AccountsManager) FindAccountEx(by string, value string, cloneAccount
 }
 
 // GrantSitesAccess sets the Sites access status of the account identified by the account email; if no such account exists, an error is returned.
-func (mngr *AccountsManager) GrantSitesAccess(accountData *data.Account, grantAccess bool) error {
+func (mngr *AccountsManager) GrantSitesAccess(accountData *data.Account, grantAccess bool, actor string) error {
 	mngr.mutex.Lock()
 	defer mngr.mutex.Unlock()
 
@@ -254,11 +266,36 @@ func (mngr *AccountsManager) GrantSitesAccess(accountData *data.Account, grantAc
 		return errors.Wrap(err, "no account with the specified email exists")
 	}
 
-	return mngr.grantAccess(account, &account.Data.SitesAccess, grantAccess, email.SendSitesAccessGranted)
+	action := data.AuditActionGrantSitesAccess
+	if !grantAccess {
+		action = data.AuditActionRevokeSitesAccess
+	}
+	return mngr.grantAccess(account, &account.Data.SitesAccess, grantAccess, email.SendSitesAccessGranted, actor, action)
 }
 
 // GrantGOCDBAccess sets the GOCDB access status of the account identified by the account email; if no such account exists, an error is returned.
-func (mngr *AccountsManager) GrantGOCDBAccess(accountData *data.Account, grantAccess bool) error {
+func (mngr *AccountsManager) GrantGOCDBAccess(accountData *data.Account, grantAccess bool, actor string) error {
+	mngr.mutex.Lock()
+	defer mngr.mutex.Unlock()
+
+	account, err := mngr.findAccount(FindByEmail, accountData.Email)
+	if err != nil {
+		return errors.Wrap(err, "no account with the specified email exists")
+	}
+
+	action := data.AuditActionGrantGOCDBAccess
+	if !grantAccess {
+		action = data.AuditActionRevokeGOCDBAccess
+	}
+	return mngr.grantAccess(account, &account.Data.GOCDBAccess, grantAccess, email.SendGOCDBAccessGranted, actor, action)
+}
+
+// SetAccessRole assigns an access role to the account identified by the account email; if no such account exists, an error is returned.
+func (mngr *AccountsManager) SetAccessRole(accountData *data.Account, role string, actor string) error {
+	if !data.IsValidRole(role) {
+		return errors.Errorf("unknown access role %v", role)
+	}
+
 	mngr.mutex.Lock()
 	defer mngr.mutex.Unlock()
 
@@ -267,11 +304,18 @@ func (mngr *AccountsManager) GrantGOCDBAccess(accountData *data.Account, grantAc
 		return errors.Wrap(err, "no account with the specified email exists")
 	}
 
-	return mngr.grantAccess(account, &account.Data.GOCDBAccess, grantAccess, email.SendGOCDBAccessGranted)
+	account.Data.AccessRole = role
+
+	mngr.storage.AccountUpdated(account)
+	mngr.writeAllAccounts(actor, data.AuditActionSetAccessRole, account)
+
+	mngr.callListeners(account, AccountsListener.AccountUpdated)
+
+	return nil
 }
 
 // RemoveAccount removes the account identified by the account email; if no such account exists, an error is returned.
-func (mngr *AccountsManager) RemoveAccount(accountData *data.Account) error {
+func (mngr *AccountsManager) RemoveAccount(accountData *data.Account, actor string) error {
 	mngr.mutex.Lock()
 	defer mngr.mutex.Unlock()
 
@@ -279,7 +323,7 @@ func (mngr *AccountsManager) RemoveAccount(accountData *data.Account) error {
 		if strings.EqualFold(account.Email, accountData.Email) {
 			mngr.accounts = append(mngr.accounts[:i], mngr.accounts[i+1:]...)
 			mngr.storage.AccountRemoved(account)
-			mngr.writeAllAccounts()
+			mngr.writeAllAccounts(actor, data.AuditActionRemove, account)
 
 			mngr.callListeners(account, AccountsListener.AccountRemoved)
 			return nil
@@ -307,12 +351,29 @@ func (mngr *AccountsManager) CloneAccounts(erasePasswords bool) data.Accounts {
 	return clones
 }
 
-func (mngr *AccountsManager) grantAccess(account *data.Account, accessFlag *bool, grantAccess bool, emailFunc email.SendFunction) error {
+// ReadAuditLog retrieves the entire audit log from the storage; as it is read freshly, outside modifications have no effect.
+func (mngr *AccountsManager) ReadAuditLog() (data.AuditLog, error) {
+	mngr.mutex.RLock()
+	defer mngr.mutex.RUnlock()
+
+	auditLog, err := mngr.storage.ReadAuditLog()
+	if err != nil {
+		return nil, errors.Wrap(err, "unable to read the audit log")
+	}
+	return *auditLog, nil
+}
+
+func (mngr *AccountsManager) grantAccess(account *data.Account, accessFlag *bool, grantAccess bool, emailFunc email.SendFunction, actor, action string) error {
+	// Access can only be granted to verified accounts (but it can always be revoked)
+	if grantAccess && !account.IsEmailVerified() {
+		return errors.Errorf("the email address of the account has not been verified yet")
+	}
+
 	accessOld := *accessFlag
 	*accessFlag = grantAccess
 
 	mngr.storage.AccountUpdated(account)
-	mngr.writeAllAccounts()
+	mngr.writeAllAccounts(actor, action, account)
 
 	if *accessFlag && *accessFlag != accessOld {
 		mngr.sendEmail(account, nil, emailFunc)
@@ -323,6 +384,27 @@ func (mngr *AccountsManager) grantAccess(account *data.Account, accessFlag *bool
 	return nil
 }
 
+func (mngr *AccountsManager) setConnectorStatus(email string, connector string, status *data.ConnectorStatus) {
+	mngr.mutex.Lock()
+	defer mngr.mutex.Unlock()
+
+	account, err := mngr.findAccount(FindByEmail, email)
+	if err != nil {
+		// The account might have been removed in the meantime
+		return
+	}
+
+	if account.Data.Connectors == nil {
+		account.Data.Connectors = make(map[string]*data.ConnectorStatus)
+	}
+	account.Data.Connectors[connector] = status
+
+	mngr.storage.AccountUpdated(account)
+	if err := mngr.storage.WriteAccounts(&mngr.accounts); err != nil {
+		mngr.log.Warn().Err(err).Str("target", email).Str("connector", connector).Msg("error while writing accounts")
+	}
+}
+
 func (mngr *AccountsManager) callListeners(account *data.Account, cb AccountsListenerCallback) {
 	for _, listener := range mngr.accountsListeners {
 		cb(listener, account)
@@ -333,6 +415,26 @@ func (mngr *AccountsManager) sendEmail(account *data.Account, params map[string]
 	_ = sendFunc(account, []string{account.Email, mngr.conf.Email.NotificationsMail}, params, *mngr.conf)
 }
 
+// SetAlertsDispatcher sets the dispatcher used to report the accounts whose access could not be provisioned.
+func (mngr *AccountsManager) SetAlertsDispatcher(dispatcher *alerting.Dispatcher) {
+	mngr.accessProvisioner.setAlertFunc(func(account *data.Account, connector string, err error) {
+		if err := dispatcher.DispatchProvisioningFailure(account, connector, err); err != nil {
+			mngr.log.Err(err).Str("account", account.Email).Str("connector", connector).Msg("unable to dispatch the provisioning failure alert")
+		}
+	})
+}
+
+// WaitForProvisioning waits until the access of all accounts has been provisioned, or until the context is done.
+func (mngr *AccountsManager) WaitForProvisioning(ctx context.Context) error {
+	return mngr.accessProvisioner.Wait(ctx)
+}
+
+// StopProvisioning waits until the access of all accounts has been provisioned, or until the context is done,
+// in which case the pending retries are aborted.
+func (mngr *AccountsManager) StopProvisioning(ctx context.Context) error {
+	return mngr.accessProvisioner.Stop(ctx)
+}
+
 // NewAccountsManager creates a new accounts manager instance.
 func NewAccountsManager(storage data.Storage, conf *config.Configuration, log *zerolog.Logger) (*AccountsManager, error) {
 	mngr := &AccountsManager{}
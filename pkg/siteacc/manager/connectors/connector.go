@@ -0,0 +1,72 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package connectors
+
+import (
+	"github.com/cs3org/reva/pkg/siteacc/data"
+	"github.com/pkg/errors"
+)
+
+// Connector provisions the access of the accounts to a downstream system.
+type Connector interface {
+	// Name returns the name of the connector.
+	Name() string
+	// Scope returns the account scope driving the access to the system: the access is granted to the accounts
+	// having access to the scope, and revoked from the others.
+	Scope() string
+
+	// Grant grants the account access to the system, or updates its data if it already has access.
+	Grant(account *data.Account) error
+	// Revoke revokes the access of the account to the system.
+	Revoke(account *data.Account) error
+	// CheckStatus reports whether the account currently has access to the system;
+	// ErrStatusUnknown is returned if the system cannot be queried.
+	CheckStatus(account *data.Account) (bool, error)
+}
+
+// ErrStatusUnknown is returned by the connectors unable to query the access of an account.
+var ErrStatusUnknown = errors.New("the access status cannot be queried")
+
+// TransientError wraps the errors worth retrying, like network failures or unavailable services.
+type TransientError struct {
+	err error
+}
+
+func (e *TransientError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error.
+func (e *TransientError) Unwrap() error {
+	return e.err
+}
+
+// Transient marks the given error as transient.
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TransientError{err: err}
+}
+
+// IsTransient checks whether the given error is worth retrying.
+func IsTransient(err error) bool {
+	var transient *TransientError
+	return errors.As(err, &transient)
+}
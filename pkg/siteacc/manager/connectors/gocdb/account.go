@@ -26,6 +26,7 @@ import (
 
 	"github.com/cs3org/reva/pkg/mentix/utils/network"
 	"github.com/cs3org/reva/pkg/siteacc/data"
+	"github.com/cs3org/reva/pkg/siteacc/manager/connectors"
 	"github.com/pkg/errors"
 )
 
@@ -73,13 +74,17 @@ func writeAccount(account *data.Account, operation string, address string, apiKe
 
 	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return errors.Wrap(err, "unable to send data to endpoint")
+		return connectors.Transient(errors.Wrap(err, "unable to send data to endpoint"))
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= http.StatusBadRequest {
 		msg, _ := io.ReadAll(resp.Body)
-		return errors.Errorf("unable to perform request: %v", string(msg))
+		err := errors.Errorf("unable to perform request: %v", string(msg))
+		if resp.StatusCode >= http.StatusInternalServerError || resp.StatusCode == http.StatusTooManyRequests {
+			return connectors.Transient(err)
+		}
+		return err
 	}
 
 	return nil
@@ -0,0 +1,89 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package gocdb
+
+import (
+	"github.com/cs3org/reva/pkg/siteacc/config"
+	"github.com/cs3org/reva/pkg/siteacc/data"
+	"github.com/cs3org/reva/pkg/siteacc/manager/connectors"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+func init() {
+	connectors.Register("gocdb", New)
+}
+
+// Connector is the GOCDB accounts connector.
+type Connector struct {
+	conf *config.Configuration
+	log  *zerolog.Logger
+}
+
+func (connector *Connector) initialize(conf *config.Configuration, log *zerolog.Logger) error {
+	if conf == nil {
+		return errors.Errorf("no configuration provided")
+	}
+	connector.conf = conf
+
+	if log == nil {
+		return errors.Errorf("no logger provided")
+	}
+	connector.log = log
+
+	return nil
+}
+
+// Name returns the name of the connector.
+func (connector *Connector) Name() string {
+	return "gocdb"
+}
+
+// Scope returns the account scope driving the access to GOCDB.
+func (connector *Connector) Scope() string {
+	return data.ScopeGOCDB
+}
+
+// Grant creates the GOCDB account, or updates it if it already exists.
+func (connector *Connector) Grant(account *data.Account) error {
+	return writeAccount(account, opCreateOrUpdate, connector.conf.GOCDB.WriteURL, connector.conf.GOCDB.APIKey)
+}
+
+// Revoke deletes the GOCDB account.
+func (connector *Connector) Revoke(account *data.Account) error {
+	if err := writeAccount(account, opDelete, connector.conf.GOCDB.WriteURL, connector.conf.GOCDB.APIKey); err != nil && connectors.IsTransient(err) {
+		return err
+	}
+	// Other errors while deleting an account are ignored (account might not exist at all, for example)
+	return nil
+}
+
+// CheckStatus is not supported, as the GOCDB write API cannot be queried.
+func (connector *Connector) CheckStatus(account *data.Account) (bool, error) {
+	return false, connectors.ErrStatusUnknown
+}
+
+// New creates a new GOCDB accounts connector.
+func New(conf *config.Configuration, log *zerolog.Logger) (connectors.Connector, error) {
+	connector := &Connector{}
+	if err := connector.initialize(conf, log); err != nil {
+		return nil, errors.Wrap(err, "unable to initialize the GOCDB accounts connector")
+	}
+	return connector, nil
+}
@@ -0,0 +1,50 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package connectors
+
+import (
+	"github.com/cs3org/reva/pkg/siteacc/config"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+)
+
+// NewFunc is the function that connectors should register at init time.
+type NewFunc func(conf *config.Configuration, log *zerolog.Logger) (Connector, error)
+
+// NewFuncs is a map containing all the registered connectors.
+var NewFuncs = map[string]NewFunc{}
+
+// Register registers a new connector new function.
+// Not safe for concurrent use. Safe for use from package level init.
+func Register(name string, f NewFunc) {
+	NewFuncs[name] = f
+}
+
+// New creates the connector registered with the given name.
+func New(name string, conf *config.Configuration, log *zerolog.Logger) (Connector, error) {
+	f, ok := NewFuncs[name]
+	if !ok {
+		return nil, errors.Errorf("unknown connector %v", name)
+	}
+	connector, err := f(conf, log)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unable to create the %v connector", name)
+	}
+	return connector, nil
+}
@@ -0,0 +1,305 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package manager
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cs3org/reva/pkg/siteacc/config"
+	"github.com/cs3org/reva/pkg/siteacc/data"
+	"github.com/cs3org/reva/pkg/siteacc/manager/connectors"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+
+	// Load the connectors
+	_ "github.com/cs3org/reva/pkg/siteacc/manager/connectors/gocdb"
+)
+
+// provisioningStatusFunc records the status of a connector in the account with the given email address.
+type provisioningStatusFunc = func(email string, connector string, status *data.ConnectorStatus)
+
+// provisioningAlertFunc reports an account whose access could not be provisioned.
+type provisioningAlertFunc = func(account *data.Account, connector string, err error)
+
+// AccessProvisioner provisions the access of the accounts to the downstream systems through the connectors;
+// the connectors are run in the background, retrying the transient errors.
+type AccessProvisioner struct {
+	log *zerolog.Logger
+
+	connectors    []connectors.Connector
+	retries       int
+	retryInterval time.Duration
+
+	setStatus provisioningStatusFunc
+	alert     provisioningAlertFunc
+
+	// requests tracks the latest provisioning request of each account and connector, so that
+	// superseded requests are dropped; locks ensures that they are run one at a time;
+	// pending counts the requests not run yet, so that the entries are removed once all have been run
+	requests map[string]uint64
+	locks    map[string]*sync.Mutex
+	pending  map[string]int
+	sequence uint64
+	mutex    sync.Mutex
+
+	provisioning sync.WaitGroup
+
+	// ctx is canceled when the provisioner is stopped, aborting the pending retries
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+func (provisioner *AccessProvisioner) initialize(conf *config.Configuration, log *zerolog.Logger, setStatus provisioningStatusFunc) error {
+	if conf == nil {
+		return errors.Errorf("no configuration provided")
+	}
+
+	if log == nil {
+		return errors.Errorf("no logger provided")
+	}
+	provisioner.log = log
+
+	for _, name := range conf.Connectors.Enabled {
+		connector, err := connectors.New(name, conf, log)
+		if err != nil {
+			return err
+		}
+		provisioner.connectors = append(provisioner.connectors, connector)
+	}
+
+	provisioner.retries = conf.Connectors.Retries
+	provisioner.retryInterval = time.Duration(conf.Connectors.RetryInterval) * time.Second
+
+	provisioner.setStatus = setStatus
+	provisioner.alert = func(*data.Account, string, error) {}
+
+	provisioner.requests = make(map[string]uint64)
+	provisioner.locks = make(map[string]*sync.Mutex)
+	provisioner.pending = make(map[string]int)
+
+	provisioner.ctx, provisioner.cancel = context.WithCancel(context.Background())
+
+	return nil
+}
+
+// AccountCreated is called whenever an account was created.
+func (provisioner *AccessProvisioner) AccountCreated(account *data.Account) {
+	provisioner.provision(account, false)
+}
+
+// AccountUpdated is called whenever an account was updated.
+func (provisioner *AccessProvisioner) AccountUpdated(account *data.Account) {
+	provisioner.provision(account, false)
+}
+
+// AccountRemoved is called whenever an account was removed.
+func (provisioner *AccessProvisioner) AccountRemoved(account *data.Account) {
+	provisioner.provision(account, true)
+}
+
+func (provisioner *AccessProvisioner) provision(account *data.Account, removed bool) {
+	if account == nil {
+		return
+	}
+
+	for _, connector := range provisioner.connectors {
+		grant := !removed && account.CheckScopeAccess(connector.Scope())
+		// The account is cloned, as the connectors run in the background
+		provisioner.run(connector, account.Clone(true), grant, provisioner.newRequest(connector, account))
+	}
+}
+
+func (provisioner *AccessProvisioner) newRequest(connector connectors.Connector, account *data.Account) uint64 {
+	provisioner.mutex.Lock()
+	defer provisioner.mutex.Unlock()
+
+	key := requestKey(connector, account)
+	provisioner.sequence++
+	provisioner.requests[key] = provisioner.sequence
+	provisioner.pending[key]++
+	return provisioner.sequence
+}
+
+// requestDone removes the entries of an account and connector once all its requests have been run.
+func (provisioner *AccessProvisioner) requestDone(connector connectors.Connector, account *data.Account) {
+	provisioner.mutex.Lock()
+	defer provisioner.mutex.Unlock()
+
+	key := requestKey(connector, account)
+	provisioner.pending[key]--
+	if provisioner.pending[key] <= 0 {
+		delete(provisioner.pending, key)
+		delete(provisioner.requests, key)
+		delete(provisioner.locks, key)
+	}
+}
+
+func (provisioner *AccessProvisioner) superseded(connector connectors.Connector, account *data.Account, request uint64) bool {
+	provisioner.mutex.Lock()
+	defer provisioner.mutex.Unlock()
+
+	return provisioner.requests[requestKey(connector, account)] != request
+}
+
+func (provisioner *AccessProvisioner) lock(connector connectors.Connector, account *data.Account) *sync.Mutex {
+	provisioner.mutex.Lock()
+	defer provisioner.mutex.Unlock()
+
+	key := requestKey(connector, account)
+	lock, ok := provisioner.locks[key]
+	if !ok {
+		lock = &sync.Mutex{}
+		provisioner.locks[key] = lock
+	}
+	return lock
+}
+
+func (provisioner *AccessProvisioner) run(connector connectors.Connector, account *data.Account, grant bool, request uint64) {
+	provisioner.provisioning.Add(1)
+	go func() {
+		defer provisioner.provisioning.Done()
+		defer provisioner.requestDone(connector, account)
+
+		lock := provisioner.lock(connector, account)
+		lock.Lock()
+		defer lock.Unlock()
+
+		interval := provisioner.retryInterval
+		for attempt := 1; ; attempt++ {
+			if provisioner.superseded(connector, account, request) {
+				return
+			}
+
+			err := provisioner.apply(connector, account, grant)
+			status := &data.ConnectorStatus{
+				Granted:  grant,
+				Status:   data.ConnectorStatusDone,
+				Attempts: attempt,
+				Updated:  time.Now(),
+			}
+			switch {
+			case err == nil:
+				provisioner.setStatus(account.Email, connector.Name(), status)
+				return
+
+			case connectors.IsTransient(err) && attempt <= provisioner.retries:
+				provisioner.log.Warn().Err(err).Str("connector", connector.Name()).Str("account", account.Email).Int("attempt", attempt).Msg("unable to provision the account access, retrying")
+				status.Status = data.ConnectorStatusRetrying
+				status.Error = err.Error()
+				provisioner.setStatus(account.Email, connector.Name(), status)
+
+				timer := time.NewTimer(interval)
+				select {
+				case <-provisioner.ctx.Done():
+					timer.Stop()
+					provisioner.log.Warn().Str("connector", connector.Name()).Str("account", account.Email).Msg("provisioning stopped, the account access will not be retried")
+					return
+				case <-timer.C:
+				}
+				interval *= 2
+
+			default:
+				provisioner.log.Err(err).Str("connector", connector.Name()).Str("account", account.Email).Msg("unable to provision the account access")
+				status.Status = data.ConnectorStatusFailed
+				status.Error = err.Error()
+				provisioner.setStatus(account.Email, connector.Name(), status)
+				provisioner.alertFunc()(account, connector.Name(), err)
+				return
+			}
+		}
+	}()
+}
+
+func (provisioner *AccessProvisioner) setAlertFunc(alert provisioningAlertFunc) {
+	provisioner.mutex.Lock()
+	defer provisioner.mutex.Unlock()
+
+	provisioner.alert = alert
+}
+
+func (provisioner *AccessProvisioner) alertFunc() provisioningAlertFunc {
+	provisioner.mutex.Lock()
+	defer provisioner.mutex.Unlock()
+
+	return provisioner.alert
+}
+
+func (provisioner *AccessProvisioner) apply(connector connectors.Connector, account *data.Account, grant bool) error {
+	if grant {
+		if err := connector.Grant(account); err != nil {
+			return errors.Wrap(err, "unable to grant access")
+		}
+	} else {
+		if err := connector.Revoke(account); err != nil {
+			return errors.Wrap(err, "unable to revoke access")
+		}
+	}
+
+	// Make sure that the access is as expected, if the connector can tell
+	granted, err := connector.CheckStatus(account)
+	if err != nil {
+		if errors.Is(err, connectors.ErrStatusUnknown) {
+			return nil
+		}
+		return errors.Wrap(err, "unable to check the access status")
+	}
+	if granted != grant {
+		return errors.Errorf("the access status does not match (granted: %v)", granted)
+	}
+	return nil
+}
+
+// Wait waits until all accounts have been provisioned, or until the context is done.
+func (provisioner *AccessProvisioner) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		provisioner.provisioning.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrap(ctx.Err(), "not all accounts have been provisioned")
+	}
+}
+
+// Stop waits until all accounts have been provisioned, or until the context is done;
+// the pending retries are aborted in the latter case.
+func (provisioner *AccessProvisioner) Stop(ctx context.Context) error {
+	err := provisioner.Wait(ctx)
+	provisioner.cancel()
+	return err
+}
+
+func requestKey(connector connectors.Connector, account *data.Account) string {
+	return connector.Name() + "/" + strings.ToLower(account.Email)
+}
+
+func newAccessProvisioner(conf *config.Configuration, log *zerolog.Logger, setStatus provisioningStatusFunc) (*AccessProvisioner, error) {
+	provisioner := &AccessProvisioner{}
+	if err := provisioner.initialize(conf, log, setStatus); err != nil {
+		return nil, errors.Wrap(err, "unable to initialize the access provisioner")
+	}
+	return provisioner, nil
+}
@@ -0,0 +1,277 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package manager
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cs3org/reva/pkg/siteacc/config"
+	"github.com/cs3org/reva/pkg/siteacc/data"
+	"github.com/cs3org/reva/pkg/siteacc/manager/connectors"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func init() {
+	connectors.Register("mock", func(*config.Configuration, *zerolog.Logger) (connectors.Connector, error) {
+		return &mockConnector{granted: map[string]bool{}}, nil
+	})
+}
+
+// mockConnector keeps the accounts having access in memory; it fails the given number of times before succeeding.
+type mockConnector struct {
+	calls     []string
+	granted   map[string]bool
+	failures  int
+	transient bool
+	mutex     sync.Mutex
+}
+
+func (c *mockConnector) Name() string  { return "mock" }
+func (c *mockConnector) Scope() string { return data.ScopeGOCDB }
+
+func (c *mockConnector) Grant(account *data.Account) error {
+	return c.call("grant", account, true)
+}
+
+func (c *mockConnector) Revoke(account *data.Account) error {
+	return c.call("revoke", account, false)
+}
+
+func (c *mockConnector) CheckStatus(account *data.Account) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.granted[account.Email], nil
+}
+
+func (c *mockConnector) call(op string, account *data.Account, granted bool) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.calls = append(c.calls, op)
+	if c.failures > 0 {
+		c.failures--
+		err := errors.Errorf("%v failed", op)
+		if c.transient {
+			return connectors.Transient(err)
+		}
+		return err
+	}
+	c.granted[account.Email] = granted
+	return nil
+}
+
+func (c *mockConnector) fail(failures int, transient bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.calls = nil
+	c.failures = failures
+	c.transient = transient
+}
+
+func (c *mockConnector) lastCalls() ([]string, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.calls, c.granted["einstein@example.org"]
+}
+
+func newTestProvisioning(t *testing.T) (*AccountsManager, *mockConnector) {
+	mngr := newTestAccountsManager(t, "mock")
+	waitForProvisioning(t, mngr)
+	return mngr, mngr.accessProvisioner.connectors[0].(*mockConnector)
+}
+
+func waitForProvisioning(t *testing.T, mngr *AccountsManager) {
+	if err := mngr.WaitForProvisioning(context.Background()); err != nil {
+		t.Fatalf("not expected error while waiting for the provisioning: %+v", err)
+	}
+}
+
+func connectorStatus(t *testing.T, mngr *AccountsManager) *data.ConnectorStatus {
+	account, err := mngr.FindAccount(FindByEmail, "einstein@example.org")
+	assert.NoError(t, err)
+	return account.Data.Connectors["mock"]
+}
+
+func TestAccessProvisioning(t *testing.T) {
+	mngr, connector := newTestProvisioning(t)
+	account := &data.Account{Email: "einstein@example.org"}
+
+	// new accounts have no access
+	calls, granted := connector.lastCalls()
+	assert.Equal(t, []string{"revoke"}, calls)
+	assert.False(t, granted)
+	if status := connectorStatus(t, mngr); assert.NotNil(t, status) {
+		assert.False(t, status.Granted)
+		assert.Equal(t, data.ConnectorStatusDone, status.Status)
+	}
+
+	assert.NoError(t, mngr.ConfirmEmail(account, "admin"))
+	waitForProvisioning(t, mngr)
+	connector.fail(0, false)
+	assert.NoError(t, mngr.GrantGOCDBAccess(account, true, "admin"))
+	waitForProvisioning(t, mngr)
+
+	calls, granted = connector.lastCalls()
+	assert.Equal(t, []string{"grant"}, calls)
+	assert.True(t, granted)
+	if status := connectorStatus(t, mngr); assert.NotNil(t, status) {
+		assert.True(t, status.Granted)
+		assert.Equal(t, data.ConnectorStatusDone, status.Status)
+		assert.Equal(t, 1, status.Attempts)
+	}
+
+	// the status is kept when the account data is copied
+	assert.NoError(t, mngr.UpdateAccount(&data.Account{Email: account.Email, FirstName: "Albert", LastName: "Einstein", Role: "Admin"}, false, true, "admin"))
+	waitForProvisioning(t, mngr)
+	assert.NotNil(t, connectorStatus(t, mngr))
+
+	connector.fail(0, false)
+	assert.NoError(t, mngr.RemoveAccount(account, "admin"))
+	waitForProvisioning(t, mngr)
+
+	calls, granted = connector.lastCalls()
+	assert.Equal(t, []string{"revoke"}, calls)
+	assert.False(t, granted)
+}
+
+func TestAccessProvisioningRetries(t *testing.T) {
+	mngr, connector := newTestProvisioning(t)
+	account := &data.Account{Email: "einstein@example.org"}
+	assert.NoError(t, mngr.ConfirmEmail(account, "admin"))
+	waitForProvisioning(t, mngr)
+
+	// transient errors are retried
+	connector.fail(2, true)
+	assert.NoError(t, mngr.GrantGOCDBAccess(account, true, "admin"))
+	waitForProvisioning(t, mngr)
+
+	calls, granted := connector.lastCalls()
+	assert.Equal(t, []string{"grant", "grant", "grant"}, calls)
+	assert.True(t, granted)
+	if status := connectorStatus(t, mngr); assert.NotNil(t, status) {
+		assert.Equal(t, data.ConnectorStatusDone, status.Status)
+		assert.Equal(t, 3, status.Attempts)
+		assert.Empty(t, status.Error)
+	}
+}
+
+func TestAccessProvisioningFailures(t *testing.T) {
+	tests := []struct {
+		name      string
+		failures  int
+		transient bool
+		attempts  int
+	}{
+		{name: "permanent error", failures: 1, transient: false, attempts: 1},
+		{name: "too many transient errors", failures: 5, transient: true, attempts: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mngr, connector := newTestProvisioning(t)
+			account := &data.Account{Email: "einstein@example.org"}
+			assert.NoError(t, mngr.ConfirmEmail(account, "admin"))
+			waitForProvisioning(t, mngr)
+
+			var alerts []string
+			mngr.accessProvisioner.setAlertFunc(func(account *data.Account, connector string, err error) {
+				alerts = append(alerts, account.Email+"/"+connector)
+			})
+
+			connector.fail(tt.failures, tt.transient)
+			assert.NoError(t, mngr.GrantGOCDBAccess(account, true, "admin"))
+			waitForProvisioning(t, mngr)
+
+			calls, granted := connector.lastCalls()
+			assert.Len(t, calls, tt.attempts)
+			assert.False(t, granted)
+			if status := connectorStatus(t, mngr); assert.NotNil(t, status) {
+				assert.True(t, status.Granted)
+				assert.Equal(t, data.ConnectorStatusFailed, status.Status)
+				assert.Equal(t, tt.attempts, status.Attempts)
+				assert.NotEmpty(t, status.Error)
+			}
+			assert.Equal(t, []string{"einstein@example.org/mock"}, alerts)
+		})
+	}
+}
+
+func TestAccessProvisioningWithoutRetries(t *testing.T) {
+	mngr, connector := newTestProvisioning(t)
+	mngr.accessProvisioner.retries = 0
+	account := &data.Account{Email: "einstein@example.org"}
+	assert.NoError(t, mngr.ConfirmEmail(account, "admin"))
+	waitForProvisioning(t, mngr)
+
+	connector.fail(1, true)
+	assert.NoError(t, mngr.GrantGOCDBAccess(account, true, "admin"))
+	waitForProvisioning(t, mngr)
+
+	calls, granted := connector.lastCalls()
+	assert.Equal(t, []string{"grant"}, calls)
+	assert.False(t, granted)
+	if status := connectorStatus(t, mngr); assert.NotNil(t, status) {
+		assert.Equal(t, data.ConnectorStatusFailed, status.Status)
+		assert.Equal(t, 1, status.Attempts)
+	}
+}
+
+func TestAccessProvisioningRequestsRemoved(t *testing.T) {
+	mngr, _ := newTestProvisioning(t)
+	account := &data.Account{Email: "einstein@example.org"}
+	assert.NoError(t, mngr.ConfirmEmail(account, "admin"))
+	assert.NoError(t, mngr.GrantGOCDBAccess(account, true, "admin"))
+	waitForProvisioning(t, mngr)
+
+	provisioner := mngr.accessProvisioner
+	provisioner.mutex.Lock()
+	defer provisioner.mutex.Unlock()
+	assert.Empty(t, provisioner.requests)
+	assert.Empty(t, provisioner.locks)
+	assert.Empty(t, provisioner.pending)
+}
+
+func TestAccessProvisioningStop(t *testing.T) {
+	mngr, connector := newTestProvisioning(t)
+	mngr.accessProvisioner.retryInterval = time.Hour
+	account := &data.Account{Email: "einstein@example.org"}
+	assert.NoError(t, mngr.ConfirmEmail(account, "admin"))
+	waitForProvisioning(t, mngr)
+
+	connector.fail(1, true)
+	assert.NoError(t, mngr.GrantGOCDBAccess(account, true, "admin"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Error(t, mngr.StopProvisioning(ctx))
+
+	// the pending retry is aborted rather than waiting for the retry interval
+	waitForProvisioning(t, mngr)
+	calls, granted := connector.lastCalls()
+	assert.Equal(t, []string{"grant"}, calls)
+	assert.False(t, granted)
+	if status := connectorStatus(t, mngr); assert.NotNil(t, status) {
+		assert.Equal(t, data.ConnectorStatusRetrying, status.Status)
+	}
+}
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/sethvargo/go-password/password"
 )
@@ -46,6 +47,8 @@ func generateUserToken(user string, scope string, timeout int) (string, error) {
 	// Create a JWT as the user token
 	claims := userToken{
 		StandardClaims: jwt.StandardClaims{
+			// A unique ID makes every token distinct, even if issued within the same second
+			Id:        uuid.NewString(),
 			ExpiresAt: time.Now().Add(time.Duration(timeout) * time.Second).Unix(),
 			Issuer:    tokenIssuer,
 			IssuedAt:  time.Now().Unix(),
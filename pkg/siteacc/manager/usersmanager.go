@@ -22,6 +22,7 @@ import (
 	"strings"
 
 	"github.com/cs3org/reva/pkg/siteacc/config"
+	"github.com/cs3org/reva/pkg/siteacc/data"
 	"github.com/cs3org/reva/pkg/siteacc/html"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
@@ -87,8 +88,8 @@ func (mngr *UsersManager) LoginUser(name, password string, scope string, session
 		return "", errors.Wrap(err, "no operator with the specified ID exists")
 	}
 
-	// Store the user account in the session
-	session.LoginUser(account, op)
+	// Store the user account in the session, along with its access role
+	session.LoginUser(account, op, mngr.ResolveRole(account))
 
 	// Generate a token that can be used as a "ticket"
 	token, err := generateUserToken(session.LoggedInUser().Account.Email, scope, mngr.conf.Webserver.SessionTimeout)
@@ -99,6 +100,19 @@ func (mngr *UsersManager) LoginUser(name, password string, scope string, session
 	return token, nil
 }
 
+// ResolveRole determines the access role of the given account.
+// Accounts without an explicitly assigned role are operators if they were granted Sites access, and account owners otherwise.
+func (mngr *UsersManager) ResolveRole(account *data.Account) string {
+	if data.IsValidRole(account.Data.AccessRole) {
+		return account.Data.AccessRole
+	}
+
+	if account.Data.SitesAccess {
+		return data.RoleOperator
+	}
+	return data.RoleAccountOwner
+}
+
 // LogoutUser logs the current user out.
 func (mngr *UsersManager) LogoutUser(session *html.Session) {
 	// Just unset the user account stored in the session
@@ -0,0 +1,156 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package manager
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cs3org/reva/pkg/siteacc/config"
+	"github.com/cs3org/reva/pkg/siteacc/data"
+	"github.com/cs3org/reva/pkg/siteacc/email"
+	"github.com/pkg/errors"
+)
+
+const (
+	verificationScope = "email-verification"
+)
+
+// VerifyEmail verifies the email address of the account the given verification token was issued for.
+// Tokens can only be used once, and only the most recently sent one is valid.
+func (mngr *AccountsManager) VerifyEmail(token string) error {
+	utoken, err := extractUserToken(token)
+	if err != nil {
+		return errors.Wrap(err, "invalid or expired verification token")
+	}
+	if utoken.Scope != verificationScope {
+		return errors.Errorf("invalid verification token")
+	}
+
+	mngr.mutex.Lock()
+	defer mngr.mutex.Unlock()
+
+	account, err := mngr.findAccount(FindByEmail, utoken.User)
+	if err != nil {
+		return errors.Wrap(err, "no account with the specified email exists")
+	}
+	if account.IsEmailVerified() {
+		return errors.Errorf("the email address has already been verified")
+	}
+	if subtle.ConstantTimeCompare([]byte(account.Verification.TokenHash), []byte(hashVerificationToken(token))) != 1 {
+		return errors.Errorf("the verification token has already been used or was superseded by a newer one")
+	}
+
+	mngr.setEmailVerified(account, account.Email, data.AuditActionVerifyEmail)
+	return nil
+}
+
+// ResendVerification sends a new verification email to the account identified by the account email;
+// a new email can only be requested once per configured resend interval.
+func (mngr *AccountsManager) ResendVerification(accountData *data.Account) error {
+	mngr.mutex.Lock()
+	defer mngr.mutex.Unlock()
+
+	account, err := mngr.findAccount(FindByEmail, accountData.Email)
+	if err != nil {
+		return errors.Wrap(err, "no account with the specified email exists")
+	}
+	if account.IsEmailVerified() {
+		return errors.Errorf("the email address has already been verified")
+	}
+	if wait := time.Until(account.Verification.SentAt.Add(time.Duration(mngr.conf.Verification.ResendInterval) * time.Second)); wait > 0 {
+		return errors.Errorf("a verification email has been sent recently; please try again in %v", wait.Round(time.Second))
+	}
+
+	link, err := mngr.prepareVerification(account)
+	if err != nil {
+		return err
+	}
+	mngr.storage.AccountUpdated(account)
+	if err := mngr.storage.WriteAccounts(&mngr.accounts); err != nil {
+		// Just warn when not being able to write accounts; the previous token is invalid in any case
+		mngr.log.Warn().Err(err).Str("target", account.Email).Msg("error while writing accounts")
+	}
+
+	mngr.sendVerificationEmail(account, link)
+	return nil
+}
+
+// ConfirmEmail marks the email address of the account identified by the account email as verified without
+// requiring the verification by its owner; if no such account exists, an error is returned.
+func (mngr *AccountsManager) ConfirmEmail(accountData *data.Account, actor string) error {
+	mngr.mutex.Lock()
+	defer mngr.mutex.Unlock()
+
+	account, err := mngr.findAccount(FindByEmail, accountData.Email)
+	if err != nil {
+		return errors.Wrap(err, "no account with the specified email exists")
+	}
+	if account.IsEmailVerified() {
+		return errors.Errorf("the email address has already been verified")
+	}
+
+	mngr.setEmailVerified(account, actor, data.AuditActionConfirmEmail)
+	return nil
+}
+
+// prepareVerification generates a new verification token for the account, replacing any previous one,
+// and returns the link to be sent to the account owner.
+func (mngr *AccountsManager) prepareVerification(account *data.Account) (string, error) {
+	token, err := generateUserToken(account.Email, verificationScope, mngr.conf.Verification.TokenTimeout)
+	if err != nil {
+		return "", errors.Wrap(err, "unable to generate verification token")
+	}
+
+	// Only the hash of the token is stored, so that the token can't be used by anyone reading the storage
+	account.Verification = &data.AccountVerification{
+		TokenHash: hashVerificationToken(token),
+		SentAt:    time.Now(),
+	}
+
+	return getVerificationLink(mngr.conf, token), nil
+}
+
+func (mngr *AccountsManager) setEmailVerified(account *data.Account, actor, action string) {
+	account.Verification = &data.AccountVerification{Verified: true}
+	account.DateModified = time.Now()
+
+	mngr.storage.AccountUpdated(account)
+	mngr.writeAllAccounts(actor, action, account)
+
+	mngr.callListeners(account, AccountsListener.AccountUpdated)
+}
+
+func (mngr *AccountsManager) sendVerificationEmail(account *data.Account, link string) {
+	// The verification link is only sent to the account owner
+	_ = email.SendEmailVerification(account, []string{account.Email}, map[string]string{"Link": link}, *mngr.conf)
+}
+
+func getVerificationLink(conf *config.Configuration, token string) string {
+	return strings.TrimRight(conf.Webserver.URL, "/") + config.EndpointVerifyEmail + "?token=" + url.QueryEscape(token)
+}
+
+func hashVerificationToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,158 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package manager
+
+import (
+	"net/url"
+	"path/filepath"
+	"testing"
+
+	"github.com/cs3org/reva/pkg/siteacc/config"
+	"github.com/cs3org/reva/pkg/siteacc/data"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestAccountsManager(t *testing.T, connectors ...string) *AccountsManager {
+	dir := t.TempDir()
+	conf := &config.Configuration{}
+	conf.Connectors.Enabled = connectors
+	conf.Connectors.Retries = 2
+	conf.Storage.File.OperatorsFile = filepath.Join(dir, "operators.json")
+	conf.Storage.File.AccountsFile = filepath.Join(dir, "accounts.json")
+	conf.Webserver.URL = "https://sciencemesh.example.org/siteacc/"
+	conf.Verification.TokenTimeout = 60
+	conf.Verification.ResendInterval = 60
+
+	log := zerolog.Nop()
+	storage, err := data.NewFileStorage(conf, &log)
+	if err != nil {
+		t.Fatalf("not expected error while creating the file storage: %+v", err)
+	}
+	mngr, err := NewAccountsManager(storage, conf, &log)
+	if err != nil {
+		t.Fatalf("not expected error while creating the accounts manager: %+v", err)
+	}
+
+	account := &data.Account{
+		Email:     "einstein@example.org",
+		FirstName: "Albert",
+		LastName:  "Einstein",
+		Operator:  "cern",
+		Role:      "Admin",
+	}
+	account.Password.Value = "Relativity-1905!"
+	if err := mngr.CreateAccount(account, "einstein@example.org"); err != nil {
+		t.Fatalf("not expected error while creating the account: %+v", err)
+	}
+	return mngr
+}
+
+// newVerificationToken replaces the verification token of the account, as the emails are not sent in the tests.
+func newVerificationToken(t *testing.T, mngr *AccountsManager, email string) string {
+	account, err := mngr.findAccount(FindByEmail, email)
+	assert.NoError(t, err)
+	link, err := mngr.prepareVerification(account)
+	assert.NoError(t, err)
+
+	u, err := url.Parse(link)
+	assert.NoError(t, err)
+	assert.Equal(t, "/siteacc/verify", u.Path)
+	return u.Query().Get("token")
+}
+
+func TestVerifyEmail(t *testing.T) {
+	mngr := newTestAccountsManager(t)
+	account := &data.Account{Email: "einstein@example.org"}
+
+	stored, err := mngr.FindAccount(FindByEmail, account.Email)
+	assert.NoError(t, err)
+	assert.False(t, stored.IsEmailVerified())
+	assert.Error(t, mngr.GrantSitesAccess(account, true, "admin"))
+
+	superseded := newVerificationToken(t, mngr, account.Email)
+	token := newVerificationToken(t, mngr, account.Email)
+	assert.Error(t, mngr.VerifyEmail(superseded))
+	assert.Error(t, mngr.VerifyEmail("invalid"))
+
+	assert.NoError(t, mngr.VerifyEmail(token))
+	stored, err = mngr.FindAccount(FindByEmail, account.Email)
+	assert.NoError(t, err)
+	assert.True(t, stored.IsEmailVerified())
+
+	// tokens can only be used once
+	assert.Error(t, mngr.VerifyEmail(token))
+
+	assert.NoError(t, mngr.GrantSitesAccess(account, true, "admin"))
+
+	auditLog, err := mngr.ReadAuditLog()
+	assert.NoError(t, err)
+	if assert.Len(t, auditLog, 3) {
+		assert.Equal(t, data.AuditActionVerifyEmail, auditLog[1].Action)
+		assert.Equal(t, account.Email, auditLog[1].Actor)
+	}
+}
+
+func TestVerifyEmailExpiredToken(t *testing.T) {
+	mngr := newTestAccountsManager(t)
+
+	mngr.conf.Verification.TokenTimeout = -60
+	token := newVerificationToken(t, mngr, "einstein@example.org")
+	assert.Error(t, mngr.VerifyEmail(token))
+}
+
+func TestVerifyEmailLoginToken(t *testing.T) {
+	mngr := newTestAccountsManager(t)
+
+	token, err := generateUserToken("einstein@example.org", data.ScopeDefault, 60)
+	assert.NoError(t, err)
+	assert.Error(t, mngr.VerifyEmail(token))
+}
+
+func TestResendVerification(t *testing.T) {
+	mngr := newTestAccountsManager(t)
+	account := &data.Account{Email: "einstein@example.org"}
+
+	// the verification email was sent when the account was created
+	assert.Error(t, mngr.ResendVerification(account))
+
+	mngr.conf.Verification.ResendInterval = 0
+	old := newVerificationToken(t, mngr, account.Email)
+	assert.NoError(t, mngr.ResendVerification(account))
+	assert.Error(t, mngr.VerifyEmail(old))
+
+	assert.NoError(t, mngr.ConfirmEmail(account, "admin"))
+	assert.Error(t, mngr.ResendVerification(account))
+}
+
+func TestConfirmEmail(t *testing.T) {
+	mngr := newTestAccountsManager(t)
+	account := &data.Account{Email: "einstein@example.org"}
+
+	assert.NoError(t, mngr.ConfirmEmail(account, "admin"))
+	assert.Error(t, mngr.ConfirmEmail(account, "admin"))
+	assert.NoError(t, mngr.GrantGOCDBAccess(account, true, "admin"))
+
+	auditLog, err := mngr.ReadAuditLog()
+	assert.NoError(t, err)
+	if assert.Len(t, auditLog, 3) {
+		assert.Equal(t, data.AuditActionConfirmEmail, auditLog[1].Action)
+		assert.Equal(t, "admin", auditLog[1].Actor)
+	}
+}
@@ -94,6 +94,34 @@ function handleResetPassword() {
 
     xhr.send(JSON.stringify(postData));
 }
+
+function handleResendVerification() {
+	const formData = new FormData(document.querySelector("form"));
+	if (!verifyForm(formData, false)) {
+		return;
+	}
+
+	setState(STATE_STATUS, "Sending verification email... this should only take a moment.", "form", null, false);
+
+	var xhr = new XMLHttpRequest();
+    xhr.open("POST", "{{getServerAddress}}/resend-verification");
+    xhr.setRequestHeader('Content-Type', 'application/json; charset=UTF-8');
+
+	xhr.onload = function() {
+		if (this.status == 200) {
+			setState(STATE_SUCCESS, "A new verification email has been sent! Please check your inbox.", "form", null, true);
+		} else {
+			var resp = JSON.parse(this.responseText);
+			setState(STATE_ERROR, "An error occurred while trying to send the verification email:<br><em>" + resp.error + "</em>", "form", null, true);
+		}
+	}
+
+	var postData = {
+        "email": formData.getTrimmed("email")
+    };
+
+    xhr.send(JSON.stringify(postData));
+}
 `
 
 const tplStyleSheet = `
@@ -119,7 +147,8 @@ const tplBody = `
 		<div style="grid-row: 1;"><label for="password">Password: <span class="mandatory">*</span></label></div>
 		<div style="grid-row: 2;"><input type="password" id="password" name="password"/></div>
 		<div style="grid-row: 3; grid-column: 2; font-style: italic; font-size: 0.8em;">
-			Forgot your password? Click <a href="#" onClick="handleResetPassword();">here</a> to reset it.
+			Forgot your password? Click <a href="#" onClick="handleResetPassword();">here</a> to reset it.<br>
+			Didn't receive the verification email? Click <a href="#" onClick="handleResendVerification();">here</a> to send it again.
 		</div>
 
 		<div style="grid-row: 4; align-self: center;">
@@ -41,6 +41,10 @@ function handleAction(action, email) {
 
     xhr.send(JSON.stringify(postData));
 }
+
+function handleExport(format) {
+	window.location.assign("{{getServerAddress}}/admin/export?data=accounts&format=" + format);
+}
 `
 
 const tplStyleSheet = `
@@ -83,8 +87,13 @@ const tplBody = `
 			<div>
 				<strong>Account data:</strong>
 				<ul style="padding-left: 1em; padding-top: 0em;">	
+					<li>Email address: <em>{{if .IsEmailVerified}}Verified{{else}}Not verified{{end}}</em></li>
 					<li>Sites access: <em>{{if .Data.SitesAccess}}Granted{{else}}Not granted{{end}}</em></li>
 					<li>GOCDB access: <em>{{if .Data.GOCDBAccess}}Granted{{else}}Not granted{{end}}</em></li>	
+					<li>Access role: <em>{{if .Data.AccessRole}}{{.Data.AccessRole}}{{else}}Default{{end}}</em></li>
+				{{range $name, $status := .Data.Connectors}}
+					<li>Connector {{$name}}: <em>{{if $status.Granted}}Granted{{else}}Revoked{{end}} ({{$status.Status}}, {{$status.Attempts}} attempt(s), {{$status.Updated.Format "Jan 02, 2006 15:04"}}){{if $status.Error}}: {{$status.Error}}{{end}}</em></li>
+				{{end}}
 				</ul>
 			</div>
 
@@ -92,18 +101,29 @@ const tplBody = `
 
 			<div>
 				<form method="POST" style="width: 100%;">
+				{{if not .IsEmailVerified}}
+					<button type="button" onClick="handleAction('confirm-email', '{{.Email}}');">Confirm email address</button>
+				{{end}}
+
 				{{if .Data.SitesAccess}}
 					<button type="button" onClick="handleAction('grant-sites-access?status=false', '{{.Email}}');">Revoke Sites access</button>
 				{{else}}
-					<button type="button" onClick="handleAction('grant-sites-access?status=true', '{{.Email}}');">Grant Sites access</button>
+					<button type="button" onClick="handleAction('grant-sites-access?status=true', '{{.Email}}');" {{if not .IsEmailVerified}}disabled{{end}}>Grant Sites access</button>
 				{{end}}
 
 				{{if .Data.GOCDBAccess}}
 					<button type="button" onClick="handleAction('grant-gocdb-access?status=false', '{{.Email}}');">Revoke GOCDB access</button>
 				{{else}}
-					<button type="button" onClick="handleAction('grant-gocdb-access?status=true', '{{.Email}}');">Grant GOCDB access</button>
+					<button type="button" onClick="handleAction('grant-gocdb-access?status=true', '{{.Email}}');" {{if not .IsEmailVerified}}disabled{{end}}>Grant GOCDB access</button>
 				{{end}}
 
+				<select onChange="handleAction('set-access-role?role=' + this.value, '{{.Email}}');">
+					<option value="" selected disabled>Set access role...</option>
+					<option value="account-owner">Account owner</option>
+					<option value="operator">Operator</option>
+					<option value="admin">Administrator</option>
+				</select>
+
 					<span style="width: 25px;">&nbsp;</span>
 					<button type="button" onClick="handleAction('remove', '{{.Email}}');" style="float: right;">Remove</button>
 				</form>
@@ -113,6 +133,12 @@ const tplBody = `
 	{{end}}
 	</ol>
 </div>
+<div>
+	<form method="POST" style="width: 100%;">
+		<button type="button" onClick="handleExport('csv');">Export as CSV</button>
+		<button type="button" onClick="handleExport('json');">Export as JSON</button>
+	</form>
+</div>
 <div>
 	<p>Go <a href="{{getServerAddress}}/admin/?path=manage">back</a> to the main page.</p>
 </div>
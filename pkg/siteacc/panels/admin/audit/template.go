@@ -0,0 +1,75 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package audit
+
+const tplJavaScript = `
+function handleExport(format) {
+	window.location.assign("{{getServerAddress}}/admin/export?data=audit&format=" + format);
+}
+`
+
+const tplStyleSheet = `
+html * {
+	font-family: arial !important;
+}
+table {
+	border-collapse: collapse;
+}
+th, td {
+	padding: 2px 10px;
+	text-align: left;
+}
+tr:nth-child(even) {
+	background-color: #f2f2f2;
+}
+`
+
+const tplBody = `
+<div>
+	<p>There are currently <strong>{{.AuditLog | len}} actions</strong> recorded in the audit log:</p>
+</div>
+<div style="font-size: 14px;">
+	<table>
+		<tr>
+			<th>Timestamp</th>
+			<th>Actor</th>
+			<th>Action</th>
+			<th>Target</th>
+		</tr>
+	{{range .AuditLog}}
+		<tr>
+			<td>{{.Timestamp.Format "Jan 02, 2006 15:04:05"}}</td>
+			<td>{{.Actor}}</td>
+			<td>{{.Action}}</td>
+			<td>{{.Target}}</td>
+		</tr>
+	{{end}}
+	</table>
+</div>
+<div>&nbsp;</div>
+<div>
+	<form method="POST" style="width: 100%;">
+		<button type="button" onClick="handleExport('csv');">Export as CSV</button>
+		<button type="button" onClick="handleExport('json');">Export as JSON</button>
+	</form>
+</div>
+<div>
+	<p>Go <a href="{{getServerAddress}}/admin/?path=manage">back</a> to the main page.</p>
+</div>
+`
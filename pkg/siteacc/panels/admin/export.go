@@ -0,0 +1,77 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package admin
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cs3org/reva/pkg/siteacc/data"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ExportAccounts exports all stored accounts.
+	ExportAccounts = "accounts"
+	// ExportAuditLog exports the audit log.
+	ExportAuditLog = "audit"
+)
+
+// Export writes the requested data in the requested format to the response writer.
+func (panel *Panel) Export(w http.ResponseWriter, r *http.Request, accounts *data.Accounts, auditLog *data.AuditLog) error {
+	what := strings.ToLower(r.URL.Query().Get("data"))
+	if what == "" {
+		what = ExportAccounts
+	}
+	if what != ExportAccounts && what != ExportAuditLog {
+		return errors.Errorf("unsupported export data %v", what)
+	}
+
+	format, err := data.GetExportFormat(r)
+	if err != nil {
+		return err
+	}
+	data.SetExportHeaders(w, what, format)
+
+	if what == ExportAccounts {
+		return data.WriteAccounts(w, format, *accounts, panel.queryOperatorName)
+	}
+
+	if format == data.ExportFormatJSON {
+		jsonData, _ := json.MarshalIndent(auditLog, "", "\t")
+		_, _ = w.Write(jsonData)
+		return nil
+	}
+
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"Timestamp", "Actor", "Action", "Target"})
+	for _, entry := range *auditLog {
+		_ = writer.Write([]string{entry.Timestamp.Format(time.RFC3339), entry.Actor, entry.Action, entry.Target})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func (panel *Panel) queryOperatorName(opID string) string {
+	opName, _ := data.QueryOperatorName(opID, panel.Config().Mentix.URL, panel.Config().Mentix.DataEndpoint)
+	return opName
+}
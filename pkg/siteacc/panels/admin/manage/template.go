@@ -28,6 +28,11 @@ function handleViewSites() {
 	setState(STATE_STATUS, "Redirecting to the sites overview...");
 	window.location.replace("{{getServerAddress}}/admin/?path=sites");
 }
+
+function handleViewAuditLog() {
+	setState(STATE_STATUS, "Redirecting to the audit log...");
+	window.location.replace("{{getServerAddress}}/admin/?path=audit");
+}
 `
 
 const tplStyleSheet = `
@@ -49,6 +54,7 @@ const tplBody = `
 		<div>
 			<button type="button" onClick="handleViewAccounts();">View accounts</button>
 			<button type="button" onClick="handleViewSites();">View sites</button>	
+			<button type="button" onClick="handleViewAuditLog();">View audit log</button>
 		</div>	
 	</form>
 </div>
@@ -26,6 +26,7 @@ import (
 	"github.com/cs3org/reva/pkg/siteacc/html"
 	"github.com/cs3org/reva/pkg/siteacc/panels"
 	"github.com/cs3org/reva/pkg/siteacc/panels/admin/accounts"
+	"github.com/cs3org/reva/pkg/siteacc/panels/admin/audit"
 	"github.com/cs3org/reva/pkg/siteacc/panels/admin/manage"
 	"github.com/cs3org/reva/pkg/siteacc/panels/admin/sites"
 	"github.com/pkg/errors"
@@ -42,6 +43,7 @@ const (
 	templateManage   = "manage"
 	templateAccounts = "accounts"
 	templateSites    = "sites"
+	templateAudit    = "audit"
 )
 
 func (panel *Panel) initialize(conf *config.Configuration, log *zerolog.Logger) error {
@@ -62,6 +64,11 @@ func (panel *Panel) initialize(conf *config.Configuration, log *zerolog.Logger)
 			Name:     "sites",
 			Provider: &sites.PanelTemplate{},
 		},
+		{
+			ID:       templateAudit,
+			Name:     "audit",
+			Provider: &audit.PanelTemplate{},
+		},
 	}
 
 	// Initialize base
@@ -74,7 +81,7 @@ func (panel *Panel) initialize(conf *config.Configuration, log *zerolog.Logger)
 
 // GetActiveTemplate returns the name of the active template.
 func (panel *Panel) GetActiveTemplate(session *html.Session, path string) string {
-	validPaths := []string{templateManage, templateAccounts, templateSites}
+	validPaths := []string{templateManage, templateAccounts, templateSites, templateAudit}
 	return panel.GetPathTemplate(validPaths, templateManage, path)
 }
 
@@ -84,7 +91,7 @@ func (panel *Panel) PreExecute(*html.Session, string, http.ResponseWriter, *http
 }
 
 // Execute generates the HTTP output of the panel and writes it to the response writer.
-func (panel *Panel) Execute(w http.ResponseWriter, r *http.Request, session *html.Session, accounts *data.Accounts, operators *data.Operators) error {
+func (panel *Panel) Execute(w http.ResponseWriter, r *http.Request, session *html.Session, accounts *data.Accounts, operators *data.Operators, auditLog *data.AuditLog) error {
 	// Clone all operators
 	opsClone, err := panel.cloneOperators(operators)
 	if err != nil {
@@ -95,11 +102,13 @@ func (panel *Panel) Execute(w http.ResponseWriter, r *http.Request, session *htm
 		type TemplateData struct {
 			Accounts  *data.Accounts
 			Operators *data.Operators
+			AuditLog  *data.AuditLog
 		}
 
 		return TemplateData{
 			Accounts:  accounts,
 			Operators: opsClone,
+			AuditLog:  auditLog,
 		}
 	}
 	return panel.BasePanel.Execute(w, r, session, dataProvider)
@@ -0,0 +1,142 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package siteacc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	userpb "github.com/cs3org/go-cs3apis/cs3/identity/user/v1beta1"
+	ctxpkg "github.com/cs3org/reva/pkg/ctx"
+	"github.com/cs3org/reva/pkg/siteacc/config"
+	"github.com/cs3org/reva/pkg/siteacc/data"
+	acchtml "github.com/cs3org/reva/pkg/siteacc/html"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSiteAccounts(t *testing.T) *SiteAccounts {
+	dir := t.TempDir()
+	conf := &config.Configuration{}
+	conf.Storage.Driver = "file"
+	conf.Storage.File.OperatorsFile = filepath.Join(dir, "operators.json")
+	conf.Storage.File.AccountsFile = filepath.Join(dir, "accounts.json")
+
+	log := zerolog.Nop()
+	siteacc, err := New(conf, &log)
+	if err != nil {
+		t.Fatalf("not expected error while creating the site accounts: %+v", err)
+	}
+	t.Cleanup(func() { _ = siteacc.Close(context.Background()) })
+	return siteacc
+}
+
+func newTestSession(r *http.Request, role string) *acchtml.Session {
+	session := acchtml.NewSession("siteacc_session", time.Hour, r)
+	if role != "" {
+		session.LoginUser(&data.Account{Email: "jane@example.org", Operator: "op-a"}, &data.Operator{ID: "op-a"}, role)
+	}
+	return session
+}
+
+func TestEndpointRoles(t *testing.T) {
+	siteacc := newTestSiteAccounts(t)
+
+	tests := []struct {
+		name      string
+		method    string
+		url       string
+		role      string
+		revaUser  bool
+		forbidden bool
+		html      bool
+	}{
+		{name: "operator lists accounts", method: http.MethodGet, url: "/list", role: data.RoleOperator, forbidden: true},
+		{name: "operator removes an account", method: http.MethodPost, url: "/remove", role: data.RoleOperator, forbidden: true},
+		{name: "operator grants sites access", method: http.MethodPost, url: "/grant-sites-access?status=true", role: data.RoleOperator, forbidden: true},
+		{name: "operator makes itself admin", method: http.MethodPost, url: "/set-access-role?role=admin", role: data.RoleOperator, forbidden: true},
+		{name: "operator opens the administration panel", method: http.MethodGet, url: "/admin", role: data.RoleOperator, forbidden: true, html: true},
+		{name: "account owner configures sites", method: http.MethodPost, url: "/sites-configure?invoker=user", role: data.RoleAccountOwner, forbidden: true},
+		{name: "account owner gets a site", method: http.MethodGet, url: "/site-get?site=site-a", role: data.RoleAccountOwner, forbidden: true},
+		{name: "anonymous updates an account", method: http.MethodPost, url: "/update?invoker=user", forbidden: true},
+		{name: "anonymous opens the account panel", method: http.MethodGet, url: "/account"},
+		{name: "operator gets a site", method: http.MethodGet, url: "/site-get?site=site-a", role: data.RoleOperator},
+		{name: "admin lists accounts", method: http.MethodGet, url: "/list", role: data.RoleAdmin},
+		{name: "reva user lists accounts", method: http.MethodGet, url: "/list", revaUser: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(tt.method, tt.url, strings.NewReader("{}"))
+			if tt.revaUser {
+				r = r.WithContext(ctxpkg.ContextSetUser(r.Context(), &userpb.User{Username: "admin"}))
+			}
+			w := httptest.NewRecorder()
+
+			siteacc.dispatchRequest(w, r, newTestSession(r, tt.role))
+
+			if !tt.forbidden {
+				assert.NotEqual(t, http.StatusForbidden, w.Code)
+				return
+			}
+
+			assert.Equal(t, http.StatusForbidden, w.Code)
+			if tt.html {
+				assert.Equal(t, "text/html; charset=UTF-8", w.Header().Get("Content-Type"))
+				assert.Contains(t, w.Body.String(), "Access denied")
+			} else {
+				resp := methodResponse{}
+				assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+				assert.False(t, resp.Success)
+				assert.NotEmpty(t, resp.Error)
+			}
+		})
+	}
+}
+
+func TestOperatorSitesScope(t *testing.T) {
+	siteacc := newTestSiteAccounts(t)
+	assert.NoError(t, siteacc.OperatorsManager().UpdateOperator(&data.Operator{ID: "op-b", Sites: []*data.Site{{ID: "site-b"}}}))
+
+	r := httptest.NewRequest(http.MethodGet, "/site-get?site=site-b", nil)
+	w := httptest.NewRecorder()
+	siteacc.dispatchRequest(w, r, newTestSession(r, data.RoleOperator))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	r = httptest.NewRequest(http.MethodGet, "/site-get?site=site-b", nil)
+	w = httptest.NewRecorder()
+	siteacc.dispatchRequest(w, r, newTestSession(r, data.RoleAdmin))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestResolveRole(t *testing.T) {
+	siteacc := newTestSiteAccounts(t)
+	umngr := siteacc.UsersManager()
+
+	assert.Equal(t, data.RoleAccountOwner, umngr.ResolveRole(&data.Account{}))
+	assert.Equal(t, data.RoleOperator, umngr.ResolveRole(&data.Account{Data: data.AccountData{SitesAccess: true}}))
+	assert.Equal(t, data.RoleAdmin, umngr.ResolveRole(&data.Account{Data: data.AccountData{AccessRole: data.RoleAdmin}}))
+	assert.Equal(t, data.RoleAccountOwner, umngr.ResolveRole(&data.Account{Data: data.AccountData{AccessRole: "superuser"}}))
+}
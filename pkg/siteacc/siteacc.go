@@ -19,6 +19,7 @@
 package siteacc
 
 import (
+	"context"
 	"fmt"
 	"html"
 	"net/http"
@@ -26,6 +27,7 @@ import (
 	"github.com/cs3org/reva/pkg/siteacc/alerting"
 	"github.com/cs3org/reva/pkg/siteacc/config"
 	"github.com/cs3org/reva/pkg/siteacc/data"
+	"github.com/cs3org/reva/pkg/siteacc/email"
 	acchtml "github.com/cs3org/reva/pkg/siteacc/html"
 	"github.com/cs3org/reva/pkg/siteacc/manager"
 	accpanel "github.com/cs3org/reva/pkg/siteacc/panels/account"
@@ -108,6 +110,7 @@ func (siteacc *SiteAccounts) initialize(conf *config.Configuration, log *zerolog
 		return errors.Wrap(err, "error creating the alerts dispatcher")
 	}
 	siteacc.alertsDispatcher = dispatcher
+	siteacc.accountsManager.SetAlertsDispatcher(dispatcher)
 
 	// Create the admin panel
 	if pnl, err := admin.NewPanel(conf, log); err == nil {
@@ -135,26 +138,31 @@ func (siteacc *SiteAccounts) RequestHandler() http.Handler {
 		defer r.Body.Close()
 
 		// Get the active session for the request (or create a new one); a valid session object will always be returned
-		siteacc.sessions.PurgeSessions() // Remove expired sessions first
 		session, err := siteacc.sessions.HandleRequest(w, r)
 		if err != nil {
 			siteacc.log.Err(err).Msg("an error occurred while handling sessions")
 		}
 
-		epHandled := false
-		for _, ep := range getEndpoints() {
-			if ep.Path == r.URL.Path {
-				ep.Handler(siteacc, ep, w, r, session)
-				epHandled = true
-				break
+		siteacc.dispatchRequest(w, r, session)
+	})
+}
+
+func (siteacc *SiteAccounts) dispatchRequest(w http.ResponseWriter, r *http.Request, session *acchtml.Session) {
+	for _, ep := range getEndpoints() {
+		if ep.Path == r.URL.Path {
+			// Every endpoint requires a minimum access role, which is enforced before dispatching the request
+			if !data.RoleSatisfies(getRole(r, session), ep.MinRole) {
+				callForbiddenEndpoint(siteacc, ep, w, r)
+				return
 			}
-		}
 
-		if !epHandled {
-			w.WriteHeader(http.StatusBadRequest)
-			_, _ = w.Write([]byte(fmt.Sprintf("Unknown endpoint %v", html.EscapeString(r.URL.Path))))
+			ep.Handler(siteacc, ep, w, r, session)
+			return
 		}
-	})
+	}
+
+	w.WriteHeader(http.StatusBadRequest)
+	_, _ = w.Write([]byte(fmt.Sprintf("Unknown endpoint %v", html.EscapeString(r.URL.Path))))
 }
 
 // ShowAdministrationPanel writes the administration panel HTTP output directly to the response writer.
@@ -162,7 +170,33 @@ func (siteacc *SiteAccounts) ShowAdministrationPanel(w http.ResponseWriter, r *h
 	// The admin panel only shows the stored accounts and offers actions through links, so let it use cloned data
 	accounts := siteacc.accountsManager.CloneAccounts(true)
 	operators := siteacc.operatorsManager.CloneOperators(false)
-	return siteacc.adminPanel.Execute(w, r, session, &accounts, &operators)
+	auditLog, err := siteacc.accountsManager.ReadAuditLog()
+	if err != nil {
+		return err
+	}
+	return siteacc.adminPanel.Execute(w, r, session, &accounts, &operators, &auditLog)
+}
+
+// ExportAdministrationData writes the data requested from the administration panel (accounts or audit log) directly to the response writer.
+func (siteacc *SiteAccounts) ExportAdministrationData(w http.ResponseWriter, r *http.Request) error {
+	// Exports use the same cloned data as the admin panel
+	accounts := siteacc.accountsManager.CloneAccounts(true)
+	auditLog, err := siteacc.accountsManager.ReadAuditLog()
+	if err != nil {
+		return err
+	}
+	return siteacc.adminPanel.Export(w, r, &accounts, &auditLog)
+}
+
+// ExportAccounts streams all accounts in the given format (CSV or JSON) directly to the response writer.
+func (siteacc *SiteAccounts) ExportAccounts(w http.ResponseWriter, format string) error {
+	// The export is read-only, so it works on cloned data
+	accounts := siteacc.accountsManager.CloneAccounts(true)
+	data.SetExportHeaders(w, "accounts", format)
+	return data.WriteAccounts(w, format, accounts, func(opID string) string {
+		opName, _ := data.QueryOperatorName(opID, siteacc.conf.Mentix.URL, siteacc.conf.Mentix.DataEndpoint)
+		return opName
+	})
 }
 
 // ShowAccountPanel writes the account panel HTTP output directly to the response writer.
@@ -190,6 +224,32 @@ func (siteacc *SiteAccounts) AlertsDispatcher() *alerting.Dispatcher {
 	return siteacc.alertsDispatcher
 }
 
+// Close flushes the pending alerts and emails, waiting until they have been sent or until the context is done,
+// and stops the background tasks. It can be called multiple times.
+func (siteacc *SiteAccounts) Close(ctx context.Context) error {
+	if err := siteacc.sessions.Close(ctx); err != nil {
+		return errors.Wrap(err, "unable to close the session manager")
+	}
+
+	// Provisioning failures are reported through the alerts dispatcher; the alerts and emails
+	// are flushed even if not all accounts have been provisioned in time
+	provisioningErr := siteacc.accountsManager.StopProvisioning(ctx)
+
+	if err := siteacc.alertsDispatcher.Close(ctx); err != nil {
+		return errors.Wrap(err, "unable to close the alerts dispatcher")
+	}
+
+	// Wait for the other notifications as well (e.g., about new accounts)
+	if err := email.Wait(ctx); err != nil {
+		return err
+	}
+
+	if provisioningErr != nil {
+		return errors.Wrap(provisioningErr, "unable to wait for the access provisioning")
+	}
+	return nil
+}
+
 // GetPublicEndpoints returns a list of all public endpoints.
 func (siteacc *SiteAccounts) GetPublicEndpoints() []string {
 	// TODO: Only for local testing!
@@ -1878,7 +1878,8 @@ func (fs *eosfs) Delete(ctx context.Context, ref *provider.Reference) error {
 		return err
 	}
 
-	return fs.c.Remove(ctx, auth, fn, false)
+	noRecycle, _ := ctx.Value(appctx.SkipTrash).(bool)
+	return fs.c.Remove(ctx, auth, fn, noRecycle)
 }
 
 func (fs *eosfs) deleteShadow(ctx context.Context, p string) error {
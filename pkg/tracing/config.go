@@ -25,6 +25,26 @@ import (
 type Config struct {
 	Agent     string `mapstructure:"agent"`
 	Collector string `mapstructure:"collector"`
+	// Propagators are the formats of the trace context propagated in the requests,
+	// among jaeger, tracecontext and baggage. Defaults to jaeger and tracecontext.
+	Propagators []string `mapstructure:"propagators"`
+	// Services overrides the tracing of single services, by their name.
+	// The services not listed are traced with the exporter configured above.
+	Services map[string]ServiceConfig `mapstructure:"services"`
+}
+
+// ServiceConfig is the tracing configuration of a single service.
+type ServiceConfig struct {
+	// Enabled turns the tracing of the service off when false. Defaults to true.
+	Enabled *bool `mapstructure:"enabled"`
+	// Agent and Collector, if set, are the endpoint where the spans
+	// of the service are exported, in place of the global one.
+	Agent     string `mapstructure:"agent"`
+	Collector string `mapstructure:"collector"`
+}
+
+func (c ServiceConfig) enabled() bool {
+	return c.Enabled == nil || *c.Enabled
 }
 
 func newConfig(v interface{}) (*Config, error) {
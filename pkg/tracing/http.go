@@ -20,6 +20,8 @@ package tracing
 
 import (
 	"net/http"
+	"sort"
+	"strings"
 
 	"github.com/cs3org/reva/pkg/rhttp/utils"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
@@ -48,9 +50,22 @@ func (m *HTTPMiddleware) Middleware(h http.Handler) http.Handler {
 }
 
 func Middleware(h http.Handler, ms map[string]HTTPMiddlewarer) http.Handler {
+	// every service traces the requests to its prefix and to the urls below it,
+	// the service with the longest matching prefix is selected
+	prefixes := make([]string, 0, len(ms))
+	for prefix := range ms {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	matcher := &utils.Matcher{}
 	handlers := map[string]http.Handler{}
-	for prefix, m := range ms {
-		handlers[prefix] = m.Middleware(h)
+	for _, prefix := range prefixes {
+		pattern := strings.TrimRight(prefix, "/") + "/**"
+		if err := matcher.Add(pattern); err != nil {
+			continue
+		}
+		handlers[pattern] = ms[prefix].Middleware(h)
 	}
 
 	noopHandler := otelhttp.NewHandler(h, "",
@@ -59,20 +74,8 @@ func Middleware(h http.Handler, ms map[string]HTTPMiddlewarer) http.Handler {
 	)
 
 	handlerFunc := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if h, ok := handlers[r.URL.Path]; ok {
-			h.ServeHTTP(w, r)
-			return
-		}
-
-		var match string
-		for prefix := range handlers {
-			if utils.URLHasPrefix(r.URL.Path, prefix) && len(prefix) > len(match) {
-				match = prefix
-			}
-		}
-
-		if h, ok := handlers[match]; ok {
-			h.ServeHTTP(w, r)
+		if match, ok := matcher.MatchURL(r.URL); ok {
+			handlers[match.Pattern].ServeHTTP(w, r)
 			return
 		}
 
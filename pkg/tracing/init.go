@@ -24,6 +24,7 @@ import (
 	"sync"
 
 	jaegerExporter "go.opentelemetry.io/otel/exporters/jaeger"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 )
 
 var initOnce sync.Once
@@ -39,40 +40,80 @@ func Init(v interface{}, l ...LoggerOption) {
 			return
 		}
 
-		var endpointOption jaegerExporter.EndpointOption
-		switch {
-		case c.Collector != "" && c.Agent != "":
-			err := fmt.Errorf("more than one tracing endpoint option provided - agent: \"%s\", collector: \"%s\"", c.Agent, c.Collector)
-			log.Error().Err(err).Msg("error initializing tracing")
+		prop, err := newPropagator(c.Propagators)
+		if err != nil {
+			log.Error().Err(err).Msgf("error initializing tracing")
 			return
-		case c.Agent != "":
-			// Endpoint option to create a Jaeger exporter that sends spans to the Jaeger Agent
-			// https://pkg.go.dev/go.opentelemetry.io/otel/exporters/jaeger#WithAgentEndpoint
-			endpointOption, err = withAgentEndpoint(c.Agent)
-			if err != nil {
-				log.Error().Err(err).Msgf("error initializing tracing")
-				return
-			}
-		case c.Collector != "":
-			// Endpoint option to create a Jaeger exporter that sends spans
-			// directly to the Jaeger Collector (without a Jaeger Agent in the middle)
-			// https://pkg.go.dev/go.opentelemetry.io/otel/exporters/jaeger#WithCollectorEndpoint
-			endpointOption = withCollectorEndpoint(c.Collector)
-		default:
-			log.Warn().Msg("tracing disabled - using NoopExporter")
+		}
+		tr.prop = prop
+
+		services, err := newServices(c.Services)
+		if err != nil {
+			log.Error().Err(err).Msgf("error initializing tracing")
 			return
 		}
+		tr.services = services
 
-		log.Info().Msg("creating jaegerExporter")
-		exp, err := jaegerExporter.New(endpointOption)
+		exp, err := newExporter(c.Agent, c.Collector)
 		if err != nil {
 			log.Error().Err(err).Msgf("error initializing tracing")
 			return
 		}
+		if exp == nil {
+			log.Warn().Msg("tracing disabled - using NoopExporter")
+			return
+		}
 		tr.exp = exp
 	})
 }
 
+// newServices creates the overrides of the tracing of the services,
+// with their own exporters when configured.
+func newServices(c map[string]ServiceConfig) (map[string]*serviceTracing, error) {
+	services := make(map[string]*serviceTracing, len(c))
+	for name, sc := range c {
+		if !sc.enabled() {
+			log.Info().Msgf("tracing disabled for service \"%s\"", name)
+			services[name] = &serviceTracing{disabled: true}
+			continue
+		}
+		exp, err := newExporter(sc.Agent, sc.Collector)
+		if err != nil {
+			return nil, fmt.Errorf("error creating the exporter of service \"%s\": %w", name, err)
+		}
+		services[name] = &serviceTracing{exp: exp}
+	}
+	return services, nil
+}
+
+// newExporter creates a Jaeger exporter sending the spans to the given
+// agent or collector. No exporter is returned if none of them is set.
+func newExporter(agent, collector string) (tracesdk.SpanExporter, error) {
+	var endpointOption jaegerExporter.EndpointOption
+	switch {
+	case collector != "" && agent != "":
+		return nil, fmt.Errorf("more than one tracing endpoint option provided - agent: \"%s\", collector: \"%s\"", agent, collector)
+	case agent != "":
+		// Endpoint option to create a Jaeger exporter that sends spans to the Jaeger Agent
+		// https://pkg.go.dev/go.opentelemetry.io/otel/exporters/jaeger#WithAgentEndpoint
+		var err error
+		endpointOption, err = withAgentEndpoint(agent)
+		if err != nil {
+			return nil, err
+		}
+	case collector != "":
+		// Endpoint option to create a Jaeger exporter that sends spans
+		// directly to the Jaeger Collector (without a Jaeger Agent in the middle)
+		// https://pkg.go.dev/go.opentelemetry.io/otel/exporters/jaeger#WithCollectorEndpoint
+		endpointOption = withCollectorEndpoint(collector)
+	default:
+		return nil, nil
+	}
+
+	log.Info().Msg("creating jaegerExporter")
+	return jaegerExporter.New(endpointOption)
+}
+
 func withAgentEndpoint(agent string) (jaegerExporter.EndpointOption, error) {
 	log.Info().Msgf("creating jaegerExporter.EndpointOption for agent \"%s\"", agent)
 
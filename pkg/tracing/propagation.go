@@ -0,0 +1,53 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package tracing
+
+import (
+	"fmt"
+
+	jaegerPropagator "go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// defaultPropagators are the formats of the trace context propagated
+// when none is configured.
+var defaultPropagators = []string{"jaeger", "tracecontext"}
+
+// newPropagator returns a propagator injecting and extracting the trace context
+// in all the given formats, among jaeger, tracecontext (W3C) and baggage.
+func newPropagator(names []string) (propagation.TextMapPropagator, error) {
+	if len(names) == 0 {
+		names = defaultPropagators
+	}
+
+	props := make([]propagation.TextMapPropagator, 0, len(names))
+	for _, n := range names {
+		switch n {
+		case "jaeger":
+			props = append(props, jaegerPropagator.Jaeger{})
+		case "tracecontext":
+			props = append(props, propagation.TraceContext{})
+		case "baggage":
+			props = append(props, propagation.Baggage{})
+		default:
+			return nil, fmt.Errorf("unknown tracing propagator \"%s\"", n)
+		}
+	}
+	return propagation.NewCompositeTextMapPropagator(props...), nil
+}
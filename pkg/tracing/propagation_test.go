@@ -0,0 +1,161 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	spanID  = "00f067aa0ba902b7"
+)
+
+var parentHeaders = map[string]map[string]string{
+	"jaeger":       {"uber-trace-id": traceID + ":" + spanID + ":0:1"},
+	"tracecontext": {"traceparent": "00-" + traceID + "-" + spanID + "-01"},
+}
+
+// recordSpans makes the service named after the test record its spans
+// in the returned exporter, propagating the trace context in the default formats.
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	exp, prop := tr.exp, tr.prop
+	t.Cleanup(func() {
+		tr.exp, tr.prop = exp, prop
+		tr.reg.Delete(t.Name())
+	})
+
+	rec := tracetest.NewInMemoryExporter()
+	tr.exp = rec
+	tr.prop, _ = newPropagator(nil)
+	return rec
+}
+
+func flush(t *testing.T, name string) {
+	if err := tr.tracerProvider(name).(*tracesdk.TracerProvider).ForceFlush(context.Background()); err != nil {
+		t.Fatalf("error flushing spans: %v", err)
+	}
+}
+
+func assertChildOfParent(t *testing.T, rec *tracetest.InMemoryExporter) {
+	spans := rec.GetSpans()
+	if assert.Len(t, spans, 1) {
+		assert.Equal(t, traceID, spans[0].Parent.TraceID().String())
+		assert.Equal(t, spanID, spans[0].Parent.SpanID().String())
+		assert.True(t, spans[0].Parent.IsRemote())
+		assert.Equal(t, traceID, spans[0].SpanContext.TraceID().String())
+	}
+}
+
+func TestNewPropagator(t *testing.T) {
+	prop, err := newPropagator(nil)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"uber-trace-id", "traceparent", "tracestate"}, prop.Fields())
+
+	prop, err = newPropagator([]string{"tracecontext", "baggage"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"traceparent", "tracestate", "baggage"}, prop.Fields())
+
+	_, err = newPropagator([]string{"b3"})
+	assert.Error(t, err)
+}
+
+func TestHTTPMiddlewarePropagation(t *testing.T) {
+	for format, headers := range parentHeaders {
+		t.Run(format, func(t *testing.T) {
+			rec := recordSpans(t)
+			name := t.Name()
+
+			m := &HTTPMiddleware{}
+			m.SetMiddleware(name, "/data")
+			h := m.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			r := httptest.NewRequest(http.MethodGet, "/data/file", nil)
+			for k, v := range headers {
+				r.Header.Set(k, v)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), r)
+
+			flush(t, name)
+			assertChildOfParent(t, rec)
+		})
+	}
+}
+
+func TestGrpcServerInterceptorPropagation(t *testing.T) {
+	for format, headers := range parentHeaders {
+		t.Run(format, func(t *testing.T) {
+			rec := recordSpans(t)
+			name := t.Name()
+
+			m := &GrpcMiddleware{}
+			m.SetInterceptors(name)
+
+			ctx := metadata.NewIncomingContext(context.Background(), metadata.New(headers))
+			info := &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+			_, err := m.UnaryServerInterceptor(ctx, nil, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+				return nil, nil
+			})
+			assert.NoError(t, err)
+
+			flush(t, name)
+			assertChildOfParent(t, rec)
+		})
+	}
+}
+
+func TestGrpcClientInterceptorPropagation(t *testing.T) {
+	recordSpans(t)
+
+	ctx, span := tr.tracerProvider(t.Name()).Tracer("test").Start(context.Background(), "client")
+	defer span.End()
+
+	var md metadata.MD
+	invoker := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, opts ...grpc.CallOption) error {
+		md, _ = metadata.FromOutgoingContext(ctx)
+		return nil
+	}
+	// the connection is never established, as the invoker does not use it
+	cc, err := grpc.Dial("localhost:0", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer cc.Close()
+
+	err = UnaryClientInterceptor()(ctx, "/test.Service/Method", nil, nil, cc, invoker)
+	assert.NoError(t, err)
+
+	// the trace context is sent in all the default formats
+	sc := trace.SpanContextFromContext(ctx)
+	if assert.Len(t, md.Get("traceparent"), 1) {
+		assert.Contains(t, md.Get("traceparent")[0], sc.TraceID().String())
+	}
+	if assert.Len(t, md.Get("uber-trace-id"), 1) {
+		assert.Contains(t, md.Get("uber-trace-id")[0], sc.TraceID().String())
+	}
+}
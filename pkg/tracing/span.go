@@ -20,9 +20,15 @@ package tracing
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"net/http"
 	"sync"
 
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -51,3 +57,43 @@ func SpanStart(ctx context.Context, serviceName string, tracerName string, spanN
 	tp := tr.tracerProvider(serviceName)
 	return spanStart(ctx, tp, tracerName, spanName, opts...)
 }
+
+// RecordError records the error on the span and marks the span as failed.
+// A nil error leaves the span untouched.
+func RecordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// EndSpan sets the attributes on the span, records the error, if any, and
+// ends the span. With a named error result, it is meant to be deferred as
+//
+//	defer func() { tracing.EndSpan(span, err) }()
+func EndSpan(span trace.Span, err error, attrs ...attribute.KeyValue) {
+	span.SetAttributes(attrs...)
+	RecordError(span, err)
+	span.End()
+}
+
+// EndSpanWithStatus is like EndSpan for the CS3 APIs, which report most of
+// the failures in the status of the response: a status other than OK is
+// recorded as an error when err is nil.
+func EndSpanWithStatus(span trace.Span, st *rpc.Status, err error, attrs ...attribute.KeyValue) {
+	if err == nil && st != nil && st.Code != rpc.Code_CODE_OK {
+		err = errors.New(st.Code.String() + ": " + st.Message)
+	}
+	EndSpan(span, err, attrs...)
+}
+
+// Hashed returns an attribute holding a hash of a secret value, such as a
+// token, allowing to correlate the spans without leaking the secret.
+func Hashed(key attribute.Key, secret string) attribute.KeyValue {
+	if secret == "" {
+		return key.String("")
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return key.String(hex.EncodeToString(sum[:8]))
+}
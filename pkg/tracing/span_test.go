@@ -0,0 +1,130 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	rpc "github.com/cs3org/go-cs3apis/cs3/rpc/v1beta1"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func recordedSpan(t *testing.T, end func(span trace.Span)) tracetest.SpanStub {
+	rec := tracetest.NewInMemoryExporter()
+	tp := tracesdk.NewTracerProvider(tracesdk.WithSyncer(rec))
+	_, span := tp.Tracer("test").Start(context.Background(), "op")
+	end(span)
+
+	spans := rec.GetSpans()
+	if !assert.Len(t, spans, 1) {
+		t.FailNow()
+	}
+	return spans[0]
+}
+
+func attributes(s tracetest.SpanStub) map[attribute.Key]attribute.Value {
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, a := range s.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	return attrs
+}
+
+func TestEndSpan(t *testing.T) {
+	key := attribute.Key("endpoint")
+
+	s := recordedSpan(t, func(span trace.Span) {
+		EndSpan(span, errors.New("boom"), key.String("localhost:1"))
+	})
+	assert.Equal(t, codes.Error, s.Status.Code)
+	assert.Equal(t, "boom", s.Status.Description)
+	assert.Equal(t, "localhost:1", attributes(s)[key].AsString())
+	if assert.Len(t, s.Events, 1) {
+		assert.Equal(t, "exception", s.Events[0].Name)
+	}
+
+	s = recordedSpan(t, func(span trace.Span) {
+		EndSpan(span, nil, key.String("localhost:1"))
+	})
+	assert.Equal(t, codes.Unset, s.Status.Code)
+	assert.Empty(t, s.Events)
+	assert.Equal(t, "localhost:1", attributes(s)[key].AsString())
+}
+
+func TestEndSpanWithStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		st     *rpc.Status
+		err    error
+		code   codes.Code
+		reason string
+	}{
+		{
+			name: "ok",
+			st:   &rpc.Status{Code: rpc.Code_CODE_OK},
+			code: codes.Unset,
+		},
+		{
+			name: "no status",
+			code: codes.Unset,
+		},
+		{
+			name:   "failed status",
+			st:     &rpc.Status{Code: rpc.Code_CODE_NOT_FOUND, Message: "share not found"},
+			code:   codes.Error,
+			reason: "CODE_NOT_FOUND: share not found",
+		},
+		{
+			name:   "error takes precedence",
+			st:     &rpc.Status{Code: rpc.Code_CODE_INTERNAL},
+			err:    errors.New("boom"),
+			code:   codes.Error,
+			reason: "boom",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := recordedSpan(t, func(span trace.Span) {
+				EndSpanWithStatus(span, tt.st, tt.err)
+			})
+			assert.Equal(t, tt.code, s.Status.Code)
+			assert.Equal(t, tt.reason, s.Status.Description)
+		})
+	}
+}
+
+func TestHashed(t *testing.T) {
+	key := attribute.Key("share.token_hash")
+
+	a := Hashed(key, "secret-token")
+	assert.Equal(t, key, a.Key)
+	assert.Len(t, a.Value.AsString(), 16)
+	assert.NotContains(t, a.Value.AsString(), "secret")
+	assert.Equal(t, a, Hashed(key, "secret-token"))
+	assert.NotEqual(t, a, Hashed(key, "other-token"))
+	assert.Equal(t, "", Hashed(key, "").Value.AsString())
+}
@@ -22,7 +22,7 @@ import (
 	"os"
 	"sync"
 
-	jaegerPropagator "go.opentelemetry.io/contrib/propagators/jaeger"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/sdk/resource"
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 	"go.opentelemetry.io/otel/sdk/trace/tracetest"
@@ -33,18 +33,27 @@ import (
 var tr *tracing
 
 type tracing struct {
-	exp  tracesdk.SpanExporter
-	prop jaegerPropagator.Jaeger
-	noop trace.TracerProvider
-	reg  sync.Map
-	mux  sync.Mutex
+	exp      tracesdk.SpanExporter
+	prop     propagation.TextMapPropagator
+	noop     trace.TracerProvider
+	services map[string]*serviceTracing
+	reg      sync.Map
+	mux      sync.Mutex
+}
+
+// serviceTracing overrides the tracing of a service.
+type serviceTracing struct {
+	disabled bool
+	// the exporter of the spans of the service, if different from the global one
+	exp tracesdk.SpanExporter
 }
 
 func init() {
+	prop, _ := newPropagator(defaultPropagators)
 	tr = &tracing{
 		noop: trace.NewNoopTracerProvider(),
 		exp:  tracetest.NewNoopExporter(),
-		prop: jaegerPropagator.Jaeger{},
+		prop: prop,
 	}
 }
 
@@ -60,16 +69,29 @@ func (t *tracing) tracerProvider(name string) trace.TracerProvider {
 
 	var tp = t.noop
 
+	exp := t.exp
+	if s, ok := t.services[name]; ok {
+		if s.disabled {
+			t.reg.Store(name, tp)
+			return tp
+		}
+		if s.exp != nil {
+			exp = s.exp
+		}
+	}
+
 	hostname, err := os.Hostname()
 	if err != nil {
 		t.reg.Store(name, tp)
 		return tp
 	}
 
+	// the attributes are schemaless, as the schema of the default resource
+	// follows the version of the sdk, and resources with different schemas
+	// cannot be merged
 	r, err := resource.Merge(
 		resource.Default(),
-		resource.NewWithAttributes(
-			semconv.SchemaURL,
+		resource.NewSchemaless(
 			semconv.ServiceNameKey.String(name),
 			semconv.HostNameKey.String(hostname),
 		),
@@ -80,7 +102,7 @@ func (t *tracing) tracerProvider(name string) trace.TracerProvider {
 	}
 
 	tp = tracesdk.NewTracerProvider(
-		tracesdk.WithBatcher(t.exp),
+		tracesdk.WithBatcher(exp),
 		tracesdk.WithResource(r),
 	)
 	t.reg.Store(name, tp)
@@ -0,0 +1,120 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// withServices overrides the tracing of the services for the duration of the test.
+func withServices(t *testing.T, services map[string]*serviceTracing) {
+	prev := tr.services
+	t.Cleanup(func() {
+		tr.services = prev
+		for name := range services {
+			tr.reg.Delete(name)
+		}
+	})
+	tr.services = services
+}
+
+func startSpan(name string) {
+	_, span := tr.tracerProvider(name).Tracer("test").Start(context.Background(), name)
+	span.End()
+	if tp, ok := tr.tracerProvider(name).(*tracesdk.TracerProvider); ok {
+		_ = tp.ForceFlush(context.Background())
+	}
+}
+
+func spanNames(rec *tracetest.InMemoryExporter) []string {
+	names := []string{}
+	for _, s := range rec.GetSpans() {
+		names = append(names, s.Name)
+	}
+	return names
+}
+
+func TestServicesConfig(t *testing.T) {
+	c, err := newConfig(map[string]interface{}{
+		"collector": "http://localhost:14268/api/traces",
+		"services": map[string]interface{}{
+			"ocdav":        map[string]interface{}{"enabled": true},
+			"dataprovider": map[string]interface{}{"enabled": false},
+			"gateway":      map[string]interface{}{"agent": "localhost:6831"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.True(t, c.Services["ocdav"].enabled())
+	assert.False(t, c.Services["dataprovider"].enabled())
+	assert.True(t, c.Services["gateway"].enabled())
+	assert.Equal(t, "localhost:6831", c.Services["gateway"].Agent)
+
+	services, err := newServices(c.Services)
+	assert.NoError(t, err)
+	assert.False(t, services["ocdav"].disabled)
+	assert.Nil(t, services["ocdav"].exp)
+	assert.True(t, services["dataprovider"].disabled)
+	assert.NotNil(t, services["gateway"].exp)
+
+	_, err = newServices(map[string]ServiceConfig{
+		"gateway": {Agent: "localhost:6831", Collector: "http://localhost:14268/api/traces"},
+	})
+	assert.Error(t, err)
+}
+
+func TestServicesEnabled(t *testing.T) {
+	rec := recordSpans(t)
+	disabled := false
+	enabled := true
+	services, err := newServices(map[string]ServiceConfig{
+		"TestServicesEnabled-ocdav":        {Enabled: &enabled},
+		"TestServicesEnabled-dataprovider": {Enabled: &disabled},
+	})
+	assert.NoError(t, err)
+	withServices(t, services)
+	t.Cleanup(func() { tr.reg.Delete("TestServicesEnabled-gateway") })
+
+	startSpan("TestServicesEnabled-ocdav")
+	startSpan("TestServicesEnabled-dataprovider")
+	// the services not listed are traced
+	startSpan("TestServicesEnabled-gateway")
+
+	assert.ElementsMatch(t, []string{"TestServicesEnabled-ocdav", "TestServicesEnabled-gateway"}, spanNames(rec))
+	assert.Equal(t, tr.noop, tr.tracerProvider("TestServicesEnabled-dataprovider"))
+}
+
+func TestServicesExporter(t *testing.T) {
+	rec := recordSpans(t)
+	serviceRec := tracetest.NewInMemoryExporter()
+	withServices(t, map[string]*serviceTracing{
+		"TestServicesExporter-ocdav": {exp: serviceRec},
+	})
+	t.Cleanup(func() { tr.reg.Delete("TestServicesExporter-gateway") })
+
+	startSpan("TestServicesExporter-ocdav")
+	startSpan("TestServicesExporter-gateway")
+
+	assert.Equal(t, []string{"TestServicesExporter-ocdav"}, spanNames(serviceRec))
+	assert.Equal(t, []string{"TestServicesExporter-gateway"}, spanNames(rec))
+}
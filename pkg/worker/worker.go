@@ -0,0 +1,148 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+// Package worker coordinates the lifecycle of the goroutines running in the
+// background of the services, so that they are stopped before the process exits.
+package worker
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Group is a set of workers stopped together.
+type Group struct {
+	parent *Group
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu      sync.Mutex
+	n       int
+	idle    chan struct{} // closed when the last running worker returns
+	running map[string]int
+}
+
+// process is the group of all the workers of the process,
+// stopped when the servers shut down.
+var process = newGroup(nil)
+
+// NewGroup returns a new group of workers, stopped by Stop or
+// when the process shuts down.
+func NewGroup() *Group {
+	return newGroup(process)
+}
+
+func newGroup(parent *Group) *Group {
+	ctx := context.Background()
+	if parent != nil {
+		ctx = parent.ctx
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &Group{
+		parent:  parent,
+		ctx:     ctx,
+		cancel:  cancel,
+		running: map[string]int{},
+	}
+}
+
+// Start runs f in a new goroutine. The context given to f is canceled
+// when the group is stopped, and f is expected to return then.
+func (g *Group) Start(name string, f func(ctx context.Context)) {
+	for p := g; p != nil; p = p.parent {
+		p.add(name)
+	}
+	go func() {
+		defer func() {
+			for p := g; p != nil; p = p.parent {
+				p.done(name)
+			}
+		}()
+		f(g.ctx)
+	}()
+}
+
+func (g *Group) add(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.n == 0 {
+		g.idle = make(chan struct{})
+	}
+	g.n++
+	g.running[name]++
+}
+
+func (g *Group) done(name string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.n--
+	if g.running[name]--; g.running[name] == 0 {
+		delete(g.running, name)
+	}
+	if g.n == 0 {
+		close(g.idle)
+	}
+}
+
+// Stop cancels the context of the workers, and waits until all of them
+// have returned, or until ctx is done. The workers started afterwards
+// get an already canceled context. It can be called multiple times.
+func (g *Group) Stop(ctx context.Context) error {
+	g.cancel()
+
+	g.mu.Lock()
+	if g.n == 0 {
+		g.mu.Unlock()
+		return nil
+	}
+	idle := g.idle
+	g.mu.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return errors.Wrapf(ctx.Err(), "workers still running: %s", strings.Join(g.pending(), ", "))
+	}
+}
+
+func (g *Group) pending() []string {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	names := make([]string, 0, len(g.running))
+	for name := range g.running {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Start runs f in a new goroutine, until the process shuts down.
+func Start(name string, f func(ctx context.Context)) {
+	process.Start(name, f)
+}
+
+// Stop stops all the workers of the process, waiting until they have
+// returned or until ctx is done.
+func Stop(ctx context.Context) error {
+	return process.Stop(ctx)
+}
@@ -0,0 +1,103 @@
+// Copyright 2018-2023 CERN
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+//
+// In applying this license, CERN does not waive the privileges and immunities
+// granted to it by virtue of its status as an Intergovernmental Organization
+// or submit itself to any jurisdiction.
+
+package worker
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+func TestStop(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	g := newGroup(nil)
+	stopped := make(chan string, 2)
+	for _, name := range []string{"a", "b"} {
+		name := name
+		g.Start(name, func(ctx context.Context) {
+			<-ctx.Done()
+			stopped <- name
+		})
+	}
+
+	if err := g.Stop(context.Background()); err != nil {
+		t.Fatalf("not expected error while stopping: %v", err)
+	}
+	if len(stopped) != 2 {
+		t.Fatalf("expected 2 stopped workers, got %d", len(stopped))
+	}
+
+	// the workers started afterwards are stopped right away
+	g.Start("c", func(ctx context.Context) {
+		<-ctx.Done()
+	})
+	if err := g.Stop(context.Background()); err != nil {
+		t.Fatalf("not expected error while stopping again: %v", err)
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	g := newGroup(nil)
+	release := make(chan struct{})
+	g.Start("stuck", func(ctx context.Context) {
+		<-release
+	})
+	g.Start("quick", func(ctx context.Context) {
+		<-ctx.Done()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := g.Stop(ctx)
+	if err == nil {
+		t.Fatalf("expected error while stopping a stuck worker")
+	}
+	if !strings.Contains(err.Error(), "stuck") || strings.Contains(err.Error(), "quick") {
+		t.Fatalf("expected only the stuck worker to be reported, got %v", err)
+	}
+
+	close(release)
+	if err := g.Stop(context.Background()); err != nil {
+		t.Fatalf("not expected error while stopping: %v", err)
+	}
+}
+
+func TestStopParent(t *testing.T) {
+	defer goleak.VerifyNone(t)
+
+	parent := newGroup(nil)
+	child := newGroup(parent)
+	child.Start("child", func(ctx context.Context) {
+		<-ctx.Done()
+	})
+
+	// stopping the parent stops and waits for the workers of the children
+	if err := parent.Stop(context.Background()); err != nil {
+		t.Fatalf("not expected error while stopping the parent: %v", err)
+	}
+	if err := child.Stop(context.Background()); err != nil {
+		t.Fatalf("not expected error while stopping the child: %v", err)
+	}
+}
@@ -82,7 +82,8 @@ CREATE TABLE IF NOT EXISTS ocm_tokens (
     token VARCHAR(255) NOT NULL PRIMARY KEY,
     initiator VARCHAR(255) NOT NULL,
     expiration DATETIME NOT NULL,
-    description VARCHAR(255) DEFAULT NULL
+    description VARCHAR(255) DEFAULT NULL,
+    accepted BOOLEAN NOT NULL DEFAULT FALSE
 )`
 	table2 := `
 CREATE TABLE IF NOT EXISTS ocm_remote_users (